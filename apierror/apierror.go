@@ -0,0 +1,78 @@
+package apierror
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"signerflow-crl/i18n"
+	"signerflow-crl/requestid"
+)
+
+// Códigos de error legibles por máquina; el mensaje es solo informativo
+const (
+	CodeInternal            = "INTERNAL_ERROR"
+	CodeNotFound            = "NOT_FOUND"
+	CodeUnauthorized        = "UNAUTHORIZED"
+	CodeForbidden           = "FORBIDDEN"
+	CodeRateLimited         = "RATE_LIMITED"
+	CodeInvalidRequest      = "INVALID_REQUEST"
+	CodeInvalidParameter    = "INVALID_PARAMETER"
+	CodeMissingParameter    = "MISSING_PARAMETER"
+	CodeInvalidFormat       = "INVALID_FORMAT"
+	CodeSerialRequired      = "SERIAL_REQUIRED"
+	CodeInvalidSerial       = "INVALID_SERIAL"
+	CodeInvalidRange        = "INVALID_RANGE"
+	CodeInvalidFingerprint  = "INVALID_FINGERPRINT"
+	CodeCertificateRequired = "CERTIFICATE_REQUIRED"
+	CodeInvalidCertificate  = "INVALID_CERTIFICATE"
+	CodeInvalidID           = "INVALID_ID"
+	CodeInvalidURL          = "INVALID_URL"
+	CodeInvalidCRL          = "INVALID_CRL"
+	CodeInvalidTLSConfig    = "INVALID_TLS_CONFIG"
+	CodeAlreadyExists       = "ALREADY_EXISTS"
+	CodeRefreshInProgress   = "REFRESH_IN_PROGRESS"
+	CodeSigningDisabled     = "SIGNING_DISABLED"
+	CodeCacheDisabled       = "CACHE_DISABLED"
+	CodeTimeout             = "TIMEOUT"
+	CodeDegraded            = "SERVICE_DEGRADED"
+)
+
+// Detail es el contenido del sobre de error
+type Detail struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Response es el sobre uniforme de las respuestas de error: {"error": {...}}
+type Response struct {
+	Error Detail `json:"error"`
+}
+
+// New arma el sobre de error con el ID de la petición en curso
+func New(c *gin.Context, code, message string) Response {
+	return Response{
+		Error: Detail{
+			Code:      code,
+			Message:   message,
+			RequestID: requestid.FromContext(c.Request.Context()),
+		},
+	}
+}
+
+// Respond escribe el sobre de error con el status indicado y el mensaje en el idioma de
+// Accept-Language. Un error del servidor causado por el vencimiento del plazo de la
+// petición se responde como 504.
+func Respond(c *gin.Context, status int, code string, message i18n.Message, args ...interface{}) {
+	if status >= http.StatusInternalServerError && errors.Is(c.Request.Context().Err(), context.DeadlineExceeded) {
+		status, code, message, args = http.StatusGatewayTimeout, CodeTimeout, i18n.MsgTimeout, nil
+	}
+	c.JSON(status, New(c, code, i18n.T(c, message, args...)))
+}
+
+// Abort escribe el sobre de error y detiene la cadena de handlers; para uso en middleware
+func Abort(c *gin.Context, status int, code string, message i18n.Message, args ...interface{}) {
+	c.AbortWithStatusJSON(status, New(c, code, i18n.T(c, message, args...)))
+}
@@ -0,0 +1,153 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ErrCircuitOpen indica que el circuit breaker está abierto y la llamada a Redis no se realizó
+var ErrCircuitOpen = errors.New("redis circuit breaker is open")
+
+// Estados del circuit breaker
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half_open"
+)
+
+// BreakerConfig define tras cuántos fallos consecutivos se abre el circuito y cuánto
+// tiempo permanece abierto antes de dejar pasar una llamada de prueba
+type BreakerConfig struct {
+	Threshold int
+	Cooldown  time.Duration
+}
+
+// circuitBreaker es un hook de go-redis que corta las llamadas a Redis tras varios fallos
+// consecutivos, para que las consultas pasen directo a la base de datos sin esperar timeouts
+type circuitBreaker struct {
+	mu       sync.Mutex
+	cfg      BreakerConfig
+	state    string
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+type allowedKey struct{}
+
+func newCircuitBreaker(cfg BreakerConfig) *circuitBreaker {
+	return &circuitBreaker{cfg: cfg, state: BreakerClosed}
+}
+
+// allow indica si la llamada puede hacerse; con el circuito abierto, pasado el cooldown
+// deja pasar una sola llamada de prueba
+func (b *circuitBreaker) allow() bool {
+	if b.cfg.Threshold <= 0 {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.cfg.Cooldown {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		b.probing = true
+		return true
+	case BreakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+func (b *circuitBreaker) record(failed bool) {
+	if b.cfg.Threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if !failed {
+		b.state = BreakerClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.cfg.Threshold {
+		b.state = BreakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+func (b *circuitBreaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen && time.Since(b.openedAt) >= b.cfg.Cooldown {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+// isFailure distingue los errores de conexión o timeout de los resultados normales de Redis
+func isFailure(err error) bool {
+	if err == nil || err == redis.Nil || errors.Is(err, context.Canceled) || errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
+
+	var redisErr redis.Error
+	return !errors.As(err, &redisErr)
+}
+
+func (b *circuitBreaker) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	if !b.allow() {
+		return ctx, ErrCircuitOpen
+	}
+	return context.WithValue(ctx, allowedKey{}, true), nil
+}
+
+func (b *circuitBreaker) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	// go-redis llama AfterProcess incluso cuando BeforeProcess rechazó la llamada
+	if allowed, _ := ctx.Value(allowedKey{}).(bool); allowed {
+		b.record(isFailure(cmd.Err()))
+	}
+	return nil
+}
+
+func (b *circuitBreaker) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	if !b.allow() {
+		return ctx, ErrCircuitOpen
+	}
+	return context.WithValue(ctx, allowedKey{}, true), nil
+}
+
+func (b *circuitBreaker) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	if allowed, _ := ctx.Value(allowedKey{}).(bool); !allowed {
+		return nil
+	}
+
+	failed := false
+	for _, cmd := range cmds {
+		if isFailure(cmd.Err()) {
+			failed = true
+			break
+		}
+	}
+	b.record(failed)
+	return nil
+}
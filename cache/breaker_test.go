@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"signerflow-crl/cache/redistest"
+)
+
+func TestCircuitBreakerTripsAndResets(t *testing.T) {
+	srv := redistest.NewServer(t)
+	const cooldown = 200 * time.Millisecond
+	client, err := NewRedisClient(srv.Addr(), "", 0, "", BreakerConfig{Threshold: 2, Cooldown: cooldown})
+	if err != nil {
+		t.Fatalf("NewRedisClient: %v", err)
+	}
+	defer client.Close()
+	ctx := context.Background()
+
+	if _, err := client.GetCertificateStatus(ctx, "1"); err != nil {
+		t.Fatalf("GetCertificateStatus with Redis up: %v", err)
+	}
+	if state := client.BreakerState(); state != BreakerClosed {
+		t.Fatalf("got state %s with Redis up, want %s", state, BreakerClosed)
+	}
+
+	// Dos fallos consecutivos abren el circuito
+	srv.SetDown(true)
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetCertificateStatus(ctx, "1"); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("call %d with Redis down: got %v, want a connection error", i+1, err)
+		}
+	}
+	if state := client.BreakerState(); state != BreakerOpen {
+		t.Fatalf("got state %s after 2 failures, want %s", state, BreakerOpen)
+	}
+
+	// Con el circuito abierto las llamadas fallan sin intentar la conexión
+	commands := srv.CommandCount("GET")
+	if _, err := client.GetCertificateStatus(ctx, "1"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("call with the circuit open: got %v, want ErrCircuitOpen", err)
+	}
+	if srv.CommandCount("GET") != commands {
+		t.Error("a command reached Redis with the circuit open")
+	}
+
+	// Pasado el cooldown, una prueba fallida vuelve a abrir el circuito
+	time.Sleep(cooldown)
+	if state := client.BreakerState(); state != BreakerHalfOpen {
+		t.Fatalf("got state %s after the cooldown, want %s", state, BreakerHalfOpen)
+	}
+	if _, err := client.GetCertificateStatus(ctx, "1"); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("probe with Redis down: got %v, want a connection error", err)
+	}
+	if state := client.BreakerState(); state != BreakerOpen {
+		t.Fatalf("got state %s after a failed probe, want %s", state, BreakerOpen)
+	}
+
+	// Con Redis recuperado, la prueba siguiente cierra el circuito
+	srv.SetDown(false)
+	time.Sleep(cooldown)
+	if _, err := client.GetCertificateStatus(ctx, "1"); err != nil {
+		t.Fatalf("probe with Redis up: %v", err)
+	}
+	if state := client.BreakerState(); state != BreakerClosed {
+		t.Errorf("got state %s after a successful probe, want %s", state, BreakerClosed)
+	}
+}
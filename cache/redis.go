@@ -12,11 +12,26 @@ import (
 )
 
 type RedisClient struct {
-	client *redis.Client
-	ctx    context.Context
+	client  *redis.Client
+	ctx     context.Context
+	breaker *circuitBreaker
+	// Prefijo antepuesto a todas las claves (REDIS_KEY_PREFIX)
+	keyPrefix string
 }
 
-func NewRedisClient(redisURL, password string, db int) (*RedisClient, error) {
+// Claves de los contadores de estadísticas, sin el prefijo del entorno
+var statsKeys = []string{
+	"stats:requests_total",
+	"stats:cache_hits",
+	"stats:cache_misses",
+	"stats:crls_processed",
+	"stats:revoked_total",
+	"stats:crls_total",
+	"stats:ocsp_mismatches",
+	"stats:crl_failure_warnings",
+}
+
+func NewRedisClient(redisURL, password string, db int, keyPrefix string, breakerCfg BreakerConfig) (*RedisClient, error) {
 	rdb := redis.NewClient(&redis.Options{
 		Addr:     redisURL,
 		Password: password,
@@ -41,38 +56,77 @@ func NewRedisClient(redisURL, password string, db int) (*RedisClient, error) {
 		return nil, fmt.Errorf("error connecting to Redis: %v", err)
 	}
 
+	// El breaker se agrega después del ping inicial para no contar fallos de arranque
+	breaker := newCircuitBreaker(breakerCfg)
+	rdb.AddHook(breaker)
+
 	log.Println("Connected to Redis with optimized pool settings")
 	return &RedisClient{
-		client: rdb,
-		ctx:    ctx,
+		client:    rdb,
+		ctx:       ctx,
+		breaker:   breaker,
+		keyPrefix: keyPrefix,
 	}, nil
 }
 
-func (r *RedisClient) SetCertificateStatus(serial string, status *models.CertificateStatus, ttl time.Duration) error {
-	key := fmt.Sprintf("cert:%s", serial)
+// key antepone el prefijo del entorno a una clave o patrón
+func (r *RedisClient) key(name string) string {
+	return r.keyPrefix + name
+}
+
+func (r *RedisClient) SetCertificateStatus(ctx context.Context, serial string, status *models.CertificateStatus, ttl time.Duration) error {
+	key := r.key("cert:" + serial)
 
 	data, err := json.Marshal(status)
 	if err != nil {
 		return fmt.Errorf("error marshaling certificate status: %v", err)
 	}
 
-	err = r.client.Set(r.ctx, key, data, ttl).Err()
+	err = r.client.Set(ctx, key, data, ttl).Err()
 	if err != nil {
-		return fmt.Errorf("error setting certificate status in Redis: %v", err)
+		return fmt.Errorf("error setting certificate status in Redis: %w", err)
 	}
 
 	return nil
 }
 
-func (r *RedisClient) GetCertificateStatus(serial string) (*models.CertificateStatus, error) {
-	key := fmt.Sprintf("cert:%s", serial)
+// InvalidateCertificateStatusBatch descarta el estado sin CA y los estados por CA de varios
+// seriales usando pipelines de hasta chunkSize seriales, en lugar de una ida y vuelta a Redis
+// por certificado. La próxima consulta de cada serial vuelve a leer su estado de la base.
+func (r *RedisClient) InvalidateCertificateStatusBatch(ctx context.Context, serials []string, chunkSize int) error {
+	pipe := r.client.Pipeline()
+	queued := 0
 
-	val, err := r.client.Get(r.ctx, key).Result()
+	for _, serial := range serials {
+		pipe.Del(ctx, r.key("cert:"+serial), r.key("cert_ca:"+serial))
+		queued++
+
+		if queued >= chunkSize {
+			if _, err := pipe.Exec(ctx); err != nil {
+				return fmt.Errorf("error invalidating certificate statuses in Redis: %w", err)
+			}
+			queued = 0
+		}
+	}
+
+	if queued > 0 {
+		if _, err := pipe.Exec(ctx); err != nil {
+			return fmt.Errorf("error invalidating certificate statuses in Redis: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (r *RedisClient) GetCertificateStatus(ctx context.Context, serial string) (*models.CertificateStatus, error) {
+	key := r.key("cert:" + serial)
+
+	val, err := r.client.Get(ctx, key).Result()
 	if err == redis.Nil {
 		return nil, nil
 	}
 	if err != nil {
-		return nil, fmt.Errorf("error getting certificate status from Redis: %v", err)
+		return nil, fmt.Errorf("error getting certificate status from Redis: %w", err)
 	}
 
 	var status models.CertificateStatus
@@ -80,12 +134,89 @@ func (r *RedisClient) GetCertificateStatus(serial string) (*models.CertificateSt
 	if err != nil {
 		return nil, fmt.Errorf("error unmarshaling certificate status: %v", err)
 	}
+	fillStatus(&status)
 
 	return &status, nil
 }
 
+// SetCertificateStatusForCA guarda el estado del serial emitido por una CA. Los estados por CA
+// de un serial comparten el hash cert_ca:{serial}, con un campo por CA, para poder invalidarlos
+// todos junto con cert:{serial} sin conocer las CAs; el TTL se aplica al hash completo.
+func (r *RedisClient) SetCertificateStatusForCA(ctx context.Context, serial, ca string, status *models.CertificateStatus, ttl time.Duration) error {
+	key := r.key("cert_ca:" + serial)
+
+	data, err := json.Marshal(status)
+	if err != nil {
+		return fmt.Errorf("error marshaling certificate status: %v", err)
+	}
+
+	pipe := r.client.TxPipeline()
+	pipe.HSet(ctx, key, ca, data)
+	pipe.Expire(ctx, key, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("error setting certificate status in Redis: %w", err)
+	}
+
+	return nil
+}
+
+// GetCertificateStatusForCA devuelve el estado guardado del serial emitido por la CA, o nil
+// si no está en cache
+func (r *RedisClient) GetCertificateStatusForCA(ctx context.Context, serial, ca string) (*models.CertificateStatus, error) {
+	val, err := r.client.HGet(ctx, r.key("cert_ca:"+serial), ca).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting certificate status from Redis: %w", err)
+	}
+
+	var status models.CertificateStatus
+	if err := json.Unmarshal([]byte(val), &status); err != nil {
+		return nil, fmt.Errorf("error unmarshaling certificate status: %v", err)
+	}
+	fillStatus(&status)
+
+	return &status, nil
+}
+
+// fillStatus completa Status en las entradas guardadas antes de que existiera el campo
+func fillStatus(status *models.CertificateStatus) {
+	if status.Status != "" {
+		return
+	}
+	switch {
+	case !status.IsRevoked:
+		status.Status = models.StatusGood
+	case status.ReasonCode != nil:
+		status.Status = models.RevokedStatus(*status.ReasonCode)
+	default:
+		status.Status = models.StatusRevoked
+	}
+}
+
+// DeleteCertificateStatus invalida las entradas de cache de los seriales indicados, incluidos
+// sus estados por CA
+func (r *RedisClient) DeleteCertificateStatus(serials ...string) error {
+	if len(serials) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, 2*len(serials))
+	for _, serial := range serials {
+		keys = append(keys, r.key("cert:"+serial), r.key("cert_ca:"+serial))
+	}
+
+	err := r.client.Del(r.ctx, keys...).Err()
+	if err != nil {
+		return fmt.Errorf("error deleting certificate status from Redis: %v", err)
+	}
+
+	return nil
+}
+
 func (r *RedisClient) SetCRLProcessing(url string, processing bool) error {
-	key := fmt.Sprintf("crl_processing:%s", url)
+	key := r.key("crl_processing:" + url)
 
 	var value string
 	var ttl time.Duration
@@ -107,7 +238,7 @@ func (r *RedisClient) SetCRLProcessing(url string, processing bool) error {
 }
 
 func (r *RedisClient) IsCRLProcessing(url string) (bool, error) {
-	key := fmt.Sprintf("crl_processing:%s", url)
+	key := r.key("crl_processing:" + url)
 
 	val, err := r.client.Get(r.ctx, key).Result()
 	if err == redis.Nil {
@@ -120,27 +251,110 @@ func (r *RedisClient) IsCRLProcessing(url string) (bool, error) {
 	return val == "true", nil
 }
 
+// PurgeProcessingFlags elimina las marcas crl_processing:* huérfanas: las que ya no indican
+// procesamiento activo o quedaron sin expiración. Devuelve cuántas se eliminaron.
+func (r *RedisClient) PurgeProcessingFlags() (int, error) {
+	removed := 0
+	iter := r.client.Scan(r.ctx, 0, r.key("crl_processing:*"), 100).Iterator()
+	for iter.Next(r.ctx) {
+		key := iter.Val()
+
+		val, err := r.client.Get(r.ctx, key).Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return removed, fmt.Errorf("error reading processing flag %s: %v", key, err)
+		}
+
+		ttl, err := r.client.TTL(r.ctx, key).Result()
+		if err != nil {
+			return removed, fmt.Errorf("error reading TTL of %s: %v", key, err)
+		}
+
+		if val != "true" || ttl < 0 {
+			if err := r.client.Del(r.ctx, key).Err(); err != nil {
+				return removed, fmt.Errorf("error deleting processing flag %s: %v", key, err)
+			}
+			removed++
+		}
+	}
+
+	if err := iter.Err(); err != nil {
+		return removed, fmt.Errorf("error scanning processing flags: %v", err)
+	}
+
+	return removed, nil
+}
+
+// ResetStats elimina los contadores stats:* y devuelve cuántos se eliminaron
+func (r *RedisClient) ResetStats() (int, error) {
+	keys, err := r.client.Keys(r.ctx, r.key("stats:*")).Result()
+	if err != nil {
+		return 0, fmt.Errorf("error listing stats keys: %v", err)
+	}
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	removed, err := r.client.Del(r.ctx, keys...).Result()
+	if err != nil {
+		return 0, fmt.Errorf("error resetting stats: %v", err)
+	}
+
+	return int(removed), nil
+}
+
 func (r *RedisClient) IncrementStats(key string) error {
-	err := r.client.Incr(r.ctx, key).Err()
+	err := r.client.Incr(r.ctx, r.key(key)).Err()
 	if err != nil {
 		return fmt.Errorf("error incrementing stats: %v", err)
 	}
 	return nil
 }
 
-func (r *RedisClient) GetStats() (map[string]interface{}, error) {
-	keys := []string{
-		"stats:requests_total",
-		"stats:cache_hits",
-		"stats:cache_misses",
-		"stats:crls_processed",
+// IncrementStatsBy suma delta (que puede ser negativo) al contador
+func (r *RedisClient) IncrementStatsBy(key string, delta int64) error {
+	err := r.client.IncrBy(r.ctx, r.key(key), delta).Err()
+	if err != nil {
+		return fmt.Errorf("error incrementing stats: %v", err)
 	}
+	return nil
+}
 
+// ReplaceStats reemplaza los contadores por los valores indicados y devuelve los que tenían
+// antes (0 si no existían), en un solo pipeline
+func (r *RedisClient) ReplaceStats(values map[string]int64) (map[string]int64, error) {
+	pipe := r.client.Pipeline()
+	results := make(map[string]*redis.StringCmd, len(values))
+	for key, value := range values {
+		results[key] = pipe.GetSet(r.ctx, r.key(key), value)
+	}
+
+	_, err := pipe.Exec(r.ctx)
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("error replacing stats: %v", err)
+	}
+
+	previous := make(map[string]int64, len(results))
+	for key, cmd := range results {
+		value, err := cmd.Int64()
+		if err != nil && err != redis.Nil {
+			return nil, fmt.Errorf("error reading previous value of %s: %v", key, err)
+		}
+		previous[key] = value
+	}
+
+	return previous, nil
+}
+
+func (r *RedisClient) GetStats() (map[string]interface{}, error) {
 	pipe := r.client.Pipeline()
 	results := make(map[string]*redis.StringCmd)
 
-	for _, key := range keys {
-		results[key] = pipe.Get(r.ctx, key)
+	// Las estadísticas se informan con el nombre sin prefijo
+	for _, key := range statsKeys {
+		results[key] = pipe.Get(r.ctx, r.key(key))
 	}
 
 	_, err := pipe.Exec(r.ctx)
@@ -163,6 +377,16 @@ func (r *RedisClient) GetStats() (map[string]interface{}, error) {
 	return stats, nil
 }
 
+// BreakerState devuelve el estado del circuit breaker: closed, open o half_open
+func (r *RedisClient) BreakerState() string {
+	return r.breaker.State()
+}
+
+// Ping verifica la conexión con Redis
+func (r *RedisClient) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
+
 func (r *RedisClient) Close() error {
 	return r.client.Close()
 }
\ No newline at end of file
@@ -0,0 +1,210 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"signerflow-crl/cache/redistest"
+	"signerflow-crl/models"
+)
+
+// pipelineCounter cuenta las idas y vueltas a Redis: cada pipeline ejecutado es una sola
+type pipelineCounter struct {
+	pipelines int
+	commands  []int
+}
+
+func (h *pipelineCounter) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *pipelineCounter) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	return nil
+}
+
+func (h *pipelineCounter) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	h.pipelines++
+	h.commands = append(h.commands, len(cmds))
+	return ctx, nil
+}
+
+func (h *pipelineCounter) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	return nil
+}
+
+func TestInvalidateCertificateStatusBatch(t *testing.T) {
+	ctx := context.Background()
+	srv := redistest.NewServer(t)
+	client, err := NewRedisClient(srv.Addr(), "", 0, "", BreakerConfig{})
+	if err != nil {
+		t.Fatalf("NewRedisClient: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	counter := &pipelineCounter{}
+	client.client.AddHook(counter)
+
+	const entries, chunkSize = 2500, 1000
+	serials := make([]string, entries)
+	status := &models.CertificateStatus{IsRevoked: true, Status: models.StatusRevoked}
+	for i := range serials {
+		serials[i] = strconv.Itoa(100000 + i)
+		if err := client.SetCertificateStatus(ctx, serials[i], status, time.Hour); err != nil {
+			t.Fatalf("SetCertificateStatus: %v", err)
+		}
+		if err := client.SetCertificateStatusForCA(ctx, serials[i], "Batch CA", status, time.Hour); err != nil {
+			t.Fatalf("SetCertificateStatusForCA: %v", err)
+		}
+	}
+	counter.pipelines, counter.commands = 0, nil
+	srv.ResetCommandCounts()
+
+	if err := client.InvalidateCertificateStatusBatch(ctx, serials, chunkSize); err != nil {
+		t.Fatalf("InvalidateCertificateStatusBatch: %v", err)
+	}
+
+	// 2500 seriales en bloques de 1000 son 3 pipelines, no 2500 idas y vueltas
+	if counter.pipelines != 3 {
+		t.Errorf("got %d pipelines, want 3 (commands per pipeline %v)", counter.pipelines, counter.commands)
+	}
+	for _, n := range counter.commands {
+		if n > chunkSize {
+			t.Errorf("pipeline carried %d commands, want at most %d", n, chunkSize)
+		}
+	}
+	if dels := srv.CommandCount("DEL"); dels != entries {
+		t.Errorf("got %d DEL commands, want %d", dels, entries)
+	}
+
+	for _, serial := range serials {
+		if _, ok := srv.Get("cert:" + serial); ok {
+			t.Fatalf("serial %s still cached after the batch invalidation", serial)
+		}
+		if _, ok := srv.HGet("cert_ca:"+serial, "Batch CA"); ok {
+			t.Fatalf("per-CA status of serial %s still cached after the batch invalidation", serial)
+		}
+	}
+}
+
+func TestKeyPrefixNamespacesKeys(t *testing.T) {
+	ctx := context.Background()
+	srv := redistest.NewServer(t)
+	newClient := func(prefix string) *RedisClient {
+		client, err := NewRedisClient(srv.Addr(), "", 0, prefix, BreakerConfig{})
+		if err != nil {
+			t.Fatalf("NewRedisClient(%q): %v", prefix, err)
+		}
+		t.Cleanup(func() { client.Close() })
+		return client
+	}
+	// Dos entornos comparten la instancia de Redis, cada uno con su prefijo
+	staging, prod := newClient("staging:"), newClient("prod:")
+
+	for prefix, client := range map[string]*RedisClient{"staging:": staging, "prod:": prod} {
+		status := &models.CertificateStatus{Serial: "5150", IsRevoked: prefix == "prod:", Status: models.StatusGood}
+		if err := client.SetCertificateStatus(ctx, "5150", status, time.Hour); err != nil {
+			t.Fatalf("SetCertificateStatus: %v", err)
+		}
+		if err := client.SetCertificateStatusForCA(ctx, "5150", "Prefix CA", status, time.Hour); err != nil {
+			t.Fatalf("SetCertificateStatusForCA: %v", err)
+		}
+		if err := client.SetCRLProcessing("http://crl.example/ca.crl", false); err != nil {
+			t.Fatalf("SetCRLProcessing: %v", err)
+		}
+		if err := client.IncrementStats("stats:requests_total"); err != nil {
+			t.Fatalf("IncrementStats: %v", err)
+		}
+	}
+	if err := prod.IncrementStatsBy("stats:requests_total", 4); err != nil {
+		t.Fatalf("IncrementStatsBy: %v", err)
+	}
+
+	for _, key := range srv.Keys() {
+		if !strings.HasPrefix(key, "staging:") && !strings.HasPrefix(key, "prod:") {
+			t.Errorf("key %q is not namespaced", key)
+		}
+	}
+	for _, key := range []string{"staging:cert:5150", "prod:cert:5150", "staging:crl_processing:http://crl.example/ca.crl", "prod:stats:requests_total"} {
+		if _, ok := srv.Get(key); !ok {
+			t.Errorf("key %q missing", key)
+		}
+	}
+	if _, ok := srv.HGet("staging:cert_ca:5150", "Prefix CA"); !ok {
+		t.Error("per-CA status was not stored under the prefix")
+	}
+
+	// Cada entorno solo ve lo suyo
+	if status, err := staging.GetCertificateStatus(ctx, "5150"); err != nil || status == nil || status.IsRevoked {
+		t.Errorf("staging lookup = %+v, %v; want its own unrevoked status", status, err)
+	}
+	if status, err := prod.GetCertificateStatusForCA(ctx, "5150", "Prefix CA"); err != nil || status == nil || !status.IsRevoked {
+		t.Errorf("prod per-CA lookup = %+v, %v; want its own revoked status", status, err)
+	}
+	for client, want := range map[*RedisClient]string{staging: "1", prod: "5"} {
+		stats, err := client.GetStats()
+		if err != nil {
+			t.Fatalf("GetStats: %v", err)
+		}
+		if stats["stats:requests_total"] != want {
+			t.Errorf("got requests_total %v, want %s", stats["stats:requests_total"], want)
+		}
+	}
+
+	// Las operaciones por patrón no salen del propio espacio de nombres
+	if removed, err := staging.PurgeProcessingFlags(); err != nil || removed != 1 {
+		t.Errorf("PurgeProcessingFlags = %d, %v; want 1", removed, err)
+	}
+	if _, ok := srv.Get("prod:crl_processing:http://crl.example/ca.crl"); !ok {
+		t.Error("staging purged the processing flag of prod")
+	}
+	if removed, err := staging.ResetStats(); err != nil || removed != 1 {
+		t.Errorf("ResetStats = %d, %v; want 1", removed, err)
+	}
+	if val, _ := srv.Get("prod:stats:requests_total"); val != "5" {
+		t.Errorf("prod requests_total = %q after resetting staging, want 5", val)
+	}
+
+	// Sin prefijo se conservan las claves de siempre
+	if err := newClient("").SetCertificateStatus(ctx, "5151", &models.CertificateStatus{Serial: "5151"}, time.Hour); err != nil {
+		t.Fatalf("SetCertificateStatus: %v", err)
+	}
+	if _, ok := srv.Get("cert:5151"); !ok {
+		t.Error("unprefixed client did not use the cert: key")
+	}
+}
+
+func TestCachedStatusWithoutStatusField(t *testing.T) {
+	ctx := context.Background()
+	srv := redistest.NewServer(t)
+	client, err := NewRedisClient(srv.Addr(), "", 0, "", BreakerConfig{})
+	if err != nil {
+		t.Fatalf("NewRedisClient: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	// Entradas guardadas antes de que existiera status
+	tests := []struct {
+		serial string
+		entry  string
+		want   string
+	}{
+		{"6101", `{"serial":"6101","is_revoked":true,"reason_code":6}`, models.StatusOnHold},
+		{"6102", `{"serial":"6102","is_revoked":true,"reason_code":1}`, models.StatusRevoked},
+		{"6103", `{"serial":"6103","is_revoked":true}`, models.StatusRevoked},
+		{"6104", `{"serial":"6104","is_revoked":false}`, models.StatusGood},
+	}
+	for _, tt := range tests {
+		srv.Set("cert:"+tt.serial, tt.entry)
+		status, err := client.GetCertificateStatus(ctx, tt.serial)
+		if err != nil || status == nil {
+			t.Fatalf("GetCertificateStatus(%s) = %+v, %v", tt.serial, status, err)
+		}
+		if status.Status != tt.want {
+			t.Errorf("serial %s: got status %q, want %q", tt.serial, status.Status, tt.want)
+		}
+	}
+}
@@ -0,0 +1,597 @@
+// Package redistest ofrece un servidor Redis mínimo en memoria para los tests, con el subconjunto
+// de comandos que usa cache.RedisClient. Escucha en 127.0.0.1 con el protocolo RESP2, igual que
+// un servidor real, de modo que el cliente go-redis se ejercita completo.
+package redistest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Server es un servidor Redis en memoria. Cuenta los comandos recibidos y puede simular una
+// caída con SetDown.
+type Server struct {
+	ln net.Listener
+
+	mu       sync.Mutex
+	strings  map[string]string
+	hashes   map[string]map[string]string
+	expires  map[string]time.Time
+	commands map[string]int
+	down     bool
+	conns    map[net.Conn]struct{}
+}
+
+// NewServer inicia un servidor que se cierra al terminar el test
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	s := &Server{
+		ln:       ln,
+		strings:  make(map[string]string),
+		hashes:   make(map[string]map[string]string),
+		expires:  make(map[string]time.Time),
+		commands: make(map[string]int),
+		conns:    make(map[net.Conn]struct{}),
+	}
+	go s.serve()
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// Addr devuelve la dirección host:puerto del servidor
+func (s *Server) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// Close detiene el servidor y cierra las conexiones abiertas
+func (s *Server) Close() {
+	s.ln.Close()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+}
+
+// SetDown simula una caída: mientras está activo, las conexiones abiertas y las nuevas se
+// cierran sin responder
+func (s *Server) SetDown(down bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.down = down
+	if down {
+		for conn := range s.conns {
+			conn.Close()
+		}
+	}
+}
+
+// Get devuelve el valor de una clave de tipo string
+func (s *Server) Get(key string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expire(key)
+	val, ok := s.strings[key]
+	return val, ok
+}
+
+// HGet devuelve un campo de una clave de tipo hash
+func (s *Server) HGet(key, field string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expire(key)
+	val, ok := s.hashes[key][field]
+	return val, ok
+}
+
+// Set guarda una clave de tipo string sin expiración
+func (s *Server) Set(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.del(key)
+	s.strings[key] = value
+}
+
+// TTL devuelve el tiempo restante de la clave, 0 si no tiene expiración o no existe
+func (s *Server) TTL(key string) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expire(key)
+	at, ok := s.expires[key]
+	if !ok {
+		return 0
+	}
+	return time.Until(at)
+}
+
+// Keys devuelve las claves existentes ordenadas
+func (s *Server) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.keys("*")
+}
+
+// CommandCount devuelve cuántas veces se recibió el comando, en mayúsculas (p. ej. "SET")
+func (s *Server) CommandCount(name string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.commands[name]
+}
+
+// ResetCommandCounts pone en cero los contadores de comandos
+func (s *Server) ResetCommandCounts() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.commands = make(map[string]int)
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		if s.down {
+			s.mu.Unlock()
+			conn.Close()
+			continue
+		}
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+
+	// Comandos encolados entre MULTI y EXEC
+	var queued [][]string
+	inMulti := false
+
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+
+		name := strings.ToUpper(args[0])
+		s.mu.Lock()
+		s.commands[name]++
+		s.mu.Unlock()
+
+		switch {
+		case name == "MULTI":
+			inMulti = true
+			queued = nil
+			w.WriteString("+OK\r\n")
+		case name == "EXEC" && inMulti:
+			inMulti = false
+			fmt.Fprintf(w, "*%d\r\n", len(queued))
+			for _, cmd := range queued {
+				w.WriteString(s.exec(cmd))
+			}
+		case name == "DISCARD" && inMulti:
+			inMulti = false
+			w.WriteString("+OK\r\n")
+		case inMulti:
+			queued = append(queued, args)
+			w.WriteString("+QUEUED\r\n")
+		default:
+			w.WriteString(s.exec(args))
+		}
+
+		// Las respuestas de un pipeline se envían juntas
+		if r.Buffered() == 0 {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// readCommand lee un comando como arreglo de bulk strings
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("unexpected request line %q", line)
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n <= 0 {
+		return nil, fmt.Errorf("invalid array length %q", line)
+	}
+
+	args := make([]string, n)
+	for i := range args {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(line, "$") {
+			return nil, fmt.Errorf("unexpected argument line %q", line)
+		}
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("invalid bulk length %q", line)
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+
+	return args, nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasSuffix(line, "\r\n") {
+		return "", errors.New("line without CRLF")
+	}
+	return line[:len(line)-2], nil
+}
+
+// exec ejecuta un comando y devuelve la respuesta codificada
+func (s *Server) exec(args []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name := strings.ToUpper(args[0])
+	args = args[1:]
+	for _, key := range keyArgs(name, args) {
+		s.expire(key)
+	}
+
+	switch name {
+	case "PING":
+		return "+PONG\r\n"
+	case "GET":
+		if len(args) != 1 {
+			return wrongArgs(name)
+		}
+		if _, ok := s.hashes[args[0]]; ok {
+			return wrongType()
+		}
+		val, ok := s.strings[args[0]]
+		if !ok {
+			return nilBulk()
+		}
+		return bulk(val)
+	case "SET":
+		if len(args) < 2 {
+			return wrongArgs(name)
+		}
+		var ttl time.Duration
+		keepTTL := false
+		for i := 2; i < len(args); i++ {
+			switch strings.ToUpper(args[i]) {
+			case "EX", "PX":
+				if i+1 >= len(args) {
+					return syntaxError()
+				}
+				n, err := strconv.ParseInt(args[i+1], 10, 64)
+				if err != nil || n <= 0 {
+					return "-ERR invalid expire time in 'set' command\r\n"
+				}
+				if strings.ToUpper(args[i]) == "EX" {
+					ttl = time.Duration(n) * time.Second
+				} else {
+					ttl = time.Duration(n) * time.Millisecond
+				}
+				i++
+			case "KEEPTTL":
+				keepTTL = true
+			default:
+				return syntaxError()
+			}
+		}
+		expiresAt, hadTTL := s.expires[args[0]]
+		s.del(args[0])
+		s.strings[args[0]] = args[1]
+		if ttl > 0 {
+			s.expires[args[0]] = time.Now().Add(ttl)
+		} else if keepTTL && hadTTL {
+			s.expires[args[0]] = expiresAt
+		}
+		return "+OK\r\n"
+	case "GETSET":
+		if len(args) != 2 {
+			return wrongArgs(name)
+		}
+		if _, ok := s.hashes[args[0]]; ok {
+			return wrongType()
+		}
+		old, existed := s.strings[args[0]]
+		s.del(args[0])
+		s.strings[args[0]] = args[1]
+		if !existed {
+			return nilBulk()
+		}
+		return bulk(old)
+	case "DEL":
+		removed := 0
+		for _, key := range args {
+			if s.exists(key) {
+				s.del(key)
+				removed++
+			}
+		}
+		return integer(int64(removed))
+	case "INCR", "INCRBY":
+		if (name == "INCR" && len(args) != 1) || (name == "INCRBY" && len(args) != 2) {
+			return wrongArgs(name)
+		}
+		delta := int64(1)
+		if name == "INCRBY" {
+			var err error
+			if delta, err = strconv.ParseInt(args[1], 10, 64); err != nil {
+				return notInteger()
+			}
+		}
+		if _, ok := s.hashes[args[0]]; ok {
+			return wrongType()
+		}
+		var current int64
+		if val, ok := s.strings[args[0]]; ok {
+			var err error
+			if current, err = strconv.ParseInt(val, 10, 64); err != nil {
+				return notInteger()
+			}
+		}
+		current += delta
+		s.strings[args[0]] = strconv.FormatInt(current, 10)
+		return integer(current)
+	case "HSET":
+		if len(args) < 3 || len(args)%2 != 1 {
+			return wrongArgs(name)
+		}
+		if _, ok := s.strings[args[0]]; ok {
+			return wrongType()
+		}
+		hash, ok := s.hashes[args[0]]
+		if !ok {
+			hash = make(map[string]string)
+			s.hashes[args[0]] = hash
+		}
+		added := 0
+		for i := 1; i < len(args); i += 2 {
+			if _, ok := hash[args[i]]; !ok {
+				added++
+			}
+			hash[args[i]] = args[i+1]
+		}
+		return integer(int64(added))
+	case "HGET":
+		if len(args) != 2 {
+			return wrongArgs(name)
+		}
+		if _, ok := s.strings[args[0]]; ok {
+			return wrongType()
+		}
+		val, ok := s.hashes[args[0]][args[1]]
+		if !ok {
+			return nilBulk()
+		}
+		return bulk(val)
+	case "EXPIRE":
+		if len(args) != 2 {
+			return wrongArgs(name)
+		}
+		seconds, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return notInteger()
+		}
+		if !s.exists(args[0]) {
+			return integer(0)
+		}
+		if seconds <= 0 {
+			s.del(args[0])
+		} else {
+			s.expires[args[0]] = time.Now().Add(time.Duration(seconds) * time.Second)
+		}
+		return integer(1)
+	case "TTL":
+		if len(args) != 1 {
+			return wrongArgs(name)
+		}
+		if !s.exists(args[0]) {
+			return integer(-2)
+		}
+		at, ok := s.expires[args[0]]
+		if !ok {
+			return integer(-1)
+		}
+		return integer(int64((time.Until(at) + time.Second - 1) / time.Second))
+	case "KEYS":
+		if len(args) != 1 {
+			return wrongArgs(name)
+		}
+		return array(s.keys(args[0]))
+	case "SCAN":
+		// Devuelve todas las claves en una sola página, con cursor 0
+		pattern := "*"
+		for i := 1; i+1 < len(args); i += 2 {
+			if strings.ToUpper(args[i]) == "MATCH" {
+				pattern = args[i+1]
+			}
+		}
+		return "*2\r\n" + bulk("0") + array(s.keys(pattern))
+	default:
+		return fmt.Sprintf("-ERR unknown command '%s'\r\n", strings.ToLower(name))
+	}
+}
+
+// keyArgs devuelve las claves que el comando lee o modifica, para aplicar antes la expiración
+func keyArgs(name string, args []string) []string {
+	switch name {
+	case "DEL":
+		return args
+	case "PING", "KEYS", "SCAN":
+		return nil
+	default:
+		if len(args) == 0 {
+			return nil
+		}
+		return args[:1]
+	}
+}
+
+// expire elimina la clave si ya venció. Debe llamarse con mu tomado.
+func (s *Server) expire(key string) {
+	if at, ok := s.expires[key]; ok && !time.Now().Before(at) {
+		s.del(key)
+	}
+}
+
+func (s *Server) exists(key string) bool {
+	_, isString := s.strings[key]
+	_, isHash := s.hashes[key]
+	return isString || isHash
+}
+
+func (s *Server) del(key string) {
+	delete(s.strings, key)
+	delete(s.hashes, key)
+	delete(s.expires, key)
+}
+
+// keys devuelve las claves vigentes que coinciden con el patrón. Debe llamarse con mu tomado.
+func (s *Server) keys(pattern string) []string {
+	match := globRegexp(pattern)
+	var keys []string
+	add := func(key string) {
+		if at, ok := s.expires[key]; ok && !time.Now().Before(at) {
+			return
+		}
+		if match.MatchString(key) {
+			keys = append(keys, key)
+		}
+	}
+	for key := range s.strings {
+		add(key)
+	}
+	for key := range s.hashes {
+		add(key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// globRegexp traduce un patrón glob de Redis a una expresión regular. A diferencia de
+// path.Match, * y ? también coinciden con '/', como en Redis, donde las claves suelen llevar URLs.
+func globRegexp(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			b.WriteString("(?s:.*)")
+		case '?':
+			b.WriteString("(?s:.)")
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+			}
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "^") {
+				class = "^" + regexp.QuoteMeta(class[1:])
+			} else {
+				class = regexp.QuoteMeta(class)
+			}
+			b.WriteString("[" + class + "]")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+func bulk(val string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(val), val)
+}
+
+func nilBulk() string {
+	return "$-1\r\n"
+}
+
+func integer(n int64) string {
+	return fmt.Sprintf(":%d\r\n", n)
+}
+
+func array(vals []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(vals))
+	for _, val := range vals {
+		b.WriteString(bulk(val))
+	}
+	return b.String()
+}
+
+func wrongArgs(name string) string {
+	return fmt.Sprintf("-ERR wrong number of arguments for '%s' command\r\n", strings.ToLower(name))
+}
+
+func wrongType() string {
+	return "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"
+}
+
+func notInteger() string {
+	return "-ERR value is not an integer or out of range\r\n"
+}
+
+func syntaxError() string {
+	return "-ERR syntax error\r\n"
+}
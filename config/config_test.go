@@ -0,0 +1,263 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReconcileCRLsConfig(t *testing.T) {
+	// La reconciliación borra revocaciones, así que solo se activa a pedido
+	if LoadConfig().ReconcileCRLs {
+		t.Error("CRL reconciliation is enabled by default")
+	}
+	t.Setenv("CRL_RECONCILE", "true")
+	if !LoadConfig().ReconcileCRLs {
+		t.Error("CRL_RECONCILE=true did not enable reconciliation")
+	}
+}
+
+func TestDatabasePoolConfig(t *testing.T) {
+	cfg := LoadConfig()
+	if cfg.DBMaxOpenConns != 25 || cfg.DBMaxIdleConns != 10 || cfg.DBConnMaxLifetime != 5*time.Minute || cfg.DBConnMaxIdleTime != 2*time.Minute {
+		t.Errorf("got default pool %d/%d/%v/%v, want 25/10/5m/2m",
+			cfg.DBMaxOpenConns, cfg.DBMaxIdleConns, cfg.DBConnMaxLifetime, cfg.DBConnMaxIdleTime)
+	}
+
+	t.Setenv("DB_MAX_OPEN_CONNS", "50")
+	t.Setenv("DB_MAX_IDLE_CONNS", "20")
+	t.Setenv("DB_CONN_MAX_LIFETIME", "10m")
+	t.Setenv("DB_CONN_MAX_IDLE_TIME", "30s")
+	cfg = LoadConfig()
+	if cfg.DBMaxOpenConns != 50 || cfg.DBMaxIdleConns != 20 || cfg.DBConnMaxLifetime != 10*time.Minute || cfg.DBConnMaxIdleTime != 30*time.Second {
+		t.Errorf("got pool %d/%d/%v/%v, want 50/20/10m/30s",
+			cfg.DBMaxOpenConns, cfg.DBMaxIdleConns, cfg.DBConnMaxLifetime, cfg.DBConnMaxIdleTime)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+
+	invalid := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr string
+	}{
+		{"no open connections", func(c *Config) { c.DBMaxOpenConns = 0 }, "DB_MAX_OPEN_CONNS"},
+		{"more idle than open", func(c *Config) { c.DBMaxIdleConns = c.DBMaxOpenConns + 1 }, "DB_MAX_IDLE_CONNS"},
+		{"negative lifetime", func(c *Config) { c.DBConnMaxLifetime = -time.Second }, "DB_CONN_MAX_LIFETIME"},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := LoadConfig()
+			tt.modify(cfg)
+			if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate: got %v, want an error about %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTrustedProxiesValidation(t *testing.T) {
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.1, 192.168.0.0/16, ::1")
+	cfg := LoadConfig()
+	if len(cfg.TrustedProxies) != 3 {
+		t.Fatalf("got trusted proxies %q, want 3 entries", cfg.TrustedProxies)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.1,proxy.internal")
+	if err := LoadConfig().Validate(); err == nil || !strings.Contains(err.Error(), "proxy.internal") {
+		t.Errorf("Validate: got %v, want an error about proxy.internal", err)
+	}
+}
+
+func TestBatchSizeConfig(t *testing.T) {
+	cfg := LoadConfig()
+	if cfg.ImportBatchSize != 500 || cfg.RedisChunkSize != 1000 {
+		t.Errorf("got default batch size %d and Redis chunk %d, want 500 and 1000", cfg.ImportBatchSize, cfg.RedisChunkSize)
+	}
+
+	t.Setenv("IMPORT_BATCH_SIZE", "2000")
+	t.Setenv("REDIS_CHUNK_SIZE", "250")
+	cfg = LoadConfig()
+	if cfg.ImportBatchSize != 2000 || cfg.RedisChunkSize != 250 {
+		t.Errorf("got batch size %d and Redis chunk %d, want 2000 and 250", cfg.ImportBatchSize, cfg.RedisChunkSize)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+
+	invalid := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr string
+	}{
+		{"zero batch size", func(c *Config) { c.ImportBatchSize = 0 }, "IMPORT_BATCH_SIZE"},
+		{"negative batch size", func(c *Config) { c.ImportBatchSize = -1 }, "IMPORT_BATCH_SIZE"},
+		{"zero Redis chunk", func(c *Config) { c.RedisChunkSize = 0 }, "REDIS_CHUNK_SIZE"},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := LoadConfig()
+			tt.modify(cfg)
+			if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate: got %v, want an error about %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDatabaseDriverValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr string
+	}{
+		{"postgres", func(c *Config) { c.DBDriver = "postgres" }, ""},
+		{"sqlite", func(c *Config) { c.DBDriver, c.SQLitePath = "sqlite", "/var/lib/crl/crl.db" }, ""},
+		{"sqlite without path", func(c *Config) { c.DBDriver, c.SQLitePath = "sqlite", "" }, "SQLITE_PATH"},
+		{"sqlite with schema", func(c *Config) { c.DBDriver, c.SQLitePath, c.DBSchema = "sqlite", "crl.db", "tenant" }, "DB_SCHEMA"},
+		{"unknown driver", func(c *Config) { c.DBDriver = "mysql" }, "DB_DRIVER"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := LoadConfig()
+			tt.modify(cfg)
+			err := cfg.Validate()
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("Validate: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Validate: got %v, want an error about %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRedisKeyPrefixValidation(t *testing.T) {
+	if cfg := LoadConfig(); cfg.RedisKeyPrefix != "" {
+		t.Errorf("got default prefix %q, want none", cfg.RedisKeyPrefix)
+	}
+
+	t.Setenv("REDIS_KEY_PREFIX", "staging:")
+	cfg := LoadConfig()
+	if cfg.RedisKeyPrefix != "staging:" {
+		t.Errorf("got prefix %q, want staging:", cfg.RedisKeyPrefix)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+
+	for _, prefix := range []string{"staging*", "env[1]:", "my env:"} {
+		t.Setenv("REDIS_KEY_PREFIX", prefix)
+		if err := LoadConfig().Validate(); err == nil || !strings.Contains(err.Error(), "REDIS_KEY_PREFIX") {
+			t.Errorf("prefix %q: got %v, want an error about REDIS_KEY_PREFIX", prefix, err)
+		}
+	}
+}
+
+func TestCRLTransportTimeouts(t *testing.T) {
+	cfg := LoadConfig()
+	if cfg.CRLResponseHeaderTimeout != 15*time.Second || cfg.CRLExpectContinueTimeout != time.Second || cfg.CRLIdleConnTimeout != 90*time.Second {
+		t.Errorf("got default timeouts %v/%v/%v, want 15s/1s/90s",
+			cfg.CRLResponseHeaderTimeout, cfg.CRLExpectContinueTimeout, cfg.CRLIdleConnTimeout)
+	}
+
+	t.Setenv("CRL_RESPONSE_HEADER_TIMEOUT", "30s")
+	t.Setenv("CRL_EXPECT_CONTINUE_TIMEOUT", "500ms")
+	t.Setenv("CRL_IDLE_CONN_TIMEOUT", "0")
+	cfg = LoadConfig()
+	if cfg.CRLResponseHeaderTimeout != 30*time.Second || cfg.CRLExpectContinueTimeout != 500*time.Millisecond || cfg.CRLIdleConnTimeout != 0 {
+		t.Errorf("got timeouts %v/%v/%v, want 30s/500ms/0",
+			cfg.CRLResponseHeaderTimeout, cfg.CRLExpectContinueTimeout, cfg.CRLIdleConnTimeout)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+
+	cfg.CRLResponseHeaderTimeout = -time.Second
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "CRL_RESPONSE_HEADER_TIMEOUT") {
+		t.Errorf("Validate: got %v, want an error about CRL_RESPONSE_HEADER_TIMEOUT", err)
+	}
+}
+
+func TestAccessLogConfig(t *testing.T) {
+	cfg := LoadConfig()
+	if len(cfg.AccessLogSkipPaths) != 1 || cfg.AccessLogSkipPaths[0] != "/api/v1/health" || cfg.AccessLogSampleRate != 1 {
+		t.Errorf("got skip paths %q and sample rate %v, want /api/v1/health and 1", cfg.AccessLogSkipPaths, cfg.AccessLogSampleRate)
+	}
+
+	// Definida y vacía no omite ninguna ruta
+	t.Setenv("ACCESS_LOG_SKIP_PATHS", "")
+	if paths := LoadConfig().AccessLogSkipPaths; len(paths) != 0 {
+		t.Errorf("got skip paths %q, want none", paths)
+	}
+
+	t.Setenv("ACCESS_LOG_SAMPLE_RATE", "1.5")
+	if err := LoadConfig().Validate(); err == nil || !strings.Contains(err.Error(), "ACCESS_LOG_SAMPLE_RATE") {
+		t.Errorf("Validate: got %v, want an error about ACCESS_LOG_SAMPLE_RATE", err)
+	}
+}
+
+func TestCRLTLSConfig(t *testing.T) {
+	cfg := LoadConfig()
+	if cfg.CRLTLSMinVersion != "1.2" || cfg.CRLExtraCABundle != "" || cfg.CRLTLSInsecureSkipVerify {
+		t.Errorf("got min version %q, extra CA bundle %q and insecure skip verify %v, want 1.2 with verification on",
+			cfg.CRLTLSMinVersion, cfg.CRLExtraCABundle, cfg.CRLTLSInsecureSkipVerify)
+	}
+
+	t.Setenv("CRL_TLS_MIN_VERSION", "1.3")
+	t.Setenv("CRL_TLS_INSECURE_SKIP_VERIFY", "true")
+	cfg = LoadConfig()
+	if cfg.CRLTLSMinVersion != "1.3" || !cfg.CRLTLSInsecureSkipVerify {
+		t.Errorf("got min version %q and insecure skip verify %v, want 1.3 and true", cfg.CRLTLSMinVersion, cfg.CRLTLSInsecureSkipVerify)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+
+	t.Setenv("CRL_TLS_MIN_VERSION", "TLS1.2")
+	if err := LoadConfig().Validate(); err == nil || !strings.Contains(err.Error(), "CRL_TLS_MIN_VERSION") {
+		t.Errorf("Validate: got %v, want an error about CRL_TLS_MIN_VERSION", err)
+	}
+}
+
+func TestCRLURLsJSONValidation(t *testing.T) {
+	if cfg := LoadConfig(); cfg.CRLURLsJSON != "" {
+		t.Errorf("got CRL_URLS_JSON %q by default, want it unset", cfg.CRLURLsJSON)
+	}
+
+	t.Setenv("CRL_URLS_JSON", `["http://crl.example/one.crl", "http://crl.example/two.crl"]`)
+	if err := LoadConfig().Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+
+	for _, value := range []string{`http://crl.example/one.crl`, `{"urls": []}`, `["http://crl.example/one.crl"`} {
+		t.Setenv("CRL_URLS_JSON", value)
+		if err := LoadConfig().Validate(); err == nil || !strings.Contains(err.Error(), "CRL_URLS_JSON") {
+			t.Errorf("Validate with CRL_URLS_JSON=%s: got %v, want an error about CRL_URLS_JSON", value, err)
+		}
+	}
+}
+
+func TestCRLContentTypesConfig(t *testing.T) {
+	if types := LoadConfig().CRLContentTypes; len(types) != 0 {
+		t.Errorf("got CRL content types %q by default, want none", types)
+	}
+
+	t.Setenv("CRL_CONTENT_TYPES", "application/pkix-crl, application/x-pkcs7-crl,application/octet-stream")
+	cfg := LoadConfig()
+	if len(cfg.CRLContentTypes) != 3 || cfg.CRLContentTypes[1] != "application/x-pkcs7-crl" {
+		t.Errorf("got CRL content types %q, want the 3 configured types", cfg.CRLContentTypes)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+
+	t.Setenv("CRL_CONTENT_TYPES", "application/pkix-crl,not a type")
+	if err := LoadConfig().Validate(); err == nil || !strings.Contains(err.Error(), "CRL_CONTENT_TYPES") {
+		t.Errorf("Validate: got %v, want an error about CRL_CONTENT_TYPES", err)
+	}
+}
@@ -0,0 +1,52 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// forEachStore ejecuta la prueba contra una base SQLite temporal, contra MemoryStore y, si
+// TEST_DATABASE_URL está definida, contra PostgreSQL en un schema propio
+func forEachStore(t *testing.T, test func(t *testing.T, store CertStore)) {
+	t.Run("sqlite", func(t *testing.T) {
+		db, err := NewSQLiteDB(filepath.Join(t.TempDir(), "crl.db"), PoolConfig{MaxOpenConns: 1, MaxIdleConns: 1}, TableConfig{})
+		if err != nil {
+			t.Fatalf("NewSQLiteDB: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+		test(t, db)
+	})
+	t.Run("memory", func(t *testing.T) {
+		test(t, NewMemoryStore())
+	})
+	t.Run("postgres", func(t *testing.T) {
+		test(t, newTestPostgres(t))
+	})
+}
+
+// newTestPostgres conecta a TEST_DATABASE_URL con las tablas en un schema que se elimina al
+// terminar la prueba; la omite si la variable no está definida
+func newTestPostgres(t *testing.T) *DB {
+	t.Helper()
+
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	schema := fmt.Sprintf("crl_test_%d", time.Now().UnixNano())
+	db, err := NewPostgresDB(databaseURL, PoolConfig{MaxOpenConns: 2, MaxIdleConns: 2}, TableConfig{Schema: schema})
+	if err != nil {
+		t.Fatalf("NewPostgresDB: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := db.Exec("DROP SCHEMA " + schema + " CASCADE"); err != nil {
+			t.Errorf("dropping schema %s: %v", schema, err)
+		}
+		db.Close()
+	})
+	return db
+}
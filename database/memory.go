@@ -0,0 +1,930 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math/big"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"signerflow-crl/models"
+)
+
+// MemoryStore implementa CertStore en memoria, sin persistencia. Sirve para pruebas y para
+// ejecutar el servicio sin PostgreSQL; los datos se pierden al reiniciar.
+type MemoryStore struct {
+	mu sync.RWMutex
+
+	// Certificados por serial y CA: dos CAs pueden revocar el mismo serial
+	certs      map[string]map[string]*models.RevokedCertificate
+	certCount  int
+	nextCertID int
+
+	crlInfos   map[string]*memoryCRLInfo
+	sources    []*models.CRLSource
+	nextSource int
+	logs       []*models.ProcessingLogEntry
+	nextLog    int
+	cas        []*models.CACertificate
+	nextCA     int
+}
+
+// memoryCRLInfo es una fila de crl_info con sus validadores HTTP
+type memoryCRLInfo struct {
+	info       models.CRLInfo
+	validators models.CRLValidators
+}
+
+var _ CertStore = (*MemoryStore)(nil)
+
+// decimalSerial identifica los seriales que ListRevokedInRange puede comparar como enteros
+var decimalSerial = regexp.MustCompile(`^-?[0-9]+$`)
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		certs:    make(map[string]map[string]*models.RevokedCertificate),
+		crlInfos: make(map[string]*memoryCRLInfo),
+	}
+}
+
+func (m *MemoryStore) InsertRevokedCertificate(ctx context.Context, cert *models.RevokedCertificate) error {
+	if len(cert.Serial) > models.MaxSerialLength {
+		return ErrSerialTooLong
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.upsertCert(cert, time.Now())
+	return nil
+}
+
+func (m *MemoryStore) BatchInsertRevokedCertificates(ctx context.Context, certs []*models.RevokedCertificate) ([]*models.RevokedCertificate, error) {
+	if len(certs) == 0 {
+		return nil, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	var inserted []*models.RevokedCertificate
+	for _, cert := range certs {
+		if cert.Serial == "" || len(cert.Serial) > models.MaxSerialLength {
+			log.Printf("Warning: skipping certificate from %s with serial of %d characters (max %d)",
+				cert.CertificateAuthority, len(cert.Serial), models.MaxSerialLength)
+			continue
+		}
+		if m.upsertCert(cert, now) {
+			inserted = append(inserted, cert)
+		}
+	}
+	return inserted, nil
+}
+
+// upsertCert inserta o actualiza el certificado conservando id, huella y fecha de creación;
+// devuelve true si no existía
+func (m *MemoryStore) upsertCert(cert *models.RevokedCertificate, now time.Time) bool {
+	byCA, ok := m.certs[cert.Serial]
+	if !ok {
+		byCA = make(map[string]*models.RevokedCertificate, 1)
+		m.certs[cert.Serial] = byCA
+	}
+	if existing, ok := byCA[cert.CertificateAuthority]; ok {
+		existing.RevocationDate = cert.RevocationDate
+		existing.Reason = cert.Reason
+		existing.ReasonText = cert.ReasonText
+		existing.CertificateAuthority = cert.CertificateAuthority
+		existing.IssuerDN = cert.IssuerDN
+		existing.InvalidityDate = cert.InvalidityDate
+		existing.UpdatedAt = now
+		return false
+	}
+
+	m.nextCertID++
+	stored := *cert
+	stored.ID = m.nextCertID
+	stored.Fingerprint = ""
+	stored.CreatedAt = now
+	stored.UpdatedAt = now
+	byCA[cert.CertificateAuthority] = &stored
+	m.certCount++
+	return true
+}
+
+// eachCert llama a fn con cada certificado guardado
+func (m *MemoryStore) eachCert(fn func(*models.RevokedCertificate)) {
+	for _, byCA := range m.certs {
+		for _, cert := range byCA {
+			fn(cert)
+		}
+	}
+}
+
+// deleteCert elimina el certificado del serial y la CA; devuelve true si existía
+func (m *MemoryStore) deleteCert(serial, ca string) bool {
+	byCA, ok := m.certs[serial]
+	if !ok {
+		return false
+	}
+	if _, ok := byCA[ca]; !ok {
+		return false
+	}
+	delete(byCA, ca)
+	if len(byCA) == 0 {
+		delete(m.certs, serial)
+	}
+	m.certCount--
+	return true
+}
+
+// deleteCerts elimina los certificados que cumplen match y devuelve sus seriales
+func (m *MemoryStore) deleteCerts(match func(*models.RevokedCertificate) bool) []string {
+	var matched []*models.RevokedCertificate
+	m.eachCert(func(cert *models.RevokedCertificate) {
+		if match(cert) {
+			matched = append(matched, cert)
+		}
+	})
+
+	deleted := make([]string, 0, len(matched))
+	for _, cert := range matched {
+		m.deleteCert(cert.Serial, cert.CertificateAuthority)
+		deleted = append(deleted, cert.Serial)
+	}
+	return deleted
+}
+
+func (m *MemoryStore) DeleteCertificatesNotIn(ctx context.Context, issuerDN, certificateAuthority string, serials []string) ([]string, error) {
+	keep := make(map[string]bool, len(serials))
+	for _, serial := range serials {
+		keep[serial] = true
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.deleteCerts(func(cert *models.RevokedCertificate) bool {
+		sameIssuer := cert.IssuerDN == issuerDN || (cert.IssuerDN == "" && cert.CertificateAuthority == certificateAuthority)
+		return sameIssuer && !keep[cert.Serial]
+	}), nil
+}
+
+func (m *MemoryStore) PurgeCA(ctx context.Context, certificateAuthority string) ([]string, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	deleted := m.deleteCerts(func(cert *models.RevokedCertificate) bool {
+		return cert.CertificateAuthority == certificateAuthority
+	})
+
+	crlInfos := 0
+	for url, stored := range m.crlInfos {
+		if stored.info.Issuer == certificateAuthority {
+			delete(m.crlInfos, url)
+			crlInfos++
+		}
+	}
+	return deleted, crlInfos, nil
+}
+
+func (m *MemoryStore) DeleteRevokedCertificates(ctx context.Context, certificateAuthority string, serials []string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var deleted []string
+	for _, serial := range serials {
+		if m.deleteCert(serial, certificateAuthority) {
+			deleted = append(deleted, serial)
+		}
+	}
+	return deleted, nil
+}
+
+func (m *MemoryStore) DeleteUntrackedCertificates(ctx context.Context, cutoff time.Time, trackedURLs []string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tracked := make(map[string]bool, len(trackedURLs)+len(m.sources))
+	for _, url := range trackedURLs {
+		tracked[url] = true
+	}
+	for _, source := range m.sources {
+		tracked[source.URL] = true
+	}
+
+	return m.deleteCerts(func(cert *models.RevokedCertificate) bool {
+		if !cert.UpdatedAt.Before(cutoff) {
+			return false
+		}
+		for _, stored := range m.crlInfos {
+			info := stored.info
+			sameIssuer := (info.IssuerDN != "" && info.IssuerDN == cert.IssuerDN) || info.Issuer == cert.CertificateAuthority
+			if sameIssuer && (!info.LastProcessed.Before(cutoff) || tracked[info.URL]) {
+				return false
+			}
+		}
+		return true
+	}), nil
+}
+
+func (m *MemoryStore) GetCertificateStatus(ctx context.Context, serial string) (*models.CertificateStatus, error) {
+	return m.certificateStatus(serial, "")
+}
+
+func (m *MemoryStore) GetCertificateStatusByCA(ctx context.Context, serial, ca string) (*models.CertificateStatus, error) {
+	return m.certificateStatus(serial, ca)
+}
+
+func (m *MemoryStore) GetCertificateStatusAsOf(ctx context.Context, serial, ca string, asOf time.Time) (*models.CertificateStatus, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var found *models.RevokedCertificate
+	for _, cert := range m.certs[serial] {
+		if (ca != "" && cert.CertificateAuthority != ca) || cert.RevocationDate.After(asOf) {
+			continue
+		}
+		if found == nil || cert.RevocationDate.After(found.RevocationDate) ||
+			(cert.RevocationDate.Equal(found.RevocationDate) && cert.CertificateAuthority < found.CertificateAuthority) {
+			found = cert
+		}
+	}
+	return certificateStatusOf(serial, found), nil
+}
+
+func (m *MemoryStore) certificateStatus(serial, ca string) (*models.CertificateStatus, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return certificateStatusOf(serial, m.findCert(serial, ca)), nil
+}
+
+// certificateStatusOf arma el estado del serial a partir del certificado encontrado, o nil si
+// no está revocado
+func certificateStatusOf(serial string, cert *models.RevokedCertificate) *models.CertificateStatus {
+	if cert == nil {
+		return &models.CertificateStatus{
+			Serial:    serial,
+			IsRevoked: false,
+			Status:    models.StatusGood,
+		}
+	}
+
+	status := revokedStatus(cert)
+	if cert.Fingerprint != "" {
+		fingerprint := cert.Fingerprint
+		status.Fingerprint = &fingerprint
+	}
+	return status
+}
+
+func (m *MemoryStore) GetCertificateByFingerprint(ctx context.Context, fingerprint string) (*models.CertificateStatus, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var found *models.RevokedCertificate
+	m.eachCert(func(cert *models.RevokedCertificate) {
+		if found == nil && cert.Fingerprint == fingerprint {
+			found = cert
+		}
+	})
+	if found == nil {
+		return nil, nil
+	}
+
+	status := revokedStatus(found)
+	status.Fingerprint = &fingerprint
+	return status, nil
+}
+
+// findCert busca el certificado del serial emitido por la CA. Sin CA devuelve la revocación
+// más reciente del serial entre todas las CAs, igual que el ORDER BY de PostgreSQL.
+func (m *MemoryStore) findCert(serial, certificateAuthority string) *models.RevokedCertificate {
+	byCA := m.certs[serial]
+	if certificateAuthority != "" {
+		return byCA[certificateAuthority]
+	}
+
+	var found *models.RevokedCertificate
+	for _, cert := range byCA {
+		if found == nil || cert.RevocationDate.After(found.RevocationDate) ||
+			(cert.RevocationDate.Equal(found.RevocationDate) && cert.CertificateAuthority < found.CertificateAuthority) {
+			found = cert
+		}
+	}
+	return found
+}
+
+// revokedStatus arma el estado de un certificado revocado a partir de una copia de sus datos
+func revokedStatus(stored *models.RevokedCertificate) *models.CertificateStatus {
+	cert := *stored
+	reasonText := models.RevocationReasons[cert.Reason]
+	if cert.ReasonText != "" {
+		reasonText = cert.ReasonText
+	}
+
+	return &models.CertificateStatus{
+		Serial:               cert.Serial,
+		IsRevoked:            true,
+		Status:               models.RevokedStatus(cert.Reason),
+		RevocationDate:       &cert.RevocationDate,
+		Reason:               &reasonText,
+		ReasonCode:           &cert.Reason,
+		CertificateAuthority: &cert.CertificateAuthority,
+		InvalidityDate:       cert.InvalidityDate,
+	}
+}
+
+func (m *MemoryStore) SetCertificateFingerprint(ctx context.Context, serial, certificateAuthority, fingerprint string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if cert, ok := m.certs[serial][certificateAuthority]; ok {
+		cert.Fingerprint = fingerprint
+	}
+	return nil
+}
+
+func (m *MemoryStore) HasCRLForIssuer(ctx context.Context, issuer string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, stored := range m.crlInfos {
+		if stored.info.Issuer == issuer {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// selectCerts devuelve copias de los certificados que cumplen match, sin ordenar
+func (m *MemoryStore) selectCerts(match func(*models.RevokedCertificate) bool) []*models.RevokedCertificate {
+	var certs []*models.RevokedCertificate
+	m.eachCert(func(cert *models.RevokedCertificate) {
+		if match == nil || match(cert) {
+			copied := *cert
+			certs = append(certs, &copied)
+		}
+	})
+	return certs
+}
+
+// byRevocationDateDesc ordena como ORDER BY revocation_date DESC, id DESC
+func byRevocationDateDesc(certs []*models.RevokedCertificate) {
+	sort.Slice(certs, func(i, j int) bool {
+		if !certs[i].RevocationDate.Equal(certs[j].RevocationDate) {
+			return certs[i].RevocationDate.After(certs[j].RevocationDate)
+		}
+		return certs[i].ID > certs[j].ID
+	})
+}
+
+func (m *MemoryStore) ListRevokedCertificates(ctx context.Context, filter models.CertificateFilter, limit, offset int) ([]*models.RevokedCertificate, int, error) {
+	m.mu.RLock()
+	certs := m.selectCerts(func(cert *models.RevokedCertificate) bool {
+		switch {
+		case filter.CertificateAuthority != "" && cert.CertificateAuthority != filter.CertificateAuthority:
+			return false
+		case filter.Reason != nil && cert.Reason != *filter.Reason:
+			return false
+		case filter.From != nil && cert.RevocationDate.Before(*filter.From):
+			return false
+		case filter.To != nil && cert.RevocationDate.After(*filter.To):
+			return false
+		case filter.AfterID != nil && *filter.AfterID > 0 && cert.ID >= *filter.AfterID:
+			return false
+		}
+		return true
+	})
+	m.mu.RUnlock()
+
+	// Con cursor se ordena por id y no se calcula el total, igual que en PostgreSQL
+	total := 0
+	if filter.AfterID != nil {
+		sort.Slice(certs, func(i, j int) bool { return certs[i].ID > certs[j].ID })
+		offset = 0
+	} else {
+		total = len(certs)
+		byRevocationDateDesc(certs)
+	}
+
+	if offset >= len(certs) {
+		return make([]*models.RevokedCertificate, 0), total, nil
+	}
+	certs = certs[offset:]
+	if len(certs) > limit {
+		certs = certs[:limit]
+	}
+	return certs, total, nil
+}
+
+func (m *MemoryStore) ListRevokedInRange(ctx context.Context, ca, from, to string, limit int) ([]*models.RevokedCertificate, error) {
+	lower, ok := new(big.Int).SetString(from, 10)
+	if !ok {
+		return nil, fmt.Errorf("error listing certificates in range: invalid serial %q", from)
+	}
+	upper, ok := new(big.Int).SetString(to, 10)
+	if !ok {
+		return nil, fmt.Errorf("error listing certificates in range: invalid serial %q", to)
+	}
+
+	m.mu.RLock()
+	certs := m.selectCerts(func(cert *models.RevokedCertificate) bool {
+		if cert.CertificateAuthority != ca || !decimalSerial.MatchString(cert.Serial) {
+			return false
+		}
+		serial, _ := new(big.Int).SetString(cert.Serial, 10)
+		return serial.Cmp(lower) >= 0 && serial.Cmp(upper) <= 0
+	})
+	m.mu.RUnlock()
+
+	sort.Slice(certs, func(i, j int) bool {
+		a, _ := new(big.Int).SetString(certs[i].Serial, 10)
+		b, _ := new(big.Int).SetString(certs[j].Serial, 10)
+		return a.Cmp(b) < 0
+	})
+	if len(certs) > limit {
+		certs = certs[:limit]
+	}
+	if certs == nil {
+		certs = make([]*models.RevokedCertificate, 0)
+	}
+	return certs, nil
+}
+
+func (m *MemoryStore) GetRecentRevoked(ctx context.Context, n int) ([]*models.RevokedCertificate, error) {
+	m.mu.RLock()
+	certs := m.selectCerts(nil)
+	m.mu.RUnlock()
+
+	byRevocationDateDesc(certs)
+	if len(certs) > n {
+		certs = certs[:n]
+	}
+	if certs == nil {
+		certs = make([]*models.RevokedCertificate, 0)
+	}
+	return certs, nil
+}
+
+func (m *MemoryStore) StreamRevokedCertificates(ctx context.Context, ca string, fn func(*models.RevokedCertificate) error) error {
+	m.mu.RLock()
+	certs := m.selectCerts(func(cert *models.RevokedCertificate) bool {
+		return ca == "" || cert.CertificateAuthority == ca
+	})
+	m.mu.RUnlock()
+
+	sort.Slice(certs, func(i, j int) bool { return certs[i].ID < certs[j].ID })
+	for _, cert := range certs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(cert); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) InsertCRLInfo(ctx context.Context, crlInfo *models.CRLInfo) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.crlInfos[crlInfo.URL]
+	if !ok {
+		stored = &memoryCRLInfo{}
+		m.crlInfos[crlInfo.URL] = stored
+	}
+	// Los intentos solo los modifica RecordCRLAttempt
+	attempts := stored.info.CRLAttempts
+	stored.info = *crlInfo
+	stored.info.CRLAttempts = attempts
+	return nil
+}
+
+func (m *MemoryStore) GetCRLNumber(ctx context.Context, url string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if stored, ok := m.crlInfos[url]; ok {
+		return stored.info.CRLNumber, nil
+	}
+	return "", nil
+}
+
+func (m *MemoryStore) GetCRLValidators(ctx context.Context, url string) (*models.CRLValidators, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if stored, ok := m.crlInfos[url]; ok {
+		validators := stored.validators
+		return &validators, nil
+	}
+	return &models.CRLValidators{}, nil
+}
+
+func (m *MemoryStore) UpdateCRLValidators(ctx context.Context, url string, validators *models.CRLValidators) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if stored, ok := m.crlInfos[url]; ok {
+		stored.validators = *validators
+	}
+	return nil
+}
+
+func (m *MemoryStore) TouchCRLInfo(ctx context.Context, url string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if stored, ok := m.crlInfos[url]; ok {
+		stored.info.LastProcessed = time.Now()
+	}
+	return nil
+}
+
+func (m *MemoryStore) RecordCRLAttempt(ctx context.Context, url string, attemptErr error) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var attempts []*models.CRLAttempts
+	if stored, ok := m.crlInfos[url]; ok {
+		attempts = append(attempts, &stored.info.CRLAttempts)
+	}
+	for _, source := range m.sources {
+		if source.URL == url {
+			attempts = append(attempts, &source.CRLAttempts)
+		}
+	}
+
+	failures := 0
+	now := time.Now()
+	for _, attempt := range attempts {
+		if attemptErr == nil {
+			attempt.LastSuccess = &now
+			attempt.LastError = ""
+			attempt.ConsecutiveFailures = 0
+			continue
+		}
+		attempt.LastError = attemptErr.Error()
+		attempt.ConsecutiveFailures++
+		failures = max(failures, attempt.ConsecutiveFailures)
+	}
+	return failures, nil
+}
+
+func (m *MemoryStore) ListCRLInfo(ctx context.Context) ([]*models.CRLInfo, error) {
+	m.mu.RLock()
+	infos := make([]*models.CRLInfo, 0, len(m.crlInfos))
+	for _, stored := range m.crlInfos {
+		info := stored.info
+		infos = append(infos, &info)
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].Issuer != infos[j].Issuer {
+			return infos[i].Issuer < infos[j].Issuer
+		}
+		return infos[i].URL < infos[j].URL
+	})
+	return infos, nil
+}
+
+func (m *MemoryStore) GetCRLInfo(ctx context.Context, url string) (*models.CRLInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stored, ok := m.crlInfos[url]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	info := stored.info
+	return &info, nil
+}
+
+func (m *MemoryStore) GetCRLStats(ctx context.Context) (map[string]interface{}, error) {
+	lastUpdate, _ := m.GetLastProcessed(ctx)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return map[string]interface{}{
+		"total_revoked_certificates": m.certCount,
+		"total_crls_processed":       len(m.crlInfos),
+		"last_update":                lastUpdate,
+	}, nil
+}
+
+func (m *MemoryStore) GetLastProcessed(ctx context.Context) (time.Time, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	lastUpdate := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, stored := range m.crlInfos {
+		if stored.info.LastProcessed.After(lastUpdate) {
+			lastUpdate = stored.info.LastProcessed
+		}
+	}
+	return lastUpdate, nil
+}
+
+func (m *MemoryStore) GetReasonBreakdown(ctx context.Context, ca string) (map[int]models.ReasonCount, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	breakdown := make(map[int]models.ReasonCount)
+	certs := m.selectCerts(func(cert *models.RevokedCertificate) bool {
+		return ca == "" || cert.CertificateAuthority == ca
+	})
+	for _, cert := range certs {
+		count := breakdown[cert.Reason]
+		count.ReasonText = models.RevocationReasons[cert.Reason]
+		count.Count++
+		breakdown[cert.Reason] = count
+	}
+	return breakdown, nil
+}
+
+func (m *MemoryStore) GetStatsByCA(ctx context.Context, ca string) ([]*models.CAStats, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// Igual que en PostgreSQL se agrupa por DN y por nombre las filas sin DN
+	groups := make(map[string]*models.CAStats)
+	certs := m.selectCerts(func(cert *models.RevokedCertificate) bool {
+		return ca == "" || cert.CertificateAuthority == ca || cert.IssuerDN == ca
+	})
+	for _, cert := range certs {
+		key := "dn:" + cert.IssuerDN
+		if cert.IssuerDN == "" {
+			key = "ca:" + cert.CertificateAuthority
+		}
+
+		stat, ok := groups[key]
+		if !ok {
+			stat = &models.CAStats{CertificateAuthority: cert.CertificateAuthority, IssuerDN: cert.IssuerDN}
+			groups[key] = stat
+		}
+		if cert.CertificateAuthority > stat.CertificateAuthority {
+			stat.CertificateAuthority = cert.CertificateAuthority
+		}
+		stat.RevokedCount++
+	}
+
+	stats := make([]*models.CAStats, 0, len(groups))
+	for _, stat := range groups {
+		var latest *models.CRLInfo
+		for _, stored := range m.crlInfos {
+			info := stored.info
+			sameIssuer := info.Issuer == stat.CertificateAuthority
+			if stat.IssuerDN != "" {
+				sameIssuer = info.IssuerDN == stat.IssuerDN
+			}
+			if sameIssuer && (latest == nil || info.LastProcessed.After(latest.LastProcessed)) {
+				latest = &info
+			}
+		}
+		if latest != nil {
+			stat.CRLURL = &latest.URL
+			stat.LastProcessed = &latest.LastProcessed
+			if !latest.NextUpdate.IsZero() {
+				stat.NextUpdate = &latest.NextUpdate
+				stat.IsStale = latest.NextUpdate.Before(time.Now())
+			}
+		}
+		stats = append(stats, stat)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].RevokedCount != stats[j].RevokedCount {
+			return stats[i].RevokedCount > stats[j].RevokedCount
+		}
+		return stats[i].CertificateAuthority < stats[j].CertificateAuthority
+	})
+	return stats, nil
+}
+
+func (m *MemoryStore) ListCRLSources(ctx context.Context) ([]*models.CRLSource, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	sources := make([]*models.CRLSource, 0, len(m.sources))
+	for _, source := range m.sources {
+		copied := *source
+		sources = append(sources, &copied)
+	}
+	return sources, nil
+}
+
+func (m *MemoryStore) GetCRLSourceByURL(ctx context.Context, url string) (*models.CRLSource, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, source := range m.sources {
+		if source.URL == url {
+			copied := *source
+			return &copied, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MemoryStore) AddCRLSource(ctx context.Context, source *models.CRLSource) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.sources {
+		if existing.URL == source.URL {
+			return ErrDuplicateSource
+		}
+	}
+
+	m.nextSource++
+	source.ID = m.nextSource
+	source.CreatedAt = time.Now()
+	copied := *source
+	m.sources = append(m.sources, &copied)
+	return nil
+}
+
+func (m *MemoryStore) SetCRLSourceEnabled(ctx context.Context, id int, enabled bool) (*models.CRLSource, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, source := range m.sources {
+		if source.ID == id {
+			source.Enabled = enabled
+			copied := *source
+			return &copied, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MemoryStore) DeleteCRLSource(ctx context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, source := range m.sources {
+		if source.ID == id {
+			m.sources = append(m.sources[:i], m.sources[i+1:]...)
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (m *MemoryStore) InsertProcessingLog(ctx context.Context, entry *models.ProcessingLogEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nextLog++
+	copied := *entry
+	copied.ID = m.nextLog
+	m.logs = append(m.logs, &copied)
+	return nil
+}
+
+func (m *MemoryStore) GetProcessingHistory(ctx context.Context, url string, limit int) ([]*models.ProcessingLogEntry, error) {
+	m.mu.RLock()
+	entries := make([]*models.ProcessingLogEntry, 0)
+	for _, entry := range m.logs {
+		if url == "" || entry.URL == url {
+			copied := *entry
+			entries = append(entries, &copied)
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].StartedAt.Equal(entries[j].StartedAt) {
+			return entries[i].StartedAt.After(entries[j].StartedAt)
+		}
+		return entries[i].ID > entries[j].ID
+	})
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
+func (m *MemoryStore) GetThroughput(ctx context.Context, since time.Time, bucket string) ([]*models.ThroughputBucket, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	buckets := make(map[time.Time]*models.ThroughputBucket)
+	for _, entry := range m.logs {
+		if entry.StartedAt.Before(since) {
+			continue
+		}
+
+		start := entry.StartedAt.Truncate(time.Hour)
+		if bucket == "day" {
+			year, month, day := entry.StartedAt.Date()
+			start = time.Date(year, month, day, 0, 0, 0, 0, entry.StartedAt.Location())
+		}
+
+		b, ok := buckets[start]
+		if !ok {
+			b = &models.ThroughputBucket{BucketStart: start}
+			buckets[start] = b
+		}
+		b.Runs++
+		b.DurationMs += entry.DurationMs
+		switch entry.Status {
+		case models.ProcessingStatusFailed:
+			b.FailedRuns++
+		case models.ProcessingStatusSuccess:
+			b.CertsImported += int64(entry.CertCount)
+		}
+	}
+
+	result := make([]*models.ThroughputBucket, 0, len(buckets))
+	for _, b := range buckets {
+		result = append(result, b)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].BucketStart.Before(result[j].BucketStart) })
+	return result, nil
+}
+
+func (m *MemoryStore) PruneProcessingLog(ctx context.Context, cutoff time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	kept := m.logs[:0]
+	for _, entry := range m.logs {
+		if !entry.StartedAt.Before(cutoff) {
+			kept = append(kept, entry)
+		}
+	}
+	pruned := len(m.logs) - len(kept)
+	m.logs = kept
+	return pruned, nil
+}
+
+func (m *MemoryStore) AddCACertificate(ctx context.Context, ca *models.CACertificate) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.cas {
+		if existing.KeyID == ca.KeyID {
+			return ErrDuplicateCA
+		}
+	}
+
+	m.nextCA++
+	ca.ID = m.nextCA
+	ca.CreatedAt = time.Now()
+	copied := *ca
+	m.cas = append(m.cas, &copied)
+	return nil
+}
+
+func (m *MemoryStore) ListCACertificates(ctx context.Context) ([]*models.CACertificate, error) {
+	return m.selectCAs(""), nil
+}
+
+func (m *MemoryStore) GetCACertificatesBySubject(ctx context.Context, subject string) ([]*models.CACertificate, error) {
+	return m.selectCAs(subject), nil
+}
+
+// selectCAs devuelve copias de los certificados de CA, solo los de subject si no está vacío
+func (m *MemoryStore) selectCAs(subject string) []*models.CACertificate {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	cas := make([]*models.CACertificate, 0)
+	for _, ca := range m.cas {
+		if subject == "" || ca.Subject == subject {
+			copied := *ca
+			cas = append(cas, &copied)
+		}
+	}
+	return cas
+}
+
+func (m *MemoryStore) DeleteCACertificate(ctx context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, ca := range m.cas {
+		if ca.ID == id {
+			m.cas = append(m.cas[:i], m.cas[i+1:]...)
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+// PingContext siempre tiene éxito: no hay conexión que verificar
+func (m *MemoryStore) PingContext(ctx context.Context) error {
+	return nil
+}
+
+func (m *MemoryStore) Close() error {
+	return nil
+}
@@ -1,12 +1,15 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"signerflow-crl/models"
 )
 
@@ -19,31 +22,87 @@ type DB struct {
 	stmtGetTotalCerts   *sql.Stmt
 	stmtGetTotalCRLs    *sql.Stmt
 	stmtGetLastUpdate   *sql.Stmt
+	stmtGetCRLNumber    *sql.Stmt
+	stmtListInRange     *sql.Stmt
+	stmtGetRecent       *sql.Stmt
+	stmtGetStatsAllCAs  *sql.Stmt
+	stmtGetStatsByCA    *sql.Stmt
+	// Reemplaza los nombres de las tablas por los del schema y prefijo configurados; nil si no hay
+	tableNames *strings.Replacer
+	schema     string
 }
 
-func NewPostgresDB(databaseURL string) (*DB, error) {
+// PoolConfig define los límites del pool de conexiones; 0 en las duraciones las deshabilita
+type PoolConfig struct {
+	MaxOpenConns    int           // Máximo de conexiones abiertas
+	MaxIdleConns    int           // Conexiones idle en el pool
+	ConnMaxLifetime time.Duration // Tiempo de vida máximo de una conexión
+	ConnMaxIdleTime time.Duration // Tiempo máximo que una conexión puede estar idle
+}
+
+// TableConfig aísla las tablas del servicio cuando varias instancias comparten la base:
+// Schema las crea en ese schema y Prefix antepone el prefijo a cada nombre. Vacíos usan las
+// tablas sin prefijo del schema por defecto.
+type TableConfig struct {
+	Schema string
+	Prefix string
+}
+
+// tables son las tablas del servicio; los índices llevan el nombre de su tabla después de idx_
+var tables = []string{"revoked_certificates", "crl_info", "crl_sources", "ca_certificates", "crl_processing_log"}
+
+// newTableNames arma el reemplazo de los nombres de tabla e índice de las consultas según
+// cfg, o nil si se usan los nombres sin cambios
+func newTableNames(cfg TableConfig) *strings.Replacer {
+	if cfg.Schema == "" && cfg.Prefix == "" {
+		return nil
+	}
+
+	qualifier := ""
+	if cfg.Schema != "" {
+		qualifier = cfg.Schema + "."
+	}
+	// Los índices se reemplazan antes que las tablas: viven en el schema de su tabla y su
+	// nombre no admite el schema, solo el prefijo
+	var pairs []string
+	for _, table := range tables {
+		pairs = append(pairs, "idx_"+table, "idx_"+cfg.Prefix+table)
+	}
+	for _, table := range tables {
+		pairs = append(pairs, table, qualifier+cfg.Prefix+table)
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+// qualify aplica el schema y el prefijo configurados a las tablas de una consulta
+func (db *DB) qualify(query string) string {
+	if db.tableNames == nil {
+		return query
+	}
+	return db.tableNames.Replace(query)
+}
+
+func NewPostgresDB(databaseURL string, pool PoolConfig, tableConfig TableConfig) (*DB, error) {
 	db, err := sql.Open("postgres", databaseURL)
 	if err != nil {
 		return nil, fmt.Errorf("error connecting to database: %v", err)
 	}
 
 	// Optimización del pool de conexiones
-	db.SetMaxOpenConns(25)                 // Máximo de conexiones abiertas
-	db.SetMaxIdleConns(10)                 // Conexiones idle en el pool
-	db.SetConnMaxLifetime(5 * time.Minute) // Tiempo de vida máximo de una conexión
-	db.SetConnMaxIdleTime(2 * time.Minute) // Tiempo máximo que una conexión puede estar idle
+	applyPoolConfig(db, pool)
 
 	if err := db.Ping(); err != nil {
 		return nil, fmt.Errorf("error pinging database: %v", err)
 	}
 
-	database := &DB{DB: db}
+	database := &DB{DB: db, tableNames: newTableNames(tableConfig), schema: tableConfig.Schema}
 	if err := database.createTables(); err != nil {
 		return nil, fmt.Errorf("error creating tables: %v", err)
 	}
 
 	// Preparar statements para mejor rendimiento
 	if err := database.prepareStatements(); err != nil {
+		database.Close()
 		return nil, fmt.Errorf("error preparing statements: %v", err)
 	}
 
@@ -51,77 +110,158 @@ func NewPostgresDB(databaseURL string) (*DB, error) {
 	return database, nil
 }
 
+func applyPoolConfig(db *sql.DB, pool PoolConfig) {
+	db.SetMaxOpenConns(pool.MaxOpenConns)
+	db.SetMaxIdleConns(pool.MaxIdleConns)
+	db.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
+}
+
 func (db *DB) prepareStatements() error {
 	var err error
 
-	// Statement para obtener estado de certificado
-	db.stmtGetCertStatus, err = db.Prepare(`
-		SELECT serial, revocation_date, reason, reason_text, certificate_authority
+	// Statement para obtener estado de certificado; sin CA ($2 vacío) se toma la revocación
+	// más reciente del serial entre todas las CAs
+	db.stmtGetCertStatus, err = db.Prepare(db.qualify(`
+		SELECT serial, revocation_date, reason, reason_text, certificate_authority, COALESCE(fingerprint, ''), invalidity_date
 		FROM revoked_certificates
-		WHERE serial = $1
-	`)
+		WHERE serial = $1 AND ($2 = '' OR certificate_authority = $2)
+		ORDER BY revocation_date DESC, certificate_authority
+		LIMIT 1
+	`))
 	if err != nil {
 		return fmt.Errorf("error preparing stmtGetCertStatus: %v", err)
 	}
 
 	// Statement para insertar certificado revocado
-	db.stmtInsertCert, err = db.Prepare(`
+	db.stmtInsertCert, err = db.Prepare(db.qualify(`
 		INSERT INTO revoked_certificates
-		(serial, revocation_date, reason, reason_text, certificate_authority, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		ON CONFLICT (serial)
+		(serial, revocation_date, reason, reason_text, certificate_authority, issuer_dn, updated_at, invalidity_date)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8)
+		ON CONFLICT (serial, certificate_authority)
 		DO UPDATE SET
 			revocation_date = EXCLUDED.revocation_date,
 			reason = EXCLUDED.reason,
 			reason_text = EXCLUDED.reason_text,
-			certificate_authority = EXCLUDED.certificate_authority,
-			updated_at = EXCLUDED.updated_at
-	`)
+			issuer_dn = EXCLUDED.issuer_dn,
+			updated_at = EXCLUDED.updated_at,
+			invalidity_date = EXCLUDED.invalidity_date
+	`))
 	if err != nil {
 		return fmt.Errorf("error preparing stmtInsertCert: %v", err)
 	}
 
 	// Statement para insertar CRL info
-	db.stmtInsertCRLInfo, err = db.Prepare(`
+	db.stmtInsertCRLInfo, err = db.Prepare(db.qualify(`
 		INSERT INTO crl_info
-		(url, issuer, next_update, last_processed, cert_count, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		(url, issuer, issuer_dn, next_update, last_processed, cert_count, crl_number, updated_at)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7, $8)
 		ON CONFLICT (url)
 		DO UPDATE SET
 			issuer = EXCLUDED.issuer,
+			issuer_dn = EXCLUDED.issuer_dn,
 			next_update = EXCLUDED.next_update,
 			last_processed = EXCLUDED.last_processed,
 			cert_count = EXCLUDED.cert_count,
+			crl_number = EXCLUDED.crl_number,
 			updated_at = EXCLUDED.updated_at
-	`)
+	`))
 	if err != nil {
 		return fmt.Errorf("error preparing stmtInsertCRLInfo: %v", err)
 	}
 
 	// Statement para estadísticas
-	db.stmtGetTotalCerts, err = db.Prepare("SELECT COUNT(*) FROM revoked_certificates")
+	db.stmtGetTotalCerts, err = db.Prepare(db.qualify("SELECT COUNT(*) FROM revoked_certificates"))
 	if err != nil {
 		return fmt.Errorf("error preparing stmtGetTotalCerts: %v", err)
 	}
 
-	db.stmtGetTotalCRLs, err = db.Prepare("SELECT COUNT(*) FROM crl_info")
+	db.stmtGetTotalCRLs, err = db.Prepare(db.qualify("SELECT COUNT(*) FROM crl_info"))
 	if err != nil {
 		return fmt.Errorf("error preparing stmtGetTotalCRLs: %v", err)
 	}
 
-	db.stmtGetLastUpdate, err = db.Prepare("SELECT COALESCE(MAX(last_processed), '1970-01-01') FROM crl_info")
+	db.stmtGetLastUpdate, err = db.Prepare(db.qualify("SELECT COALESCE(MAX(last_processed), '1970-01-01') FROM crl_info"))
 	if err != nil {
 		return fmt.Errorf("error preparing stmtGetLastUpdate: %v", err)
 	}
 
+	db.stmtGetCRLNumber, err = db.Prepare(db.qualify("SELECT crl_number::TEXT FROM crl_info WHERE url = $1"))
+	if err != nil {
+		return fmt.Errorf("error preparing stmtGetCRLNumber: %v", err)
+	}
+
+	// Statements de los listados y estadísticas por CA, que se consultan con frecuencia. El
+	// listado filtrado de ListRevokedCertificates arma el WHERE según los filtros presentes y
+	// no se prepara: un plan genérico con todos los filtros opcionales no usaría sus índices.
+
+	// Los seriales se guardan como texto decimal; el CASE evita que un valor no numérico
+	// haga fallar la conversión, ya que PostgreSQL no garantiza el orden de evaluación del WHERE
+	db.stmtListInRange, err = db.Prepare(db.qualify(`
+		SELECT id, serial, revocation_date, reason, COALESCE(reason_text, ''), certificate_authority, COALESCE(fingerprint, ''), created_at, updated_at, invalidity_date
+		FROM (
+			SELECT *, CASE WHEN serial ~ '^-?[0-9]+$' THEN serial::NUMERIC END AS serial_number
+			FROM revoked_certificates
+			WHERE certificate_authority = $1
+		) r
+		WHERE serial_number BETWEEN $2::NUMERIC AND $3::NUMERIC
+		ORDER BY serial_number
+		LIMIT $4
+	`))
+	if err != nil {
+		return fmt.Errorf("error preparing stmtListInRange: %v", err)
+	}
+
+	db.stmtGetRecent, err = db.Prepare(db.qualify(`
+		SELECT id, serial, revocation_date, reason, COALESCE(reason_text, ''), certificate_authority, COALESCE(fingerprint, ''), created_at, updated_at, invalidity_date
+		FROM revoked_certificates
+		ORDER BY revocation_date DESC, id DESC
+		LIMIT $1
+	`))
+	if err != nil {
+		return fmt.Errorf("error preparing stmtGetRecent: %v", err)
+	}
+
+	// Una sola consulta agrupa los revocados por emisor y une a cada grupo su CRL más reciente.
+	// Con y sin filtro de CA son statements distintos para que el filtro use los índices en
+	// lugar del plan genérico de un predicado opcional
+	db.stmtGetStatsAllCAs, err = db.Prepare(db.qualify(fmt.Sprintf(statsByCAQuery, "")))
+	if err != nil {
+		return fmt.Errorf("error preparing stmtGetStatsAllCAs: %v", err)
+	}
+
+	db.stmtGetStatsByCA, err = db.Prepare(db.qualify(fmt.Sprintf(statsByCAQuery, "WHERE certificate_authority = $1 OR issuer_dn = $1")))
+	if err != nil {
+		return fmt.Errorf("error preparing stmtGetStatsByCA: %v", err)
+	}
+
 	return nil
 }
 
+// statsByCAQuery es la consulta de GetStatsByCA; %s es el filtro de CA, vacío para todas
+const statsByCAQuery = `
+	SELECT r.certificate_authority, r.issuer_dn, r.revoked_count, c.url, c.next_update, c.last_processed
+	FROM (
+		SELECT MAX(certificate_authority) AS certificate_authority, issuer_dn, COUNT(*) AS revoked_count
+		FROM revoked_certificates
+		%s
+		GROUP BY issuer_dn, CASE WHEN issuer_dn IS NULL THEN certificate_authority END
+	) r
+	LEFT JOIN LATERAL (
+		SELECT url, next_update, last_processed
+		FROM crl_info
+		WHERE CASE WHEN r.issuer_dn IS NULL THEN issuer = r.certificate_authority ELSE issuer_dn = r.issuer_dn END
+		ORDER BY last_processed DESC
+		LIMIT 1
+	) c ON true
+	ORDER BY r.revoked_count DESC, r.certificate_authority
+`
+
 func (db *DB) createTables() error {
 	query := `
 	CREATE TABLE IF NOT EXISTS revoked_certificates (
 		id SERIAL PRIMARY KEY,
-		serial VARCHAR(255) NOT NULL UNIQUE,
+		serial VARCHAR(255) NOT NULL,
 		revocation_date TIMESTAMP NOT NULL,
 		reason INTEGER NOT NULL DEFAULT 0,
 		reason_text VARCHAR(255),
@@ -133,7 +273,8 @@ func (db *DB) createTables() error {
 	CREATE INDEX IF NOT EXISTS idx_revoked_certificates_serial ON revoked_certificates(serial);
 	CREATE INDEX IF NOT EXISTS idx_revoked_certificates_ca ON revoked_certificates(certificate_authority);
 	CREATE INDEX IF NOT EXISTS idx_revoked_certificates_revocation_date ON revoked_certificates(revocation_date);
-	CREATE INDEX IF NOT EXISTS idx_revoked_certificates_composite ON revoked_certificates(serial, certificate_authority);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_revoked_certificates_serial_ca ON revoked_certificates(serial, certificate_authority);
+	CREATE INDEX IF NOT EXISTS idx_revoked_certificates_reason ON revoked_certificates(reason);
 
 	CREATE TABLE IF NOT EXISTS crl_info (
 		id SERIAL PRIMARY KEY,
@@ -145,95 +286,415 @@ func (db *DB) createTables() error {
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 	);
+
+	CREATE TABLE IF NOT EXISTS crl_sources (
+		id SERIAL PRIMARY KEY,
+		url VARCHAR(500) NOT NULL UNIQUE,
+		label VARCHAR(255),
+		enabled BOOLEAN NOT NULL DEFAULT TRUE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS ca_certificates (
+		id SERIAL PRIMARY KEY,
+		subject VARCHAR(1000) NOT NULL,
+		key_id VARCHAR(64) NOT NULL UNIQUE,
+		not_before TIMESTAMP NOT NULL,
+		not_after TIMESTAMP NOT NULL,
+		der BYTEA NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_ca_certificates_subject ON ca_certificates(subject);
+
+	CREATE TABLE IF NOT EXISTS crl_processing_log (
+		id BIGSERIAL PRIMARY KEY,
+		url VARCHAR(1000) NOT NULL,
+		started_at TIMESTAMP NOT NULL,
+		duration_ms BIGINT NOT NULL,
+		http_status INTEGER,
+		bytes_downloaded BIGINT NOT NULL DEFAULT 0,
+		cert_count INTEGER NOT NULL DEFAULT 0,
+		status VARCHAR(20) NOT NULL,
+		error TEXT
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_crl_processing_log_url_started ON crl_processing_log(url, started_at DESC);
+	CREATE INDEX IF NOT EXISTS idx_crl_processing_log_started ON crl_processing_log(started_at);
+
+	ALTER TABLE revoked_certificates ADD COLUMN IF NOT EXISTS fingerprint VARCHAR(64);
+	CREATE INDEX IF NOT EXISTS idx_revoked_certificates_fingerprint ON revoked_certificates(fingerprint);
+
+	ALTER TABLE crl_sources ADD COLUMN IF NOT EXISTS client_cert VARCHAR(1000);
+	ALTER TABLE crl_sources ADD COLUMN IF NOT EXISTS client_key VARCHAR(1000);
+	ALTER TABLE crl_sources ADD COLUMN IF NOT EXISTS ca_bundle VARCHAR(1000);
+
+	ALTER TABLE crl_info ADD COLUMN IF NOT EXISTS crl_number NUMERIC;
+	ALTER TABLE crl_info ADD COLUMN IF NOT EXISTS etag VARCHAR(500);
+	ALTER TABLE crl_info ADD COLUMN IF NOT EXISTS last_modified VARCHAR(100);
+
+	ALTER TABLE revoked_certificates ADD COLUMN IF NOT EXISTS issuer_dn VARCHAR(1000);
+	CREATE INDEX IF NOT EXISTS idx_revoked_certificates_issuer_dn ON revoked_certificates(issuer_dn);
+	ALTER TABLE crl_info ADD COLUMN IF NOT EXISTS issuer_dn VARCHAR(1000);
+
+	ALTER TABLE crl_info ADD COLUMN IF NOT EXISTS last_success TIMESTAMP;
+	ALTER TABLE crl_info ADD COLUMN IF NOT EXISTS last_error TEXT;
+	ALTER TABLE crl_info ADD COLUMN IF NOT EXISTS consecutive_failures INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE crl_sources ADD COLUMN IF NOT EXISTS last_success TIMESTAMP;
+	ALTER TABLE crl_sources ADD COLUMN IF NOT EXISTS last_error TEXT;
+	ALTER TABLE crl_sources ADD COLUMN IF NOT EXISTS consecutive_failures INTEGER NOT NULL DEFAULT 0;
+
+	ALTER TABLE crl_processing_log ADD COLUMN IF NOT EXISTS skipped_entries INTEGER NOT NULL DEFAULT 0;
+
+	ALTER TABLE revoked_certificates ADD COLUMN IF NOT EXISTS invalidity_date TIMESTAMP;
+
+	-- Las filas anteriores al DN canónico lo toman de crl_info cuando su nombre corresponde
+	-- a un único DN; las demás lo reciben al volver a procesar la CRL de su emisor
+	UPDATE revoked_certificates r
+	SET issuer_dn = c.issuer_dn
+	FROM (
+		SELECT issuer, MIN(issuer_dn) AS issuer_dn
+		FROM crl_info
+		WHERE issuer_dn IS NOT NULL
+		GROUP BY issuer
+		HAVING COUNT(DISTINCT issuer_dn) = 1
+	) c
+	WHERE r.issuer_dn IS NULL AND r.certificate_authority = c.issuer;
+
+	-- Un mismo serial puede aparecer en CRLs de CAs distintas: la unicidad pasa a ser por
+	-- serial y CA. Se quita la restricción UNIQUE sobre serial de las tablas anteriores,
+	-- cuyo nombre depende del de la tabla
+	DO $$
+	DECLARE
+		serial_constraint TEXT;
+	BEGIN
+		FOR serial_constraint IN
+			SELECT con.conname
+			FROM pg_constraint con
+			JOIN pg_attribute att ON att.attrelid = con.conrelid AND att.attnum = ANY(con.conkey)
+			WHERE con.conrelid = 'revoked_certificates'::regclass
+				AND con.contype = 'u'
+				AND array_length(con.conkey, 1) = 1
+				AND att.attname = 'serial'
+		LOOP
+			EXECUTE format('ALTER TABLE %s DROP CONSTRAINT %I', 'revoked_certificates'::regclass, serial_constraint);
+		END LOOP;
+	END $$;
 	`
 
-	_, err := db.Exec(query)
+	// El índice único por serial y CA reemplaza al compuesto anterior; DROP INDEX necesita el
+	// schema de la tabla para encontrarlo
+	indexSchema := ""
+	if db.schema != "" {
+		indexSchema = db.schema + "."
+	}
+	query += "DROP INDEX IF EXISTS " + indexSchema + "idx_revoked_certificates_composite;\n"
+
+	if db.schema != "" {
+		query = "CREATE SCHEMA IF NOT EXISTS " + db.schema + ";\n" + query
+	}
+
+	_, err := db.Exec(db.qualify(query))
 	return err
 }
 
-func (db *DB) InsertRevokedCertificate(cert *models.RevokedCertificate) error {
+// ErrSerialTooLong indica un serial que no cabe en la columna serial
+var ErrSerialTooLong = errors.New("serial exceeds maximum length")
+
+func (db *DB) InsertRevokedCertificate(ctx context.Context, cert *models.RevokedCertificate) error {
+	if len(cert.Serial) > models.MaxSerialLength {
+		return ErrSerialTooLong
+	}
+
 	// Usar prepared statement para mejor rendimiento
-	_, err := db.stmtInsertCert.Exec(
+	_, err := db.stmtInsertCert.ExecContext(ctx,
 		cert.Serial,
 		cert.RevocationDate,
 		cert.Reason,
 		cert.ReasonText,
 		cert.CertificateAuthority,
+		cert.IssuerDN,
 		time.Now(),
+		cert.InvalidityDate,
 	)
 	return err
 }
 
 // BatchInsertRevokedCertificates inserta múltiples certificados en una sola transacción
-func (db *DB) BatchInsertRevokedCertificates(certs []*models.RevokedCertificate) error {
+// y devuelve los que no existían previamente (inserciones, no actualizaciones). Los seriales
+// vacíos o más largos que MaxSerialLength se omiten con una advertencia para no abortar el lote.
+func (db *DB) BatchInsertRevokedCertificates(ctx context.Context, certs []*models.RevokedCertificate) ([]*models.RevokedCertificate, error) {
 	if len(certs) == 0 {
-		return nil
+		return nil, nil
 	}
 
 	// Iniciar transacción
-	tx, err := db.Begin()
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("error starting transaction: %v", err)
+		return nil, fmt.Errorf("error starting transaction: %v", err)
 	}
 	defer tx.Rollback()
 
 	// Preparar statement dentro de la transacción
-	stmt, err := tx.Prepare(`
+	stmt, err := tx.PrepareContext(ctx, db.qualify(`
 		INSERT INTO revoked_certificates
-		(serial, revocation_date, reason, reason_text, certificate_authority, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		ON CONFLICT (serial)
+		(serial, revocation_date, reason, reason_text, certificate_authority, issuer_dn, updated_at, invalidity_date)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8)
+		ON CONFLICT (serial, certificate_authority)
 		DO UPDATE SET
 			revocation_date = EXCLUDED.revocation_date,
 			reason = EXCLUDED.reason,
 			reason_text = EXCLUDED.reason_text,
-			certificate_authority = EXCLUDED.certificate_authority,
-			updated_at = EXCLUDED.updated_at
-	`)
+			issuer_dn = EXCLUDED.issuer_dn,
+			updated_at = EXCLUDED.updated_at,
+			invalidity_date = EXCLUDED.invalidity_date
+		RETURNING (xmax = 0) AS inserted
+	`))
 	if err != nil {
-		return fmt.Errorf("error preparing statement: %v", err)
+		return nil, fmt.Errorf("error preparing statement: %v", err)
 	}
 	defer stmt.Close()
 
-	// Insertar certificados en batch
+	// Insertar certificados en batch; xmax = 0 identifica filas nuevas frente a actualizadas
 	now := time.Now()
+	var inserted []*models.RevokedCertificate
 	for _, cert := range certs {
-		_, err = stmt.Exec(
+		if cert.Serial == "" || len(cert.Serial) > models.MaxSerialLength {
+			log.Printf("Warning: skipping certificate from %s with serial of %d characters (max %d)",
+				cert.CertificateAuthority, len(cert.Serial), models.MaxSerialLength)
+			continue
+		}
+
+		var isNew bool
+		err = stmt.QueryRowContext(ctx,
 			cert.Serial,
 			cert.RevocationDate,
 			cert.Reason,
 			cert.ReasonText,
 			cert.CertificateAuthority,
+			cert.IssuerDN,
 			now,
-		)
+			cert.InvalidityDate,
+		).Scan(&isNew)
 		if err != nil {
-			return fmt.Errorf("error inserting certificate %s: %v", cert.Serial, err)
+			return nil, fmt.Errorf("error inserting certificate %s: %v", cert.Serial, err)
+		}
+		if isNew {
+			inserted = append(inserted, cert)
 		}
 	}
 
 	// Confirmar transacción
 	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("error committing transaction: %v", err)
+		return nil, fmt.Errorf("error committing transaction: %v", err)
 	}
 
-	return nil
+	return inserted, nil
+}
+
+// DeleteCertificatesNotIn elimina los certificados del emisor cuyo serial ya no figura en la CRL
+// y devuelve los seriales eliminados. El emisor se identifica por su DN; las filas sin DN,
+// anteriores a la columna issuer_dn, se identifican por el nombre certificateAuthority.
+func (db *DB) DeleteCertificatesNotIn(ctx context.Context, issuerDN, certificateAuthority string, serials []string) ([]string, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, db.qualify(`
+		DELETE FROM revoked_certificates
+		WHERE (issuer_dn = $1 OR (issuer_dn IS NULL AND certificate_authority = $2))
+		AND NOT (serial = ANY($3))
+		RETURNING serial
+	`), issuerDN, certificateAuthority, pq.Array(serials))
+	if err != nil {
+		return nil, fmt.Errorf("error deleting stale certificates: %v", err)
+	}
+
+	var deleted []string
+	for rows.Next() {
+		var serial string
+		if err := rows.Scan(&serial); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error scanning deleted serial: %v", err)
+		}
+		deleted = append(deleted, serial)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating deleted serials: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	return deleted, nil
+}
+
+// PurgeCA elimina en una transacción todos los certificados revocados de la CA y sus filas
+// de crl_info. Devuelve los seriales eliminados y el número de filas de crl_info.
+func (db *DB) PurgeCA(ctx context.Context, certificateAuthority string) ([]string, int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, db.qualify(`
+		DELETE FROM revoked_certificates
+		WHERE certificate_authority = $1
+		RETURNING serial
+	`), certificateAuthority)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error purging certificates: %v", err)
+	}
+
+	var deleted []string
+	for rows.Next() {
+		var serial string
+		if err := rows.Scan(&serial); err != nil {
+			rows.Close()
+			return nil, 0, fmt.Errorf("error scanning purged serial: %v", err)
+		}
+		deleted = append(deleted, serial)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error iterating purged serials: %v", err)
+	}
+
+	result, err := tx.ExecContext(ctx, db.qualify("DELETE FROM crl_info WHERE issuer = $1"), certificateAuthority)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error purging CRL info: %v", err)
+	}
+	crlInfos, err := result.RowsAffected()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, 0, fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	return deleted, int(crlInfos), nil
+}
+
+// DeleteRevokedCertificates elimina los certificados de la CA con los seriales indicados y
+// devuelve los que efectivamente existían; los del mismo serial de otras CAs no se tocan
+func (db *DB) DeleteRevokedCertificates(ctx context.Context, certificateAuthority string, serials []string) ([]string, error) {
+	if len(serials) == 0 {
+		return nil, nil
+	}
+
+	rows, err := db.QueryContext(ctx, db.qualify(`
+		DELETE FROM revoked_certificates
+		WHERE certificate_authority = $1 AND serial = ANY($2)
+		RETURNING serial
+	`), certificateAuthority, pq.Array(serials))
+	if err != nil {
+		return nil, fmt.Errorf("error deleting certificates: %v", err)
+	}
+	defer rows.Close()
+
+	var deleted []string
+	for rows.Next() {
+		var serial string
+		if err := rows.Scan(&serial); err != nil {
+			return nil, fmt.Errorf("error scanning deleted serial: %v", err)
+		}
+		deleted = append(deleted, serial)
+	}
+
+	return deleted, rows.Err()
+}
+
+// DeleteUntrackedCertificates elimina los certificados no actualizados desde antes de cutoff
+// cuya CA ya no tiene ninguna CRL configurada: ninguna de sus CRLs en crl_info está en
+// trackedURLs, en crl_sources (habilitada o no) ni se procesó después de cutoff. Una CA cuya
+// CRL sigue configurada conserva sus revocaciones aunque su descarga falle. Devuelve los
+// seriales eliminados.
+func (db *DB) DeleteUntrackedCertificates(ctx context.Context, cutoff time.Time, trackedURLs []string) ([]string, error) {
+	rows, err := db.QueryContext(ctx, db.qualify(`
+		DELETE FROM revoked_certificates r
+		WHERE r.updated_at < $1
+		AND NOT EXISTS (
+			SELECT 1 FROM crl_info c
+			WHERE (c.issuer_dn = r.issuer_dn OR c.issuer = r.certificate_authority)
+			AND (
+				c.last_processed >= $1
+				OR c.url = ANY($2)
+				OR EXISTS (SELECT 1 FROM crl_sources s WHERE s.url = c.url)
+			)
+		)
+		RETURNING r.serial
+	`), cutoff, pq.Array(trackedURLs))
+	if err != nil {
+		return nil, fmt.Errorf("error deleting untracked certificates: %v", err)
+	}
+	defer rows.Close()
+
+	var deleted []string
+	for rows.Next() {
+		var serial string
+		if err := rows.Scan(&serial); err != nil {
+			return nil, fmt.Errorf("error scanning deleted serial: %v", err)
+		}
+		deleted = append(deleted, serial)
+	}
+
+	return deleted, rows.Err()
+}
+
+func (db *DB) GetCertificateStatus(ctx context.Context, serial string) (*models.CertificateStatus, error) {
+	return db.getCertificateStatus(ctx, serial, "")
 }
 
-func (db *DB) GetCertificateStatus(serial string) (*models.CertificateStatus, error) {
+// GetCertificateStatusByCA busca el serial solo entre los certificados de la CA, para
+// distinguir seriales repetidos entre CAs
+func (db *DB) GetCertificateStatusByCA(ctx context.Context, serial, ca string) (*models.CertificateStatus, error) {
+	return db.getCertificateStatus(ctx, serial, ca)
+}
+
+// getCertificateStatus consulta el serial en la CA indicada o, con ca vacío, en todas
+func (db *DB) getCertificateStatus(ctx context.Context, serial, ca string) (*models.CertificateStatus, error) {
 	// Usar prepared statement para mejor rendimiento
+	return scanCertificateStatus(serial, db.stmtGetCertStatus.QueryRowContext(ctx, serial, ca))
+}
+
+// GetCertificateStatusAsOf busca la revocación más reciente del serial con fecha igual o
+// anterior a asOf, en la CA indicada o, con ca vacío, en todas
+func (db *DB) GetCertificateStatusAsOf(ctx context.Context, serial, ca string, asOf time.Time) (*models.CertificateStatus, error) {
+	return scanCertificateStatus(serial, db.QueryRowContext(ctx, db.qualify(`
+		SELECT serial, revocation_date, reason, reason_text, certificate_authority, COALESCE(fingerprint, ''), invalidity_date
+		FROM revoked_certificates
+		WHERE serial = $1 AND ($2 = '' OR certificate_authority = $2) AND revocation_date <= $3
+		ORDER BY revocation_date DESC, certificate_authority
+		LIMIT 1
+	`), serial, ca, asOf))
+}
+
+// scanCertificateStatus arma el estado del serial a partir de una fila con las columnas de
+// stmtGetCertStatus, en ese orden; sin fila el certificado no está revocado
+func scanCertificateStatus(serial string, row rowScanner) (*models.CertificateStatus, error) {
 	var cert models.RevokedCertificate
-	err := db.stmtGetCertStatus.QueryRow(serial).Scan(
+	var invalidityDate sql.NullTime
+	err := row.Scan(
 		&cert.Serial,
 		&cert.RevocationDate,
 		&cert.Reason,
 		&cert.ReasonText,
 		&cert.CertificateAuthority,
+		&cert.Fingerprint,
+		&invalidityDate,
 	)
 
 	if err == sql.ErrNoRows {
 		return &models.CertificateStatus{
 			Serial:    serial,
 			IsRevoked: false,
+			Status:    models.StatusGood,
 		}, nil
 	}
 
@@ -246,45 +707,458 @@ func (db *DB) GetCertificateStatus(serial string) (*models.CertificateStatus, er
 		reasonText = cert.ReasonText
 	}
 
-	return &models.CertificateStatus{
+	status := &models.CertificateStatus{
 		Serial:               serial,
-		IsRevoked:           true,
-		RevocationDate:      &cert.RevocationDate,
-		Reason:              &reasonText,
+		IsRevoked:            true,
+		Status:               models.RevokedStatus(cert.Reason),
+		RevocationDate:       &cert.RevocationDate,
+		Reason:               &reasonText,
+		ReasonCode:           &cert.Reason,
 		CertificateAuthority: &cert.CertificateAuthority,
+		InvalidityDate:       nullTimePtr(invalidityDate),
+	}
+	if cert.Fingerprint != "" {
+		status.Fingerprint = &cert.Fingerprint
+	}
+
+	return status, nil
+}
+
+// GetCertificateByFingerprint busca un certificado revocado por su huella SHA-256 en hexadecimal.
+// Devuelve nil si la huella no está registrada.
+func (db *DB) GetCertificateByFingerprint(ctx context.Context, fingerprint string) (*models.CertificateStatus, error) {
+	var cert models.RevokedCertificate
+	var invalidityDate sql.NullTime
+	err := db.QueryRowContext(ctx, db.qualify(`
+		SELECT serial, revocation_date, reason, COALESCE(reason_text, ''), certificate_authority, invalidity_date
+		FROM revoked_certificates
+		WHERE fingerprint = $1
+	`), fingerprint).Scan(
+		&cert.Serial,
+		&cert.RevocationDate,
+		&cert.Reason,
+		&cert.ReasonText,
+		&cert.CertificateAuthority,
+		&invalidityDate,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	reasonText := models.RevocationReasons[cert.Reason]
+	if cert.ReasonText != "" {
+		reasonText = cert.ReasonText
+	}
+
+	return &models.CertificateStatus{
+		Serial:               cert.Serial,
+		IsRevoked:            true,
+		Status:               models.RevokedStatus(cert.Reason),
+		RevocationDate:       &cert.RevocationDate,
+		Reason:               &reasonText,
+		ReasonCode:           &cert.Reason,
+		CertificateAuthority: &cert.CertificateAuthority,
+		Fingerprint:          &fingerprint,
+		InvalidityDate:       nullTimePtr(invalidityDate),
 	}, nil
 }
 
-func (db *DB) InsertCRLInfo(crlInfo *models.CRLInfo) error {
+// SetCertificateFingerprint registra la huella de un certificado revocado ya importado
+func (db *DB) SetCertificateFingerprint(ctx context.Context, serial, certificateAuthority, fingerprint string) error {
+	_, err := db.ExecContext(ctx,
+		db.qualify("UPDATE revoked_certificates SET fingerprint = $3 WHERE serial = $1 AND certificate_authority = $2"),
+		serial, certificateAuthority, fingerprint,
+	)
+	return err
+}
+
+// HasCRLForIssuer indica si se ha procesado alguna CRL del emisor
+func (db *DB) HasCRLForIssuer(ctx context.Context, issuer string) (bool, error) {
+	var exists bool
+	err := db.QueryRowContext(ctx, db.qualify("SELECT EXISTS(SELECT 1 FROM crl_info WHERE issuer = $1)"), issuer).Scan(&exists)
+	return exists, err
+}
+
+// ListRevokedCertificates devuelve una página de certificados revocados y el total que cumple el filtro.
+// Si filter.AfterID no es nil pagina por cursor sobre id, ignora offset y no calcula el total
+// para que el costo dependa solo de limit y no de la profundidad de la página
+func (db *DB) ListRevokedCertificates(ctx context.Context, filter models.CertificateFilter, limit, offset int) ([]*models.RevokedCertificate, int, error) {
+	var conditions []string
+	var args []interface{}
+
+	if filter.CertificateAuthority != "" {
+		args = append(args, filter.CertificateAuthority)
+		conditions = append(conditions, fmt.Sprintf("certificate_authority = $%d", len(args)))
+	}
+	if filter.Reason != nil {
+		args = append(args, *filter.Reason)
+		conditions = append(conditions, fmt.Sprintf("reason = $%d", len(args)))
+	}
+	if filter.From != nil {
+		args = append(args, *filter.From)
+		conditions = append(conditions, fmt.Sprintf("revocation_date >= $%d", len(args)))
+	}
+	if filter.To != nil {
+		args = append(args, *filter.To)
+		conditions = append(conditions, fmt.Sprintf("revocation_date <= $%d", len(args)))
+	}
+
+	if filter.AfterID != nil && *filter.AfterID > 0 {
+		args = append(args, *filter.AfterID)
+		conditions = append(conditions, fmt.Sprintf("id < $%d", len(args)))
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	var query string
+	if filter.AfterID != nil {
+		query = fmt.Sprintf(`
+			SELECT id, serial, revocation_date, reason, COALESCE(reason_text, ''), certificate_authority, COALESCE(fingerprint, ''), created_at, updated_at, invalidity_date
+			FROM revoked_certificates
+			%s
+			ORDER BY id DESC
+			LIMIT $%d
+		`, where, len(args)+1)
+		args = append(args, limit)
+	} else {
+		err := db.QueryRowContext(ctx, db.qualify("SELECT COUNT(*) FROM revoked_certificates "+where), args...).Scan(&total)
+		if err != nil {
+			return nil, 0, fmt.Errorf("error counting certificates: %v", err)
+		}
+
+		query = fmt.Sprintf(`
+			SELECT id, serial, revocation_date, reason, COALESCE(reason_text, ''), certificate_authority, COALESCE(fingerprint, ''), created_at, updated_at, invalidity_date
+			FROM revoked_certificates
+			%s
+			ORDER BY revocation_date DESC, id DESC
+			LIMIT $%d OFFSET $%d
+		`, where, len(args)+1, len(args)+2)
+		args = append(args, limit, offset)
+	}
+
+	rows, err := db.QueryContext(ctx, db.qualify(query), args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error listing certificates: %v", err)
+	}
+	defer rows.Close()
+
+	certs := make([]*models.RevokedCertificate, 0, limit)
+	for rows.Next() {
+		cert, err := scanRevokedCertificate(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("error scanning certificate: %v", err)
+		}
+		certs = append(certs, cert)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error iterating certificates: %v", err)
+	}
+
+	return certs, total, nil
+}
+
+// ListRevokedInRange devuelve hasta limit certificados revocados de la CA cuyo serial, como
+// entero, está entre from y to (ambos incluidos). from y to son seriales decimales.
+func (db *DB) ListRevokedInRange(ctx context.Context, ca, from, to string, limit int) ([]*models.RevokedCertificate, error) {
+	rows, err := db.stmtListInRange.QueryContext(ctx, ca, from, to, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error listing certificates in range: %v", err)
+	}
+	defer rows.Close()
+
+	certs := make([]*models.RevokedCertificate, 0)
+	for rows.Next() {
+		cert, err := scanRevokedCertificate(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning certificate: %v", err)
+		}
+		certs = append(certs, cert)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating certificates: %v", err)
+	}
+
+	return certs, nil
+}
+
+// GetRecentRevoked devuelve los n certificados revocados más recientes por fecha de revocación
+func (db *DB) GetRecentRevoked(ctx context.Context, n int) ([]*models.RevokedCertificate, error) {
+	rows, err := db.stmtGetRecent.QueryContext(ctx, n)
+	if err != nil {
+		return nil, fmt.Errorf("error querying recent revocations: %v", err)
+	}
+	defer rows.Close()
+
+	certs := make([]*models.RevokedCertificate, 0, n)
+	for rows.Next() {
+		cert, err := scanRevokedCertificate(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning certificate: %v", err)
+		}
+		certs = append(certs, cert)
+	}
+
+	return certs, rows.Err()
+}
+
+// scanRevokedCertificate lee una fila de los listados de certificados revocados
+func scanRevokedCertificate(row rowScanner) (*models.RevokedCertificate, error) {
+	var cert models.RevokedCertificate
+	var invalidityDate sql.NullTime
+	err := row.Scan(
+		&cert.ID,
+		&cert.Serial,
+		&cert.RevocationDate,
+		&cert.Reason,
+		&cert.ReasonText,
+		&cert.CertificateAuthority,
+		&cert.Fingerprint,
+		&cert.CreatedAt,
+		&cert.UpdatedAt,
+		&invalidityDate,
+	)
+	if err != nil {
+		return nil, err
+	}
+	cert.InvalidityDate = nullTimePtr(invalidityDate)
+	return &cert, nil
+}
+
+// StreamRevokedCertificates recorre todos los certificados revocados (opcionalmente de una CA)
+// llamando a fn por cada fila, sin cargar el resultado completo en memoria
+func (db *DB) StreamRevokedCertificates(ctx context.Context, ca string, fn func(*models.RevokedCertificate) error) error {
+	rows, err := db.QueryContext(ctx, db.qualify(`
+		SELECT serial, revocation_date, reason, COALESCE(reason_text, ''), certificate_authority
+		FROM revoked_certificates
+		WHERE $1 = '' OR certificate_authority = $1
+		ORDER BY id
+	`), ca)
+	if err != nil {
+		return fmt.Errorf("error querying certificates for export: %v", err)
+	}
+	defer rows.Close()
+
+	var cert models.RevokedCertificate
+	for rows.Next() {
+		err := rows.Scan(
+			&cert.Serial,
+			&cert.RevocationDate,
+			&cert.Reason,
+			&cert.ReasonText,
+			&cert.CertificateAuthority,
+		)
+		if err != nil {
+			return fmt.Errorf("error scanning certificate for export: %v", err)
+		}
+		if err := fn(&cert); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (db *DB) InsertCRLInfo(ctx context.Context, crlInfo *models.CRLInfo) error {
 	// Usar prepared statement para mejor rendimiento
-	_, err := db.stmtInsertCRLInfo.Exec(
+	_, err := db.stmtInsertCRLInfo.ExecContext(ctx,
 		crlInfo.URL,
 		crlInfo.Issuer,
+		crlInfo.IssuerDN,
 		crlInfo.NextUpdate,
 		crlInfo.LastProcessed,
 		crlInfo.CertCount,
+		sql.NullString{String: crlInfo.CRLNumber, Valid: crlInfo.CRLNumber != ""},
 		time.Now(),
 	)
 	return err
 }
 
-func (db *DB) GetCRLStats() (map[string]interface{}, error) {
+// GetCRLNumber devuelve el último CRLNumber registrado para la URL, o "" si no existe
+func (db *DB) GetCRLNumber(ctx context.Context, url string) (string, error) {
+	var number sql.NullString
+	err := db.stmtGetCRLNumber.QueryRowContext(ctx, url).Scan(&number)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return number.String, nil
+}
+
+// GetCRLValidators devuelve el ETag y Last-Modified guardados para la URL
+func (db *DB) GetCRLValidators(ctx context.Context, url string) (*models.CRLValidators, error) {
+	var validators models.CRLValidators
+	err := db.QueryRowContext(ctx,
+		db.qualify("SELECT COALESCE(etag, ''), COALESCE(last_modified, '') FROM crl_info WHERE url = $1"),
+		url,
+	).Scan(&validators.ETag, &validators.LastModified)
+	if err == sql.ErrNoRows {
+		return &validators, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &validators, nil
+}
+
+// UpdateCRLValidators guarda los validadores HTTP de la última descarga completa
+func (db *DB) UpdateCRLValidators(ctx context.Context, url string, validators *models.CRLValidators) error {
+	_, err := db.ExecContext(ctx,
+		db.qualify("UPDATE crl_info SET etag = NULLIF($2, ''), last_modified = NULLIF($3, ''), updated_at = $4 WHERE url = $1"),
+		url, validators.ETag, validators.LastModified, time.Now(),
+	)
+	return err
+}
+
+// TouchCRLInfo actualiza last_processed cuando la CRL no cambió desde la última descarga
+func (db *DB) TouchCRLInfo(ctx context.Context, url string) error {
+	now := time.Now()
+	_, err := db.ExecContext(ctx,
+		db.qualify("UPDATE crl_info SET last_processed = $2, updated_at = $2 WHERE url = $1"),
+		url, now,
+	)
+	return err
+}
+
+// RecordCRLAttempt registra el resultado de un intento de procesar la CRL en su fila de crl_info
+// y en su fuente de crl_sources, las que existan. Un éxito (attemptErr nil) guarda last_success
+// y reinicia consecutive_failures; un fallo lo incrementa y guarda el error en last_error.
+// Devuelve los fallos consecutivos, el mayor de ambas filas.
+func (db *DB) RecordCRLAttempt(ctx context.Context, url string, attemptErr error) (int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	failures := 0
+	for _, table := range []string{"crl_info", "crl_sources"} {
+		if attemptErr == nil {
+			_, err := tx.ExecContext(ctx, db.qualify(`
+				UPDATE `+table+` SET last_success = $2, last_error = NULL, consecutive_failures = 0
+				WHERE url = $1
+			`), url, time.Now())
+			if err != nil {
+				return 0, fmt.Errorf("error recording CRL attempt: %v", err)
+			}
+			continue
+		}
+
+		var count int
+		err := tx.QueryRowContext(ctx, db.qualify(`
+			UPDATE `+table+` SET last_error = $2, consecutive_failures = consecutive_failures + 1
+			WHERE url = $1
+			RETURNING consecutive_failures
+		`), url, attemptErr.Error()).Scan(&count)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("error recording CRL attempt: %v", err)
+		}
+		failures = max(failures, count)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error committing CRL attempt: %v", err)
+	}
+	return failures, nil
+}
+
+// ListCRLInfo devuelve la información registrada de cada CRL procesada
+func (db *DB) ListCRLInfo(ctx context.Context) ([]*models.CRLInfo, error) {
+	rows, err := db.QueryContext(ctx, db.qualify(`
+		SELECT url, issuer, COALESCE(issuer_dn, ''), next_update, last_processed, cert_count, COALESCE(crl_number::TEXT, ''),
+			last_success, COALESCE(last_error, ''), consecutive_failures
+		FROM crl_info
+		ORDER BY issuer, url
+	`))
+	if err != nil {
+		return nil, fmt.Errorf("error listing CRL info: %v", err)
+	}
+	defer rows.Close()
+
+	infos := make([]*models.CRLInfo, 0)
+	for rows.Next() {
+		var info models.CRLInfo
+		var nextUpdate, lastSuccess sql.NullTime
+		err := rows.Scan(
+			&info.URL,
+			&info.Issuer,
+			&info.IssuerDN,
+			&nextUpdate,
+			&info.LastProcessed,
+			&info.CertCount,
+			&info.CRLNumber,
+			&lastSuccess,
+			&info.LastError,
+			&info.ConsecutiveFailures,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning CRL info: %v", err)
+		}
+		info.NextUpdate = nextUpdate.Time
+		info.LastSuccess = nullTimePtr(lastSuccess)
+		infos = append(infos, &info)
+	}
+
+	return infos, rows.Err()
+}
+
+// GetCRLInfo devuelve los metadatos de la CRL; devuelve sql.ErrNoRows si la URL no existe
+func (db *DB) GetCRLInfo(ctx context.Context, url string) (*models.CRLInfo, error) {
+	var info models.CRLInfo
+	var nextUpdate, lastSuccess sql.NullTime
+	err := db.QueryRowContext(ctx, db.qualify(`
+		SELECT url, issuer, COALESCE(issuer_dn, ''), next_update, last_processed, cert_count, COALESCE(crl_number::TEXT, ''),
+			last_success, COALESCE(last_error, ''), consecutive_failures
+		FROM crl_info
+		WHERE url = $1
+	`), url).Scan(
+		&info.URL,
+		&info.Issuer,
+		&info.IssuerDN,
+		&nextUpdate,
+		&info.LastProcessed,
+		&info.CertCount,
+		&info.CRLNumber,
+		&lastSuccess,
+		&info.LastError,
+		&info.ConsecutiveFailures,
+	)
+	if err != nil {
+		return nil, err
+	}
+	info.NextUpdate = nextUpdate.Time
+	info.LastSuccess = nullTimePtr(lastSuccess)
+
+	return &info, nil
+}
+
+func (db *DB) GetCRLStats(ctx context.Context) (map[string]interface{}, error) {
 	var totalCerts int
 	var totalCRLs int
 	var lastUpdate time.Time
 
 	// Usar prepared statements para mejor rendimiento
-	err := db.stmtGetTotalCerts.QueryRow().Scan(&totalCerts)
+	err := db.stmtGetTotalCerts.QueryRowContext(ctx).Scan(&totalCerts)
 	if err != nil {
 		return nil, err
 	}
 
-	err = db.stmtGetTotalCRLs.QueryRow().Scan(&totalCRLs)
+	err = db.stmtGetTotalCRLs.QueryRowContext(ctx).Scan(&totalCRLs)
 	if err != nil {
 		return nil, err
 	}
 
-	err = db.stmtGetLastUpdate.QueryRow().Scan(&lastUpdate)
+	err = db.stmtGetLastUpdate.QueryRowContext(ctx).Scan(&lastUpdate)
 	if err != nil {
 		return nil, err
 	}
@@ -296,6 +1170,375 @@ func (db *DB) GetCRLStats() (map[string]interface{}, error) {
 	}, nil
 }
 
+// GetLastProcessed devuelve la fecha del último procesamiento exitoso de cualquier CRL
+func (db *DB) GetLastProcessed(ctx context.Context) (time.Time, error) {
+	var lastUpdate time.Time
+	err := db.stmtGetLastUpdate.QueryRowContext(ctx).Scan(&lastUpdate)
+	return lastUpdate, err
+}
+
+// rowScanner es la parte común de *sql.Row y *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanCRLSource lee una fuente con las columnas de ListCRLSources, en ese orden
+func scanCRLSource(row rowScanner) (*models.CRLSource, error) {
+	var source models.CRLSource
+	var lastSuccess sql.NullTime
+	err := row.Scan(
+		&source.ID,
+		&source.URL,
+		&source.Label,
+		&source.Enabled,
+		&source.ClientCert,
+		&source.ClientKey,
+		&source.CABundle,
+		&source.CreatedAt,
+		&lastSuccess,
+		&source.LastError,
+		&source.ConsecutiveFailures,
+	)
+	if err != nil {
+		return nil, err
+	}
+	source.LastSuccess = nullTimePtr(lastSuccess)
+	return &source, nil
+}
+
+// nullTimePtr convierte una fecha opcional de la base en un puntero, nil si es NULL
+func nullTimePtr(value sql.NullTime) *time.Time {
+	if !value.Valid {
+		return nil
+	}
+	return &value.Time
+}
+
+// ErrDuplicateSource indica que la URL ya está registrada como fuente
+var ErrDuplicateSource = errors.New("CRL source already exists")
+
+func (db *DB) ListCRLSources(ctx context.Context) ([]*models.CRLSource, error) {
+	rows, err := db.QueryContext(ctx, db.qualify(`
+		SELECT id, url, COALESCE(label, ''), enabled, COALESCE(client_cert, ''), COALESCE(client_key, ''), COALESCE(ca_bundle, ''), created_at,
+			last_success, COALESCE(last_error, ''), consecutive_failures
+		FROM crl_sources
+		ORDER BY id
+	`))
+	if err != nil {
+		return nil, fmt.Errorf("error listing CRL sources: %v", err)
+	}
+	defer rows.Close()
+
+	sources := make([]*models.CRLSource, 0)
+	for rows.Next() {
+		source, err := scanCRLSource(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning CRL source: %v", err)
+		}
+		sources = append(sources, source)
+	}
+
+	return sources, rows.Err()
+}
+
+// GetCRLSourceByURL devuelve la fuente registrada para la URL; sql.ErrNoRows si no existe
+func (db *DB) GetCRLSourceByURL(ctx context.Context, url string) (*models.CRLSource, error) {
+	return scanCRLSource(db.QueryRowContext(ctx, db.qualify(`
+		SELECT id, url, COALESCE(label, ''), enabled, COALESCE(client_cert, ''), COALESCE(client_key, ''), COALESCE(ca_bundle, ''), created_at,
+			last_success, COALESCE(last_error, ''), consecutive_failures
+		FROM crl_sources
+		WHERE url = $1
+	`), url))
+}
+
+// AddCRLSource registra una nueva fuente y completa su ID y fecha de creación
+func (db *DB) AddCRLSource(ctx context.Context, source *models.CRLSource) error {
+	err := db.QueryRowContext(ctx, db.qualify(`
+		INSERT INTO crl_sources (url, label, enabled, client_cert, client_key, ca_bundle)
+		VALUES ($1, NULLIF($2, ''), $3, NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, ''))
+		RETURNING id, created_at
+	`), source.URL, source.Label, source.Enabled, source.ClientCert, source.ClientKey, source.CABundle).Scan(&source.ID, &source.CreatedAt)
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrDuplicateSource
+	}
+	return err
+}
+
+// SetCRLSourceEnabled habilita o deshabilita la fuente y la devuelve actualizada;
+// sql.ErrNoRows si no existe
+func (db *DB) SetCRLSourceEnabled(ctx context.Context, id int, enabled bool) (*models.CRLSource, error) {
+	return scanCRLSource(db.QueryRowContext(ctx, db.qualify(`
+		UPDATE crl_sources SET enabled = $2
+		WHERE id = $1
+		RETURNING id, url, COALESCE(label, ''), enabled, COALESCE(client_cert, ''), COALESCE(client_key, ''), COALESCE(ca_bundle, ''), created_at,
+			last_success, COALESCE(last_error, ''), consecutive_failures
+	`), id, enabled))
+}
+
+// DeleteCRLSource elimina la fuente; devuelve sql.ErrNoRows si no existe
+func (db *DB) DeleteCRLSource(ctx context.Context, id int) error {
+	result, err := db.ExecContext(ctx, db.qualify("DELETE FROM crl_sources WHERE id = $1"), id)
+	if err != nil {
+		return fmt.Errorf("error deleting CRL source: %v", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// InsertProcessingLog registra un intento de procesamiento de CRL
+func (db *DB) InsertProcessingLog(ctx context.Context, entry *models.ProcessingLogEntry) error {
+	_, err := db.ExecContext(ctx, db.qualify(`
+		INSERT INTO crl_processing_log (url, started_at, duration_ms, http_status, bytes_downloaded, cert_count, skipped_entries, status, error)
+		VALUES ($1, $2, $3, NULLIF($4, 0), $5, $6, $7, $8, NULLIF($9, ''))
+	`), entry.URL, entry.StartedAt, entry.DurationMs, entry.HTTPStatus, entry.BytesDownloaded, entry.CertCount, entry.SkippedEntries, entry.Status, entry.Error)
+	if err != nil {
+		return fmt.Errorf("error inserting processing log: %v", err)
+	}
+	return nil
+}
+
+// GetProcessingHistory devuelve los intentos más recientes primero, opcionalmente de una sola URL
+func (db *DB) GetProcessingHistory(ctx context.Context, url string, limit int) ([]*models.ProcessingLogEntry, error) {
+	rows, err := db.QueryContext(ctx, db.qualify(`
+		SELECT id, url, started_at, duration_ms, COALESCE(http_status, 0), bytes_downloaded, cert_count, skipped_entries, status, COALESCE(error, '')
+		FROM crl_processing_log
+		WHERE $1 = '' OR url = $1
+		ORDER BY started_at DESC, id DESC
+		LIMIT $2
+	`), url, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error querying processing history: %v", err)
+	}
+	defer rows.Close()
+
+	entries := make([]*models.ProcessingLogEntry, 0)
+	for rows.Next() {
+		var entry models.ProcessingLogEntry
+		err := rows.Scan(
+			&entry.ID,
+			&entry.URL,
+			&entry.StartedAt,
+			&entry.DurationMs,
+			&entry.HTTPStatus,
+			&entry.BytesDownloaded,
+			&entry.CertCount,
+			&entry.SkippedEntries,
+			&entry.Status,
+			&entry.Error,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning processing log: %v", err)
+		}
+		entries = append(entries, &entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// GetThroughput agrupa el historial de procesamiento desde since en intervalos de bucket
+// (hour o day), sumando los certificados importados por los procesamientos exitosos. Los
+// intervalos sin procesamientos no se incluyen.
+func (db *DB) GetThroughput(ctx context.Context, since time.Time, bucket string) ([]*models.ThroughputBucket, error) {
+	rows, err := db.QueryContext(ctx, db.qualify(`
+		SELECT
+			date_trunc($1, started_at) AS bucket_start,
+			COUNT(*),
+			COUNT(*) FILTER (WHERE status = $3),
+			COALESCE(SUM(cert_count) FILTER (WHERE status = $4), 0),
+			COALESCE(SUM(duration_ms), 0)
+		FROM crl_processing_log
+		WHERE started_at >= $2
+		GROUP BY bucket_start
+		ORDER BY bucket_start
+	`), bucket, since, models.ProcessingStatusFailed, models.ProcessingStatusSuccess)
+	if err != nil {
+		return nil, fmt.Errorf("error querying throughput: %v", err)
+	}
+	defer rows.Close()
+
+	buckets := make([]*models.ThroughputBucket, 0)
+	for rows.Next() {
+		var b models.ThroughputBucket
+		if err := rows.Scan(&b.BucketStart, &b.Runs, &b.FailedRuns, &b.CertsImported, &b.DurationMs); err != nil {
+			return nil, fmt.Errorf("error scanning throughput: %v", err)
+		}
+		buckets = append(buckets, &b)
+	}
+
+	return buckets, rows.Err()
+}
+
+// PruneProcessingLog elimina las entradas del historial anteriores a cutoff y devuelve cuántas borró
+func (db *DB) PruneProcessingLog(ctx context.Context, cutoff time.Time) (int, error) {
+	result, err := db.ExecContext(ctx, db.qualify("DELETE FROM crl_processing_log WHERE started_at < $1"), cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("error pruning processing log: %v", err)
+	}
+
+	affected, err := result.RowsAffected()
+	return int(affected), err
+}
+
+// ErrDuplicateCA indica que ya existe un certificado de CA con el mismo identificador de clave
+var ErrDuplicateCA = errors.New("CA certificate already exists")
+
+// AddCACertificate registra el certificado de CA y completa su ID y fecha de creación
+func (db *DB) AddCACertificate(ctx context.Context, ca *models.CACertificate) error {
+	err := db.QueryRowContext(ctx, db.qualify(`
+		INSERT INTO ca_certificates (subject, key_id, not_before, not_after, der)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`), ca.Subject, ca.KeyID, ca.NotBefore, ca.NotAfter, ca.DER).Scan(&ca.ID, &ca.CreatedAt)
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrDuplicateCA
+	}
+	return err
+}
+
+func (db *DB) ListCACertificates(ctx context.Context) ([]*models.CACertificate, error) {
+	return db.queryCACertificates(ctx, `
+		SELECT id, subject, key_id, not_before, not_after, der, created_at
+		FROM ca_certificates
+		ORDER BY id
+	`)
+}
+
+// GetCACertificatesBySubject devuelve los certificados de CA registrados con ese subject
+func (db *DB) GetCACertificatesBySubject(ctx context.Context, subject string) ([]*models.CACertificate, error) {
+	return db.queryCACertificates(ctx, `
+		SELECT id, subject, key_id, not_before, not_after, der, created_at
+		FROM ca_certificates
+		WHERE subject = $1
+		ORDER BY id
+	`, subject)
+}
+
+func (db *DB) queryCACertificates(ctx context.Context, query string, args ...interface{}) ([]*models.CACertificate, error) {
+	rows, err := db.QueryContext(ctx, db.qualify(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying CA certificates: %v", err)
+	}
+	defer rows.Close()
+
+	cas := make([]*models.CACertificate, 0)
+	for rows.Next() {
+		var ca models.CACertificate
+		err := rows.Scan(&ca.ID, &ca.Subject, &ca.KeyID, &ca.NotBefore, &ca.NotAfter, &ca.DER, &ca.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning CA certificate: %v", err)
+		}
+		cas = append(cas, &ca)
+	}
+
+	return cas, rows.Err()
+}
+
+// DeleteCACertificate elimina el certificado de CA; devuelve sql.ErrNoRows si no existe
+func (db *DB) DeleteCACertificate(ctx context.Context, id int) error {
+	result, err := db.ExecContext(ctx, db.qualify("DELETE FROM ca_certificates WHERE id = $1"), id)
+	if err != nil {
+		return fmt.Errorf("error deleting CA certificate: %v", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetReasonBreakdown cuenta los certificados revocados por código de motivo, opcionalmente
+// solo los de la CA indicada
+func (db *DB) GetReasonBreakdown(ctx context.Context, ca string) (map[int]models.ReasonCount, error) {
+	rows, err := db.QueryContext(ctx, db.qualify(`
+		SELECT reason, COUNT(*)
+		FROM revoked_certificates
+		WHERE ($1 = '' OR certificate_authority = $1)
+		GROUP BY reason
+	`), ca)
+	if err != nil {
+		return nil, fmt.Errorf("error querying reason breakdown: %v", err)
+	}
+	defer rows.Close()
+
+	breakdown := make(map[int]models.ReasonCount)
+	for rows.Next() {
+		var reason, count int
+		if err := rows.Scan(&reason, &count); err != nil {
+			return nil, fmt.Errorf("error scanning reason breakdown: %v", err)
+		}
+		breakdown[reason] = models.ReasonCount{
+			ReasonText: models.RevocationReasons[reason],
+			Count:      count,
+		}
+	}
+
+	return breakdown, rows.Err()
+}
+
+// GetStatsByCA devuelve el total de revocados por CA junto con su CRL procesada más recientemente.
+// Las CAs se agrupan por DN canónico (por nombre las filas que aún no lo tienen). Si ca no está
+// vacío se filtra por esa CA, indicada por nombre o por DN.
+func (db *DB) GetStatsByCA(ctx context.Context, ca string) ([]*models.CAStats, error) {
+	var rows *sql.Rows
+	var err error
+	if ca == "" {
+		rows, err = db.stmtGetStatsAllCAs.QueryContext(ctx)
+	} else {
+		rows, err = db.stmtGetStatsByCA.QueryContext(ctx, ca)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting stats by CA: %v", err)
+	}
+	defer rows.Close()
+
+	stats := make([]*models.CAStats, 0)
+	for rows.Next() {
+		var stat models.CAStats
+		var issuerDN, url sql.NullString
+		var nextUpdate, lastProcessed sql.NullTime
+		err := rows.Scan(
+			&stat.CertificateAuthority,
+			&issuerDN,
+			&stat.RevokedCount,
+			&url,
+			&nextUpdate,
+			&lastProcessed,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning CA stats: %v", err)
+		}
+		stat.IssuerDN = issuerDN.String
+		if url.Valid {
+			stat.CRLURL = &url.String
+		}
+		if nextUpdate.Valid {
+			stat.NextUpdate = &nextUpdate.Time
+			stat.IsStale = nextUpdate.Time.Before(time.Now())
+		}
+		if lastProcessed.Valid {
+			stat.LastProcessed = &lastProcessed.Time
+		}
+		stats = append(stats, &stat)
+	}
+
+	return stats, rows.Err()
+}
+
 // Close cierra todas las prepared statements y la conexión a la base de datos
 func (db *DB) Close() error {
 	// Cerrar todos los prepared statements
@@ -317,6 +1560,21 @@ func (db *DB) Close() error {
 	if db.stmtGetLastUpdate != nil {
 		db.stmtGetLastUpdate.Close()
 	}
+	if db.stmtGetCRLNumber != nil {
+		db.stmtGetCRLNumber.Close()
+	}
+	if db.stmtListInRange != nil {
+		db.stmtListInRange.Close()
+	}
+	if db.stmtGetRecent != nil {
+		db.stmtGetRecent.Close()
+	}
+	if db.stmtGetStatsAllCAs != nil {
+		db.stmtGetStatsAllCAs.Close()
+	}
+	if db.stmtGetStatsByCA != nil {
+		db.stmtGetStatsByCA.Close()
+	}
 
 	// Cerrar la conexión a la base de datos
 	return db.DB.Close()
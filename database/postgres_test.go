@@ -0,0 +1,70 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPoolConfigIsApplied(t *testing.T) {
+	pool := PoolConfig{
+		MaxOpenConns:    3,
+		MaxIdleConns:    1,
+		ConnMaxLifetime: 50 * time.Millisecond,
+		ConnMaxIdleTime: time.Minute,
+	}
+	db, err := NewSQLiteDB(filepath.Join(t.TempDir(), "crl.db"), pool, TableConfig{})
+	if err != nil {
+		t.Fatalf("NewSQLiteDB: %v", err)
+	}
+	defer db.Close()
+
+	if got := db.Stats().MaxOpenConnections; got != pool.MaxOpenConns {
+		t.Errorf("got max open connections %d, want %d", got, pool.MaxOpenConns)
+	}
+
+	// Con todas las conexiones abiertas y devueltas al pool solo se conserva una idle
+	ctx := context.Background()
+	conns := make([]*sql.Conn, pool.MaxOpenConns)
+	for i := range conns {
+		if conns[i], err = db.Conn(ctx); err != nil {
+			t.Fatalf("Conn: %v", err)
+		}
+	}
+	for _, conn := range conns {
+		conn.Close()
+	}
+	stats := db.Stats()
+	if stats.Idle != pool.MaxIdleConns || stats.MaxIdleClosed != int64(pool.MaxOpenConns-pool.MaxIdleConns) {
+		t.Errorf("got %d idle connections and %d closed by the idle limit, want %d and %d",
+			stats.Idle, stats.MaxIdleClosed, pool.MaxIdleConns, pool.MaxOpenConns-pool.MaxIdleConns)
+	}
+
+	// La conexión idle se descarta al superar su tiempo de vida
+	time.Sleep(2 * pool.ConnMaxLifetime)
+	if err := db.PingContext(ctx); err != nil {
+		t.Fatalf("PingContext: %v", err)
+	}
+	if db.Stats().MaxLifetimeClosed == 0 {
+		t.Error("no connection was closed after exceeding its lifetime")
+	}
+}
+
+func TestPostgresPrepareStatementsFailure(t *testing.T) {
+	db := newTestPostgres(t)
+
+	// Sin la tabla crl_info los statements que la consultan no se pueden preparar
+	if _, err := db.Exec(db.qualify("DROP TABLE crl_info")); err != nil {
+		t.Fatalf("dropping crl_info: %v", err)
+	}
+	err := db.prepareStatements()
+	if err == nil {
+		t.Fatal("prepareStatements succeeded without the crl_info table")
+	}
+	if !strings.Contains(err.Error(), "stmtInsertCRLInfo") {
+		t.Errorf("got error %q, want it to name stmtInsertCRLInfo", err)
+	}
+}
@@ -0,0 +1,1393 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+	"signerflow-crl/models"
+)
+
+// SQLiteDB implementa CertStore sobre SQLite (modernc.org/sqlite, sin cgo) para despliegues
+// de un solo nodo. El esquema es el de PostgreSQL adaptado a los tipos de SQLite; las fechas
+// se guardan como texto en UTC para que se comparen correctamente como cadenas.
+type SQLiteDB struct {
+	*sql.DB
+	// Reemplaza los nombres de las tablas por los del prefijo configurado; nil si no hay
+	tableNames *strings.Replacer
+}
+
+var _ CertStore = (*SQLiteDB)(nil)
+
+// sqliteTimeFormat es el formato con el que el driver escribe las fechas (_time_format=sqlite)
+const sqliteTimeFormat = "2006-01-02 15:04:05.999999999-07:00"
+
+// sqliteBuckets son los formatos de strftime que truncan started_at en GetThroughput
+var sqliteBuckets = map[string]string{
+	"hour": "%Y-%m-%d %H:00:00",
+	"day":  "%Y-%m-%d 00:00:00",
+}
+
+// NewSQLiteDB abre o crea la base SQLite en path. Las transacciones toman el bloqueo de
+// escritura al empezar y las escrituras concurrentes esperan hasta 5 segundos.
+func NewSQLiteDB(path string, pool PoolConfig, tableConfig TableConfig) (*SQLiteDB, error) {
+	if tableConfig.Schema != "" {
+		return nil, fmt.Errorf("SQLite does not support schemas, got %q", tableConfig.Schema)
+	}
+
+	dsn := path + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_time_format=sqlite&_txlock=immediate"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("error opening SQLite database: %v", err)
+	}
+
+	applyPoolConfig(db, pool)
+
+	if err := db.Ping(); err != nil {
+		return nil, fmt.Errorf("error pinging database: %v", err)
+	}
+
+	database := &SQLiteDB{DB: db, tableNames: newTableNames(tableConfig)}
+	if err := database.createTables(); err != nil {
+		return nil, fmt.Errorf("error creating tables: %v", err)
+	}
+
+	log.Printf("Connected to SQLite database %s", path)
+	return database, nil
+}
+
+// qualify aplica el prefijo configurado a las tablas de una consulta
+func (db *SQLiteDB) qualify(query string) string {
+	if db.tableNames == nil {
+		return query
+	}
+	return db.tableNames.Replace(query)
+}
+
+func (db *SQLiteDB) createTables() error {
+	query := sqliteRevokedCertificatesTable + `
+	CREATE TABLE IF NOT EXISTS crl_info (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		url TEXT NOT NULL UNIQUE,
+		issuer TEXT NOT NULL,
+		issuer_dn TEXT,
+		next_update TIMESTAMP,
+		last_processed TIMESTAMP,
+		cert_count INTEGER DEFAULT 0,
+		crl_number TEXT,
+		etag TEXT,
+		last_modified TEXT,
+		last_success TIMESTAMP,
+		last_error TEXT,
+		consecutive_failures INTEGER NOT NULL DEFAULT 0,
+		created_at TIMESTAMP,
+		updated_at TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS crl_sources (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		url TEXT NOT NULL UNIQUE,
+		label TEXT,
+		enabled INTEGER NOT NULL DEFAULT 1,
+		client_cert TEXT,
+		client_key TEXT,
+		ca_bundle TEXT,
+		created_at TIMESTAMP,
+		last_success TIMESTAMP,
+		last_error TEXT,
+		consecutive_failures INTEGER NOT NULL DEFAULT 0
+	);
+
+	CREATE TABLE IF NOT EXISTS ca_certificates (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		subject TEXT NOT NULL,
+		key_id TEXT NOT NULL UNIQUE,
+		not_before TIMESTAMP NOT NULL,
+		not_after TIMESTAMP NOT NULL,
+		der BLOB NOT NULL,
+		created_at TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_ca_certificates_subject ON ca_certificates(subject);
+
+	CREATE TABLE IF NOT EXISTS crl_processing_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		url TEXT NOT NULL,
+		started_at TIMESTAMP NOT NULL,
+		duration_ms INTEGER NOT NULL,
+		http_status INTEGER,
+		bytes_downloaded INTEGER NOT NULL DEFAULT 0,
+		cert_count INTEGER NOT NULL DEFAULT 0,
+		skipped_entries INTEGER NOT NULL DEFAULT 0,
+		status TEXT NOT NULL,
+		error TEXT
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_crl_processing_log_url_started ON crl_processing_log(url, started_at DESC);
+	CREATE INDEX IF NOT EXISTS idx_crl_processing_log_started ON crl_processing_log(started_at);
+	`
+
+	if _, err := db.Exec(db.qualify(query)); err != nil {
+		return err
+	}
+	if err := db.migrateAddedColumns(); err != nil {
+		return err
+	}
+	return db.migrateSerialUniqueness()
+}
+
+// addedColumns son las columnas que se agregaron a tablas existentes y que las bases
+// anteriores no tienen
+var addedColumns = []struct{ table, name, definition string }{
+	{"crl_info", "last_success", "TIMESTAMP"},
+	{"crl_info", "last_error", "TEXT"},
+	{"crl_info", "consecutive_failures", "INTEGER NOT NULL DEFAULT 0"},
+	{"crl_sources", "last_success", "TIMESTAMP"},
+	{"crl_sources", "last_error", "TEXT"},
+	{"crl_sources", "consecutive_failures", "INTEGER NOT NULL DEFAULT 0"},
+	{"crl_processing_log", "skipped_entries", "INTEGER NOT NULL DEFAULT 0"},
+	{"revoked_certificates", "invalidity_date", "TIMESTAMP"},
+}
+
+// migrateAddedColumns agrega las columnas de addedColumns que falten, ya que SQLite no admite
+// ADD COLUMN IF NOT EXISTS
+func (db *SQLiteDB) migrateAddedColumns() error {
+	existing := make(map[string]map[string]bool)
+	for _, column := range addedColumns {
+		columns, ok := existing[column.table]
+		if !ok {
+			var err error
+			if columns, err = db.tableColumns(column.table); err != nil {
+				return err
+			}
+			existing[column.table] = columns
+		}
+		if columns[column.name] {
+			continue
+		}
+
+		_, err := db.Exec(db.qualify(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", column.table, column.name, column.definition)))
+		if err != nil {
+			return fmt.Errorf("error adding %s.%s: %v", column.table, column.name, err)
+		}
+	}
+	return nil
+}
+
+// utcTime pasa a UTC una fecha opcional, como se guardan todas las fechas en SQLite
+func utcTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
+
+// tableColumns devuelve los nombres de las columnas de la tabla
+func (db *SQLiteDB) tableColumns(table string) (map[string]bool, error) {
+	rows, err := db.Query("SELECT name FROM pragma_table_info(?1)", db.qualify(table))
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s columns: %v", table, err)
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("error reading %s columns: %v", table, err)
+		}
+		columns[name] = true
+	}
+	return columns, rows.Err()
+}
+
+// sqliteRevokedCertificatesTable crea la tabla de certificados revocados y sus índices. Un mismo
+// serial puede aparecer en CRLs de CAs distintas, por lo que la unicidad es por serial y CA.
+const sqliteRevokedCertificatesTable = `
+	CREATE TABLE IF NOT EXISTS revoked_certificates (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		serial TEXT NOT NULL,
+		revocation_date TIMESTAMP NOT NULL,
+		reason INTEGER NOT NULL DEFAULT 0,
+		reason_text TEXT,
+		certificate_authority TEXT NOT NULL,
+		issuer_dn TEXT,
+		fingerprint TEXT,
+		created_at TIMESTAMP,
+		updated_at TIMESTAMP,
+		invalidity_date TIMESTAMP,
+		UNIQUE (serial, certificate_authority)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_revoked_certificates_serial ON revoked_certificates(serial);
+	CREATE INDEX IF NOT EXISTS idx_revoked_certificates_ca ON revoked_certificates(certificate_authority);
+	CREATE INDEX IF NOT EXISTS idx_revoked_certificates_revocation_date ON revoked_certificates(revocation_date);
+	CREATE INDEX IF NOT EXISTS idx_revoked_certificates_reason ON revoked_certificates(reason);
+	CREATE INDEX IF NOT EXISTS idx_revoked_certificates_fingerprint ON revoked_certificates(fingerprint);
+	CREATE INDEX IF NOT EXISTS idx_revoked_certificates_issuer_dn ON revoked_certificates(issuer_dn);
+`
+
+// migrateSerialUniqueness reconstruye la tabla de certificados revocados de las bases creadas
+// con serial UNIQUE, ya que SQLite no permite quitar una restricción con ALTER TABLE. La tabla
+// anterior se renombra, se crea la nueva con sus índices y se copian las filas.
+func (db *SQLiteDB) migrateSerialUniqueness() error {
+	var definition string
+	err := db.QueryRow(
+		"SELECT sql FROM sqlite_master WHERE type = 'table' AND name = ?1",
+		db.qualify("revoked_certificates"),
+	).Scan(&definition)
+	if err != nil {
+		return fmt.Errorf("error reading revoked_certificates definition: %v", err)
+	}
+	if !strings.Contains(definition, "serial TEXT NOT NULL UNIQUE") {
+		return nil
+	}
+
+	log.Println("Migrating revoked_certificates to unique serial and certificate authority")
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// Los índices siguen a la tabla renombrada con el mismo nombre; se eliminan para que la
+	// tabla nueva los pueda crear
+	rows, err := tx.Query(
+		"SELECT name FROM sqlite_master WHERE type = 'index' AND tbl_name = ?1 AND sql IS NOT NULL",
+		db.qualify("revoked_certificates"),
+	)
+	if err != nil {
+		return fmt.Errorf("error listing revoked_certificates indexes: %v", err)
+	}
+	var indexes []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return fmt.Errorf("error listing revoked_certificates indexes: %v", err)
+		}
+		indexes = append(indexes, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error listing revoked_certificates indexes: %v", err)
+	}
+
+	statements := []string{db.qualify("ALTER TABLE revoked_certificates RENAME TO revoked_certificates_old")}
+	for _, index := range indexes {
+		statements = append(statements, fmt.Sprintf("DROP INDEX %q", index))
+	}
+	statements = append(statements,
+		db.qualify(sqliteRevokedCertificatesTable),
+		db.qualify(`
+		INSERT INTO revoked_certificates
+		(id, serial, revocation_date, reason, reason_text, certificate_authority, issuer_dn, fingerprint, created_at, updated_at, invalidity_date)
+		SELECT id, serial, revocation_date, reason, reason_text, certificate_authority, issuer_dn, fingerprint, created_at, updated_at, invalidity_date
+		FROM revoked_certificates_old
+		`),
+		db.qualify("DROP TABLE revoked_certificates_old"),
+	)
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return fmt.Errorf("error migrating revoked_certificates: %v", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// parseSQLiteTime interpreta una fecha devuelta como texto, como las de MAX() o strftime(),
+// a las que el driver no aplica la conversión de las columnas TIMESTAMP
+func parseSQLiteTime(value string) (time.Time, error) {
+	for _, layout := range []string{sqliteTimeFormat, "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid SQLite time %q", value)
+}
+
+// isSQLiteUniqueViolation indica si err es una violación de una restricción UNIQUE
+func isSQLiteUniqueViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+}
+
+// jsonList codifica los valores (seriales o URLs) como arreglo JSON para recorrerlos con
+// json_each, ya que SQLite no tiene arreglos como parámetro
+func jsonList(values []string) string {
+	if values == nil {
+		values = []string{}
+	}
+	encoded, _ := json.Marshal(values)
+	return string(encoded)
+}
+
+// querySerials ejecuta una consulta que devuelve seriales, como un DELETE ... RETURNING serial
+func querySerials(rows *sql.Rows, err error) ([]string, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var serials []string
+	for rows.Next() {
+		var serial string
+		if err := rows.Scan(&serial); err != nil {
+			return nil, fmt.Errorf("error scanning deleted serial: %v", err)
+		}
+		serials = append(serials, serial)
+	}
+
+	return serials, rows.Err()
+}
+
+func (db *SQLiteDB) InsertRevokedCertificate(ctx context.Context, cert *models.RevokedCertificate) error {
+	if len(cert.Serial) > models.MaxSerialLength {
+		return ErrSerialTooLong
+	}
+
+	now := time.Now().UTC()
+	_, err := db.ExecContext(ctx, db.qualify(`
+		INSERT INTO revoked_certificates
+		(serial, revocation_date, reason, reason_text, certificate_authority, issuer_dn, created_at, updated_at, invalidity_date)
+		VALUES (?1, ?2, ?3, ?4, ?5, NULLIF(?6, ''), ?7, ?7, ?8)
+		ON CONFLICT (serial, certificate_authority)
+		DO UPDATE SET
+			revocation_date = excluded.revocation_date,
+			reason = excluded.reason,
+			reason_text = excluded.reason_text,
+			issuer_dn = excluded.issuer_dn,
+			updated_at = excluded.updated_at,
+			invalidity_date = excluded.invalidity_date
+	`), cert.Serial, cert.RevocationDate.UTC(), cert.Reason, cert.ReasonText, cert.CertificateAuthority, cert.IssuerDN, now, utcTime(cert.InvalidityDate))
+	return err
+}
+
+// BatchInsertRevokedCertificates inserta múltiples certificados en una sola transacción y
+// devuelve los que no existían previamente. SQLite no distingue inserciones de actualizaciones
+// en un upsert, así que cada certificado se intenta insertar y, si ya existía, se actualiza.
+func (db *SQLiteDB) BatchInsertRevokedCertificates(ctx context.Context, certs []*models.RevokedCertificate) ([]*models.RevokedCertificate, error) {
+	if len(certs) == 0 {
+		return nil, nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	insertStmt, err := tx.PrepareContext(ctx, db.qualify(`
+		INSERT INTO revoked_certificates
+		(serial, revocation_date, reason, reason_text, certificate_authority, issuer_dn, created_at, updated_at, invalidity_date)
+		VALUES (?1, ?2, ?3, ?4, ?5, NULLIF(?6, ''), ?7, ?7, ?8)
+		ON CONFLICT (serial, certificate_authority) DO NOTHING
+	`))
+	if err != nil {
+		return nil, fmt.Errorf("error preparing statement: %v", err)
+	}
+	defer insertStmt.Close()
+
+	updateStmt, err := tx.PrepareContext(ctx, db.qualify(`
+		UPDATE revoked_certificates SET
+			revocation_date = ?2,
+			reason = ?3,
+			reason_text = ?4,
+			issuer_dn = NULLIF(?6, ''),
+			updated_at = ?7,
+			invalidity_date = ?8
+		WHERE serial = ?1 AND certificate_authority = ?5
+	`))
+	if err != nil {
+		return nil, fmt.Errorf("error preparing statement: %v", err)
+	}
+	defer updateStmt.Close()
+
+	now := time.Now().UTC()
+	var inserted []*models.RevokedCertificate
+	for _, cert := range certs {
+		if cert.Serial == "" || len(cert.Serial) > models.MaxSerialLength {
+			log.Printf("Warning: skipping certificate from %s with serial of %d characters (max %d)",
+				cert.CertificateAuthority, len(cert.Serial), models.MaxSerialLength)
+			continue
+		}
+
+		args := []interface{}{cert.Serial, cert.RevocationDate.UTC(), cert.Reason, cert.ReasonText, cert.CertificateAuthority, cert.IssuerDN, now, utcTime(cert.InvalidityDate)}
+		result, err := insertStmt.ExecContext(ctx, args...)
+		if err != nil {
+			return nil, fmt.Errorf("error inserting certificate %s: %v", cert.Serial, err)
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return nil, fmt.Errorf("error inserting certificate %s: %v", cert.Serial, err)
+		}
+		if affected > 0 {
+			inserted = append(inserted, cert)
+			continue
+		}
+
+		if _, err := updateStmt.ExecContext(ctx, args...); err != nil {
+			return nil, fmt.Errorf("error updating certificate %s: %v", cert.Serial, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	return inserted, nil
+}
+
+// DeleteCertificatesNotIn elimina los certificados del emisor cuyo serial ya no figura en la CRL
+// y devuelve los seriales eliminados, con la misma identificación del emisor que DB
+func (db *SQLiteDB) DeleteCertificatesNotIn(ctx context.Context, issuerDN, certificateAuthority string, serials []string) ([]string, error) {
+	rows, err := db.QueryContext(ctx, db.qualify(`
+		DELETE FROM revoked_certificates
+		WHERE (issuer_dn = ?1 OR (issuer_dn IS NULL AND certificate_authority = ?2))
+		AND serial NOT IN (SELECT value FROM json_each(?3))
+		RETURNING serial
+	`), issuerDN, certificateAuthority, jsonList(serials))
+	deleted, err := querySerials(rows, err)
+	if err != nil {
+		return nil, fmt.Errorf("error deleting stale certificates: %v", err)
+	}
+	return deleted, nil
+}
+
+// PurgeCA elimina en una transacción todos los certificados revocados de la CA y sus filas
+// de crl_info. Devuelve los seriales eliminados y el número de filas de crl_info.
+func (db *SQLiteDB) PurgeCA(ctx context.Context, certificateAuthority string) ([]string, int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, db.qualify(`
+		DELETE FROM revoked_certificates
+		WHERE certificate_authority = ?1
+		RETURNING serial
+	`), certificateAuthority)
+	deleted, err := querySerials(rows, err)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error purging certificates: %v", err)
+	}
+
+	result, err := tx.ExecContext(ctx, db.qualify("DELETE FROM crl_info WHERE issuer = ?1"), certificateAuthority)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error purging CRL info: %v", err)
+	}
+	crlInfos, err := result.RowsAffected()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, 0, fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	return deleted, int(crlInfos), nil
+}
+
+// DeleteRevokedCertificates elimina los certificados de la CA con los seriales indicados y
+// devuelve los que efectivamente existían; los del mismo serial de otras CAs no se tocan
+func (db *SQLiteDB) DeleteRevokedCertificates(ctx context.Context, certificateAuthority string, serials []string) ([]string, error) {
+	if len(serials) == 0 {
+		return nil, nil
+	}
+
+	rows, err := db.QueryContext(ctx, db.qualify(`
+		DELETE FROM revoked_certificates
+		WHERE certificate_authority = ?1
+		AND serial IN (SELECT value FROM json_each(?2))
+		RETURNING serial
+	`), certificateAuthority, jsonList(serials))
+	deleted, err := querySerials(rows, err)
+	if err != nil {
+		return nil, fmt.Errorf("error deleting certificates: %v", err)
+	}
+	return deleted, nil
+}
+
+// DeleteUntrackedCertificates elimina los certificados no actualizados desde antes de cutoff
+// cuya CA ya no tiene ninguna CRL configurada, igual que DB
+func (db *SQLiteDB) DeleteUntrackedCertificates(ctx context.Context, cutoff time.Time, trackedURLs []string) ([]string, error) {
+	rows, err := db.QueryContext(ctx, db.qualify(`
+		DELETE FROM revoked_certificates AS r
+		WHERE r.updated_at < ?1
+		AND NOT EXISTS (
+			SELECT 1 FROM crl_info c
+			WHERE (c.issuer_dn = r.issuer_dn OR c.issuer = r.certificate_authority)
+			AND (
+				c.last_processed >= ?1
+				OR c.url IN (SELECT value FROM json_each(?2))
+				OR EXISTS (SELECT 1 FROM crl_sources s WHERE s.url = c.url)
+			)
+		)
+		RETURNING serial
+	`), cutoff.UTC(), jsonList(trackedURLs))
+	deleted, err := querySerials(rows, err)
+	if err != nil {
+		return nil, fmt.Errorf("error deleting untracked certificates: %v", err)
+	}
+	return deleted, nil
+}
+
+// GetCertificateStatus toma la revocación más reciente del serial entre todas las CAs, igual
+// que DB
+func (db *SQLiteDB) GetCertificateStatus(ctx context.Context, serial string) (*models.CertificateStatus, error) {
+	return db.getCertificateStatus(ctx, serial, "")
+}
+
+// GetCertificateStatusByCA busca el serial solo entre los certificados de la CA
+func (db *SQLiteDB) GetCertificateStatusByCA(ctx context.Context, serial, ca string) (*models.CertificateStatus, error) {
+	return db.getCertificateStatus(ctx, serial, ca)
+}
+
+func (db *SQLiteDB) getCertificateStatus(ctx context.Context, serial, ca string) (*models.CertificateStatus, error) {
+	return scanCertificateStatus(serial, db.QueryRowContext(ctx, db.qualify(`
+		SELECT serial, revocation_date, reason, COALESCE(reason_text, ''), certificate_authority, COALESCE(fingerprint, ''), invalidity_date
+		FROM revoked_certificates
+		WHERE serial = ?1 AND (?2 = '' OR certificate_authority = ?2)
+		ORDER BY revocation_date DESC, certificate_authority
+		LIMIT 1
+	`), serial, ca))
+}
+
+// GetCertificateStatusAsOf busca la revocación más reciente del serial con fecha igual o
+// anterior a asOf, en la CA indicada o, con ca vacío, en todas
+func (db *SQLiteDB) GetCertificateStatusAsOf(ctx context.Context, serial, ca string, asOf time.Time) (*models.CertificateStatus, error) {
+	return scanCertificateStatus(serial, db.QueryRowContext(ctx, db.qualify(`
+		SELECT serial, revocation_date, reason, COALESCE(reason_text, ''), certificate_authority, COALESCE(fingerprint, ''), invalidity_date
+		FROM revoked_certificates
+		WHERE serial = ?1 AND (?2 = '' OR certificate_authority = ?2) AND revocation_date <= ?3
+		ORDER BY revocation_date DESC, certificate_authority
+		LIMIT 1
+	`), serial, ca, asOf.UTC()))
+}
+
+// GetCertificateByFingerprint busca un certificado revocado por su huella SHA-256 en hexadecimal.
+// Devuelve nil si la huella no está registrada.
+func (db *SQLiteDB) GetCertificateByFingerprint(ctx context.Context, fingerprint string) (*models.CertificateStatus, error) {
+	var cert models.RevokedCertificate
+	var invalidityDate sql.NullTime
+	err := db.QueryRowContext(ctx, db.qualify(`
+		SELECT serial, revocation_date, reason, COALESCE(reason_text, ''), certificate_authority, invalidity_date
+		FROM revoked_certificates
+		WHERE fingerprint = ?1
+	`), fingerprint).Scan(
+		&cert.Serial,
+		&cert.RevocationDate,
+		&cert.Reason,
+		&cert.ReasonText,
+		&cert.CertificateAuthority,
+		&invalidityDate,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	reasonText := models.RevocationReasons[cert.Reason]
+	if cert.ReasonText != "" {
+		reasonText = cert.ReasonText
+	}
+
+	return &models.CertificateStatus{
+		Serial:               cert.Serial,
+		IsRevoked:            true,
+		Status:               models.RevokedStatus(cert.Reason),
+		RevocationDate:       &cert.RevocationDate,
+		Reason:               &reasonText,
+		ReasonCode:           &cert.Reason,
+		CertificateAuthority: &cert.CertificateAuthority,
+		Fingerprint:          &fingerprint,
+		InvalidityDate:       nullTimePtr(invalidityDate),
+	}, nil
+}
+
+// SetCertificateFingerprint registra la huella de un certificado revocado ya importado
+func (db *SQLiteDB) SetCertificateFingerprint(ctx context.Context, serial, certificateAuthority, fingerprint string) error {
+	_, err := db.ExecContext(ctx,
+		db.qualify("UPDATE revoked_certificates SET fingerprint = ?3 WHERE serial = ?1 AND certificate_authority = ?2"),
+		serial, certificateAuthority, fingerprint,
+	)
+	return err
+}
+
+// HasCRLForIssuer indica si se ha procesado alguna CRL del emisor
+func (db *SQLiteDB) HasCRLForIssuer(ctx context.Context, issuer string) (bool, error) {
+	var exists bool
+	err := db.QueryRowContext(ctx, db.qualify("SELECT EXISTS(SELECT 1 FROM crl_info WHERE issuer = ?1)"), issuer).Scan(&exists)
+	return exists, err
+}
+
+// queryCertificates ejecuta una consulta de certificados con las columnas de ListRevokedCertificates
+func (db *SQLiteDB) queryCertificates(ctx context.Context, query string, args ...interface{}) ([]*models.RevokedCertificate, error) {
+	rows, err := db.QueryContext(ctx, db.qualify(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	certs := make([]*models.RevokedCertificate, 0)
+	for rows.Next() {
+		cert, err := scanRevokedCertificate(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning certificate: %v", err)
+		}
+		certs = append(certs, cert)
+	}
+
+	return certs, rows.Err()
+}
+
+// ListRevokedCertificates devuelve una página de certificados revocados y el total que cumple
+// el filtro, con la misma paginación por offset o por cursor que DB
+func (db *SQLiteDB) ListRevokedCertificates(ctx context.Context, filter models.CertificateFilter, limit, offset int) ([]*models.RevokedCertificate, int, error) {
+	var conditions []string
+	var args []interface{}
+
+	if filter.CertificateAuthority != "" {
+		args = append(args, filter.CertificateAuthority)
+		conditions = append(conditions, fmt.Sprintf("certificate_authority = ?%d", len(args)))
+	}
+	if filter.Reason != nil {
+		args = append(args, *filter.Reason)
+		conditions = append(conditions, fmt.Sprintf("reason = ?%d", len(args)))
+	}
+	if filter.From != nil {
+		args = append(args, filter.From.UTC())
+		conditions = append(conditions, fmt.Sprintf("revocation_date >= ?%d", len(args)))
+	}
+	if filter.To != nil {
+		args = append(args, filter.To.UTC())
+		conditions = append(conditions, fmt.Sprintf("revocation_date <= ?%d", len(args)))
+	}
+
+	if filter.AfterID != nil && *filter.AfterID > 0 {
+		args = append(args, *filter.AfterID)
+		conditions = append(conditions, fmt.Sprintf("id < ?%d", len(args)))
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	var query string
+	if filter.AfterID != nil {
+		query = fmt.Sprintf(`
+			SELECT id, serial, revocation_date, reason, COALESCE(reason_text, ''), certificate_authority, COALESCE(fingerprint, ''), created_at, updated_at, invalidity_date
+			FROM revoked_certificates
+			%s
+			ORDER BY id DESC
+			LIMIT ?%d
+		`, where, len(args)+1)
+		args = append(args, limit)
+	} else {
+		err := db.QueryRowContext(ctx, db.qualify("SELECT COUNT(*) FROM revoked_certificates "+where), args...).Scan(&total)
+		if err != nil {
+			return nil, 0, fmt.Errorf("error counting certificates: %v", err)
+		}
+
+		query = fmt.Sprintf(`
+			SELECT id, serial, revocation_date, reason, COALESCE(reason_text, ''), certificate_authority, COALESCE(fingerprint, ''), created_at, updated_at, invalidity_date
+			FROM revoked_certificates
+			%s
+			ORDER BY revocation_date DESC, id DESC
+			LIMIT ?%d OFFSET ?%d
+		`, where, len(args)+1, len(args)+2)
+		args = append(args, limit, offset)
+	}
+
+	certs, err := db.queryCertificates(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error listing certificates: %v", err)
+	}
+	return certs, total, nil
+}
+
+// ListRevokedInRange devuelve hasta limit certificados revocados de la CA cuyo serial, como
+// entero, está entre from y to (ambos incluidos). SQLite no tiene enteros de precisión
+// arbitraria: los seriales decimales sin ceros a la izquierda se ordenan por largo y luego
+// como texto, lo que excluye los seriales negativos, que RFC 5280 no admite.
+func (db *SQLiteDB) ListRevokedInRange(ctx context.Context, ca, from, to string, limit int) ([]*models.RevokedCertificate, error) {
+	if strings.HasPrefix(to, "-") {
+		return make([]*models.RevokedCertificate, 0), nil
+	}
+	if strings.HasPrefix(from, "-") {
+		from = "0"
+	}
+
+	certs, err := db.queryCertificates(ctx, `
+		SELECT id, serial, revocation_date, reason, COALESCE(reason_text, ''), certificate_authority, COALESCE(fingerprint, ''), created_at, updated_at, invalidity_date
+		FROM revoked_certificates
+		WHERE certificate_authority = ?1
+		AND serial GLOB '[0-9]*' AND serial NOT GLOB '*[^0-9]*'
+		AND (length(serial), serial) >= (length(?2), ?2)
+		AND (length(serial), serial) <= (length(?3), ?3)
+		ORDER BY length(serial), serial
+		LIMIT ?4
+	`, ca, from, to, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error listing certificates in range: %v", err)
+	}
+	return certs, nil
+}
+
+// GetRecentRevoked devuelve los n certificados revocados más recientes por fecha de revocación
+func (db *SQLiteDB) GetRecentRevoked(ctx context.Context, n int) ([]*models.RevokedCertificate, error) {
+	certs, err := db.queryCertificates(ctx, `
+		SELECT id, serial, revocation_date, reason, COALESCE(reason_text, ''), certificate_authority, COALESCE(fingerprint, ''), created_at, updated_at, invalidity_date
+		FROM revoked_certificates
+		ORDER BY revocation_date DESC, id DESC
+		LIMIT ?1
+	`, n)
+	if err != nil {
+		return nil, fmt.Errorf("error querying recent revocations: %v", err)
+	}
+	return certs, nil
+}
+
+// StreamRevokedCertificates recorre todos los certificados revocados (opcionalmente de una CA)
+// llamando a fn por cada fila, sin cargar el resultado completo en memoria
+func (db *SQLiteDB) StreamRevokedCertificates(ctx context.Context, ca string, fn func(*models.RevokedCertificate) error) error {
+	rows, err := db.QueryContext(ctx, db.qualify(`
+		SELECT serial, revocation_date, reason, COALESCE(reason_text, ''), certificate_authority
+		FROM revoked_certificates
+		WHERE ?1 = '' OR certificate_authority = ?1
+		ORDER BY id
+	`), ca)
+	if err != nil {
+		return fmt.Errorf("error querying certificates for export: %v", err)
+	}
+	defer rows.Close()
+
+	var cert models.RevokedCertificate
+	for rows.Next() {
+		err := rows.Scan(
+			&cert.Serial,
+			&cert.RevocationDate,
+			&cert.Reason,
+			&cert.ReasonText,
+			&cert.CertificateAuthority,
+		)
+		if err != nil {
+			return fmt.Errorf("error scanning certificate for export: %v", err)
+		}
+		if err := fn(&cert); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (db *SQLiteDB) InsertCRLInfo(ctx context.Context, crlInfo *models.CRLInfo) error {
+	now := time.Now().UTC()
+	_, err := db.ExecContext(ctx, db.qualify(`
+		INSERT INTO crl_info
+		(url, issuer, issuer_dn, next_update, last_processed, cert_count, crl_number, created_at, updated_at)
+		VALUES (?1, ?2, NULLIF(?3, ''), ?4, ?5, ?6, NULLIF(?7, ''), ?8, ?8)
+		ON CONFLICT (url)
+		DO UPDATE SET
+			issuer = excluded.issuer,
+			issuer_dn = excluded.issuer_dn,
+			next_update = excluded.next_update,
+			last_processed = excluded.last_processed,
+			cert_count = excluded.cert_count,
+			crl_number = excluded.crl_number,
+			updated_at = excluded.updated_at
+	`),
+		crlInfo.URL,
+		crlInfo.Issuer,
+		crlInfo.IssuerDN,
+		crlInfo.NextUpdate.UTC(),
+		crlInfo.LastProcessed.UTC(),
+		crlInfo.CertCount,
+		crlInfo.CRLNumber,
+		now,
+	)
+	return err
+}
+
+// GetCRLNumber devuelve el último CRLNumber registrado para la URL, o "" si no existe
+func (db *SQLiteDB) GetCRLNumber(ctx context.Context, url string) (string, error) {
+	var number sql.NullString
+	err := db.QueryRowContext(ctx, db.qualify("SELECT crl_number FROM crl_info WHERE url = ?1"), url).Scan(&number)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return number.String, nil
+}
+
+// GetCRLValidators devuelve el ETag y Last-Modified guardados para la URL
+func (db *SQLiteDB) GetCRLValidators(ctx context.Context, url string) (*models.CRLValidators, error) {
+	var validators models.CRLValidators
+	err := db.QueryRowContext(ctx,
+		db.qualify("SELECT COALESCE(etag, ''), COALESCE(last_modified, '') FROM crl_info WHERE url = ?1"),
+		url,
+	).Scan(&validators.ETag, &validators.LastModified)
+	if err == sql.ErrNoRows {
+		return &validators, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &validators, nil
+}
+
+// UpdateCRLValidators guarda los validadores HTTP de la última descarga completa
+func (db *SQLiteDB) UpdateCRLValidators(ctx context.Context, url string, validators *models.CRLValidators) error {
+	_, err := db.ExecContext(ctx,
+		db.qualify("UPDATE crl_info SET etag = NULLIF(?2, ''), last_modified = NULLIF(?3, ''), updated_at = ?4 WHERE url = ?1"),
+		url, validators.ETag, validators.LastModified, time.Now().UTC(),
+	)
+	return err
+}
+
+// TouchCRLInfo actualiza last_processed cuando la CRL no cambió desde la última descarga
+func (db *SQLiteDB) TouchCRLInfo(ctx context.Context, url string) error {
+	_, err := db.ExecContext(ctx,
+		db.qualify("UPDATE crl_info SET last_processed = ?2, updated_at = ?2 WHERE url = ?1"),
+		url, time.Now().UTC(),
+	)
+	return err
+}
+
+// RecordCRLAttempt registra el resultado de un intento de procesar la CRL en su fila de crl_info
+// y en su fuente de crl_sources, las que existan. Un éxito (attemptErr nil) guarda last_success
+// y reinicia consecutive_failures; un fallo lo incrementa y guarda el error en last_error.
+// Devuelve los fallos consecutivos, el mayor de ambas filas.
+func (db *SQLiteDB) RecordCRLAttempt(ctx context.Context, url string, attemptErr error) (int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	failures := 0
+	for _, table := range []string{"crl_info", "crl_sources"} {
+		if attemptErr == nil {
+			_, err := tx.ExecContext(ctx, db.qualify(`
+				UPDATE `+table+` SET last_success = ?2, last_error = NULL, consecutive_failures = 0
+				WHERE url = ?1
+			`), url, time.Now().UTC())
+			if err != nil {
+				return 0, fmt.Errorf("error recording CRL attempt: %v", err)
+			}
+			continue
+		}
+
+		var count int
+		err := tx.QueryRowContext(ctx, db.qualify(`
+			UPDATE `+table+` SET last_error = ?2, consecutive_failures = consecutive_failures + 1
+			WHERE url = ?1
+			RETURNING consecutive_failures
+		`), url, attemptErr.Error()).Scan(&count)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("error recording CRL attempt: %v", err)
+		}
+		failures = max(failures, count)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error committing CRL attempt: %v", err)
+	}
+	return failures, nil
+}
+
+// ListCRLInfo devuelve la información registrada de cada CRL procesada
+func (db *SQLiteDB) ListCRLInfo(ctx context.Context) ([]*models.CRLInfo, error) {
+	rows, err := db.QueryContext(ctx, db.qualify(`
+		SELECT url, issuer, COALESCE(issuer_dn, ''), next_update, last_processed, cert_count, COALESCE(crl_number, ''),
+			last_success, COALESCE(last_error, ''), consecutive_failures
+		FROM crl_info
+		ORDER BY issuer, url
+	`))
+	if err != nil {
+		return nil, fmt.Errorf("error listing CRL info: %v", err)
+	}
+	defer rows.Close()
+
+	infos := make([]*models.CRLInfo, 0)
+	for rows.Next() {
+		var info models.CRLInfo
+		var nextUpdate, lastSuccess sql.NullTime
+		err := rows.Scan(
+			&info.URL,
+			&info.Issuer,
+			&info.IssuerDN,
+			&nextUpdate,
+			&info.LastProcessed,
+			&info.CertCount,
+			&info.CRLNumber,
+			&lastSuccess,
+			&info.LastError,
+			&info.ConsecutiveFailures,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning CRL info: %v", err)
+		}
+		info.NextUpdate = nextUpdate.Time
+		info.LastSuccess = nullTimePtr(lastSuccess)
+		infos = append(infos, &info)
+	}
+
+	return infos, rows.Err()
+}
+
+// GetCRLInfo devuelve los metadatos de la CRL; devuelve sql.ErrNoRows si la URL no existe
+func (db *SQLiteDB) GetCRLInfo(ctx context.Context, url string) (*models.CRLInfo, error) {
+	var info models.CRLInfo
+	var nextUpdate, lastSuccess sql.NullTime
+	err := db.QueryRowContext(ctx, db.qualify(`
+		SELECT url, issuer, COALESCE(issuer_dn, ''), next_update, last_processed, cert_count, COALESCE(crl_number, ''),
+			last_success, COALESCE(last_error, ''), consecutive_failures
+		FROM crl_info
+		WHERE url = ?1
+	`), url).Scan(
+		&info.URL,
+		&info.Issuer,
+		&info.IssuerDN,
+		&nextUpdate,
+		&info.LastProcessed,
+		&info.CertCount,
+		&info.CRLNumber,
+		&lastSuccess,
+		&info.LastError,
+		&info.ConsecutiveFailures,
+	)
+	if err != nil {
+		return nil, err
+	}
+	info.NextUpdate = nextUpdate.Time
+	info.LastSuccess = nullTimePtr(lastSuccess)
+
+	return &info, nil
+}
+
+func (db *SQLiteDB) GetCRLStats(ctx context.Context) (map[string]interface{}, error) {
+	var totalCerts int
+	var totalCRLs int
+
+	err := db.QueryRowContext(ctx, db.qualify("SELECT COUNT(*) FROM revoked_certificates")).Scan(&totalCerts)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.QueryRowContext(ctx, db.qualify("SELECT COUNT(*) FROM crl_info")).Scan(&totalCRLs)
+	if err != nil {
+		return nil, err
+	}
+
+	lastUpdate, err := db.GetLastProcessed(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"total_revoked_certificates": totalCerts,
+		"total_crls_processed":       totalCRLs,
+		"last_update":                lastUpdate,
+	}, nil
+}
+
+// GetLastProcessed devuelve la fecha del último procesamiento exitoso de cualquier CRL
+func (db *SQLiteDB) GetLastProcessed(ctx context.Context) (time.Time, error) {
+	var lastUpdate string
+	err := db.QueryRowContext(ctx, db.qualify("SELECT COALESCE(MAX(last_processed), '1970-01-01') FROM crl_info")).Scan(&lastUpdate)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return parseSQLiteTime(lastUpdate)
+}
+
+func (db *SQLiteDB) GetReasonBreakdown(ctx context.Context, ca string) (map[int]models.ReasonCount, error) {
+	rows, err := db.QueryContext(ctx, db.qualify(`
+		SELECT reason, COUNT(*)
+		FROM revoked_certificates
+		WHERE ?1 = '' OR certificate_authority = ?1
+		GROUP BY reason
+	`), ca)
+	if err != nil {
+		return nil, fmt.Errorf("error querying reason breakdown: %v", err)
+	}
+	defer rows.Close()
+
+	breakdown := make(map[int]models.ReasonCount)
+	for rows.Next() {
+		var reason, count int
+		if err := rows.Scan(&reason, &count); err != nil {
+			return nil, fmt.Errorf("error scanning reason breakdown: %v", err)
+		}
+		breakdown[reason] = models.ReasonCount{
+			ReasonText: models.RevocationReasons[reason],
+			Count:      count,
+		}
+	}
+
+	return breakdown, rows.Err()
+}
+
+// GetStatsByCA devuelve el total de revocados por CA junto con su CRL procesada más
+// recientemente, agrupando igual que DB; SQLite no tiene LATERAL y la CRL se elige con una
+// subconsulta correlacionada
+func (db *SQLiteDB) GetStatsByCA(ctx context.Context, ca string) ([]*models.CAStats, error) {
+	rows, err := db.QueryContext(ctx, db.qualify(`
+		WITH r AS (
+			SELECT MAX(certificate_authority) AS certificate_authority, issuer_dn, COUNT(*) AS revoked_count
+			FROM revoked_certificates
+			WHERE ?1 = '' OR certificate_authority = ?1 OR issuer_dn = ?1
+			GROUP BY issuer_dn, CASE WHEN issuer_dn IS NULL THEN certificate_authority END
+		)
+		SELECT r.certificate_authority, r.issuer_dn, r.revoked_count, c.url, c.next_update, c.last_processed
+		FROM r
+		LEFT JOIN crl_info c ON c.id = (
+			SELECT id
+			FROM crl_info
+			WHERE CASE WHEN r.issuer_dn IS NULL THEN issuer = r.certificate_authority ELSE issuer_dn = r.issuer_dn END
+			ORDER BY last_processed DESC
+			LIMIT 1
+		)
+		ORDER BY r.revoked_count DESC, r.certificate_authority
+	`), ca)
+	if err != nil {
+		return nil, fmt.Errorf("error getting stats by CA: %v", err)
+	}
+	defer rows.Close()
+
+	stats := make([]*models.CAStats, 0)
+	for rows.Next() {
+		var stat models.CAStats
+		var issuerDN, url sql.NullString
+		var nextUpdate, lastProcessed sql.NullTime
+		err := rows.Scan(
+			&stat.CertificateAuthority,
+			&issuerDN,
+			&stat.RevokedCount,
+			&url,
+			&nextUpdate,
+			&lastProcessed,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning CA stats: %v", err)
+		}
+		stat.IssuerDN = issuerDN.String
+		if url.Valid {
+			stat.CRLURL = &url.String
+		}
+		if nextUpdate.Valid {
+			stat.NextUpdate = &nextUpdate.Time
+			stat.IsStale = nextUpdate.Time.Before(time.Now())
+		}
+		if lastProcessed.Valid {
+			stat.LastProcessed = &lastProcessed.Time
+		}
+		stats = append(stats, &stat)
+	}
+
+	return stats, rows.Err()
+}
+
+// querySources ejecuta una consulta de fuentes con las columnas de ListCRLSources
+func (db *SQLiteDB) querySources(ctx context.Context, query string, args ...interface{}) ([]*models.CRLSource, error) {
+	rows, err := db.QueryContext(ctx, db.qualify(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sources := make([]*models.CRLSource, 0)
+	for rows.Next() {
+		source, err := scanCRLSource(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning CRL source: %v", err)
+		}
+		sources = append(sources, source)
+	}
+
+	return sources, rows.Err()
+}
+
+// getCRLSource devuelve la única fuente de la consulta o sql.ErrNoRows si no hay ninguna
+func (db *SQLiteDB) getCRLSource(ctx context.Context, where string, arg interface{}) (*models.CRLSource, error) {
+	sources, err := db.querySources(ctx, `
+		SELECT id, url, COALESCE(label, ''), enabled, COALESCE(client_cert, ''), COALESCE(client_key, ''), COALESCE(ca_bundle, ''), created_at,
+			last_success, COALESCE(last_error, ''), consecutive_failures
+		FROM crl_sources
+		WHERE `+where, arg)
+	if err != nil {
+		return nil, err
+	}
+	if len(sources) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return sources[0], nil
+}
+
+func (db *SQLiteDB) ListCRLSources(ctx context.Context) ([]*models.CRLSource, error) {
+	sources, err := db.querySources(ctx, `
+		SELECT id, url, COALESCE(label, ''), enabled, COALESCE(client_cert, ''), COALESCE(client_key, ''), COALESCE(ca_bundle, ''), created_at,
+			last_success, COALESCE(last_error, ''), consecutive_failures
+		FROM crl_sources
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("error listing CRL sources: %v", err)
+	}
+	return sources, nil
+}
+
+// GetCRLSourceByURL devuelve la fuente registrada para la URL; sql.ErrNoRows si no existe
+func (db *SQLiteDB) GetCRLSourceByURL(ctx context.Context, url string) (*models.CRLSource, error) {
+	return db.getCRLSource(ctx, "url = ?1", url)
+}
+
+// AddCRLSource registra una nueva fuente y completa su ID y fecha de creación
+func (db *SQLiteDB) AddCRLSource(ctx context.Context, source *models.CRLSource) error {
+	now := time.Now().UTC()
+	result, err := db.ExecContext(ctx, db.qualify(`
+		INSERT INTO crl_sources (url, label, enabled, client_cert, client_key, ca_bundle, created_at)
+		VALUES (?1, NULLIF(?2, ''), ?3, NULLIF(?4, ''), NULLIF(?5, ''), NULLIF(?6, ''), ?7)
+	`), source.URL, source.Label, source.Enabled, source.ClientCert, source.ClientKey, source.CABundle, now)
+	if isSQLiteUniqueViolation(err) {
+		return ErrDuplicateSource
+	}
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	source.ID = int(id)
+	source.CreatedAt = now
+	return nil
+}
+
+// SetCRLSourceEnabled habilita o deshabilita la fuente y la devuelve actualizada;
+// sql.ErrNoRows si no existe
+func (db *SQLiteDB) SetCRLSourceEnabled(ctx context.Context, id int, enabled bool) (*models.CRLSource, error) {
+	result, err := db.ExecContext(ctx, db.qualify("UPDATE crl_sources SET enabled = ?2 WHERE id = ?1"), id, enabled)
+	if err != nil {
+		return nil, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if affected == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return db.getCRLSource(ctx, "id = ?1", id)
+}
+
+// DeleteCRLSource elimina la fuente; devuelve sql.ErrNoRows si no existe
+func (db *SQLiteDB) DeleteCRLSource(ctx context.Context, id int) error {
+	return db.deleteByID(ctx, "crl_sources", id, "error deleting CRL source")
+}
+
+// deleteByID elimina la fila id de table; devuelve sql.ErrNoRows si no existe
+func (db *SQLiteDB) deleteByID(ctx context.Context, table string, id int, errPrefix string) error {
+	result, err := db.ExecContext(ctx, db.qualify("DELETE FROM "+table+" WHERE id = ?1"), id)
+	if err != nil {
+		return fmt.Errorf("%s: %v", errPrefix, err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// InsertProcessingLog registra un intento de procesamiento de CRL
+func (db *SQLiteDB) InsertProcessingLog(ctx context.Context, entry *models.ProcessingLogEntry) error {
+	_, err := db.ExecContext(ctx, db.qualify(`
+		INSERT INTO crl_processing_log (url, started_at, duration_ms, http_status, bytes_downloaded, cert_count, skipped_entries, status, error)
+		VALUES (?1, ?2, ?3, NULLIF(?4, 0), ?5, ?6, ?7, ?8, NULLIF(?9, ''))
+	`), entry.URL, entry.StartedAt.UTC(), entry.DurationMs, entry.HTTPStatus, entry.BytesDownloaded, entry.CertCount, entry.SkippedEntries, entry.Status, entry.Error)
+	if err != nil {
+		return fmt.Errorf("error inserting processing log: %v", err)
+	}
+	return nil
+}
+
+// GetProcessingHistory devuelve los intentos más recientes primero, opcionalmente de una sola URL
+func (db *SQLiteDB) GetProcessingHistory(ctx context.Context, url string, limit int) ([]*models.ProcessingLogEntry, error) {
+	rows, err := db.QueryContext(ctx, db.qualify(`
+		SELECT id, url, started_at, duration_ms, COALESCE(http_status, 0), bytes_downloaded, cert_count, skipped_entries, status, COALESCE(error, '')
+		FROM crl_processing_log
+		WHERE ?1 = '' OR url = ?1
+		ORDER BY started_at DESC, id DESC
+		LIMIT ?2
+	`), url, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error querying processing history: %v", err)
+	}
+	defer rows.Close()
+
+	entries := make([]*models.ProcessingLogEntry, 0)
+	for rows.Next() {
+		var entry models.ProcessingLogEntry
+		err := rows.Scan(
+			&entry.ID,
+			&entry.URL,
+			&entry.StartedAt,
+			&entry.DurationMs,
+			&entry.HTTPStatus,
+			&entry.BytesDownloaded,
+			&entry.CertCount,
+			&entry.SkippedEntries,
+			&entry.Status,
+			&entry.Error,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning processing log: %v", err)
+		}
+		entries = append(entries, &entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// GetThroughput agrupa el historial de procesamiento desde since en intervalos de bucket
+// (hour o day), igual que DB; los intervalos se calculan en UTC
+func (db *SQLiteDB) GetThroughput(ctx context.Context, since time.Time, bucket string) ([]*models.ThroughputBucket, error) {
+	format, ok := sqliteBuckets[bucket]
+	if !ok {
+		return nil, fmt.Errorf("unsupported throughput bucket %q", bucket)
+	}
+
+	rows, err := db.QueryContext(ctx, db.qualify(`
+		SELECT
+			strftime(?1, started_at) AS bucket_start,
+			COUNT(*),
+			COUNT(*) FILTER (WHERE status = ?3),
+			COALESCE(SUM(cert_count) FILTER (WHERE status = ?4), 0),
+			COALESCE(SUM(duration_ms), 0)
+		FROM crl_processing_log
+		WHERE started_at >= ?2
+		GROUP BY bucket_start
+		ORDER BY bucket_start
+	`), format, since.UTC(), models.ProcessingStatusFailed, models.ProcessingStatusSuccess)
+	if err != nil {
+		return nil, fmt.Errorf("error querying throughput: %v", err)
+	}
+	defer rows.Close()
+
+	buckets := make([]*models.ThroughputBucket, 0)
+	for rows.Next() {
+		var b models.ThroughputBucket
+		var bucketStart string
+		if err := rows.Scan(&bucketStart, &b.Runs, &b.FailedRuns, &b.CertsImported, &b.DurationMs); err != nil {
+			return nil, fmt.Errorf("error scanning throughput: %v", err)
+		}
+		if b.BucketStart, err = parseSQLiteTime(bucketStart); err != nil {
+			return nil, fmt.Errorf("error scanning throughput: %v", err)
+		}
+		buckets = append(buckets, &b)
+	}
+
+	return buckets, rows.Err()
+}
+
+// PruneProcessingLog elimina las entradas del historial anteriores a cutoff y devuelve cuántas borró
+func (db *SQLiteDB) PruneProcessingLog(ctx context.Context, cutoff time.Time) (int, error) {
+	result, err := db.ExecContext(ctx, db.qualify("DELETE FROM crl_processing_log WHERE started_at < ?1"), cutoff.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("error pruning processing log: %v", err)
+	}
+
+	affected, err := result.RowsAffected()
+	return int(affected), err
+}
+
+// AddCACertificate registra el certificado de CA y completa su ID y fecha de creación
+func (db *SQLiteDB) AddCACertificate(ctx context.Context, ca *models.CACertificate) error {
+	now := time.Now().UTC()
+	result, err := db.ExecContext(ctx, db.qualify(`
+		INSERT INTO ca_certificates (subject, key_id, not_before, not_after, der, created_at)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6)
+	`), ca.Subject, ca.KeyID, ca.NotBefore.UTC(), ca.NotAfter.UTC(), ca.DER, now)
+	if isSQLiteUniqueViolation(err) {
+		return ErrDuplicateCA
+	}
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	ca.ID = int(id)
+	ca.CreatedAt = now
+	return nil
+}
+
+func (db *SQLiteDB) ListCACertificates(ctx context.Context) ([]*models.CACertificate, error) {
+	return db.queryCACertificates(ctx, `
+		SELECT id, subject, key_id, not_before, not_after, der, created_at
+		FROM ca_certificates
+		ORDER BY id
+	`)
+}
+
+// GetCACertificatesBySubject devuelve los certificados de CA registrados con ese subject
+func (db *SQLiteDB) GetCACertificatesBySubject(ctx context.Context, subject string) ([]*models.CACertificate, error) {
+	return db.queryCACertificates(ctx, `
+		SELECT id, subject, key_id, not_before, not_after, der, created_at
+		FROM ca_certificates
+		WHERE subject = ?1
+		ORDER BY id
+	`, subject)
+}
+
+func (db *SQLiteDB) queryCACertificates(ctx context.Context, query string, args ...interface{}) ([]*models.CACertificate, error) {
+	rows, err := db.QueryContext(ctx, db.qualify(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying CA certificates: %v", err)
+	}
+	defer rows.Close()
+
+	cas := make([]*models.CACertificate, 0)
+	for rows.Next() {
+		var ca models.CACertificate
+		err := rows.Scan(&ca.ID, &ca.Subject, &ca.KeyID, &ca.NotBefore, &ca.NotAfter, &ca.DER, &ca.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning CA certificate: %v", err)
+		}
+		cas = append(cas, &ca)
+	}
+
+	return cas, rows.Err()
+}
+
+// DeleteCACertificate elimina el certificado de CA; devuelve sql.ErrNoRows si no existe
+func (db *SQLiteDB) DeleteCACertificate(ctx context.Context, id int) error {
+	return db.deleteByID(ctx, "ca_certificates", id, "error deleting CA certificate")
+}
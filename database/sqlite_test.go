@@ -0,0 +1,158 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"signerflow-crl/models"
+)
+
+// openSQLite abre la base SQLite de path y la cierra al terminar la prueba
+func openSQLite(t *testing.T, path string) *SQLiteDB {
+	t.Helper()
+
+	db, err := NewSQLiteDB(path, PoolConfig{MaxOpenConns: 1, MaxIdleConns: 1}, TableConfig{})
+	if err != nil {
+		t.Fatalf("NewSQLiteDB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestSQLiteInsertLookupAndStats(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "crl.db")
+	db := openSQLite(t, path)
+	revokedAt := time.Now().UTC().Truncate(time.Second).Add(-time.Hour)
+
+	inserted, err := db.BatchInsertRevokedCertificates(ctx, []*models.RevokedCertificate{
+		{Serial: "1001", RevocationDate: revokedAt, Reason: models.ReasonKeyCompromise, CertificateAuthority: "SQLite CA"},
+		{Serial: "1002", RevocationDate: revokedAt, Reason: models.ReasonSuperseded, CertificateAuthority: "SQLite CA"},
+		{Serial: "1001", RevocationDate: revokedAt, Reason: models.ReasonCACompromise, CertificateAuthority: "Other CA"},
+	})
+	if err != nil {
+		t.Fatalf("BatchInsertRevokedCertificates: %v", err)
+	}
+	if len(inserted) != 3 {
+		t.Errorf("got %d new certificates, want 3", len(inserted))
+	}
+
+	// El upsert actualiza el certificado existente y solo informa el nuevo
+	inserted, err = db.BatchInsertRevokedCertificates(ctx, []*models.RevokedCertificate{
+		{Serial: "1002", RevocationDate: revokedAt, Reason: models.ReasonCessationOfOperation, CertificateAuthority: "SQLite CA"},
+		{Serial: "1003", RevocationDate: revokedAt, Reason: models.ReasonUnspecified, CertificateAuthority: "SQLite CA"},
+	})
+	if err != nil {
+		t.Fatalf("BatchInsertRevokedCertificates: %v", err)
+	}
+	if len(inserted) != 1 || inserted[0].Serial != "1003" {
+		t.Errorf("got new certificates %+v, want only 1003", inserted)
+	}
+
+	status, err := db.GetCertificateStatusByCA(ctx, "1002", "SQLite CA")
+	if err != nil {
+		t.Fatalf("GetCertificateStatusByCA: %v", err)
+	}
+	if !status.IsRevoked || status.ReasonCode == nil || *status.ReasonCode != models.ReasonCessationOfOperation {
+		t.Errorf("got status %+v, want 1002 revoked with the updated reason", status)
+	}
+	if status.RevocationDate == nil || !status.RevocationDate.Equal(revokedAt) {
+		t.Errorf("got revocation date %v, want %v", status.RevocationDate, revokedAt)
+	}
+	if status, err := db.GetCertificateStatus(ctx, "9999"); err != nil || status.IsRevoked {
+		t.Errorf("unknown serial: got %+v, %v; want not revoked", status, err)
+	}
+
+	lastProcessed := revokedAt.Add(30 * time.Minute)
+	for _, info := range []*models.CRLInfo{
+		{URL: "http://crl.example/sqlite.crl", Issuer: "SQLite CA", LastProcessed: revokedAt},
+		{URL: "http://crl.example/other.crl", Issuer: "Other CA", LastProcessed: lastProcessed},
+	} {
+		info.NextUpdate = revokedAt.Add(24 * time.Hour)
+		if err := db.InsertCRLInfo(ctx, info); err != nil {
+			t.Fatalf("InsertCRLInfo: %v", err)
+		}
+	}
+
+	stats, err := db.GetCRLStats(ctx)
+	if err != nil {
+		t.Fatalf("GetCRLStats: %v", err)
+	}
+	if stats["total_revoked_certificates"] != 4 || stats["total_crls_processed"] != 2 {
+		t.Errorf("got stats %v, want 4 certificates and 2 CRLs", stats)
+	}
+	if last, _ := stats["last_update"].(time.Time); !last.Equal(lastProcessed) {
+		t.Errorf("got last update %v, want %v", stats["last_update"], lastProcessed)
+	}
+
+	// Los datos quedan en el archivo al reabrir la base
+	db.Close()
+	reopened := openSQLite(t, path)
+	if status, err := reopened.GetCertificateStatusByCA(ctx, "1001", "Other CA"); err != nil || !status.IsRevoked {
+		t.Errorf("after reopening: got %+v, %v; want 1001 revoked", status, err)
+	}
+}
+
+func TestSQLiteMigratesUniqueSerial(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "crl.db")
+	revokedAt := time.Now().UTC().Truncate(time.Second).Add(-time.Hour)
+
+	// Base creada cuando serial era UNIQUE, antes de invalidity_date
+	legacy, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("opening legacy database: %v", err)
+	}
+	for _, statement := range []string{
+		`CREATE TABLE revoked_certificates (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			serial TEXT NOT NULL UNIQUE,
+			revocation_date TIMESTAMP NOT NULL,
+			reason INTEGER NOT NULL DEFAULT 0,
+			reason_text TEXT,
+			certificate_authority TEXT NOT NULL,
+			issuer_dn TEXT,
+			fingerprint TEXT,
+			created_at TIMESTAMP,
+			updated_at TIMESTAMP
+		)`,
+		"CREATE INDEX idx_revoked_certificates_ca ON revoked_certificates(certificate_authority)",
+	} {
+		if _, err := legacy.Exec(statement); err != nil {
+			t.Fatalf("creating legacy table: %v", err)
+		}
+	}
+	if _, err := legacy.Exec(
+		"INSERT INTO revoked_certificates (serial, revocation_date, reason, certificate_authority, created_at, updated_at) VALUES (?1, ?2, ?3, ?4, ?2, ?2)",
+		"3030", revokedAt.Format(sqliteTimeFormat), models.ReasonKeyCompromise, "Legacy CA",
+	); err != nil {
+		t.Fatalf("inserting legacy row: %v", err)
+	}
+	legacy.Close()
+
+	db := openSQLite(t, path)
+	if status, err := db.GetCertificateStatusByCA(ctx, "3030", "Legacy CA"); err != nil || !status.IsRevoked {
+		t.Fatalf("migrated row: got %+v, %v; want 3030 revoked", status, err)
+	}
+
+	// Tras la migración otra CA puede revocar el mismo serial
+	inserted, err := db.BatchInsertRevokedCertificates(ctx, []*models.RevokedCertificate{
+		{Serial: "3030", RevocationDate: revokedAt, Reason: models.ReasonSuperseded, CertificateAuthority: "Other CA"},
+	})
+	if err != nil || len(inserted) != 1 {
+		t.Fatalf("inserting the serial under a second CA: got %d new certificates, %v", len(inserted), err)
+	}
+	if status, err := db.GetCertificateStatusByCA(ctx, "3030", "Legacy CA"); err != nil || *status.ReasonCode != models.ReasonKeyCompromise {
+		t.Errorf("Legacy CA revocation after the second insert: got %+v, %v", status, err)
+	}
+
+	// Reabrir la base migrada no vuelve a migrarla ni pierde filas
+	db.Close()
+	reopened := openSQLite(t, path)
+	if _, total, err := reopened.ListRevokedCertificates(ctx, models.CertificateFilter{}, 10, 0); err != nil || total != 2 {
+		t.Errorf("after reopening: got %d revoked certificates (err %v), want 2", total, err)
+	}
+}
@@ -0,0 +1,75 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"signerflow-crl/models"
+)
+
+// CertStore es el almacenamiento de certificados revocados, CRLs y configuración que usan el
+// servicio y los handlers. Las implementaciones devuelven sql.ErrNoRows cuando el registro
+// buscado no existe y los errores ErrSerialTooLong, ErrDuplicateSource y ErrDuplicateCA en
+// los mismos casos que DB.
+type CertStore interface {
+	// Certificados revocados, únicos por serial y CA. GetCertificateStatus devuelve la
+	// revocación más reciente del serial entre todas las CAs y GetCertificateStatusByCA solo
+	// la de la CA indicada. GetCertificateStatusAsOf devuelve la más reciente con
+	// revocation_date igual o anterior a asOf, de la CA indicada o, con ca vacío, de todas
+	InsertRevokedCertificate(ctx context.Context, cert *models.RevokedCertificate) error
+	BatchInsertRevokedCertificates(ctx context.Context, certs []*models.RevokedCertificate) ([]*models.RevokedCertificate, error)
+	DeleteCertificatesNotIn(ctx context.Context, issuerDN, certificateAuthority string, serials []string) ([]string, error)
+	DeleteRevokedCertificates(ctx context.Context, certificateAuthority string, serials []string) ([]string, error)
+	DeleteUntrackedCertificates(ctx context.Context, cutoff time.Time, trackedURLs []string) ([]string, error)
+	PurgeCA(ctx context.Context, certificateAuthority string) ([]string, int, error)
+	GetCertificateStatus(ctx context.Context, serial string) (*models.CertificateStatus, error)
+	GetCertificateStatusByCA(ctx context.Context, serial, ca string) (*models.CertificateStatus, error)
+	GetCertificateStatusAsOf(ctx context.Context, serial, ca string, asOf time.Time) (*models.CertificateStatus, error)
+	GetCertificateByFingerprint(ctx context.Context, fingerprint string) (*models.CertificateStatus, error)
+	SetCertificateFingerprint(ctx context.Context, serial, certificateAuthority, fingerprint string) error
+	ListRevokedCertificates(ctx context.Context, filter models.CertificateFilter, limit, offset int) ([]*models.RevokedCertificate, int, error)
+	ListRevokedInRange(ctx context.Context, ca, from, to string, limit int) ([]*models.RevokedCertificate, error)
+	GetRecentRevoked(ctx context.Context, n int) ([]*models.RevokedCertificate, error)
+	StreamRevokedCertificates(ctx context.Context, ca string, fn func(*models.RevokedCertificate) error) error
+
+	// Información de las CRLs procesadas
+	InsertCRLInfo(ctx context.Context, crlInfo *models.CRLInfo) error
+	GetCRLNumber(ctx context.Context, url string) (string, error)
+	GetCRLValidators(ctx context.Context, url string) (*models.CRLValidators, error)
+	UpdateCRLValidators(ctx context.Context, url string, validators *models.CRLValidators) error
+	TouchCRLInfo(ctx context.Context, url string) error
+	RecordCRLAttempt(ctx context.Context, url string, attemptErr error) (int, error)
+	ListCRLInfo(ctx context.Context) ([]*models.CRLInfo, error)
+	GetCRLInfo(ctx context.Context, url string) (*models.CRLInfo, error)
+	HasCRLForIssuer(ctx context.Context, issuer string) (bool, error)
+
+	// Estadísticas
+	GetCRLStats(ctx context.Context) (map[string]interface{}, error)
+	GetLastProcessed(ctx context.Context) (time.Time, error)
+	GetReasonBreakdown(ctx context.Context, ca string) (map[int]models.ReasonCount, error)
+	GetStatsByCA(ctx context.Context, ca string) ([]*models.CAStats, error)
+
+	// Fuentes de CRL
+	ListCRLSources(ctx context.Context) ([]*models.CRLSource, error)
+	GetCRLSourceByURL(ctx context.Context, url string) (*models.CRLSource, error)
+	AddCRLSource(ctx context.Context, source *models.CRLSource) error
+	SetCRLSourceEnabled(ctx context.Context, id int, enabled bool) (*models.CRLSource, error)
+	DeleteCRLSource(ctx context.Context, id int) error
+
+	// Historial de procesamiento
+	InsertProcessingLog(ctx context.Context, entry *models.ProcessingLogEntry) error
+	GetProcessingHistory(ctx context.Context, url string, limit int) ([]*models.ProcessingLogEntry, error)
+	GetThroughput(ctx context.Context, since time.Time, bucket string) ([]*models.ThroughputBucket, error)
+	PruneProcessingLog(ctx context.Context, cutoff time.Time) (int, error)
+
+	// Certificados de CA para verificar firmas de CRLs
+	AddCACertificate(ctx context.Context, ca *models.CACertificate) error
+	ListCACertificates(ctx context.Context) ([]*models.CACertificate, error)
+	GetCACertificatesBySubject(ctx context.Context, subject string) ([]*models.CACertificate, error)
+	DeleteCACertificate(ctx context.Context, id int) error
+
+	PingContext(ctx context.Context) error
+	Close() error
+}
+
+var _ CertStore = (*DB)(nil)
@@ -0,0 +1,1017 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"signerflow-crl/models"
+)
+
+func TestGetCertificateStatusAsOfAcrossCAs(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store CertStore) {
+		ctx := context.Background()
+		early := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+		late := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+
+		// El mismo serial revocado por dos CAs en fechas distintas
+		_, err := store.BatchInsertRevokedCertificates(ctx, []*models.RevokedCertificate{
+			{Serial: "4660", RevocationDate: early, Reason: models.ReasonKeyCompromise, CertificateAuthority: "CA One"},
+			{Serial: "4660", RevocationDate: late, Reason: models.ReasonSuperseded, CertificateAuthority: "CA Two"},
+		})
+		if err != nil {
+			t.Fatalf("BatchInsertRevokedCertificates: %v", err)
+		}
+
+		tests := []struct {
+			name    string
+			ca      string
+			asOf    time.Time
+			revoked bool
+			wantCA  string
+		}{
+			{"before both", "", early.Add(-time.Hour), false, ""},
+			{"between both", "", early.Add(24 * time.Hour), true, "CA One"},
+			{"after both", "", late.Add(time.Hour), true, "CA Two"},
+			{"second CA only", "CA Two", early.Add(24 * time.Hour), false, ""},
+			{"first CA after both", "CA One", late.Add(time.Hour), true, "CA One"},
+		}
+		for _, tt := range tests {
+			status, err := store.GetCertificateStatusAsOf(ctx, "4660", tt.ca, tt.asOf)
+			if err != nil {
+				t.Fatalf("%s: GetCertificateStatusAsOf: %v", tt.name, err)
+			}
+			if status.IsRevoked != tt.revoked {
+				t.Errorf("%s: got revoked=%v, want %v", tt.name, status.IsRevoked, tt.revoked)
+				continue
+			}
+			if tt.revoked && (status.CertificateAuthority == nil || *status.CertificateAuthority != tt.wantCA) {
+				t.Errorf("%s: got CA %v, want %s", tt.name, status.CertificateAuthority, tt.wantCA)
+			}
+		}
+
+		// Sin fecha la consulta sigue devolviendo la revocación más reciente
+		status, err := store.GetCertificateStatus(ctx, "4660")
+		if err != nil {
+			t.Fatalf("GetCertificateStatus: %v", err)
+		}
+		if status.CertificateAuthority == nil || *status.CertificateAuthority != "CA Two" {
+			t.Errorf("got current CA %v, want CA Two", status.CertificateAuthority)
+		}
+	})
+}
+
+func TestGetStatsByCAGroupsByIssuer(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store CertStore) {
+		ctx := context.Background()
+		revokedAt := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+		_, err := store.BatchInsertRevokedCertificates(ctx, []*models.RevokedCertificate{
+			{Serial: "1", RevocationDate: revokedAt, CertificateAuthority: "CA One", IssuerDN: "CN=CA One"},
+			{Serial: "2", RevocationDate: revokedAt, CertificateAuthority: "CA One", IssuerDN: "CN=CA One"},
+			{Serial: "3", RevocationDate: revokedAt, CertificateAuthority: "CA One", IssuerDN: "CN=CA One"},
+			{Serial: "1", RevocationDate: revokedAt, CertificateAuthority: "CA Two", IssuerDN: "CN=CA Two"},
+		})
+		if err != nil {
+			t.Fatalf("BatchInsertRevokedCertificates: %v", err)
+		}
+		nextUpdate := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+		err = store.InsertCRLInfo(ctx, &models.CRLInfo{
+			URL:           "http://crl.example/one.crl",
+			Issuer:        "CA One",
+			IssuerDN:      "CN=CA One",
+			NextUpdate:    nextUpdate,
+			LastProcessed: time.Now().UTC(),
+			CertCount:     3,
+		})
+		if err != nil {
+			t.Fatalf("InsertCRLInfo: %v", err)
+		}
+
+		stats, err := store.GetStatsByCA(ctx, "")
+		if err != nil {
+			t.Fatalf("GetStatsByCA: %v", err)
+		}
+		if len(stats) != 2 {
+			t.Fatalf("got %d CAs, want 2", len(stats))
+		}
+		if stats[0].CertificateAuthority != "CA One" || stats[0].RevokedCount != 3 {
+			t.Errorf("got first CA %s with %d revoked, want CA One with 3", stats[0].CertificateAuthority, stats[0].RevokedCount)
+		}
+		if stats[0].CRLURL == nil || *stats[0].CRLURL != "http://crl.example/one.crl" || stats[0].IsStale {
+			t.Errorf("got CA One CRL %v stale=%v, want its fresh CRL", stats[0].CRLURL, stats[0].IsStale)
+		}
+		if stats[1].CertificateAuthority != "CA Two" || stats[1].RevokedCount != 1 || stats[1].CRLURL != nil {
+			t.Errorf("got second CA %s with %d revoked and CRL %v, want CA Two with 1 and no CRL", stats[1].CertificateAuthority, stats[1].RevokedCount, stats[1].CRLURL)
+		}
+
+		// El filtro acepta el nombre o el DN de la CA
+		for _, ca := range []string{"CA Two", "CN=CA Two"} {
+			stats, err := store.GetStatsByCA(ctx, ca)
+			if err != nil {
+				t.Fatalf("GetStatsByCA(%q): %v", ca, err)
+			}
+			if len(stats) != 1 || stats[0].CertificateAuthority != "CA Two" || stats[0].RevokedCount != 1 {
+				t.Errorf("GetStatsByCA(%q): got %+v, want only CA Two with 1 revoked", ca, stats)
+			}
+		}
+	})
+}
+
+func TestListRevokedCertificatesFilters(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store CertStore) {
+		ctx := context.Background()
+		jan := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+		feb := time.Date(2024, 2, 15, 0, 0, 0, 0, time.UTC)
+		mar := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+		_, err := store.BatchInsertRevokedCertificates(ctx, []*models.RevokedCertificate{
+			{Serial: "10", RevocationDate: jan, Reason: models.ReasonKeyCompromise, CertificateAuthority: "CA One"},
+			{Serial: "11", RevocationDate: feb, Reason: models.ReasonSuperseded, CertificateAuthority: "CA One"},
+			{Serial: "12", RevocationDate: mar, Reason: models.ReasonKeyCompromise, CertificateAuthority: "CA One"},
+			{Serial: "20", RevocationDate: feb, Reason: models.ReasonKeyCompromise, CertificateAuthority: "CA Two"},
+		})
+		if err != nil {
+			t.Fatalf("BatchInsertRevokedCertificates: %v", err)
+		}
+
+		keyCompromise := models.ReasonKeyCompromise
+		from := feb.Add(-time.Hour)
+		to := feb.Add(time.Hour)
+		tests := []struct {
+			name   string
+			filter models.CertificateFilter
+			want   []string
+		}{
+			{"no filter", models.CertificateFilter{}, []string{"12", "11", "20", "10"}},
+			{"CA", models.CertificateFilter{CertificateAuthority: "CA One"}, []string{"12", "11", "10"}},
+			{"reason", models.CertificateFilter{Reason: &keyCompromise}, []string{"12", "20", "10"}},
+			{"from", models.CertificateFilter{From: &from}, []string{"12", "11", "20"}},
+			{"to", models.CertificateFilter{To: &to}, []string{"11", "20", "10"}},
+			{"date range", models.CertificateFilter{From: &from, To: &to}, []string{"11", "20"}},
+			{"CA and reason", models.CertificateFilter{CertificateAuthority: "CA One", Reason: &keyCompromise}, []string{"12", "10"}},
+			{"CA and date range", models.CertificateFilter{CertificateAuthority: "CA Two", From: &from, To: &to}, []string{"20"}},
+			{"all filters", models.CertificateFilter{CertificateAuthority: "CA One", Reason: &keyCompromise, From: &from, To: &to}, nil},
+		}
+		for _, tt := range tests {
+			certs, total, err := store.ListRevokedCertificates(ctx, tt.filter, 10, 0)
+			if err != nil {
+				t.Fatalf("%s: ListRevokedCertificates: %v", tt.name, err)
+			}
+			if total != len(tt.want) || len(certs) != len(tt.want) {
+				t.Errorf("%s: got %d certificates (total %d), want %d", tt.name, len(certs), total, len(tt.want))
+				continue
+			}
+			// Las fechas iguales no tienen un orden garantizado entre sí
+			got := make(map[string]bool)
+			for i, cert := range certs {
+				got[cert.Serial] = true
+				if i > 0 && cert.RevocationDate.After(certs[i-1].RevocationDate) {
+					t.Errorf("%s: results not ordered by revocation date", tt.name)
+				}
+			}
+			for _, serial := range tt.want {
+				if !got[serial] {
+					t.Errorf("%s: serial %s missing from %v", tt.name, serial, got)
+				}
+			}
+		}
+
+		// La paginación respeta el total y el desplazamiento
+		certs, total, err := store.ListRevokedCertificates(ctx, models.CertificateFilter{}, 2, 2)
+		if err != nil {
+			t.Fatalf("ListRevokedCertificates: %v", err)
+		}
+		if total != 4 || len(certs) != 2 || certs[1].Serial != "10" {
+			t.Errorf("got page %v with total %d, want the last 2 of 4", certs, total)
+		}
+	})
+}
+
+func TestCRLSourcesRoundTrip(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store CertStore) {
+		ctx := context.Background()
+
+		first := &models.CRLSource{URL: "http://crl.example/one.crl", Label: "One", Enabled: true}
+		second := &models.CRLSource{URL: "ldap://ldap.example/cn=Two?certificateRevocationList", Enabled: true}
+		for _, source := range []*models.CRLSource{first, second} {
+			if err := store.AddCRLSource(ctx, source); err != nil {
+				t.Fatalf("AddCRLSource(%s): %v", source.URL, err)
+			}
+			if source.ID == 0 {
+				t.Fatalf("AddCRLSource(%s) did not assign an ID", source.URL)
+			}
+		}
+		if err := store.AddCRLSource(ctx, &models.CRLSource{URL: first.URL}); !errors.Is(err, ErrDuplicateSource) {
+			t.Errorf("got error %v adding a duplicate URL, want ErrDuplicateSource", err)
+		}
+
+		sources, err := store.ListCRLSources(ctx)
+		if err != nil {
+			t.Fatalf("ListCRLSources: %v", err)
+		}
+		if len(sources) != 2 {
+			t.Fatalf("got %d sources, want 2", len(sources))
+		}
+		got, err := store.GetCRLSourceByURL(ctx, first.URL)
+		if err != nil {
+			t.Fatalf("GetCRLSourceByURL: %v", err)
+		}
+		if got.ID != first.ID || got.Label != "One" || !got.Enabled {
+			t.Errorf("got source %+v, want %+v", got, first)
+		}
+
+		if err := store.DeleteCRLSource(ctx, first.ID); err != nil {
+			t.Fatalf("DeleteCRLSource: %v", err)
+		}
+		if err := store.DeleteCRLSource(ctx, first.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("got error %v deleting a missing source, want sql.ErrNoRows", err)
+		}
+		sources, err = store.ListCRLSources(ctx)
+		if err != nil {
+			t.Fatalf("ListCRLSources: %v", err)
+		}
+		if len(sources) != 1 || sources[0].URL != second.URL {
+			t.Errorf("got sources %+v after delete, want only %s", sources, second.URL)
+		}
+	})
+}
+
+func TestCertificateFingerprintLookup(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store CertStore) {
+		ctx := context.Background()
+		revokedAt := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+		_, err := store.BatchInsertRevokedCertificates(ctx, []*models.RevokedCertificate{
+			{Serial: "77", RevocationDate: revokedAt, Reason: models.ReasonKeyCompromise, CertificateAuthority: "CA One"},
+			{Serial: "77", RevocationDate: revokedAt, Reason: models.ReasonSuperseded, CertificateAuthority: "CA Two"},
+		})
+		if err != nil {
+			t.Fatalf("BatchInsertRevokedCertificates: %v", err)
+		}
+
+		fingerprint := strings.Repeat("ab", 32)
+		if err := store.SetCertificateFingerprint(ctx, "77", "CA Two", fingerprint); err != nil {
+			t.Fatalf("SetCertificateFingerprint: %v", err)
+		}
+
+		status, err := store.GetCertificateByFingerprint(ctx, fingerprint)
+		if err != nil {
+			t.Fatalf("GetCertificateByFingerprint: %v", err)
+		}
+		if status == nil || !status.IsRevoked || status.CertificateAuthority == nil || *status.CertificateAuthority != "CA Two" {
+			t.Fatalf("got status %+v, want serial 77 revoked by CA Two", status)
+		}
+		if status.Fingerprint == nil || *status.Fingerprint != fingerprint {
+			t.Errorf("got fingerprint %v, want %s", status.Fingerprint, fingerprint)
+		}
+
+		status, err = store.GetCertificateByFingerprint(ctx, strings.Repeat("cd", 32))
+		if err != nil {
+			t.Fatalf("GetCertificateByFingerprint: %v", err)
+		}
+		if status != nil {
+			t.Errorf("got status %+v for an unknown fingerprint, want nil", status)
+		}
+	})
+}
+
+func TestDeleteUntrackedCertificates(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store CertStore) {
+		ctx := context.Background()
+		now := time.Now().UTC().Truncate(time.Second)
+		revokedAt := now.Add(-30 * 24 * time.Hour)
+
+		var certs []*models.RevokedCertificate
+		for _, ca := range []string{"Tracked CA", "Source CA", "Recent CA", "Dropped CA", "Orphan CA"} {
+			certs = append(certs, &models.RevokedCertificate{Serial: "1", RevocationDate: revokedAt, CertificateAuthority: ca})
+		}
+		if _, err := store.BatchInsertRevokedCertificates(ctx, certs); err != nil {
+			t.Fatalf("BatchInsertRevokedCertificates: %v", err)
+		}
+
+		old := now.Add(-48 * time.Hour)
+		for _, info := range []*models.CRLInfo{
+			{URL: "http://crl.example/tracked.crl", Issuer: "Tracked CA", LastProcessed: old},
+			{URL: "http://crl.example/source.crl", Issuer: "Source CA", LastProcessed: old},
+			// Procesada después del corte aunque ya no esté configurada
+			{URL: "http://crl.example/recent.crl", Issuer: "Recent CA", LastProcessed: now.Add(2 * time.Hour)},
+			{URL: "http://crl.example/dropped.crl", Issuer: "Dropped CA", LastProcessed: old},
+		} {
+			info.NextUpdate = old
+			if err := store.InsertCRLInfo(ctx, info); err != nil {
+				t.Fatalf("InsertCRLInfo: %v", err)
+			}
+		}
+		if err := store.AddCRLSource(ctx, &models.CRLSource{URL: "http://crl.example/source.crl", Enabled: true}); err != nil {
+			t.Fatalf("AddCRLSource: %v", err)
+		}
+
+		// Con el corte en el futuro todos los certificados quedan fuera de la retención
+		deleted, err := store.DeleteUntrackedCertificates(ctx, now.Add(time.Hour), []string{"http://crl.example/tracked.crl"})
+		if err != nil {
+			t.Fatalf("DeleteUntrackedCertificates: %v", err)
+		}
+		if len(deleted) != 2 {
+			t.Errorf("got %d deleted certificates, want 2", len(deleted))
+		}
+
+		for ca, wantKept := range map[string]bool{
+			"Tracked CA": true,
+			"Source CA":  true,
+			"Recent CA":  true,
+			"Dropped CA": false,
+			"Orphan CA":  false,
+		} {
+			status, err := store.GetCertificateStatusByCA(ctx, "1", ca)
+			if err != nil {
+				t.Fatalf("GetCertificateStatusByCA(%s): %v", ca, err)
+			}
+			if status.IsRevoked != wantKept {
+				t.Errorf("%s: got kept=%v, want %v", ca, status.IsRevoked, wantKept)
+			}
+		}
+
+		// Con el corte en el pasado los certificados recién actualizados se conservan
+		deleted, err = store.DeleteUntrackedCertificates(ctx, now.Add(-time.Hour), nil)
+		if err != nil {
+			t.Fatalf("DeleteUntrackedCertificates: %v", err)
+		}
+		if len(deleted) != 0 {
+			t.Errorf("got %d deleted certificates within the retention window, want 0", len(deleted))
+		}
+	})
+}
+
+func TestCACertificatesRoundTrip(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store CertStore) {
+		ctx := context.Background()
+		notBefore := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		ca := &models.CACertificate{
+			Subject:   "CN=Stored CA",
+			KeyID:     "0a1b2c",
+			NotBefore: notBefore,
+			NotAfter:  notBefore.AddDate(1, 0, 0),
+			DER:       []byte{0x30, 0x03, 0x02, 0x01, 0x01},
+		}
+		if err := store.AddCACertificate(ctx, ca); err != nil {
+			t.Fatalf("AddCACertificate: %v", err)
+		}
+		if ca.ID == 0 {
+			t.Fatal("AddCACertificate did not assign an ID")
+		}
+
+		duplicate := *ca
+		duplicate.ID = 0
+		if err := store.AddCACertificate(ctx, &duplicate); !errors.Is(err, ErrDuplicateCA) {
+			t.Errorf("adding the same certificate again: got %v, want ErrDuplicateCA", err)
+		}
+
+		listed, err := store.ListCACertificates(ctx)
+		if err != nil {
+			t.Fatalf("ListCACertificates: %v", err)
+		}
+		if len(listed) != 1 || listed[0].Subject != ca.Subject || listed[0].KeyID != ca.KeyID || string(listed[0].DER) != string(ca.DER) {
+			t.Fatalf("got listed CAs %+v, want the stored one", listed)
+		}
+
+		bySubject, err := store.GetCACertificatesBySubject(ctx, "CN=Stored CA")
+		if err != nil {
+			t.Fatalf("GetCACertificatesBySubject: %v", err)
+		}
+		if len(bySubject) != 1 || bySubject[0].ID != ca.ID {
+			t.Errorf("got CAs by subject %+v, want the stored one", bySubject)
+		}
+
+		if err := store.DeleteCACertificate(ctx, ca.ID); err != nil {
+			t.Fatalf("DeleteCACertificate: %v", err)
+		}
+		if err := store.DeleteCACertificate(ctx, ca.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("deleting a missing CA: got %v, want sql.ErrNoRows", err)
+		}
+	})
+}
+
+func TestGetReasonBreakdown(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store CertStore) {
+		ctx := context.Background()
+		revokedAt := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+		_, err := store.BatchInsertRevokedCertificates(ctx, []*models.RevokedCertificate{
+			{Serial: "1", RevocationDate: revokedAt, Reason: models.ReasonKeyCompromise, CertificateAuthority: "CA One"},
+			{Serial: "2", RevocationDate: revokedAt, Reason: models.ReasonKeyCompromise, CertificateAuthority: "CA One"},
+			{Serial: "3", RevocationDate: revokedAt, Reason: models.ReasonSuperseded, CertificateAuthority: "CA One"},
+			{Serial: "4", RevocationDate: revokedAt, Reason: models.ReasonKeyCompromise, CertificateAuthority: "CA Two"},
+			{Serial: "5", RevocationDate: revokedAt, Reason: models.ReasonCertificateHold, CertificateAuthority: "CA Two"},
+		})
+		if err != nil {
+			t.Fatalf("BatchInsertRevokedCertificates: %v", err)
+		}
+
+		tests := []struct {
+			ca   string
+			want map[int]int
+		}{
+			{"", map[int]int{models.ReasonKeyCompromise: 3, models.ReasonSuperseded: 1, models.ReasonCertificateHold: 1}},
+			{"CA One", map[int]int{models.ReasonKeyCompromise: 2, models.ReasonSuperseded: 1}},
+			{"Unknown CA", map[int]int{}},
+		}
+		for _, tt := range tests {
+			breakdown, err := store.GetReasonBreakdown(ctx, tt.ca)
+			if err != nil {
+				t.Fatalf("GetReasonBreakdown(%q): %v", tt.ca, err)
+			}
+			if len(breakdown) != len(tt.want) {
+				t.Errorf("GetReasonBreakdown(%q): got %v, want counts %v", tt.ca, breakdown, tt.want)
+				continue
+			}
+			for reason, count := range tt.want {
+				got := breakdown[reason]
+				if got.Count != count || got.ReasonText != models.RevocationReasons[reason] {
+					t.Errorf("GetReasonBreakdown(%q) reason %d: got %+v, want %d %q", tt.ca, reason, got, count, models.RevocationReasons[reason])
+				}
+			}
+		}
+	})
+}
+
+func TestListRevokedCertificatesByCursorVisitsEveryRowOnce(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store CertStore) {
+		ctx := context.Background()
+		revokedAt := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+		const rows = 25
+		certs := make([]*models.RevokedCertificate, 0, rows)
+		for i := 0; i < rows; i++ {
+			certs = append(certs, &models.RevokedCertificate{
+				Serial:               strconv.Itoa(1000 + i),
+				RevocationDate:       revokedAt,
+				CertificateAuthority: "Cursor CA",
+			})
+		}
+		if _, err := store.BatchInsertRevokedCertificates(ctx, certs); err != nil {
+			t.Fatalf("BatchInsertRevokedCertificates: %v", err)
+		}
+
+		seen := make(map[string]int)
+		afterID, pages := 0, 0
+		for {
+			cursor := afterID
+			page, _, err := store.ListRevokedCertificates(ctx, models.CertificateFilter{AfterID: &cursor}, 10, 0)
+			if err != nil {
+				t.Fatalf("ListRevokedCertificates: %v", err)
+			}
+			pages++
+			for i, cert := range page {
+				seen[cert.Serial]++
+				if i > 0 && cert.ID >= page[i-1].ID {
+					t.Fatalf("page %d not ordered by descending id", pages)
+				}
+			}
+			if len(page) < 10 {
+				break
+			}
+			afterID = page[len(page)-1].ID
+			if pages > rows {
+				t.Fatal("cursor pagination did not terminate")
+			}
+		}
+
+		if pages != 3 || len(seen) != rows {
+			t.Errorf("got %d distinct rows in %d pages, want %d in 3", len(seen), pages, rows)
+		}
+		for serial, count := range seen {
+			if count != 1 {
+				t.Errorf("serial %s visited %d times", serial, count)
+			}
+		}
+	})
+}
+
+func TestProcessingHistoryAndPruning(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store CertStore) {
+		ctx := context.Background()
+		now := time.Now().UTC().Truncate(time.Second)
+		entries := []*models.ProcessingLogEntry{
+			{URL: "http://crl.example/a.crl", StartedAt: now.Add(-40 * 24 * time.Hour), DurationMs: 10, HTTPStatus: 200, BytesDownloaded: 100, CertCount: 1, Status: models.ProcessingStatusSuccess},
+			{URL: "http://crl.example/a.crl", StartedAt: now.Add(-2 * time.Hour), DurationMs: 20, HTTPStatus: 503, Status: models.ProcessingStatusFailed, Error: "HTTP 503"},
+			{URL: "http://crl.example/a.crl", StartedAt: now.Add(-time.Hour), DurationMs: 30, HTTPStatus: 200, BytesDownloaded: 300, CertCount: 3, Status: models.ProcessingStatusSuccess},
+			{URL: "http://crl.example/b.crl", StartedAt: now.Add(-30 * time.Minute), DurationMs: 5, HTTPStatus: 304, Status: models.ProcessingStatusNotModified},
+		}
+		for _, entry := range entries {
+			if err := store.InsertProcessingLog(ctx, entry); err != nil {
+				t.Fatalf("InsertProcessingLog: %v", err)
+			}
+		}
+
+		history, err := store.GetProcessingHistory(ctx, "http://crl.example/a.crl", 2)
+		if err != nil {
+			t.Fatalf("GetProcessingHistory: %v", err)
+		}
+		if len(history) != 2 || history[0].DurationMs != 30 || history[1].DurationMs != 20 {
+			t.Fatalf("got history %+v, want the 2 most recent runs of a.crl, newest first", history)
+		}
+		if failed := history[1]; failed.Status != models.ProcessingStatusFailed || failed.Error != "HTTP 503" || failed.HTTPStatus != 503 {
+			t.Errorf("got failed run %+v, want status failed with its error and HTTP status", failed)
+		}
+		if ok := history[0]; ok.BytesDownloaded != 300 || ok.CertCount != 3 || !ok.StartedAt.Equal(now.Add(-time.Hour)) {
+			t.Errorf("got successful run %+v, want 300 bytes, 3 certificates and its start time", ok)
+		}
+
+		all, err := store.GetProcessingHistory(ctx, "", 10)
+		if err != nil || len(all) != 4 {
+			t.Fatalf("got %d runs across URLs (err %v), want 4", len(all), err)
+		}
+
+		pruned, err := store.PruneProcessingLog(ctx, now.Add(-30*24*time.Hour))
+		if err != nil {
+			t.Fatalf("PruneProcessingLog: %v", err)
+		}
+		if pruned != 1 {
+			t.Errorf("pruned %d runs, want 1", pruned)
+		}
+		if remaining, _ := store.GetProcessingHistory(ctx, "", 10); len(remaining) != 3 {
+			t.Errorf("got %d runs after pruning, want 3", len(remaining))
+		}
+	})
+}
+
+func TestListRevokedInRangeComparesNumerically(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store CertStore) {
+		ctx := context.Background()
+		revokedAt := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+		var certs []*models.RevokedCertificate
+		for _, serial := range []string{"5", "9", "10", "99", "100", "101", "123456789012345678901234567890"} {
+			certs = append(certs, &models.RevokedCertificate{Serial: serial, RevocationDate: revokedAt, CertificateAuthority: "CA One"})
+		}
+		certs = append(certs, &models.RevokedCertificate{Serial: "50", RevocationDate: revokedAt, CertificateAuthority: "CA Two"})
+		if _, err := store.BatchInsertRevokedCertificates(ctx, certs); err != nil {
+			t.Fatalf("BatchInsertRevokedCertificates: %v", err)
+		}
+
+		tests := []struct {
+			name     string
+			ca       string
+			from, to string
+			limit    int
+			want     []string
+		}{
+			// Como texto "10" < "9" y "100" < "99"; la comparación debe ser numérica
+			{"small serials", "CA One", "9", "100", 10, []string{"9", "10", "99", "100"}},
+			{"beyond 64 bits", "CA One", "100000000000000000000000000000", "200000000000000000000000000000", 10, []string{"123456789012345678901234567890"}},
+			{"limit", "CA One", "0", "1000", 2, []string{"5", "9"}},
+			{"other CA", "CA Two", "0", "1000", 10, []string{"50"}},
+			{"empty range", "CA One", "11", "98", 10, nil},
+		}
+		for _, tt := range tests {
+			certs, err := store.ListRevokedInRange(ctx, tt.ca, tt.from, tt.to, tt.limit)
+			if err != nil {
+				t.Fatalf("%s: ListRevokedInRange: %v", tt.name, err)
+			}
+			got := make([]string, 0, len(certs))
+			for _, cert := range certs {
+				got = append(got, cert.Serial)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("%s: got serials %v, want %v", tt.name, got, tt.want)
+			}
+		}
+	})
+}
+
+func TestGetRecentRevokedOrdersByRevocationDate(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store CertStore) {
+		ctx := context.Background()
+		base := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+		if _, err := store.BatchInsertRevokedCertificates(ctx, []*models.RevokedCertificate{
+			{Serial: "1", RevocationDate: base, CertificateAuthority: "CA One"},
+			{Serial: "2", RevocationDate: base.Add(48 * time.Hour), CertificateAuthority: "CA One"},
+			{Serial: "3", RevocationDate: base.Add(24 * time.Hour), CertificateAuthority: "CA Two"},
+			{Serial: "4", RevocationDate: base.Add(72 * time.Hour), CertificateAuthority: "CA Two"},
+		}); err != nil {
+			t.Fatalf("BatchInsertRevokedCertificates: %v", err)
+		}
+
+		for _, tt := range []struct {
+			n    int
+			want string
+		}{
+			{2, "4,2"},
+			{10, "4,2,3,1"},
+		} {
+			certs, err := store.GetRecentRevoked(ctx, tt.n)
+			if err != nil {
+				t.Fatalf("GetRecentRevoked(%d): %v", tt.n, err)
+			}
+			got := make([]string, 0, len(certs))
+			for _, cert := range certs {
+				got = append(got, cert.Serial)
+			}
+			if strings.Join(got, ",") != tt.want {
+				t.Errorf("GetRecentRevoked(%d) = %v, want %s", tt.n, got, tt.want)
+			}
+		}
+	})
+}
+
+func TestSerialLengthLimits(t *testing.T) {
+	// El serial más largo que admite RFC 5280 (20 octetos) en su forma decimal
+	maxRFCSerial := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 159), big.NewInt(1)).String()
+	tooLong := strings.Repeat("9", models.MaxSerialLength+1)
+
+	forEachStore(t, func(t *testing.T, store CertStore) {
+		ctx := context.Background()
+		revokedAt := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+
+		// Un serial demasiado largo se omite sin hacer fallar el resto del lote
+		inserted, err := store.BatchInsertRevokedCertificates(ctx, []*models.RevokedCertificate{
+			{Serial: "777", RevocationDate: revokedAt, CertificateAuthority: "CA One"},
+			{Serial: tooLong, RevocationDate: revokedAt, CertificateAuthority: "CA One"},
+			{Serial: maxRFCSerial, RevocationDate: revokedAt, CertificateAuthority: "CA One"},
+		})
+		if err != nil {
+			t.Fatalf("BatchInsertRevokedCertificates: %v", err)
+		}
+		if len(inserted) != 2 {
+			t.Errorf("inserted %d certificates, want 2", len(inserted))
+		}
+
+		for _, serial := range []string{"777", maxRFCSerial} {
+			status, err := store.GetCertificateStatus(ctx, serial)
+			if err != nil {
+				t.Fatalf("GetCertificateStatus(%s): %v", serial, err)
+			}
+			if !status.IsRevoked || status.Serial != serial {
+				t.Errorf("serial %s: got revoked=%v serial %q, want it stored unchanged", serial, status.IsRevoked, status.Serial)
+			}
+		}
+		if status, err := store.GetCertificateStatus(ctx, tooLong); err != nil || status.IsRevoked {
+			t.Errorf("overlong serial was stored (err %v)", err)
+		}
+
+		err = store.InsertRevokedCertificate(ctx, &models.RevokedCertificate{Serial: tooLong, RevocationDate: revokedAt, CertificateAuthority: "CA One"})
+		if !errors.Is(err, ErrSerialTooLong) {
+			t.Errorf("InsertRevokedCertificate with an overlong serial: got %v, want ErrSerialTooLong", err)
+		}
+	})
+}
+
+func TestPurgeCA(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store CertStore) {
+		ctx := context.Background()
+		revokedAt := time.Now().UTC().Truncate(time.Second).Add(-time.Hour)
+
+		// El serial 1 existe en ambas CAs; solo debe desaparecer el de la CA purgada
+		if _, err := store.BatchInsertRevokedCertificates(ctx, []*models.RevokedCertificate{
+			{Serial: "1", RevocationDate: revokedAt, CertificateAuthority: "Retired CA"},
+			{Serial: "2", RevocationDate: revokedAt, CertificateAuthority: "Retired CA"},
+			{Serial: "1", RevocationDate: revokedAt, CertificateAuthority: "Active CA"},
+		}); err != nil {
+			t.Fatalf("BatchInsertRevokedCertificates: %v", err)
+		}
+		for _, info := range []*models.CRLInfo{
+			{URL: "http://crl.example/retired.crl", Issuer: "Retired CA"},
+			{URL: "http://crl.example/active.crl", Issuer: "Active CA"},
+		} {
+			info.LastProcessed = revokedAt
+			info.NextUpdate = revokedAt.Add(24 * time.Hour)
+			if err := store.InsertCRLInfo(ctx, info); err != nil {
+				t.Fatalf("InsertCRLInfo: %v", err)
+			}
+		}
+
+		serials, crlInfos, err := store.PurgeCA(ctx, "Retired CA")
+		if err != nil {
+			t.Fatalf("PurgeCA: %v", err)
+		}
+		sort.Strings(serials)
+		if !reflect.DeepEqual(serials, []string{"1", "2"}) || crlInfos != 1 {
+			t.Errorf("got serials %v and %d CRL info rows, want [1 2] and 1", serials, crlInfos)
+		}
+
+		for _, serial := range []string{"1", "2"} {
+			status, err := store.GetCertificateStatusByCA(ctx, serial, "Retired CA")
+			if err != nil {
+				t.Fatalf("GetCertificateStatusByCA(%s): %v", serial, err)
+			}
+			if status.IsRevoked {
+				t.Errorf("serial %s of the purged CA is still revoked", serial)
+			}
+		}
+		if _, err := store.GetCRLInfo(ctx, "http://crl.example/retired.crl"); err == nil {
+			t.Error("CRL info of the purged CA is still stored")
+		}
+
+		// La otra CA no se toca
+		status, err := store.GetCertificateStatusByCA(ctx, "1", "Active CA")
+		if err != nil || !status.IsRevoked {
+			t.Errorf("serial 1 of the remaining CA: got %+v, %v; want revoked", status, err)
+		}
+		if _, err := store.GetCRLInfo(ctx, "http://crl.example/active.crl"); err != nil {
+			t.Errorf("CRL info of the remaining CA: %v", err)
+		}
+
+		serials, crlInfos, err = store.PurgeCA(ctx, "Retired CA")
+		if err != nil || len(serials) != 0 || crlInfos != 0 {
+			t.Errorf("purging again = %v, %d, %v; want nothing deleted", serials, crlInfos, err)
+		}
+	})
+}
+
+func TestGetThroughput(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store CertStore) {
+		ctx := context.Background()
+		day := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+		at := func(hours, minutes int) time.Time {
+			return day.Add(time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute)
+		}
+		for _, entry := range []*models.ProcessingLogEntry{
+			// Anterior a since: no se cuenta
+			{StartedAt: at(-48, 0), DurationMs: 1000, CertCount: 99, Status: models.ProcessingStatusSuccess},
+			{StartedAt: at(10, 15), DurationMs: 100, CertCount: 3, Status: models.ProcessingStatusSuccess},
+			{StartedAt: at(10, 45), DurationMs: 50, CertCount: 4, Status: models.ProcessingStatusFailed},
+			{StartedAt: at(11, 5), DurationMs: 200, CertCount: 5, Status: models.ProcessingStatusSuccess},
+			{StartedAt: at(11, 30), DurationMs: 10, Status: models.ProcessingStatusNotModified},
+			{StartedAt: at(33, 0), DurationMs: 300, CertCount: 7, Status: models.ProcessingStatusSuccess},
+		} {
+			entry.URL = "http://crl.example/throughput.crl"
+			if err := store.InsertProcessingLog(ctx, entry); err != nil {
+				t.Fatalf("InsertProcessingLog: %v", err)
+			}
+		}
+
+		tests := []struct {
+			bucket string
+			want   []models.ThroughputBucket
+		}{
+			{"hour", []models.ThroughputBucket{
+				{BucketStart: at(10, 0), Runs: 2, FailedRuns: 1, CertsImported: 3, DurationMs: 150},
+				{BucketStart: at(11, 0), Runs: 2, CertsImported: 5, DurationMs: 210},
+				{BucketStart: at(33, 0), Runs: 1, CertsImported: 7, DurationMs: 300},
+			}},
+			{"day", []models.ThroughputBucket{
+				{BucketStart: day, Runs: 4, FailedRuns: 1, CertsImported: 8, DurationMs: 360},
+				{BucketStart: day.Add(24 * time.Hour), Runs: 1, CertsImported: 7, DurationMs: 300},
+			}},
+		}
+		for _, tt := range tests {
+			buckets, err := store.GetThroughput(ctx, day, tt.bucket)
+			if err != nil {
+				t.Fatalf("GetThroughput(%s): %v", tt.bucket, err)
+			}
+			if len(buckets) != len(tt.want) {
+				t.Fatalf("%s: got %d buckets, want %d", tt.bucket, len(buckets), len(tt.want))
+			}
+			for i, want := range tt.want {
+				got := *buckets[i]
+				if !got.BucketStart.Equal(want.BucketStart) {
+					t.Errorf("%s bucket %d: got start %v, want %v", tt.bucket, i, got.BucketStart, want.BucketStart)
+				}
+				got.BucketStart = want.BucketStart
+				if got != want {
+					t.Errorf("%s bucket %d: got %+v, want %+v", tt.bucket, i, got, want)
+				}
+			}
+		}
+	})
+}
+
+func TestSameSerialUnderTwoCAs(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store CertStore) {
+		ctx := context.Background()
+		revokedAt := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+
+		// La misma fecha en las dos CAs: sin ca gana la de nombre menor
+		inserted, err := store.BatchInsertRevokedCertificates(ctx, []*models.RevokedCertificate{
+			{Serial: "7070", RevocationDate: revokedAt, Reason: models.ReasonKeyCompromise, CertificateAuthority: "Beta CA"},
+			{Serial: "7070", RevocationDate: revokedAt, Reason: models.ReasonSuperseded, CertificateAuthority: "Alpha CA"},
+		})
+		if err != nil {
+			t.Fatalf("BatchInsertRevokedCertificates: %v", err)
+		}
+		if len(inserted) != 2 {
+			t.Fatalf("got %d new certificates, want one per CA", len(inserted))
+		}
+		if status, err := store.GetCertificateStatus(ctx, "7070"); err != nil || status.CertificateAuthority == nil || *status.CertificateAuthority != "Alpha CA" {
+			t.Errorf("tied lookup = %+v, %v; want Alpha CA", status, err)
+		}
+
+		// La misma CA que vuelve a listar el serial actualiza solo su fila
+		inserted, err = store.BatchInsertRevokedCertificates(ctx, []*models.RevokedCertificate{
+			{Serial: "7070", RevocationDate: revokedAt.Add(24 * time.Hour), Reason: models.ReasonCACompromise, CertificateAuthority: "Beta CA"},
+		})
+		if err != nil || len(inserted) != 0 {
+			t.Fatalf("re-listing the serial: got %d new certificates, %v; want an update", len(inserted), err)
+		}
+		if _, total, err := store.ListRevokedCertificates(ctx, models.CertificateFilter{}, 10, 0); err != nil || total != 2 {
+			t.Errorf("got %d revoked certificates (err %v), want 2", total, err)
+		}
+		status, err := store.GetCertificateStatus(ctx, "7070")
+		if err != nil || status.CertificateAuthority == nil || *status.CertificateAuthority != "Beta CA" || *status.ReasonCode != models.ReasonCACompromise {
+			t.Errorf("latest lookup = %+v, %v; want the updated Beta CA revocation", status, err)
+		}
+
+		// Quitar el serial de una CA deja la revocación de la otra
+		removed, err := store.DeleteRevokedCertificates(ctx, "Beta CA", []string{"7070"})
+		if err != nil || len(removed) != 1 {
+			t.Fatalf("DeleteRevokedCertificates = %v, %v; want the Beta CA row", removed, err)
+		}
+		status, err = store.GetCertificateStatus(ctx, "7070")
+		if err != nil || !status.IsRevoked || status.CertificateAuthority == nil || *status.CertificateAuthority != "Alpha CA" {
+			t.Errorf("after removing Beta CA: got %+v, %v; want the Alpha CA revocation", status, err)
+		}
+	})
+}
+
+func TestGetCertificateStatusByCA(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store CertStore) {
+		ctx := context.Background()
+		early := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+		late := early.Add(48 * time.Hour)
+		if _, err := store.BatchInsertRevokedCertificates(ctx, []*models.RevokedCertificate{
+			{Serial: "8080", RevocationDate: early, Reason: models.ReasonKeyCompromise, CertificateAuthority: "Scoped CA One"},
+			{Serial: "8080", RevocationDate: late, Reason: models.ReasonSuperseded, CertificateAuthority: "Scoped CA Two"},
+		}); err != nil {
+			t.Fatalf("BatchInsertRevokedCertificates: %v", err)
+		}
+
+		tests := []struct {
+			name       string
+			ca         string
+			revoked    bool
+			wantReason int
+			wantDate   time.Time
+		}{
+			{"first CA", "Scoped CA One", true, models.ReasonKeyCompromise, early},
+			{"second CA", "Scoped CA Two", true, models.ReasonSuperseded, late},
+			{"other CA", "Scoped CA Three", false, 0, time.Time{}},
+		}
+		for _, tt := range tests {
+			status, err := store.GetCertificateStatusByCA(ctx, "8080", tt.ca)
+			if err != nil {
+				t.Fatalf("%s: GetCertificateStatusByCA: %v", tt.name, err)
+			}
+			if status.Serial != "8080" || status.IsRevoked != tt.revoked {
+				t.Errorf("%s: got serial %s revoked=%v, want 8080 revoked=%v", tt.name, status.Serial, status.IsRevoked, tt.revoked)
+				continue
+			}
+			if !tt.revoked {
+				continue
+			}
+			if status.CertificateAuthority == nil || *status.CertificateAuthority != tt.ca {
+				t.Errorf("%s: got CA %v, want %s", tt.name, status.CertificateAuthority, tt.ca)
+			}
+			if *status.ReasonCode != tt.wantReason || !status.RevocationDate.Equal(tt.wantDate) {
+				t.Errorf("%s: got reason %d at %v, want %d at %v", tt.name, *status.ReasonCode, status.RevocationDate, tt.wantReason, tt.wantDate)
+			}
+		}
+
+		// Sin CA se devuelve la revocación más reciente
+		status, err := store.GetCertificateStatus(ctx, "8080")
+		if err != nil || status.CertificateAuthority == nil || *status.CertificateAuthority != "Scoped CA Two" {
+			t.Errorf("unscoped lookup = %+v, %v; want Scoped CA Two", status, err)
+		}
+	})
+}
+
+func TestCertificateStatusReportsOnHold(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store CertStore) {
+		ctx := context.Background()
+		revokedAt := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+		if _, err := store.BatchInsertRevokedCertificates(ctx, []*models.RevokedCertificate{
+			{Serial: "6006", RevocationDate: revokedAt, Reason: models.ReasonCertificateHold, CertificateAuthority: "Hold CA"},
+			{Serial: "6007", RevocationDate: revokedAt, Reason: models.ReasonKeyCompromise, CertificateAuthority: "Hold CA"},
+		}); err != nil {
+			t.Fatalf("BatchInsertRevokedCertificates: %v", err)
+		}
+		fingerprint := strings.Repeat("ab", 32)
+		if err := store.SetCertificateFingerprint(ctx, "6006", "Hold CA", fingerprint); err != nil {
+			t.Fatalf("SetCertificateFingerprint: %v", err)
+		}
+
+		// is_revoked se mantiene en true para los certificados suspendidos
+		expect := func(name, want string) func(*models.CertificateStatus, error) {
+			return func(status *models.CertificateStatus, err error) {
+				t.Helper()
+				if err != nil {
+					t.Fatalf("%s: %v", name, err)
+				}
+				if status.Status != want || status.IsRevoked != (want != models.StatusGood) {
+					t.Errorf("%s: got revoked=%v status %q, want status %q", name, status.IsRevoked, status.Status, want)
+				}
+			}
+		}
+		expect("on hold", models.StatusOnHold)(store.GetCertificateStatus(ctx, "6006"))
+		expect("on hold by CA", models.StatusOnHold)(store.GetCertificateStatusByCA(ctx, "6006", "Hold CA"))
+		expect("on hold as of", models.StatusOnHold)(store.GetCertificateStatusAsOf(ctx, "6006", "", revokedAt.Add(time.Hour)))
+		expect("on hold by fingerprint", models.StatusOnHold)(store.GetCertificateByFingerprint(ctx, fingerprint))
+		expect("revoked", models.StatusRevoked)(store.GetCertificateStatus(ctx, "6007"))
+		expect("unknown", models.StatusGood)(store.GetCertificateStatus(ctx, "6008"))
+	})
+}
+
+func TestRecordCRLAttempt(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store CertStore) {
+		ctx := context.Background()
+		const url = "http://crl.example/attempts.crl"
+
+		// Sin crl_info ni fuente no hay dónde registrar el intento
+		if failures, err := store.RecordCRLAttempt(ctx, url, errors.New("connection refused")); err != nil || failures != 0 {
+			t.Errorf("RecordCRLAttempt for an unknown URL = %d, %v; want 0", failures, err)
+		}
+
+		source := &models.CRLSource{URL: url, Enabled: true}
+		if err := store.AddCRLSource(ctx, source); err != nil {
+			t.Fatalf("AddCRLSource: %v", err)
+		}
+		if err := store.InsertCRLInfo(ctx, &models.CRLInfo{URL: url, Issuer: "Attempts CA", NextUpdate: time.Now().Add(time.Hour), LastProcessed: time.Now()}); err != nil {
+			t.Fatalf("InsertCRLInfo: %v", err)
+		}
+		attempts := func() (info, src models.CRLAttempts) {
+			t.Helper()
+			crlInfo, err := store.GetCRLInfo(ctx, url)
+			if err != nil {
+				t.Fatalf("GetCRLInfo: %v", err)
+			}
+			crlSource, err := store.GetCRLSourceByURL(ctx, url)
+			if err != nil {
+				t.Fatalf("GetCRLSourceByURL: %v", err)
+			}
+			return crlInfo.CRLAttempts, crlSource.CRLAttempts
+		}
+
+		// Cada fallo suma uno y guarda el último error, en crl_info y en la fuente
+		for want := 1; want <= 3; want++ {
+			attemptErr := fmt.Errorf("HTTP error: 503 (attempt %d)", want)
+			failures, err := store.RecordCRLAttempt(ctx, url, attemptErr)
+			if err != nil || failures != want {
+				t.Fatalf("RecordCRLAttempt failure %d = %d, %v", want, failures, err)
+			}
+			info, src := attempts()
+			for name, got := range map[string]models.CRLAttempts{"crl_info": info, "source": src} {
+				if got.ConsecutiveFailures != want || got.LastError != attemptErr.Error() || got.LastSuccess != nil {
+					t.Errorf("%s after failure %d: got %+v, want %d failures with error %q and no success",
+						name, want, got, want, attemptErr)
+				}
+			}
+		}
+
+		// Reemplazar crl_info al procesar la CRL no borra los intentos
+		if err := store.InsertCRLInfo(ctx, &models.CRLInfo{URL: url, Issuer: "Attempts CA", NextUpdate: time.Now().Add(time.Hour), LastProcessed: time.Now()}); err != nil {
+			t.Fatalf("InsertCRLInfo: %v", err)
+		}
+		if info, _ := attempts(); info.ConsecutiveFailures != 3 {
+			t.Errorf("got %d failures after replacing crl_info, want 3", info.ConsecutiveFailures)
+		}
+
+		// Un éxito reinicia el contador y limpia el error
+		before := time.Now().Add(-time.Second)
+		if failures, err := store.RecordCRLAttempt(ctx, url, nil); err != nil || failures != 0 {
+			t.Fatalf("RecordCRLAttempt success = %d, %v; want 0", failures, err)
+		}
+		info, src := attempts()
+		for name, got := range map[string]models.CRLAttempts{"crl_info": info, "source": src} {
+			if got.ConsecutiveFailures != 0 || got.LastError != "" || got.LastSuccess == nil || got.LastSuccess.Before(before) {
+				t.Errorf("%s after success: got %+v, want no failures and a recent success", name, got)
+			}
+		}
+		if failures, err := store.RecordCRLAttempt(ctx, url, errors.New("timeout")); err != nil || failures != 1 {
+			t.Errorf("RecordCRLAttempt after success = %d, %v; want the count to start over at 1", failures, err)
+		}
+	})
+}
+
+func TestInvalidityDateRoundTrip(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store CertStore) {
+		ctx := context.Background()
+		revokedAt := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+		compromisedAt := time.Date(2024, 5, 20, 8, 30, 0, 0, time.UTC)
+		if _, err := store.BatchInsertRevokedCertificates(ctx, []*models.RevokedCertificate{
+			{Serial: "6101", RevocationDate: revokedAt, Reason: models.ReasonKeyCompromise, CertificateAuthority: "Invalidity CA", InvalidityDate: &compromisedAt},
+			{Serial: "6102", RevocationDate: revokedAt, Reason: models.ReasonKeyCompromise, CertificateAuthority: "Invalidity CA"},
+		}); err != nil {
+			t.Fatalf("BatchInsertRevokedCertificates: %v", err)
+		}
+
+		// La fecha de invalidez solo aparece en el estado de la entrada que la tiene
+		expect := func(name string, want *time.Time) func(*models.CertificateStatus, error) {
+			return func(status *models.CertificateStatus, err error) {
+				t.Helper()
+				if err != nil {
+					t.Fatalf("%s: %v", name, err)
+				}
+				got := status.InvalidityDate
+				if (got == nil) != (want == nil) || (got != nil && !got.Equal(*want)) {
+					t.Errorf("%s: got invalidity date %v, want %v", name, got, want)
+				}
+			}
+		}
+		expect("with date", &compromisedAt)(store.GetCertificateStatus(ctx, "6101"))
+		expect("with date by CA", &compromisedAt)(store.GetCertificateStatusByCA(ctx, "6101", "Invalidity CA"))
+		expect("with date as of", &compromisedAt)(store.GetCertificateStatusAsOf(ctx, "6101", "", revokedAt.Add(time.Hour)))
+		expect("without date", nil)(store.GetCertificateStatus(ctx, "6102"))
+	})
+}
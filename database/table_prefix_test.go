@@ -0,0 +1,119 @@
+package database
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"signerflow-crl/models"
+)
+
+func TestNewTableNames(t *testing.T) {
+	const query = "CREATE INDEX IF NOT EXISTS idx_crl_info_issuer ON crl_info (issuer); SELECT 1 FROM revoked_certificates"
+
+	tests := []struct {
+		name string
+		cfg  TableConfig
+		want string
+	}{
+		{"prefix", TableConfig{Prefix: "tenant_a_"},
+			"CREATE INDEX IF NOT EXISTS idx_tenant_a_crl_info_issuer ON tenant_a_crl_info (issuer); SELECT 1 FROM tenant_a_revoked_certificates"},
+		{"schema", TableConfig{Schema: "tenant_a"},
+			"CREATE INDEX IF NOT EXISTS idx_crl_info_issuer ON tenant_a.crl_info (issuer); SELECT 1 FROM tenant_a.revoked_certificates"},
+		{"schema and prefix", TableConfig{Schema: "crl", Prefix: "a_"},
+			"CREATE INDEX IF NOT EXISTS idx_a_crl_info_issuer ON crl.a_crl_info (issuer); SELECT 1 FROM crl.a_revoked_certificates"},
+	}
+	for _, tt := range tests {
+		if got := newTableNames(tt.cfg).Replace(query); got != tt.want {
+			t.Errorf("%s:\ngot  %s\nwant %s", tt.name, got, tt.want)
+		}
+	}
+
+	if newTableNames(TableConfig{}) != nil {
+		t.Error("empty table config rewrites the queries")
+	}
+}
+
+// assertTenantsIsolated comprueba que lo guardado por un tenant no lo ve el otro
+func assertTenantsIsolated(t *testing.T, tenantA, tenantB CertStore) {
+	t.Helper()
+
+	ctx := context.Background()
+	revokedAt := time.Now().UTC().Truncate(time.Second).Add(-time.Hour)
+	if _, err := tenantA.BatchInsertRevokedCertificates(ctx, []*models.RevokedCertificate{
+		{Serial: "4242", RevocationDate: revokedAt, CertificateAuthority: "Tenant A CA"},
+	}); err != nil {
+		t.Fatalf("BatchInsertRevokedCertificates: %v", err)
+	}
+	if err := tenantA.InsertCRLInfo(ctx, &models.CRLInfo{URL: "http://crl.example/a.crl", Issuer: "Tenant A CA", LastProcessed: revokedAt, NextUpdate: revokedAt.Add(24 * time.Hour)}); err != nil {
+		t.Fatalf("InsertCRLInfo: %v", err)
+	}
+
+	if status, err := tenantA.GetCertificateStatus(ctx, "4242"); err != nil || !status.IsRevoked {
+		t.Errorf("tenant A lookup = %+v, %v; want revoked", status, err)
+	}
+	if status, err := tenantB.GetCertificateStatus(ctx, "4242"); err != nil || status.IsRevoked {
+		t.Errorf("tenant B lookup = %+v, %v; want the serial unknown", status, err)
+	}
+	if infos, err := tenantB.ListCRLInfo(ctx); err != nil || len(infos) != 0 {
+		t.Errorf("tenant B CRL info = %d rows, %v; want none", len(infos), err)
+	}
+}
+
+func TestSQLiteTablePrefix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "crl.db")
+	open := func(prefix string) *SQLiteDB {
+		db, err := NewSQLiteDB(path, PoolConfig{MaxOpenConns: 1, MaxIdleConns: 1}, TableConfig{Prefix: prefix})
+		if err != nil {
+			t.Fatalf("NewSQLiteDB(%q): %v", prefix, err)
+		}
+		t.Cleanup(func() { db.Close() })
+		return db
+	}
+	tenantA, tenantB := open("tenant_a_"), open("tenant_b_")
+
+	for _, tt := range []struct {
+		name   string
+		exists bool
+	}{
+		{"tenant_a_revoked_certificates", true},
+		{"tenant_a_crl_info", true},
+		{"tenant_b_crl_processing_log", true},
+		{"revoked_certificates", false},
+		{"crl_info", false},
+	} {
+		var count int
+		if err := tenantA.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?1", tt.name).Scan(&count); err != nil {
+			t.Fatalf("querying sqlite_master: %v", err)
+		}
+		if (count == 1) != tt.exists {
+			t.Errorf("table %s: got exists=%v, want %v", tt.name, count == 1, tt.exists)
+		}
+	}
+
+	assertTenantsIsolated(t, tenantA, tenantB)
+
+	if _, err := NewSQLiteDB(path, PoolConfig{}, TableConfig{Schema: "tenant_a"}); err == nil {
+		t.Error("SQLite accepted a schema")
+	}
+}
+
+func TestPostgresTablePrefix(t *testing.T) {
+	// newTestPostgres crea el schema del tenant A; el B comparte el schema con otro prefijo
+	tenantA := newTestPostgres(t)
+	tenantB, err := NewPostgresDB(os.Getenv("TEST_DATABASE_URL"), PoolConfig{MaxOpenConns: 2, MaxIdleConns: 2}, TableConfig{Schema: tenantA.schema, Prefix: "tenant_b_"})
+	if err != nil {
+		t.Fatalf("NewPostgresDB: %v", err)
+	}
+	t.Cleanup(func() { tenantB.Close() })
+
+	var count int
+	query := "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = $1 AND table_name = $2"
+	if err := tenantB.QueryRow(query, tenantA.schema, "tenant_b_revoked_certificates").Scan(&count); err != nil || count != 1 {
+		t.Fatalf("prefixed table lookup = %d, %v; want tenant_b_revoked_certificates in schema %s", count, err, tenantA.schema)
+	}
+
+	assertTenantsIsolated(t, tenantA, tenantB)
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"signerflow-crl/apierror"
+	"signerflow-crl/database"
+	"signerflow-crl/i18n"
+	"signerflow-crl/requestid"
+	"signerflow-crl/services"
+)
+
+type CAHandler struct {
+	crlService *services.CRLService
+	db         database.CertStore
+}
+
+func NewCAHandler(crlService *services.CRLService, db database.CertStore) *CAHandler {
+	return &CAHandler{
+		crlService: crlService,
+		db:         db,
+	}
+}
+
+func (h *CAHandler) ListCAs(c *gin.Context) {
+	cas, err := h.db.ListCACertificates(c.Request.Context())
+	if err != nil {
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, i18n.MsgListCAsFailed)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"certificate_authorities": cas,
+	})
+}
+
+// UploadCA recibe el certificado de la CA en DER o PEM
+func (h *CAHandler) UploadCA(c *gin.Context) {
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, maxCertificateUploadSize))
+	if err != nil || len(data) == 0 {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeCertificateRequired, i18n.MsgCACertificateRequired)
+		return
+	}
+
+	ca, err := h.crlService.AddCACertificate(c.Request.Context(), data)
+	switch {
+	case errors.Is(err, services.ErrInvalidCertificate):
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidCertificate, i18n.MsgInvalidCertificate)
+	case errors.Is(err, services.ErrMissingCRLSign):
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidCertificate, i18n.MsgMissingCRLSign)
+	case errors.Is(err, database.ErrDuplicateCA):
+		apierror.Respond(c, http.StatusConflict, apierror.CodeAlreadyExists, i18n.MsgCAAlreadyExists)
+	case err != nil:
+		requestid.Logf(c.Request.Context(), "Error registering CA certificate: %v", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, i18n.MsgAddCAFailed)
+	default:
+		c.JSON(http.StatusCreated, ca)
+	}
+}
+
+func (h *CAHandler) DeleteCA(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidID, i18n.MsgInvalidCAID)
+		return
+	}
+
+	err = h.db.DeleteCACertificate(c.Request.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, i18n.MsgCANotFound)
+		return
+	}
+	if err != nil {
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, i18n.MsgDeleteCAFailed)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// PurgeCAData elimina los certificados revocados y la información de CRL de la CA indicada
+// en el parámetro ca; responde 404 si no había datos de esa CA
+func (h *CAHandler) PurgeCAData(c *gin.Context) {
+	ca := strings.TrimSpace(c.Query("ca"))
+	if ca == "" {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeMissingParameter, i18n.MsgCANameRequired)
+		return
+	}
+
+	result, err := h.crlService.PurgeCA(c.Request.Context(), ca)
+	if err != nil {
+		requestid.Logf(c.Request.Context(), "Error purging CA %s: %v", ca, err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, i18n.MsgPurgeCAFailed)
+		return
+	}
+	if result.Certificates == 0 && result.CRLInfo == 0 {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, i18n.MsgCADataNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"signerflow-crl/apierror"
+	"signerflow-crl/cache"
+	"signerflow-crl/database"
+	"signerflow-crl/models"
+	"signerflow-crl/services"
+)
+
+// newCACertificate genera un certificado de CA autofirmado con el uso de clave indicado, en DER
+func newCACertificate(t *testing.T, commonName string, usage x509.KeyUsage) []byte {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              usage,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatalf("creating certificate: %v", err)
+	}
+	return der
+}
+
+func TestUploadAndListCAs(t *testing.T) {
+	store := database.NewMemoryStore()
+	service, _ := newTestService(t, store)
+	h := NewCAHandler(service, store)
+
+	der := newCACertificate(t, "Upload Test CA", x509.KeyUsageCertSign|x509.KeyUsageCRLSign)
+	pemData := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+	rec := serve(h.UploadCA, http.MethodPost, "/cas", "/cas", bytes.NewReader(pemData))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("upload PEM: got status %d, want 201: %s", rec.Code, rec.Body)
+	}
+	var uploaded models.CACertificate
+	if err := json.Unmarshal(rec.Body.Bytes(), &uploaded); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if uploaded.ID == 0 || uploaded.Subject != "CN=Upload Test CA" || uploaded.KeyID == "" {
+		t.Errorf("got uploaded CA %+v, want an ID, subject CN=Upload Test CA and a key ID", uploaded)
+	}
+
+	// El mismo certificado en DER ya está registrado
+	rec = serve(h.UploadCA, http.MethodPost, "/cas", "/cas", bytes.NewReader(der))
+	assertErrorCode(t, rec, http.StatusConflict, apierror.CodeAlreadyExists)
+
+	rejected := []struct {
+		name string
+		body []byte
+	}{
+		{"without CRLSign", newCACertificate(t, "No CRLSign CA", x509.KeyUsageCertSign)},
+		{"not a certificate", []byte("not a certificate")},
+	}
+	for _, tt := range rejected {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(h.UploadCA, http.MethodPost, "/cas", "/cas", bytes.NewReader(tt.body))
+			assertErrorCode(t, rec, http.StatusBadRequest, apierror.CodeInvalidCertificate)
+		})
+	}
+
+	var listed struct {
+		CertificateAuthorities []models.CACertificate `json:"certificate_authorities"`
+	}
+	rec = serve(h.ListCAs, http.MethodGet, "/cas", "/cas", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("list: got status %d, want 200", rec.Code)
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &listed); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if len(listed.CertificateAuthorities) != 1 || listed.CertificateAuthorities[0].KeyID != uploaded.KeyID {
+		t.Fatalf("got listed CAs %+v, want only the uploaded one", listed.CertificateAuthorities)
+	}
+
+	target := "/cas/" + strconv.Itoa(uploaded.ID)
+	if rec := serve(h.DeleteCA, http.MethodDelete, "/cas/:id", target, nil); rec.Code != http.StatusNoContent {
+		t.Errorf("delete: got status %d, want 204", rec.Code)
+	}
+	rec = serve(h.DeleteCA, http.MethodDelete, "/cas/:id", target, nil)
+	assertErrorCode(t, rec, http.StatusNotFound, apierror.CodeNotFound)
+}
+
+func TestPurgeCAData(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	redis, redisServer := newTestRedis(t, cache.BreakerConfig{})
+	service, _ := newCachedTestService(t, store, redis)
+	h := NewCAHandler(service, store)
+
+	revokedAt := time.Now().Add(-time.Hour)
+	seedRevoked(t, store,
+		&models.RevokedCertificate{Serial: "7001", RevocationDate: revokedAt, CertificateAuthority: "Retired CA"},
+		&models.RevokedCertificate{Serial: "7002", RevocationDate: revokedAt, CertificateAuthority: "Retired CA"},
+		&models.RevokedCertificate{Serial: "7003", RevocationDate: revokedAt, CertificateAuthority: "Active CA"},
+	)
+	if err := store.InsertCRLInfo(ctx, &models.CRLInfo{URL: "http://crl.example/retired.crl", Issuer: "Retired CA", LastProcessed: revokedAt, NextUpdate: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatalf("InsertCRLInfo: %v", err)
+	}
+
+	// Las consultas dejan el estado revocado en cache
+	for _, serial := range []string{"7001", "7003"} {
+		if status, err := service.CheckCertificateStatus(ctx, serial); err != nil || !status.IsRevoked {
+			t.Fatalf("CheckCertificateStatus(%s) = %+v, %v; want revoked", serial, status, err)
+		}
+		if _, ok := redisServer.Get("cert:" + serial); !ok {
+			t.Fatalf("serial %s was not cached", serial)
+		}
+	}
+
+	purge := func(target string) *httptest.ResponseRecorder {
+		return serve(h.PurgeCAData, http.MethodDelete, "/ca-data", target, nil)
+	}
+
+	rec := purge("/ca-data?ca=Retired+CA")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200: %s", rec.Code, rec.Body)
+	}
+	var result services.PurgeCAResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if result.CertificateAuthority != "Retired CA" || result.Certificates != 2 || result.CRLInfo != 1 {
+		t.Errorf("got result %+v, want 2 certificates and 1 CRL info row of Retired CA", result)
+	}
+
+	// Ni la base ni la cache conservan el estado de la CA purgada
+	if _, ok := redisServer.Get("cert:7001"); ok {
+		t.Error("cached status of a purged serial was not invalidated")
+	}
+	if status, err := service.CheckCertificateStatus(ctx, "7001"); err != nil || status.IsRevoked {
+		t.Errorf("purged serial: got %+v, %v; want not revoked", status, err)
+	}
+	if _, err := store.GetCRLInfo(ctx, "http://crl.example/retired.crl"); err == nil {
+		t.Error("CRL info of the purged CA is still stored")
+	}
+	if _, ok := redisServer.Get("cert:7003"); !ok {
+		t.Error("cached status of another CA was invalidated")
+	}
+
+	assertErrorCode(t, purge("/ca-data?ca=Retired+CA"), http.StatusNotFound, apierror.CodeNotFound)
+	assertErrorCode(t, purge("/ca-data"), http.StatusBadRequest, apierror.CodeMissingParameter)
+}
@@ -1,87 +1,505 @@
 package handlers
 
 import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"signerflow-crl/apierror"
 	"signerflow-crl/cache"
+	"signerflow-crl/config"
 	"signerflow-crl/database"
+	"signerflow-crl/i18n"
+	"signerflow-crl/middleware"
+	"signerflow-crl/models"
+	"signerflow-crl/requestid"
 	"signerflow-crl/services"
 )
 
 type CertificateHandler struct {
 	crlService *services.CRLService
-	db         *database.DB
+	db         database.CertStore
 	redis      *cache.RedisClient
+	cfg        *config.Config
+	// Firma las respuestas de CheckCertificate; nil deshabilita la firma
+	signer *services.ResponseSigner
+	// Valida los tokens de administración con ADMIN_AUTH_MODE=jwt; nil usa la API key
+	adminJWT *middleware.JWTVerifier
 }
 
-func NewCertificateHandler(crlService *services.CRLService, db *database.DB, redis *cache.RedisClient) *CertificateHandler {
+func NewCertificateHandler(crlService *services.CRLService, db database.CertStore, redis *cache.RedisClient, cfg *config.Config, signer *services.ResponseSigner, adminJWT *middleware.JWTVerifier) *CertificateHandler {
 	return &CertificateHandler{
 		crlService: crlService,
 		db:         db,
 		redis:      redis,
+		cfg:        cfg,
+		signer:     signer,
+		adminJWT:   adminJWT,
 	}
 }
 
 func (h *CertificateHandler) CheckCertificate(c *gin.Context) {
-	serial := c.Param("serial")
-	if serial == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Serial requerido",
-			"message": "Debe proporcionar el número de serie del certificado",
-		})
+	serial, ok := parseSerialParam(c)
+	if !ok {
 		return
 	}
 
-	serial = strings.ToUpper(strings.TrimSpace(serial))
-
 	if h.redis != nil {
 		h.redis.IncrementStats("stats:requests_total")
 	}
 
-	status, err := h.crlService.CheckCertificateStatus(serial)
+	// Con ca solo se considera el serial emitido por esa CA. Con as_of se responde el estado
+	// histórico, consultado siempre en la base de datos
+	var status *models.CertificateStatus
+	var err error
+	ca := strings.TrimSpace(c.Query("ca"))
+	if value := c.Query("as_of"); value != "" {
+		asOf, parseErr := parseDateParam(value)
+		if parseErr != nil {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidParameter, i18n.MsgInvalidAsOf)
+			return
+		}
+		status, err = h.crlService.CheckCertificateStatusAsOf(c.Request.Context(), serial, ca, asOf)
+	} else if c.Query("refresh") == "true" {
+		// Saltear el cache queda reservado a administradores para que no se pueda usar
+		// para cargar la base con consultas que nunca aciertan en Redis
+		if !middleware.IsAdmin(c, h.cfg.AdminAPIKey, h.adminJWT) {
+			apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, i18n.MsgUnauthorized)
+			return
+		}
+		status, err = h.crlService.RefreshCertificateStatus(c.Request.Context(), serial, ca)
+	} else {
+		status, err = h.crlService.CheckCertificateStatusForCA(c.Request.Context(), serial, ca)
+	}
+	if err != nil {
+		requestid.Logf(c.Request.Context(), "Error checking certificate %s: %v", serial, err)
+		respondCheckError(c, err)
+		return
+	}
+
+	contentType, data, err := encodeStatus(c, status)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Error interno del servidor",
-			"message": "Error al verificar el estado del certificado",
-		})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, i18n.MsgEncodeFailed)
 		return
 	}
 
-	c.JSON(http.StatusOK, status)
+	h.setStaleHeader(c, status)
+	if h.setCacheHeaders(c, status, data) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	h.respondSigned(c, http.StatusOK, contentType, data)
 }
-func (h *CertificateHandler) ValidCertificate(c *gin.Context) {
-	serial := c.Param("serial")
-	if serial == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Serial requerido",
-			"message": "Debe proporcionar el número de serie del certificado",
-		})
+
+// encodeStatus serializa el estado en el formato que pide el header Accept: JSON (por
+// defecto), XML o texto plano compacto. Agrega Vary: Accept para que los proxies guarden
+// cada formato por separado.
+func encodeStatus(c *gin.Context, status *models.CertificateStatus) (string, []byte, error) {
+	c.Writer.Header().Add("Vary", "Accept")
+
+	switch c.NegotiateFormat(gin.MIMEJSON, gin.MIMEXML, gin.MIMEXML2, gin.MIMEPlain) {
+	case gin.MIMEXML, gin.MIMEXML2:
+		data, err := xml.Marshal(status)
+		if err != nil {
+			return "", nil, err
+		}
+		return "application/xml; charset=utf-8", append([]byte(xml.Header), data...), nil
+	case gin.MIMEPlain:
+		return "text/plain; charset=utf-8", []byte(statusText(status)), nil
+	default:
+		// encoding/json serializa los structs en el orden de sus campos, por lo que el
+		// cuerpo es determinista y se puede firmar
+		data, err := json.Marshal(status)
+		if err != nil {
+			return "", nil, err
+		}
+		return "application/json; charset=utf-8", data, nil
+	}
+}
+
+// statusText es la forma compacta en texto plano: el serial y good o revoked, seguidos en
+// los revocados de la fecha de revocación y el código de motivo
+func statusText(status *models.CertificateStatus) string {
+	if !status.IsRevoked {
+		return status.Serial + " good\n"
+	}
+
+	fields := []string{status.Serial, "revoked"}
+	if status.RevocationDate != nil {
+		fields = append(fields, status.RevocationDate.UTC().Format(time.RFC3339))
+	}
+	if status.ReasonCode != nil {
+		fields = append(fields, strconv.Itoa(*status.ReasonCode))
+	}
+	return strings.Join(fields, " ") + "\n"
+}
+
+// databaseRetryAfter son los segundos sugeridos en Retry-After cuando PostgreSQL no responde
+const databaseRetryAfter = 5
+
+// respondCheckError responde el error de una verificación de estado. Si PostgreSQL no está
+// disponible y el estado no estaba en cache se responde 503 con Retry-After y el código
+// SERVICE_DEGRADED, para que el cliente reintente en lugar de tratarlo como un fallo.
+func respondCheckError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrDatabaseUnavailable) {
+		c.Header("Retry-After", strconv.Itoa(databaseRetryAfter))
+		c.Header("X-Service-Degraded", "true")
+		apierror.Respond(c, http.StatusServiceUnavailable, apierror.CodeDegraded, i18n.MsgDatabaseUnavailable)
+		return
+	}
+	apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, i18n.MsgCheckFailed)
+}
+
+// setCacheHeaders agrega Cache-Control según el TTL de cache del estado y un ETag derivado
+// del cuerpo de la respuesta. Devuelve true si If-None-Match coincide y corresponde responder 304.
+func (h *CertificateHandler) setCacheHeaders(c *gin.Context, status *models.CertificateStatus, data []byte) bool {
+	sum := sha256.Sum256(data)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	// Con la CRL de la CA vencida la respuesta puede estar desactualizada: los proxies
+	// deben revalidarla en cada uso
+	cacheControl := "no-cache"
+	if c.Writer.Header().Get("X-CRL-Stale") == "" {
+		ttl := h.cfg.CacheTTLValid
+		if status.IsRevoked {
+			ttl = h.cfg.CacheTTLRevoked
+		}
+		cacheControl = fmt.Sprintf("public, max-age=%d", int(ttl.Seconds()))
+	}
+
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", cacheControl)
+	return etagMatches(c.GetHeader("If-None-Match"), etag)
+}
+
+// etagMatches compara If-None-Match con el ETag usando la comparación débil de RFC 9110,
+// ya que la compresión gzip convierte el ETag en débil
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// respondSigned responde con el cuerpo ya serializado y, si la firma está habilitada, agrega
+// X-Signature con la firma de los bytes exactos del cuerpo y X-Signature-Key-ID con el key_id
+// de la clave
+func (h *CertificateHandler) respondSigned(c *gin.Context, code int, contentType string, data []byte) {
+	if h.signer == nil {
+		c.Data(code, contentType, data)
+		return
+	}
+
+	signature, err := h.signer.Sign(data)
+	if err != nil {
+		requestid.Logf(c.Request.Context(), "Error signing response: %v", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, i18n.MsgSignFailed)
+		return
+	}
+
+	c.Header("X-Signature", signature)
+	c.Header("X-Signature-Key-ID", h.signer.KeyID())
+	c.Data(code, contentType, data)
+}
+
+// GetPublicKey publica la clave con la que se verifican las respuestas firmadas
+func (h *CertificateHandler) GetPublicKey(c *gin.Context) {
+	if h.signer == nil {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeSigningDisabled, i18n.MsgSigningDisabled)
+		return
+	}
+
+	c.JSON(http.StatusOK, h.signer.PublicKey())
+}
+
+// parseSerialParam convierte el serial de la ruta a decimal según el parámetro format
+// (auto, decimal, hex o base64) y responde 400 si no es válido
+func parseSerialParam(c *gin.Context) (string, bool) {
+	raw := strings.TrimSpace(c.Param("serial"))
+	if raw == "" {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeSerialRequired, i18n.MsgSerialRequired)
+		return "", false
+	}
+
+	serial, err := services.ParseSerial(raw, c.Query("format"))
+	if errors.Is(err, services.ErrInvalidSerialFormat) {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidFormat, i18n.MsgInvalidSerialFormat)
+		return "", false
+	}
+	if err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidSerial, i18n.MsgInvalidSerial)
+		return "", false
+	}
+
+	return serial, true
+}
+
+// setStaleHeader agrega X-CRL-Stale cuando la CRL de la CA que respondió ya expiró
+func (h *CertificateHandler) setStaleHeader(c *gin.Context, status *models.CertificateStatus) {
+	if status.CertificateAuthority == nil {
 		return
 	}
+	if h.crlService.IsCAStale(*status.CertificateAuthority) {
+		c.Header("X-CRL-Stale", "true")
+	}
+}
 
-	serial = strings.ToUpper(strings.TrimSpace(serial))
+func (h *CertificateHandler) ValidCertificate(c *gin.Context) {
+	serial, ok := parseSerialParam(c)
+	if !ok {
+		return
+	}
 
 	if h.redis != nil {
 		h.redis.IncrementStats("stats:requests_total")
 	}
 
-	status, err := h.crlService.CheckCertificateStatus(serial)
+	// Con ca solo cuenta la revocación del serial emitido por esa CA
+	ca := strings.TrimSpace(c.Query("ca"))
+	status, err := h.crlService.CheckCertificateStatusForCA(c.Request.Context(), serial, ca)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Error interno del servidor",
-			"message": "Error al verificar el estado del certificado",
-		})
+		requestid.Logf(c.Request.Context(), "Error checking certificate %s: %v", serial, err)
+		respondCheckError(c, err)
 		return
 	}
+	h.setStaleHeader(c, status)
 	if status.IsRevoked {
+		c.Header(certStatusHeader, certStatusRevoked)
 		c.String(http.StatusOK, status.RevocationDate.Format(time.RFC3339))
-	} else {
-		c.String(http.StatusOK, "")
+		return
+	}
+
+	// Las CRLs solo listan revocados: un serial ausente es válido únicamente si la CRL de
+	// su CA (parámetro ca) ya fue procesada; sin ella el estado es desconocido
+	certStatus := certStatusUnknown
+	if ca != "" {
+		tracked, err := h.db.HasCRLForIssuer(c.Request.Context(), ca)
+		if err != nil {
+			requestid.Logf(c.Request.Context(), "Error checking CRL for issuer %s: %v", ca, err)
+		} else if tracked {
+			certStatus = certStatusGood
+		}
+	}
+
+	c.Header(certStatusHeader, certStatus)
+	c.String(http.StatusOK, "%s", h.cfg.ValidResponseText)
+}
+
+// Valores del header X-Cert-Status de /valid/{serial}
+const (
+	certStatusHeader  = "X-Cert-Status"
+	certStatusRevoked = "revoked"
+	certStatusGood    = "good"
+	certStatusUnknown = "unknown"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 500
+)
+
+func (h *CertificateHandler) ListCertificates(c *gin.Context) {
+	limit := defaultListLimit
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidParameter, i18n.MsgInvalidLimit)
+			return
+		}
+		limit = parsed
+	}
+	if limit > maxListLimit {
+		limit = maxListLimit
 	}
 
+	offset := 0
+	if value := c.Query("offset"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidParameter, i18n.MsgInvalidOffset)
+			return
+		}
+		offset = parsed
+	}
+
+	filter := models.CertificateFilter{
+		CertificateAuthority: strings.TrimSpace(c.Query("ca")),
+	}
+
+	// La presencia de cursor (aunque esté vacío) activa la paginación por cursor
+	if value, ok := c.GetQuery("cursor"); ok {
+		afterID, err := decodeCursor(value)
+		if err != nil {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidParameter, i18n.MsgInvalidCursor)
+			return
+		}
+		filter.AfterID = &afterID
+	}
+
+	if value := c.Query("reason"); value != "" {
+		reason, err := strconv.Atoi(value)
+		if err != nil {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidParameter, i18n.MsgInvalidReason)
+			return
+		}
+		filter.Reason = &reason
+	}
+
+	for _, param := range []struct {
+		name   string
+		target **time.Time
+	}{
+		{"from", &filter.From},
+		{"to", &filter.To},
+	} {
+		value := c.Query(param.name)
+		if value == "" {
+			continue
+		}
+		parsed, err := parseDateParam(value)
+		if err != nil {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidParameter, i18n.MsgInvalidDateParam, param.name)
+			return
+		}
+		*param.target = &parsed
+	}
+
+	items, total, err := h.db.ListRevokedCertificates(c.Request.Context(), filter, limit, offset)
+	if err != nil {
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, i18n.MsgListCertificatesFailed)
+		return
+	}
+
+	if filter.AfterID != nil {
+		// Una página incompleta indica que no quedan más filas
+		nextCursor := ""
+		if len(items) == limit {
+			nextCursor = encodeCursor(items[len(items)-1].ID)
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"items":       items,
+			"limit":       limit,
+			"next_cursor": nextCursor,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items":  items,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// encodeCursor genera el token opaco de paginación a partir del último id devuelto
+func encodeCursor(id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(id)))
+}
+
+// decodeCursor interpreta el token de encodeCursor; un cursor vacío corresponde a la primera página
+func decodeCursor(cursor string) (int, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	id, err := strconv.Atoi(string(raw))
+	if err != nil || id <= 0 {
+		return 0, errors.New("invalid cursor")
+	}
+	return id, nil
+}
+
+// maxRangeResults limita los certificados devueltos por una consulta de rango de seriales
+const maxRangeResults = 10000
+
+// ListCertificatesInRange devuelve los certificados revocados de una CA cuyo serial está
+// entre from y to, para acotar el impacto de un compromiso de clave de la CA
+func (h *CertificateHandler) ListCertificatesInRange(c *gin.Context) {
+	ca := strings.TrimSpace(c.Query("ca"))
+	if ca == "" {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeMissingParameter, i18n.MsgCARequired)
+		return
+	}
+
+	bounds := make([]*big.Int, 0, 2)
+	for _, name := range []string{"from", "to"} {
+		value := strings.TrimSpace(c.Query(name))
+		if value == "" {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeMissingParameter, i18n.MsgRangeRequired)
+			return
+		}
+
+		serial, err := services.ParseSerial(value, c.Query("format"))
+		if errors.Is(err, services.ErrInvalidSerialFormat) {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidFormat, i18n.MsgInvalidSerialFormat)
+			return
+		}
+		if err != nil {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidSerial, i18n.MsgInvalidRangeSerial, name)
+			return
+		}
+
+		n, _ := new(big.Int).SetString(serial, 10)
+		bounds = append(bounds, n)
+	}
+
+	from, to := bounds[0], bounds[1]
+	if from.Cmp(to) > 0 {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRange, i18n.MsgInvalidRange)
+		return
+	}
+
+	// Se pide un elemento extra para saber si el resultado quedó truncado
+	items, err := h.db.ListRevokedInRange(c.Request.Context(), ca, from.String(), to.String(), maxRangeResults+1)
+	if err != nil {
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, i18n.MsgRangeFailed)
+		return
+	}
+
+	truncated := len(items) > maxRangeResults
+	if truncated {
+		items = items[:maxRangeResults]
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"certificate_authority": ca,
+		"from":                  from.String(),
+		"to":                    to.String(),
+		"items":                 items,
+		"count":                 len(items),
+		"truncated":             truncated,
+	})
+}
+
+// parseDateParam acepta fechas RFC3339 o solo la fecha (YYYY-MM-DD)
+func parseDateParam(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
 }
 
 func (h *CertificateHandler) GetHealth(c *gin.Context) {
@@ -92,12 +510,81 @@ func (h *CertificateHandler) GetHealth(c *gin.Context) {
 	})
 }
 
+// healthCheckTimeout limita cada verificación de dependencias en el readiness
+const healthCheckTimeout = 2 * time.Second
+
+// GetLiveness indica únicamente que el proceso está en ejecución
+func (h *CertificateHandler) GetLiveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "alive",
+		"service": "signerflow-crl-service",
+	})
+}
+
+// GetReadiness verifica PostgreSQL, Redis (si está configurado) y la antigüedad del último procesamiento
+func (h *CertificateHandler) GetReadiness(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), healthCheckTimeout)
+	defer cancel()
+
+	checks := gin.H{}
+	ready := true
+
+	if err := h.db.PingContext(ctx); err != nil {
+		checks["database"] = gin.H{"status": "down", "error": err.Error()}
+		ready = false
+	} else {
+		checks["database"] = gin.H{"status": "up"}
+	}
+
+	if h.redis != nil {
+		if err := h.redis.Ping(ctx); err != nil {
+			checks["redis"] = gin.H{"status": "down", "error": err.Error(), "circuit": h.redis.BreakerState()}
+			ready = false
+		} else {
+			checks["redis"] = gin.H{"status": "up", "circuit": h.redis.BreakerState()}
+		}
+	}
+
+	status := "ready"
+	response := gin.H{
+		"service": "signerflow-crl-service",
+		"checks":  checks,
+	}
+
+	if ready {
+		lastProcessed, err := h.db.GetLastProcessed(ctx)
+		if err != nil {
+			checks["crl_processing"] = gin.H{"status": "unknown", "error": err.Error()}
+		} else {
+			age := time.Since(lastProcessed)
+			crlCheck := gin.H{
+				"status":         "up",
+				"last_processed": lastProcessed,
+			}
+			if age > h.cfg.HealthMaxCRLAge {
+				crlCheck["status"] = "stale"
+				status = "degraded"
+			}
+			checks["crl_processing"] = crlCheck
+		}
+	} else {
+		status = "not_ready"
+	}
+
+	response["status"] = status
+
+	if !ready {
+		c.JSON(http.StatusServiceUnavailable, response)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
 func (h *CertificateHandler) GetStats(c *gin.Context) {
-	dbStats, err := h.db.GetCRLStats()
+	dbStats, err := h.db.GetCRLStats(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Error obteniendo estadísticas de base de datos",
-		})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, i18n.MsgStatsFailed)
 		return
 	}
 
@@ -105,6 +592,13 @@ func (h *CertificateHandler) GetStats(c *gin.Context) {
 		"database": dbStats,
 	}
 
+	crls, err := h.db.ListCRLInfo(c.Request.Context())
+	if err != nil {
+		response["crls"] = gin.H{"error": "Error obteniendo información de CRLs"}
+	} else {
+		response["crls"] = crls
+	}
+
 	if h.redis != nil {
 		redisStats, err := h.redis.GetStats()
 		if err != nil {
@@ -112,59 +606,379 @@ func (h *CertificateHandler) GetStats(c *gin.Context) {
 		} else {
 			response["cache"] = redisStats
 		}
+		// Totales de la base con los que se corrigieron por última vez los contadores de cache
+		if reconciliation := h.crlService.LastStatsReconciliation(); reconciliation != nil {
+			response["reconciliation"] = reconciliation
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *CertificateHandler) ListCRLs(c *gin.Context) {
+	crls, err := h.db.ListCRLInfo(c.Request.Context())
+	if err != nil {
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, i18n.MsgListCRLsFailed)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"crls":  crls,
+		"total": len(crls),
+	})
+}
+
+func (h *CertificateHandler) GetCRLDetail(c *gin.Context) {
+	url := strings.TrimSpace(c.Query("url"))
+	if url == "" {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeMissingParameter, i18n.MsgCRLURLRequired)
+		return
+	}
+
+	info, err := h.db.GetCRLInfo(c.Request.Context(), url)
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, i18n.MsgCRLInfoNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, i18n.MsgCRLInfoFailed)
+		return
+	}
+
+	c.JSON(http.StatusOK, info)
+}
+
+func (h *CertificateHandler) GetStatsByCA(c *gin.Context) {
+	stats, err := h.db.GetStatsByCA(c.Request.Context(), strings.TrimSpace(c.Query("ca")))
+	if err != nil {
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, i18n.MsgCAStatsFailed)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"certificate_authorities": stats,
+	})
+}
+
+// throughputWindows es el periodo por defecto de cada tamaño de intervalo cuando no se indica since
+var throughputWindows = map[string]time.Duration{
+	"hour": 24 * time.Hour,
+	"day":  30 * 24 * time.Hour,
+}
+
+// GetThroughput devuelve los procesamientos de CRLs y los certificados importados por hora
+// o por día desde since
+func (h *CertificateHandler) GetThroughput(c *gin.Context) {
+	bucket := c.DefaultQuery("bucket", "hour")
+	window, ok := throughputWindows[bucket]
+	if !ok {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidParameter, i18n.MsgInvalidBucket)
+		return
+	}
+
+	since := time.Now().Add(-window)
+	if value := c.Query("since"); value != "" {
+		parsed, err := parseDateParam(value)
+		if err != nil {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidParameter, i18n.MsgInvalidDateParam, "since")
+			return
+		}
+		since = parsed
+	}
+
+	buckets, err := h.db.GetThroughput(c.Request.Context(), since, bucket)
+	if err != nil {
+		requestid.Logf(c.Request.Context(), "Error getting throughput: %v", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, i18n.MsgThroughputFailed)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"bucket":  bucket,
+		"since":   since,
+		"buckets": buckets,
+	})
+}
+
+// GetConsistency informa las comparaciones de las revocaciones importadas con los responders
+// OCSP de las CAs
+func (h *CertificateHandler) GetConsistency(c *gin.Context) {
+	c.JSON(http.StatusOK, h.crlService.ConsistencyReport())
+}
+
+func (h *CertificateHandler) GetReasonStats(c *gin.Context) {
+	ca := strings.TrimSpace(c.Query("ca"))
+
+	breakdown, err := h.db.GetReasonBreakdown(c.Request.Context(), ca)
+	if err != nil {
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, i18n.MsgReasonStatsFailed)
+		return
+	}
+
+	total := 0
+	for _, reason := range breakdown {
+		total += reason.Count
+	}
+
+	response := gin.H{
+		"reasons": breakdown,
+		"total":   total,
+	}
+	if ca != "" {
+		response["certificate_authority"] = ca
 	}
 
 	c.JSON(http.StatusOK, response)
 }
 
+// GetReasons devuelve el texto de cada código de motivo de revocación en el idioma de
+// lang (es o en; por defecto el de Accept-Language), para que los clientes no tengan que fijarlos
+func (h *CertificateHandler) GetReasons(c *gin.Context) {
+	lang := strings.ToLower(strings.TrimSpace(c.DefaultQuery("lang", i18n.Lang(c.GetHeader("Accept-Language")))))
+	reasons, ok := models.RevocationReasonsByLang(lang)
+	if !ok {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidParameter, i18n.MsgInvalidLang)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"lang":    lang,
+		"reasons": reasons,
+	})
+}
+
 func (h *CertificateHandler) ForceRefresh(c *gin.Context) {
 	crlURLsFile := c.Query("file")
 	if crlURLsFile == "" {
 		crlURLsFile = "crl_urls.json"
 	}
 
-	go func() {
-		err := h.crlService.ProcessAllCRLs(crlURLsFile)
-		if err != nil {
-			// Log error but don't block the response
-			// In a production environment, you might want to use proper logging
-			println("Error en procesamiento manual de CRLs:", err.Error())
-		}
-	}()
+	// El procesamiento sigue después de responder, por lo que no se cancela con la petición
+	err := h.crlService.StartRefresh(context.WithoutCancel(c.Request.Context()), crlURLsFile)
+	if errors.Is(err, services.ErrRefreshInProgress) {
+		apierror.Respond(c, http.StatusConflict, apierror.CodeRefreshInProgress, i18n.MsgRefreshInProgress)
+		return
+	}
 
 	c.JSON(http.StatusAccepted, gin.H{
-		"message": "Actualización de CRLs iniciada en segundo plano",
+		"message": i18n.T(c, i18n.MsgRefreshStarted),
 		"status":  "processing",
 	})
 }
 
-func (h *CertificateHandler) GetCertificateDetails(c *gin.Context) {
-	serial := c.Param("serial")
-	if serial == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Serial requerido",
-			"message": "Debe proporcionar el número de serie del certificado",
-		})
+// DryRunCRL valida una CRL (descarga, parseo y firma) sin importarla, para revisar una
+// fuente antes de agregarla
+func (h *CertificateHandler) DryRunCRL(c *gin.Context) {
+	crlURL := strings.TrimSpace(c.Query("url"))
+	if !isValidSourceURL(crlURL) {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidURL, i18n.MsgInvalidCRLURL)
 		return
 	}
 
-	serial = strings.ToUpper(strings.TrimSpace(serial))
+	result, err := h.crlService.DryRunCRL(c.Request.Context(), crlURL)
+	if err != nil {
+		apierror.Respond(c, http.StatusUnprocessableEntity, apierror.CodeInvalidCRL, i18n.MsgInvalidCRL, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
 
-	status, err := h.db.GetCertificateStatus(serial)
+// TestSource comprueba que una URL responde y publica una CRL pidiendo solo sus primeros
+// bytes, sin descargarla ni guardarla
+func (h *CertificateHandler) TestSource(c *gin.Context) {
+	crlURL := strings.TrimSpace(c.Query("url"))
+	if !isValidSourceURL(crlURL) {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidURL, i18n.MsgInvalidCRLURL)
+		return
+	}
+
+	result, err := h.crlService.ProbeSource(c.Request.Context(), crlURL)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Error interno del servidor",
-			"message": "Error al obtener detalles del certificado",
-		})
+		if errors.Is(err, services.ErrProbeUnsupportedScheme) {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidURL, i18n.MsgProbeURLNotHTTP)
+			return
+		}
+		// El único otro fallo posible es la configuración TLS propia de la fuente
+		apierror.Respond(c, http.StatusUnprocessableEntity, apierror.CodeInvalidTLSConfig, i18n.MsgInvalidTLSConfig, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+const (
+	maxWarmCacheCount   = 100000
+	maxWarmCacheSerials = 10000
+)
+
+type warmCacheRequest struct {
+	Serials []string `json:"serials"`
+	Format  string   `json:"format"`
+}
+
+// WarmCache precarga en Redis los seriales enviados en el cuerpo o, sin cuerpo, los
+// certificados revocados más recientes (count, por defecto WARM_CACHE_COUNT)
+func (h *CertificateHandler) WarmCache(c *gin.Context) {
+	count := h.cfg.WarmCacheCount
+	if value := c.Query("count"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidParameter, i18n.MsgInvalidCount)
+			return
+		}
+		count = parsed
+	}
+	if count > maxWarmCacheCount {
+		count = maxWarmCacheCount
+	}
+
+	var req warmCacheRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, i18n.MsgInvalidWarmCacheBody)
+			return
+		}
+	}
+	if len(req.Serials) > maxWarmCacheSerials {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, i18n.MsgTooManySerials)
+		return
+	}
+
+	serials := make([]string, 0, len(req.Serials))
+	for _, raw := range req.Serials {
+		serial, err := services.ParseSerial(raw, req.Format)
+		if errors.Is(err, services.ErrInvalidSerialFormat) {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidFormat, i18n.MsgInvalidSerialFormatField)
+			return
+		}
+		if err != nil {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidSerial, i18n.MsgInvalidSerialValue, raw)
+			return
+		}
+		serials = append(serials, serial)
+	}
+
+	result, err := h.crlService.WarmCache(c.Request.Context(), serials, count)
+	if errors.Is(err, services.ErrCacheDisabled) {
+		apierror.Respond(c, http.StatusServiceUnavailable, apierror.CodeCacheDisabled, i18n.MsgCacheDisabled)
+		return
+	}
+	if err != nil {
+		requestid.Logf(c.Request.Context(), "Error warming cache: %v", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, i18n.MsgWarmCacheFailed)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// maxCertificateUploadSize limita el tamaño de los certificados enviados en el cuerpo
+const maxCertificateUploadSize = 64 * 1024
+
+func (h *CertificateHandler) CheckFingerprint(c *gin.Context) {
+	fingerprint := services.NormalizeFingerprint(c.Param("sha256"))
+	if fingerprint == "" {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidFingerprint, i18n.MsgInvalidFingerprint)
+		return
+	}
+
+	if h.redis != nil {
+		h.redis.IncrementStats("stats:requests_total")
+	}
+
+	status, err := h.crlService.CheckCertificateByFingerprint(c.Request.Context(), fingerprint)
+	if err != nil {
+		requestid.Logf(c.Request.Context(), "Error checking fingerprint %s: %v", fingerprint, err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, i18n.MsgCheckFailed)
+		return
+	}
+
+	if status == nil {
+		// Las CRLs no contienen certificados completos, solo se conocen huellas de certificados enviados
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, i18n.MsgFingerprintNotFound)
+		return
+	}
+
+	h.setStaleHeader(c, status)
+	c.JSON(http.StatusOK, status)
+}
+
+// CheckFingerprintUpload recibe el certificado en DER o PEM, calcula serial y huella y verifica su estado
+func (h *CertificateHandler) CheckFingerprintUpload(c *gin.Context) {
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, maxCertificateUploadSize))
+	if err != nil || len(data) == 0 {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeCertificateRequired, i18n.MsgCertificateRequired)
+		return
+	}
+
+	if h.redis != nil {
+		h.redis.IncrementStats("stats:requests_total")
+	}
+
+	status, err := h.crlService.CheckCertificateData(c.Request.Context(), data)
+	if errors.Is(err, services.ErrInvalidCertificate) {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidCertificate, i18n.MsgInvalidCertificate)
+		return
+	}
+	if err != nil {
+		requestid.Logf(c.Request.Context(), "Error checking uploaded certificate: %v", err)
+		respondCheckError(c, err)
+		return
+	}
+
+	h.setStaleHeader(c, status)
+	c.JSON(http.StatusOK, status)
+}
+
+// VerifyCertificate recibe el certificado en DER o PEM y devuelve su estado de revocación y vigencia
+func (h *CertificateHandler) VerifyCertificate(c *gin.Context) {
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, maxCertificateUploadSize))
+	if err != nil || len(data) == 0 {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeCertificateRequired, i18n.MsgCertificateRequired)
+		return
+	}
+
+	if h.redis != nil {
+		h.redis.IncrementStats("stats:requests_total")
+	}
+
+	verification, err := h.crlService.VerifyCertificate(c.Request.Context(), data)
+	if errors.Is(err, services.ErrInvalidCertificate) {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidCertificate, i18n.MsgInvalidCertificate)
+		return
+	}
+	if err != nil {
+		requestid.Logf(c.Request.Context(), "Error verifying uploaded certificate: %v", err)
+		respondCheckError(c, err)
+		return
+	}
+
+	h.setStaleHeader(c, &verification.CertificateStatus)
+	c.JSON(http.StatusOK, verification)
+}
+
+func (h *CertificateHandler) GetCertificateDetails(c *gin.Context) {
+	serial, ok := parseSerialParam(c)
+	if !ok {
+		return
+	}
+
+	// Sin ca se muestra la revocación más reciente del serial entre todas las CAs
+	var status *models.CertificateStatus
+	var err error
+	if ca := strings.TrimSpace(c.Query("ca")); ca != "" {
+		status, err = h.db.GetCertificateStatusByCA(c.Request.Context(), serial, ca)
+	} else {
+		status, err = h.db.GetCertificateStatus(c.Request.Context(), serial)
+	}
+	if err != nil {
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, i18n.MsgDetailsFailed)
 		return
 	}
 
 	if !status.IsRevoked {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error":   "Certificado no encontrado",
-			"message": "El certificado no está en la lista de revocación",
-			"serial":  serial,
-		})
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, i18n.MsgNotRevoked)
 		return
 	}
 
@@ -0,0 +1,1301 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"encoding/xml"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"signerflow-crl/apierror"
+	"signerflow-crl/cache"
+	"signerflow-crl/config"
+	"signerflow-crl/database"
+	"signerflow-crl/models"
+	"signerflow-crl/services"
+)
+
+func TestHealthLivenessAndReadiness(t *testing.T) {
+	tests := []struct {
+		name       string
+		store      database.CertStore
+		wantStatus int
+		want       string
+		wantDB     string
+	}{
+		{"database up", database.NewMemoryStore(), http.StatusOK, "degraded", "up"},
+		{"database down", unavailableStore{database.NewMemoryStore()}, http.StatusServiceUnavailable, "not_ready", "down"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandler(t, tt.store)
+
+			// El proceso sigue vivo aunque la base no responda
+			rec := serve(h.GetLiveness, http.MethodGet, "/health/live", "/health/live", nil)
+			if rec.Code != http.StatusOK {
+				t.Errorf("liveness: got status %d, want 200", rec.Code)
+			}
+
+			rec = serve(h.GetReadiness, http.MethodGet, "/health/ready", "/health/ready", nil)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("readiness: got status %d, want %d", rec.Code, tt.wantStatus)
+			}
+			var body struct {
+				Status string `json:"status"`
+				Checks struct {
+					Database struct {
+						Status string `json:"status"`
+					} `json:"database"`
+				} `json:"checks"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decoding readiness response: %v", err)
+			}
+			// Sin ninguna CRL procesada el servicio está listo pero degradado
+			if body.Status != tt.want || body.Checks.Database.Status != tt.wantDB {
+				t.Errorf("readiness: got status %q with database %q, want %q with %q", body.Status, body.Checks.Database.Status, tt.want, tt.wantDB)
+			}
+		})
+	}
+}
+
+func TestGetStatsByCA(t *testing.T) {
+	store := database.NewMemoryStore()
+	revokedAt := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	seedRevoked(t, store,
+		&models.RevokedCertificate{Serial: "1", RevocationDate: revokedAt, CertificateAuthority: "CA One", IssuerDN: "CN=CA One"},
+		&models.RevokedCertificate{Serial: "2", RevocationDate: revokedAt, CertificateAuthority: "CA One", IssuerDN: "CN=CA One"},
+		&models.RevokedCertificate{Serial: "3", RevocationDate: revokedAt, CertificateAuthority: "CA Two", IssuerDN: "CN=CA Two"},
+	)
+	h := newTestHandler(t, store)
+
+	var body struct {
+		CertificateAuthorities []models.CAStats `json:"certificate_authorities"`
+	}
+	rec := serve(h.GetStatsByCA, http.MethodGet, "/stats/by-ca", "/stats/by-ca", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200", rec.Code)
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	got := make(map[string]int)
+	for _, stats := range body.CertificateAuthorities {
+		got[stats.CertificateAuthority] = stats.RevokedCount
+	}
+	if len(got) != 2 || got["CA One"] != 2 || got["CA Two"] != 1 {
+		t.Errorf("got revoked counts %v, want CA One: 2 and CA Two: 1", got)
+	}
+
+	rec = serve(h.GetStatsByCA, http.MethodGet, "/stats/by-ca", "/stats/by-ca?ca=CA+Two", nil)
+	body.CertificateAuthorities = nil
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if len(body.CertificateAuthorities) != 1 || body.CertificateAuthorities[0].CertificateAuthority != "CA Two" {
+		t.Errorf("got %+v, want only CA Two", body.CertificateAuthorities)
+	}
+}
+
+func TestGetStatsIncludesReconciliation(t *testing.T) {
+	store := database.NewMemoryStore()
+	seedRevoked(t, store,
+		&models.RevokedCertificate{Serial: "1", RevocationDate: time.Now(), CertificateAuthority: "CA One", IssuerDN: "CN=CA One"},
+		&models.RevokedCertificate{Serial: "2", RevocationDate: time.Now(), CertificateAuthority: "CA One", IssuerDN: "CN=CA One"},
+	)
+	redis, srv := newTestRedis(t, cache.BreakerConfig{})
+	h := newCachedTestHandler(t, store, redis)
+	srv.Set("stats:revoked_total", "7")
+
+	var body struct {
+		Cache          map[string]any                `json:"cache"`
+		Reconciliation *services.StatsReconciliation `json:"reconciliation"`
+	}
+	decode := func() {
+		t.Helper()
+		rec := serve(h.GetStats, http.MethodGet, "/stats", "/stats", nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("got status %d, want 200", rec.Code)
+		}
+		body.Cache, body.Reconciliation = nil, nil
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		}
+	}
+
+	// Antes de reconciliar solo se publican los contadores en vivo
+	decode()
+	if body.Reconciliation != nil {
+		t.Errorf("got reconciliation %+v before any reconciliation ran", body.Reconciliation)
+	}
+	if body.Cache["stats:revoked_total"] != "7" {
+		t.Errorf("got live revoked_total %v, want 7", body.Cache["stats:revoked_total"])
+	}
+
+	if _, err := h.crlService.ReconcileStats(context.Background()); err != nil {
+		t.Fatalf("ReconcileStats: %v", err)
+	}
+	decode()
+	if body.Cache["stats:revoked_total"] != "2" {
+		t.Errorf("got live revoked_total %v after reconciling, want 2", body.Cache["stats:revoked_total"])
+	}
+	if body.Reconciliation == nil || body.Reconciliation.Database["stats:revoked_total"] != 2 || body.Reconciliation.Drift["stats:revoked_total"] != 5 {
+		t.Errorf("got reconciliation %+v, want 2 revoked certificates in the database with a drift of 5", body.Reconciliation)
+	}
+}
+
+func TestGetConsistency(t *testing.T) {
+	h := newTestHandler(t, database.NewMemoryStore(), func(cfg *config.Config) {
+		cfg.OCSPProbeCron = "@hourly"
+	})
+
+	rec := serve(h.GetConsistency, http.MethodGet, "/stats/consistency", "/stats/consistency", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200", rec.Code)
+	}
+	var report services.ConsistencyReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	// Antes de la primera ejecución el informe está vacío
+	if !report.Enabled || report.LastRun != nil || report.Totals != (services.ConsistencyCounts{}) || len(report.RecentMismatches) != 0 {
+		t.Errorf("got report %+v, want an enabled probe without runs", report)
+	}
+}
+
+func TestCheckCertificateStaleHeader(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	revokedAt := time.Now().Add(-48 * time.Hour).UTC().Truncate(time.Second)
+	seedRevoked(t, store,
+		&models.RevokedCertificate{Serial: "100", RevocationDate: revokedAt, CertificateAuthority: "Stale CA"},
+		&models.RevokedCertificate{Serial: "200", RevocationDate: revokedAt, CertificateAuthority: "Fresh CA"},
+	)
+	for _, info := range []*models.CRLInfo{
+		{URL: "http://crl.example/stale.crl", Issuer: "Stale CA", NextUpdate: time.Now().Add(-time.Hour).UTC(), LastProcessed: revokedAt},
+		{URL: "http://crl.example/fresh.crl", Issuer: "Fresh CA", NextUpdate: time.Now().Add(time.Hour).UTC(), LastProcessed: revokedAt},
+	} {
+		if err := store.InsertCRLInfo(ctx, info); err != nil {
+			t.Fatalf("InsertCRLInfo: %v", err)
+		}
+	}
+	h := newTestHandler(t, store)
+	h.crlService.WarnStaleCRLs(ctx)
+
+	for serial, want := range map[string]string{"100": "true", "200": ""} {
+		rec := serve(h.CheckCertificate, http.MethodGet, "/check/:serial", "/check/"+serial, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("serial %s: got status %d, want 200", serial, rec.Code)
+		}
+		if got := rec.Header().Get("X-CRL-Stale"); got != want {
+			t.Errorf("serial %s: got X-CRL-Stale %q, want %q", serial, got, want)
+		}
+		// Una respuesta posiblemente desactualizada no se guarda en los proxies sin revalidar
+		if cacheControl := rec.Header().Get("Cache-Control"); (cacheControl == "no-cache") != (want == "true") {
+			t.Errorf("serial %s: got Cache-Control %q with X-CRL-Stale %q", serial, cacheControl, want)
+		}
+	}
+}
+
+func TestCheckCertificateIncludesInvalidityDate(t *testing.T) {
+	store := database.NewMemoryStore()
+	revokedAt := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	compromisedAt := time.Date(2024, 5, 20, 8, 30, 0, 0, time.UTC)
+	seedRevoked(t, store,
+		&models.RevokedCertificate{Serial: "610", RevocationDate: revokedAt, Reason: models.ReasonKeyCompromise, CertificateAuthority: "Invalidity CA", InvalidityDate: &compromisedAt},
+		&models.RevokedCertificate{Serial: "611", RevocationDate: revokedAt, Reason: models.ReasonKeyCompromise, CertificateAuthority: "Invalidity CA"},
+	)
+	h := newTestHandler(t, store)
+
+	for serial, want := range map[string]string{"610": compromisedAt.Format(time.RFC3339), "611": ""} {
+		rec := serve(h.CheckCertificate, http.MethodGet, "/check/:serial", "/check/"+serial, nil)
+		var body map[string]any
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("serial %s: decoding response: %v", serial, err)
+		}
+		// Sin la extensión el campo no aparece en la respuesta
+		got, ok := body["invalidity_date"]
+		if (want == "" && ok) || (want != "" && got != want) {
+			t.Errorf("serial %s: got invalidity_date %v, want %q", serial, got, want)
+		}
+	}
+}
+
+func TestVerifyCertificate(t *testing.T) {
+	store := database.NewMemoryStore()
+	now := time.Now()
+	seedRevoked(t, store, &models.RevokedCertificate{
+		Serial: "303", RevocationDate: now.Add(-time.Hour).UTC(), Reason: models.ReasonKeyCompromise, CertificateAuthority: testIssuerName,
+	})
+	h := newTestHandler(t, store)
+
+	valid := newTestCertificate(t, 301, now.Add(-time.Hour), now.Add(24*time.Hour))
+	expired := newTestCertificate(t, 302, now.Add(-48*time.Hour), now.Add(-24*time.Hour))
+	revokedCert := newTestCertificate(t, 303, now.Add(-time.Hour), now.Add(24*time.Hour))
+
+	tests := []struct {
+		name        string
+		body        []byte
+		wantRevoked bool
+		wantExpired bool
+	}{
+		{"valid", valid, false, false},
+		{"expired", expired, false, true},
+		{"revoked PEM", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: revokedCert}), true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(h.VerifyCertificate, http.MethodPost, "/verify", "/verify", bytes.NewReader(tt.body))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("got status %d, want 200: %s", rec.Code, rec.Body)
+			}
+			var verification models.CertificateVerification
+			if err := json.Unmarshal(rec.Body.Bytes(), &verification); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			if verification.IsRevoked != tt.wantRevoked || verification.Expired != tt.wantExpired {
+				t.Errorf("got revoked=%v expired=%v, want revoked=%v expired=%v",
+					verification.IsRevoked, verification.Expired, tt.wantRevoked, tt.wantExpired)
+			}
+			if verification.Fingerprint == nil {
+				t.Error("response has no fingerprint")
+			}
+		})
+	}
+
+	rec := serve(h.VerifyCertificate, http.MethodPost, "/verify", "/verify", strings.NewReader("not a certificate"))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid certificate: got status %d, want 400", rec.Code)
+	}
+}
+
+func TestListAndDetailCRLs(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	nextUpdate := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+	for _, info := range []*models.CRLInfo{
+		{URL: "http://crl.example/one.crl", Issuer: "CA One", NextUpdate: nextUpdate, CertCount: 3, CRLNumber: "7"},
+		{URL: "http://crl.example/two.crl", Issuer: "CA Two", NextUpdate: nextUpdate, CertCount: 1, CRLNumber: "12"},
+	} {
+		if err := store.InsertCRLInfo(ctx, info); err != nil {
+			t.Fatalf("InsertCRLInfo: %v", err)
+		}
+	}
+	h := newTestHandler(t, store)
+
+	var listed struct {
+		CRLs  []models.CRLInfo `json:"crls"`
+		Total int              `json:"total"`
+	}
+	rec := serve(h.ListCRLs, http.MethodGet, "/crls", "/crls", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("list: got status %d, want 200", rec.Code)
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &listed); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if listed.Total != 2 || len(listed.CRLs) != 2 {
+		t.Fatalf("got %d CRLs (total %d), want 2", len(listed.CRLs), listed.Total)
+	}
+
+	var detail models.CRLInfo
+	rec = serve(h.GetCRLDetail, http.MethodGet, "/crls/detail", "/crls/detail?url="+url.QueryEscape("http://crl.example/two.crl"), nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("detail: got status %d, want 200", rec.Code)
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &detail); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if detail.Issuer != "CA Two" || detail.CertCount != 1 || detail.CRLNumber != "12" || !detail.NextUpdate.Equal(nextUpdate) {
+		t.Errorf("got detail %+v, want CA Two with 1 certificate and CRL number 12", detail)
+	}
+
+	rec = serve(h.GetCRLDetail, http.MethodGet, "/crls/detail", "/crls/detail?url="+url.QueryEscape("http://crl.example/unknown.crl"), nil)
+	assertErrorCode(t, rec, http.StatusNotFound, apierror.CodeNotFound)
+
+	rec = serve(h.GetCRLDetail, http.MethodGet, "/crls/detail", "/crls/detail", nil)
+	assertErrorCode(t, rec, http.StatusBadRequest, apierror.CodeMissingParameter)
+}
+
+func TestForceRefreshRejectsConcurrentRuns(t *testing.T) {
+	release := make(chan struct{})
+	var downloads atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// La primera descarga queda retenida para mantener el refresco en curso
+		downloads.Add(1)
+		<-release
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	urlsFile := filepath.Join(t.TempDir(), "crl_urls.json")
+	if err := os.WriteFile(urlsFile, []byte(`["`+srv.URL+`/ca.crl"]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	h := newTestHandler(t, database.NewMemoryStore(), func(cfg *config.Config) {
+		cfg.CRLPerHostRate = 0
+	})
+	target := "/admin/refresh?file=" + url.QueryEscape(urlsFile)
+
+	const callers = 10
+	codes := make(chan int, callers)
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- serve(h.ForceRefresh, http.MethodPost, "/admin/refresh", target, nil).Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	accepted, conflicts := 0, 0
+	for code := range codes {
+		switch code {
+		case http.StatusAccepted:
+			accepted++
+		case http.StatusConflict:
+			conflicts++
+		default:
+			t.Errorf("got status %d, want 202 or 409", code)
+		}
+	}
+	if accepted != 1 || conflicts != callers-1 {
+		t.Errorf("got %d accepted and %d conflicts, want 1 and %d", accepted, conflicts, callers-1)
+	}
+
+	rec := serve(h.ForceRefresh, http.MethodPost, "/admin/refresh", target, nil)
+	assertErrorCode(t, rec, http.StatusConflict, apierror.CodeRefreshInProgress)
+	if got := downloads.Load(); got > 1 {
+		t.Errorf("CRL downloaded %d times while one refresh was running, want at most 1", got)
+	}
+
+	// Terminado el refresco en curso se acepta uno nuevo
+	close(release)
+	deadline := time.Now().Add(2 * time.Second)
+	for serve(h.ForceRefresh, http.MethodPost, "/admin/refresh", target, nil).Code != http.StatusAccepted {
+		if time.Now().After(deadline) {
+			t.Fatal("refresh lock was not released after the run finished")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestValidCertificateDistinguishesUnknownSerials(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	revokedAt := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	seedRevoked(t, store, &models.RevokedCertificate{Serial: "1", RevocationDate: revokedAt, CertificateAuthority: "Known CA"})
+	if err := store.InsertCRLInfo(ctx, &models.CRLInfo{URL: "http://crl.example/known.crl", Issuer: "Known CA", NextUpdate: time.Now().Add(time.Hour), CertCount: 1}); err != nil {
+		t.Fatalf("InsertCRLInfo: %v", err)
+	}
+	h := newTestHandler(t, store)
+
+	tests := []struct {
+		name       string
+		target     string
+		wantStatus string
+		wantBody   string
+	}{
+		{"revoked", "/valid/1?ca=" + url.QueryEscape("Known CA"), certStatusRevoked, revokedAt.Format(time.RFC3339)},
+		{"known valid", "/valid/2?ca=" + url.QueryEscape("Known CA"), certStatusGood, h.cfg.ValidResponseText},
+		{"CA without CRL", "/valid/2?ca=" + url.QueryEscape("Other CA"), certStatusUnknown, h.cfg.ValidResponseText},
+		{"without CA", "/valid/2", certStatusUnknown, h.cfg.ValidResponseText},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(h.ValidCertificate, http.MethodGet, "/valid/:serial", tt.target, nil)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("got status %d, want 200", rec.Code)
+			}
+			if got := rec.Header().Get(certStatusHeader); got != tt.wantStatus {
+				t.Errorf("got %s %q, want %q", certStatusHeader, got, tt.wantStatus)
+			}
+			if rec.Body.String() != tt.wantBody {
+				t.Errorf("got body %q, want %q", rec.Body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestValidCertificateResponseText(t *testing.T) {
+	store := database.NewMemoryStore()
+	revokedAt := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	seedRevoked(t, store, &models.RevokedCertificate{Serial: "1", RevocationDate: revokedAt, CertificateAuthority: "Known CA"})
+
+	tests := []struct {
+		name      string
+		configure func(*config.Config)
+		wantBody  string
+	}{
+		// Sin VALID_RESPONSE_TEXT se mantiene el cuerpo vacío de siempre
+		{"default", func(*config.Config) {}, ""},
+		{"configured", func(cfg *config.Config) { cfg.ValidResponseText = "VALID" }, "VALID"},
+		{"literal percent", func(cfg *config.Config) { cfg.ValidResponseText = "100% VALID" }, "100% VALID"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandler(t, store, tt.configure)
+
+			rec := serve(h.ValidCertificate, http.MethodGet, "/valid/:serial", "/valid/2", nil)
+			if rec.Code != http.StatusOK || rec.Body.String() != tt.wantBody {
+				t.Errorf("not revoked: got status %d body %q, want 200 %q", rec.Code, rec.Body, tt.wantBody)
+			}
+			// La fecha de revocación no cambia con el texto configurado
+			rec = serve(h.ValidCertificate, http.MethodGet, "/valid/:serial", "/valid/1", nil)
+			if rec.Body.String() != revokedAt.Format(time.RFC3339) {
+				t.Errorf("revoked: got body %q, want %q", rec.Body, revokedAt.Format(time.RFC3339))
+			}
+		})
+	}
+}
+
+func TestSignedResponsesVerifyWithPublishedKey(t *testing.T) {
+	store := database.NewMemoryStore()
+	seedRevoked(t, store, &models.RevokedCertificate{Serial: "1", RevocationDate: time.Now().Add(-time.Hour), CertificateAuthority: "Signing CA"})
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, key := range map[string]crypto.Signer{"ECDSA": ecKey, "Ed25519": edKey} {
+		t.Run(name, func(t *testing.T) {
+			der, err := x509.MarshalPKCS8PrivateKey(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			keyFile := filepath.Join(t.TempDir(), "signing.key")
+			if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			signer, err := services.NewResponseSigner(keyFile)
+			if err != nil {
+				t.Fatalf("NewResponseSigner: %v", err)
+			}
+			service, cfg := newTestService(t, store)
+			h := NewCertificateHandler(service, store, nil, cfg, signer, nil)
+
+			// El cliente obtiene la clave publicada y verifica con ella cada respuesta
+			var published services.SignerPublicKey
+			rec := serve(h.GetPublicKey, http.MethodGet, "/pubkey", "/pubkey", nil)
+			if err := json.Unmarshal(rec.Body.Bytes(), &published); err != nil {
+				t.Fatalf("decoding public key: %v", err)
+			}
+			block, _ := pem.Decode([]byte(published.PublicKey))
+			if block == nil {
+				t.Fatalf("published public key is not PEM: %q", published.PublicKey)
+			}
+			publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+			if err != nil {
+				t.Fatalf("parsing public key: %v", err)
+			}
+
+			for _, serial := range []string{"1", "2"} {
+				rec := serve(h.CheckCertificate, http.MethodGet, "/check/:serial", "/check/"+serial, nil)
+				if rec.Code != http.StatusOK {
+					t.Fatalf("serial %s: got status %d, want 200", serial, rec.Code)
+				}
+				if got := rec.Header().Get("X-Signature-Key-ID"); got != published.KeyID {
+					t.Errorf("serial %s: got key ID %q, want %q", serial, got, published.KeyID)
+				}
+				signature, err := base64.StdEncoding.DecodeString(rec.Header().Get("X-Signature"))
+				if err != nil {
+					t.Fatalf("serial %s: decoding signature: %v", serial, err)
+				}
+
+				if !verifyResponseSignature(t, publicKey, rec.Body.Bytes(), signature) {
+					t.Errorf("serial %s: signature does not verify against the published key", serial)
+				}
+
+				// Un cuerpo alterado no verifica
+				tampered := bytes.Replace(rec.Body.Bytes(), []byte(`"serial"`), []byte(`"Serial"`), 1)
+				if verifyResponseSignature(t, publicKey, tampered, signature) {
+					t.Errorf("serial %s: signature verifies a tampered body", serial)
+				}
+			}
+		})
+	}
+
+	rec := serve(newTestHandler(t, store).GetPublicKey, http.MethodGet, "/pubkey", "/pubkey", nil)
+	assertErrorCode(t, rec, http.StatusNotFound, apierror.CodeSigningDisabled)
+}
+
+// verifyResponseSignature verifica la firma como lo haría un cliente: Ed25519 sobre el cuerpo
+// o ECDSA sobre su SHA-256
+func verifyResponseSignature(t *testing.T, publicKey crypto.PublicKey, body, signature []byte) bool {
+	t.Helper()
+
+	switch publicKey := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(body)
+		return ecdsa.VerifyASN1(publicKey, digest[:], signature)
+	case ed25519.PublicKey:
+		return ed25519.Verify(publicKey, body, signature)
+	default:
+		t.Fatalf("unexpected public key type %T", publicKey)
+		return false
+	}
+}
+
+func TestReadinessReportsRedisCircuit(t *testing.T) {
+	redis, redisServer := newTestRedis(t, cache.BreakerConfig{Threshold: 1, Cooldown: time.Minute})
+	h := newCachedTestHandler(t, database.NewMemoryStore(), redis)
+
+	readiness := func() (int, string, string) {
+		var body struct {
+			Checks struct {
+				Redis struct {
+					Status  string `json:"status"`
+					Circuit string `json:"circuit"`
+				} `json:"redis"`
+			} `json:"checks"`
+		}
+		rec := serve(h.GetReadiness, http.MethodGet, "/health/ready", "/health/ready", nil)
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("decoding readiness response: %v", err)
+		}
+		return rec.Code, body.Checks.Redis.Status, body.Checks.Redis.Circuit
+	}
+
+	if code, status, circuit := readiness(); code != http.StatusOK || status != "up" || circuit != cache.BreakerClosed {
+		t.Errorf("Redis up: got %d with redis %q and circuit %q, want 200 up closed", code, status, circuit)
+	}
+
+	// El primer fallo abre el circuito y las comprobaciones siguientes no llegan a Redis
+	redisServer.SetDown(true)
+	for i := 0; i < 2; i++ {
+		if code, status, circuit := readiness(); code != http.StatusServiceUnavailable || status != "down" || circuit != cache.BreakerOpen {
+			t.Errorf("Redis down, check %d: got %d with redis %q and circuit %q, want 503 down open", i+1, code, status, circuit)
+		}
+	}
+}
+
+func TestListCertificatesCursorPagination(t *testing.T) {
+	store := database.NewMemoryStore()
+	revokedAt := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 12; i++ {
+		seedRevoked(t, store, &models.RevokedCertificate{Serial: strconv.Itoa(100 + i), RevocationDate: revokedAt, CertificateAuthority: "Cursor CA"})
+	}
+	h := newTestHandler(t, store)
+
+	seen := make(map[string]int)
+	cursor, pages := "", 0
+	for {
+		var page struct {
+			Items      []models.RevokedCertificate `json:"items"`
+			NextCursor string                      `json:"next_cursor"`
+		}
+		rec := serve(h.ListCertificates, http.MethodGet, "/certificates", "/certificates?limit=5&cursor="+url.QueryEscape(cursor), nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("page %d: got status %d, want 200", pages+1, rec.Code)
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		}
+		pages++
+		for _, cert := range page.Items {
+			seen[cert.Serial]++
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		if pages > 12 {
+			t.Fatal("cursor pagination did not terminate")
+		}
+		cursor = page.NextCursor
+	}
+
+	if pages != 3 || len(seen) != 12 {
+		t.Errorf("got %d distinct certificates in %d pages, want 12 in 3", len(seen), pages)
+	}
+	for serial, count := range seen {
+		if count != 1 {
+			t.Errorf("serial %s listed %d times", serial, count)
+		}
+	}
+
+	rec := serve(h.ListCertificates, http.MethodGet, "/certificates", "/certificates?cursor=not-a-cursor", nil)
+	assertErrorCode(t, rec, http.StatusBadRequest, apierror.CodeInvalidParameter)
+}
+
+func TestListCertificatesInRange(t *testing.T) {
+	store := database.NewMemoryStore()
+	revokedAt := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	for _, serial := range []string{"255", "256", "300", "4096"} {
+		seedRevoked(t, store, &models.RevokedCertificate{Serial: serial, RevocationDate: revokedAt, CertificateAuthority: "Range CA"})
+	}
+	h := newTestHandler(t, store)
+	ca := url.QueryEscape("Range CA")
+
+	var body struct {
+		Items     []models.RevokedCertificate `json:"items"`
+		Count     int                         `json:"count"`
+		Truncated bool                        `json:"truncated"`
+	}
+	// Los límites aceptan los mismos formatos que el serial de /check
+	rec := serve(h.ListCertificatesInRange, http.MethodGet, "/range", "/range?ca="+ca+"&from=0x100&to=0x0FFF", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200: %s", rec.Code, rec.Body)
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if body.Count != 2 || len(body.Items) != 2 || body.Items[0].Serial != "256" || body.Items[1].Serial != "300" || body.Truncated {
+		t.Errorf("got %+v, want serials 256 and 300", body)
+	}
+
+	invalid := []struct {
+		name     string
+		query    string
+		wantCode string
+	}{
+		{"missing CA", "from=1&to=2", apierror.CodeMissingParameter},
+		{"missing bound", "ca=" + ca + "&from=1", apierror.CodeMissingParameter},
+		{"inverted range", "ca=" + ca + "&from=300&to=256", apierror.CodeInvalidRange},
+		{"invalid bound", "ca=" + ca + "&from=1&to=12G4", apierror.CodeInvalidSerial},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(h.ListCertificatesInRange, http.MethodGet, "/range", "/range?"+tt.query, nil)
+			assertErrorCode(t, rec, http.StatusBadRequest, tt.wantCode)
+		})
+	}
+}
+
+func TestWarmCacheEndpoint(t *testing.T) {
+	store := database.NewMemoryStore()
+	seedRevoked(t, store,
+		&models.RevokedCertificate{Serial: "4096", RevocationDate: time.Now().Add(-time.Hour), CertificateAuthority: testIssuerName},
+		&models.RevokedCertificate{Serial: "4097", RevocationDate: time.Now().Add(-2 * time.Hour), CertificateAuthority: testIssuerName},
+	)
+	redis, redisServer := newTestRedis(t, cache.BreakerConfig{})
+	handler := newCachedTestHandler(t, store, redis)
+
+	warm := func(target, body string) *httptest.ResponseRecorder {
+		return serve(handler.WarmCache, http.MethodPost, "/admin/warm-cache", target, strings.NewReader(body), "Content-Type", "application/json")
+	}
+
+	rec := warm("/admin/warm-cache?count=1", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var result services.WarmCacheResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if result.Requested != 1 || result.Cached != 1 {
+		t.Errorf("got %+v, want the most recent revocation cached", result)
+	}
+	if _, cached := redisServer.Get("cert:4096"); !cached {
+		t.Error("most recent revocation 4096 was not cached")
+	}
+	if _, cached := redisServer.Get("cert:4097"); cached {
+		t.Error("serial 4097 was cached although count=1")
+	}
+
+	// Los seriales del cuerpo se aceptan en el formato indicado
+	if rec := warm("/admin/warm-cache", `{"serials":["0x1001"],"format":"hex"}`); rec.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	if _, cached := redisServer.Get("cert:4097"); !cached {
+		t.Error("requested serial 0x1001 was not cached")
+	}
+
+	assertErrorCode(t, warm("/admin/warm-cache?count=0", ""), http.StatusBadRequest, apierror.CodeInvalidParameter)
+	assertErrorCode(t, warm("/admin/warm-cache", `{"serials":["xyz"],"format":"hex"}`), http.StatusBadRequest, apierror.CodeInvalidSerial)
+	assertErrorCode(t, warm("/admin/warm-cache", `{"serials":`), http.StatusBadRequest, apierror.CodeInvalidRequest)
+
+	uncached := newTestHandler(t, store)
+	rec = serve(uncached.WarmCache, http.MethodPost, "/admin/warm-cache", "/admin/warm-cache", nil)
+	assertErrorCode(t, rec, http.StatusServiceUnavailable, apierror.CodeCacheDisabled)
+}
+
+func TestCheckCertificateAsOf(t *testing.T) {
+	store := database.NewMemoryStore()
+	revokedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	seedRevoked(t, store, &models.RevokedCertificate{
+		Serial: "6000", RevocationDate: revokedAt, Reason: models.ReasonKeyCompromise, CertificateAuthority: testIssuerName,
+	})
+	redis, redisServer := newTestRedis(t, cache.BreakerConfig{})
+	h := newCachedTestHandler(t, store, redis)
+
+	// Con el estado actual en cache, as_of debe ignorarlo y consultar la base
+	if rec := serve(h.CheckCertificate, http.MethodGet, "/check/:serial", "/check/6000", nil); rec.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200", rec.Code)
+	}
+	if _, cached := redisServer.Get("cert:6000"); !cached {
+		t.Fatal("current status was not cached")
+	}
+	redisServer.ResetCommandCounts()
+
+	tests := []struct {
+		name    string
+		asOf    time.Time
+		revoked bool
+	}{
+		{"before revocation", revokedAt.Add(-time.Second), false},
+		{"at revocation", revokedAt, true},
+		{"after revocation", revokedAt.Add(30 * 24 * time.Hour), true},
+	}
+	for _, tt := range tests {
+		target := "/check/6000?as_of=" + url.QueryEscape(tt.asOf.Format(time.RFC3339))
+		rec := serve(h.CheckCertificate, http.MethodGet, "/check/:serial", target, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: got status %d, want 200: %s", tt.name, rec.Code, rec.Body.String())
+		}
+		var status models.CertificateStatus
+		if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+			t.Fatalf("%s: decoding response: %v", tt.name, err)
+		}
+		if status.IsRevoked != tt.revoked {
+			t.Errorf("%s: got revoked=%v, want %v", tt.name, status.IsRevoked, tt.revoked)
+		}
+		if status.AsOf == nil || !status.AsOf.Equal(tt.asOf) {
+			t.Errorf("%s: got as_of %v, want %v", tt.name, status.AsOf, tt.asOf)
+		}
+	}
+	if gets, sets := redisServer.CommandCount("GET"), redisServer.CommandCount("SET"); gets != 0 || sets != 0 {
+		t.Errorf("as_of lookups used the cache (%d GET, %d SET), want no cache access", gets, sets)
+	}
+
+	rec := serve(h.CheckCertificate, http.MethodGet, "/check/:serial", "/check/6000?as_of=yesterday", nil)
+	assertErrorCode(t, rec, http.StatusBadRequest, apierror.CodeInvalidParameter)
+}
+
+func TestCheckCertificateScopedByCA(t *testing.T) {
+	store := database.NewMemoryStore()
+	revokedAt := time.Now().Add(-48 * time.Hour).UTC().Truncate(time.Second)
+	seedRevoked(t, store,
+		&models.RevokedCertificate{Serial: "8080", RevocationDate: revokedAt, Reason: models.ReasonKeyCompromise, CertificateAuthority: "Scoped CA One"},
+		&models.RevokedCertificate{Serial: "8080", RevocationDate: revokedAt.Add(24 * time.Hour), Reason: models.ReasonSuperseded, CertificateAuthority: "Scoped CA Two"},
+	)
+	redis, redisServer := newTestRedis(t, cache.BreakerConfig{})
+	h := newCachedTestHandler(t, store, redis)
+
+	check := func(target string) models.CertificateStatus {
+		t.Helper()
+		rec := serve(h.CheckCertificate, http.MethodGet, "/check/:serial", target, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: got status %d, want 200: %s", target, rec.Code, rec.Body.String())
+		}
+		var status models.CertificateStatus
+		if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+			t.Fatalf("%s: decoding response: %v", target, err)
+		}
+		return status
+	}
+	issuer := func(status models.CertificateStatus) string {
+		if !status.IsRevoked || status.CertificateAuthority == nil {
+			return ""
+		}
+		return *status.CertificateAuthority
+	}
+
+	tests := []struct {
+		target string
+		wantCA string
+	}{
+		{"/check/8080", "Scoped CA Two"},
+		{"/check/8080?ca=" + url.QueryEscape("Scoped CA One"), "Scoped CA One"},
+		{"/check/8080?ca=" + url.QueryEscape("Scoped CA Two"), "Scoped CA Two"},
+	}
+	for _, tt := range tests {
+		if got := issuer(check(tt.target)); got != tt.wantCA {
+			t.Errorf("%s: got revoked by %q, want %s", tt.target, got, tt.wantCA)
+		}
+	}
+	if got := issuer(check("/check/8080?ca=" + url.QueryEscape("Scoped CA Three"))); got != "" {
+		t.Errorf("serial under another CA: got revoked by %q, want not revoked", got)
+	}
+
+	// Cada CA tiene su propia entrada en el cache, separada de la consulta sin CA
+	if cached, _ := redisServer.Get("cert:8080"); !strings.Contains(cached, "Scoped CA Two") {
+		t.Errorf("unscoped cache entry = %s, want the Scoped CA Two revocation", cached)
+	}
+	if cached, _ := redisServer.HGet("cert_ca:8080", "Scoped CA One"); !strings.Contains(cached, "Scoped CA One") {
+		t.Errorf("Scoped CA One cache entry = %s, want its revocation", cached)
+	}
+
+	// Con la fila borrada de la base la consulta acotada sigue respondiendo desde su entrada
+	if _, err := store.DeleteRevokedCertificates(context.Background(), "Scoped CA One", []string{"8080"}); err != nil {
+		t.Fatalf("DeleteRevokedCertificates: %v", err)
+	}
+	if got := issuer(check("/check/8080?ca=" + url.QueryEscape("Scoped CA One"))); got != "Scoped CA One" {
+		t.Errorf("cached scoped lookup: got revoked by %q, want Scoped CA One", got)
+	}
+}
+
+func TestCheckCertificateReportsOnHold(t *testing.T) {
+	store := database.NewMemoryStore()
+	revokedAt := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	seedRevoked(t, store,
+		&models.RevokedCertificate{Serial: "6600", RevocationDate: revokedAt, Reason: models.ReasonCertificateHold, CertificateAuthority: testIssuerName},
+		&models.RevokedCertificate{Serial: "6601", RevocationDate: revokedAt, Reason: models.ReasonKeyCompromise, CertificateAuthority: testIssuerName},
+	)
+	redis, _ := newTestRedis(t, cache.BreakerConfig{})
+	h := newCachedTestHandler(t, store, redis)
+
+	tests := []struct {
+		serial  string
+		revoked bool
+		want    string
+	}{
+		{"6600", true, models.StatusOnHold},
+		{"6601", true, models.StatusRevoked},
+		{"6602", false, models.StatusGood},
+	}
+	// La segunda vuelta responde desde el cache
+	for round := 0; round < 2; round++ {
+		for _, tt := range tests {
+			rec := serve(h.CheckCertificate, http.MethodGet, "/check/:serial", "/check/"+tt.serial, nil)
+			var body map[string]interface{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("serial %s: decoding response: %v", tt.serial, err)
+			}
+			if body["is_revoked"] != tt.revoked || body["status"] != tt.want {
+				t.Errorf("serial %s (round %d): got is_revoked=%v status=%v, want %v %q", tt.serial, round, body["is_revoked"], body["status"], tt.revoked, tt.want)
+			}
+		}
+	}
+}
+
+func TestCheckCertificateRefreshBypassesStaleCache(t *testing.T) {
+	store := database.NewMemoryStore()
+	seedRevoked(t, store, &models.RevokedCertificate{
+		Serial: "6050", RevocationDate: time.Now().Add(-time.Hour).UTC().Truncate(time.Second), Reason: models.ReasonKeyCompromise, CertificateAuthority: testIssuerName,
+	})
+	redis, redisServer := newTestRedis(t, cache.BreakerConfig{})
+	h := newCachedTestHandler(t, store, redis, func(cfg *config.Config) {
+		cfg.AdminAPIKey = "admin-secret"
+	})
+
+	// Entrada que quedó en Redis antes de corregir la base a mano
+	redisServer.Set("cert:6050", `{"serial":"6050","is_revoked":false,"status":"good"}`)
+	check := func(target string, headers ...string) *httptest.ResponseRecorder {
+		return serve(h.CheckCertificate, http.MethodGet, "/check/:serial", target, nil, headers...)
+	}
+	revoked := func(rec *httptest.ResponseRecorder) bool {
+		t.Helper()
+		var status models.CertificateStatus
+		if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		}
+		return status.IsRevoked
+	}
+
+	if rec := check("/check/6050"); rec.Code != http.StatusOK || revoked(rec) {
+		t.Fatalf("got status %d %s, want the stale cached answer", rec.Code, rec.Body.String())
+	}
+
+	// Sin la clave de administrador no se puede saltear el cache
+	assertErrorCode(t, check("/check/6050?refresh=true"), http.StatusUnauthorized, apierror.CodeUnauthorized)
+	assertErrorCode(t, check("/check/6050?refresh=true", "X-API-Key", "wrong"), http.StatusUnauthorized, apierror.CodeUnauthorized)
+
+	redisServer.ResetCommandCounts()
+	rec := check("/check/6050?refresh=true", "X-API-Key", "admin-secret")
+	if rec.Code != http.StatusOK || !revoked(rec) {
+		t.Fatalf("got status %d %s, want the revoked status from the database", rec.Code, rec.Body.String())
+	}
+	if gets := redisServer.CommandCount("GET"); gets != 0 {
+		t.Errorf("refresh read the cache %d times, want none", gets)
+	}
+
+	// El resultado nuevo reemplaza la entrada vieja para las consultas siguientes
+	if rec := check("/check/6050"); rec.Code != http.StatusOK || !revoked(rec) {
+		t.Errorf("got %s after refreshing, want the revoked status from the cache", rec.Body.String())
+	}
+	if cached, _ := redisServer.Get("cert:6050"); !strings.Contains(cached, `"is_revoked":true`) {
+		t.Errorf("cache entry after refresh = %s, want the revoked status", cached)
+	}
+}
+
+func TestCheckCertificateCacheHeaders(t *testing.T) {
+	store := database.NewMemoryStore()
+	seedRevoked(t, store, &models.RevokedCertificate{
+		Serial: "6100", RevocationDate: time.Now().Add(-time.Hour).UTC().Truncate(time.Second), CertificateAuthority: testIssuerName,
+	})
+	h := newTestHandler(t, store, func(cfg *config.Config) {
+		cfg.CacheTTLValid = 5 * time.Minute
+		cfg.CacheTTLRevoked = 24 * time.Hour
+	})
+	check := func(serial string, headers ...string) *httptest.ResponseRecorder {
+		return serve(h.CheckCertificate, http.MethodGet, "/check/:serial", "/check/"+serial, nil, headers...)
+	}
+
+	// Los revocados no dejan de estarlo: max-age más largo que el de los válidos
+	for serial, want := range map[string]string{"6100": "public, max-age=86400", "6101": "public, max-age=300"} {
+		rec := check(serial)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("serial %s: got status %d, want 200", serial, rec.Code)
+		}
+		if got := rec.Header().Get("Cache-Control"); got != want {
+			t.Errorf("serial %s: got Cache-Control %q, want %q", serial, got, want)
+		}
+		if etag := rec.Header().Get("ETag"); len(etag) != 34 || !strings.HasPrefix(etag, `"`) {
+			t.Errorf("serial %s: got ETag %q, want a quoted 32-character hash", serial, etag)
+		}
+	}
+
+	etag := check("6100").Header().Get("ETag")
+	if other := check("6101").Header().Get("ETag"); other == etag {
+		t.Error("revoked and valid serials share the same ETag")
+	}
+	// El ETag identifica la representación: otro formato tiene otro ETag
+	if plain := check("6100", "Accept", "text/plain").Header().Get("ETag"); plain == etag {
+		t.Error("JSON and plain-text responses share the same ETag")
+	}
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		wantStatus  int
+	}{
+		{"matching ETag", etag, http.StatusNotModified},
+		{"weak ETag after gzip", "W/" + etag, http.StatusNotModified},
+		{"one of several", `"other", ` + etag, http.StatusNotModified},
+		{"wildcard", "*", http.StatusNotModified},
+		{"different ETag", `"0123456789abcdef0123456789abcdef"`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		rec := check("6100", "If-None-Match", tt.ifNoneMatch)
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: got status %d, want %d", tt.name, rec.Code, tt.wantStatus)
+			continue
+		}
+		if tt.wantStatus == http.StatusNotModified {
+			if rec.Body.Len() != 0 {
+				t.Errorf("%s: 304 response has a body: %s", tt.name, rec.Body.String())
+			}
+			if rec.Header().Get("ETag") != etag || rec.Header().Get("Cache-Control") == "" {
+				t.Errorf("%s: 304 response without ETag and Cache-Control", tt.name)
+			}
+		}
+	}
+}
+
+func TestCheckCertificateContentNegotiation(t *testing.T) {
+	store := database.NewMemoryStore()
+	revokedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	seedRevoked(t, store, &models.RevokedCertificate{
+		Serial: "7100", RevocationDate: revokedAt, Reason: models.ReasonKeyCompromise, CertificateAuthority: testIssuerName,
+	})
+	h := newTestHandler(t, store)
+
+	tests := []struct {
+		name            string
+		serial          string
+		accept          string
+		wantContentType string
+	}{
+		{"default", "7100", "", "application/json; charset=utf-8"},
+		{"JSON", "7100", "application/json", "application/json; charset=utf-8"},
+		{"XML", "7100", "application/xml", "application/xml; charset=utf-8"},
+		{"text XML", "7100", "text/xml", "application/xml; charset=utf-8"},
+		{"XML listed first", "7100", "application/xml, application/json", "application/xml; charset=utf-8"},
+		{"plain text", "7100", "text/plain", "text/plain; charset=utf-8"},
+		{"plain text good", "7101", "text/plain", "text/plain; charset=utf-8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var headers []string
+			if tt.accept != "" {
+				headers = []string{"Accept", tt.accept}
+			}
+			rec := serve(h.CheckCertificate, http.MethodGet, "/check/:serial", "/check/"+tt.serial, nil, headers...)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("got status %d, want 200", rec.Code)
+			}
+			if got := rec.Header().Get("Content-Type"); got != tt.wantContentType {
+				t.Errorf("got Content-Type %q, want %q", got, tt.wantContentType)
+			}
+			if vary := rec.Header().Values("Vary"); !slices.Contains(vary, "Accept") {
+				t.Errorf("got Vary %q, want Accept", vary)
+			}
+
+			var status models.CertificateStatus
+			switch {
+			case strings.HasPrefix(tt.wantContentType, "application/json"):
+				if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+					t.Fatalf("decoding JSON: %v", err)
+				}
+			case strings.HasPrefix(tt.wantContentType, "application/xml"):
+				if !strings.HasPrefix(rec.Body.String(), xml.Header) {
+					t.Errorf("XML body does not start with the XML declaration: %s", rec.Body)
+				}
+				if !strings.Contains(rec.Body.String(), "<certificate_status>") {
+					t.Errorf("XML body has no certificate_status element: %s", rec.Body)
+				}
+				if err := xml.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+					t.Fatalf("decoding XML: %v", err)
+				}
+			default:
+				want := "7101 good\n"
+				if tt.serial == "7100" {
+					want = "7100 revoked 2024-03-01T12:00:00Z 1\n"
+				}
+				if rec.Body.String() != want {
+					t.Errorf("got body %q, want %q", rec.Body.String(), want)
+				}
+				return
+			}
+			if status.Serial != "7100" || !status.IsRevoked || status.ReasonCode == nil || *status.ReasonCode != models.ReasonKeyCompromise {
+				t.Errorf("got status %+v, want 7100 revoked for key compromise", status)
+			}
+			if status.RevocationDate == nil || !status.RevocationDate.Equal(revokedAt) {
+				t.Errorf("got revocation date %v, want %v", status.RevocationDate, revokedAt)
+			}
+		})
+	}
+}
+
+func TestGetReasons(t *testing.T) {
+	h := newTestHandler(t, database.NewMemoryStore())
+
+	tests := []struct {
+		name           string
+		target         string
+		acceptLanguage string
+		wantLang       string
+		want           map[int]string
+	}{
+		{"default", "/reasons", "", "es", models.RevocationReasons},
+		{"spanish", "/reasons?lang=es", "", "es", models.RevocationReasons},
+		{"english", "/reasons?lang=en", "", "en", models.RevocationReasonsEN},
+		{"Accept-Language", "/reasons", "en-US,en;q=0.9", "en", models.RevocationReasonsEN},
+		{"query over Accept-Language", "/reasons?lang=es", "en", "es", models.RevocationReasons},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var headers []string
+			if tt.acceptLanguage != "" {
+				headers = []string{"Accept-Language", tt.acceptLanguage}
+			}
+			rec := serve(h.GetReasons, http.MethodGet, "/reasons", tt.target, nil, headers...)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("got status %d, want 200", rec.Code)
+			}
+			var body struct {
+				Lang    string         `json:"lang"`
+				Reasons map[int]string `json:"reasons"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			if body.Lang != tt.wantLang {
+				t.Errorf("got lang %q, want %q", body.Lang, tt.wantLang)
+			}
+			if len(body.Reasons) != len(tt.want) {
+				t.Errorf("got %d reasons, want %d", len(body.Reasons), len(tt.want))
+			}
+			for code, text := range tt.want {
+				if body.Reasons[code] != text {
+					t.Errorf("reason %d: got %q, want %q", code, body.Reasons[code], text)
+				}
+			}
+		})
+	}
+
+	// Todos los códigos tienen texto en ambos idiomas
+	for code := range models.RevocationReasons {
+		if models.RevocationReasonsEN[code] == "" {
+			t.Errorf("reason %d has no English text", code)
+		}
+	}
+
+	rec := serve(h.GetReasons, http.MethodGet, "/reasons", "/reasons?lang=fr", nil)
+	assertErrorCode(t, rec, http.StatusBadRequest, apierror.CodeInvalidParameter)
+}
+
+func TestGetThroughput(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	now := time.Now().UTC()
+	for _, entry := range []*models.ProcessingLogEntry{
+		{StartedAt: now.Add(-30 * time.Minute), DurationMs: 100, CertCount: 3, Status: models.ProcessingStatusSuccess},
+		{StartedAt: now.Add(-3 * 24 * time.Hour), DurationMs: 100, CertCount: 5, Status: models.ProcessingStatusSuccess},
+	} {
+		entry.URL = "http://crl.example/throughput.crl"
+		if err := store.InsertProcessingLog(ctx, entry); err != nil {
+			t.Fatalf("InsertProcessingLog: %v", err)
+		}
+	}
+	h := newTestHandler(t, store)
+
+	throughput := func(target string) (int, []models.ThroughputBucket) {
+		rec := serve(h.GetThroughput, http.MethodGet, "/stats/throughput", target, nil)
+		var body struct {
+			Buckets []models.ThroughputBucket `json:"buckets"`
+		}
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+		}
+		return rec.Code, body.Buckets
+	}
+
+	// Por hora el periodo por defecto es el último día; por día, el último mes
+	tests := []struct {
+		target    string
+		wantCerts []int64
+	}{
+		{"/stats/throughput", []int64{3}},
+		{"/stats/throughput?bucket=day", []int64{5, 3}},
+		{"/stats/throughput?bucket=hour&since=" + now.Add(-7*24*time.Hour).Format("2006-01-02"), []int64{5, 3}},
+	}
+	for _, tt := range tests {
+		code, buckets := throughput(tt.target)
+		if code != http.StatusOK {
+			t.Fatalf("%s: got status %d, want 200", tt.target, code)
+		}
+		var certs []int64
+		for _, b := range buckets {
+			certs = append(certs, b.CertsImported)
+		}
+		if !slices.Equal(certs, tt.wantCerts) {
+			t.Errorf("%s: got certificates per bucket %v, want %v", tt.target, certs, tt.wantCerts)
+		}
+	}
+
+	for _, target := range []string{"/stats/throughput?bucket=week", "/stats/throughput?since=yesterday"} {
+		rec := serve(h.GetThroughput, http.MethodGet, "/stats/throughput", target, nil)
+		assertErrorCode(t, rec, http.StatusBadRequest, apierror.CodeInvalidParameter)
+	}
+}
+
+// outageStore simula la caída de PostgreSQL en las consultas de estado mientras down es true
+type outageStore struct {
+	database.CertStore
+	down    atomic.Bool
+	lookups atomic.Int32
+}
+
+func (s *outageStore) GetCertificateStatus(ctx context.Context, serial string) (*models.CertificateStatus, error) {
+	s.lookups.Add(1)
+	if s.down.Load() {
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: errDatabaseDown}
+	}
+	return s.CertStore.GetCertificateStatus(ctx, serial)
+}
+
+func TestCheckCertificateDuringDatabaseOutage(t *testing.T) {
+	store := &outageStore{CertStore: database.NewMemoryStore()}
+	seedRevoked(t, store, &models.RevokedCertificate{
+		Serial: "8100", RevocationDate: time.Now().Add(-time.Hour).UTC().Truncate(time.Second), CertificateAuthority: testIssuerName,
+	})
+	redis, _ := newTestRedis(t, cache.BreakerConfig{})
+	h := newCachedTestHandler(t, store, redis)
+	check := func(serial string) *httptest.ResponseRecorder {
+		return serve(h.CheckCertificate, http.MethodGet, "/check/:serial", "/check/"+serial, nil)
+	}
+
+	// La consulta con la base disponible deja el estado en cache
+	if rec := check("8100"); rec.Code != http.StatusOK {
+		t.Fatalf("got status %d before the outage, want 200", rec.Code)
+	}
+	store.down.Store(true)
+	store.lookups.Store(0)
+
+	t.Run("cached", func(t *testing.T) {
+		rec := check("8100")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("got status %d, want 200 from the cache: %s", rec.Code, rec.Body)
+		}
+		var status models.CertificateStatus
+		if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil || !status.IsRevoked {
+			t.Errorf("got status %+v (err %v), want revoked", status, err)
+		}
+		if rec.Header().Get("X-Service-Degraded") != "" {
+			t.Error("a cached answer was marked as degraded")
+		}
+		if lookups := store.lookups.Load(); lookups != 0 {
+			t.Errorf("the database was queried %d times for a cached serial", lookups)
+		}
+	})
+
+	t.Run("not cached", func(t *testing.T) {
+		rec := check("8101")
+		assertErrorCode(t, rec, http.StatusServiceUnavailable, apierror.CodeDegraded)
+		if got := rec.Header().Get("Retry-After"); got != strconv.Itoa(databaseRetryAfter) {
+			t.Errorf("got Retry-After %q, want %d", got, databaseRetryAfter)
+		}
+		if rec.Header().Get("X-Service-Degraded") != "true" {
+			t.Error("response is not marked as degraded")
+		}
+		// Los fallos de conexión se reintentan antes de responder
+		if lookups := store.lookups.Load(); lookups < 2 {
+			t.Errorf("got %d database lookups, want the transient error retried", lookups)
+		}
+	})
+}
+
+func TestTestSource(t *testing.T) {
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ca.crl" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/pkix-crl")
+		w.Write(pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: []byte{0x30, 0x03, 0x30, 0x01, 0x00}}))
+	}))
+	t.Cleanup(source.Close)
+	h := newTestHandler(t, database.NewMemoryStore(), func(cfg *config.Config) {
+		cfg.CRLPerHostRate = 0
+	})
+	testSource := func(crlURL string) *httptest.ResponseRecorder {
+		return serve(h.TestSource, http.MethodGet, "/admin/test-source", "/admin/test-source?url="+url.QueryEscape(crlURL), nil)
+	}
+
+	tests := []struct {
+		path       string
+		wantStatus int
+		wantCRL    bool
+	}{
+		{"/ca.crl", http.StatusOK, true},
+		{"/missing.crl", http.StatusNotFound, false},
+	}
+	for _, tt := range tests {
+		rec := testSource(source.URL + tt.path)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: got status %d, want 200: %s", tt.path, rec.Code, rec.Body)
+		}
+		var result services.SourceProbeResult
+		if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		}
+		if !result.Reachable || result.Status != tt.wantStatus || result.LooksLikeCRL != tt.wantCRL {
+			t.Errorf("%s: got %+v, want source status %d and looks_like_crl %v", tt.path, result, tt.wantStatus, tt.wantCRL)
+		}
+	}
+
+	for _, crlURL := range []string{"", "not a url", "ldap://ldap.example/cn=CA?certificateRevocationList"} {
+		assertErrorCode(t, testSource(crlURL), http.StatusBadRequest, apierror.CodeInvalidURL)
+	}
+}
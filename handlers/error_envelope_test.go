@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"signerflow-crl/apierror"
+	"signerflow-crl/database"
+	"signerflow-crl/models"
+	"signerflow-crl/requestid"
+)
+
+// brokenStore hace fallar las consultas de listado y estadísticas para ejercitar las respuestas
+// INTERNAL_ERROR de los handlers
+type brokenStore struct {
+	database.CertStore
+}
+
+func (brokenStore) ListRevokedCertificates(ctx context.Context, filter models.CertificateFilter, limit, offset int) ([]*models.RevokedCertificate, int, error) {
+	return nil, 0, errDatabaseDown
+}
+
+func (brokenStore) ListCRLInfo(ctx context.Context) ([]*models.CRLInfo, error) {
+	return nil, errDatabaseDown
+}
+
+func (brokenStore) GetCRLInfo(ctx context.Context, url string) (*models.CRLInfo, error) {
+	return nil, errDatabaseDown
+}
+
+func (brokenStore) GetCRLStats(ctx context.Context) (map[string]interface{}, error) {
+	return nil, errDatabaseDown
+}
+
+// envelopeRouter registra las rutas de h bajo un middleware que fija el ID de petición test-request-id
+func envelopeRouter(h *CertificateHandler) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Request = c.Request.WithContext(requestid.NewContext(c.Request.Context(), "test-request-id"))
+	})
+	router.GET("/certificate/:serial", h.CheckCertificate)
+	router.GET("/valid/:serial", h.ValidCertificate)
+	router.GET("/certificates", h.ListCertificates)
+	router.GET("/certificates/range", h.ListCertificatesInRange)
+	router.GET("/crls", h.ListCRLs)
+	router.GET("/crls/detail", h.GetCRLDetail)
+	router.GET("/stats", h.GetStats)
+	router.GET("/public-key", h.GetPublicKey)
+	router.GET("/fingerprint/:sha256", h.CheckFingerprint)
+	router.POST("/fingerprint", h.CheckFingerprintUpload)
+	return router
+}
+
+func TestErrorResponsesUseEnvelope(t *testing.T) {
+	healthy := envelopeRouter(newTestHandler(t, database.NewMemoryStore()))
+	broken := envelopeRouter(newTestHandler(t, brokenStore{database.NewMemoryStore()}))
+
+	tests := []struct {
+		name       string
+		router     *gin.Engine
+		method     string
+		target     string
+		body       string
+		wantStatus int
+		wantCode   string
+	}{
+		{"invalid serial", healthy, http.MethodGet, "/certificate/not-a-serial!", "", http.StatusBadRequest, apierror.CodeInvalidSerial},
+		{"unknown serial format", healthy, http.MethodGet, "/certificate/42?format=octal", "", http.StatusBadRequest, apierror.CodeInvalidFormat},
+		{"blank serial", healthy, http.MethodGet, "/valid/%20", "", http.StatusBadRequest, apierror.CodeSerialRequired},
+		{"invalid limit", healthy, http.MethodGet, "/certificates?limit=abc", "", http.StatusBadRequest, apierror.CodeInvalidParameter},
+		{"list failure", broken, http.MethodGet, "/certificates", "", http.StatusInternalServerError, apierror.CodeInternal},
+		{"range without CA", healthy, http.MethodGet, "/certificates/range?from=1&to=2", "", http.StatusBadRequest, apierror.CodeMissingParameter},
+		{"CRL list failure", broken, http.MethodGet, "/crls", "", http.StatusInternalServerError, apierror.CodeInternal},
+		{"CRL detail without URL", healthy, http.MethodGet, "/crls/detail", "", http.StatusBadRequest, apierror.CodeMissingParameter},
+		{"CRL detail not found", healthy, http.MethodGet, "/crls/detail?url=http://crl.example/ca.crl", "", http.StatusNotFound, apierror.CodeNotFound},
+		{"CRL detail failure", broken, http.MethodGet, "/crls/detail?url=http://crl.example/ca.crl", "", http.StatusInternalServerError, apierror.CodeInternal},
+		{"stats failure", broken, http.MethodGet, "/stats", "", http.StatusInternalServerError, apierror.CodeInternal},
+		{"signing disabled", healthy, http.MethodGet, "/public-key", "", http.StatusNotFound, apierror.CodeSigningDisabled},
+		{"invalid fingerprint", healthy, http.MethodGet, "/fingerprint/xyz", "", http.StatusBadRequest, apierror.CodeInvalidFingerprint},
+		{"unknown fingerprint", healthy, http.MethodGet, "/fingerprint/" + strings.Repeat("ab", 32), "", http.StatusNotFound, apierror.CodeNotFound},
+		{"missing certificate", healthy, http.MethodPost, "/fingerprint", "", http.StatusBadRequest, apierror.CodeCertificateRequired},
+		{"invalid certificate", healthy, http.MethodPost, "/fingerprint", "not a certificate", http.StatusBadRequest, apierror.CodeInvalidCertificate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tt.router.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body)))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("got status %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if contentType := rec.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "application/json") {
+				t.Errorf("got Content-Type %q, want JSON", contentType)
+			}
+
+			// El sobre tiene exactamente {"error": {"code", "message", "request_id"}}
+			var envelope map[string]map[string]interface{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &envelope); err != nil {
+				t.Fatalf("response is not an error envelope: %v: %s", err, rec.Body.String())
+			}
+			detail, ok := envelope["error"]
+			if len(envelope) != 1 || !ok {
+				t.Fatalf("got top-level keys %v, want only error", envelope)
+			}
+			if len(detail) != 3 {
+				t.Errorf("got error fields %v, want code, message and request_id", detail)
+			}
+			if detail["code"] != tt.wantCode {
+				t.Errorf("got code %v, want %s", detail["code"], tt.wantCode)
+			}
+			if message, _ := detail["message"].(string); message == "" {
+				t.Error("error envelope has an empty message")
+			}
+			if detail["request_id"] != "test-request-id" {
+				t.Errorf("got request_id %v, want test-request-id", detail["request_id"])
+			}
+		})
+	}
+}
+
+func TestValidCertificateKeepsPlainTextResponse(t *testing.T) {
+	handler := newTestHandler(t, database.NewMemoryStore())
+	router := envelopeRouter(handler)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/valid/1234", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200", rec.Code)
+	}
+	if contentType := rec.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain") {
+		t.Errorf("got Content-Type %q, want text/plain", contentType)
+	}
+	if rec.Body.String() != handler.cfg.ValidResponseText {
+		t.Errorf("got body %q, want %q", rec.Body.String(), handler.cfg.ValidResponseText)
+	}
+}
+
+func TestErrorMessagesFollowAcceptLanguage(t *testing.T) {
+	router := envelopeRouter(newTestHandler(t, database.NewMemoryStore()))
+
+	tests := []struct {
+		name           string
+		target         string
+		acceptLanguage string
+		wantCode       string
+		wantMessage    string
+	}{
+		{"English", "/crls/detail", "en", apierror.CodeMissingParameter, "The CRL url parameter is required"},
+		{"English region", "/crls/detail", "en-US,en;q=0.9", apierror.CodeMissingParameter, "The CRL url parameter is required"},
+		{"default Spanish", "/crls/detail", "", apierror.CodeMissingParameter, "Debe proporcionar el parámetro url de la CRL"},
+		{"unsupported language", "/crls/detail", "fr", apierror.CodeMissingParameter, "Debe proporcionar el parámetro url de la CRL"},
+		{"English with arguments", "/certificates/range?ca=CA&from=xyz&to=2", "en", apierror.CodeInvalidSerial, "Could not parse from in the given format"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.acceptLanguage != "" {
+				req.Header.Set("Accept-Language", tt.acceptLanguage)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			var body apierror.Response
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decoding error response: %v", err)
+			}
+			if body.Error.Message != tt.wantMessage {
+				t.Errorf("got message %q, want %q", body.Error.Message, tt.wantMessage)
+			}
+			// Los códigos no dependen del idioma
+			if body.Error.Code != tt.wantCode {
+				t.Errorf("got code %q, want %q", body.Error.Code, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"signerflow-crl/apierror"
+	"signerflow-crl/i18n"
+	"signerflow-crl/models"
+	"signerflow-crl/requestid"
+)
+
+// exportFlushInterval es cada cuántas filas se envían los datos acumulados al cliente
+const exportFlushInterval = 1000
+
+// ExportCertificates transmite la lista completa de revocados en CSV o JSON
+func (h *CertificateHandler) ExportCertificates(c *gin.Context) {
+	format := strings.ToLower(c.DefaultQuery("format", "csv"))
+	if format != "csv" && format != "json" {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidFormat, i18n.MsgInvalidExportFormat)
+		return
+	}
+
+	ca := strings.TrimSpace(c.Query("ca"))
+	filename := fmt.Sprintf("revoked_certificates_%s.%s", time.Now().UTC().Format("20060102T150405Z"), format)
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Status(http.StatusOK)
+
+	var err error
+	if format == "csv" {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		err = h.exportCSV(c, ca)
+	} else {
+		c.Header("Content-Type", "application/json; charset=utf-8")
+		err = h.exportJSON(c, ca)
+	}
+
+	// Los headers ya se enviaron, solo queda registrar el error
+	if err != nil {
+		requestid.Logf(c.Request.Context(), "Error exporting certificates: %v", err)
+	}
+}
+
+func (h *CertificateHandler) exportCSV(c *gin.Context, ca string) error {
+	writer := csv.NewWriter(c.Writer)
+	if err := writer.Write([]string{"serial", "revocation_date", "reason", "reason_text", "certificate_authority"}); err != nil {
+		return err
+	}
+
+	count := 0
+	err := h.db.StreamRevokedCertificates(c.Request.Context(), ca, func(cert *models.RevokedCertificate) error {
+		err := writer.Write([]string{
+			cert.Serial,
+			cert.RevocationDate.UTC().Format(time.RFC3339),
+			strconv.Itoa(cert.Reason),
+			cert.ReasonText,
+			cert.CertificateAuthority,
+		})
+		if err != nil {
+			return err
+		}
+
+		count++
+		if count%exportFlushInterval == 0 {
+			writer.Flush()
+			c.Writer.Flush()
+		}
+		return writer.Error()
+	})
+
+	writer.Flush()
+	c.Writer.Flush()
+	if err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+type exportRecord struct {
+	Serial               string    `json:"serial"`
+	RevocationDate       time.Time `json:"revocation_date"`
+	Reason               int       `json:"reason"`
+	ReasonText           string    `json:"reason_text"`
+	CertificateAuthority string    `json:"certificate_authority"`
+}
+
+func (h *CertificateHandler) exportJSON(c *gin.Context, ca string) error {
+	if _, err := c.Writer.WriteString("["); err != nil {
+		return err
+	}
+
+	count := 0
+	err := h.db.StreamRevokedCertificates(c.Request.Context(), ca, func(cert *models.RevokedCertificate) error {
+		data, err := json.Marshal(exportRecord{
+			Serial:               cert.Serial,
+			RevocationDate:       cert.RevocationDate.UTC(),
+			Reason:               cert.Reason,
+			ReasonText:           cert.ReasonText,
+			CertificateAuthority: cert.CertificateAuthority,
+		})
+		if err != nil {
+			return err
+		}
+
+		if count > 0 {
+			if _, err := c.Writer.WriteString(","); err != nil {
+				return err
+			}
+		}
+		if _, err := c.Writer.Write(data); err != nil {
+			return err
+		}
+
+		count++
+		if count%exportFlushInterval == 0 {
+			c.Writer.Flush()
+		}
+		return nil
+	})
+
+	// Cerrar el arreglo aunque la consulta haya fallado a mitad del recorrido
+	if _, writeErr := c.Writer.WriteString("]"); writeErr != nil && err == nil {
+		err = writeErr
+	}
+	c.Writer.Flush()
+	return err
+}
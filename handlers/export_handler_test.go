@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"signerflow-crl/database"
+	"signerflow-crl/models"
+)
+
+func TestExportCertificates(t *testing.T) {
+	store := database.NewMemoryStore()
+	revokedAt := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	seedRevoked(t, store,
+		&models.RevokedCertificate{Serial: "1", RevocationDate: revokedAt, Reason: models.ReasonKeyCompromise, ReasonText: "Compromiso de clave", CertificateAuthority: "CA One"},
+		&models.RevokedCertificate{Serial: "2", RevocationDate: revokedAt, Reason: models.ReasonSuperseded, ReasonText: "Reemplazado", CertificateAuthority: "CA One"},
+		&models.RevokedCertificate{Serial: "3", RevocationDate: revokedAt, CertificateAuthority: "CA Two"},
+	)
+	h := newTestHandler(t, store)
+
+	t.Run("csv", func(t *testing.T) {
+		rec := serve(h.ExportCertificates, http.MethodGet, "/export", "/export?format=csv", nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("got status %d, want 200", rec.Code)
+		}
+		if got := rec.Header().Get("Content-Type"); got != "text/csv; charset=utf-8" {
+			t.Errorf("got Content-Type %q, want text/csv", got)
+		}
+		if got := rec.Header().Get("Content-Disposition"); !strings.HasPrefix(got, `attachment; filename="revoked_certificates_`) || !strings.HasSuffix(got, `.csv"`) {
+			t.Errorf("got Content-Disposition %q, want a .csv attachment", got)
+		}
+
+		rows, err := csv.NewReader(rec.Body).ReadAll()
+		if err != nil {
+			t.Fatalf("parsing CSV: %v", err)
+		}
+		header := []string{"serial", "revocation_date", "reason", "reason_text", "certificate_authority"}
+		if len(rows) == 0 || strings.Join(rows[0], ",") != strings.Join(header, ",") {
+			t.Fatalf("got header row %v, want %v", rows, header)
+		}
+		if len(rows) != 4 {
+			t.Errorf("got %d data rows, want 3", len(rows)-1)
+		}
+	})
+
+	t.Run("json filtered by CA", func(t *testing.T) {
+		rec := serve(h.ExportCertificates, http.MethodGet, "/export", "/export?format=json&ca=CA+One", nil)
+		if got := rec.Header().Get("Content-Type"); got != "application/json; charset=utf-8" {
+			t.Errorf("got Content-Type %q, want application/json", got)
+		}
+		if got := rec.Header().Get("Content-Disposition"); !strings.HasSuffix(got, `.json"`) {
+			t.Errorf("got Content-Disposition %q, want a .json attachment", got)
+		}
+
+		var records []exportRecord
+		if err := json.Unmarshal(rec.Body.Bytes(), &records); err != nil {
+			t.Fatalf("decoding JSON: %v", err)
+		}
+		if len(records) != 2 {
+			t.Fatalf("got %d records, want 2", len(records))
+		}
+		for _, record := range records {
+			if record.CertificateAuthority != "CA One" {
+				t.Errorf("got record of %s, want only CA One", record.CertificateAuthority)
+			}
+		}
+	})
+
+	t.Run("invalid format", func(t *testing.T) {
+		rec := serve(h.ExportCertificates, http.MethodGet, "/export", "/export?format=xml", nil)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("got status %d, want 400", rec.Code)
+		}
+	})
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"signerflow-crl/apierror"
+	"signerflow-crl/cache"
+	"signerflow-crl/cache/redistest"
+	"signerflow-crl/config"
+	"signerflow-crl/database"
+	"signerflow-crl/models"
+	"signerflow-crl/services"
+)
+
+// newTestService crea un CRLService sin Redis sobre store, con la configuración por defecto
+func newTestService(t *testing.T, store database.CertStore, configure ...func(*config.Config)) (*services.CRLService, *config.Config) {
+	t.Helper()
+	return newCachedTestService(t, store, nil, configure...)
+}
+
+// newCachedTestService crea el CRLService con un cliente Redis, que puede ser nil
+func newCachedTestService(t *testing.T, store database.CertStore, redis *cache.RedisClient, configure ...func(*config.Config)) (*services.CRLService, *config.Config) {
+	t.Helper()
+
+	cfg := config.LoadConfig()
+	cfg.DownloadAttempts = 1
+	for _, fn := range configure {
+		fn(cfg)
+	}
+
+	service, err := services.NewCRLService(store, redis, cfg)
+	if err != nil {
+		t.Fatalf("creating service: %v", err)
+	}
+	t.Cleanup(service.Close)
+	return service, cfg
+}
+
+// newTestHandler crea un CertificateHandler sin Redis sobre store, con la configuración por defecto
+func newTestHandler(t *testing.T, store database.CertStore, configure ...func(*config.Config)) *CertificateHandler {
+	t.Helper()
+	return newCachedTestHandler(t, store, nil, configure...)
+}
+
+// newCachedTestHandler crea el CertificateHandler con un cliente Redis, que puede ser nil
+func newCachedTestHandler(t *testing.T, store database.CertStore, redis *cache.RedisClient, configure ...func(*config.Config)) *CertificateHandler {
+	t.Helper()
+
+	service, cfg := newCachedTestService(t, store, redis, configure...)
+	return NewCertificateHandler(service, store, redis, cfg, nil, nil)
+}
+
+// newTestRedis conecta un cliente a un servidor Redis en memoria con el circuit breaker dado
+func newTestRedis(t *testing.T, breaker cache.BreakerConfig) (*cache.RedisClient, *redistest.Server) {
+	t.Helper()
+
+	srv := redistest.NewServer(t)
+	client, err := cache.NewRedisClient(srv.Addr(), "", 0, "", breaker)
+	if err != nil {
+		t.Fatalf("connecting to test Redis: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client, srv
+}
+
+// testIssuerName es el CN de la CA que emite los certificados de newTestCertificate
+const testIssuerName = "Handler Test CA"
+
+// newTestCertificate emite un certificado con el serial y la vigencia dados, firmado por una
+// CA llamada testIssuerName, y lo devuelve en DER
+func newTestCertificate(t *testing.T, serial int64, notBefore, notAfter time.Time) []byte {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	issuer := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: testIssuerName},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter.Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "leaf"},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, key.Public(), key)
+	if err != nil {
+		t.Fatalf("creating certificate: %v", err)
+	}
+	return der
+}
+
+// seedRevoked inserta certificados revocados en store
+func seedRevoked(t *testing.T, store database.CertStore, certs ...*models.RevokedCertificate) {
+	t.Helper()
+
+	if _, err := store.BatchInsertRevokedCertificates(context.Background(), certs); err != nil {
+		t.Fatalf("BatchInsertRevokedCertificates: %v", err)
+	}
+}
+
+// serve registra handler en method y path y le envía una petición con el cuerpo y los headers dados
+func serve(handler gin.HandlerFunc, method, path, target string, body io.Reader, headers ...string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Handle(method, path, handler)
+
+	req := httptest.NewRequest(method, target, body)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+// errDatabaseDown es el error devuelto por unavailableStore
+var errDatabaseDown = errors.New("connection refused")
+
+// unavailableStore simula una base de datos caída sobre el CertStore que envuelve
+type unavailableStore struct {
+	database.CertStore
+}
+
+func (s unavailableStore) PingContext(ctx context.Context) error {
+	return errDatabaseDown
+}
+
+// assertErrorCode comprueba el status y el código del sobre de error de la respuesta
+func assertErrorCode(t *testing.T, rec *httptest.ResponseRecorder, wantStatus int, wantCode string) {
+	t.Helper()
+
+	var body apierror.Response
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding error response: %v", err)
+	}
+	if rec.Code != wantStatus || body.Error.Code != wantCode {
+		t.Errorf("got status %d code %q, want %d %q", rec.Code, body.Error.Code, wantStatus, wantCode)
+	}
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/ocsp"
+	"signerflow-crl/services"
+)
+
+// maxOCSPRequestSize limita el cuerpo de las peticiones OCSP
+const maxOCSPRequestSize = 64 * 1024
+
+type OCSPHandler struct {
+	responder *services.OCSPResponder
+}
+
+func NewOCSPHandler(responder *services.OCSPResponder) *OCSPHandler {
+	return &OCSPHandler{
+		responder: responder,
+	}
+}
+
+func (h *OCSPHandler) HandlePost(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxOCSPRequestSize))
+	if err != nil {
+		h.writeResponse(c, ocsp.MalformedRequestErrorResponse)
+		return
+	}
+
+	h.respond(c, body)
+}
+
+// HandleGet atiende peticiones OCSP codificadas en base64 en la URL (RFC 6960, apéndice A.1)
+func (h *OCSPHandler) HandleGet(c *gin.Context) {
+	encoded := strings.TrimPrefix(c.Param("request"), "/")
+
+	unescaped, err := url.PathUnescape(encoded)
+	if err != nil {
+		h.writeResponse(c, ocsp.MalformedRequestErrorResponse)
+		return
+	}
+
+	body, err := base64.StdEncoding.DecodeString(unescaped)
+	if err != nil {
+		h.writeResponse(c, ocsp.MalformedRequestErrorResponse)
+		return
+	}
+
+	h.respond(c, body)
+}
+
+func (h *OCSPHandler) respond(c *gin.Context, body []byte) {
+	response, err := h.responder.Respond(c.Request.Context(), body)
+	if err != nil {
+		h.writeResponse(c, ocsp.InternalErrorErrorResponse)
+		return
+	}
+
+	h.writeResponse(c, response)
+}
+
+func (h *OCSPHandler) writeResponse(c *gin.Context, response []byte) {
+	c.Data(http.StatusOK, "application/ocsp-response", response)
+}
@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"signerflow-crl/apierror"
+	"signerflow-crl/database"
+	"signerflow-crl/i18n"
+	"signerflow-crl/models"
+	"signerflow-crl/requestid"
+	"signerflow-crl/services"
+)
+
+type SourceHandler struct {
+	db database.CertStore
+}
+
+func NewSourceHandler(db database.CertStore) *SourceHandler {
+	return &SourceHandler{
+		db: db,
+	}
+}
+
+type addSourceRequest struct {
+	URL        string `json:"url" binding:"required"`
+	Label      string `json:"label"`
+	Enabled    *bool  `json:"enabled"`
+	ClientCert string `json:"client_cert"`
+	ClientKey  string `json:"client_key"`
+	CABundle   string `json:"ca_bundle"`
+}
+
+type updateSourceRequest struct {
+	Enabled *bool `json:"enabled"`
+}
+
+func (h *SourceHandler) ListSources(c *gin.Context) {
+	sources, err := h.db.ListCRLSources(c.Request.Context())
+	if err != nil {
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, i18n.MsgListSourcesFailed)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sources": sources,
+	})
+}
+
+func (h *SourceHandler) AddSource(c *gin.Context) {
+	var req addSourceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, i18n.MsgSourceURLRequired)
+		return
+	}
+
+	req.URL = strings.TrimSpace(req.URL)
+	if !isValidSourceURL(req.URL) {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidURL, i18n.MsgInvalidCRLURL)
+		return
+	}
+
+	source := &models.CRLSource{
+		URL:        req.URL,
+		Label:      strings.TrimSpace(req.Label),
+		Enabled:    req.Enabled == nil || *req.Enabled,
+		ClientCert: strings.TrimSpace(req.ClientCert),
+		ClientKey:  strings.TrimSpace(req.ClientKey),
+		CABundle:   strings.TrimSpace(req.CABundle),
+	}
+
+	// Cargar los archivos ahora para no descubrir el error recién en el próximo procesamiento
+	if _, err := services.NewClientTLSConfig(source.ClientCert, source.ClientKey, source.CABundle); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidTLSConfig, i18n.MsgInvalidTLSConfig, err)
+		return
+	}
+
+	err := h.db.AddCRLSource(c.Request.Context(), source)
+	if errors.Is(err, database.ErrDuplicateSource) {
+		apierror.Respond(c, http.StatusConflict, apierror.CodeAlreadyExists, i18n.MsgSourceExists)
+		return
+	}
+	if err != nil {
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, i18n.MsgAddSourceFailed)
+		return
+	}
+
+	c.JSON(http.StatusCreated, source)
+}
+
+func (h *SourceHandler) DeleteSource(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidID, i18n.MsgInvalidSourceID)
+		return
+	}
+
+	err = h.db.DeleteCRLSource(c.Request.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, i18n.MsgSourceNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, i18n.MsgDeleteSourceFailed)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// UpdateSource habilita o deshabilita una fuente. Una fuente deshabilitada no se procesa,
+// pero sus certificados revocados y la información de su CRL se conservan.
+func (h *SourceHandler) UpdateSource(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidID, i18n.MsgInvalidSourceID)
+		return
+	}
+
+	var req updateSourceRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Enabled == nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, i18n.MsgEnabledRequired)
+		return
+	}
+
+	source, err := h.db.SetCRLSourceEnabled(c.Request.Context(), id, *req.Enabled)
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, i18n.MsgSourceNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, i18n.MsgUpdateSourceFailed)
+		return
+	}
+
+	c.JSON(http.StatusOK, source)
+}
+
+// Resultados de registrar una URL descubierta como fuente
+const (
+	discoveredAdded       = "added"
+	discoveredExists      = "exists"
+	discoveredUnsupported = "unsupported"
+	discoveredFailed      = "failed"
+)
+
+// discoveredSource es el resultado de registrar como fuente una URL de CRL descubierta
+type discoveredSource struct {
+	URL    string            `json:"url"`
+	Status string            `json:"status"`
+	Source *models.CRLSource `json:"source,omitempty"`
+}
+
+// DiscoverSources recibe un certificado en DER o PEM y devuelve las URLs de CRL de su
+// extensión CRL Distribution Points. Con ?add=true además registra como fuentes habilitadas
+// las que usan un esquema soportado y todavía no están registradas.
+func (h *SourceHandler) DiscoverSources(c *gin.Context) {
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, maxCertificateUploadSize))
+	if err != nil || len(data) == 0 {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeCertificateRequired, i18n.MsgCertificateRequired)
+		return
+	}
+
+	points, err := services.DiscoverDistributionPoints(data)
+	if err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidCertificate, i18n.MsgInvalidCertificate)
+		return
+	}
+
+	if c.Query("add") != "true" {
+		c.JSON(http.StatusOK, points)
+		return
+	}
+
+	sources := make([]discoveredSource, 0, len(points.CRLURLs))
+	for _, crlURL := range points.CRLURLs {
+		result := discoveredSource{URL: crlURL}
+		if !isValidSourceURL(crlURL) {
+			result.Status = discoveredUnsupported
+			sources = append(sources, result)
+			continue
+		}
+
+		source := &models.CRLSource{URL: crlURL, Enabled: true}
+		err := h.db.AddCRLSource(c.Request.Context(), source)
+		switch {
+		case errors.Is(err, database.ErrDuplicateSource):
+			result.Status = discoveredExists
+		case err != nil:
+			requestid.Logf(c.Request.Context(), "Error adding discovered CRL source %s: %v", crlURL, err)
+			result.Status = discoveredFailed
+		default:
+			result.Status = discoveredAdded
+			result.Source = source
+		}
+		sources = append(sources, result)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"subject":  points.Subject,
+		"issuer":   points.Issuer,
+		"crl_urls": points.CRLURLs,
+		"sources":  sources,
+	})
+}
+
+const (
+	defaultHistoryLimit = 100
+	maxHistoryLimit     = 1000
+)
+
+// GetHistory devuelve el historial de intentos de procesamiento de CRLs, opcionalmente de una URL
+func (h *SourceHandler) GetHistory(c *gin.Context) {
+	limit := defaultHistoryLimit
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidParameter, i18n.MsgInvalidLimit)
+			return
+		}
+		limit = parsed
+	}
+	if limit > maxHistoryLimit {
+		limit = maxHistoryLimit
+	}
+
+	entries, err := h.db.GetProcessingHistory(c.Request.Context(), strings.TrimSpace(c.Query("url")), limit)
+	if err != nil {
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, i18n.MsgHistoryFailed)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"history": entries,
+		"count":   len(entries),
+	})
+}
+
+func isValidSourceURL(rawURL string) bool {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return false
+	}
+	switch parsed.Scheme {
+	case "http", "https", "ldap", "ldaps":
+		return true
+	default:
+		return false
+	}
+}
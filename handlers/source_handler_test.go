@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"signerflow-crl/apierror"
+	"signerflow-crl/database"
+	"signerflow-crl/models"
+)
+
+func TestUpdateSourceTogglesEnabled(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	source := &models.CRLSource{URL: "http://crl.example/paused.crl", Label: "Paused CA", Enabled: true}
+	if err := store.AddCRLSource(ctx, source); err != nil {
+		t.Fatalf("AddCRLSource: %v", err)
+	}
+	h := NewSourceHandler(store)
+
+	patch := func(id, body string) *httptest.ResponseRecorder {
+		return serve(h.UpdateSource, http.MethodPatch, "/sources/:id", "/sources/"+id, strings.NewReader(body), "Content-Type", "application/json")
+	}
+
+	rec := patch(strconv.Itoa(source.ID), `{"enabled": false}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var updated models.CRLSource
+	if err := json.Unmarshal(rec.Body.Bytes(), &updated); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if updated.ID != source.ID || updated.Enabled {
+		t.Errorf("got source %+v, want source %d disabled", updated, source.ID)
+	}
+
+	// El listado refleja el estado
+	rec = serve(h.ListSources, http.MethodGet, "/sources", "/sources", nil)
+	var listing struct {
+		Sources []models.CRLSource `json:"sources"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &listing); err != nil {
+		t.Fatalf("decoding listing: %v", err)
+	}
+	if len(listing.Sources) != 1 || listing.Sources[0].Enabled {
+		t.Errorf("got sources %+v, want the source listed as disabled", listing.Sources)
+	}
+
+	if rec := patch(strconv.Itoa(source.ID), `{"enabled": true}`); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"enabled":true`) {
+		t.Errorf("re-enabling: got status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	assertErrorCode(t, patch("abc", `{"enabled": false}`), http.StatusBadRequest, apierror.CodeInvalidID)
+	assertErrorCode(t, patch(strconv.Itoa(source.ID), `{}`), http.StatusBadRequest, apierror.CodeInvalidRequest)
+	assertErrorCode(t, patch("999", `{"enabled": false}`), http.StatusNotFound, apierror.CodeNotFound)
+}
+
+// newCertificateWithCDP emite un certificado autofirmado con las URLs dadas en CRL
+// Distribution Points, en DER
+func newCertificateWithCDP(t *testing.T, urls ...string) []byte {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(7),
+		Subject:               pkix.Name{CommonName: "Discovered Leaf"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		CRLDistributionPoints: urls,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatalf("creating certificate: %v", err)
+	}
+	return der
+}
+
+func TestDiscoverSources(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	if err := store.AddCRLSource(ctx, &models.CRLSource{URL: "http://crl.example/known.crl", Enabled: true}); err != nil {
+		t.Fatalf("AddCRLSource: %v", err)
+	}
+	h := NewSourceHandler(store)
+	discover := func(target string, body []byte) *httptest.ResponseRecorder {
+		return serve(h.DiscoverSources, http.MethodPost, "/admin/discover", target, bytes.NewReader(body), "Content-Type", "application/pkix-cert")
+	}
+
+	der := newCertificateWithCDP(t,
+		"http://crl.example/new.crl",
+		"ldap://ldap.example/cn=Discovery%20CA?certificateRevocationList",
+		"http://crl.example/known.crl",
+		"ftp://crl.example/legacy.crl",
+	)
+	type discovery struct {
+		Subject string   `json:"subject"`
+		CRLURLs []string `json:"crl_urls"`
+		Sources []struct {
+			URL    string            `json:"url"`
+			Status string            `json:"status"`
+			Source *models.CRLSource `json:"source"`
+		} `json:"sources"`
+	}
+	decode := func(rec *httptest.ResponseRecorder) discovery {
+		t.Helper()
+		if rec.Code != http.StatusOK {
+			t.Fatalf("got status %d, want 200: %s", rec.Code, rec.Body.String())
+		}
+		var result discovery
+		if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		}
+		return result
+	}
+
+	// Sin add solo se informan las URLs, también con el certificado en PEM
+	result := decode(discover("/admin/discover", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})))
+	if result.Subject != "CN=Discovered Leaf" || len(result.CRLURLs) != 4 || result.Sources != nil {
+		t.Errorf("got %+v, want the four URLs and no sources", result)
+	}
+	if sources, _ := store.ListCRLSources(ctx); len(sources) != 1 {
+		t.Errorf("discovering without add registered %d sources, want only the existing one", len(sources))
+	}
+
+	result = decode(discover("/admin/discover?add=true", der))
+	wantStatus := map[string]string{
+		"http://crl.example/new.crl":                                      discoveredAdded,
+		"ldap://ldap.example/cn=Discovery%20CA?certificateRevocationList": discoveredAdded,
+		"http://crl.example/known.crl":                                    discoveredExists,
+		"ftp://crl.example/legacy.crl":                                    discoveredUnsupported,
+	}
+	if len(result.Sources) != len(wantStatus) {
+		t.Fatalf("got %d source results, want %d", len(result.Sources), len(wantStatus))
+	}
+	for _, source := range result.Sources {
+		if source.Status != wantStatus[source.URL] {
+			t.Errorf("%s: got status %q, want %q", source.URL, source.Status, wantStatus[source.URL])
+		}
+		if (source.Source != nil) != (source.Status == discoveredAdded) {
+			t.Errorf("%s: got source %+v for status %q", source.URL, source.Source, source.Status)
+		}
+	}
+	added, err := store.GetCRLSourceByURL(ctx, "http://crl.example/new.crl")
+	if err != nil || !added.Enabled {
+		t.Errorf("discovered source = %+v, %v; want it registered and enabled", added, err)
+	}
+	if sources, _ := store.ListCRLSources(ctx); len(sources) != 3 {
+		t.Errorf("got %d sources, want 3", len(sources))
+	}
+
+	// Un certificado sin la extensión no registra nada
+	result = decode(discover("/admin/discover?add=true", newCertificateWithCDP(t)))
+	if len(result.CRLURLs) != 0 || len(result.Sources) != 0 {
+		t.Errorf("got %+v, want no URLs and no sources", result)
+	}
+
+	assertErrorCode(t, discover("/admin/discover", nil), http.StatusBadRequest, apierror.CodeCertificateRequired)
+	assertErrorCode(t, discover("/admin/discover", []byte("not a certificate")), http.StatusBadRequest, apierror.CodeInvalidCertificate)
+}
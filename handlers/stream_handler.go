@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"signerflow-crl/apierror"
+	"signerflow-crl/i18n"
+	"signerflow-crl/requestid"
+	"signerflow-crl/services"
+)
+
+// streamMaxLineSize es el tamaño máximo de una línea del cuerpo de StreamCertificateStatus
+const streamMaxLineSize = 4096
+
+// streamRequest es una línea del cuerpo NDJSON de StreamCertificateStatus
+type streamRequest struct {
+	Serial string `json:"serial"`
+	CA     string `json:"ca"`
+}
+
+// streamError es la línea de respuesta de una consulta que no se pudo resolver; line es el
+// número de línea del cuerpo, contando desde 1
+type streamError struct {
+	Line   int             `json:"line"`
+	Serial string          `json:"serial,omitempty"`
+	Error  apierror.Detail `json:"error"`
+}
+
+// StreamCertificateStatus lee seriales de un cuerpo NDJSON, un objeto {"serial": "...",
+// "ca": "..."} por línea con ca opcional, y responde en NDJSON un CertificateStatus por línea
+// en el mismo orden, enviando cada resultado apenas se obtiene. Las líneas vacías se ignoran y
+// las que no se pueden resolver responden una línea de error sin cortar el stream; una línea
+// más larga que streamMaxLineSize termina el stream con su error.
+func (h *CertificateHandler) StreamCertificateStatus(c *gin.Context) {
+	format := c.Query("format")
+	if _, err := services.ParseSerial("1", format); errors.Is(err, services.ErrInvalidSerialFormat) {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidFormat, i18n.MsgInvalidSerialFormat)
+		return
+	}
+
+	// En HTTP/1.1 el servidor descarta el resto del cuerpo al empezar a responder, salvo en
+	// modo full duplex; HTTP/2 ya lo es y no lo admite, por lo que el error se ignora
+	_ = http.NewResponseController(c.Writer).EnableFullDuplex()
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+
+	ctx := c.Request.Context()
+	encoder := json.NewEncoder(c.Writer)
+	scanner := bufio.NewScanner(c.Request.Body)
+	scanner.Buffer(make([]byte, 0, streamMaxLineSize), streamMaxLineSize)
+
+	line := 0
+	writeError := func(serial, code string, message i18n.Message) error {
+		return encoder.Encode(streamError{
+			Line:   line,
+			Serial: serial,
+			Error:  apierror.New(c, code, i18n.T(c, message)).Error,
+		})
+	}
+
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var err error
+		var request streamRequest
+		if json.Unmarshal([]byte(text), &request) != nil || strings.TrimSpace(request.Serial) == "" {
+			err = writeError("", apierror.CodeInvalidRequest, i18n.MsgInvalidStreamLine)
+		} else if serial, parseErr := services.ParseSerial(request.Serial, format); parseErr != nil {
+			err = writeError(request.Serial, apierror.CodeInvalidSerial, i18n.MsgInvalidSerial)
+		} else {
+			if h.redis != nil {
+				h.redis.IncrementStats("stats:requests_total")
+			}
+			status, checkErr := h.crlService.CheckCertificateStatusForCA(ctx, serial, strings.TrimSpace(request.CA))
+			switch {
+			case checkErr == nil:
+				err = encoder.Encode(status)
+			case ctx.Err() != nil:
+				// El cliente se desconectó: no queda a quién responder
+				return
+			case errors.Is(checkErr, services.ErrDatabaseUnavailable):
+				err = writeError(request.Serial, apierror.CodeDegraded, i18n.MsgDatabaseUnavailable)
+			default:
+				requestid.Logf(ctx, "Error checking certificate %s in stream: %v", serial, checkErr)
+				err = writeError(request.Serial, apierror.CodeInternal, i18n.MsgCheckFailed)
+			}
+		}
+		if err != nil {
+			requestid.Logf(ctx, "Error writing certificate status stream: %v", err)
+			return
+		}
+		c.Writer.Flush()
+	}
+
+	if err := scanner.Err(); err != nil {
+		line++
+		if errors.Is(err, bufio.ErrTooLong) {
+			_ = writeError("", apierror.CodeInvalidRequest, i18n.MsgStreamLineTooLong)
+		} else {
+			requestid.Logf(ctx, "Error reading certificate status stream: %v", err)
+		}
+	}
+	c.Writer.Flush()
+}
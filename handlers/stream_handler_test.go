@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"signerflow-crl/apierror"
+	"signerflow-crl/database"
+	"signerflow-crl/models"
+)
+
+// streamLine es una línea de la respuesta de StreamCertificateStatus, de estado o de error
+type streamLine struct {
+	models.CertificateStatus
+	Line  int             `json:"line"`
+	Error apierror.Detail `json:"error"`
+}
+
+// decodeStream separa las líneas NDJSON de la respuesta
+func decodeStream(t *testing.T, body string) []streamLine {
+	t.Helper()
+
+	var lines []streamLine
+	for _, text := range strings.Split(strings.TrimSuffix(body, "\n"), "\n") {
+		var line streamLine
+		if err := json.Unmarshal([]byte(text), &line); err != nil {
+			t.Fatalf("decoding stream line %q: %v", text, err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestStreamCertificateStatus(t *testing.T) {
+	const serials = 500
+	store := database.NewMemoryStore()
+	revokedAt := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	// Los seriales pares están revocados
+	for serial := 0; serial < serials; serial += 2 {
+		seedRevoked(t, store, &models.RevokedCertificate{Serial: fmt.Sprint(10000 + serial), RevocationDate: revokedAt, CertificateAuthority: "Stream CA", IssuerDN: "CN=Stream CA"})
+	}
+	h := newTestHandler(t, store)
+
+	var body strings.Builder
+	for serial := 0; serial < serials; serial++ {
+		fmt.Fprintf(&body, "{\"serial\": \"%d\"}\n", 10000+serial)
+	}
+	rec := serve(h.StreamCertificateStatus, http.MethodPost, "/stream", "/stream?format=decimal", strings.NewReader(body.String()))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200", rec.Code)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/x-ndjson" {
+		t.Errorf("got Content-Type %q, want application/x-ndjson", got)
+	}
+
+	// Una línea por serial, en el orden pedido
+	lines := decodeStream(t, rec.Body.String())
+	if len(lines) != serials {
+		t.Fatalf("got %d lines, want %d", len(lines), serials)
+	}
+	for i, line := range lines {
+		if want := fmt.Sprint(10000 + i); line.Serial != want || line.IsRevoked != (i%2 == 0) {
+			t.Errorf("line %d: got serial %s revoked=%v, want %s revoked=%v", i+1, line.Serial, line.IsRevoked, want, i%2 == 0)
+		}
+	}
+}
+
+func TestStreamCertificateStatusLineErrors(t *testing.T) {
+	store := database.NewMemoryStore()
+	seedRevoked(t, store, &models.RevokedCertificate{Serial: "42", RevocationDate: time.Now(), CertificateAuthority: "Stream CA", IssuerDN: "CN=Stream CA"})
+	h := newTestHandler(t, store)
+
+	// Las líneas inválidas responden un error sin cortar el stream; las vacías se ignoran
+	body := strings.Join([]string{
+		`{"serial": "42"}`,
+		``,
+		`not json`,
+		`{"ca": "Stream CA"}`,
+		`{"serial": "12ab"}`,
+		`{"serial": "42", "ca": "Other CA"}`,
+	}, "\n")
+	rec := serve(h.StreamCertificateStatus, http.MethodPost, "/stream", "/stream?format=decimal", strings.NewReader(body))
+
+	lines := decodeStream(t, rec.Body.String())
+	if len(lines) != 5 {
+		t.Fatalf("got %d lines, want 5: %s", len(lines), rec.Body.String())
+	}
+	if lines[0].Serial != "42" || !lines[0].IsRevoked {
+		t.Errorf("line 1: got %+v, want serial 42 revoked", lines[0])
+	}
+	for i, want := range []struct {
+		line int
+		code string
+	}{
+		{3, apierror.CodeInvalidRequest},
+		{4, apierror.CodeInvalidRequest},
+		{5, apierror.CodeInvalidSerial},
+	} {
+		if got := lines[i+1]; got.Line != want.line || got.Error.Code != want.code {
+			t.Errorf("got error line %d code %q, want line %d %q", got.Line, got.Error.Code, want.line, want.code)
+		}
+	}
+	if last := lines[4]; last.Serial != "42" || last.IsRevoked || last.Error.Code != "" {
+		t.Errorf("line 6: got %+v, want serial 42 not revoked by another CA", last)
+	}
+
+	// Una línea demasiado larga termina el stream con su error
+	body = `{"serial": "42"}` + "\n" + `{"serial": "` + strings.Repeat("1", streamMaxLineSize) + `"}` + "\n" + `{"serial": "42"}`
+	rec = serve(h.StreamCertificateStatus, http.MethodPost, "/stream", "/stream", strings.NewReader(body))
+	lines = decodeStream(t, rec.Body.String())
+	if len(lines) != 2 || lines[1].Line != 2 || lines[1].Error.Code != apierror.CodeInvalidRequest {
+		t.Errorf("got %s, want one status and an error for line 2", rec.Body.String())
+	}
+
+	rec = serve(h.StreamCertificateStatus, http.MethodPost, "/stream", "/stream?format=octal", strings.NewReader(body))
+	assertErrorCode(t, rec, http.StatusBadRequest, apierror.CodeInvalidFormat)
+}
+
+func TestStreamCertificateStatusRespondsIncrementally(t *testing.T) {
+	store := database.NewMemoryStore()
+	seedRevoked(t, store, &models.RevokedCertificate{Serial: "7", RevocationDate: time.Now(), CertificateAuthority: "Stream CA", IssuerDN: "CN=Stream CA"})
+	h := newTestHandler(t, store)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/stream", h.StreamCertificateStatus)
+	srv := httptest.NewServer(router)
+	t.Cleanup(srv.Close)
+
+	// El cuerpo sigue abierto mientras se leen las respuestas: cada una tiene que llegar
+	// antes de enviar la línea siguiente, o la petición vence sin respuesta
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	requestBody, requestWriter := io.Pipe()
+	// El transporte espera a que termine de enviarse el cuerpo antes de devolver el error
+	context.AfterFunc(ctx, func() { requestWriter.CloseWithError(ctx.Err()) })
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, srv.URL+"/stream", requestBody)
+	if err != nil {
+		t.Fatalf("creating request: %v", err)
+	}
+	// La respuesta empieza apenas el handler lee la primera línea
+	go fmt.Fprintln(requestWriter, `{"serial": "7"}`)
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("sending request: %v", err)
+	}
+	defer resp.Body.Close()
+	responses := bufio.NewReader(resp.Body)
+
+	for i, serial := range []string{"7", "8", "7"} {
+		if i > 0 {
+			if _, err := fmt.Fprintf(requestWriter, "{\"serial\": \"%s\"}\n", serial); err != nil {
+				t.Fatalf("writing line %d: %v", i+1, err)
+			}
+		}
+		text, err := responses.ReadString('\n')
+		if err != nil {
+			t.Fatalf("line %d: no response while the request body was open: %v", i+1, err)
+		}
+		if line := decodeStream(t, text)[0]; line.Serial != serial || line.IsRevoked != (serial == "7") {
+			t.Errorf("line %d: got %s, want serial %s", i+1, text, serial)
+		}
+	}
+
+	requestWriter.Close()
+	if rest, err := io.ReadAll(responses); err != nil || len(rest) != 0 {
+		t.Errorf("got trailing response %q (err %v), want the stream to end with the request body", rest, err)
+	}
+}
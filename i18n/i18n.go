@@ -0,0 +1,75 @@
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Message identifica un mensaje de las respuestas; el texto depende del idioma
+type Message string
+
+// DefaultLang es el idioma cuando Accept-Language no pide uno soportado, para mantener
+// las respuestas que recibían los clientes existentes
+const DefaultLang = "es"
+
+// T devuelve el mensaje en el idioma de la petición. Si el mensaje tiene verbos de formato
+// se completan con args.
+func T(c *gin.Context, id Message, args ...interface{}) string {
+	return Translate(Lang(c.GetHeader("Accept-Language")), id, args...)
+}
+
+// Translate devuelve el mensaje en el idioma indicado, en el idioma por defecto si el
+// idioma no tiene el mensaje, o el propio ID si ninguno lo tiene
+func Translate(lang string, id Message, args ...interface{}) string {
+	text, ok := bundles[lang][id]
+	if !ok {
+		if text, ok = bundles[DefaultLang][id]; !ok {
+			text = string(id)
+		}
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(text, args...)
+	}
+	return text
+}
+
+// Lang elige el idioma soportado de mayor preferencia en un header Accept-Language,
+// comparando solo el idioma principal (en-US es en); sin coincidencias devuelve DefaultLang
+func Lang(acceptLanguage string) string {
+	type candidate struct {
+		lang string
+		q    float64
+	}
+
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		primary, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		if _, ok := bundles[primary]; !ok {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if ok && strings.EqualFold(strings.TrimSpace(key), "q") {
+				if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q > 0 {
+			candidates = append(candidates, candidate{lang: primary, q: q})
+		}
+	}
+	if len(candidates) == 0 {
+		return DefaultLang
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	return candidates[0].lang
+}
@@ -0,0 +1,59 @@
+package i18n
+
+import "testing"
+
+func TestLang(t *testing.T) {
+	tests := []struct {
+		acceptLanguage string
+		want           string
+	}{
+		{"", "es"},
+		{"en", "en"},
+		{"en-US,en;q=0.9", "en"},
+		{"EN-gb", "en"},
+		{"es-AR, en;q=0.8", "es"},
+		{"es;q=0.5, en", "en"},
+		{"fr, en;q=0.3", "en"},
+		{"fr, de", "es"},
+		{"en;q=0", "es"},
+	}
+	for _, tt := range tests {
+		if got := Lang(tt.acceptLanguage); got != tt.want {
+			t.Errorf("Lang(%q) = %q, want %q", tt.acceptLanguage, got, tt.want)
+		}
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	if got := Translate("en", MsgRouteNotFound); got != "Route not found" {
+		t.Errorf("got %q, want the English text", got)
+	}
+	if got := Translate("es", MsgRouteNotFound); got != "Ruta no encontrada" {
+		t.Errorf("got %q, want the Spanish text", got)
+	}
+	if got := Translate("en", MsgInsufficientScope, "admin"); got != "The access token does not have the admin scope" {
+		t.Errorf("got %q, want the scope filled in", got)
+	}
+	// Un idioma sin el mensaje usa el por defecto y un mensaje desconocido devuelve su ID
+	if got := Translate("fr", MsgRouteNotFound); got != "Ruta no encontrada" {
+		t.Errorf("got %q for an unsupported language, want the Spanish text", got)
+	}
+	if got := Translate("en", Message("unknown_message")); got != "unknown_message" {
+		t.Errorf("got %q for an unknown message, want its ID", got)
+	}
+}
+
+func TestBundlesHaveEveryMessage(t *testing.T) {
+	for lang, bundle := range bundles {
+		for id := range bundles[DefaultLang] {
+			if bundle[id] == "" {
+				t.Errorf("message %s has no %s text", id, lang)
+			}
+		}
+		for id := range bundle {
+			if _, ok := bundles[DefaultLang][id]; !ok {
+				t.Errorf("message %s of %s is missing in %s", id, lang, DefaultLang)
+			}
+		}
+	}
+}
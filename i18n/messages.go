@@ -0,0 +1,254 @@
+package i18n
+
+// Mensajes de las respuestas de la API
+const (
+	MsgInternal                 Message = "internal"
+	MsgRouteNotFound            Message = "route_not_found"
+	MsgUnauthorized             Message = "unauthorized"
+	MsgInvalidToken             Message = "invalid_token"
+	MsgInsufficientScope        Message = "insufficient_scope"
+	MsgRateLimited              Message = "rate_limited"
+	MsgTimeout                  Message = "timeout"
+	MsgInvalidExportFormat      Message = "invalid_export_format"
+	MsgListCAsFailed            Message = "list_cas_failed"
+	MsgCACertificateRequired    Message = "ca_certificate_required"
+	MsgInvalidCertificate       Message = "invalid_certificate"
+	MsgMissingCRLSign           Message = "missing_crl_sign"
+	MsgCAAlreadyExists          Message = "ca_already_exists"
+	MsgAddCAFailed              Message = "add_ca_failed"
+	MsgInvalidCAID              Message = "invalid_ca_id"
+	MsgCANotFound               Message = "ca_not_found"
+	MsgDeleteCAFailed           Message = "delete_ca_failed"
+	MsgCANameRequired           Message = "ca_name_required"
+	MsgPurgeCAFailed            Message = "purge_ca_failed"
+	MsgCADataNotFound           Message = "ca_data_not_found"
+	MsgInvalidAsOf              Message = "invalid_as_of"
+	MsgCheckFailed              Message = "check_failed"
+	MsgEncodeFailed             Message = "encode_failed"
+	MsgSignFailed               Message = "sign_failed"
+	MsgSigningDisabled          Message = "signing_disabled"
+	MsgSerialRequired           Message = "serial_required"
+	MsgInvalidSerialFormat      Message = "invalid_serial_format"
+	MsgInvalidSerial            Message = "invalid_serial"
+	MsgInvalidLimit             Message = "invalid_limit"
+	MsgInvalidOffset            Message = "invalid_offset"
+	MsgInvalidCursor            Message = "invalid_cursor"
+	MsgInvalidReason            Message = "invalid_reason"
+	MsgListCertificatesFailed   Message = "list_certificates_failed"
+	MsgCARequired               Message = "ca_required"
+	MsgRangeRequired            Message = "range_required"
+	MsgInvalidRange             Message = "invalid_range"
+	MsgRangeFailed              Message = "range_failed"
+	MsgStatsFailed              Message = "stats_failed"
+	MsgListCRLsFailed           Message = "list_crls_failed"
+	MsgCRLURLRequired           Message = "crl_url_required"
+	MsgCRLInfoNotFound          Message = "crl_info_not_found"
+	MsgCRLInfoFailed            Message = "crl_info_failed"
+	MsgCAStatsFailed            Message = "ca_stats_failed"
+	MsgReasonStatsFailed        Message = "reason_stats_failed"
+	MsgInvalidLang              Message = "invalid_lang"
+	MsgRefreshInProgress        Message = "refresh_in_progress"
+	MsgRefreshStarted           Message = "refresh_started"
+	MsgInvalidCRLURL            Message = "invalid_crl_url"
+	MsgInvalidCount             Message = "invalid_count"
+	MsgInvalidWarmCacheBody     Message = "invalid_warm_cache_body"
+	MsgTooManySerials           Message = "too_many_serials"
+	MsgInvalidSerialFormatField Message = "invalid_serial_format_field"
+	MsgCacheDisabled            Message = "cache_disabled"
+	MsgWarmCacheFailed          Message = "warm_cache_failed"
+	MsgInvalidFingerprint       Message = "invalid_fingerprint"
+	MsgFingerprintNotFound      Message = "fingerprint_not_found"
+	MsgCertificateRequired      Message = "certificate_required"
+	MsgDetailsFailed            Message = "details_failed"
+	MsgNotRevoked               Message = "not_revoked"
+	MsgListSourcesFailed        Message = "list_sources_failed"
+	MsgSourceURLRequired        Message = "source_url_required"
+	MsgSourceExists             Message = "source_exists"
+	MsgAddSourceFailed          Message = "add_source_failed"
+	MsgInvalidSourceID          Message = "invalid_source_id"
+	MsgSourceNotFound           Message = "source_not_found"
+	MsgDeleteSourceFailed       Message = "delete_source_failed"
+	MsgEnabledRequired          Message = "enabled_required"
+	MsgUpdateSourceFailed       Message = "update_source_failed"
+	MsgHistoryFailed            Message = "history_failed"
+	MsgInvalidBucket            Message = "invalid_bucket"
+	MsgThroughputFailed         Message = "throughput_failed"
+	MsgDatabaseUnavailable      Message = "database_unavailable"
+	MsgInvalidDateParam         Message = "invalid_date_param"
+	MsgInvalidRangeSerial       Message = "invalid_range_serial"
+	MsgInvalidSerialValue       Message = "invalid_serial_value"
+	MsgInvalidCRL               Message = "invalid_crl"
+	MsgInvalidTLSConfig         Message = "invalid_tls_config"
+	MsgProbeURLNotHTTP          Message = "probe_url_not_http"
+	MsgInvalidStreamLine        Message = "invalid_stream_line"
+	MsgStreamLineTooLong        Message = "stream_line_too_long"
+)
+
+// bundles tiene los textos de cada mensaje por idioma; todos los mensajes deben estar en
+// DefaultLang
+var bundles = map[string]map[Message]string{
+	"es": {
+		MsgInternal:                 "Error interno del servidor",
+		MsgRouteNotFound:            "Ruta no encontrada",
+		MsgUnauthorized:             "API key inválida o ausente",
+		MsgInvalidToken:             "Token de acceso inválido, vencido o ausente",
+		MsgInsufficientScope:        "El token de acceso no tiene el scope %s",
+		MsgRateLimited:              "Se superó el límite de peticiones por cliente, intente más tarde",
+		MsgTimeout:                  "La petición superó el tiempo máximo de respuesta",
+		MsgInvalidExportFormat:      "format debe ser csv o json",
+		MsgListCAsFailed:            "Error al listar los certificados de CA",
+		MsgCACertificateRequired:    "Debe enviar el certificado de la CA en formato DER o PEM",
+		MsgInvalidCertificate:       "No se pudo interpretar el certificado DER o PEM",
+		MsgMissingCRLSign:           "El certificado no tiene el uso de clave cRLSign",
+		MsgCAAlreadyExists:          "Ya existe un certificado de CA con el mismo identificador de clave",
+		MsgAddCAFailed:              "Error al registrar el certificado de CA",
+		MsgInvalidCAID:              "El ID del certificado de CA debe ser un entero positivo",
+		MsgCANotFound:               "No existe un certificado de CA con ese ID",
+		MsgDeleteCAFailed:           "Error al eliminar el certificado de CA",
+		MsgCANameRequired:           "Debe indicar el nombre de la CA en el parámetro ca",
+		MsgPurgeCAFailed:            "Error al eliminar los datos de la CA",
+		MsgCADataNotFound:           "No hay datos registrados para esa CA",
+		MsgInvalidAsOf:              "as_of debe tener formato RFC3339 o YYYY-MM-DD",
+		MsgCheckFailed:              "Error al verificar el estado del certificado",
+		MsgEncodeFailed:             "Error serializando la respuesta",
+		MsgSignFailed:               "Error firmando la respuesta",
+		MsgSigningDisabled:          "El servicio no está configurado para firmar respuestas",
+		MsgSerialRequired:           "Debe proporcionar el número de serie del certificado",
+		MsgInvalidSerialFormat:      "El parámetro format debe ser auto, decimal, hex o base64",
+		MsgInvalidSerial:            "No se pudo interpretar el número de serie en el formato indicado",
+		MsgInvalidLimit:             "limit debe ser un entero positivo",
+		MsgInvalidOffset:            "offset debe ser un entero mayor o igual a cero",
+		MsgInvalidCursor:            "cursor inválido",
+		MsgInvalidReason:            "reason debe ser un código numérico de revocación",
+		MsgListCertificatesFailed:   "Error al listar certificados revocados",
+		MsgCARequired:               "Debe indicar la CA en el parámetro ca",
+		MsgRangeRequired:            "Debe indicar los parámetros from y to",
+		MsgInvalidRange:             "from debe ser menor o igual que to",
+		MsgRangeFailed:              "Error al consultar el rango de seriales",
+		MsgStatsFailed:              "Error obteniendo estadísticas de base de datos",
+		MsgListCRLsFailed:           "Error obteniendo información de CRLs",
+		MsgCRLURLRequired:           "Debe proporcionar el parámetro url de la CRL",
+		MsgCRLInfoNotFound:          "No hay información registrada para esa URL",
+		MsgCRLInfoFailed:            "Error obteniendo información de la CRL",
+		MsgCAStatsFailed:            "Error obteniendo estadísticas por CA",
+		MsgReasonStatsFailed:        "Error obteniendo estadísticas por motivo",
+		MsgInvalidLang:              "lang debe ser es o en",
+		MsgRefreshInProgress:        "Ya hay una actualización de CRLs en ejecución; intente cuando termine",
+		MsgRefreshStarted:           "Actualización de CRLs iniciada en segundo plano",
+		MsgInvalidCRLURL:            "La URL de la CRL debe ser http, https, ldap o ldaps",
+		MsgInvalidCount:             "count debe ser un entero positivo",
+		MsgInvalidWarmCacheBody:     "El cuerpo debe ser JSON con la lista serials",
+		MsgTooManySerials:           "Se aceptan como máximo 10000 seriales por petición",
+		MsgInvalidSerialFormatField: "El campo format debe ser auto, decimal, hex o base64",
+		MsgCacheDisabled:            "El servicio está funcionando sin cache Redis",
+		MsgWarmCacheFailed:          "Error al precargar el cache",
+		MsgInvalidFingerprint:       "Debe proporcionar la huella SHA-256 del certificado en hexadecimal",
+		MsgFingerprintNotFound:      "No hay un certificado revocado registrado con esa huella; envíe el certificado con POST para verificarlo por serial",
+		MsgCertificateRequired:      "Debe enviar el certificado en formato DER o PEM",
+		MsgDetailsFailed:            "Error al obtener detalles del certificado",
+		MsgNotRevoked:               "El certificado no está en la lista de revocación",
+		MsgListSourcesFailed:        "Error al listar las fuentes de CRL",
+		MsgSourceURLRequired:        "Debe proporcionar la URL de la CRL",
+		MsgSourceExists:             "La URL ya está registrada",
+		MsgAddSourceFailed:          "Error al registrar la fuente de CRL",
+		MsgInvalidSourceID:          "El ID de la fuente debe ser un entero positivo",
+		MsgSourceNotFound:           "No existe una fuente de CRL con ese ID",
+		MsgDeleteSourceFailed:       "Error al eliminar la fuente de CRL",
+		MsgEnabledRequired:          "Debe indicar enabled como true o false",
+		MsgUpdateSourceFailed:       "Error al actualizar la fuente de CRL",
+		MsgHistoryFailed:            "Error al obtener el historial de procesamiento",
+		MsgInvalidBucket:            "bucket debe ser hour o day",
+		MsgThroughputFailed:         "Error obteniendo el rendimiento de procesamiento",
+		MsgDatabaseUnavailable:      "La base de datos no está disponible por el momento y el estado del certificado no está en cache; intente nuevamente en unos segundos",
+		MsgInvalidDateParam:         "%s debe tener formato RFC3339 o YYYY-MM-DD",
+		MsgInvalidRangeSerial:       "No se pudo interpretar %s en el formato indicado",
+		MsgInvalidSerialValue:       "No se pudo interpretar el serial %s",
+		MsgInvalidCRL:               "No se pudo validar la CRL: %v",
+		MsgInvalidTLSConfig:         "Configuración TLS inválida: %v",
+		MsgProbeURLNotHTTP:          "Solo se pueden probar fuentes http o https",
+		MsgInvalidStreamLine:        "Cada línea debe ser un objeto JSON con el campo serial",
+		MsgStreamLineTooLong:        "La línea supera el tamaño máximo de 4096 bytes",
+	},
+	"en": {
+		MsgInternal:                 "Internal server error",
+		MsgRouteNotFound:            "Route not found",
+		MsgUnauthorized:             "Invalid or missing API key",
+		MsgInvalidToken:             "Invalid, expired or missing access token",
+		MsgInsufficientScope:        "The access token does not have the %s scope",
+		MsgRateLimited:              "Per-client request limit exceeded, try again later",
+		MsgTimeout:                  "The request exceeded the maximum response time",
+		MsgInvalidExportFormat:      "format must be csv or json",
+		MsgListCAsFailed:            "Error listing CA certificates",
+		MsgCACertificateRequired:    "The CA certificate must be sent in DER or PEM format",
+		MsgInvalidCertificate:       "Could not parse the DER or PEM certificate",
+		MsgMissingCRLSign:           "The certificate does not have the cRLSign key usage",
+		MsgCAAlreadyExists:          "A CA certificate with the same key identifier already exists",
+		MsgAddCAFailed:              "Error registering the CA certificate",
+		MsgInvalidCAID:              "The CA certificate ID must be a positive integer",
+		MsgCANotFound:               "There is no CA certificate with that ID",
+		MsgDeleteCAFailed:           "Error deleting the CA certificate",
+		MsgCANameRequired:           "The CA name must be given in the ca parameter",
+		MsgPurgeCAFailed:            "Error deleting the CA data",
+		MsgCADataNotFound:           "There is no data registered for that CA",
+		MsgInvalidAsOf:              "as_of must use RFC3339 or YYYY-MM-DD format",
+		MsgCheckFailed:              "Error checking the certificate status",
+		MsgEncodeFailed:             "Error encoding the response",
+		MsgSignFailed:               "Error signing the response",
+		MsgSigningDisabled:          "The service is not configured to sign responses",
+		MsgSerialRequired:           "The certificate serial number is required",
+		MsgInvalidSerialFormat:      "The format parameter must be auto, decimal, hex or base64",
+		MsgInvalidSerial:            "Could not parse the serial number in the given format",
+		MsgInvalidLimit:             "limit must be a positive integer",
+		MsgInvalidOffset:            "offset must be an integer greater than or equal to zero",
+		MsgInvalidCursor:            "invalid cursor",
+		MsgInvalidReason:            "reason must be a numeric revocation code",
+		MsgListCertificatesFailed:   "Error listing revoked certificates",
+		MsgCARequired:               "The CA must be given in the ca parameter",
+		MsgRangeRequired:            "The from and to parameters are required",
+		MsgInvalidRange:             "from must be less than or equal to to",
+		MsgRangeFailed:              "Error querying the serial range",
+		MsgStatsFailed:              "Error getting database statistics",
+		MsgListCRLsFailed:           "Error getting CRL information",
+		MsgCRLURLRequired:           "The CRL url parameter is required",
+		MsgCRLInfoNotFound:          "There is no information registered for that URL",
+		MsgCRLInfoFailed:            "Error getting the CRL information",
+		MsgCAStatsFailed:            "Error getting statistics by CA",
+		MsgReasonStatsFailed:        "Error getting statistics by reason",
+		MsgInvalidLang:              "lang must be es or en",
+		MsgRefreshInProgress:        "A CRL refresh is already running; try again when it finishes",
+		MsgRefreshStarted:           "CRL refresh started in the background",
+		MsgInvalidCRLURL:            "The CRL URL must be http, https, ldap or ldaps",
+		MsgInvalidCount:             "count must be a positive integer",
+		MsgInvalidWarmCacheBody:     "The body must be JSON with the serials list",
+		MsgTooManySerials:           "At most 10000 serials are accepted per request",
+		MsgInvalidSerialFormatField: "The format field must be auto, decimal, hex or base64",
+		MsgCacheDisabled:            "The service is running without the Redis cache",
+		MsgWarmCacheFailed:          "Error warming the cache",
+		MsgInvalidFingerprint:       "The certificate SHA-256 fingerprint must be given in hexadecimal",
+		MsgFingerprintNotFound:      "There is no revoked certificate registered with that fingerprint; send the certificate with POST to check it by serial",
+		MsgCertificateRequired:      "The certificate must be sent in DER or PEM format",
+		MsgDetailsFailed:            "Error getting the certificate details",
+		MsgNotRevoked:               "The certificate is not in the revocation list",
+		MsgListSourcesFailed:        "Error listing CRL sources",
+		MsgSourceURLRequired:        "The CRL URL is required",
+		MsgSourceExists:             "The URL is already registered",
+		MsgAddSourceFailed:          "Error registering the CRL source",
+		MsgInvalidSourceID:          "The source ID must be a positive integer",
+		MsgSourceNotFound:           "There is no CRL source with that ID",
+		MsgDeleteSourceFailed:       "Error deleting the CRL source",
+		MsgEnabledRequired:          "enabled must be given as true or false",
+		MsgUpdateSourceFailed:       "Error updating the CRL source",
+		MsgHistoryFailed:            "Error getting the processing history",
+		MsgInvalidBucket:            "bucket must be hour or day",
+		MsgThroughputFailed:         "Error getting the processing throughput",
+		MsgDatabaseUnavailable:      "The database is temporarily unavailable and the certificate status is not cached; try again in a few seconds",
+		MsgInvalidDateParam:         "%s must use RFC3339 or YYYY-MM-DD format",
+		MsgInvalidRangeSerial:       "Could not parse %s in the given format",
+		MsgInvalidSerialValue:       "Could not parse the serial %s",
+		MsgInvalidCRL:               "Could not validate the CRL: %v",
+		MsgInvalidTLSConfig:         "Invalid TLS configuration: %v",
+		MsgProbeURLNotHTTP:          "Only http and https sources can be tested",
+		MsgInvalidStreamLine:        "Each line must be a JSON object with the serial field",
+		MsgStreamLineTooLong:        "The line exceeds the maximum size of 4096 bytes",
+	},
+}
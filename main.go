@@ -1,33 +1,81 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
-	"github.com/gin-contrib/gzip"
 	"github.com/gin-gonic/gin"
+	"signerflow-crl/apierror"
 	"signerflow-crl/cache"
 	"signerflow-crl/config"
 	"signerflow-crl/database"
 	"signerflow-crl/handlers"
+	"signerflow-crl/i18n"
+	"signerflow-crl/middleware"
 	"signerflow-crl/scheduler"
 	"signerflow-crl/services"
+	"signerflow-crl/tracing"
 )
 
+const shutdownTimeout = 15 * time.Second
+
 func main() {
 	cfg := config.LoadConfig()
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Configuración inválida: %v", err)
+	}
 
-	db, err := database.NewPostgresDB(cfg.DatabaseURL)
+	shutdownTracing, err := tracing.Init(context.Background(), cfg.OTLPEndpoint, cfg.OTelServiceName)
 	if err != nil {
-		log.Fatalf("Error conectando a PostgreSQL: %v", err)
+		log.Fatalf("Error configurando trazas: %v", err)
+	}
+	// Se ejecuta al final para enviar también los spans del cierre del scheduler
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Printf("Error enviando trazas pendientes: %v", err)
+		}
+	}()
+
+	pool := database.PoolConfig{
+		MaxOpenConns:    cfg.DBMaxOpenConns,
+		MaxIdleConns:    cfg.DBMaxIdleConns,
+		ConnMaxLifetime: cfg.DBConnMaxLifetime,
+		ConnMaxIdleTime: cfg.DBConnMaxIdleTime,
+	}
+	tableConfig := database.TableConfig{
+		Schema: cfg.DBSchema,
+		Prefix: cfg.DBTablePrefix,
+	}
+
+	var db database.CertStore
+	if cfg.DBDriver == "sqlite" {
+		db, err = database.NewSQLiteDB(cfg.SQLitePath, pool, tableConfig)
+		if err != nil {
+			log.Fatalf("Error abriendo la base SQLite: %v", err)
+		}
+	} else {
+		db, err = database.NewPostgresDB(cfg.DatabaseURL, pool, tableConfig)
+		if err != nil {
+			log.Fatalf("Error conectando a PostgreSQL: %v", err)
+		}
 	}
 	defer db.Close()
 
 	var redisClient *cache.RedisClient
 	if cfg.RedisURL != "" {
-		redisClient, err = cache.NewRedisClient(cfg.RedisURL, cfg.RedisPassword, cfg.RedisDB)
+		redisClient, err = cache.NewRedisClient(cfg.RedisURL, cfg.RedisPassword, cfg.RedisDB, cfg.RedisKeyPrefix, cache.BreakerConfig{
+			Threshold: cfg.RedisBreakerThreshold,
+			Cooldown:  cfg.RedisBreakerCooldown,
+		})
 		if err != nil {
 			log.Printf("Warning: Error conectando a Redis: %v", err)
 			log.Println("Continuando sin cache Redis")
@@ -37,47 +85,169 @@ func main() {
 		}
 	}
 
-	crlService := services.NewCRLService(db, redisClient)
+	crlService, err := services.NewCRLService(db, redisClient, cfg)
+	if err != nil {
+		log.Fatalf("Error configurando servicio de CRLs: %v", err)
+	}
+	defer crlService.Close()
 
-	crlScheduler := scheduler.NewScheduler(crlService, cfg.CRLURLsFile)
+	urlCount, urlSource, err := crlService.CheckCRLURLSources(context.Background(), cfg.CRLURLsFile)
+	if err != nil {
+		log.Fatalf("No hay URLs de CRL para procesar (configure CRL_URLS_JSON o CRL_URLS_FILE, o registre fuentes en crl_sources): %v", err)
+	}
+	log.Printf("%d URLs de CRL cargadas desde %s", urlCount, urlSource)
+
+	// Con CRL_URLS_JSON el archivo no se usa y no tiene sentido vigilarlo
+	watchURLs := cfg.WatchCRLURLs && cfg.CRLURLsJSON == ""
+	crlScheduler, err := scheduler.NewScheduler(crlService, cfg.CRLURLsFile, cfg.CRLRefreshCron, cfg.CacheCleanupCron, cfg.StatsReconcileCron, cfg.OCSPProbeCron, watchURLs)
+	if err != nil {
+		log.Fatalf("Error configurando scheduler: %v", err)
+	}
 	err = crlScheduler.Start()
 	if err != nil {
 		log.Fatalf("Error iniciando scheduler: %v", err)
 	}
-	defer crlScheduler.Stop()
 
-	certificateHandler := handlers.NewCertificateHandler(crlService, db, redisClient)
+	var responseSigner *services.ResponseSigner
+	if cfg.ResponseSigningKey != "" {
+		responseSigner, err = services.NewResponseSigner(cfg.ResponseSigningKey)
+		if err != nil {
+			log.Fatalf("Error configurando firma de respuestas: %v", err)
+		}
+	}
+
+	var adminJWT *middleware.JWTVerifier
+	if cfg.AdminAuthMode == "jwt" {
+		adminJWT, err = middleware.NewJWTVerifier(middleware.JWTConfig{
+			Issuer:        cfg.JWTIssuer,
+			Audience:      cfg.JWTAudience,
+			JWKSURL:       cfg.JWTJWKSURL,
+			PublicKeyFile: cfg.JWTPublicKey,
+			RequiredScope: cfg.JWTRequiredScope,
+			JWKSRefresh:   cfg.JWTJWKSRefresh,
+		})
+		if err != nil {
+			log.Fatalf("Error configurando autenticación JWT: %v", err)
+		}
+		defer adminJWT.Close()
+	}
+
+	certificateHandler := handlers.NewCertificateHandler(crlService, db, redisClient, cfg, responseSigner, adminJWT)
+
+	var ocspHandler *handlers.OCSPHandler
+	if cfg.OCSPResponderCert != "" {
+		responder, err := services.NewOCSPResponder(crlService, db, cfg.OCSPResponderCert, cfg.OCSPResponderKey, cfg.OCSPIssuerCerts)
+		if err != nil {
+			log.Fatalf("Error configurando responder OCSP: %v", err)
+		}
+		ocspHandler = handlers.NewOCSPHandler(responder)
+	}
+
+	sourceHandler := handlers.NewSourceHandler(db)
+	caHandler := handlers.NewCAHandler(crlService, db)
 
-	router := setupRouter(certificateHandler)
+	router := setupRouter(cfg, adminJWT, certificateHandler, sourceHandler, caHandler, ocspHandler)
+
+	srv := &http.Server{
+		Addr:    ":" + cfg.Port,
+		Handler: router,
+	}
+
+	listener, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		log.Fatalf("Error iniciando servidor: %v", err)
+	}
+	log.Printf("Servidor iniciado en puerto %s", cfg.Port)
 
+	// SIGHUP recarga el filtro de códigos de motivo del webhook sin reiniciar
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
 	go func() {
-		log.Printf("Servidor iniciado en puerto %s", cfg.Port)
-		if err := router.Run(":" + cfg.Port); err != nil {
-			log.Fatalf("Error iniciando servidor: %v", err)
+		for range reload {
+			if err := crlService.ReloadWebhookFilter(); err != nil {
+				log.Printf("Error recargando filtro del webhook, se mantiene el anterior: %v", err)
+				continue
+			}
+			log.Println("Filtro de códigos de motivo del webhook recargado")
 		}
 	}()
 
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+
+	// Esperar a que terminen las peticiones en curso antes de cerrar scheduler, Redis y PostgreSQL
+	if err := serveUntilSignal(srv, listener, quit, shutdownTimeout); err != nil {
+		log.Printf("Error cerrando servidor HTTP: %v", err)
+	}
+
+	log.Println("Servidor HTTP detenido")
+
+	// Cancelar los procesamientos de CRLs en curso; cada importación guarda su lote pendiente
+	// antes de terminar, y se espera a lo sumo shutdownTimeout
+	drainCtx, drainCancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer drainCancel()
+
+	if err := crlScheduler.Stop(drainCtx); err != nil {
+		log.Printf("El scheduler no terminó a tiempo: %v", err)
+	}
+	flushed, err := crlService.StopProcessing(drainCtx)
+	if err != nil {
+		log.Printf("Los procesamientos de CRLs no terminaron a tiempo: %v", err)
+	}
+	log.Printf("Procesamientos de CRLs detenidos: %d certificados pendientes guardados durante el cierre", flushed)
+}
+
+// serveUntilSignal atiende peticiones en listener hasta recibir una señal en quit y entonces
+// cierra el servidor, esperando a lo sumo timeout a que terminen las peticiones en curso
+func serveUntilSignal(srv *http.Server, listener net.Listener, quit <-chan os.Signal, timeout time.Duration) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.Serve(listener)
+	}()
+
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("error serving HTTP: %v", err)
+	case <-quit:
+	}
 
 	log.Println("Cerrando servidor...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return srv.Shutdown(ctx)
 }
 
-func setupRouter(handler *handlers.CertificateHandler) *gin.Engine {
+func setupRouter(cfg *config.Config, adminJWT *middleware.JWTVerifier, handler *handlers.CertificateHandler, sourceHandler *handlers.SourceHandler, caHandler *handlers.CAHandler, ocspHandler *handlers.OCSPHandler) *gin.Engine {
 	gin.SetMode(gin.ReleaseMode)
 
 	router := gin.New()
-	router.Use(gin.Logger())
-	router.Use(gin.Recovery())
+	// Sin proxies de confianza ClientIP usa la IP de la conexión, de modo que el límite de
+	// peticiones y RATE_LIMIT_ALLOWLIST no dependen de un X-Forwarded-For del cliente
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("TRUSTED_PROXIES inválido: %v", err)
+	}
+	router.Use(middleware.RequestID())
+	router.Use(middleware.Tracing())
+	router.Use(middleware.AccessLog(cfg.AccessLogSkipPaths, cfg.AccessLogSampleRate))
+	router.Use(gin.CustomRecovery(func(c *gin.Context, recovered interface{}) {
+		apierror.Abort(c, http.StatusInternalServerError, apierror.CodeInternal, i18n.MsgInternal)
+	}))
+
+	// Usar compresión gzip para reducir tamaño de respuestas. Los health checks y OCSP
+	// responden cuerpos pequeños o binarios que no vale la pena comprimir
+	if cfg.GzipEnabled {
+		router.Use(middleware.Gzip(cfg.GzipMinSize, []string{"/api/v1/health", "/ocsp"}))
+	}
 
-	// Usar compresión gzip para reducir tamaño de respuestas
-	router.Use(gzip.Gzip(gzip.DefaultCompression))
+	// Las exportaciones, los streams y las operaciones de administración pueden tardar más que una consulta
+	router.Use(middleware.Timeout(cfg.RequestTimeout, []string{"/api/v1/certificates/export", "/api/v1/certificates/stream", "/api/v1/admin"}))
 
 	router.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type")
+		c.Header("Access-Control-Allow-Methods", "GET, POST, PATCH, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key, X-Request-ID, If-None-Match, traceparent, tracestate")
+		c.Header("Access-Control-Expose-Headers", "X-Request-ID, Retry-After, X-Cert-Status, X-CRL-Stale, X-Signature, X-Signature-Key-ID, ETag, X-Service-Degraded")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -90,21 +260,62 @@ func setupRouter(handler *handlers.CertificateHandler) *gin.Engine {
 	v1 := router.Group("/api/v1")
 	{
 		v1.GET("/health", handler.GetHealth)
+		v1.GET("/health/live", handler.GetLiveness)
+		v1.GET("/health/ready", handler.GetReadiness)
 		v1.GET("/stats", handler.GetStats)
+		v1.GET("/stats/ca", handler.GetStatsByCA)
+		v1.GET("/stats/reasons", handler.GetReasonStats)
+		v1.GET("/stats/throughput", handler.GetThroughput)
+		v1.GET("/stats/consistency", handler.GetConsistency)
+		v1.GET("/reasons", handler.GetReasons)
+		v1.GET("/pubkey", handler.GetPublicKey)
+		v1.GET("/crls", handler.ListCRLs)
+		v1.GET("/crls/detail", handler.GetCRLDetail)
 
 		certificates := v1.Group("/certificates")
+		certificates.Use(middleware.RateLimit(cfg.RateLimitRPS, cfg.RateLimitBurst, cfg.RateLimitAllowlist))
 		{
 			certificates.GET("/check/:serial", handler.CheckCertificate)
 			certificates.GET("/valid/:serial", handler.ValidCertificate)
 			certificates.GET("/details/:serial", handler.GetCertificateDetails)
+			certificates.POST("/verify", handler.VerifyCertificate)
+			certificates.GET("/list", handler.ListCertificates)
+			certificates.GET("/range", handler.ListCertificatesInRange)
+			certificates.GET("/export", handler.ExportCertificates)
+			certificates.POST("/stream", handler.StreamCertificateStatus)
+			certificates.GET("/check-fingerprint/:sha256", handler.CheckFingerprint)
+			certificates.POST("/check-fingerprint", handler.CheckFingerprintUpload)
 		}
 
 		admin := v1.Group("/admin")
+		admin.Use(middleware.AdminAuth(cfg.AdminAPIKey, adminJWT))
 		{
 			admin.POST("/refresh", handler.ForceRefresh)
+			admin.POST("/dry-run", handler.DryRunCRL)
+			admin.GET("/test-source", handler.TestSource)
+			admin.POST("/warm-cache", handler.WarmCache)
+			admin.GET("/sources", sourceHandler.ListSources)
+			admin.POST("/sources", sourceHandler.AddSource)
+			admin.PATCH("/sources/:id", sourceHandler.UpdateSource)
+			admin.DELETE("/sources/:id", sourceHandler.DeleteSource)
+			admin.POST("/discover", sourceHandler.DiscoverSources)
+			admin.GET("/history", sourceHandler.GetHistory)
+			admin.GET("/cas", caHandler.ListCAs)
+			admin.POST("/cas", caHandler.UploadCA)
+			admin.DELETE("/cas/:id", caHandler.DeleteCA)
+			admin.DELETE("/ca-data", caHandler.PurgeCAData)
 		}
 	}
 
+	if ocspHandler != nil {
+		router.POST("/ocsp", ocspHandler.HandlePost)
+		router.GET("/ocsp/*request", ocspHandler.HandleGet)
+	}
+
+	router.NoRoute(func(c *gin.Context) {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, i18n.MsgRouteNotFound)
+	})
+
 	router.GET("/", func(c *gin.Context) {
 		c.JSON(200, gin.H{
 			"service":     "SignerFlow CRL Service",
@@ -112,10 +323,33 @@ func setupRouter(handler *handlers.CertificateHandler) *gin.Engine {
 			"description": "Servicio de verificación de certificados revocados",
 			"endpoints": gin.H{
 				"health":              "/api/v1/health",
+				"health_live":         "/api/v1/health/live",
+				"health_ready":        "/api/v1/health/ready",
 				"stats":               "/api/v1/stats",
+				"stats_by_ca":         "/api/v1/stats/ca",
+				"stats_by_reason":     "/api/v1/stats/reasons",
+				"stats_throughput":    "/api/v1/stats/throughput?bucket={hour|day}",
+				"stats_consistency":   "/api/v1/stats/consistency",
+				"reasons":             "/api/v1/reasons?lang={es|en}",
+				"pubkey":              "/api/v1/pubkey",
+				"crls":                "/api/v1/crls",
+				"crl_detail":          "/api/v1/crls/detail?url={url}",
 				"check_certificate":   "/api/v1/certificates/check/:serial",
 				"certificate_details": "/api/v1/certificates/details/:serial",
+				"verify_certificate":  "/api/v1/certificates/verify",
+				"list_certificates":   "/api/v1/certificates/list",
+				"serial_range":        "/api/v1/certificates/range?ca={ca}&from={serial}&to={serial}",
+				"export_certificates": "/api/v1/certificates/export",
+				"stream_status":       "/api/v1/certificates/stream",
+				"check_fingerprint":   "/api/v1/certificates/check-fingerprint/:sha256",
 				"force_refresh":       "/api/v1/admin/refresh",
+				"crl_dry_run":         "/api/v1/admin/dry-run?url={url}",
+				"crl_test_source":     "/api/v1/admin/test-source?url={url}",
+				"warm_cache":          "/api/v1/admin/warm-cache",
+				"crl_sources":         "/api/v1/admin/sources",
+				"discover_sources":    "/api/v1/admin/discover?add={true|false}",
+				"crl_history":         "/api/v1/admin/history",
+				"ca_certificates":     "/api/v1/admin/cas",
 			},
 		})
 	})
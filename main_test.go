@@ -0,0 +1,72 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestServeUntilSignalCompletesInFlightRequests(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		io.WriteString(w, "done")
+	})}
+
+	quit := make(chan os.Signal, 1)
+	served := make(chan error, 1)
+	go func() {
+		served <- serveUntilSignal(srv, listener, quit, 5*time.Second)
+	}()
+
+	type result struct {
+		body string
+		err  error
+	}
+	response := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String())
+		if err != nil {
+			response <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		response <- result{body: string(body), err: err}
+	}()
+
+	<-started
+	quit <- syscall.SIGTERM
+
+	// El cierre espera a la petición en curso
+	select {
+	case err := <-served:
+		t.Fatalf("server stopped with a request in flight: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+	res := <-response
+	if res.err != nil || res.body != "done" {
+		t.Fatalf("in-flight request got body %q and error %v, want it completed", res.body, res.err)
+	}
+	if err := <-served; err != nil {
+		t.Fatalf("serveUntilSignal: %v", err)
+	}
+
+	// Tras el cierre no se aceptan nuevas conexiones
+	if _, err := http.Get("http://" + listener.Addr().String()); err == nil {
+		t.Error("server accepted a request after shutdown")
+	}
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"log"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"signerflow-crl/requestid"
+)
+
+// AccessLog registra cada petición en una línea clave=valor con método, ruta, estado, latencia,
+// IP del cliente e ID de la petición. Las respuestas exitosas de las rutas que empiezan con
+// alguno de skippedPrefixes no se registran, y las demás exitosas se registran con
+// probabilidad sampleRate (1 registra todas). Los errores (estado 400 o mayor) se registran
+// siempre, incluso en las rutas omitidas.
+func AccessLog(skippedPrefixes []string, sampleRate float64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		path := c.Request.URL.Path
+		if c.Request.URL.RawQuery != "" {
+			path += "?" + c.Request.URL.RawQuery
+		}
+
+		c.Next()
+
+		status := c.Writer.Status()
+		if status < 400 {
+			for _, prefix := range skippedPrefixes {
+				if strings.HasPrefix(c.Request.URL.Path, prefix) {
+					return
+				}
+			}
+			if sampleRate < 1 && rand.Float64() >= sampleRate {
+				return
+			}
+		}
+
+		fields := []string{
+			"method=" + logfmtValue(c.Request.Method),
+			"path=" + logfmtValue(path),
+			"status=" + strconv.Itoa(status),
+			"latency_ms=" + strconv.FormatFloat(float64(time.Since(start).Microseconds())/1000, 'f', 3, 64),
+			"client_ip=" + logfmtValue(c.ClientIP()),
+			"request_id=" + logfmtValue(requestid.FromContext(c.Request.Context())),
+		}
+		if errs := c.Errors.String(); errs != "" {
+			fields = append(fields, "errors="+logfmtValue(strings.TrimSpace(errs)))
+		}
+		log.Printf("access %s", strings.Join(fields, " "))
+	}
+}
+
+// logfmtValue entrecomilla el valor si contiene espacios, comillas o signos igual, o si está vacío
+func logfmtValue(value string) string {
+	if value == "" || strings.ContainsAny(value, " \t\r\n\"=") {
+		return strconv.Quote(value)
+	}
+	return value
+}
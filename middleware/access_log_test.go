@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"signerflow-crl/requestid"
+)
+
+// newAccessLogRouter registra rutas que responden el estado pedido detrás de RequestID y AccessLog
+func newAccessLogRouter(skippedPrefixes []string, sampleRate float64) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestID())
+	router.Use(AccessLog(skippedPrefixes, sampleRate))
+
+	respond := func(c *gin.Context) {
+		switch c.Query("fail") {
+		case "client":
+			c.Status(http.StatusNotFound)
+		case "server":
+			c.Error(errors.New("redis unavailable"))
+			c.Status(http.StatusServiceUnavailable)
+		default:
+			c.Status(http.StatusOK)
+		}
+	}
+	router.GET("/api/v1/health", respond)
+	router.GET("/api/v1/health/ready", respond)
+	router.GET("/api/v1/certificates/check/:serial", respond)
+	return router
+}
+
+// captureLogs redirige el log estándar a un buffer durante la prueba
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return &logs
+}
+
+func TestAccessLogSkipsHealthChecksAndLogsErrors(t *testing.T) {
+	logs := captureLogs(t)
+	router := newAccessLogRouter([]string{"/api/v1/health"}, 1)
+
+	tests := []struct {
+		target  string
+		logged  bool
+		wantLog string
+	}{
+		{"/api/v1/health", false, ""},
+		{"/api/v1/health/ready", false, ""},
+		{"/api/v1/certificates/check/1001", true, "method=GET path=/api/v1/certificates/check/1001 status=200"},
+		// Los errores se registran aunque la ruta se omita
+		{"/api/v1/health/ready?fail=server", true, "status=503"},
+		{"/api/v1/certificates/check/1001?fail=client", true, "status=404"},
+	}
+	for _, tt := range tests {
+		logs.Reset()
+		req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		req.Header.Set(requestid.Header, "access-log-test")
+		req.RemoteAddr = "192.0.2.10:5555"
+		router.ServeHTTP(httptest.NewRecorder(), req)
+
+		line := logs.String()
+		if logged := strings.Contains(line, "access "); logged != tt.logged {
+			t.Errorf("%s: got logged=%v, want %v (log %q)", tt.target, logged, tt.logged, line)
+			continue
+		}
+		if !tt.logged {
+			continue
+		}
+		for _, want := range []string{tt.wantLog, "client_ip=192.0.2.10", "request_id=access-log-test", "latency_ms="} {
+			if !strings.Contains(line, want) {
+				t.Errorf("%s: log %q does not contain %q", tt.target, line, want)
+			}
+		}
+	}
+}
+
+func TestAccessLogSamplesSuccessfulRequests(t *testing.T) {
+	logs := captureLogs(t)
+
+	// Con tasa 0 no se registra ninguna respuesta exitosa, pero sí todos los errores
+	router := newAccessLogRouter(nil, 0)
+	for i := 0; i < 20; i++ {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/certificates/check/1001", nil))
+	}
+	if logs.Len() != 0 {
+		t.Errorf("successful requests were logged with sample rate 0: %q", logs.String())
+	}
+	for i := 0; i < 20; i++ {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/certificates/check/1001?fail=server", nil))
+	}
+	if got := strings.Count(logs.String(), "access "); got != 20 {
+		t.Errorf("got %d error lines with sample rate 0, want 20", got)
+	}
+	if !strings.Contains(logs.String(), `errors="Error #01: redis unavailable"`) {
+		t.Errorf("error lines do not include the handler errors: %q", logs.String())
+	}
+
+	// Con una tasa intermedia se registra una parte de las exitosas
+	logs.Reset()
+	router = newAccessLogRouter(nil, 0.5)
+	for i := 0; i < 400; i++ {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/certificates/check/1001", nil))
+	}
+	if got := strings.Count(logs.String(), "access "); got < 100 || got > 300 {
+		t.Errorf("got %d of 400 requests logged with sample rate 0.5, want about 200", got)
+	}
+}
+
+func TestLogfmtValue(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"GET", "GET"},
+		{"/check/1001?ca=Test CA", `"/check/1001?ca=Test CA"`},
+		{"a=b", `"a=b"`},
+		{"", `""`},
+	}
+	for _, tt := range tests {
+		if got := logfmtValue(tt.value); got != tt.want {
+			t.Errorf("logfmtValue(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
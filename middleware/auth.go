@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"signerflow-crl/apierror"
+	"signerflow-crl/i18n"
+)
+
+// APIKeyAuth exige el header X-API-Key con la clave configurada.
+// Si la clave está vacía el endpoint queda abierto y se emite una advertencia.
+func APIKeyAuth(apiKey string) gin.HandlerFunc {
+	if apiKey == "" {
+		log.Println("WARNING: ADMIN_API_KEY no configurada, los endpoints de administración están abiertos")
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	return func(c *gin.Context) {
+		if !HasAPIKey(c, apiKey) {
+			apierror.Abort(c, http.StatusUnauthorized, apierror.CodeUnauthorized, i18n.MsgUnauthorized)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// HasAPIKey indica si la petición trae en X-API-Key la clave configurada; con la clave vacía
+// acepta cualquier petición, igual que APIKeyAuth. Sirve para opciones de endpoints públicos
+// reservadas a administradores.
+func HasAPIKey(c *gin.Context, apiKey string) bool {
+	if apiKey == "" {
+		return true
+	}
+	provided := c.GetHeader("X-API-Key")
+	return provided != "" && subtle.ConstantTimeCompare([]byte(provided), []byte(apiKey)) == 1
+}
+
+// AdminAuth protege los endpoints de administración con un token JWT si verifier no es nil
+// (ADMIN_AUTH_MODE=jwt) o con la API key en caso contrario
+func AdminAuth(apiKey string, verifier *JWTVerifier) gin.HandlerFunc {
+	if verifier != nil {
+		return JWTAuth(verifier)
+	}
+	return APIKeyAuth(apiKey)
+}
+
+// IsAdmin indica si la petición se autentica como administrador según el modo de AdminAuth
+func IsAdmin(c *gin.Context, apiKey string, verifier *JWTVerifier) bool {
+	if verifier != nil {
+		return HasJWT(c, verifier)
+	}
+	return HasAPIKey(c, apiKey)
+}
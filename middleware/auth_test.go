@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newAuthRouter protege un endpoint de prueba con la API key dada
+func newAuthRouter(apiKey string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/admin/refresh", APIKeyAuth(apiKey), func(c *gin.Context) {
+		c.Status(http.StatusAccepted)
+	})
+	return router
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured string
+		provided   string
+		wantStatus int
+	}{
+		{"accepted", "secret-key", "secret-key", http.StatusAccepted},
+		{"wrong key", "secret-key", "other-key", http.StatusUnauthorized},
+		{"missing key", "secret-key", "", http.StatusUnauthorized},
+		{"unconfigured", "", "", http.StatusAccepted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/admin/refresh", nil)
+			if tt.provided != "" {
+				req.Header.Set("X-API-Key", tt.provided)
+			}
+			rec := httptest.NewRecorder()
+			newAuthRouter(tt.configured).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("got status %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+var gzipWriterPool = sync.Pool{
+	New: func() interface{} {
+		gz, _ := gzip.NewWriterLevel(io.Discard, gzip.DefaultCompression)
+		return gz
+	},
+}
+
+// gzipResponseWriter acumula el cuerpo hasta alcanzar minSize y recién entonces decide
+// comprimir, para no pagar el costo de gzip en respuestas pequeñas
+type gzipResponseWriter struct {
+	gin.ResponseWriter
+	minSize     int
+	buf         []byte
+	gz          *gzip.Writer
+	passthrough bool
+}
+
+func (w *gzipResponseWriter) Write(data []byte) (int, error) {
+	if w.gz != nil {
+		return w.gz.Write(data)
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(data)
+	}
+
+	w.buf = append(w.buf, data...)
+	if len(w.buf) >= w.minSize {
+		if err := w.startGzip(); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (w *gzipResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Written considera escrito el cuerpo acumulado aunque todavía no se haya enviado
+func (w *gzipResponseWriter) Written() bool {
+	return len(w.buf) > 0 || w.ResponseWriter.Written()
+}
+
+// Flush comprime desde el primer envío: las respuestas que se envían por partes, como las
+// exportaciones, suelen ser grandes y ya no se pueden cambiar los headers después
+func (w *gzipResponseWriter) Flush() {
+	if w.gz == nil && !w.passthrough {
+		if err := w.startGzip(); err != nil {
+			return
+		}
+	}
+	if w.gz != nil {
+		_ = w.gz.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// Unwrap expone el writer original a http.ResponseController, por ejemplo para habilitar el
+// modo full duplex en los streams
+func (w *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// startGzip fija los headers de la respuesta comprimida y envía lo acumulado por gzip. Si
+// el handler ya definió otra codificación el cuerpo se envía sin cambios.
+func (w *gzipResponseWriter) startGzip() error {
+	buffered := w.buf
+	w.buf = nil
+
+	header := w.Header()
+	if header.Get("Content-Encoding") != "" {
+		w.passthrough = true
+		_, err := w.ResponseWriter.Write(buffered)
+		return err
+	}
+
+	header.Set("Content-Encoding", "gzip")
+	header.Del("Content-Length")
+	// El ETag fuerte identifica los bytes sin comprimir
+	if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		header.Set("ETag", "W/"+etag)
+	}
+
+	w.gz = gzipWriterPool.Get().(*gzip.Writer)
+	w.gz.Reset(w.ResponseWriter)
+	_, err := w.gz.Write(buffered)
+	return err
+}
+
+// finish cierra el stream gzip o envía sin comprimir lo que no alcanzó minSize
+func (w *gzipResponseWriter) finish() {
+	if w.gz != nil {
+		_ = w.gz.Close()
+		w.gz.Reset(io.Discard)
+		gzipWriterPool.Put(w.gz)
+		w.gz = nil
+		return
+	}
+	if len(w.buf) > 0 {
+		w.Header().Set("Content-Length", strconv.Itoa(len(w.buf)))
+		_, _ = w.ResponseWriter.Write(w.buf)
+		w.buf = nil
+	}
+}
+
+// Gzip comprime con gzip las respuestas de al menos minSize bytes cuando el cliente lo
+// acepta en Accept-Encoding. Las rutas que empiezan con alguno de excludedPrefixes no se
+// comprimen.
+func Gzip(minSize int, excludedPrefixes []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, prefix := range excludedPrefixes {
+			if strings.HasPrefix(c.Request.URL.Path, prefix) {
+				c.Next()
+				return
+			}
+		}
+
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+		if !acceptsGzip(c.GetHeader("Accept-Encoding")) || c.Request.Method == "HEAD" {
+			c.Next()
+			return
+		}
+
+		writer := &gzipResponseWriter{ResponseWriter: c.Writer, minSize: minSize}
+		c.Writer = writer
+		completed := false
+		defer func() {
+			// Ante un pánico se descarta lo acumulado para que el recovery responda limpio
+			if !completed {
+				writer.buf = nil
+			}
+			writer.finish()
+			c.Writer = writer.ResponseWriter
+		}()
+
+		c.Next()
+		completed = true
+	}
+}
+
+// acceptsGzip indica si Accept-Encoding admite gzip (o *) con un q distinto de cero
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if ok && strings.EqualFold(strings.TrimSpace(key), "q") {
+				if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q > 0 {
+			return true
+		}
+	}
+	return false
+}
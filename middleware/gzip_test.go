@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+const gzipTestMinSize = 1024
+
+// largeBody supera el umbral de compresión
+var largeBody = strings.Repeat(`{"serial":"1234567890","status":"revoked"},`, 100)
+
+// newGzipRouter registra respuestas grandes, pequeñas y por partes detrás de Gzip
+func newGzipRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Gzip(gzipTestMinSize, []string{"/api/v1/health"}))
+
+	router.GET("/large", func(c *gin.Context) {
+		c.Header("ETag", `"abc"`)
+		c.String(http.StatusOK, largeBody)
+	})
+	router.GET("/small", func(c *gin.Context) {
+		c.String(http.StatusOK, "OK")
+	})
+	router.GET("/stream", func(c *gin.Context) {
+		c.Header("Content-Type", "text/csv")
+		for i := 0; i < 3; i++ {
+			c.Writer.WriteString("serial,status\n")
+			c.Writer.Flush()
+		}
+	})
+	router.GET("/api/v1/health", func(c *gin.Context) {
+		c.String(http.StatusOK, largeBody)
+	})
+	return router
+}
+
+// getWithEncoding envía la petición con el Accept-Encoding dado
+func getWithEncoding(router *gin.Engine, path, acceptEncoding string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+// gunzip descomprime el cuerpo de la respuesta
+func gunzip(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+
+	reader, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("response is not gzip: %v", err)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("reading gzip body: %v", err)
+	}
+	return string(data)
+}
+
+func TestGzipCompressesLargeResponses(t *testing.T) {
+	router := newGzipRouter()
+
+	rec := getWithEncoding(router, "/large", "br, gzip;q=0.8")
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("got Content-Encoding %q, want gzip", rec.Header().Get("Content-Encoding"))
+	}
+	if rec.Body.Len() >= len(largeBody) {
+		t.Errorf("compressed body is %d bytes, not smaller than the original %d", rec.Body.Len(), len(largeBody))
+	}
+	if body := gunzip(t, rec); body != largeBody {
+		t.Errorf("decompressed body does not match the original")
+	}
+	if vary := rec.Header().Get("Vary"); vary != "Accept-Encoding" {
+		t.Errorf("got Vary %q, want Accept-Encoding", vary)
+	}
+	if etag := rec.Header().Get("ETag"); etag != `W/"abc"` {
+		t.Errorf("got ETag %q, want the weak W/\"abc\"", etag)
+	}
+
+	// Las respuestas que se envían por partes se comprimen desde el primer envío
+	rec = getWithEncoding(router, "/stream", "gzip")
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("streamed response: got Content-Encoding %q, want gzip", rec.Header().Get("Content-Encoding"))
+	}
+	if body := gunzip(t, rec); body != strings.Repeat("serial,status\n", 3) {
+		t.Errorf("streamed response: got %q", body)
+	}
+}
+
+func TestGzipLeavesResponsesUncompressed(t *testing.T) {
+	router := newGzipRouter()
+
+	tests := []struct {
+		name           string
+		path           string
+		acceptEncoding string
+		want           string
+	}{
+		{"below threshold", "/small", "gzip", "OK"},
+		{"client without gzip", "/large", "", largeBody},
+		{"gzip refused", "/large", "gzip;q=0, identity", largeBody},
+		{"excluded path", "/api/v1/health", "gzip", largeBody},
+	}
+	for _, tt := range tests {
+		rec := getWithEncoding(router, tt.path, tt.acceptEncoding)
+		if encoding := rec.Header().Get("Content-Encoding"); encoding != "" {
+			t.Errorf("%s: got Content-Encoding %q, want none", tt.name, encoding)
+		}
+		if rec.Body.String() != tt.want {
+			t.Errorf("%s: body changed", tt.name)
+		}
+	}
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"gzip", true},
+		{"GZIP", true},
+		{"deflate, gzip", true},
+		{"gzip;q=0.5", true},
+		{"*", true},
+		{"gzip;q=0", false},
+		{"*;q=0", false},
+		{"deflate, br", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := acceptsGzip(tt.header); got != tt.want {
+			t.Errorf("acceptsGzip(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
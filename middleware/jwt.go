@@ -0,0 +1,295 @@
+package middleware
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/MicahParks/keyfunc/v3"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/time/rate"
+	"signerflow-crl/apierror"
+	"signerflow-crl/i18n"
+)
+
+const (
+	// jwtLeeway es la tolerancia de reloj al comprobar exp y nbf
+	jwtLeeway = time.Minute
+	// jwksMinRefresh es el intervalo mínimo entre descargas del JWKS al encontrar un kid desconocido
+	jwksMinRefresh = time.Minute
+	// jwksTimeout acota la descarga del JWKS
+	jwksTimeout = 10 * time.Second
+	// jwtMinRSABits es el tamaño mínimo de las claves RSA que se aceptan
+	jwtMinRSABits = 2048
+)
+
+// jwtAlgorithms son los algoritmos de firma aceptados; none y los HMAC quedan fuera, ya que
+// con ellos cualquiera que conozca la clave pública podría firmar tokens
+var jwtAlgorithms = []string{
+	"RS256", "RS384", "RS512",
+	"PS256", "PS384", "PS512",
+	"ES256", "ES384", "ES512",
+	"EdDSA",
+}
+
+var (
+	// ErrInvalidToken indica un token mal formado, con firma inválida, vencido o emitido
+	// para otro emisor o audiencia
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrInsufficientScope indica un token válido sin el scope requerido
+	ErrInsufficientScope = errors.New("insufficient scope")
+)
+
+// JWTConfig configura la validación de tokens de administración. Las claves se toman del
+// JWKS publicado en JWKSURL o del PEM PublicKeyFile (clave pública o certificado); con
+// RequiredScope vacío no se exige ningún scope.
+type JWTConfig struct {
+	Issuer        string
+	Audience      string
+	JWKSURL       string
+	PublicKeyFile string
+	RequiredScope string
+	// Antigüedad máxima del JWKS descargado antes de volver a pedirlo
+	JWKSRefresh time.Duration
+}
+
+// JWTVerifier valida tokens JWT firmados con RSA (RS*, PS*), ECDSA (ES*) o Ed25519 (EdDSA).
+// El parseo y la verificación de la firma y los claims registrados los hace golang-jwt, y
+// el JWKS lo descarga y renueva keyfunc.
+type JWTVerifier struct {
+	cfg    JWTConfig
+	parser *jwt.Parser
+	// keys devuelve la función que elige la clave con la que verificar cada token; el
+	// contexto acota la descarga del JWKS ante un kid desconocido
+	keys func(ctx context.Context) jwt.Keyfunc
+	// cancel detiene la renovación periódica del JWKS
+	cancel context.CancelFunc
+}
+
+// jwtClaims son los claims que se validan además de los registrados. scp admite un string o
+// una lista; scope es una lista separada por espacios (RFC 8693).
+type jwtClaims struct {
+	jwt.RegisteredClaims
+	Scope string     `json:"scope"`
+	Scp   stringList `json:"scp"`
+}
+
+// stringList acepta un string o un array de strings; un string se separa por espacios
+type stringList []string
+
+func (l *stringList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*l = strings.Fields(single)
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+// NewJWTVerifier crea el verificador. Con PublicKeyFile la clave se lee al crearlo; con
+// JWKSURL se descarga al crearlo y se renueva cada JWKSRefresh o al recibir un kid
+// desconocido, como mucho una vez por jwksMinRefresh. Close detiene la renovación.
+func NewJWTVerifier(cfg JWTConfig) (*JWTVerifier, error) {
+	v := &JWTVerifier{
+		cfg: cfg,
+		parser: jwt.NewParser(
+			jwt.WithValidMethods(jwtAlgorithms),
+			jwt.WithIssuer(cfg.Issuer),
+			jwt.WithAudience(cfg.Audience),
+			jwt.WithExpirationRequired(),
+			jwt.WithLeeway(jwtLeeway),
+		),
+		cancel: func() {},
+	}
+
+	if cfg.PublicKeyFile != "" {
+		key, err := loadPublicKey(cfg.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		v.keys = func(context.Context) jwt.Keyfunc {
+			return func(*jwt.Token) (interface{}, error) { return key, nil }
+		}
+		return v, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	jwks, err := keyfunc.NewDefaultOverrideCtx(ctx, []string{cfg.JWKSURL}, keyfunc.Override{
+		Client:          &http.Client{Timeout: jwksTimeout},
+		HTTPTimeout:     jwksTimeout,
+		RefreshInterval: cfg.JWKSRefresh,
+		// Un kid desconocido dentro de jwksMinRefresh se rechaza sin esperar al limitador;
+		// la espera máxima también acota la descarga
+		RefreshUnknownKID: rate.NewLimiter(rate.Every(jwksMinRefresh), 1),
+		RateLimitWaitMax:  jwksTimeout,
+		RefreshErrorHandlerFunc: func(url string) func(context.Context, error) {
+			return func(_ context.Context, err error) {
+				log.Printf("Error fetching JWKS from %s: %v", url, err)
+			}
+		},
+	})
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("error configuring JWKS: %v", err)
+	}
+	v.keys = func(ctx context.Context) jwt.Keyfunc {
+		return strongKeys(jwks.KeyfuncCtx(ctx))
+	}
+	v.cancel = cancel
+	return v, nil
+}
+
+// Close detiene la renovación periódica del JWKS
+func (v *JWTVerifier) Close() {
+	v.cancel()
+}
+
+// strongKeys descarta las claves RSA del JWKS más chicas que jwtMinRSABits. Sin kid keyfunc
+// devuelve todas las claves del JWKS y se prueban las que quedan.
+func strongKeys(keys jwt.Keyfunc) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		key, err := keys(token)
+		if err != nil {
+			return nil, err
+		}
+		set, ok := key.(jwt.VerificationKeySet)
+		if !ok {
+			return key, checkKeySize(key)
+		}
+		var strong jwt.VerificationKeySet
+		for _, key := range set.Keys {
+			if checkKeySize(key) == nil {
+				strong.Keys = append(strong.Keys, key)
+			}
+		}
+		if len(strong.Keys) == 0 {
+			return nil, fmt.Errorf("no usable key in the JWKS")
+		}
+		return strong, nil
+	}
+}
+
+// checkKeySize rechaza las claves RSA de menos de jwtMinRSABits; go.mod declara una versión
+// de Go en la que el mínimo de crypto/rsa no está activo por defecto
+func checkKeySize(key crypto.PublicKey) error {
+	if pub, ok := key.(*rsa.PublicKey); ok && pub.N.BitLen() < jwtMinRSABits {
+		return fmt.Errorf("RSA key of %d bits is smaller than %d bits", pub.N.BitLen(), jwtMinRSABits)
+	}
+	return nil
+}
+
+// loadPublicKey lee una clave pública PKIX o la de un certificado desde un archivo PEM
+func loadPublicKey(path string) (crypto.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading JWT public key: %v", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("JWT public key %s is not PEM", path)
+	}
+
+	var key crypto.PublicKey
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing JWT public key: %v", err)
+		}
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing JWT certificate: %v", err)
+		}
+		key = cert.PublicKey
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q in JWT public key", block.Type)
+	}
+	if err := checkKeySize(key); err != nil {
+		return nil, fmt.Errorf("JWT public key %s: %v", path, err)
+	}
+	return key, nil
+}
+
+// Verify valida la firma, el emisor, la audiencia y la vigencia del token, y el scope
+// requerido. Devuelve el sujeto del token; los errores envuelven ErrInvalidToken o
+// ErrInsufficientScope.
+func (v *JWTVerifier) Verify(ctx context.Context, token string) (string, error) {
+	var claims jwtClaims
+	if _, err := v.parser.ParseWithClaims(token, &claims, v.keys(ctx)); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	if v.cfg.RequiredScope == "" {
+		return claims.Subject, nil
+	}
+	if slices.Contains(strings.Fields(claims.Scope), v.cfg.RequiredScope) || slices.Contains(claims.Scp, v.cfg.RequiredScope) {
+		return claims.Subject, nil
+	}
+	return claims.Subject, fmt.Errorf("%w: %q is required", ErrInsufficientScope, v.cfg.RequiredScope)
+}
+
+// JWTAuth exige un token válido en Authorization: Bearer. Un token inválido o ausente
+// responde 401 y uno sin el scope requerido 403.
+func JWTAuth(verifier *JWTVerifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := bearerToken(c)
+		if !ok {
+			c.Header("WWW-Authenticate", `Bearer`)
+			apierror.Abort(c, http.StatusUnauthorized, apierror.CodeUnauthorized, i18n.MsgInvalidToken)
+			return
+		}
+
+		subject, err := verifier.Verify(c.Request.Context(), token)
+		if errors.Is(err, ErrInsufficientScope) {
+			log.Printf("JWT for %q rejected on %s: %v", subject, c.Request.URL.Path, err)
+			c.Header("WWW-Authenticate", `Bearer error="insufficient_scope"`)
+			apierror.Abort(c, http.StatusForbidden, apierror.CodeForbidden, i18n.MsgInsufficientScope, verifier.cfg.RequiredScope)
+			return
+		}
+		if err != nil {
+			log.Printf("JWT rejected on %s: %v", c.Request.URL.Path, err)
+			c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			apierror.Abort(c, http.StatusUnauthorized, apierror.CodeUnauthorized, i18n.MsgInvalidToken)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// HasJWT indica si la petición trae un token válido con el scope requerido
+func HasJWT(c *gin.Context, verifier *JWTVerifier) bool {
+	token, ok := bearerToken(c)
+	if !ok {
+		return false
+	}
+	_, err := verifier.Verify(c.Request.Context(), token)
+	return err == nil
+}
+
+func bearerToken(c *gin.Context) (string, bool) {
+	scheme, token, ok := strings.Cut(c.GetHeader("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
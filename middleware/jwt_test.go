@@ -0,0 +1,287 @@
+package middleware
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// testJWKS publica las claves de firma dadas y cuenta las descargas del JWKS
+type testJWKS struct {
+	mu      sync.Mutex
+	keys    map[string]crypto.Signer
+	fetches atomic.Int32
+	server  *httptest.Server
+}
+
+func newTestJWKS(t *testing.T) *testJWKS {
+	t.Helper()
+
+	jwks := &testJWKS{keys: make(map[string]crypto.Signer)}
+	jwks.add(t, "test-key", newECKey(t))
+	jwks.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		jwks.fetches.Add(1)
+		jwks.mu.Lock()
+		defer jwks.mu.Unlock()
+
+		keys := make([]map[string]string, 0, len(jwks.keys))
+		for kid, key := range jwks.keys {
+			keys = append(keys, publicJWK(kid, key.Public()))
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+	}))
+	t.Cleanup(jwks.server.Close)
+	return jwks
+}
+
+func newECKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	return key
+}
+
+func newRSAKey(t *testing.T, bits int) *rsa.PrivateKey {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		t.Fatalf("generating %d-bit RSA key: %v", bits, err)
+	}
+	return key
+}
+
+// add publica una clave más en el JWKS
+func (j *testJWKS) add(t *testing.T, kid string, key crypto.Signer) {
+	t.Helper()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.keys[kid] = key
+}
+
+// publicJWK codifica una clave pública P-256 o RSA como JWK de firma
+func publicJWK(kid string, key crypto.PublicKey) map[string]string {
+	encode := func(data []byte) string { return base64.RawURLEncoding.EncodeToString(data) }
+	switch pub := key.(type) {
+	case *ecdsa.PublicKey:
+		return map[string]string{
+			"kty": "EC", "kid": kid, "use": "sig", "alg": "ES256", "crv": "P-256",
+			"x": encode(pub.X.FillBytes(make([]byte, 32))),
+			"y": encode(pub.Y.FillBytes(make([]byte, 32))),
+		}
+	case *rsa.PublicKey:
+		return map[string]string{
+			"kty": "RSA", "kid": kid, "use": "sig", "alg": "RS256",
+			"n": encode(pub.N.Bytes()),
+			"e": encode(big.NewInt(int64(pub.E)).Bytes()),
+		}
+	}
+	panic("unsupported key type")
+}
+
+// sign emite un token con los claims dados, firmado con la clave kid del JWKS
+func (j *testJWKS) sign(t *testing.T, kid string, claims map[string]interface{}) string {
+	t.Helper()
+
+	j.mu.Lock()
+	key := j.keys[kid]
+	j.mu.Unlock()
+	return signToken(t, kid, key, claims)
+}
+
+func signToken(t *testing.T, kid string, key crypto.Signer, claims map[string]interface{}) string {
+	t.Helper()
+
+	method := jwt.SigningMethod(jwt.SigningMethodES256)
+	if _, ok := key.(*rsa.PrivateKey); ok {
+		method = jwt.SigningMethodRS256
+	}
+	token := jwt.NewWithClaims(method, jwt.MapClaims(claims))
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("signing token: %v", err)
+	}
+	return signed
+}
+
+func newTestVerifier(t *testing.T, jwks *testJWKS) *JWTVerifier {
+	t.Helper()
+
+	verifier, err := NewJWTVerifier(JWTConfig{
+		Issuer:        "https://issuer.example",
+		Audience:      "signerflow-crl",
+		JWKSURL:       jwks.server.URL,
+		RequiredScope: "crl:admin",
+		JWKSRefresh:   time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("NewJWTVerifier: %v", err)
+	}
+	t.Cleanup(verifier.Close)
+	return verifier
+}
+
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"iss":   "https://issuer.example",
+		"sub":   "operator",
+		"aud":   "signerflow-crl",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"scope": "openid crl:admin",
+	}
+}
+
+func TestJWTVerify(t *testing.T) {
+	jwks := newTestJWKS(t)
+	verifier := newTestVerifier(t, jwks)
+
+	tests := []struct {
+		name    string
+		modify  func(claims map[string]interface{})
+		wantErr error
+	}{
+		{"valid", func(map[string]interface{}) {}, nil},
+		{"audience list", func(c map[string]interface{}) { c["aud"] = []string{"other", "signerflow-crl"} }, nil},
+		{"scp list", func(c map[string]interface{}) { delete(c, "scope"); c["scp"] = []string{"crl:admin"} }, nil},
+		{"expired", func(c map[string]interface{}) { c["exp"] = time.Now().Add(-2 * jwtLeeway).Unix() }, ErrInvalidToken},
+		{"not valid yet", func(c map[string]interface{}) { c["nbf"] = time.Now().Add(2 * jwtLeeway).Unix() }, ErrInvalidToken},
+		{"missing exp", func(c map[string]interface{}) { delete(c, "exp") }, ErrInvalidToken},
+		{"wrong audience", func(c map[string]interface{}) { c["aud"] = "another-service" }, ErrInvalidToken},
+		{"wrong issuer", func(c map[string]interface{}) { c["iss"] = "https://evil.example" }, ErrInvalidToken},
+		{"missing scope", func(c map[string]interface{}) { c["scope"] = "openid" }, ErrInsufficientScope},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := validClaims()
+			tt.modify(claims)
+
+			subject, err := verifier.Verify(context.Background(), jwks.sign(t, "test-key", claims))
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && subject != "operator" {
+				t.Errorf("got subject %q, want operator", subject)
+			}
+		})
+	}
+}
+
+func TestJWTVerifyRejectsTamperedSignature(t *testing.T) {
+	jwks := newTestJWKS(t)
+	verifier := newTestVerifier(t, jwks)
+
+	token := jwks.sign(t, "test-key", validClaims())
+	other := jwks.sign(t, "test-key", map[string]interface{}{"sub": "attacker"})
+	// Firma válida de otro payload
+	tampered := token[:strings.LastIndex(token, ".")] + other[strings.LastIndex(other, "."):]
+
+	if _, err := verifier.Verify(context.Background(), tampered); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("got error %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestJWTVerifyRejectsDisallowedAlgorithms(t *testing.T) {
+	jwks := newTestJWKS(t)
+	verifier := newTestVerifier(t, jwks)
+
+	hmac := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims(validClaims()))
+	hmac.Header["kid"] = "test-key"
+	hmacToken, err := hmac.SignedString([]byte("shared secret"))
+	if err != nil {
+		t.Fatalf("signing HS256 token: %v", err)
+	}
+	none := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims(validClaims()))
+	none.Header["kid"] = "test-key"
+	noneToken, err := none.SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatalf("encoding unsigned token: %v", err)
+	}
+
+	for name, token := range map[string]string{"HS256": hmacToken, "none": noneToken} {
+		if _, err := verifier.Verify(context.Background(), token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: got error %v, want ErrInvalidToken", name, err)
+		}
+	}
+}
+
+func TestJWTVerifyRejectsWeakRSAKeys(t *testing.T) {
+	jwks := newTestJWKS(t)
+	jwks.add(t, "weak-rsa", newRSAKey(t, 1024))
+	jwks.add(t, "strong-rsa", newRSAKey(t, 2048))
+	verifier := newTestVerifier(t, jwks)
+
+	if _, err := verifier.Verify(context.Background(), jwks.sign(t, "weak-rsa", validClaims())); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("token signed with a 1024-bit RSA key: got error %v, want ErrInvalidToken", err)
+	}
+	if _, err := verifier.Verify(context.Background(), jwks.sign(t, "strong-rsa", validClaims())); err != nil {
+		t.Errorf("token signed with a 2048-bit RSA key: %v", err)
+	}
+
+	// Una clave fija débil se rechaza al configurar el verificador
+	der, err := x509.MarshalPKIXPublicKey(newRSAKey(t, 1024).Public())
+	if err != nil {
+		t.Fatalf("encoding public key: %v", err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "jwt.pem")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o644); err != nil {
+		t.Fatalf("writing public key: %v", err)
+	}
+	if _, err := NewJWTVerifier(JWTConfig{Issuer: "https://issuer.example", Audience: "signerflow-crl", PublicKeyFile: keyFile}); err == nil {
+		t.Error("NewJWTVerifier accepted a 1024-bit RSA public key")
+	}
+}
+
+func TestJWTRefreshesJWKSForUnknownKeyID(t *testing.T) {
+	jwks := newTestJWKS(t)
+	verifier := newTestVerifier(t, jwks)
+
+	if _, err := verifier.Verify(context.Background(), jwks.sign(t, "test-key", validClaims())); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if fetches := jwks.fetches.Load(); fetches != 1 {
+		t.Fatalf("got %d JWKS downloads, want 1", fetches)
+	}
+
+	// Una clave rotada se descarga al ver su kid por primera vez
+	jwks.add(t, "rotated-key", newECKey(t))
+	if _, err := verifier.Verify(context.Background(), jwks.sign(t, "rotated-key", validClaims())); err != nil {
+		t.Fatalf("Verify with the rotated key: %v", err)
+	}
+	if fetches := jwks.fetches.Load(); fetches != 2 {
+		t.Errorf("got %d JWKS downloads after the rotation, want 2", fetches)
+	}
+
+	// Dentro de jwksMinRefresh otro kid desconocido no vuelve a descargar el JWKS
+	unknown := signToken(t, "unknown-key", newECKey(t), validClaims())
+	if _, err := verifier.Verify(context.Background(), unknown); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("got error %v for an unknown key, want ErrInvalidToken", err)
+	}
+	if fetches := jwks.fetches.Load(); fetches != 2 {
+		t.Errorf("got %d JWKS downloads after an unknown key, want 2", fetches)
+	}
+}
@@ -0,0 +1,139 @@
+package middleware
+
+import (
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+	"signerflow-crl/apierror"
+	"signerflow-crl/i18n"
+)
+
+// rateLimiterIdleTTL es el tiempo sin peticiones tras el cual se descarta el limitador de una IP
+const rateLimiterIdleTTL = 10 * time.Minute
+
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// ipRateLimiter mantiene un token bucket por IP de cliente
+type ipRateLimiter struct {
+	mu        sync.Mutex
+	clients   map[string]*clientLimiter
+	limit     rate.Limit
+	burst     int
+	lastSweep time.Time
+}
+
+func (l *ipRateLimiter) get(ip string, now time.Time) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Descartar periódicamente los limitadores de IPs inactivas para acotar la memoria
+	if now.Sub(l.lastSweep) > rateLimiterIdleTTL {
+		for key, client := range l.clients {
+			if now.Sub(client.lastSeen) > rateLimiterIdleTTL {
+				delete(l.clients, key)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	client, ok := l.clients[ip]
+	if !ok {
+		client = &clientLimiter{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.clients[ip] = client
+	}
+	client.lastSeen = now
+
+	return client.limiter
+}
+
+// RateLimit limita las peticiones por IP de cliente con un token bucket de rps peticiones
+// por segundo y ráfagas de burst. Las IPs o rangos CIDR de allowlist no se limitan.
+// Con rps <= 0 el middleware no aplica ningún límite.
+func RateLimit(rps float64, burst int, allowlist []string) gin.HandlerFunc {
+	if rps <= 0 {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	allowed := parseAllowlist(allowlist)
+	limiter := &ipRateLimiter{
+		clients:   make(map[string]*clientLimiter),
+		limit:     rate.Limit(rps),
+		burst:     burst,
+		lastSweep: time.Now(),
+	}
+
+	return func(c *gin.Context) {
+		clientIP := c.ClientIP()
+		if ip := net.ParseIP(clientIP); ip != nil && isAllowlisted(ip, allowed) {
+			c.Next()
+			return
+		}
+
+		now := time.Now()
+		reservation := limiter.get(clientIP, now).ReserveN(now, 1)
+		if delay := reservation.DelayFrom(now); !reservation.OK() || delay > 0 {
+			reservation.CancelAt(now)
+
+			retryAfter := int(math.Ceil(delay.Seconds()))
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			apierror.Abort(c, http.StatusTooManyRequests, apierror.CodeRateLimited, i18n.MsgRateLimited)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// parseAllowlist interpreta cada entrada como IP o rango CIDR, ignorando las inválidas
+func parseAllowlist(entries []string) []*net.IPNet {
+	var networks []*net.IPNet
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				log.Printf("WARNING: entrada inválida en RATE_LIMIT_ALLOWLIST: %q", entry)
+				continue
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			log.Printf("WARNING: entrada inválida en RATE_LIMIT_ALLOWLIST: %q", entry)
+			continue
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+func isAllowlisted(ip net.IP, networks []*net.IPNet) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"signerflow-crl/apierror"
+)
+
+// newRateLimitRouter limita un endpoint de prueba; las peticiones de 203.0.113.1 llegan por
+// un proxy de confianza que informa la IP del cliente en X-Forwarded-For
+func newRateLimitRouter(t *testing.T, rps float64, burst int, allowlist []string) *gin.Engine {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	if err := router.SetTrustedProxies([]string{"203.0.113.1"}); err != nil {
+		t.Fatalf("SetTrustedProxies: %v", err)
+	}
+	router.GET("/certificates/check/:serial", RateLimit(rps, burst, allowlist), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return router
+}
+
+// check envía una verificación desde remoteAddr, opcionalmente con X-Forwarded-For
+func check(router *gin.Engine, remoteAddr, forwardedFor string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/certificates/check/42", nil)
+	req.RemoteAddr = remoteAddr
+	if forwardedFor != "" {
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRateLimitRejectsRequestsOverBurst(t *testing.T) {
+	const burst = 3
+	router := newRateLimitRouter(t, 0.1, burst, []string{"10.1.0.0/16", "192.0.2.99"})
+
+	for i := 1; i <= burst; i++ {
+		if rec := check(router, "192.0.2.1:1234", ""); rec.Code != http.StatusOK {
+			t.Fatalf("request %d: got status %d, want 200", i, rec.Code)
+		}
+	}
+
+	rec := check(router, "192.0.2.1:1234", "")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("request %d: got status %d, want 429", burst+1, rec.Code)
+	}
+	if retryAfter, err := strconv.Atoi(rec.Header().Get("Retry-After")); err != nil || retryAfter < 1 {
+		t.Errorf("got Retry-After %q, want a positive number of seconds", rec.Header().Get("Retry-After"))
+	}
+	var body apierror.Response
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Error.Code != apierror.CodeRateLimited {
+		t.Errorf("got body %s, want error code %s", rec.Body, apierror.CodeRateLimited)
+	}
+
+	// Cada cliente tiene su propio bucket, también detrás del proxy de confianza
+	if rec := check(router, "192.0.2.2:1234", ""); rec.Code != http.StatusOK {
+		t.Errorf("other client: got status %d, want 200", rec.Code)
+	}
+	if rec := check(router, "203.0.113.1:1234", "198.51.100.7"); rec.Code != http.StatusOK {
+		t.Errorf("client behind proxy: got status %d, want 200", rec.Code)
+	}
+
+	// Las IPs y rangos del allowlist no se limitan
+	for _, remoteAddr := range []string{"10.1.2.3:1234", "192.0.2.99:1234"} {
+		for i := 0; i < 2*burst; i++ {
+			if rec := check(router, remoteAddr, ""); rec.Code != http.StatusOK {
+				t.Fatalf("allowlisted %s request %d: got status %d, want 200", remoteAddr, i+1, rec.Code)
+			}
+		}
+	}
+}
+
+func TestRateLimitDisabled(t *testing.T) {
+	router := newRateLimitRouter(t, 0, 1, nil)
+	for i := 0; i < 10; i++ {
+		if rec := check(router, "192.0.2.1:1234", ""); rec.Code != http.StatusOK {
+			t.Fatalf("request %d: got status %d, want 200", i+1, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"signerflow-crl/requestid"
+)
+
+// maxRequestIDLength evita propagar IDs arbitrariamente largos enviados por el cliente
+const maxRequestIDLength = 128
+
+// RequestID toma el X-Request-ID recibido o genera uno nuevo, lo guarda en el contexto
+// de gin y en el de la petición, y lo devuelve en la respuesta
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestid.Header)
+		if id == "" || len(id) > maxRequestIDLength {
+			id = requestid.New()
+		}
+
+		c.Set("request_id", id)
+		c.Request = c.Request.WithContext(requestid.NewContext(c.Request.Context(), id))
+		c.Header(requestid.Header, id)
+
+		c.Next()
+	}
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"signerflow-crl/requestid"
+)
+
+func TestRequestIDPropagatesToLogsAndResponse(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestID())
+	router.GET("/check", func(c *gin.Context) {
+		requestid.Logf(c.Request.Context(), "checking certificate")
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name     string
+		provided string
+		wantSame bool
+	}{
+		{"provided", "client-trace-42", true},
+		{"missing", "", false},
+		{"too long", strings.Repeat("x", maxRequestIDLength+1), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs.Reset()
+			req := httptest.NewRequest(http.MethodGet, "/check", nil)
+			if tt.provided != "" {
+				req.Header.Set(requestid.Header, tt.provided)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			id := rec.Header().Get(requestid.Header)
+			if id == "" {
+				t.Fatal("response has no request ID")
+			}
+			if (id == tt.provided) != tt.wantSame {
+				t.Errorf("got request ID %q for provided %q", id, tt.provided)
+			}
+			if !strings.Contains(logs.String(), "["+id+"] checking certificate") {
+				t.Errorf("log %q does not carry request ID %q", logs.String(), id)
+			}
+		})
+	}
+}
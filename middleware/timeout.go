@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"signerflow-crl/apierror"
+	"signerflow-crl/i18n"
+)
+
+// Timeout limita el contexto de cada petición a timeout, de modo que las consultas a
+// PostgreSQL y Redis se cancelen al vencer. Si el handler termina sin responder por el
+// vencimiento se responde 504. Las rutas que empiezan con alguno de excludedPrefixes no
+// tienen límite, igual que todas si timeout es 0.
+func Timeout(timeout time.Duration, excludedPrefixes []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+		for _, prefix := range excludedPrefixes {
+			if strings.HasPrefix(c.Request.URL.Path, prefix) {
+				c.Next()
+				return
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		if !c.Writer.Written() && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			apierror.Abort(c, http.StatusGatewayTimeout, apierror.CodeTimeout, i18n.MsgTimeout)
+		}
+	}
+}
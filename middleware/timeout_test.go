@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"signerflow-crl/apierror"
+	"signerflow-crl/i18n"
+)
+
+const testRequestTimeout = 50 * time.Millisecond
+
+// newTimeoutRouter registra handlers lentos, rápidos y sin plazo detrás de Timeout
+func newTimeoutRouter(timeout time.Duration) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Timeout(timeout, []string{"/admin/"}))
+
+	// Espera a que se cancele el contexto, como una consulta bloqueada, sin responder
+	router.GET("/slow", func(c *gin.Context) {
+		select {
+		case <-c.Request.Context().Done():
+		case <-time.After(5 * time.Second):
+			c.Status(http.StatusOK)
+		}
+	})
+	// Informa la cancelación como un error interno, como un handler que propaga el error de la consulta
+	router.GET("/slow-error", func(c *gin.Context) {
+		<-c.Request.Context().Done()
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, i18n.MsgInternal)
+	})
+	router.GET("/fast", func(c *gin.Context) {
+		c.String(http.StatusOK, "OK")
+	})
+	// Responde 200 solo si el contexto no tiene plazo
+	noDeadline := func(c *gin.Context) {
+		if _, ok := c.Request.Context().Deadline(); ok {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		c.Status(http.StatusOK)
+	}
+	router.GET("/deadline", noDeadline)
+	router.GET("/admin/deadline", noDeadline)
+	return router
+}
+
+func TestTimeoutCutsOffSlowHandlers(t *testing.T) {
+	router := newTimeoutRouter(testRequestTimeout)
+
+	for _, path := range []string{"/slow", "/slow-error"} {
+		t.Run(path, func(t *testing.T) {
+			start := time.Now()
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+			elapsed := time.Since(start)
+
+			if elapsed > time.Second {
+				t.Errorf("request took %v, want it cut off at %v", elapsed, testRequestTimeout)
+			}
+			var body apierror.Response
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decoding error response: %v", err)
+			}
+			if rec.Code != http.StatusGatewayTimeout || body.Error.Code != apierror.CodeTimeout {
+				t.Errorf("got status %d code %q, want 504 %q", rec.Code, body.Error.Code, apierror.CodeTimeout)
+			}
+		})
+	}
+}
+
+func TestTimeoutLeavesOtherRequestsAlone(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		path    string
+	}{
+		{"fast handler", testRequestTimeout, "/fast"},
+		{"excluded prefix", testRequestTimeout, "/admin/deadline"},
+		{"timeout disabled", 0, "/deadline"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		newTimeoutRouter(tt.timeout).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: got status %d, want 200", tt.name, rec.Code)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/xml"
 	"time"
 )
 
@@ -11,24 +12,175 @@ type RevokedCertificate struct {
 	Reason            int       `json:"reason" db:"reason"`
 	ReasonText        string    `json:"reason_text" db:"reason_text"`
 	CertificateAuthority string `json:"certificate_authority" db:"certificate_authority"`
+	// IssuerDN es el DN canónico del emisor con el que se agrupan los certificados por CA;
+	// CertificateAuthority es el nombre para mostrar
+	IssuerDN          string    `json:"-" db:"issuer_dn"`
+	Fingerprint       string    `json:"fingerprint,omitempty" db:"fingerprint"`
+	// InvalidityDate es la fecha de la extensión Invalidity Date de la entrada, si la trae
+	InvalidityDate    *time.Time `json:"invalidity_date,omitempty" db:"invalidity_date"`
 	CreatedAt         time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt         time.Time `json:"updated_at" db:"updated_at"`
 }
 
 type CertificateStatus struct {
-	Serial     string    `json:"serial"`
-	IsRevoked  bool      `json:"is_revoked"`
-	RevocationDate *time.Time `json:"revocation_date,omitempty"`
-	Reason     *string   `json:"reason,omitempty"`
-	CertificateAuthority *string `json:"certificate_authority,omitempty"`
+	XMLName    xml.Name  `json:"-" xml:"certificate_status"`
+	Serial     string    `json:"serial" xml:"serial"`
+	IsRevoked  bool      `json:"is_revoked" xml:"is_revoked"`
+	// Status es good, revoked u on_hold; IsRevoked es true en los dos últimos
+	Status     string    `json:"status" xml:"status"`
+	RevocationDate *time.Time `json:"revocation_date,omitempty" xml:"revocation_date,omitempty"`
+	Reason     *string   `json:"reason,omitempty" xml:"reason,omitempty"`
+	ReasonCode *int      `json:"reason_code,omitempty" xml:"reason_code,omitempty"`
+	CertificateAuthority *string `json:"certificate_authority,omitempty" xml:"certificate_authority,omitempty"`
+	// InvalidityDate es la fecha desde la que se sabe o se sospecha que el certificado dejó de
+	// ser válido, que puede ser anterior a RevocationDate
+	InvalidityDate *time.Time `json:"invalidity_date,omitempty" xml:"invalidity_date,omitempty"`
+	Fingerprint *string `json:"fingerprint,omitempty" xml:"fingerprint,omitempty"`
+	// AsOf es la fecha de la consulta histórica; vacío en las consultas del estado actual
+	AsOf *time.Time `json:"as_of,omitempty" xml:"as_of,omitempty"`
+}
+
+// Valores de CertificateStatus.Status
+const (
+	StatusGood    = "good"
+	StatusRevoked = "revoked"
+	StatusOnHold  = "on_hold"
+)
+
+// RevokedStatus devuelve el Status de un certificado listado en una CRL con el motivo dado:
+// certificateHold es una suspensión temporal que la CA puede levantar, a diferencia de una
+// revocación, que es definitiva
+func RevokedStatus(reason int) string {
+	if reason == ReasonCertificateHold {
+		return StatusOnHold
+	}
+	return StatusRevoked
+}
+
+// CertificateVerification agrega al estado de revocación el periodo de validez del certificado
+type CertificateVerification struct {
+	CertificateStatus
+	Expired     bool      `json:"expired"`
+	NotYetValid bool      `json:"not_yet_valid"`
+	NotBefore   time.Time `json:"not_before"`
+	NotAfter    time.Time `json:"not_after"`
+}
+
+// MaxSerialLength es el largo máximo del serial decimal almacenado (columna VARCHAR(255)).
+// Un serial de 20 octetos, el máximo de RFC 5280, ocupa 49 dígitos; el margen cubre
+// certificados mal emitidos con seriales más largos.
+const MaxSerialLength = 255
+
+// CertificateFilter agrupa los filtros opcionales para listar certificados revocados
+type CertificateFilter struct {
+	CertificateAuthority string
+	Reason               *int
+	From                 *time.Time
+	To                   *time.Time
+	// AfterID activa la paginación por cursor: se devuelven las filas con id menor,
+	// ordenadas por id descendente (0 para la primera página)
+	AfterID *int
 }
 
 type CRLInfo struct {
 	URL           string    `json:"url"`
 	Issuer        string    `json:"issuer"`
+	IssuerDN      string    `json:"issuer_dn,omitempty"`
 	NextUpdate    time.Time `json:"next_update"`
 	LastProcessed time.Time `json:"last_processed"`
 	CertCount     int       `json:"cert_count"`
+	CRLNumber     string    `json:"crl_number,omitempty"`
+	CRLAttempts
+}
+
+// CAStats resume los certificados revocados y la CRL más reciente de una CA
+type CAStats struct {
+	CertificateAuthority string     `json:"certificate_authority"`
+	IssuerDN             string     `json:"issuer_dn,omitempty"`
+	RevokedCount         int        `json:"revoked_count"`
+	CRLURL               *string    `json:"crl_url,omitempty"`
+	NextUpdate           *time.Time `json:"next_update,omitempty"`
+	LastProcessed        *time.Time `json:"last_processed,omitempty"`
+	IsStale              bool       `json:"is_stale"`
+}
+
+// ReasonCount es el número de certificados revocados con un mismo motivo
+type ReasonCount struct {
+	ReasonText string `json:"reason_text"`
+	Count      int    `json:"count"`
+}
+
+// CRLSource es una URL de CRL administrada en base de datos. ClientCert, ClientKey y CABundle
+// son rutas opcionales para TLS mutuo; vacías se usa la configuración global.
+type CRLSource struct {
+	ID         int       `json:"id"`
+	URL        string    `json:"url"`
+	Label      string    `json:"label,omitempty"`
+	Enabled    bool      `json:"enabled"`
+	ClientCert string    `json:"client_cert,omitempty"`
+	ClientKey  string    `json:"client_key,omitempty"`
+	CABundle   string    `json:"ca_bundle,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	CRLAttempts
+}
+
+// CRLAttempts resume los intentos de procesar una CRL: el último éxito, el error del último
+// fallo y cuántos fallos seguidos lleva desde el último éxito
+type CRLAttempts struct {
+	LastSuccess         *time.Time `json:"last_success,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+}
+
+// CACertificate es un certificado de CA registrado para verificar las CRLs que emite
+type CACertificate struct {
+	ID        int       `json:"id"`
+	Subject   string    `json:"subject"`
+	KeyID     string    `json:"key_id"`
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+	DER       []byte    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Resultados registrados en el historial de procesamiento de CRLs
+const (
+	ProcessingStatusSuccess     = "success"
+	ProcessingStatusNotModified = "not_modified"
+	ProcessingStatusSkipped     = "skipped"
+	ProcessingStatusFailed      = "failed"
+	// La descarga falló y se usó la última copia del cache en disco; cuenta como fallo
+	ProcessingStatusServedFromCache = "served_from_cache"
+)
+
+// ProcessingLogEntry es un intento de descarga y procesamiento de una CRL
+type ProcessingLogEntry struct {
+	ID              int       `json:"id"`
+	URL             string    `json:"url"`
+	StartedAt       time.Time `json:"started_at"`
+	DurationMs      int64     `json:"duration_ms"`
+	HTTPStatus      int       `json:"http_status,omitempty"`
+	BytesDownloaded int       `json:"bytes_downloaded"`
+	CertCount       int       `json:"cert_count"`
+	// Entradas mal formadas de la CRL que se omitieron al importarla
+	SkippedEntries int    `json:"skipped_entries"`
+	Status         string `json:"status"`
+	Error          string `json:"error,omitempty"`
+}
+
+// ThroughputBucket resume los procesamientos de CRLs de una hora o un día
+type ThroughputBucket struct {
+	BucketStart   time.Time `json:"bucket_start"`
+	Runs          int       `json:"runs"`
+	FailedRuns    int       `json:"failed_runs"`
+	CertsImported int64     `json:"certs_imported"`
+	DurationMs    int64     `json:"duration_ms"`
+}
+
+// CRLValidators guarda los validadores HTTP de la última descarga completa de una CRL
+type CRLValidators struct {
+	ETag         string
+	LastModified string
 }
 
 const (
@@ -55,4 +207,31 @@ var RevocationReasons = map[int]string{
 	ReasonRemoveFromCRL:        "Eliminado de CRL",
 	ReasonPrivilegeWithdrawn:   "Privilegio retirado",
 	ReasonAACompromise:         "Compromiso de AA",
+}
+
+// RevocationReasonsEN son los textos en inglés de los motivos, con los nombres de RFC 5280
+var RevocationReasonsEN = map[int]string{
+	ReasonUnspecified:          "Unspecified",
+	ReasonKeyCompromise:        "Key compromise",
+	ReasonCACompromise:         "CA compromise",
+	ReasonAffiliationChanged:   "Affiliation changed",
+	ReasonSuperseded:           "Superseded",
+	ReasonCessationOfOperation: "Cessation of operation",
+	ReasonCertificateHold:      "Certificate hold",
+	ReasonRemoveFromCRL:        "Remove from CRL",
+	ReasonPrivilegeWithdrawn:   "Privilege withdrawn",
+	ReasonAACompromise:         "AA compromise",
+}
+
+// RevocationReasonsByLang devuelve los textos de los motivos en el idioma indicado (es o en);
+// ok es false si el idioma no está soportado
+func RevocationReasonsByLang(lang string) (reasons map[int]string, ok bool) {
+	switch lang {
+	case "es":
+		return RevocationReasons, true
+	case "en":
+		return RevocationReasonsEN, true
+	default:
+		return nil, false
+	}
 }
\ No newline at end of file
@@ -0,0 +1,17 @@
+package models
+
+import "testing"
+
+func TestRevokedStatus(t *testing.T) {
+	for _, reason := range []int{
+		ReasonUnspecified, ReasonKeyCompromise, ReasonCACompromise, ReasonAffiliationChanged, ReasonSuperseded,
+		ReasonCessationOfOperation, ReasonRemoveFromCRL, ReasonPrivilegeWithdrawn, ReasonAACompromise,
+	} {
+		if got := RevokedStatus(reason); got != StatusRevoked {
+			t.Errorf("reason %d: got %q, want %q", reason, got, StatusRevoked)
+		}
+	}
+	if got := RevokedStatus(ReasonCertificateHold); got != StatusOnHold {
+		t.Errorf("reason 6: got %q, want %q", got, StatusOnHold)
+	}
+}
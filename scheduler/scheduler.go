@@ -1,79 +1,202 @@
 package scheduler
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"sync"
 
 	"github.com/robfig/cron/v3"
 	"signerflow-crl/services"
 )
 
 type Scheduler struct {
-	cron       *cron.Cron
-	crlService *services.CRLService
+	cron        *cron.Cron
+	crlService  *services.CRLService
 	crlURLsFile string
+	refreshCron string
+	cleanupCron string
+	statsCron   string
+	// Comparación con los responders OCSP; vacío la deshabilita
+	ocspProbeCron string
+	// Recargar la lista de URLs cuando cambia el archivo
+	watchURLs bool
+	// Procesamientos lanzados fuera de cron (inicial y manual)
+	running sync.WaitGroup
+	// Se cancela en Stop para abortar descargas y consultas en curso
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
-func NewScheduler(crlService *services.CRLService, crlURLsFile string) *Scheduler {
-	c := cron.New(cron.WithSeconds())
+// Parser con segundos, igual al usado por cron.WithSeconds()
+var cronParser = cron.NewParser(
+	cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor,
+)
 
-	return &Scheduler{
-		cron:        c,
-		crlService:  crlService,
-		crlURLsFile: crlURLsFile,
+func NewScheduler(crlService *services.CRLService, crlURLsFile, refreshCron, cleanupCron, statsCron, ocspProbeCron string, watchURLs bool) (*Scheduler, error) {
+	if _, err := cronParser.Parse(refreshCron); err != nil {
+		return nil, fmt.Errorf("invalid CRL refresh cron %q: %v", refreshCron, err)
+	}
+	if _, err := cronParser.Parse(cleanupCron); err != nil {
+		return nil, fmt.Errorf("invalid cache cleanup cron %q: %v", cleanupCron, err)
+	}
+	if _, err := cronParser.Parse(statsCron); err != nil {
+		return nil, fmt.Errorf("invalid stats reconciliation cron %q: %v", statsCron, err)
 	}
+	if ocspProbeCron != "" {
+		if _, err := cronParser.Parse(ocspProbeCron); err != nil {
+			return nil, fmt.Errorf("invalid OCSP probe cron %q: %v", ocspProbeCron, err)
+		}
+	}
+
+	c := cron.New(cron.WithParser(cronParser))
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Scheduler{
+		cron:          c,
+		crlService:    crlService,
+		crlURLsFile:   crlURLsFile,
+		refreshCron:   refreshCron,
+		cleanupCron:   cleanupCron,
+		statsCron:     statsCron,
+		ocspProbeCron: ocspProbeCron,
+		watchURLs:     watchURLs,
+		ctx:           ctx,
+		cancel:        cancel,
+	}, nil
 }
 
 func (s *Scheduler) Start() error {
-	_, err := s.cron.AddFunc("0 */10 * * * *", s.processCRLs)
+	_, err := s.cron.AddFunc(s.refreshCron, s.processCRLs)
 	if err != nil {
 		return err
 	}
 
-	_, err = s.cron.AddFunc("0 0 */6 * * *", s.cleanupCaches)
+	_, err = s.cron.AddFunc(s.cleanupCron, s.cleanupCaches)
 	if err != nil {
 		return err
 	}
 
+	_, err = s.cron.AddFunc(s.statsCron, s.reconcileStats)
+	if err != nil {
+		return err
+	}
+
+	if s.ocspProbeCron != "" {
+		if _, err := s.cron.AddFunc(s.ocspProbeCron, s.probeOCSP); err != nil {
+			return err
+		}
+	}
+
+	if s.watchURLs {
+		if err := s.watchURLsFile(s.ctx); err != nil {
+			return err
+		}
+	}
+
 	s.cron.Start()
-	log.Println("Scheduler iniciado: procesamiento de CRLs cada 10 minutos")
+	log.Printf("Scheduler iniciado: procesamiento de CRLs con cron %q", s.refreshCron)
 
-	go s.initialProcessing()
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		s.initialProcessing()
+	}()
 
 	return nil
 }
 
-func (s *Scheduler) Stop() {
-	s.cron.Stop()
-	log.Println("Scheduler detenido")
+// Stop detiene el cron, cancela los procesamientos en curso y espera a que terminen, a lo
+// sumo hasta que ctx se cancele
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		<-s.cron.Stop().Done()
+		s.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Println("Scheduler detenido")
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 func (s *Scheduler) processCRLs() {
 	log.Println("Iniciando procesamiento programado de CRLs...")
 
-	err := s.crlService.ProcessAllCRLs(s.crlURLsFile)
+	err := s.crlService.ProcessAllCRLs(s.ctx, s.crlURLsFile)
+	if errors.Is(err, services.ErrRefreshInProgress) {
+		log.Println("Procesamiento programado omitido: ya hay una actualización de CRLs en curso")
+		return
+	}
 	if err != nil {
 		log.Printf("Error en procesamiento programado de CRLs: %v", err)
 	} else {
 		log.Println("Procesamiento programado de CRLs completado exitosamente")
 	}
+
+	s.crlService.WarnStaleCRLs(s.ctx)
 }
 
 func (s *Scheduler) cleanupCaches() {
 	log.Println("Ejecutando limpieza de cache programada...")
+
+	result := s.crlService.Cleanup(s.ctx, s.crlURLsFile)
+	log.Printf("Limpieza completada: %d marcas de procesamiento, %d certificados, %d contadores, %d entradas de historial eliminados",
+		result.ProcessingFlags, result.Certificates, result.StatsCounters, result.ProcessingLogs)
+}
+
+// reconcileStats corrige los contadores de Redis con los totales de la base
+func (s *Scheduler) reconcileStats() {
+	result, err := s.crlService.ReconcileStats(s.ctx)
+	if err != nil {
+		log.Printf("Error reconciliando estadísticas: %v", err)
+		return
+	}
+	if result != nil {
+		log.Printf("Estadísticas reconciliadas: %d certificados revocados, %d CRLs",
+			result.Database["stats:revoked_total"], result.Database["stats:crls_total"])
+	}
+}
+
+// probeOCSP compara una muestra de revocaciones recientes con los responders OCSP de las CAs
+func (s *Scheduler) probeOCSP() {
+	counts, err := s.crlService.ProbeOCSPConsistency(s.ctx)
+	if err != nil {
+		log.Printf("Error comparando revocaciones con OCSP: %v", err)
+		return
+	}
+	log.Printf("Comparación con OCSP completada: %d coinciden, %d discrepan, %d omitidas, %d fallidas",
+		counts.Agreed, counts.Mismatched, counts.Skipped, counts.Failed)
 }
 
 func (s *Scheduler) initialProcessing() {
 	log.Println("Ejecutando procesamiento inicial de CRLs...")
 
-	err := s.crlService.ProcessAllCRLs(s.crlURLsFile)
+	err := s.crlService.ProcessAllCRLs(s.ctx, s.crlURLsFile)
 	if err != nil {
 		log.Printf("Error en procesamiento inicial de CRLs: %v", err)
 	} else {
 		log.Println("Procesamiento inicial de CRLs completado exitosamente")
 	}
+
+	s.crlService.WarnStaleCRLs(s.ctx)
+	// Los contadores pueden haberse perdido si Redis se reinició con el servicio detenido
+	s.reconcileStats()
 }
 
 func (s *Scheduler) TriggerManualUpdate() {
 	log.Println("Ejecutando actualización manual de CRLs...")
-	go s.processCRLs()
-}
\ No newline at end of file
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		s.processCRLs()
+	}()
+}
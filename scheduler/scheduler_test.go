@@ -0,0 +1,85 @@
+package scheduler
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"signerflow-crl/config"
+	"signerflow-crl/database"
+	"signerflow-crl/services"
+)
+
+// newTestCRLService crea un servicio sin Redis sobre MemoryStore y un archivo de URLs vacío
+func newTestCRLService(t *testing.T) (*services.CRLService, string) {
+	t.Helper()
+
+	urlsFile := filepath.Join(t.TempDir(), "crl_urls.txt")
+	if err := os.WriteFile(urlsFile, nil, 0o644); err != nil {
+		t.Fatalf("writing URLs file: %v", err)
+	}
+	service, err := services.NewCRLService(database.NewMemoryStore(), nil, config.LoadConfig())
+	if err != nil {
+		t.Fatalf("creating service: %v", err)
+	}
+	t.Cleanup(service.Close)
+	return service, urlsFile
+}
+
+func TestSchedulerRegistersCustomCron(t *testing.T) {
+	service, urlsFile := newTestCRLService(t)
+	const refreshCron = "0 15 */2 * * *"
+
+	s, err := NewScheduler(service, urlsFile, refreshCron, "0 0 3 * * *", "0 */15 * * * *", "", false)
+	if err != nil {
+		t.Fatalf("NewScheduler: %v", err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := s.Stop(ctx); err != nil {
+			t.Errorf("Stop: %v", err)
+		}
+	}()
+
+	want, err := cronParser.Parse(refreshCron)
+	if err != nil {
+		t.Fatalf("parsing cron: %v", err)
+	}
+	entries := s.cron.Entries()
+	if len(entries) != 3 {
+		t.Fatalf("got %d cron entries, want 3", len(entries))
+	}
+	for _, entry := range entries {
+		if reflect.DeepEqual(entry.Schedule, want) {
+			return
+		}
+	}
+	t.Errorf("refresh cron %q was not registered", refreshCron)
+}
+
+func TestNewSchedulerRejectsInvalidCron(t *testing.T) {
+	service, urlsFile := newTestCRLService(t)
+
+	tests := []struct {
+		name                                   string
+		refresh, cleanup, stats, ocspProbeCron string
+	}{
+		{"refresh", "every hour", "0 0 3 * * *", "0 */15 * * * *", ""},
+		{"five fields", "0 */6 * * *", "0 0 3 * * *", "0 */15 * * * *", ""},
+		{"cleanup", "0 0 */6 * * *", "0 0 25 * * *", "0 */15 * * * *", ""},
+		{"stats", "0 0 */6 * * *", "0 0 3 * * *", "", ""},
+		{"ocsp probe", "0 0 */6 * * *", "0 0 3 * * *", "0 */15 * * * *", "* * *"},
+	}
+	for _, tt := range tests {
+		if _, err := NewScheduler(service, urlsFile, tt.refresh, tt.cleanup, tt.stats, tt.ocspProbeCron, false); err == nil {
+			t.Errorf("%s: NewScheduler accepted an invalid cron", tt.name)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// urlsReloadDebounce agrupa las escrituras sucesivas de un mismo guardado en una sola recarga
+const urlsReloadDebounce = time.Second
+
+// watchURLsFile recarga la lista de URLs cuando cambia el archivo y la entrega al servicio
+// para los próximos procesamientos. Se vigila el directorio para detectar también los
+// editores que guardan reemplazando el archivo.
+func (s *Scheduler) watchURLsFile(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("error creating file watcher: %v", err)
+	}
+
+	file := filepath.Clean(s.crlURLsFile)
+	if err := watcher.Add(filepath.Dir(file)); err != nil {
+		watcher.Close()
+		return fmt.Errorf("error watching %s: %v", file, err)
+	}
+
+	current, err := s.crlService.LoadCRLURLs(file)
+	if err != nil {
+		log.Printf("Error cargando URLs de %s: %v", file, err)
+	} else {
+		s.crlService.SetFileURLs(s.crlURLsFile, current)
+	}
+
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		defer watcher.Close()
+
+		debounce := time.NewTimer(urlsReloadDebounce)
+		debounce.Stop()
+		defer debounce.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != file || !event.Has(fsnotify.Write|fsnotify.Create|fsnotify.Rename) {
+					continue
+				}
+				debounce.Reset(urlsReloadDebounce)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("Error vigilando %s: %v", file, err)
+			case <-debounce.C:
+				urls, err := s.crlService.LoadCRLURLs(file)
+				if err != nil {
+					// Se mantiene la lista anterior; un guardado a medias se corrige en el siguiente
+					log.Printf("Error recargando URLs de %s, se mantiene la lista anterior: %v", file, err)
+					continue
+				}
+				logURLsDiff(file, current, urls)
+				current = urls
+				s.crlService.SetFileURLs(s.crlURLsFile, urls)
+			}
+		}
+	}()
+
+	log.Printf("Vigilando cambios en %s", file)
+	return nil
+}
+
+// logURLsDiff registra las URLs agregadas y eliminadas entre dos versiones de la lista
+func logURLsDiff(file string, previous, current []string) {
+	before := make(map[string]bool, len(previous))
+	for _, url := range previous {
+		before[url] = true
+	}
+	after := make(map[string]bool, len(current))
+	for _, url := range current {
+		after[url] = true
+	}
+
+	var added, removed []string
+	for _, url := range current {
+		if !before[url] {
+			added = append(added, url)
+		}
+	}
+	for _, url := range previous {
+		if !after[url] {
+			removed = append(removed, url)
+		}
+	}
+
+	log.Printf("Lista de URLs de %s recargada: %d URLs, %d agregadas, %d eliminadas", file, len(current), len(added), len(removed))
+	for _, url := range added {
+		log.Printf("  + %s", url)
+	}
+	for _, url := range removed {
+		log.Printf("  - %s", url)
+	}
+}
@@ -0,0 +1,161 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"signerflow-crl/config"
+	"signerflow-crl/database"
+	"signerflow-crl/services"
+)
+
+// hitCounter es un servidor de CRLs que solo cuenta las descargas recibidas
+type hitCounter struct {
+	*httptest.Server
+	hits atomic.Int32
+}
+
+func newHitCounter(t *testing.T) *hitCounter {
+	t.Helper()
+
+	counter := &hitCounter{}
+	counter.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		counter.hits.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(counter.Close)
+	return counter
+}
+
+// writeURLs guarda la lista de URLs en el archivo JSON vigilado
+func writeURLs(t *testing.T, path string, urls ...string) {
+	t.Helper()
+
+	data, err := json.Marshal(urls)
+	if err != nil {
+		t.Fatalf("encoding URLs: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("writing URLs file: %v", err)
+	}
+}
+
+// newWatchingScheduler vigila urlsFile con un scheduler sin cron ni procesamiento inicial
+func newWatchingScheduler(t *testing.T, urlsFile string) *services.CRLService {
+	t.Helper()
+
+	cfg := config.LoadConfig()
+	cfg.DownloadAttempts = 1
+	cfg.CRLPerHostRate = 0
+	service, err := services.NewCRLService(database.NewMemoryStore(), nil, cfg)
+	if err != nil {
+		t.Fatalf("creating service: %v", err)
+	}
+	t.Cleanup(service.Close)
+
+	s, err := NewScheduler(service, urlsFile, "0 0 */6 * * *", "0 0 3 * * *", "0 */15 * * * *", "", true)
+	if err != nil {
+		t.Fatalf("NewScheduler: %v", err)
+	}
+	if err := s.watchURLsFile(s.ctx); err != nil {
+		t.Fatalf("watchURLsFile: %v", err)
+	}
+	t.Cleanup(func() {
+		s.cancel()
+		s.running.Wait()
+	})
+	return service
+}
+
+func TestWatcherReloadsModifiedURLsFile(t *testing.T) {
+	ctx := context.Background()
+	first, second := newHitCounter(t), newHitCounter(t)
+	urlsFile := filepath.Join(t.TempDir(), "crl_urls.json")
+	writeURLs(t, urlsFile, first.URL)
+
+	service := newWatchingScheduler(t, urlsFile)
+
+	// El watcher fija la lista en memoria al arrancar, así que los procesamientos solo ven
+	// second una vez que recargó el archivo modificado
+	writeURLs(t, urlsFile, second.URL)
+	deadline := time.Now().Add(10 * time.Second)
+	for second.hits.Load() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("the modified URLs file was not reloaded")
+		}
+		first.hits.Store(0)
+		service.ProcessAllCRLs(ctx, urlsFile)
+		time.Sleep(100 * time.Millisecond)
+	}
+	if hits := first.hits.Load(); hits != 0 {
+		t.Errorf("removed URL was downloaded %d times after the reload", hits)
+	}
+
+	// Un archivo inválido no reemplaza la lista recargada
+	if err := os.WriteFile(urlsFile, []byte(`["http://`), 0o644); err != nil {
+		t.Fatalf("writing URLs file: %v", err)
+	}
+	time.Sleep(urlsReloadDebounce + 500*time.Millisecond)
+	second.hits.Store(0)
+	service.ProcessAllCRLs(ctx, urlsFile)
+	if second.hits.Load() == 0 {
+		t.Error("the previous URL list was dropped after an invalid edit")
+	}
+}
+
+// syncBuffer permite leer la salida del log mientras el watcher escribe en ella
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestWatcherDebouncesSuccessiveWrites(t *testing.T) {
+	urlsFile := filepath.Join(t.TempDir(), "crl_urls.json")
+	writeURLs(t, urlsFile, "http://crl.example/a.crl", "http://crl.example/b.crl")
+
+	var output syncBuffer
+	log.SetOutput(&output)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	newWatchingScheduler(t, urlsFile)
+
+	// Varias escrituras seguidas de un mismo guardado producen una sola recarga
+	for i := 0; i < 5; i++ {
+		writeURLs(t, urlsFile, "http://crl.example/b.crl", "http://crl.example/c.crl")
+		time.Sleep(50 * time.Millisecond)
+	}
+	time.Sleep(urlsReloadDebounce + time.Second)
+
+	logged := output.String()
+	if reloads := strings.Count(logged, "recargada"); reloads != 1 {
+		t.Fatalf("got %d reloads, want 1:\n%s", reloads, logged)
+	}
+	for _, want := range []string{"1 agregadas, 1 eliminadas", "+ http://crl.example/c.crl", "- http://crl.example/a.crl"} {
+		if !strings.Contains(logged, want) {
+			t.Errorf("reload log does not contain %q:\n%s", want, logged)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"signerflow-crl/models"
+)
+
+// ErrCacheDisabled indica que la operación requiere Redis y el servicio corre sin cache
+var ErrCacheDisabled = errors.New("redis cache is not configured")
+
+// WarmCacheResult resume una ejecución de WarmCache
+type WarmCacheResult struct {
+	Requested int `json:"requested"`
+	Cached    int `json:"cached"`
+	Failed    int `json:"failed"`
+}
+
+// WarmCache precarga en Redis el estado de los seriales indicados (en decimal) o, si no se
+// indica ninguno, el de los count certificados revocados más recientes. Sobrescribe las
+// entradas existentes, por lo que repetirla deja el cache en el mismo estado.
+func (s *CRLService) WarmCache(ctx context.Context, serials []string, count int) (WarmCacheResult, error) {
+	var result WarmCacheResult
+	if s.redis == nil {
+		return result, ErrCacheDisabled
+	}
+
+	if len(serials) > 0 {
+		result.Requested = len(serials)
+		for _, serial := range serials {
+			// loadCertificateStatus guarda en Redis tanto revocados como válidos con su TTL
+			if _, err := s.loadCertificateStatus(ctx, serial, ""); err != nil {
+				log.Printf("Error warming cache for serial %s: %v", serial, err)
+				result.Failed++
+				continue
+			}
+			result.Cached++
+		}
+		return result, nil
+	}
+
+	certs, err := s.db.GetRecentRevoked(ctx, count)
+	if err != nil {
+		return result, fmt.Errorf("error loading recent revocations: %v", err)
+	}
+
+	result.Requested = len(certs)
+	for _, cert := range certs {
+		status := &models.CertificateStatus{
+			Serial:               cert.Serial,
+			IsRevoked:            true,
+			Status:               models.RevokedStatus(cert.Reason),
+			RevocationDate:       &cert.RevocationDate,
+			Reason:               &cert.ReasonText,
+			ReasonCode:           &cert.Reason,
+			CertificateAuthority: &cert.CertificateAuthority,
+			InvalidityDate:       cert.InvalidityDate,
+		}
+		if cert.Fingerprint != "" {
+			status.Fingerprint = &cert.Fingerprint
+		}
+
+		if err := s.redis.SetCertificateStatus(ctx, cert.Serial, status, s.cfg.CacheTTLRevoked); err != nil {
+			log.Printf("Error warming cache for serial %s: %v", cert.Serial, err)
+			result.Failed++
+			continue
+		}
+		result.Cached++
+	}
+
+	return result, nil
+}
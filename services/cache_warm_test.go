@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"signerflow-crl/database"
+	"signerflow-crl/models"
+)
+
+func TestWarmCacheLoadsRecentRevocations(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	redis, redisServer := newTestRedis(t)
+	service := newCachedTestService(t, store, redis)
+
+	base := time.Now().Add(-72 * time.Hour).UTC().Truncate(time.Second)
+	if _, err := store.BatchInsertRevokedCertificates(ctx, []*models.RevokedCertificate{
+		{Serial: "9001", RevocationDate: base, Reason: models.ReasonKeyCompromise, CertificateAuthority: "Warm CA"},
+		{Serial: "9002", RevocationDate: base.Add(24 * time.Hour), Reason: models.ReasonCertificateHold, CertificateAuthority: "Warm CA"},
+		{Serial: "9003", RevocationDate: base.Add(48 * time.Hour), Reason: models.ReasonKeyCompromise, CertificateAuthority: "Warm CA"},
+	}); err != nil {
+		t.Fatalf("BatchInsertRevokedCertificates: %v", err)
+	}
+
+	// Repetirla deja el cache en el mismo estado
+	for run := 0; run < 2; run++ {
+		result, err := service.WarmCache(ctx, nil, 2)
+		if err != nil {
+			t.Fatalf("WarmCache: %v", err)
+		}
+		if result != (WarmCacheResult{Requested: 2, Cached: 2}) {
+			t.Errorf("run %d: got %+v, want 2 requested and cached", run, result)
+		}
+	}
+
+	for _, serial := range []string{"9003", "9002"} {
+		if _, cached := redisServer.Get("cert:" + serial); !cached {
+			t.Errorf("serial %s was not cached", serial)
+		}
+	}
+	if _, cached := redisServer.Get("cert:9001"); cached {
+		t.Error("serial 9001 was cached although it is not among the 2 most recent")
+	}
+
+	status, err := redis.GetCertificateStatus(ctx, "9002")
+	if err != nil || status == nil {
+		t.Fatalf("GetCertificateStatus: %v, %v", status, err)
+	}
+	if !status.IsRevoked || status.Status != models.StatusOnHold || *status.CertificateAuthority != "Warm CA" {
+		t.Errorf("got cached status %+v, want serial 9002 on hold by Warm CA", status)
+	}
+}
+
+func TestWarmCacheLoadsRequestedSerials(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	redis, redisServer := newTestRedis(t)
+	service := newCachedTestService(t, store, redis)
+
+	if _, err := store.BatchInsertRevokedCertificates(ctx, []*models.RevokedCertificate{
+		{Serial: "9101", RevocationDate: time.Now().Add(-time.Hour), Reason: models.ReasonKeyCompromise, CertificateAuthority: "Warm CA"},
+		{Serial: "9102", RevocationDate: time.Now().Add(-time.Hour), Reason: models.ReasonKeyCompromise, CertificateAuthority: "Warm CA"},
+	}); err != nil {
+		t.Fatalf("BatchInsertRevokedCertificates: %v", err)
+	}
+
+	// Un serial no revocado también se precarga, como válido
+	result, err := service.WarmCache(ctx, []string{"9101", "9199"}, 10)
+	if err != nil {
+		t.Fatalf("WarmCache: %v", err)
+	}
+	if result != (WarmCacheResult{Requested: 2, Cached: 2}) {
+		t.Errorf("got %+v, want 2 requested and cached", result)
+	}
+
+	for serial, wantRevoked := range map[string]bool{"9101": true, "9199": false} {
+		status, err := redis.GetCertificateStatus(ctx, serial)
+		if err != nil || status == nil {
+			t.Fatalf("serial %s not cached: %v", serial, err)
+		}
+		if status.IsRevoked != wantRevoked {
+			t.Errorf("serial %s: got cached revoked=%v, want %v", serial, status.IsRevoked, wantRevoked)
+		}
+	}
+	if _, cached := redisServer.Get("cert:9102"); cached {
+		t.Error("serial 9102 was cached although only explicit serials were requested")
+	}
+}
+
+func TestWarmCacheRequiresRedis(t *testing.T) {
+	service := newTestService(t, database.NewMemoryStore())
+	if _, err := service.WarmCache(context.Background(), nil, 10); !errors.Is(err, ErrCacheDisabled) {
+		t.Errorf("got error %v, want ErrCacheDisabled", err)
+	}
+}
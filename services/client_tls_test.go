@@ -0,0 +1,416 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"signerflow-crl/config"
+	"signerflow-crl/database"
+	"signerflow-crl/models"
+)
+
+// newMTLSServer publica crl por HTTPS exigiendo un certificado de cliente emitido por clientCA.
+// Devuelve el servidor y la ruta de un bundle PEM con su certificado, para confiar en él.
+func newMTLSServer(t *testing.T, clientCA *testCA, crl []byte) (*httptest.Server, string) {
+	t.Helper()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(clientCA.cert)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pkix-crl")
+		w.Write(crl)
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	return srv, writePEM(t, "CERTIFICATE", srv.Certificate().Raw)
+}
+
+// issueClientCert emite un certificado de cliente TLS y devuelve las rutas del certificado y su clave
+func (ca *testCA) issueClientCert(t *testing.T) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating client key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "crl-downloader"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, key.Public(), ca.key)
+	if err != nil {
+		t.Fatalf("creating client certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshalling client key: %v", err)
+	}
+	return writePEM(t, "CERTIFICATE", der), writePEM(t, "EC PRIVATE KEY", keyDER)
+}
+
+func TestClientCertificateAuthentication(t *testing.T) {
+	issuer := newTestCA(t, "mTLS Issuer CA")
+	clientCA := newTestCA(t, "mTLS Client CA")
+	srv, serverBundle := newMTLSServer(t, clientCA, issuer.crl(t, 1, []x509.RevocationListEntry{
+		revoked(7001, models.ReasonKeyCompromise, time.Now().Add(-time.Hour)),
+	}))
+	certFile, keyFile := clientCA.issueClientCert(t)
+
+	t.Run("global client certificate", func(t *testing.T) {
+		service := newTestService(t, database.NewMemoryStore(), func(cfg *config.Config) {
+			cfg.CRLClientCert = certFile
+			cfg.CRLClientKey = keyFile
+			cfg.CRLCABundle = serverBundle
+		})
+		if err := service.ProcessSingleCRL(context.Background(), srv.URL); err != nil {
+			t.Fatalf("ProcessSingleCRL with client certificate: %v", err)
+		}
+	})
+
+	t.Run("without client certificate", func(t *testing.T) {
+		service := newTestService(t, database.NewMemoryStore(), func(cfg *config.Config) {
+			cfg.CRLCABundle = serverBundle
+		})
+		if err := service.ProcessSingleCRL(context.Background(), srv.URL); err == nil {
+			t.Fatal("ProcessSingleCRL succeeded against a server requiring a client certificate")
+		}
+	})
+
+	t.Run("client certificate from another CA", func(t *testing.T) {
+		otherCert, otherKey := newTestCA(t, "Untrusted Client CA").issueClientCert(t)
+		service := newTestService(t, database.NewMemoryStore(), func(cfg *config.Config) {
+			cfg.CRLClientCert = otherCert
+			cfg.CRLClientKey = otherKey
+			cfg.CRLCABundle = serverBundle
+		})
+		if err := service.ProcessSingleCRL(context.Background(), srv.URL); err == nil {
+			t.Fatal("ProcessSingleCRL succeeded with a client certificate the server does not trust")
+		}
+	})
+
+	t.Run("per-source client certificate", func(t *testing.T) {
+		ctx := context.Background()
+		store := database.NewMemoryStore()
+		if err := store.AddCRLSource(ctx, &models.CRLSource{
+			URL:        srv.URL,
+			Enabled:    true,
+			ClientCert: certFile,
+			ClientKey:  keyFile,
+			CABundle:   serverBundle,
+		}); err != nil {
+			t.Fatalf("AddCRLSource: %v", err)
+		}
+		// Sin configuración global: el certificado solo viene de la fuente
+		service := newTestService(t, store)
+		if err := service.ProcessSingleCRL(ctx, srv.URL); err != nil {
+			t.Fatalf("ProcessSingleCRL with per-source client certificate: %v", err)
+		}
+
+		status, err := service.CheckCertificateStatus(ctx, "7001")
+		if err != nil {
+			t.Fatalf("CheckCertificateStatus: %v", err)
+		}
+		if !status.IsRevoked {
+			t.Error("serial 7001 is not revoked after downloading the CRL over mTLS")
+		}
+	})
+}
+
+func TestNewClientTLSConfigRequiresCertAndKeyTogether(t *testing.T) {
+	certFile, _ := newTestCA(t, "Pair CA").issueClientCert(t)
+	if _, err := NewClientTLSConfig(certFile, "", ""); err == nil {
+		t.Error("NewClientTLSConfig accepted a client certificate without its key")
+	}
+	if cfg, err := NewClientTLSConfig("", "", ""); err != nil || cfg != nil {
+		t.Errorf("NewClientTLSConfig with no files = %v, %v; want nil, nil", cfg, err)
+	}
+}
+
+func TestDownloadsRouteThroughConfiguredProxy(t *testing.T) {
+	ca := newTestCA(t, "Proxied CA")
+	crl := ca.crl(t, 1, []x509.RevocationListEntry{
+		revoked(8001, models.ReasonKeyCompromise, time.Now().Add(-time.Hour)),
+	})
+
+	// El proxy responde él mismo: el host de la CRL no existe, así que la descarga solo
+	// funciona si pasa por el proxy
+	var (
+		mu        sync.Mutex
+		requested []string
+		auth      []string
+	)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested = append(requested, r.URL.String())
+		auth = append(auth, r.Header.Get("Proxy-Authorization"))
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/pkix-crl")
+		w.Write(crl)
+	}))
+	t.Cleanup(proxy.Close)
+
+	const crlURL = "http://crl.example.invalid/ca.crl"
+	service := newTestService(t, database.NewMemoryStore(), func(cfg *config.Config) {
+		cfg.CRLProxyURL = "http://crl-user:s3cret@" + strings.TrimPrefix(proxy.URL, "http://")
+	})
+	if err := service.ProcessSingleCRL(context.Background(), crlURL); err != nil {
+		t.Fatalf("ProcessSingleCRL through proxy: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(requested) != 1 || requested[0] != crlURL {
+		t.Fatalf("proxy received %v, want one request for %s", requested, crlURL)
+	}
+	wantAuth := "Basic " + base64.StdEncoding.EncodeToString([]byte("crl-user:s3cret"))
+	if auth[0] != wantAuth {
+		t.Errorf("got Proxy-Authorization %q, want %q", auth[0], wantAuth)
+	}
+}
+
+func TestCRLProxyValidation(t *testing.T) {
+	if proxy, err := crlProxy(""); err != nil || proxy == nil {
+		t.Errorf("crlProxy(\"\") returned nil proxy or error %v; want the environment proxy", err)
+	}
+	for _, proxyURL := range []string{"http://proxy.local:3128", "https://proxy.local", "socks5://proxy.local:1080"} {
+		if _, err := crlProxy(proxyURL); err != nil {
+			t.Errorf("crlProxy(%q): %v", proxyURL, err)
+		}
+	}
+	for _, proxyURL := range []string{"ftp://proxy.local", "http://", "://bad"} {
+		if _, err := crlProxy(proxyURL); err == nil {
+			t.Errorf("crlProxy(%q) accepted an invalid proxy URL", proxyURL)
+		}
+	}
+}
+
+// newDroppingServer publica crl pero corta sin responder las primeras drops conexiones, como
+// un balanceador que ya cerró la conexión que el cliente tenía en el pool
+func newDroppingServer(t *testing.T, crl []byte, drops int32) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= drops {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Errorf("hijacking connection: %v", err)
+				return
+			}
+			conn.Close()
+			return
+		}
+		w.Write(crl)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func TestStaleConnectionIsRetriedOnce(t *testing.T) {
+	ctx := context.Background()
+	crl := newTestCA(t, "Stale Connection CA").crl(t, 1, []x509.RevocationListEntry{
+		revoked(9301, models.ReasonKeyCompromise, time.Now().Add(-time.Hour)),
+	})
+
+	// Sin reintentos de descarga, solo el reintento por conexión cerrada puede recuperarla
+	srv, requests := newDroppingServer(t, crl, 1)
+	service := newTestService(t, database.NewMemoryStore())
+	if err := service.ProcessSingleCRL(ctx, srv.URL); err != nil {
+		t.Fatalf("ProcessSingleCRL after a dropped connection: %v", err)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("got %d requests, want the dropped one and a single retry", got)
+	}
+	if status, err := service.CheckCertificateStatus(ctx, "9301"); err != nil || !status.IsRevoked {
+		t.Errorf("serial 9301: got %+v, %v; want revoked", status, err)
+	}
+
+	// Si la conexión nueva también se corta no se sigue reintentando
+	srv, requests = newDroppingServer(t, crl, 2)
+	if err := newTestService(t, database.NewMemoryStore()).ProcessSingleCRL(ctx, srv.URL); err == nil {
+		t.Fatal("ProcessSingleCRL succeeded with every connection dropped")
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("got %d requests, want the dropped one and a single retry", got)
+	}
+}
+
+func TestIsStaleConnError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&url.Error{Op: "Get", URL: "http://crl.example/ca.crl", Err: io.EOF}, true},
+		{fmt.Errorf("reading body: %w", io.ErrUnexpectedEOF), true},
+		{&net.OpError{Op: "write", Net: "tcp", Err: os.NewSyscallError("write", syscall.EPIPE)}, true},
+		{&net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}, true},
+		{errors.New("http: server closed idle connection"), true},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, false},
+		{context.DeadlineExceeded, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := isStaleConnError(tt.err); got != tt.want {
+			t.Errorf("isStaleConnError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestCRLHTTPClientTransport(t *testing.T) {
+	cfg := config.LoadConfig()
+	cfg.CRLResponseHeaderTimeout = 7 * time.Second
+	cfg.CRLExpectContinueTimeout = 2 * time.Second
+	cfg.CRLIdleConnTimeout = time.Minute
+
+	transport := newCRLHTTPClient(cfg, &tls.Config{}, nil).Transport.(*http.Transport)
+	if !transport.ForceAttemptHTTP2 {
+		t.Error("HTTP/2 is not attempted with a custom TLS config")
+	}
+	if transport.ResponseHeaderTimeout != 7*time.Second || transport.ExpectContinueTimeout != 2*time.Second || transport.IdleConnTimeout != time.Minute {
+		t.Errorf("got timeouts %v/%v/%v, want 7s/2s/1m",
+			transport.ResponseHeaderTimeout, transport.ExpectContinueTimeout, transport.IdleConnTimeout)
+	}
+}
+
+// newPrivateTLSServer publica crl por HTTPS con un certificado que no está en el pool del sistema
+// y devuelve el bundle PEM que lo hace confiable
+func newPrivateTLSServer(t *testing.T, crl []byte, maxVersion uint16) (*httptest.Server, string) {
+	t.Helper()
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(crl)
+	}))
+	srv.TLS = &tls.Config{MaxVersion: maxVersion}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	return srv, writePEM(t, "CERTIFICATE", srv.Certificate().Raw)
+}
+
+func TestDownloadsTrustExtraCABundle(t *testing.T) {
+	ctx := context.Background()
+	crl := newTestCA(t, "Private PKI CA").crl(t, 1, []x509.RevocationListEntry{
+		revoked(8801, models.ReasonKeyCompromise, time.Now().Add(-time.Hour)),
+	})
+	srv, bundle := newPrivateTLSServer(t, crl, 0)
+
+	tests := []struct {
+		name      string
+		configure func(*config.Config)
+		wantErr   bool
+	}{
+		{"system pool only", func(cfg *config.Config) {}, true},
+		{"extra CA bundle", func(cfg *config.Config) { cfg.CRLExtraCABundle = bundle }, false},
+		{"insecure skip verify", func(cfg *config.Config) { cfg.CRLTLSInsecureSkipVerify = true }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			log.SetOutput(&logs)
+			t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+			service := newTestService(t, database.NewMemoryStore(), func(cfg *config.Config) {
+				cfg.CRLPerHostRate = 0
+				tt.configure(cfg)
+			})
+			err := service.ProcessSingleCRL(ctx, srv.URL)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ProcessSingleCRL: got error %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr {
+				if status, err := service.CheckCertificateStatus(ctx, "8801"); err != nil || !status.IsRevoked {
+					t.Errorf("serial 8801: got %+v, %v; want revoked", status, err)
+				}
+			}
+
+			// Saltarse la verificación tiene que quedar bien visible en el log
+			warned := strings.Contains(logs.String(), "WARNING: CRL_TLS_INSECURE_SKIP_VERIFY")
+			if want := tt.name == "insecure skip verify"; warned != want {
+				t.Errorf("got insecure skip verify warning %v, want %v (log %q)", warned, want, logs.String())
+			}
+		})
+	}
+}
+
+func TestDownloadsEnforceTLSMinVersion(t *testing.T) {
+	ctx := context.Background()
+	crl := newTestCA(t, "TLS Version CA").crl(t, 1, nil)
+	srv, bundle := newPrivateTLSServer(t, crl, tls.VersionTLS12)
+
+	for _, tt := range []struct {
+		minVersion string
+		wantErr    bool
+	}{
+		{"1.2", false},
+		{"1.3", true},
+	} {
+		service := newTestService(t, database.NewMemoryStore(), func(cfg *config.Config) {
+			cfg.CRLPerHostRate = 0
+			cfg.CRLExtraCABundle = bundle
+			cfg.CRLTLSMinVersion = tt.minVersion
+		})
+		if err := service.ProcessSingleCRL(ctx, srv.URL); (err != nil) != tt.wantErr {
+			t.Errorf("TLS %s against a TLS 1.2 server: got error %v, want error %v", tt.minVersion, err, tt.wantErr)
+		}
+	}
+}
+
+func TestNewDownloadTLSConfig(t *testing.T) {
+	cfg := config.LoadConfig()
+	tlsConfig, err := newDownloadTLSConfig(cfg, "", "", "")
+	if err != nil {
+		t.Fatalf("newDownloadTLSConfig: %v", err)
+	}
+	if tlsConfig.MinVersion != tls.VersionTLS12 || tlsConfig.InsecureSkipVerify || tlsConfig.RootCAs != nil {
+		t.Errorf("got MinVersion %x InsecureSkipVerify %v custom RootCAs %v, want TLS 1.2 with verification against the system pool",
+			tlsConfig.MinVersion, tlsConfig.InsecureSkipVerify, tlsConfig.RootCAs != nil)
+	}
+
+	notPEM := filepath.Join(t.TempDir(), "bundle.pem")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0o644); err != nil {
+		t.Fatalf("writing bundle: %v", err)
+	}
+	for _, tt := range []struct {
+		bundle  string
+		wantErr string
+	}{
+		{filepath.Join(t.TempDir(), "missing.pem"), "error reading extra CA bundle"},
+		{notPEM, "no PEM certificates found"},
+	} {
+		cfg.CRLExtraCABundle = tt.bundle
+		if _, err := newDownloadTLSConfig(cfg, "", "", ""); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("bundle %s: got error %v, want %q", tt.bundle, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+)
+
+// recordCRLAttempt registra el resultado del intento en crl_info y en la fuente de la CRL. Al
+// llegar a CRL_FAILURE_THRESHOLD fallos consecutivos incrementa stats:crl_failure_warnings y,
+// con CRL_FAILURE_AUTO_DISABLE, deshabilita la fuente registrada en la base; mientras siga
+// fallando se advierte en el log en cada intento.
+func (s *CRLService) recordCRLAttempt(ctx context.Context, crlURL string, attemptErr error) {
+	failures, err := s.db.RecordCRLAttempt(ctx, crlURL, attemptErr)
+	if err != nil {
+		log.Printf("Error recording attempt for CRL %s: %v", crlURL, err)
+		return
+	}
+
+	threshold := s.cfg.CRLFailureThreshold
+	if threshold == 0 || failures < threshold {
+		return
+	}
+	log.Printf("WARNING: CRL %s failed %d consecutive times: %v", crlURL, failures, attemptErr)
+	if failures != threshold {
+		return
+	}
+
+	if s.redis != nil {
+		s.redis.IncrementStats("stats:crl_failure_warnings")
+	}
+	if !s.cfg.CRLFailureAutoDisable {
+		return
+	}
+
+	source, err := s.db.GetCRLSourceByURL(ctx, crlURL)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Las URLs de CRL_URLS_FILE o CRL_URLS_JSON no tienen fuente que deshabilitar
+		return
+	}
+	if err != nil {
+		log.Printf("Error loading CRL source %s: %v", crlURL, err)
+		return
+	}
+	if _, err := s.db.SetCRLSourceEnabled(ctx, source.ID, false); err != nil {
+		log.Printf("Error disabling CRL source %d (%s): %v", source.ID, crlURL, err)
+		return
+	}
+	log.Printf("WARNING: disabled CRL source %d (%s) after %d consecutive failures", source.ID, crlURL, failures)
+}
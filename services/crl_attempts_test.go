@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"signerflow-crl/config"
+	"signerflow-crl/database"
+	"signerflow-crl/models"
+)
+
+func TestCRLFailureThreshold(t *testing.T) {
+	for _, autoDisable := range []bool{false, true} {
+		name := "warn only"
+		if autoDisable {
+			name = "auto disable"
+		}
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store := database.NewMemoryStore()
+			redis, redisServer := newTestRedis(t)
+			service := newCachedTestService(t, store, redis, func(cfg *config.Config) {
+				cfg.CRLPerHostRate = 0
+				cfg.CRLFailureThreshold = 3
+				cfg.CRLFailureAutoDisable = autoDisable
+			})
+
+			srv := newCRLServer(t, newTestCA(t, "Failing CA").crl(t, 1, revokedRange(6401, 2)))
+			if err := store.AddCRLSource(ctx, &models.CRLSource{URL: srv.URL, Enabled: true}); err != nil {
+				t.Fatalf("AddCRLSource: %v", err)
+			}
+			source := func() *models.CRLSource {
+				t.Helper()
+				source, err := store.GetCRLSourceByURL(ctx, srv.URL)
+				if err != nil {
+					t.Fatalf("GetCRLSourceByURL: %v", err)
+				}
+				return source
+			}
+
+			srv.status = http.StatusServiceUnavailable
+			for attempt := 1; attempt <= 4; attempt++ {
+				if err := service.ProcessSingleCRL(ctx, srv.URL); err == nil {
+					t.Fatalf("attempt %d: ProcessSingleCRL succeeded against a failing server", attempt)
+				}
+				got := source()
+				if got.ConsecutiveFailures != attempt || got.LastError == "" {
+					t.Errorf("attempt %d: got %d consecutive failures with error %q, want %d", attempt, got.ConsecutiveFailures, got.LastError, attempt)
+				}
+				// La fuente se deshabilita al llegar al umbral, no antes
+				if wantEnabled := !autoDisable || attempt < 3; got.Enabled != wantEnabled {
+					t.Errorf("attempt %d: got enabled=%v, want %v", attempt, got.Enabled, wantEnabled)
+				}
+			}
+			// La advertencia se cuenta una vez al cruzar el umbral
+			if warnings, _ := redisServer.Get("stats:crl_failure_warnings"); warnings != "1" {
+				t.Errorf("got stats:crl_failure_warnings %q after 4 failures, want 1", warnings)
+			}
+
+			srv.status = 0
+			if err := service.ProcessSingleCRL(ctx, srv.URL); err != nil {
+				t.Fatalf("ProcessSingleCRL after recovery: %v", err)
+			}
+			got := source()
+			if got.ConsecutiveFailures != 0 || got.LastError != "" || got.LastSuccess == nil {
+				t.Errorf("got %+v after a successful attempt, want the failures reset", got.CRLAttempts)
+			}
+			info, err := store.GetCRLInfo(ctx, srv.URL)
+			if err != nil || info.LastSuccess == nil || info.ConsecutiveFailures != 0 {
+				t.Errorf("got CRL info %+v (err %v), want a recorded success", info, err)
+			}
+		})
+	}
+}
+
+func TestCRLFailureThresholdDisabled(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	redis, redisServer := newTestRedis(t)
+	service := newCachedTestService(t, store, redis, func(cfg *config.Config) {
+		cfg.CRLPerHostRate = 0
+		cfg.CRLFailureThreshold = 0
+	})
+
+	srv := newCRLServer(t, nil)
+	srv.status = http.StatusNotFound
+	if err := store.AddCRLSource(ctx, &models.CRLSource{URL: srv.URL, Enabled: true}); err != nil {
+		t.Fatalf("AddCRLSource: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		service.ProcessSingleCRL(ctx, srv.URL)
+	}
+
+	// Los fallos se siguen contando, pero sin advertencia
+	source, err := store.GetCRLSourceByURL(ctx, srv.URL)
+	if err != nil || source.ConsecutiveFailures != 5 {
+		t.Fatalf("got source %+v (err %v), want 5 consecutive failures", source, err)
+	}
+	if _, ok := redisServer.Get("stats:crl_failure_warnings"); ok {
+		t.Error("failure warning counted with CRL_FAILURE_THRESHOLD=0")
+	}
+}
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"signerflow-crl/models"
+)
+
+// crlDiskCache guarda en disco, comprimida con gzip, la última descarga completa de cada CRL
+// junto con sus validadores HTTP. Permite no volver a descargar una CRL dentro de la ventana
+// de frescura, por ejemplo tras un reinicio, y seguir usando la última copia si el servidor
+// no responde.
+type crlDiskCache struct {
+	dir       string
+	freshness time.Duration
+	// maxSize limita los bytes descomprimidos al leer una entrada, igual que en la descarga
+	maxSize int64
+}
+
+// crlCacheMetadata es el archivo .json de cada entrada; se escribe después de los datos,
+// por lo que su presencia indica que la entrada está completa
+type crlCacheMetadata struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	FetchedAt    time.Time `json:"fetched_at"`
+}
+
+// cachedCRL es una entrada leída del cache
+type cachedCRL struct {
+	crlCacheMetadata
+	data []byte
+}
+
+func newCRLDiskCache(dir string, freshness time.Duration, maxSize int64) (*crlDiskCache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("error creating CRL cache directory: %v", err)
+	}
+	return &crlDiskCache{dir: dir, freshness: freshness, maxSize: maxSize}, nil
+}
+
+// paths devuelve los archivos de datos y metadatos de la URL, nombrados por su SHA-256
+func (c *crlDiskCache) paths(crlURL string) (string, string) {
+	sum := sha256.Sum256([]byte(crlURL))
+	base := filepath.Join(c.dir, hex.EncodeToString(sum[:]))
+	return base + ".crl.gz", base + ".json"
+}
+
+// load lee la entrada de la URL; devuelve false si no existe o no se puede leer
+func (c *crlDiskCache) load(crlURL string) (*cachedCRL, bool) {
+	dataPath, metaPath := c.paths(crlURL)
+
+	metaBytes, err := os.ReadFile(metaPath)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Error reading CRL cache metadata for %s: %v", crlURL, err)
+		}
+		return nil, false
+	}
+	var entry cachedCRL
+	if err := json.Unmarshal(metaBytes, &entry.crlCacheMetadata); err != nil || entry.URL != crlURL {
+		log.Printf("Ignoring invalid CRL cache entry for %s", crlURL)
+		return nil, false
+	}
+
+	file, err := os.Open(dataPath)
+	if err != nil {
+		log.Printf("Error reading cached CRL %s: %v", crlURL, err)
+		return nil, false
+	}
+	defer file.Close()
+
+	gzReader, err := gzip.NewReader(file)
+	if err != nil {
+		log.Printf("Error reading cached CRL %s: %v", crlURL, err)
+		return nil, false
+	}
+	defer gzReader.Close()
+
+	entry.data, err = io.ReadAll(io.LimitReader(gzReader, c.maxSize+1))
+	if err != nil || int64(len(entry.data)) > c.maxSize {
+		log.Printf("Ignoring unreadable or oversized cached CRL %s", crlURL)
+		return nil, false
+	}
+
+	return &entry, true
+}
+
+// fresh devuelve la entrada de la URL si se descargó dentro de la ventana de frescura
+func (c *crlDiskCache) fresh(crlURL string) (*cachedCRL, bool) {
+	if c.freshness <= 0 {
+		return nil, false
+	}
+	entry, ok := c.load(crlURL)
+	if !ok || time.Since(entry.FetchedAt) > c.freshness {
+		return nil, false
+	}
+	return entry, true
+}
+
+// store guarda una descarga completa; los errores solo se registran, ya que el cache es opcional
+func (c *crlDiskCache) store(crlURL string, download *crlDownload) {
+	var compressed bytes.Buffer
+	gzWriter := gzip.NewWriter(&compressed)
+	if _, err := gzWriter.Write(download.data); err != nil {
+		log.Printf("Error compressing CRL %s for the disk cache: %v", crlURL, err)
+		return
+	}
+	if err := gzWriter.Close(); err != nil {
+		log.Printf("Error compressing CRL %s for the disk cache: %v", crlURL, err)
+		return
+	}
+
+	dataPath, _ := c.paths(crlURL)
+	if err := c.writeFile(dataPath, compressed.Bytes()); err != nil {
+		log.Printf("Error writing CRL %s to the disk cache: %v", crlURL, err)
+		return
+	}
+	c.writeMetadata(crlCacheMetadata{
+		URL:          crlURL,
+		ETag:         download.etag,
+		LastModified: download.lastModified,
+		FetchedAt:    time.Now(),
+	})
+}
+
+// touch renueva la fecha de descarga de la entrada tras un 304 del servidor
+func (c *crlDiskCache) touch(crlURL string) {
+	_, metaPath := c.paths(crlURL)
+	metaBytes, err := os.ReadFile(metaPath)
+	if err != nil {
+		return
+	}
+	var meta crlCacheMetadata
+	if err := json.Unmarshal(metaBytes, &meta); err != nil || meta.URL != crlURL {
+		return
+	}
+	meta.FetchedAt = time.Now()
+	c.writeMetadata(meta)
+}
+
+func (c *crlDiskCache) writeMetadata(meta crlCacheMetadata) {
+	metaBytes, err := json.Marshal(meta)
+	if err != nil {
+		log.Printf("Error encoding CRL cache metadata for %s: %v", meta.URL, err)
+		return
+	}
+	_, metaPath := c.paths(meta.URL)
+	if err := c.writeFile(metaPath, metaBytes); err != nil {
+		log.Printf("Error writing CRL cache metadata for %s: %v", meta.URL, err)
+	}
+}
+
+// writeFile escribe en un archivo temporal y lo renombra, para que un lector nunca vea
+// un archivo a medio escribir
+func (c *crlDiskCache) writeFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// download convierte la entrada en el resultado de una descarga. Si validators coincide con
+// los validadores guardados, la última importación completa fue de estos mismos bytes y se
+// informa como no modificada, igual que un 304.
+func (e *cachedCRL) download(validators *models.CRLValidators) *crlDownload {
+	if (validators.ETag != "" || validators.LastModified != "") &&
+		validators.ETag == e.ETag && validators.LastModified == e.LastModified {
+		return &crlDownload{notModified: true}
+	}
+	return &crlDownload{
+		data:         e.data,
+		etag:         e.ETag,
+		lastModified: e.LastModified,
+	}
+}
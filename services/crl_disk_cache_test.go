@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"crypto/x509"
+	"os"
+	"testing"
+	"time"
+
+	"signerflow-crl/config"
+	"signerflow-crl/database"
+	"signerflow-crl/models"
+)
+
+func TestDiskCacheReusesCRLWithinFreshnessWindow(t *testing.T) {
+	ctx := context.Background()
+	cacheDir := t.TempDir()
+	store := database.NewMemoryStore()
+	// Cada servicio simula un reinicio que conserva la base y el directorio del cache
+	newService := func() *CRLService {
+		return newTestService(t, store, func(cfg *config.Config) {
+			cfg.CRLCacheDir = cacheDir
+			cfg.CRLCacheFreshness = time.Hour
+		})
+	}
+
+	ca := newTestCA(t, "Disk Cache CA")
+	revokedAt := time.Now().Add(-time.Hour)
+	srv := newCRLServer(t, ca.crl(t, 1, []x509.RevocationListEntry{revoked(7701, models.ReasonKeyCompromise, revokedAt)}))
+
+	if err := newService().ProcessSingleCRL(ctx, srv.URL); err != nil {
+		t.Fatalf("ProcessSingleCRL: %v", err)
+	}
+	cache, err := newCRLDiskCache(cacheDir, time.Hour, 10<<20)
+	if err != nil {
+		t.Fatalf("newCRLDiskCache: %v", err)
+	}
+	dataPath, _ := cache.paths(srv.URL)
+	if data, err := os.ReadFile(dataPath); err != nil || len(data) < 2 || data[0] != 0x1f || data[1] != 0x8b {
+		t.Fatalf("gzip-compressed CRL was not written to the cache (err %v)", err)
+	}
+
+	// La CA publica una CRL nueva, pero dentro de la ventana se usa la copia guardada
+	srv.body = ca.crl(t, 2, []x509.RevocationListEntry{
+		revoked(7701, models.ReasonKeyCompromise, revokedAt),
+		revoked(7702, models.ReasonKeyCompromise, revokedAt),
+	})
+	service := newService()
+	if err := service.ProcessSingleCRL(ctx, srv.URL); err != nil {
+		t.Fatalf("ProcessSingleCRL within the window: %v", err)
+	}
+	if hits := srv.hits.Load(); hits != 1 {
+		t.Errorf("CRL was downloaded %d times within the freshness window, want 1", hits)
+	}
+	if status, _ := service.CheckCertificateStatus(ctx, "7702"); status != nil && status.IsRevoked {
+		t.Error("serial of the unfetched CRL was imported")
+	}
+
+	// Pasada la ventana se vuelve a descargar
+	entry, ok := cache.load(srv.URL)
+	if !ok {
+		t.Fatal("cache entry missing")
+	}
+	entry.FetchedAt = time.Now().Add(-2 * time.Hour)
+	cache.writeMetadata(entry.crlCacheMetadata)
+
+	service = newService()
+	if err := service.ProcessSingleCRL(ctx, srv.URL); err != nil {
+		t.Fatalf("ProcessSingleCRL after the window: %v", err)
+	}
+	if hits := srv.hits.Load(); hits != 2 {
+		t.Errorf("CRL was downloaded %d times after the freshness window, want 2", hits)
+	}
+	if status, err := service.CheckCertificateStatus(ctx, "7702"); err != nil || !status.IsRevoked {
+		t.Errorf("serial 7702 after refetching: got %+v, %v; want revoked", status, err)
+	}
+	if entry, ok := cache.fresh(srv.URL); !ok || time.Since(entry.FetchedAt) > time.Minute {
+		t.Error("cache entry was not renewed by the new download")
+	}
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"signerflow-crl/config"
+	"signerflow-crl/database"
+	"signerflow-crl/models"
+)
+
+// slowStore simula una base lenta: cada lote tarda delay en insertarse
+type slowStore struct {
+	database.CertStore
+	delay time.Duration
+}
+
+func (s slowStore) BatchInsertRevokedCertificates(ctx context.Context, certs []*models.RevokedCertificate) ([]*models.RevokedCertificate, error) {
+	time.Sleep(s.delay)
+	return s.CertStore.BatchInsertRevokedCertificates(ctx, certs)
+}
+
+// newCRLFarm publica crls CRLs de CAs distintas, con entriesPerCRL seriales cada una a partir
+// de firstSerial, y escribe sus URLs en un archivo para ProcessAllCRLs
+func newCRLFarm(t testing.TB, crls, entriesPerCRL int, firstSerial int64) (urlsFile string) {
+	t.Helper()
+
+	bodies := make(map[string][]byte, crls)
+	lines := make([]string, crls)
+	for i := 0; i < crls; i++ {
+		path := fmt.Sprintf("/ca-%d.crl", i)
+		ca := newTestCA(t, fmt.Sprintf("Pipeline CA %d", i))
+		bodies[path] = ca.crl(t, 1, revokedRange(firstSerial+int64(i*entriesPerCRL), entriesPerCRL))
+		lines[i] = path
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := bodies[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(body)
+	}))
+	t.Cleanup(srv.Close)
+
+	for i := range lines {
+		lines[i] = srv.URL + lines[i]
+	}
+	urlsFile = filepath.Join(t.TempDir(), "crl_urls.txt")
+	if err := os.WriteFile(urlsFile, []byte(strings.Join(lines, "\n")), 0o644); err != nil {
+		t.Fatalf("writing URLs file: %v", err)
+	}
+	return urlsFile
+}
+
+// configurePipeline deja correr en paralelo las descargas al servidor de prueba
+func configurePipeline(persistWorkers int) func(*config.Config) {
+	return func(cfg *config.Config) {
+		cfg.CRLConcurrency = 4
+		cfg.CRLPerHostConcurrency = 4
+		cfg.CRLPerHostRate = 0
+		cfg.CRLPersistWorkers = persistWorkers
+	}
+}
+
+func TestProcessAllCRLsPersistsEveryCRL(t *testing.T) {
+	const crls, entriesPerCRL = 8, 50
+
+	for _, persistWorkers := range []int{1, 3} {
+		t.Run(fmt.Sprintf("%d persist workers", persistWorkers), func(t *testing.T) {
+			ctx := context.Background()
+			urlsFile := newCRLFarm(t, crls, entriesPerCRL, 9000)
+			store := database.NewMemoryStore()
+			redis, _ := newTestRedis(t)
+			service := newCachedTestService(t, slowStore{CertStore: store, delay: 10 * time.Millisecond}, redis,
+				configurePipeline(persistWorkers))
+
+			if err := service.ProcessAllCRLs(ctx, urlsFile); err != nil {
+				t.Fatalf("ProcessAllCRLs: %v", err)
+			}
+
+			for serial := 9000; serial < 9000+crls*entriesPerCRL; serial++ {
+				status, err := service.CheckCertificateStatus(ctx, fmt.Sprint(serial))
+				if err != nil || !status.IsRevoked {
+					t.Fatalf("serial %d: got %+v, %v; want revoked", serial, status, err)
+				}
+			}
+			infos, err := store.ListCRLInfo(ctx)
+			if err != nil || len(infos) != crls {
+				t.Fatalf("got %d CRL info rows (err %v), want %d", len(infos), err, crls)
+			}
+			for _, info := range infos {
+				if info.CertCount != entriesPerCRL {
+					t.Errorf("CRL %s: got %d certificates, want %d", info.URL, info.CertCount, entriesPerCRL)
+				}
+				history, err := store.GetProcessingHistory(ctx, info.URL, 10)
+				if err != nil || len(history) != 1 || history[0].Status != models.ProcessingStatusSuccess {
+					t.Errorf("CRL %s: got processing history %+v (err %v), want one successful run", info.URL, history, err)
+				}
+				// La marca de procesamiento se libera al terminar la persistencia
+				if processing, err := redis.IsCRLProcessing(info.URL); err != nil || processing {
+					t.Errorf("CRL %s still marked as processing (err %v)", info.URL, err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkProcessAllCRLs compara el pipeline de descarga y persistencia con el modelo
+// anterior, en el que cada worker descargaba e importaba su CRL antes de pasar a la siguiente
+func BenchmarkProcessAllCRLs(b *testing.B) {
+	const crls, entriesPerCRL = 16, 2000
+	ctx := context.Background()
+	urlsFile := newCRLFarm(b, crls, entriesPerCRL, 1)
+	urls, err := os.ReadFile(urlsFile)
+	if err != nil {
+		b.Fatal(err)
+	}
+	newService := func() *CRLService {
+		return newTestService(b, slowStore{CertStore: database.NewMemoryStore(), delay: 5 * time.Millisecond},
+			configurePipeline(2))
+	}
+
+	b.Run("pipeline", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			service := newService()
+			b.StartTimer()
+
+			if err := service.ProcessAllCRLs(ctx, urlsFile); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("inline", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			service := newService()
+			b.StartTimer()
+
+			var wg sync.WaitGroup
+			semaphore := make(chan struct{}, service.cfg.CRLConcurrency)
+			for _, url := range strings.Split(string(urls), "\n") {
+				wg.Add(1)
+				semaphore <- struct{}{}
+				go func(url string) {
+					defer wg.Done()
+					defer func() { <-semaphore }()
+					if err := service.ProcessSingleCRL(ctx, url); err != nil {
+						b.Error(err)
+					}
+				}(url)
+			}
+			wg.Wait()
+		}
+	})
+}
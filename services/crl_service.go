@@ -1,51 +1,205 @@
 package services
 
 import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"math/big"
+	"mime"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/singleflight"
+	"gopkg.in/yaml.v3"
 	"signerflow-crl/cache"
+	"signerflow-crl/config"
 	"signerflow-crl/database"
 	"signerflow-crl/models"
+	"signerflow-crl/requestid"
+	"signerflow-crl/tracing"
+)
+
+var (
+	oidCRLNumber         = asn1.ObjectIdentifier{2, 5, 29, 20}
+	oidDeltaCRLIndicator = asn1.ObjectIdentifier{2, 5, 29, 27}
+	oidCRLReason         = asn1.ObjectIdentifier{2, 5, 29, 21}
+	oidInvalidityDate    = asn1.ObjectIdentifier{2, 5, 29, 24}
 )
 
 type CRLService struct {
-	db         *database.DB
+	db         database.CertStore
 	redis      *cache.RedisClient
 	httpClient *http.Client
+	cfg        *config.Config
+
+	limitersMu sync.Mutex
+	limiters   map[string]*hostRateLimiter
+
+	// Clientes HTTP de las fuentes con TLS propio, por combinación de archivos configurados
+	clientsMu     sync.Mutex
+	sourceClients map[string]*http.Client
+	// URLs de CRL_URLS_JSON, con prioridad sobre crl_sources y el archivo; nil si no se configuró
+	inlineURLs []string
+	// Listas de URLs mantenidas por el watcher del scheduler, por archivo
+	fileURLsMu sync.RWMutex
+	fileURLs   map[string][]string
+
+	// Proxy de salida compartido por todos los clientes HTTP de descarga
+	proxy func(*http.Request) (*url.URL, error)
+
+	notifier *WebhookNotifier
+
+	// Cache en disco de las CRLs descargadas; nil si CRL_CACHE_DIR está vacío
+	diskCache *crlDiskCache
+
+	// Agrupa las consultas concurrentes a la base de datos por serial
+	lookups singleflight.Group
+
+	// Garantiza que solo haya un procesamiento completo de CRLs a la vez
+	refreshMu sync.Mutex
+
+	// Se cancela en StopProcessing para que los procesamientos en curso guarden el lote
+	// pendiente y terminen; processing los cuenta para esperarlos
+	stopCtx        context.Context
+	stopProcessing context.CancelFunc
+	processing     sync.WaitGroup
+	// Certificados guardados al cancelarse una importación, informados al detener el servicio
+	flushedOnCancel atomic.Int64
+	// Resultado de la última reconciliación de los contadores de Redis; nil si no hubo ninguna
+	lastReconciliation atomic.Pointer[StatsReconciliation]
+	// Resultados de las comparaciones con los responders OCSP de las CAs
+	consistencyMu sync.Mutex
+	consistency   ConsistencyReport
+	// next_update más lejano de las CRLs de cada emisor, por nombre, para X-CRL-Stale sin
+	// consultar la base en cada verificación; lo renueva WarnStaleCRLs
+	nextUpdatesMu sync.RWMutex
+	nextUpdates   map[string]time.Time
 }
 
-func NewCRLService(db *database.DB, redis *cache.RedisClient) *CRLService {
-	// Crear HTTP client optimizado con pool de conexiones reutilizables
-	transport := &http.Transport{
-		MaxIdleConns:        100,              // Máximo de conexiones idle totales
-		MaxIdleConnsPerHost: 20,               // Máximo de conexiones idle por host
-		MaxConnsPerHost:     50,               // Máximo de conexiones por host
-		IdleConnTimeout:     90 * time.Second, // Timeout para conexiones idle
-		DisableCompression:  false,            // Habilitar compresión
-		DisableKeepAlives:   false,            // Mantener conexiones vivas
+// maxRFCSerialOctets es el largo máximo de un serial según RFC 5280 (4.1.2.2); los más largos
+// se importan igual, ya que provienen de certificados mal emitidos que aún deben poder consultarse
+const maxRFCSerialOctets = 20
+
+// cancelFlushTimeout limita el guardado del lote pendiente de una importación cancelada
+const cancelFlushTimeout = 10 * time.Second
+
+// ErrRefreshInProgress indica que ya hay un procesamiento completo de CRLs en curso
+var ErrRefreshInProgress = errors.New("CRL refresh already in progress")
+
+func NewCRLService(db database.CertStore, redis *cache.RedisClient, cfg *config.Config) (*CRLService, error) {
+	// Certificado de cliente y bundle de CA globales; las fuentes pueden definir los suyos
+	tlsConfig, err := newDownloadTLSConfig(cfg, cfg.CRLClientCert, cfg.CRLClientKey, cfg.CRLCABundle)
+	if err != nil {
+		return nil, err
 	}
 
+	proxy, err := crlProxy(cfg.CRLProxyURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var notifier *WebhookNotifier
+	if cfg.WebhookURL != "" {
+		notifier, err = NewWebhookNotifier(cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var diskCache *crlDiskCache
+	if cfg.CRLCacheDir != "" {
+		diskCache, err = newCRLDiskCache(cfg.CRLCacheDir, cfg.CRLCacheFreshness, int64(cfg.MaxCRLSizeMB)<<20)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var inlineURLs []string
+	if cfg.CRLURLsJSON != "" {
+		inlineURLs, err = parseInlineCRLURLs(cfg.CRLURLsJSON)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	stopCtx, stopProcessing := context.WithCancel(context.Background())
+
 	return &CRLService{
-		db:    db,
-		redis: redis,
-		httpClient: &http.Client{
-			Timeout:   30 * time.Second,
-			Transport: transport,
-		},
+		db:             db,
+		redis:          redis,
+		cfg:            cfg,
+		limiters:       make(map[string]*hostRateLimiter),
+		notifier:       notifier,
+		diskCache:      diskCache,
+		inlineURLs:     inlineURLs,
+		httpClient:     newCRLHTTPClient(cfg, tlsConfig, proxy),
+		proxy:          proxy,
+		sourceClients:  make(map[string]*http.Client),
+		stopCtx:        stopCtx,
+		stopProcessing: stopProcessing,
+	}, nil
+}
+
+// ReloadWebhookFilter recarga el filtro de códigos de motivo de las notificaciones;
+// no hace nada si el webhook no está configurado
+func (s *CRLService) ReloadWebhookFilter() error {
+	if s.notifier == nil {
+		return nil
+	}
+	return s.notifier.ReloadReasonFilter()
+}
+
+// StopProcessing cancela los procesamientos de CRLs en curso y espera a que terminen, a lo
+// sumo hasta que ctx se cancele. Cada importación cancelada guarda antes su lote pendiente;
+// devuelve cuántos certificados se guardaron así desde el arranque.
+func (s *CRLService) StopProcessing(ctx context.Context) (int64, error) {
+	s.stopProcessing()
+
+	done := make(chan struct{})
+	go func() {
+		s.processing.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return s.flushedOnCancel.Load(), nil
+	case <-ctx.Done():
+		return s.flushedOnCancel.Load(), ctx.Err()
+	}
+}
+
+// Close detiene el envío de notificaciones pendientes
+func (s *CRLService) Close() {
+	if s.notifier != nil {
+		s.notifier.Stop()
 	}
 }
 
+// LoadCRLURLs lee las URLs de CRL según la extensión del archivo: .txt con una URL por línea
+// (se ignoran líneas vacías y comentarios #), .yaml/.yml con una lista y en otro caso un
+// arreglo JSON. Las entradas que no son URLs válidas se omiten con una advertencia.
 func (s *CRLService) LoadCRLURLs(filePath string) ([]string, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
@@ -53,58 +207,392 @@ func (s *CRLService) LoadCRLURLs(filePath string) ([]string, error) {
 	}
 	defer file.Close()
 
-	var urls []string
-	decoder := json.NewDecoder(file)
-	err = decoder.Decode(&urls)
+	var entries []string
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".txt":
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			entries = append(entries, line)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("error reading CRL URLs file: %v", err)
+		}
+	case ".yaml", ".yml":
+		if err := yaml.NewDecoder(file).Decode(&entries); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("error decoding CRL URLs YAML: %v", err)
+		}
+	default:
+		if err := json.NewDecoder(file).Decode(&entries); err != nil {
+			return nil, fmt.Errorf("error decoding CRL URLs JSON: %v", err)
+		}
+	}
+
+	return filterCRLURLs(entries, filePath), nil
+}
+
+// parseInlineCRLURLs interpreta la lista de CRL_URLS_JSON, un arreglo JSON de URLs. Como en
+// el archivo, las entradas que no son URLs válidas se omiten con una advertencia.
+func parseInlineCRLURLs(data string) ([]string, error) {
+	var entries []string
+	if err := json.Unmarshal([]byte(data), &entries); err != nil {
+		return nil, fmt.Errorf("error decoding CRL_URLS_JSON: %v", err)
+	}
+	return filterCRLURLs(entries, "CRL_URLS_JSON"), nil
+}
+
+// filterCRLURLs descarta con una advertencia las entradas que no son URLs de CRL válidas;
+// origin identifica de dónde se leyeron en el log
+func filterCRLURLs(entries []string, origin string) []string {
+	urls := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if !isValidCRLURL(entry) {
+			log.Printf("Warning: skipping invalid CRL URL %q in %s", entry, origin)
+			continue
+		}
+		urls = append(urls, entry)
+	}
+	return urls
+}
+
+// isValidCRLURL acepta URLs absolutas http(s) o ldap(s) con host
+func isValidCRLURL(rawURL string) bool {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return false
+	}
+
+	switch strings.ToLower(parsed.Scheme) {
+	case "http", "https", "ldap", "ldaps":
+		return true
+	default:
+		return false
+	}
+}
+
+// resolveCRLURLs usa la lista de CRL_URLS_JSON si está configurada; si no, las fuentes
+// habilitadas de la tabla crl_sources cuando la tabla tiene registros y, en caso contrario,
+// el archivo
+func (s *CRLService) resolveCRLURLs(ctx context.Context, crlURLsFile string) ([]string, error) {
+	if s.inlineURLs != nil {
+		return s.inlineURLs, nil
+	}
+
+	sources, err := s.db.ListCRLSources(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("error decoding CRL URLs JSON: %v", err)
+		log.Printf("Error loading CRL sources from database, falling back to file: %v", err)
+	} else if len(sources) > 0 {
+		urls := make([]string, 0, len(sources))
+		for _, source := range sources {
+			if source.Enabled {
+				urls = append(urls, source.URL)
+			}
+		}
+		return urls, nil
 	}
 
-	return urls, nil
+	s.fileURLsMu.RLock()
+	urls, ok := s.fileURLs[crlURLsFile]
+	s.fileURLsMu.RUnlock()
+	if ok {
+		return urls, nil
+	}
+
+	return s.LoadCRLURLs(crlURLsFile)
 }
 
-func (s *CRLService) ProcessAllCRLs(crlURLsFile string) error {
+// CheckCRLURLSources verifica al arrancar que haya URLs de CRL para procesar. Si se configuró
+// CRL_URLS_JSON debe tener al menos una URL válida; si no, y la tabla crl_sources tiene
+// registros, se usan esos; en otro caso el archivo debe existir, ser válido y tener al menos
+// una URL. Devuelve la cantidad de URLs y de dónde se cargaron.
+func (s *CRLService) CheckCRLURLSources(ctx context.Context, crlURLsFile string) (int, string, error) {
+	if s.inlineURLs != nil {
+		if len(s.inlineURLs) == 0 {
+			return 0, "CRL_URLS_JSON", errors.New("CRL_URLS_JSON contains no valid URLs")
+		}
+		return len(s.inlineURLs), "CRL_URLS_JSON", nil
+	}
+
+	sources, err := s.db.ListCRLSources(ctx)
+	if err != nil {
+		log.Printf("Error loading CRL sources from database, checking file instead: %v", err)
+	} else if len(sources) > 0 {
+		enabled := 0
+		for _, source := range sources {
+			if source.Enabled {
+				enabled++
+			}
+		}
+		return enabled, "crl_sources", nil
+	}
+
 	urls, err := s.LoadCRLURLs(crlURLsFile)
+	if err != nil {
+		return 0, crlURLsFile, err
+	}
+	if len(urls) == 0 {
+		return 0, crlURLsFile, fmt.Errorf("CRL URLs file %s contains no valid URLs", crlURLsFile)
+	}
+	return len(urls), crlURLsFile, nil
+}
+
+// SetFileURLs fija la lista de URLs del archivo; los procesamientos siguientes la usan en
+// lugar de leer el archivo. La usa el watcher del scheduler al detectar cambios.
+func (s *CRLService) SetFileURLs(crlURLsFile string, urls []string) {
+	s.fileURLsMu.Lock()
+	defer s.fileURLsMu.Unlock()
+
+	if s.fileURLs == nil {
+		s.fileURLs = make(map[string][]string)
+	}
+	s.fileURLs[crlURLsFile] = urls
+}
+
+// ProcessAllCRLs procesa todas las CRLs configuradas; al cancelarse ctx se abortan las
+// descargas en curso y no se inician nuevas. Devuelve ErrRefreshInProgress si ya hay
+// otro procesamiento completo en curso.
+func (s *CRLService) ProcessAllCRLs(ctx context.Context, crlURLsFile string) error {
+	if !s.refreshMu.TryLock() {
+		return ErrRefreshInProgress
+	}
+	defer s.refreshMu.Unlock()
+
+	return s.processAllCRLs(ctx, crlURLsFile)
+}
+
+// StartRefresh lanza en segundo plano un procesamiento completo de CRLs. Devuelve
+// ErrRefreshInProgress sin lanzar nada si ya hay uno en curso.
+func (s *CRLService) StartRefresh(ctx context.Context, crlURLsFile string) error {
+	if !s.refreshMu.TryLock() {
+		return ErrRefreshInProgress
+	}
+
+	go func() {
+		// El lock se libera aunque el procesamiento entre en pánico
+		defer s.refreshMu.Unlock()
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("Panic during manual CRL refresh: %v", r)
+			}
+		}()
+
+		if err := s.processAllCRLs(ctx, crlURLsFile); err != nil {
+			log.Printf("Error en procesamiento manual de CRLs: %v", err)
+		}
+	}()
+
+	return nil
+}
+
+func (s *CRLService) processAllCRLs(ctx context.Context, crlURLsFile string) error {
+	s.processing.Add(1)
+	defer s.processing.Done()
+
+	// StopProcessing cancela el procesamiento aunque ctx no se cancele
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(s.stopCtx, cancel)
+	defer stop()
+
+	ctx, span := tracing.Start(ctx, "CRLService.ProcessAllCRLs")
+	defer span.End()
+
+	urls, err := s.resolveCRLURLs(ctx, crlURLsFile)
 	if err != nil {
 		return fmt.Errorf("error loading CRL URLs: %v", err)
 	}
 
 	log.Printf("Starting to process %d CRL URLs", len(urls))
 
-	var wg sync.WaitGroup
-	semaphore := make(chan struct{}, 5)
+	concurrency := s.cfg.CRLConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	perHost := s.cfg.CRLPerHostConcurrency
+	if perHost < 1 {
+		perHost = 1
+	}
 
-	for _, crlURL := range urls {
-		wg.Add(1)
-		go func(url string) {
-			defer wg.Done()
-			semaphore <- struct{}{}
-			defer func() { <-semaphore }()
+	persistWorkers := s.cfg.CRLPersistWorkers
+	if persistWorkers < 1 {
+		persistWorkers = 1
+	}
 
-			err := s.ProcessSingleCRL(url)
-			if err != nil {
-				log.Printf("Error processing CRL %s: %v", url, err)
+	// Las CRLs descargadas y verificadas esperan en fetched a un worker de persistencia, de
+	// modo que una base lenta no frena las descargas ni una descarga lenta deja ociosa la
+	// base. El buffer acota cuántas CRLs descargadas quedan en memoria esperando.
+	fetched := make(chan *crlJob, persistWorkers)
+	var persistWG sync.WaitGroup
+	for i := 0; i < persistWorkers; i++ {
+		persistWG.Add(1)
+		go func() {
+			defer persistWG.Done()
+			// Se drena el canal aunque ctx se cancele: cada CRL pendiente termina como
+			// cancelada, guardando su historial y liberando su marca de procesamiento
+			for job := range fetched {
+				jobCtx := trace.ContextWithSpan(ctx, job.span)
+				err := s.finishCRLJob(jobCtx, job, s.persistCRL(jobCtx, job))
+				if err != nil {
+					log.Printf("Error processing CRL %s: %v", job.url, err)
+				}
 			}
-		}(crlURL)
+		}()
+	}
+
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, concurrency)
+
+	// Cada host tiene su propia cola y como máximo perHost workers, de modo que un host
+	// lento solo ocupa perHost cupos del semáforo global y no bloquea a los demás
+	for _, hostURLs := range groupURLsByHost(urls) {
+		jobs := make(chan string, len(hostURLs))
+		for _, crlURL := range hostURLs {
+			jobs <- crlURL
+		}
+		close(jobs)
+
+		workers := perHost
+		if len(hostURLs) < workers {
+			workers = len(hostURLs)
+		}
+
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for url := range jobs {
+					select {
+					case semaphore <- struct{}{}:
+					case <-ctx.Done():
+						return
+					}
+					jobCtx, job := s.startCRLJob(ctx, url)
+					err := s.fetchCRLJob(jobCtx, job)
+					// El cupo de descarga se libera antes de esperar a la persistencia
+					<-semaphore
+
+					if err != nil || job.crl == nil {
+						if err := s.finishCRLJob(jobCtx, job, err); err != nil {
+							log.Printf("Error processing CRL %s: %v", url, err)
+						}
+						continue
+					}
+					fetched <- job
+				}
+			}()
+		}
 	}
 
 	wg.Wait()
+	close(fetched)
+	persistWG.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("CRL processing cancelled: %v", err)
+	}
 	log.Printf("Finished processing all CRLs")
 
-	if s.redis != nil {
+	return nil
+}
+
+// crlJob es una CRL en tránsito por el procesamiento: la etapa de descarga completa la CRL
+// verificada y la de persistencia la importa. El historial y la marca de procesamiento se
+// cierran en finishCRLJob, después de la última etapa que corra.
+type crlJob struct {
+	url   string
+	entry *models.ProcessingLogEntry
+	span  trace.Span
+	// release libera la marca de procesamiento en Redis; nil si no se tomó
+	release func()
+
+	// Resultado de la descarga; crl es nil si no hay nada que importar (no modificada u omitida)
+	crl        *decodedCRL
+	download   *crlDownload
+	entryCount int
+	issuer     string
+	issuerDN   string
+	crlNumber  *big.Int
+}
+
+func (s *CRLService) ProcessSingleCRL(ctx context.Context, crlURL string) error {
+	ctx, job := s.startCRLJob(ctx, crlURL)
+
+	err := s.fetchCRLJob(ctx, job)
+	if err == nil && job.crl != nil {
+		err = s.persistCRL(ctx, job)
+	}
+
+	return s.finishCRLJob(ctx, job, err)
+}
+
+// startCRLJob abre el span y la entrada de historial del procesamiento de una CRL
+func (s *CRLService) startCRLJob(ctx context.Context, crlURL string) (context.Context, *crlJob) {
+	ctx, span := tracing.Start(ctx, "CRLService.ProcessSingleCRL",
+		trace.WithAttributes(attribute.String("crl.url", crlURL)))
+
+	return ctx, &crlJob{
+		url:  crlURL,
+		span: span,
+		entry: &models.ProcessingLogEntry{
+			URL:       crlURL,
+			StartedAt: time.Now(),
+			Status:    models.ProcessingStatusSuccess,
+		},
+	}
+}
+
+// finishCRLJob libera la marca de procesamiento, guarda el historial y cierra el span
+func (s *CRLService) finishCRLJob(ctx context.Context, job *crlJob, err error) error {
+	if job.release != nil {
+		job.release()
+	}
+
+	tracing.RecordError(job.span, err)
+	defer job.span.End()
+
+	entry := job.entry
+	entry.DurationMs = time.Since(entry.StartedAt).Milliseconds()
+	if err != nil {
+		entry.Status = models.ProcessingStatusFailed
+		entry.Error = err.Error()
+	}
+	if err == nil && entry.Status == models.ProcessingStatusSuccess && s.redis != nil {
 		s.redis.IncrementStats("stats:crls_processed")
 	}
+	// Un intento omitido o cancelado no dice nada de la disponibilidad de la CRL, salvo que la
+	// descarga haya fallado; uno servido desde el cache en disco cuenta como fallido para no
+	// reiniciar los fallos consecutivos
+	attemptErr := err
+	if attemptErr == nil && job.download != nil {
+		attemptErr = job.download.fallbackErr
+	}
+	if (entry.Status != models.ProcessingStatusSkipped || attemptErr != nil) && ctx.Err() == nil {
+		s.recordCRLAttempt(ctx, job.url, attemptErr)
+	}
+	// El historial se guarda aunque el procesamiento se haya cancelado
+	if logErr := s.db.InsertProcessingLog(context.WithoutCancel(ctx), entry); logErr != nil {
+		log.Printf("Error recording processing history for %s: %v", job.url, logErr)
+	}
 
-	return nil
+	return err
 }
 
-func (s *CRLService) ProcessSingleCRL(crlURL string) error {
+// fetchCRLJob descarga, parsea y verifica la CRL. Deja job.crl en nil si no hay nada que
+// importar; en ese caso el historial ya indica el motivo.
+func (s *CRLService) fetchCRLJob(ctx context.Context, job *crlJob) error {
+	crlURL, entry := job.url, job.entry
+
 	if s.redis != nil {
 		processing, err := s.redis.IsCRLProcessing(crlURL)
 		if err != nil {
 			log.Printf("Error checking CRL processing status: %v", err)
 		} else if processing {
 			log.Printf("CRL %s is already being processed, skipping", crlURL)
+			entry.Status = models.ProcessingStatusSkipped
 			return nil
 		}
 
@@ -112,162 +600,913 @@ func (s *CRLService) ProcessSingleCRL(crlURL string) error {
 		if err != nil {
 			log.Printf("Error setting CRL processing status: %v", err)
 		}
-		defer s.redis.SetCRLProcessing(crlURL, false)
+		job.release = func() { s.redis.SetCRLProcessing(crlURL, false) }
 	}
 
 	log.Printf("Processing CRL: %s", crlURL)
 
-	crlData, err := s.downloadCRL(crlURL)
+	validators, err := s.db.GetCRLValidators(ctx, crlURL)
 	if err != nil {
+		log.Printf("Error getting cache validators for CRL %s: %v", crlURL, err)
+		validators = &models.CRLValidators{}
+	}
+
+	downloadCtx, downloadSpan := tracing.Start(ctx, "crl.download")
+	download, err := s.downloadCRL(downloadCtx, crlURL, validators)
+	tracing.RecordError(downloadSpan, err)
+	downloadSpan.End()
+	if err != nil {
+		var statusErr *httpStatusError
+		if errors.As(err, &statusErr) {
+			entry.HTTPStatus = statusErr.statusCode
+		}
 		return fmt.Errorf("error downloading CRL: %v", err)
 	}
 
-	crl, err := x509.ParseCRL(crlData)
+	job.download = download
+	entry.HTTPStatus = download.statusCode
+	entry.BytesDownloaded = len(download.data)
+	if download.fallbackErr != nil {
+		entry.Status = models.ProcessingStatusServedFromCache
+		entry.Error = download.fallbackErr.Error()
+	}
+
+	if download.notModified && download.fallbackErr != nil {
+		// La copia en disco es la ya importada: no hay nada que importar, pero la CRL no se
+		// obtuvo del servidor, por lo que last_processed no se renueva
+		log.Printf("Cached copy of CRL %s was already imported, skipping", crlURL)
+		return nil
+	}
+
+	if download.notModified {
+		entry.Status = models.ProcessingStatusNotModified
+		log.Printf("CRL %s not modified since last download, skipping", crlURL)
+		if err := s.db.TouchCRLInfo(ctx, crlURL); err != nil {
+			log.Printf("Error updating last processed time for %s: %v", crlURL, err)
+		}
+		return nil
+	}
+
+	_, parseSpan := tracing.Start(ctx, "crl.parse",
+		trace.WithAttributes(attribute.Int("crl.size_bytes", len(download.data))))
+	crl, err := s.decodeCRL(download.data)
+	var entryCount int
+	if err == nil {
+		// Contar las entradas antes de importar para aplicar MAX_CRL_ENTRIES
+		entryCount, err = crl.countEntries()
+	}
+	tracing.RecordError(parseSpan, err)
+	parseSpan.End()
 	if err != nil {
-		return fmt.Errorf("error parsing CRL: %v", err)
+		return fmt.Errorf("error parsing CRL %s: %v", crlURL, err)
+	}
+
+	entry.CertCount = entryCount
+	if s.cfg.MaxCRLEntries > 0 && entryCount > s.cfg.MaxCRLEntries {
+		return fmt.Errorf("CRL %s lists %d entries, above MAX_CRL_ENTRIES (%d)", crlURL, entryCount, s.cfg.MaxCRLEntries)
 	}
 
 	var issuerName pkix.Name
 	issuerName.FillFromRDNSequence(&crl.TBSCertList.Issuer)
-	issuerNameStr := s.extractIssuerName(issuerName)
+
+	if _, err := s.verifyCRLSignature(ctx, crl.CertificateList, issuerName); err != nil {
+		return fmt.Errorf("error verifying CRL %s: %v", crlURL, err)
+	}
+
+	crlNumber := s.extractCRLNumber(crl.CertificateList)
+	if crlNumber != nil {
+		storedNumber, err := s.db.GetCRLNumber(ctx, crlURL)
+		if err != nil {
+			log.Printf("Error getting stored CRL number for %s: %v", crlURL, err)
+		} else if stored, ok := new(big.Int).SetString(storedNumber, 10); ok && crlNumber.Cmp(stored) < 0 {
+			log.Printf("Warning: CRL %s has CRLNumber %s lower than stored %s, skipping stale CRL", crlURL, crlNumber, stored)
+			entry.Status = models.ProcessingStatusSkipped
+			return nil
+		}
+	}
+
+	job.crl = crl
+	job.entryCount = entryCount
+	job.issuer = s.extractIssuerName(issuerName)
+	job.issuerDN = canonicalIssuerDN(crl.TBSCertList.Issuer)
+	job.crlNumber = crlNumber
+	return nil
+}
+
+// persistCRL importa en lotes las entradas de una CRL ya verificada por fetchCRLJob,
+// completando en job.entry los datos del historial
+func (s *CRLService) persistCRL(ctx context.Context, job *crlJob) error {
+	crlURL, entry, crl, download := job.url, job.entry, job.crl, job.download
+	issuerNameStr, issuerDN, entryCount, crlNumber := job.issuer, job.issuerDN, job.entryCount, job.crlNumber
 
 	crlInfo := &models.CRLInfo{
 		URL:           crlURL,
 		Issuer:        issuerNameStr,
+		IssuerDN:      issuerDN,
 		NextUpdate:    crl.TBSCertList.NextUpdate,
 		LastProcessed: time.Now(),
-		CertCount:     len(crl.TBSCertList.RevokedCertificates),
+		CertCount:     entryCount,
+	}
+	if crlNumber != nil {
+		crlInfo.CRLNumber = crlNumber.String()
+	}
+	// Una copia del cache en disco no prueba que la CRL siga disponible: se conserva el
+	// last_processed de la última descarga real
+	if download.fallbackErr != nil {
+		if stored, err := s.db.GetCRLInfo(ctx, crlURL); err == nil {
+			crlInfo.LastProcessed = stored.LastProcessed
+		}
 	}
 
-	err = s.db.InsertCRLInfo(crlInfo)
+	err := s.db.InsertCRLInfo(ctx, crlInfo)
 	if err != nil {
 		log.Printf("Error inserting CRL info: %v", err)
+	} else {
+		s.noteNextUpdate(crlInfo.Issuer, crlInfo.NextUpdate)
 	}
 
+	// La CRL vencida queda registrada en crl_info pero sus entradas no se importan, para no
+	// pisar datos más recientes con los de una fuente probablemente rota
+	if s.isExpiredCRL(crl.TBSCertList.NextUpdate) {
+		log.Printf("Warning: CRL %s expired at %s, more than %v ago, skipping import", crlURL, crl.TBSCertList.NextUpdate.Format(time.RFC3339), s.cfg.ExpiredCRLGrace)
+		entry.Status = models.ProcessingStatusSkipped
+		return nil
+	}
+
+	isDelta := s.isDeltaCRL(crl.CertificateList)
+	isIndirect := s.isIndirectCRL(crl.CertificateList)
+
 	// Procesar certificados en batch para mejor rendimiento
-	batchSize := 500
+	batchSize := s.cfg.ImportBatchSize
 	certificates := make([]*models.RevokedCertificate, 0, batchSize)
 
 	processed := 0
-	for _, revokedCert := range crl.TBSCertList.RevokedCertificates {
+	insertFailed := false
+	// Entradas mal formadas que se omitieron
+	skipped := 0
+	var newCertificates []*models.RevokedCertificate
+	// Seriales marcados removeFromCRL por CA: solo se eliminan las filas de su emisor
+	removedSerials := make(map[string][]string)
+	// Seriales vistos por DN de CA, para reconciliar cada emisor por separado en CRLs indirectas.
+	// Solo se acumulan si se va a reconciliar, ya que es lo único que crece con la CRL
+	reconcile := s.cfg.ReconcileCRLs && !isDelta
+	serialsByCA := make(map[string]*issuerSerials)
+	issuers := &entryIssuers{name: issuerNameStr, dn: issuerDN}
+	// Las entradas se decodifican de a una, por lo que en memoria queda a lo sumo un lote
+	err = crl.forEachEntry(func(revokedCert pkix.RevokedCertificate) error {
+		// Al cancelarse ctx se deja de leer la CRL y el lote acumulado se guarda más abajo
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		serial := s.formatSerial(revokedCert.SerialNumber)
+		// Una entrada firmada con serial legible indica que el certificado está revocado aunque
+		// alguna extensión esté mal formada: se importa sin el dato que no se pudo leer
+		reason, err := s.extractReasonCode(revokedCert)
+		if err != nil {
+			log.Printf("Warning: CRL %s lists serial %s with %v, storing it with reason unspecified", crlURL, serial, err)
+			reason = models.ReasonUnspecified
+		}
+		invalidityDate, err := extractInvalidityDate(revokedCert)
+		if err != nil {
+			log.Printf("Warning: CRL %s lists serial %s with %v, storing it without invalidity date", crlURL, serial, err)
+		}
+		// Solo se omite una entrada de una CRL indirecta cuyo emisor no se conoce
+		if isIndirect {
+			if err := issuers.next(s, revokedCert); err != nil {
+				log.Printf("Warning: skipping entry for serial %s in CRL %s: %v", serial, crlURL, err)
+				skipped++
+				return nil
+			}
+		}
+		entryIssuer, entryIssuerDN := issuers.name, issuers.dn
+		if len(revokedCert.SerialNumber.Bytes()) > maxRFCSerialOctets {
+			log.Printf("Warning: CRL %s lists serial %s longer than %d octets", crlURL, serial, maxRFCSerialOctets)
+		}
 
-		reason := 0
-		reasonText := ""
+		// En una delta CRL, removeFromCRL indica que el certificado (antes retenido) deja de
+		// estar revocado; en una CRL base el motivo no es válido (RFC 5280, 5.3.1) y se guarda tal cual
+		if reason == models.ReasonRemoveFromCRL {
+			if isDelta {
+				removedSerials[entryIssuer] = append(removedSerials[entryIssuer], serial)
+				return nil
+			}
+			log.Printf("Warning: base CRL %s lists %s with reason removeFromCRL, storing it as revoked", crlURL, serial)
+		}
 
-		for _, ext := range revokedCert.Extensions {
-			if ext.Id.Equal([]int{2, 5, 29, 21}) {
-				if len(ext.Value) > 0 {
-					reason = int(ext.Value[0])
-					if reasonText, exists := models.RevocationReasons[reason]; exists {
-						reasonText = reasonText
-					}
-				}
+		if reconcile {
+			seen, ok := serialsByCA[entryIssuerDN]
+			if !ok {
+				seen = &issuerSerials{name: entryIssuer}
+				serialsByCA[entryIssuerDN] = seen
 			}
+			seen.serials = append(seen.serials, serial)
 		}
+		reasonText := models.RevocationReasons[reason]
 
 		revokedCertificate := &models.RevokedCertificate{
 			Serial:               serial,
 			RevocationDate:       revokedCert.RevocationTime,
 			Reason:               reason,
 			ReasonText:           reasonText,
-			CertificateAuthority: issuerNameStr,
+			CertificateAuthority: entryIssuer,
+			IssuerDN:             entryIssuerDN,
+			InvalidityDate:       invalidityDate,
 		}
 
 		certificates = append(certificates, revokedCertificate)
 
 		// Insertar en batch cuando se alcanza el tamaño del batch
 		if len(certificates) >= batchSize {
-			err = s.db.BatchInsertRevokedCertificates(certificates)
+			inserted, err := s.insertBatch(ctx, certificates)
+			if err != nil && ctx.Err() != nil {
+				// Cancelado durante la inserción: el lote se conserva para guardarlo más abajo
+				return ctx.Err()
+			}
 			if err != nil {
 				log.Printf("Error batch inserting certificates: %v", err)
+				insertFailed = true
 			} else {
 				processed += len(certificates)
-			}
-
-			// Cachear certificados en Redis
-			if s.redis != nil {
-				for _, cert := range certificates {
-					status := &models.CertificateStatus{
-						Serial:               cert.Serial,
-						IsRevoked:            true,
-						RevocationDate:       &cert.RevocationDate,
-						Reason:               &cert.ReasonText,
-						CertificateAuthority: &issuerNameStr,
-					}
-					err = s.redis.SetCertificateStatus(cert.Serial, status, 24*time.Hour)
-					if err != nil {
-						log.Printf("Error caching certificate status for %s: %v", cert.Serial, err)
-					}
+				if s.notifier != nil {
+					newCertificates = append(newCertificates, inserted...)
+				}
+				if s.redis != nil {
+					s.invalidateImportedBatch(ctx, certificates)
 				}
 			}
 
 			certificates = make([]*models.RevokedCertificate, 0, batchSize)
 		}
+		return nil
+	}, func(index int, err error) {
+		log.Printf("Warning: skipping undecodable entry %d in CRL %s: %v", index, crlURL, err)
+		skipped++
+	})
+	entry.SkippedEntries = skipped
+	cancelled := ctx.Err() != nil
+	if err != nil && !cancelled {
+		return fmt.Errorf("error parsing CRL %s: %v", crlURL, err)
 	}
 
-	// Insertar certificados restantes
+	// Insertar certificados restantes; si se canceló ctx el lote pendiente se guarda igual,
+	// con su propio límite de tiempo, para no perder lo ya leído de la CRL
 	if len(certificates) > 0 {
-		err = s.db.BatchInsertRevokedCertificates(certificates)
+		insertCtx := ctx
+		if cancelled {
+			var cancel context.CancelFunc
+			insertCtx, cancel = context.WithTimeout(context.WithoutCancel(ctx), cancelFlushTimeout)
+			defer cancel()
+		}
+
+		inserted, err := s.insertBatch(insertCtx, certificates)
 		if err != nil {
 			log.Printf("Error batch inserting remaining certificates: %v", err)
+			insertFailed = true
 		} else {
 			processed += len(certificates)
+			if s.notifier != nil {
+				newCertificates = append(newCertificates, inserted...)
+			}
+			if cancelled {
+				s.flushedOnCancel.Add(int64(len(certificates)))
+				log.Printf("Flushed %d buffered certificates from CRL %s after cancellation", len(certificates), crlURL)
+			}
+			if s.redis != nil {
+				s.invalidateImportedBatch(insertCtx, certificates)
+			}
 		}
+	}
 
-		// Cachear certificados restantes en Redis
-		if s.redis != nil {
-			for _, cert := range certificates {
-				status := &models.CertificateStatus{
-					Serial:               cert.Serial,
-					IsRevoked:            true,
-					RevocationDate:       &cert.RevocationDate,
-					Reason:               &cert.ReasonText,
-					CertificateAuthority: &issuerNameStr,
-				}
-				err = s.redis.SetCertificateStatus(cert.Serial, status, 24*time.Hour)
-				if err != nil {
-					log.Printf("Error caching certificate status for %s: %v", cert.Serial, err)
+	if !cancelled {
+		for ca, serials := range removedSerials {
+			s.removeCertificates(ctx, crlURL, ca, serials)
+		}
+	}
+
+	if s.notifier != nil && len(newCertificates) > 0 {
+		// Las CRLs indirectas mezclan emisores; cada notificación lleva los de una sola CA
+		byCA := make(map[string][]*models.RevokedCertificate)
+		for _, cert := range newCertificates {
+			byCA[cert.CertificateAuthority] = append(byCA[cert.CertificateAuthority], cert)
+		}
+		for ca, certs := range byCA {
+			s.notifier.Notify(crlURL, ca, certs)
+		}
+	}
+
+	// Una importación cancelada quedó incompleta: no se guardan validadores ni se reconcilia
+	if cancelled {
+		return fmt.Errorf("processing of CRL %s cancelled after %d certificates: %v", crlURL, processed, ctx.Err())
+	}
+
+	// Guardar validadores solo si la importación fue completa, para no omitir
+	// con un 304 una CRL que quedó a medio importar
+	if !insertFailed {
+		err = s.db.UpdateCRLValidators(ctx, crlURL, &models.CRLValidators{
+			ETag:         download.etag,
+			LastModified: download.lastModified,
+		})
+		if err != nil {
+			log.Printf("Error saving cache validators for CRL %s: %v", crlURL, err)
+		}
+	}
+
+	if s.cfg.ReconcileCRLs {
+		switch {
+		case insertFailed:
+			log.Printf("Skipping reconciliation for CRL %s: some certificates failed to import", crlURL)
+		case skipped > 0:
+			// Los seriales o emisores de las entradas omitidas no se conocen: se eliminarían de
+			// la base revocaciones que la CRL sigue listando
+			log.Printf("Skipping reconciliation for CRL %s: %d malformed entries were skipped", crlURL, skipped)
+		case isDelta:
+			// Una delta CRL solo lista cambios; reconciliar contra ella borraría la CRL base
+			log.Printf("Skipping reconciliation for delta CRL %s", crlURL)
+		case s.isScopedCRL(crl.CertificateList):
+			// Una CRL particionada solo lista su parte; reconciliar contra ella borraría las
+			// revocaciones que publican las demás particiones
+			log.Printf("Skipping reconciliation for CRL %s: its IssuingDistributionPoint limits its scope", crlURL)
+		default:
+			shared, err := s.issuersWithOtherCRLs(ctx, crlURL)
+			if err != nil {
+				log.Printf("Skipping reconciliation for CRL %s: %v", crlURL, err)
+				break
+			}
+			// Si la CRL no lista ningún certificado de su propio emisor, se eliminan todos los de ese emisor
+			if _, ok := serialsByCA[issuerDN]; !ok {
+				serialsByCA[issuerDN] = &issuerSerials{name: issuerNameStr}
+			}
+			for dn, seen := range serialsByCA {
+				if shared[dn] {
+					log.Printf("Skipping reconciliation of %s for CRL %s: the issuer publishes other CRLs", seen.name, crlURL)
+					continue
 				}
+				s.reconcileCertificates(ctx, dn, seen.name, seen.serials)
 			}
 		}
 	}
 
-	log.Printf("Successfully processed CRL %s: %d certificates processed", crlURL, processed)
+	log.Printf("Successfully processed CRL %s: %d certificates processed, %d malformed entries skipped", crlURL, processed, skipped)
 	return nil
 }
 
-func (s *CRLService) downloadCRL(crlURL string) ([]byte, error) {
+// isExpiredCRL indica si, con REJECT_EXPIRED_CRLS, el NextUpdate de la CRL quedó atrás por más
+// del período de gracia. Una CRL sin NextUpdate nunca vence.
+func (s *CRLService) isExpiredCRL(nextUpdate time.Time) bool {
+	if !s.cfg.RejectExpiredCRLs || nextUpdate.IsZero() {
+		return false
+	}
+	return time.Since(nextUpdate) > s.cfg.ExpiredCRLGrace
+}
+
+// insertBatch inserta un lote de certificados dentro de su propio span
+func (s *CRLService) insertBatch(ctx context.Context, certificates []*models.RevokedCertificate) ([]*models.RevokedCertificate, error) {
+	ctx, span := tracing.Start(ctx, "crl.batch_insert",
+		trace.WithAttributes(attribute.Int("crl.batch_size", len(certificates))))
+	defer span.End()
+
+	inserted, err := s.db.BatchInsertRevokedCertificates(ctx, certificates)
+	tracing.RecordError(span, err)
+	if err == nil {
+		s.adjustRevokedTotal(len(inserted))
+	}
+	return inserted, err
+}
+
+// invalidateImportedBatch descarta en Redis el estado de los seriales de un lote ya guardado.
+// No se cachea el estado importado: sin CA la base responde la revocación más reciente del
+// serial entre todas las CAs, que no tiene por qué ser la de la CRL recién importada.
+func (s *CRLService) invalidateImportedBatch(ctx context.Context, certificates []*models.RevokedCertificate) {
+	serials := make([]string, len(certificates))
+	for i, cert := range certificates {
+		serials[i] = cert.Serial
+	}
+
+	if err := s.redis.InvalidateCertificateStatusBatch(ctx, serials, s.cfg.RedisChunkSize); err != nil {
+		log.Printf("Error invalidating %d cached certificate statuses: %v", len(serials), err)
+	}
+}
+
+// CleanupResult resume lo eliminado por una ejecución de Cleanup
+type CleanupResult struct {
+	ProcessingFlags int
+	Certificates    int
+	StatsCounters   int
+	ProcessingLogs  int
+}
+
+// Cleanup elimina marcas de procesamiento huérfanas y, si está configurado, los certificados
+// de CAs que ya no tienen ninguna CRL configurada y los contadores de stats. crlURLsFile es el
+// archivo de URLs, cuyas CRLs se consideran configuradas junto con las de CRL_URLS_JSON y
+// crl_sources.
+func (s *CRLService) Cleanup(ctx context.Context, crlURLsFile string) CleanupResult {
+	var result CleanupResult
+
+	if s.redis != nil {
+		removed, err := s.redis.PurgeProcessingFlags()
+		if err != nil {
+			log.Printf("Error purging CRL processing flags: %v", err)
+		}
+		result.ProcessingFlags = removed
+	}
+
+	if s.cfg.CleanupRetention > 0 {
+		result.Certificates = s.deleteUntrackedCertificates(ctx, crlURLsFile)
+	}
+
+	if s.cfg.ProcessingLogRetention > 0 {
+		pruned, err := s.db.PruneProcessingLog(ctx, time.Now().Add(-s.cfg.ProcessingLogRetention))
+		if err != nil {
+			log.Printf("Error pruning processing history: %v", err)
+		}
+		result.ProcessingLogs = pruned
+	}
+
+	if s.redis != nil && s.cfg.CleanupResetStats {
+		removed, err := s.redis.ResetStats()
+		if err != nil {
+			log.Printf("Error resetting stats counters: %v", err)
+		}
+		result.StatsCounters = removed
+	}
+
+	return result
+}
+
+// deleteUntrackedCertificates elimina los certificados de CAs sin CRL configurada que no se
+// actualizaron dentro de CLEANUP_RETENTION y devuelve cuántos eliminó. Si no se pueden
+// determinar las URLs configuradas no elimina nada, para no borrar revocaciones vigentes.
+func (s *CRLService) deleteUntrackedCertificates(ctx context.Context, crlURLsFile string) int {
+	tracked, err := s.trackedCRLURLs(crlURLsFile)
+	if err != nil {
+		log.Printf("Skipping deletion of untracked certificates: %v", err)
+		return 0
+	}
+
+	deleted, err := s.db.DeleteUntrackedCertificates(ctx, time.Now().Add(-s.cfg.CleanupRetention), tracked)
+	if err != nil {
+		log.Printf("Error deleting untracked certificates: %v", err)
+	}
+	s.adjustRevokedTotal(-len(deleted))
+
+	if s.redis != nil && len(deleted) > 0 {
+		if err := s.redis.DeleteCertificateStatus(deleted...); err != nil {
+			log.Printf("Error invalidating cache for deleted certificates: %v", err)
+		}
+	}
+	return len(deleted)
+}
+
+// trackedCRLURLs devuelve las URLs de CRL_URLS_JSON y del archivo de URLs, esté o no en uso;
+// las de crl_sources las agrega la base. Un archivo inexistente no aporta URLs.
+func (s *CRLService) trackedCRLURLs(crlURLsFile string) ([]string, error) {
+	tracked := append([]string{}, s.inlineURLs...)
+	if crlURLsFile == "" {
+		return tracked, nil
+	}
+
+	s.fileURLsMu.RLock()
+	urls, ok := s.fileURLs[crlURLsFile]
+	s.fileURLsMu.RUnlock()
+	if !ok {
+		if _, err := os.Stat(crlURLsFile); errors.Is(err, os.ErrNotExist) {
+			return tracked, nil
+		}
+		var err error
+		if urls, err = s.LoadCRLURLs(crlURLsFile); err != nil {
+			return nil, err
+		}
+	}
+	return append(tracked, urls...), nil
+}
+
+// WarnStaleCRLs registra una advertencia por cada CRL cuyo next_update ya pasó y renueva los
+// next_update por emisor que usa IsCAStale
+func (s *CRLService) WarnStaleCRLs(ctx context.Context) {
+	infos, err := s.db.ListCRLInfo(ctx)
+	if err != nil {
+		log.Printf("Error checking stale CRLs: %v", err)
+		return
+	}
+
+	now := time.Now()
+	nextUpdates := make(map[string]time.Time, len(infos))
+	for _, info := range infos {
+		if info.NextUpdate.IsZero() {
+			continue
+		}
+		if info.NextUpdate.Before(now) {
+			log.Printf("Warning: CRL %s (%s) is stale, next update was %s", info.URL, info.Issuer, info.NextUpdate.Format(time.RFC3339))
+		}
+		if info.NextUpdate.After(nextUpdates[info.Issuer]) {
+			nextUpdates[info.Issuer] = info.NextUpdate
+		}
+	}
+
+	s.nextUpdatesMu.Lock()
+	s.nextUpdates = nextUpdates
+	s.nextUpdatesMu.Unlock()
+}
+
+// noteNextUpdate registra el next_update de una CRL recién importada, para que X-CRL-Stale
+// deje de indicarse sin esperar a la siguiente pasada de WarnStaleCRLs
+func (s *CRLService) noteNextUpdate(issuer string, nextUpdate time.Time) {
+	if nextUpdate.IsZero() {
+		return
+	}
+
+	s.nextUpdatesMu.Lock()
+	defer s.nextUpdatesMu.Unlock()
+	if s.nextUpdates == nil {
+		s.nextUpdates = make(map[string]time.Time)
+	}
+	if nextUpdate.After(s.nextUpdates[issuer]) {
+		s.nextUpdates[issuer] = nextUpdate
+	}
+}
+
+// IsCAStale indica si la CRL más reciente de la CA ya superó su next_update, según los datos
+// en memoria; una CA sin CRL conocida no se considera vencida
+func (s *CRLService) IsCAStale(ca string) bool {
+	s.nextUpdatesMu.RLock()
+	nextUpdate, ok := s.nextUpdates[ca]
+	s.nextUpdatesMu.RUnlock()
+	return ok && nextUpdate.Before(time.Now())
+}
+
+// issuersWithOtherCRLs devuelve los DN de los emisores que, además de crlURL, tienen otra
+// CRL registrada en crl_info. Las revocaciones de esos emisores pueden provenir de cualquiera de
+// sus CRLs, por lo que ninguna de ellas basta para reconciliarlos.
+func (s *CRLService) issuersWithOtherCRLs(ctx context.Context, crlURL string) (map[string]bool, error) {
+	infos, err := s.db.ListCRLInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error loading CRL info: %v", err)
+	}
+
+	shared := make(map[string]bool)
+	for _, info := range infos {
+		if info.URL != crlURL && info.IssuerDN != "" {
+			shared[info.IssuerDN] = true
+		}
+	}
+	return shared, nil
+}
+
+// issuerSerials son los seriales que una CRL lista para un emisor, con su nombre para mostrar
+type issuerSerials struct {
+	name    string
+	serials []string
+}
+
+// reconcileCertificates elimina los certificados del emisor que ya no aparecen en su CRL.
+// El emisor se identifica por su DN; issuer es el nombre para los logs y las filas sin DN.
+func (s *CRLService) reconcileCertificates(ctx context.Context, issuerDN, issuer string, serials []string) {
+	deleted, err := s.db.DeleteCertificatesNotIn(ctx, issuerDN, issuer, serials)
+	if err != nil {
+		log.Printf("Error reconciling certificates for %s: %v", issuer, err)
+		return
+	}
+
+	if len(deleted) == 0 {
+		return
+	}
+
+	log.Printf("Reconciliation removed %d certificates no longer listed by %s", len(deleted), issuer)
+	s.adjustRevokedTotal(-len(deleted))
+
+	if s.redis != nil {
+		err = s.redis.DeleteCertificateStatus(deleted...)
+		if err != nil {
+			log.Printf("Error invalidating cache for reconciled certificates: %v", err)
+		}
+	}
+}
+
+// removeCertificates elimina los certificados de la CA que una delta CRL marca con
+// removeFromCRL e invalida su estado en cache
+func (s *CRLService) removeCertificates(ctx context.Context, crlURL, ca string, serials []string) {
+	deleted, err := s.db.DeleteRevokedCertificates(ctx, ca, serials)
+	if err != nil {
+		log.Printf("Error removing certificates listed as removeFromCRL in %s: %v", crlURL, err)
+		return
+	}
+
+	log.Printf("Delta CRL %s removed %d of %d certificates of %s marked removeFromCRL", crlURL, len(deleted), len(serials), ca)
+	s.adjustRevokedTotal(-len(deleted))
+
+	// Se invalidan todos los seriales: el cache puede conservar un estado revocado aunque la fila ya no exista
+	if s.redis != nil {
+		if err := s.redis.DeleteCertificateStatus(serials...); err != nil {
+			log.Printf("Error invalidating cache for removed certificates: %v", err)
+		}
+	}
+}
+
+// PurgeCAResult resume lo eliminado por PurgeCA
+type PurgeCAResult struct {
+	CertificateAuthority string `json:"certificate_authority"`
+	Certificates         int    `json:"certificates_deleted"`
+	CRLInfo              int    `json:"crl_info_deleted"`
+}
+
+// PurgeCA elimina los certificados revocados y la información de CRL de una CA dada de baja
+// e invalida su estado en cache. Si su CRL sigue configurada como fuente, el siguiente
+// procesamiento la vuelve a importar.
+func (s *CRLService) PurgeCA(ctx context.Context, ca string) (*PurgeCAResult, error) {
+	serials, crlInfos, err := s.db.PurgeCA(ctx, ca)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Purged CA %s: %d certificates and %d CRL info rows deleted", ca, len(serials), crlInfos)
+	s.adjustRevokedTotal(-len(serials))
+
+	if s.redis != nil {
+		// Se invalida en bloques de REDIS_CHUNK_SIZE claves por comando DEL
+		for start := 0; start < len(serials); start += s.cfg.RedisChunkSize {
+			end := min(start+s.cfg.RedisChunkSize, len(serials))
+			if err := s.redis.DeleteCertificateStatus(serials[start:end]...); err != nil {
+				log.Printf("Error invalidating cache for purged CA %s: %v", ca, err)
+			}
+		}
+	}
+
+	return &PurgeCAResult{
+		CertificateAuthority: ca,
+		Certificates:         len(serials),
+		CRLInfo:              crlInfos,
+	}, nil
+}
+
+func (s *CRLService) isDeltaCRL(crl *pkix.CertificateList) bool {
+	for _, ext := range crl.TBSCertList.Extensions {
+		if ext.Id.Equal(oidDeltaCRLIndicator) {
+			return true
+		}
+	}
+	return false
+}
+
+// crlDownload es el resultado de una descarga condicional
+type crlDownload struct {
+	// statusCode es 0 en descargas que no son HTTP (LDAP)
+	statusCode   int
+	data         []byte
+	etag         string
+	lastModified string
+	notModified  bool
+	// fallbackErr es el error de la descarga cuando se usó en su lugar la copia del cache en
+	// disco; el intento cuenta como fallido aunque se importe la copia
+	fallbackErr error
+}
+
+// httpStatusError es una respuesta HTTP distinta de 200 o 304; conserva el código para el historial
+type httpStatusError struct {
+	statusCode int
+	status     string
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("HTTP error: %d %s", e.statusCode, e.status)
+}
+
+// fetchCRL realiza un único intento de descarga según el esquema de la URL; los fallos
+// transitorios se devuelven como *retryableError para que downloadCRL los reintente
+func (s *CRLService) fetchCRL(ctx context.Context, crlURL string, validators *models.CRLValidators) (*crlDownload, error) {
 	parsedURL, err := url.Parse(crlURL)
 	if err != nil {
 		return nil, fmt.Errorf("invalid URL: %v", err)
 	}
 
-	// Usar el cliente HTTP reutilizable con pool de conexiones
-	req, err := http.NewRequest("GET", parsedURL.String(), nil)
+	if err := s.waitForHost(ctx, parsedURL); err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(parsedURL.Scheme) {
+	case "ldap", "ldaps":
+		return s.fetchLDAPCRL(ctx, parsedURL)
+	default:
+		return s.fetchHTTPCRL(ctx, parsedURL, validators)
+	}
+}
+
+func (s *CRLService) fetchHTTPCRL(ctx context.Context, parsedURL *url.URL, validators *models.CRLValidators) (*crlDownload, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", parsedURL.String(), nil)
 	if err != nil {
 		return nil, fmt.Errorf("error creating request: %v", err)
 	}
 
 	req.Header.Set("User-Agent", "SignerFlow-CRL-Service/1.0")
 	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	if validators.ETag != "" {
+		req.Header.Set("If-None-Match", validators.ETag)
+	}
+	if validators.LastModified != "" {
+		req.Header.Set("If-Modified-Since", validators.LastModified)
+	}
 
-	resp, err := s.httpClient.Do(req)
+	client, err := s.httpClientFor(ctx, parsedURL.String())
 	if err != nil {
-		return nil, fmt.Errorf("error downloading CRL: %v", err)
+		return nil, err
+	}
+
+	resp, err := doCRLRequest(client, req)
+	if err != nil {
+		return nil, &retryableError{err: fmt.Errorf("error downloading CRL: %v", err)}
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode >= 500 {
+		return nil, &retryableError{
+			err:        &httpStatusError{statusCode: resp.StatusCode, status: resp.Status},
+			retryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+
+	if resp.StatusCode == http.StatusNotModified {
+		return &crlDownload{statusCode: resp.StatusCode, notModified: true}, nil
+	}
+
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP error: %d %s", resp.StatusCode, resp.Status)
+		return nil, &httpStatusError{statusCode: resp.StatusCode, status: resp.Status}
+	}
+
+	if err := s.checkCRLContentType(resp.Header.Get("Content-Type")); err != nil {
+		return nil, err
+	}
+
+	maxSize := s.maxCRLSize()
+	if resp.ContentLength > maxSize {
+		return nil, fmt.Errorf("CRL size %d bytes exceeds the limit of %d MB", resp.ContentLength, s.cfg.MaxCRLSizeMB)
+	}
+
+	// Al fijar Accept-Encoding manualmente el transport no descomprime la respuesta
+	var body io.Reader = resp.Body
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "gzip", "x-gzip":
+		gzReader, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("error creating gzip reader: %v", err)
+		}
+		defer gzReader.Close()
+		body = gzReader
+	case "deflate":
+		flateReader := flate.NewReader(resp.Body)
+		defer flateReader.Close()
+		body = flateReader
+	}
+
+	// El límite se aplica después de descomprimir para acotar también los cuerpos comprimidos
+	data, err := io.ReadAll(io.LimitReader(body, maxSize+1))
+	if err != nil {
+		return nil, &retryableError{err: fmt.Errorf("error reading response body: %v", err)}
 	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("CRL exceeds the limit of %d MB", s.cfg.MaxCRLSizeMB)
+	}
+
+	return &crlDownload{
+		statusCode:   resp.StatusCode,
+		data:         data,
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+	}, nil
+}
 
-	data, err := io.ReadAll(resp.Body)
+// checkCRLContentType rechaza las respuestas cuyo Content-Type no puede ser una CRL, como la
+// página de error HTML que algunos servidores devuelven con 200 OK. Con CRL_CONTENT_TYPES vacío
+// se acepta cualquier tipo salvo HTML; una respuesta sin Content-Type se acepta siempre.
+func (s *CRLService) checkCRLContentType(header string) error {
+	if strings.TrimSpace(header) == "" {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(header)
 	if err != nil {
-		return nil, fmt.Errorf("error reading response body: %v", err)
+		mediaType = strings.ToLower(strings.TrimSpace(strings.Split(header, ";")[0]))
+	}
+
+	if len(s.cfg.CRLContentTypes) == 0 {
+		if mediaType == "text/html" || mediaType == "application/xhtml+xml" {
+			return fmt.Errorf("server returned an HTML page (Content-Type %q) instead of a CRL", header)
+		}
+		return nil
+	}
+
+	for _, allowed := range s.cfg.CRLContentTypes {
+		if allowedType, _, err := mime.ParseMediaType(allowed); err == nil && allowedType == mediaType {
+			return nil
+		}
+	}
+	return fmt.Errorf("unexpected Content-Type %q for a CRL, allowed: %s", header, strings.Join(s.cfg.CRLContentTypes, ", "))
+}
+
+// maxCRLSize devuelve en bytes el tamaño máximo aceptado para una CRL
+func (s *CRLService) maxCRLSize() int64 {
+	return int64(s.cfg.MaxCRLSizeMB) << 20
+}
+
+// decodeCRL acepta CRLs en DER o envueltas en armadura PEM (-----BEGIN X509 CRL-----)
+func (s *CRLService) decodeCRL(data []byte) (*decodedCRL, error) {
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("-----BEGIN")) {
+		block, _ := pem.Decode(trimmed)
+		if block == nil {
+			return nil, fmt.Errorf("invalid PEM data")
+		}
+		switch block.Type {
+		case "X509 CRL":
+			return parseDERCRL(block.Bytes)
+		case "PKCS7", "CMS":
+			return s.decodePKCS7CRL(block.Bytes)
+		default:
+			return nil, fmt.Errorf("unexpected PEM block type %q", block.Type)
+		}
+	}
+
+	crl, err := parseDERCRL(data)
+	if err != nil {
+		// Algunas CAs publican la CRL dentro de un SignedData (application/pkcs7-mime)
+		if crl, p7Err := s.decodePKCS7CRL(data); p7Err == nil {
+			return crl, nil
+		} else if !errors.Is(p7Err, errNotPKCS7) {
+			return nil, p7Err
+		}
+		return nil, fmt.Errorf("data is neither valid PEM nor DER: %v", err)
+	}
+
+	return crl, nil
+}
+
+// decodePKCS7CRL extrae la CRL de un contenedor PKCS#7; si hay varias se usa la primera
+func (s *CRLService) decodePKCS7CRL(data []byte) (*decodedCRL, error) {
+	crls, err := extractPKCS7CRLs(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(crls) > 1 {
+		log.Printf("Warning: PKCS#7 bundle contains %d CRLs, using the first one", len(crls))
+	}
+
+	return parseDERCRL(crls[0])
+}
+
+// extractReasonCode decodifica la extensión CRLReason (2.5.29.21) de la entrada; devuelve
+// ReasonUnspecified si no está presente y error si está mal formada
+func (s *CRLService) extractReasonCode(revokedCert pkix.RevokedCertificate) (int, error) {
+	for _, ext := range revokedCert.Extensions {
+		if !ext.Id.Equal(oidCRLReason) {
+			continue
+		}
+		var reason asn1.Enumerated
+		rest, err := asn1.Unmarshal(ext.Value, &reason)
+		if err != nil {
+			return 0, fmt.Errorf("invalid CRLReason extension: %v", err)
+		}
+		if len(rest) != 0 {
+			return 0, fmt.Errorf("invalid CRLReason extension: trailing data")
+		}
+		return int(reason), nil
 	}
+	return models.ReasonUnspecified, nil
+}
 
-	return data, nil
+// extractInvalidityDate decodifica la extensión Invalidity Date (2.5.29.24) de la entrada, la
+// fecha en que se sabe o se sospecha que la clave quedó comprometida; devuelve nil si no está
+// presente y error si está mal formada
+func extractInvalidityDate(revokedCert pkix.RevokedCertificate) (*time.Time, error) {
+	for _, ext := range revokedCert.Extensions {
+		if !ext.Id.Equal(oidInvalidityDate) {
+			continue
+		}
+		var date time.Time
+		rest, err := asn1.UnmarshalWithParams(ext.Value, &date, "generalized")
+		if err != nil {
+			return nil, fmt.Errorf("invalid InvalidityDate extension: %v", err)
+		}
+		if len(rest) != 0 {
+			return nil, fmt.Errorf("invalid InvalidityDate extension: trailing data")
+		}
+		date = date.UTC()
+		return &date, nil
+	}
+	return nil, nil
+}
+
+// extractCRLNumber lee la extensión CRLNumber (2.5.29.20); devuelve nil si no está presente
+func (s *CRLService) extractCRLNumber(crl *pkix.CertificateList) *big.Int {
+	for _, ext := range crl.TBSCertList.Extensions {
+		if !ext.Id.Equal(oidCRLNumber) {
+			continue
+		}
+		number := new(big.Int)
+		if _, err := asn1.Unmarshal(ext.Value, &number); err != nil {
+			log.Printf("Error parsing CRLNumber extension: %v", err)
+			return nil
+		}
+		return number
+	}
+	return nil
 }
 
 func (s *CRLService) extractIssuerName(issuer pkix.Name) string {
@@ -290,19 +1529,128 @@ func (s *CRLService) formatSerial(serial *big.Int) string {
 	return serial.String()
 }
 
-// normalizeSerial converts hexadecimal serial numbers to decimal
-// If the input is already decimal, it returns as-is
-func (s *CRLService) normalizeSerial(serial string) string {
-	return serial
+// NormalizeFingerprint quita separadores y pasa a minúsculas una huella SHA-256;
+// devuelve "" si no es una huella hexadecimal de 32 bytes
+func NormalizeFingerprint(fingerprint string) string {
+	cleaned := strings.ToLower(strings.NewReplacer(":", "", " ", "").Replace(strings.TrimSpace(fingerprint)))
+	decoded, err := hex.DecodeString(cleaned)
+	if err != nil || len(decoded) != sha256.Size {
+		return ""
+	}
+	return cleaned
+}
+
+// CheckCertificateByFingerprint busca por huella; devuelve nil si la huella no está registrada
+func (s *CRLService) CheckCertificateByFingerprint(ctx context.Context, fingerprint string) (*models.CertificateStatus, error) {
+	status, err := s.db.GetCertificateByFingerprint(ctx, fingerprint)
+	if err != nil {
+		return nil, fmt.Errorf("error getting certificate by fingerprint: %v", err)
+	}
+	return status, nil
+}
+
+// CheckCertificateData calcula serial y huella SHA-256 de un certificado en DER o PEM y
+// verifica su estado. Si el certificado está revocado se registra la huella para futuras consultas.
+func (s *CRLService) CheckCertificateData(ctx context.Context, data []byte) (*models.CertificateStatus, error) {
+	cert, err := parseCertificate(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.checkParsedCertificate(ctx, cert)
+}
+
+// VerifyCertificate verifica la revocación de un certificado en DER o PEM y además
+// su periodo de validez
+func (s *CRLService) VerifyCertificate(ctx context.Context, data []byte) (*models.CertificateVerification, error) {
+	cert, err := parseCertificate(data)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := s.checkParsedCertificate(ctx, cert)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	return &models.CertificateVerification{
+		CertificateStatus: *status,
+		Expired:           now.After(cert.NotAfter),
+		NotYetValid:       now.Before(cert.NotBefore),
+		NotBefore:         cert.NotBefore,
+		NotAfter:          cert.NotAfter,
+	}, nil
 }
 
-func (s *CRLService) CheckCertificateStatus(serial string) (*models.CertificateStatus, error) {
+// checkParsedCertificate consulta el estado del serial del certificado entre las revocaciones
+// de su emisor. La huella solo se guarda si el certificado está firmado por un certificado de
+// CA registrado: los endpoints que lo reciben son públicos y, de lo contrario, cualquiera
+// podría asociar a un serial revocado la huella de un certificado propio con el mismo serial.
+func (s *CRLService) checkParsedCertificate(ctx context.Context, cert *x509.Certificate) (*models.CertificateStatus, error) {
+	sum := sha256.Sum256(cert.Raw)
+	fingerprint := hex.EncodeToString(sum[:])
+
+	status, err := s.CheckCertificateStatusForCA(ctx, s.formatSerial(cert.SerialNumber), s.extractIssuerName(cert.Issuer))
+	if err != nil {
+		return nil, err
+	}
+
+	if status.IsRevoked && status.Fingerprint == nil {
+		trusted, err := s.isIssuedByRegisteredCA(ctx, cert)
+		if err != nil {
+			requestid.Logf(ctx, "Error verifying issuer of %s: %v", status.Serial, err)
+		} else if !trusted {
+			requestid.Logf(ctx, "Not saving fingerprint for %s: certificate is not signed by a registered CA", status.Serial)
+		} else if err := s.db.SetCertificateFingerprint(ctx, status.Serial, *status.CertificateAuthority, fingerprint); err != nil {
+			requestid.Logf(ctx, "Error saving fingerprint for %s: %v", status.Serial, err)
+		}
+	}
+
+	status.Fingerprint = &fingerprint
+	return status, nil
+}
+
+// ErrInvalidCertificate indica que los datos recibidos no son un certificado X.509
+var ErrInvalidCertificate = errors.New("invalid certificate")
+
+func parseCertificate(data []byte) (*x509.Certificate, error) {
+	if block, _ := pem.Decode(bytes.TrimSpace(data)); block != nil {
+		data = block.Bytes
+	}
+
+	cert, err := x509.ParseCertificate(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCertificate, err)
+	}
+	return cert, nil
+}
+
+// CheckCertificateStatus consulta el estado del serial; si varias CAs lo revocaron se informa
+// la revocación más reciente
+func (s *CRLService) CheckCertificateStatus(ctx context.Context, serial string) (*models.CertificateStatus, error) {
+	return s.CheckCertificateStatusForCA(ctx, serial, "")
+}
+
+// CheckCertificateStatusForCA consulta el estado del serial emitido por una CA concreta, para
+// distinguir seriales repetidos entre CAs. Con ca vacío se comporta como CheckCertificateStatus.
+func (s *CRLService) CheckCertificateStatusForCA(ctx context.Context, serial, ca string) (*models.CertificateStatus, error) {
+	ctx, span := tracing.Start(ctx, "CRLService.CheckCertificateStatus")
+	defer span.End()
+
 	// Normalize serial to decimal format
 	serial = s.normalizeSerial(serial)
+	span.SetAttributes(attribute.String("certificate.serial", serial))
+	if ca != "" {
+		span.SetAttributes(attribute.String("certificate.ca", ca))
+	}
 	if s.redis != nil {
-		status, err := s.redis.GetCertificateStatus(serial)
+		status, err := s.lookupCachedStatus(ctx, serial, ca)
 		if err != nil {
-			log.Printf("Error getting certificate status from cache: %v", err)
+			// Con el circuit breaker abierto se consulta directo la base sin registrar cada fallo
+			if !errors.Is(err, cache.ErrCircuitOpen) {
+				requestid.Logf(ctx, "Error getting certificate status from cache: %v", err)
+			}
 		} else if status != nil {
 			s.redis.IncrementStats("stats:cache_hits")
 			return status, nil
@@ -310,20 +1658,111 @@ func (s *CRLService) CheckCertificateStatus(serial string) (*models.CertificateS
 		s.redis.IncrementStats("stats:cache_misses")
 	}
 
-	status, err := s.db.GetCertificateStatus(serial)
+	status, err := s.sharedLoadCertificateStatus(ctx, serial, ca)
+	tracing.RecordError(span, err)
+	return status, err
+}
+
+// RefreshCertificateStatus consulta el estado en la base sin leer el cache y sobrescribe la
+// entrada de Redis con el resultado, para descartar una entrada desactualizada
+func (s *CRLService) RefreshCertificateStatus(ctx context.Context, serial, ca string) (*models.CertificateStatus, error) {
+	ctx, span := tracing.Start(ctx, "CRLService.RefreshCertificateStatus")
+	defer span.End()
+
+	serial = s.normalizeSerial(serial)
+	span.SetAttributes(attribute.String("certificate.serial", serial))
+
+	status, err := s.sharedLoadCertificateStatus(ctx, serial, ca)
+	tracing.RecordError(span, err)
+	return status, err
+}
+
+// sharedLoadCertificateStatus consulta la base y actualiza el cache. Las consultas concurrentes
+// del mismo serial y CA comparten una sola ida a la base de datos; el contexto no se cancela
+// con la petición que inició la consulta para no hacer fallar a las demás.
+func (s *CRLService) sharedLoadCertificateStatus(ctx context.Context, serial, ca string) (*models.CertificateStatus, error) {
+	key := serial
+	if ca != "" {
+		key += "\x00" + ca
+	}
+	result, err, _ := s.lookups.Do(key, func() (interface{}, error) {
+		return s.loadCertificateStatus(context.WithoutCancel(ctx), serial, ca)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Copia para que quien llama pueda modificar el resultado sin afectar a los demás
+	status := *result.(*models.CertificateStatus)
+	return &status, nil
+}
+
+// CheckCertificateStatusAsOf indica si el certificado estaba revocado en la fecha asOf:
+// devuelve la revocación más reciente con fecha igual o anterior, de la CA indicada o, con
+// ca vacío, de cualquiera. Consulta siempre la base de datos, ya que el cache guarda
+// únicamente el estado actual.
+func (s *CRLService) CheckCertificateStatusAsOf(ctx context.Context, serial, ca string, asOf time.Time) (*models.CertificateStatus, error) {
+	ctx, span := tracing.Start(ctx, "CRLService.CheckCertificateStatusAsOf")
+	defer span.End()
+
+	serial = s.normalizeSerial(serial)
+	span.SetAttributes(attribute.String("certificate.serial", serial))
+
+	status, err := s.getStatusAsOfFromDB(ctx, serial, ca, asOf)
+	tracing.RecordError(span, err)
+	if errors.Is(err, ErrDatabaseUnavailable) {
+		return nil, err
+	}
 	if err != nil {
 		return nil, fmt.Errorf("error getting certificate status from database: %v", err)
 	}
+	status.AsOf = &asOf
 
-	if s.redis != nil && status != nil {
-		ttl := 24 * time.Hour
+	return status, nil
+}
+
+// lookupCachedStatus consulta el estado en Redis dentro de su propio span
+func (s *CRLService) lookupCachedStatus(ctx context.Context, serial, ca string) (*models.CertificateStatus, error) {
+	ctx, span := tracing.Start(ctx, "redis.GetCertificateStatus")
+	defer span.End()
+
+	var status *models.CertificateStatus
+	var err error
+	if ca == "" {
+		status, err = s.redis.GetCertificateStatus(ctx, serial)
+	} else {
+		status, err = s.redis.GetCertificateStatusForCA(ctx, serial, ca)
+	}
+	tracing.RecordError(span, err)
+	span.SetAttributes(attribute.Bool("cache.hit", status != nil))
+	return status, err
+}
+
+func (s *CRLService) loadCertificateStatus(ctx context.Context, serial, ca string) (*models.CertificateStatus, error) {
+	dbCtx, span := tracing.Start(ctx, "db.GetCertificateStatus")
+	status, err := s.getStatusFromDB(dbCtx, serial, ca)
+	tracing.RecordError(span, err)
+	span.End()
+	if errors.Is(err, ErrDatabaseUnavailable) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting certificate status from database: %v", err)
+	}
+
+	if s.redis != nil {
+		ttl := s.cfg.CacheTTLValid
 		if status.IsRevoked {
-			ttl = 7 * 24 * time.Hour
+			ttl = s.cfg.CacheTTLRevoked
 		}
 
-		err = s.redis.SetCertificateStatus(serial, status, ttl)
-		if err != nil {
-			log.Printf("Error caching certificate status: %v", err)
+		if ca == "" {
+			err = s.redis.SetCertificateStatus(ctx, serial, status, ttl)
+		} else {
+			err = s.redis.SetCertificateStatusForCA(ctx, serial, ca, status, ttl)
+		}
+		if err != nil && !errors.Is(err, cache.ErrCircuitOpen) {
+			requestid.Logf(ctx, "Error caching certificate status: %v", err)
 		}
 	}
 
@@ -0,0 +1,1766 @@
+package services
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"signerflow-crl/cache/redistest"
+	"signerflow-crl/config"
+	"signerflow-crl/database"
+	"signerflow-crl/models"
+)
+
+func TestProcessSingleCRLWithMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	service := newTestService(t, store)
+
+	ca := newTestCA(t, "Memory Test CA")
+	revokedAt := time.Now().Add(-time.Hour)
+	srv := newCRLServer(t, ca.crl(t, 1, []x509.RevocationListEntry{
+		revoked(1001, models.ReasonKeyCompromise, revokedAt),
+		revoked(1002, models.ReasonCertificateHold, revokedAt),
+	}))
+
+	if err := service.ProcessSingleCRL(ctx, srv.URL); err != nil {
+		t.Fatalf("ProcessSingleCRL: %v", err)
+	}
+
+	status, err := service.CheckCertificateStatus(ctx, "1001")
+	if err != nil {
+		t.Fatalf("CheckCertificateStatus: %v", err)
+	}
+	if !status.IsRevoked || status.Status != models.StatusRevoked {
+		t.Fatalf("serial 1001: got revoked=%v status=%q, want revoked", status.IsRevoked, status.Status)
+	}
+	if status.ReasonCode == nil || *status.ReasonCode != models.ReasonKeyCompromise {
+		t.Errorf("serial 1001: got reason code %v, want %d", status.ReasonCode, models.ReasonKeyCompromise)
+	}
+	if status.CertificateAuthority == nil || *status.CertificateAuthority != "Memory Test CA" {
+		t.Errorf("serial 1001: got CA %v, want Memory Test CA", status.CertificateAuthority)
+	}
+
+	status, err = service.CheckCertificateStatus(ctx, "0x03EA")
+	if err != nil {
+		t.Fatalf("CheckCertificateStatus: %v", err)
+	}
+	if status.Status != models.StatusOnHold {
+		t.Errorf("serial 1002: got status %q, want %q", status.Status, models.StatusOnHold)
+	}
+
+	status, err = service.CheckCertificateStatus(ctx, "1003")
+	if err != nil {
+		t.Fatalf("CheckCertificateStatus: %v", err)
+	}
+	if status.IsRevoked || status.Status != models.StatusGood {
+		t.Errorf("serial 1003: got revoked=%v status=%q, want good", status.IsRevoked, status.Status)
+	}
+
+	info, err := store.GetCRLInfo(ctx, srv.URL)
+	if err != nil {
+		t.Fatalf("GetCRLInfo: %v", err)
+	}
+	if info.Issuer != "Memory Test CA" || info.CertCount != 2 || info.CRLNumber != "1" {
+		t.Errorf("got CRL info issuer=%q count=%d number=%q", info.Issuer, info.CertCount, info.CRLNumber)
+	}
+
+	history, err := store.GetProcessingHistory(ctx, srv.URL, 10)
+	if err != nil {
+		t.Fatalf("GetProcessingHistory: %v", err)
+	}
+	if len(history) != 1 || history[0].Status != models.ProcessingStatusSuccess || history[0].CertCount != 2 {
+		t.Fatalf("got processing history %+v, want one successful run with 2 certificates", history)
+	}
+}
+
+func TestProcessSingleCRLRejectsUntrustedSignature(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	service := newTestService(t, store)
+
+	// Una CA registrada con el mismo nombre pero otra clave no valida la firma de la CRL
+	registered := newTestCA(t, "Signature Test CA")
+	if _, err := service.AddCACertificate(ctx, registered.cert.Raw); err != nil {
+		t.Fatalf("AddCACertificate: %v", err)
+	}
+	impostor := newTestCA(t, "Signature Test CA")
+	srv := newCRLServer(t, impostor.crl(t, 1, []x509.RevocationListEntry{
+		revoked(2001, models.ReasonKeyCompromise, time.Now()),
+	}))
+
+	if err := service.ProcessSingleCRL(ctx, srv.URL); err == nil {
+		t.Fatal("ProcessSingleCRL accepted a CRL signed by an unregistered key")
+	}
+
+	status, err := service.CheckCertificateStatus(ctx, "2001")
+	if err != nil {
+		t.Fatalf("CheckCertificateStatus: %v", err)
+	}
+	if status.IsRevoked {
+		t.Error("serial 2001 was imported from a CRL with an invalid signature")
+	}
+
+	// La CRL firmada por la CA registrada sí se importa
+	srv.body = registered.crl(t, 2, []x509.RevocationListEntry{
+		revoked(2001, models.ReasonKeyCompromise, time.Now()),
+	})
+	if err := service.ProcessSingleCRL(ctx, srv.URL); err != nil {
+		t.Fatalf("ProcessSingleCRL: %v", err)
+	}
+	status, err = service.CheckCertificateStatus(ctx, "2001")
+	if err != nil {
+		t.Fatalf("CheckCertificateStatus: %v", err)
+	}
+	if !status.IsRevoked {
+		t.Error("serial 2001 was not imported from a CRL signed by the registered CA")
+	}
+}
+
+func TestProcessSingleCRLKeepsEntriesWithMalformedReason(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	service := newTestService(t, store)
+
+	ca := newTestCA(t, "Malformed Test CA")
+	revokedAt := time.Now().Add(-time.Hour)
+	reason := func(code int) pkix.Extension {
+		value, _ := asn1.Marshal(asn1.Enumerated(code))
+		return pkix.Extension{Id: oidCRLReason, Value: value}
+	}
+	srv := newCRLServer(t, ca.rawCRL(t, []pkix.RevokedCertificate{
+		{SerialNumber: big.NewInt(5001), RevocationTime: revokedAt, Extensions: []pkix.Extension{reason(models.ReasonKeyCompromise)}},
+		// ENUMERATED truncado
+		{SerialNumber: big.NewInt(5002), RevocationTime: revokedAt, Extensions: []pkix.Extension{{Id: oidCRLReason, Value: []byte{0x0a, 0x05}}}},
+		{SerialNumber: big.NewInt(5003), RevocationTime: revokedAt, Extensions: []pkix.Extension{reason(models.ReasonSuperseded)}},
+	}))
+
+	if err := service.ProcessSingleCRL(ctx, srv.URL); err != nil {
+		t.Fatalf("ProcessSingleCRL: %v", err)
+	}
+
+	want := map[string]int{
+		"5001": models.ReasonKeyCompromise,
+		"5002": models.ReasonUnspecified,
+		"5003": models.ReasonSuperseded,
+	}
+	for serial, reason := range want {
+		status, err := service.CheckCertificateStatus(ctx, serial)
+		if err != nil {
+			t.Fatalf("CheckCertificateStatus(%s): %v", serial, err)
+		}
+		if !status.IsRevoked {
+			t.Errorf("serial %s is not revoked", serial)
+			continue
+		}
+		if status.ReasonCode == nil || *status.ReasonCode != reason {
+			t.Errorf("serial %s: got reason code %v, want %d", serial, status.ReasonCode, reason)
+		}
+	}
+
+	history, err := store.GetProcessingHistory(ctx, srv.URL, 1)
+	if err != nil {
+		t.Fatalf("GetProcessingHistory: %v", err)
+	}
+	if len(history) != 1 || history[0].SkippedEntries != 0 {
+		t.Errorf("got processing history %+v, want no skipped entries", history)
+	}
+}
+
+// crlWithRawEntries firma con la CA una CRL cuyas entradas son los DER dados tal cual, para
+// incluir entradas que no se pueden decodificar
+func (ca *testCA) crlWithRawEntries(t *testing.T, entries ...[]byte) []byte {
+	t.Helper()
+
+	encode := func(value any) []byte {
+		t.Helper()
+		der, err := asn1.Marshal(value)
+		if err != nil {
+			t.Fatalf("encoding CRL: %v", err)
+		}
+		return der
+	}
+	sequence := func(fields ...[]byte) []byte {
+		return encode(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSequence, IsCompound: true, Bytes: bytes.Join(fields, nil)})
+	}
+
+	algorithm := pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}}
+	tbs := sequence(
+		encode(1),
+		encode(algorithm),
+		ca.cert.RawSubject,
+		encode(time.Now().Add(-time.Minute).UTC()),
+		encode(time.Now().Add(time.Hour).UTC()),
+		sequence(entries...),
+	)
+	digest := sha256.Sum256(tbs)
+	signature, err := ca.key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		t.Fatalf("signing CRL: %v", err)
+	}
+	return sequence(tbs, encode(algorithm), encode(asn1.BitString{Bytes: signature, BitLength: len(signature) * 8}))
+}
+
+func TestProcessSingleCRLSkipsUndecodableEntries(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	service := newTestService(t, store, func(cfg *config.Config) {
+		cfg.CRLPerHostRate = 0
+	})
+
+	ca := newTestCA(t, "Undecodable Test CA")
+	if _, err := service.AddCACertificate(ctx, ca.cert.Raw); err != nil {
+		t.Fatalf("AddCACertificate: %v", err)
+	}
+	revokedAt := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	entry := func(serial int64) []byte {
+		der, err := asn1.Marshal(pkix.RevokedCertificate{SerialNumber: big.NewInt(serial), RevocationTime: revokedAt})
+		if err != nil {
+			t.Fatalf("encoding entry: %v", err)
+		}
+		return der
+	}
+	srv := newCRLServer(t, ca.crlWithRawEntries(t,
+		entry(5101),
+		// BOOLEAN en lugar del serial
+		[]byte{0x30, 0x06, 0x01, 0x01, 0xff, 0x17, 0x01, '0'},
+		entry(5103),
+		// Sin fecha de revocación
+		[]byte{0x30, 0x03, 0x02, 0x01, 0x05},
+		entry(5105),
+	))
+
+	result, err := service.DryRunCRL(ctx, srv.URL)
+	if err != nil {
+		t.Fatalf("DryRunCRL: %v", err)
+	}
+	if !result.SignatureVerified || !slices.Contains(result.Warnings, "2 malformed entries would be skipped") {
+		t.Errorf("got dry run %+v, want a verified signature and a warning about 2 malformed entries", result)
+	}
+
+	if err := service.ProcessSingleCRL(ctx, srv.URL); err != nil {
+		t.Fatalf("ProcessSingleCRL: %v", err)
+	}
+	for _, serial := range []string{"5101", "5103", "5105"} {
+		if status, err := service.CheckCertificateStatus(ctx, serial); err != nil || !status.IsRevoked {
+			t.Errorf("serial %s: got %+v, %v; want revoked", serial, status, err)
+		}
+	}
+
+	// Las entradas omitidas se informan en el resumen del procesamiento
+	history, err := store.GetProcessingHistory(ctx, srv.URL, 1)
+	if err != nil {
+		t.Fatalf("GetProcessingHistory: %v", err)
+	}
+	if len(history) != 1 {
+		t.Fatalf("got %d processing runs, want 1", len(history))
+	}
+	if history[0].Status != models.ProcessingStatusSuccess || history[0].SkippedEntries != 2 {
+		t.Errorf("got processing run %+v, want a successful run with 2 skipped entries", *history[0])
+	}
+}
+
+func TestProcessSingleCRLKeepsEntriesWithMalformedInvalidityDate(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	service := newTestService(t, store)
+
+	ca := newTestCA(t, "Invalidity Test CA")
+	revokedAt := time.Now().Add(-time.Hour)
+	compromisedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	dateValue, err := asn1.MarshalWithParams(compromisedAt, "generalized")
+	if err != nil {
+		t.Fatalf("encoding invalidity date: %v", err)
+	}
+
+	withDate := revoked(7001, models.ReasonKeyCompromise, revokedAt)
+	withDate.ExtraExtensions = []pkix.Extension{{Id: oidInvalidityDate, Value: dateValue}}
+	malformed := revoked(7002, models.ReasonKeyCompromise, revokedAt)
+	malformed.ExtraExtensions = []pkix.Extension{{Id: oidInvalidityDate, Value: []byte{0x18, 0x03, '2', '0'}}}
+	srv := newCRLServer(t, ca.crl(t, 1, []x509.RevocationListEntry{withDate, malformed}))
+
+	if err := service.ProcessSingleCRL(ctx, srv.URL); err != nil {
+		t.Fatalf("ProcessSingleCRL: %v", err)
+	}
+
+	status, err := service.CheckCertificateStatus(ctx, "7001")
+	if err != nil {
+		t.Fatalf("CheckCertificateStatus: %v", err)
+	}
+	if status.InvalidityDate == nil || !status.InvalidityDate.Equal(compromisedAt) {
+		t.Errorf("serial 7001: got invalidity date %v, want %v", status.InvalidityDate, compromisedAt)
+	}
+
+	status, err = service.CheckCertificateStatus(ctx, "7002")
+	if err != nil {
+		t.Fatalf("CheckCertificateStatus: %v", err)
+	}
+	if !status.IsRevoked || status.InvalidityDate != nil {
+		t.Errorf("serial 7002: got revoked=%v invalidity date %v, want revoked without invalidity date", status.IsRevoked, status.InvalidityDate)
+	}
+	if status.ReasonCode == nil || *status.ReasonCode != models.ReasonKeyCompromise {
+		t.Errorf("serial 7002: got reason code %v, want %d", status.ReasonCode, models.ReasonKeyCompromise)
+	}
+}
+
+func TestFetchCRLDecompressesResponses(t *testing.T) {
+	ctx := context.Background()
+	service := newTestService(t, database.NewMemoryStore())
+	ca := newTestCA(t, "Compression Test CA")
+	der := ca.crl(t, 1, []x509.RevocationListEntry{revoked(4001, models.ReasonKeyCompromise, time.Now())})
+
+	compress := func(encoding string) []byte {
+		var buf bytes.Buffer
+		var w io.WriteCloser
+		switch encoding {
+		case "gzip":
+			w = gzip.NewWriter(&buf)
+		case "deflate":
+			w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+		default:
+			return der
+		}
+		w.Write(der)
+		w.Close()
+		return buf.Bytes()
+	}
+
+	// "" simula un servidor que ignora Accept-Encoding y devuelve el DER sin comprimir
+	for _, encoding := range []string{"gzip", "deflate", ""} {
+		name := encoding
+		if name == "" {
+			name = "plain"
+		}
+		t.Run(name, func(t *testing.T) {
+			var acceptEncoding string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				acceptEncoding = r.Header.Get("Accept-Encoding")
+				if encoding != "" {
+					w.Header().Set("Content-Encoding", encoding)
+				}
+				w.Write(compress(encoding))
+			}))
+			defer srv.Close()
+
+			download, err := service.fetchCRL(ctx, srv.URL, &models.CRLValidators{})
+			if err != nil {
+				t.Fatalf("fetchCRL: %v", err)
+			}
+			if !strings.Contains(acceptEncoding, "gzip") || !strings.Contains(acceptEncoding, "deflate") {
+				t.Errorf("got Accept-Encoding %q, want gzip and deflate", acceptEncoding)
+			}
+			if !bytes.Equal(download.data, der) {
+				t.Errorf("got %d bytes, want the %d bytes of the DER CRL", len(download.data), len(der))
+			}
+		})
+	}
+}
+
+func TestProcessSingleCRLAcceptsPEMAndDER(t *testing.T) {
+	ctx := context.Background()
+	service := newTestService(t, database.NewMemoryStore())
+	ca := newTestCA(t, "Encoding Test CA")
+
+	der := ca.crl(t, 1, []x509.RevocationListEntry{revoked(4101, models.ReasonKeyCompromise, time.Now())})
+	pemCRL := pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: ca.crl(t, 1, []x509.RevocationListEntry{
+		revoked(4102, models.ReasonKeyCompromise, time.Now()),
+	})})
+
+	for serial, body := range map[string][]byte{"4101": der, "4102": pemCRL} {
+		srv := newCRLServer(t, body)
+		if err := service.ProcessSingleCRL(ctx, srv.URL); err != nil {
+			t.Fatalf("ProcessSingleCRL: %v", err)
+		}
+		status, err := service.CheckCertificateStatus(ctx, serial)
+		if err != nil {
+			t.Fatalf("CheckCertificateStatus: %v", err)
+		}
+		if !status.IsRevoked {
+			t.Errorf("serial %s was not imported", serial)
+		}
+	}
+
+	srv := newCRLServer(t, []byte("this is not a CRL"))
+	err := service.ProcessSingleCRL(ctx, srv.URL)
+	if err == nil || !strings.Contains(err.Error(), srv.URL) {
+		t.Fatalf("got error %v, want a parse error naming %s", err, srv.URL)
+	}
+}
+
+func TestProcessSingleCRLRejectsLowerCRLNumber(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	service := newTestService(t, store)
+	ca := newTestCA(t, "Number Test CA")
+
+	srv := newCRLServer(t, ca.crl(t, 5, []x509.RevocationListEntry{revoked(4201, models.ReasonKeyCompromise, time.Now())}))
+	if err := service.ProcessSingleCRL(ctx, srv.URL); err != nil {
+		t.Fatalf("ProcessSingleCRL: %v", err)
+	}
+
+	// Una CRL anterior del mismo emisor (reproducida o servida por un espejo desfasado)
+	srv.body = ca.crl(t, 3, []x509.RevocationListEntry{revoked(4202, models.ReasonKeyCompromise, time.Now())})
+	if err := service.ProcessSingleCRL(ctx, srv.URL); err != nil {
+		t.Fatalf("ProcessSingleCRL with older CRL: %v", err)
+	}
+
+	status, err := service.CheckCertificateStatus(ctx, "4202")
+	if err != nil {
+		t.Fatalf("CheckCertificateStatus: %v", err)
+	}
+	if status.IsRevoked {
+		t.Error("serial 4202 was imported from a CRL with a lower CRLNumber")
+	}
+	info, err := store.GetCRLInfo(ctx, srv.URL)
+	if err != nil {
+		t.Fatalf("GetCRLInfo: %v", err)
+	}
+	if info.CRLNumber != "5" {
+		t.Errorf("got stored CRLNumber %q, want 5", info.CRLNumber)
+	}
+	history, err := store.GetProcessingHistory(ctx, srv.URL, 1)
+	if err != nil {
+		t.Fatalf("GetProcessingHistory: %v", err)
+	}
+	if len(history) != 1 || history[0].Status != models.ProcessingStatusSkipped {
+		t.Errorf("got processing history %+v, want the older CRL skipped", history)
+	}
+}
+
+func TestProcessSingleCRLReconcilesShrinkingCRL(t *testing.T) {
+	ctx := context.Background()
+	revokedAt := time.Now().Add(-time.Hour)
+	entries := []x509.RevocationListEntry{
+		revoked(4301, models.ReasonKeyCompromise, revokedAt),
+		revoked(4302, models.ReasonKeyCompromise, revokedAt),
+		revoked(4303, models.ReasonKeyCompromise, revokedAt),
+	}
+
+	tests := []struct {
+		name        string
+		reconcile   bool
+		otherCRL    bool
+		wantRemoved bool
+	}{
+		{"reconciliation enabled", true, false, true},
+		{"reconciliation disabled", false, false, false},
+		// Con otra CRL del mismo emisor las entradas ausentes pueden estar en ella
+		{"issuer with another CRL", true, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := newTestService(t, database.NewMemoryStore(), func(cfg *config.Config) {
+				cfg.ReconcileCRLs = tt.reconcile
+			})
+			ca := newTestCA(t, "Reconcile Test CA")
+
+			if tt.otherCRL {
+				other := newCRLServer(t, ca.crl(t, 1, nil))
+				if err := service.ProcessSingleCRL(ctx, other.URL); err != nil {
+					t.Fatalf("ProcessSingleCRL: %v", err)
+				}
+			}
+
+			srv := newCRLServer(t, ca.crl(t, 1, entries))
+			if err := service.ProcessSingleCRL(ctx, srv.URL); err != nil {
+				t.Fatalf("ProcessSingleCRL: %v", err)
+			}
+			srv.body = ca.crl(t, 2, entries[:1])
+			if err := service.ProcessSingleCRL(ctx, srv.URL); err != nil {
+				t.Fatalf("ProcessSingleCRL with shrunk CRL: %v", err)
+			}
+
+			for serial, listed := range map[string]bool{"4301": true, "4302": false, "4303": false} {
+				status, err := service.CheckCertificateStatus(ctx, serial)
+				if err != nil {
+					t.Fatalf("CheckCertificateStatus: %v", err)
+				}
+				if want := listed || !tt.wantRemoved; status.IsRevoked != want {
+					t.Errorf("serial %s: got revoked=%v, want %v", serial, status.IsRevoked, want)
+				}
+			}
+		})
+	}
+}
+
+func TestProcessSingleCRLConditionalDownload(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	service := newTestService(t, store)
+	ca := newTestCA(t, "Conditional Test CA")
+
+	body := ca.crl(t, 1, []x509.RevocationListEntry{revoked(4401, models.ReasonKeyCompromise, time.Now())})
+	etag := `"v1"`
+	var ifNoneMatch []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ifNoneMatch = append(ifNoneMatch, r.Header.Get("If-None-Match"))
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	for i := 0; i < 2; i++ {
+		if err := service.ProcessSingleCRL(ctx, srv.URL); err != nil {
+			t.Fatalf("ProcessSingleCRL: %v", err)
+		}
+	}
+	history, err := store.GetProcessingHistory(ctx, srv.URL, 1)
+	if err != nil {
+		t.Fatalf("GetProcessingHistory: %v", err)
+	}
+	if len(history) != 1 || history[0].Status != models.ProcessingStatusNotModified || history[0].HTTPStatus != http.StatusNotModified {
+		t.Fatalf("got processing history %+v, want a not_modified run", history)
+	}
+
+	// La CRL cambia: el servidor ignora el ETag anterior y se importa el nuevo contenido
+	body = ca.crl(t, 2, []x509.RevocationListEntry{
+		revoked(4401, models.ReasonKeyCompromise, time.Now()),
+		revoked(4402, models.ReasonKeyCompromise, time.Now()),
+	})
+	etag = `"v2"`
+	if err := service.ProcessSingleCRL(ctx, srv.URL); err != nil {
+		t.Fatalf("ProcessSingleCRL: %v", err)
+	}
+	status, err := service.CheckCertificateStatus(ctx, "4402")
+	if err != nil {
+		t.Fatalf("CheckCertificateStatus: %v", err)
+	}
+	if !status.IsRevoked {
+		t.Error("serial 4402 from the changed CRL was not imported")
+	}
+
+	want := []string{"", `"v1"`, `"v1"`}
+	if len(ifNoneMatch) != len(want) {
+		t.Fatalf("got If-None-Match headers %q, want %q", ifNoneMatch, want)
+	}
+	for i := range want {
+		if ifNoneMatch[i] != want[i] {
+			t.Errorf("request %d: got If-None-Match %q, want %q", i+1, ifNoneMatch[i], want[i])
+		}
+	}
+	validators, err := store.GetCRLValidators(ctx, srv.URL)
+	if err != nil {
+		t.Fatalf("GetCRLValidators: %v", err)
+	}
+	if validators.ETag != `"v2"` {
+		t.Errorf("got stored ETag %q, want \"v2\"", validators.ETag)
+	}
+}
+
+func TestStaleCRLIsReported(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	service := newTestService(t, store)
+
+	stale := newTestCA(t, "Stale Test CA")
+	fresh := newTestCA(t, "Fresh Test CA")
+	staleSrv := newCRLServer(t, stale.crlUntil(t, 1, time.Now().Add(-time.Hour), []x509.RevocationListEntry{
+		revoked(4501, models.ReasonKeyCompromise, time.Now().Add(-2*time.Hour)),
+	}))
+	freshSrv := newCRLServer(t, fresh.crl(t, 1, nil))
+	for _, url := range []string{staleSrv.URL, freshSrv.URL} {
+		if err := service.ProcessSingleCRL(ctx, url); err != nil {
+			t.Fatalf("ProcessSingleCRL: %v", err)
+		}
+	}
+
+	// Sin REJECT_EXPIRED_CRLS la CRL vencida se importa igual
+	status, err := service.CheckCertificateStatus(ctx, "4501")
+	if err != nil {
+		t.Fatalf("CheckCertificateStatus: %v", err)
+	}
+	if !status.IsRevoked {
+		t.Error("serial 4501 from the stale CRL was not imported")
+	}
+
+	check := func(service *CRLService) {
+		t.Helper()
+		if !service.IsCAStale("Stale Test CA") {
+			t.Error("Stale Test CA is not reported as stale")
+		}
+		if service.IsCAStale("Fresh Test CA") || service.IsCAStale("Unknown CA") {
+			t.Error("a fresh or unknown CA is reported as stale")
+		}
+	}
+	check(service)
+
+	// Tras un reinicio el estado se recupera de crl_info
+	restarted := newTestService(t, store)
+	restarted.WarnStaleCRLs(ctx)
+	check(restarted)
+
+	stats, err := store.GetStatsByCA(ctx, "Stale Test CA")
+	if err != nil {
+		t.Fatalf("GetStatsByCA: %v", err)
+	}
+	if len(stats) != 1 || !stats[0].IsStale {
+		t.Errorf("got stats %+v, want Stale Test CA marked stale", stats)
+	}
+}
+
+func TestCheckCertificateDataStoresFingerprintForRegisteredCA(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	service := newTestService(t, store)
+
+	ca := newTestCA(t, "Fingerprint Test CA")
+	if _, err := service.AddCACertificate(ctx, ca.cert.Raw); err != nil {
+		t.Fatalf("AddCACertificate: %v", err)
+	}
+	srv := newCRLServer(t, ca.crl(t, 1, []x509.RevocationListEntry{
+		revoked(8001, models.ReasonKeyCompromise, time.Now()),
+		revoked(8002, models.ReasonKeyCompromise, time.Now()),
+	}))
+	if err := service.ProcessSingleCRL(ctx, srv.URL); err != nil {
+		t.Fatalf("ProcessSingleCRL: %v", err)
+	}
+
+	// Una CA con el mismo nombre pero otra clave puede emitir un certificado con un serial revocado
+	impostor := newTestCA(t, "Fingerprint Test CA")
+	tests := []struct {
+		name      string
+		cert      *x509.Certificate
+		wantSaved bool
+	}{
+		{"registered CA", ca.issue(t, 8001), true},
+		{"unregistered key", impostor.issue(t, 8002), false},
+	}
+	for _, tt := range tests {
+		status, err := service.CheckCertificateData(ctx, tt.cert.Raw)
+		if err != nil {
+			t.Fatalf("%s: CheckCertificateData: %v", tt.name, err)
+		}
+		if !status.IsRevoked || status.Fingerprint == nil {
+			t.Fatalf("%s: got revoked=%v fingerprint %v, want revoked with its fingerprint", tt.name, status.IsRevoked, status.Fingerprint)
+		}
+
+		saved, err := service.CheckCertificateByFingerprint(ctx, *status.Fingerprint)
+		if err != nil {
+			t.Fatalf("%s: CheckCertificateByFingerprint: %v", tt.name, err)
+		}
+		if (saved != nil) != tt.wantSaved {
+			t.Errorf("%s: got saved status %+v, want saved=%v", tt.name, saved, tt.wantSaved)
+		}
+	}
+}
+
+func TestProcessSingleCRLStoresReasonCode(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	service := newTestService(t, store)
+	ca := newTestCA(t, "Reason Test CA")
+
+	srv := newCRLServer(t, ca.crl(t, 1, []x509.RevocationListEntry{
+		revoked(4701, models.ReasonKeyCompromise, time.Now()),
+		revoked(4702, models.ReasonCACompromise, time.Now()),
+		// Sin extensión CRLReason
+		revoked(4703, models.ReasonUnspecified, time.Now()),
+	}))
+	if err := service.ProcessSingleCRL(ctx, srv.URL); err != nil {
+		t.Fatalf("ProcessSingleCRL: %v", err)
+	}
+
+	certs, _, err := store.ListRevokedCertificates(ctx, models.CertificateFilter{CertificateAuthority: "Reason Test CA"}, 10, 0)
+	if err != nil {
+		t.Fatalf("ListRevokedCertificates: %v", err)
+	}
+	want := map[string]int{
+		"4701": models.ReasonKeyCompromise,
+		"4702": models.ReasonCACompromise,
+		"4703": models.ReasonUnspecified,
+	}
+	if len(certs) != len(want) {
+		t.Fatalf("got %d certificates, want %d", len(certs), len(want))
+	}
+	for _, cert := range certs {
+		reason := want[cert.Serial]
+		if cert.Reason != reason || cert.ReasonText != models.RevocationReasons[reason] {
+			t.Errorf("serial %s: got reason %d %q, want %d %q", cert.Serial, cert.Reason, cert.ReasonText, reason, models.RevocationReasons[reason])
+		}
+	}
+}
+
+func TestCleanupDeletesUntrackedCertificates(t *testing.T) {
+	ctx := context.Background()
+	store, err := database.NewSQLiteDB(filepath.Join(t.TempDir(), "crl.db"), database.PoolConfig{MaxOpenConns: 1, MaxIdleConns: 1}, database.TableConfig{})
+	if err != nil {
+		t.Fatalf("NewSQLiteDB: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	service := newTestService(t, store, func(cfg *config.Config) {
+		cfg.CleanupRetention = 24 * time.Hour
+	})
+
+	// La CRL de la CA se procesó por última vez fuera de la retención y ya no está configurada
+	old := time.Now().Add(-72 * time.Hour).UTC()
+	if _, err := store.BatchInsertRevokedCertificates(ctx, []*models.RevokedCertificate{
+		{Serial: "4901", RevocationDate: old, CertificateAuthority: "Dropped CA"},
+	}); err != nil {
+		t.Fatalf("BatchInsertRevokedCertificates: %v", err)
+	}
+	if err := store.InsertCRLInfo(ctx, &models.CRLInfo{URL: "http://crl.example/dropped.crl", Issuer: "Dropped CA", NextUpdate: old, LastProcessed: old}); err != nil {
+		t.Fatalf("InsertCRLInfo: %v", err)
+	}
+	if _, err := store.Exec("UPDATE revoked_certificates SET updated_at = ?", old.Format("2006-01-02 15:04:05.999999999-07:00")); err != nil {
+		t.Fatalf("backdating certificates: %v", err)
+	}
+
+	dir := t.TempDir()
+	isRevoked := func() bool {
+		t.Helper()
+		status, err := service.CheckCertificateStatus(ctx, "4901")
+		if err != nil {
+			t.Fatalf("CheckCertificateStatus: %v", err)
+		}
+		return status.IsRevoked
+	}
+
+	// Si el archivo de URLs no se puede leer no se sabe qué CRLs siguen configuradas
+	broken := filepath.Join(dir, "broken.json")
+	if err := os.WriteFile(broken, []byte("{"), 0o644); err != nil {
+		t.Fatalf("writing URLs file: %v", err)
+	}
+	if result := service.Cleanup(ctx, broken); result.Certificates != 0 || !isRevoked() {
+		t.Fatalf("Cleanup with an unreadable URLs file deleted %d certificates", result.Certificates)
+	}
+
+	// Mientras la CRL siga configurada sus certificados se conservan
+	tracked := filepath.Join(dir, "tracked.txt")
+	if err := os.WriteFile(tracked, []byte("http://crl.example/dropped.crl\n"), 0o644); err != nil {
+		t.Fatalf("writing URLs file: %v", err)
+	}
+	if result := service.Cleanup(ctx, tracked); result.Certificates != 0 || !isRevoked() {
+		t.Fatalf("Cleanup deleted %d certificates of a configured CRL", result.Certificates)
+	}
+
+	untracked := filepath.Join(dir, "untracked.txt")
+	if err := os.WriteFile(untracked, []byte("# sin CRLs\n"), 0o644); err != nil {
+		t.Fatalf("writing URLs file: %v", err)
+	}
+	if result := service.Cleanup(ctx, untracked); result.Certificates != 1 || isRevoked() {
+		t.Errorf("Cleanup deleted %d certificates, want the 1 of the dropped CA", result.Certificates)
+	}
+}
+
+func TestConfiguredCacheTTLsAreApplied(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	redis, redisServer := newTestRedis(t)
+	service := newCachedTestService(t, store, redis, func(cfg *config.Config) {
+		cfg.CacheTTLValid = 90 * time.Second
+		cfg.CacheTTLRevoked = 45 * time.Minute
+	})
+
+	ca := newTestCA(t, "TTL Test CA")
+	srv := newCRLServer(t, ca.crl(t, 1, []x509.RevocationListEntry{
+		revoked(7001, models.ReasonKeyCompromise, time.Now().Add(-time.Hour)),
+	}))
+	if err := service.ProcessSingleCRL(ctx, srv.URL); err != nil {
+		t.Fatalf("ProcessSingleCRL: %v", err)
+	}
+
+	for _, serial := range []string{"7001", "7002"} {
+		if _, err := service.CheckCertificateStatus(ctx, serial); err != nil {
+			t.Fatalf("CheckCertificateStatus(%s): %v", serial, err)
+		}
+	}
+	assertTTL(t, redisServer, "cert:7001", 45*time.Minute)
+	assertTTL(t, redisServer, "cert:7002", 90*time.Second)
+}
+
+// assertTTL comprueba que la clave tenga un TTL cercano al esperado
+func assertTTL(t *testing.T, srv *redistest.Server, key string, want time.Duration) {
+	t.Helper()
+
+	got := srv.TTL(key)
+	if got > want || got < want-5*time.Second {
+		t.Errorf("TTL of %s = %v, want %v", key, got, want)
+	}
+}
+
+// countingStore cuenta las consultas de estado y las retiene hasta que se cierra release
+type countingStore struct {
+	database.CertStore
+	lookups atomic.Int32
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (s *countingStore) GetCertificateStatus(ctx context.Context, serial string) (*models.CertificateStatus, error) {
+	if s.lookups.Add(1) == 1 {
+		close(s.entered)
+	}
+	<-s.release
+	return s.CertStore.GetCertificateStatus(ctx, serial)
+}
+
+func TestConcurrentLookupsShareOneDatabaseQuery(t *testing.T) {
+	ctx := context.Background()
+	store := &countingStore{
+		CertStore: database.NewMemoryStore(),
+		entered:   make(chan struct{}),
+		release:   make(chan struct{}),
+	}
+	service := newTestService(t, store)
+
+	const callers = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status, err := service.CheckCertificateStatus(ctx, "4242")
+			if err == nil && status.IsRevoked {
+				err = errors.New("unknown serial reported as revoked")
+			}
+			errs <- err
+		}()
+	}
+
+	// Con la primera consulta retenida, las demás se suman a ella
+	<-store.entered
+	time.Sleep(100 * time.Millisecond)
+	close(store.release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("CheckCertificateStatus: %v", err)
+		}
+	}
+	if got := store.lookups.Load(); got != 1 {
+		t.Errorf("database queried %d times, want 1", got)
+	}
+}
+
+func TestFetchCRLRejectsOversizedResponses(t *testing.T) {
+	ctx := context.Background()
+	service := newTestService(t, database.NewMemoryStore(), func(cfg *config.Config) {
+		cfg.MaxCRLSizeMB = 1
+	})
+	oversized := bytes.Repeat([]byte{0x30}, 1<<20+1)
+
+	var gzipped bytes.Buffer
+	gz := gzip.NewWriter(&gzipped)
+	gz.Write(oversized)
+	gz.Close()
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{"declared Content-Length", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Length", strconv.Itoa(len(oversized)))
+			w.Write(oversized)
+		}},
+		{"chunked body", func(w http.ResponseWriter, r *http.Request) {
+			// Sin Content-Length el límite se detecta al leer el cuerpo
+			w.(http.Flusher).Flush()
+			w.Write(oversized)
+		}},
+		{"gzip bomb", func(w http.ResponseWriter, r *http.Request) {
+			// Comprimido ocupa unos pocos KB; el límite se aplica al tamaño descomprimido
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(gzipped.Bytes())
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(tt.handler)
+			defer srv.Close()
+
+			_, err := service.fetchCRL(ctx, srv.URL, &models.CRLValidators{})
+			if err == nil || !strings.Contains(err.Error(), "exceeds the limit of 1 MB") {
+				t.Errorf("fetchCRL: got error %v, want size limit exceeded", err)
+			}
+		})
+	}
+}
+
+func TestProcessSingleCRLAbortsDownloadWhenCancelled(t *testing.T) {
+	service := newTestService(t, database.NewMemoryStore())
+
+	requested := make(chan struct{})
+	aborted := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(requested)
+		// El servidor no responde hasta que el cliente abandona la petición
+		select {
+		case <-r.Context().Done():
+			close(aborted)
+		case <-time.After(10 * time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- service.ProcessSingleCRL(ctx, srv.URL) }()
+
+	<-requested
+	cancel()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("ProcessSingleCRL succeeded after cancellation")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("ProcessSingleCRL did not return after cancellation")
+	}
+	select {
+	case <-aborted:
+	case <-time.After(2 * time.Second):
+		t.Error("server did not see the request being aborted")
+	}
+}
+
+func TestLoadCRLURLs(t *testing.T) {
+	service := newTestService(t, database.NewMemoryStore())
+	want := []string{"http://crl.example/one.crl", "ldap://ldap.example/cn=CA?certificateRevocationList"}
+
+	tests := []struct {
+		file    string
+		content string
+	}{
+		{"urls.json", `["http://crl.example/one.crl", "not a url", "ldap://ldap.example/cn=CA?certificateRevocationList"]`},
+		{"urls.txt", `# CRLs de producción
+http://crl.example/one.crl
+
+   # comentario indentado
+ftp://crl.example/unsupported.crl
+  ldap://ldap.example/cn=CA?certificateRevocationList  
+`},
+		{"urls.yaml", `# CRLs de producción
+- http://crl.example/one.crl
+
+- /relative/path.crl
+- ldap://ldap.example/cn=CA?certificateRevocationList
+`},
+		{"urls.yml", "- http://crl.example/one.crl\n- ldap://ldap.example/cn=CA?certificateRevocationList\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			urls, err := service.LoadCRLURLs(path)
+			if err != nil {
+				t.Fatalf("LoadCRLURLs: %v", err)
+			}
+			if strings.Join(urls, "\n") != strings.Join(want, "\n") {
+				t.Errorf("got URLs %q, want %q", urls, want)
+			}
+		})
+	}
+
+	t.Run("malformed JSON", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "urls.json")
+		if err := os.WriteFile(path, []byte(`{"urls": []}`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := service.LoadCRLURLs(path); err == nil {
+			t.Error("LoadCRLURLs accepted a JSON object")
+		}
+	})
+}
+
+func TestCheckCRLURLSources(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "missing.json")
+	empty := filepath.Join(dir, "empty.json")
+	valid := filepath.Join(dir, "valid.json")
+	for path, content := range map[string]string{
+		empty: `["not a url"]`,
+		valid: `["http://crl.example/one.crl", "http://crl.example/two.crl"]`,
+	} {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		service := newTestService(t, database.NewMemoryStore())
+		if _, _, err := service.CheckCRLURLSources(ctx, missing); err == nil {
+			t.Error("startup check accepted a missing URLs file")
+		}
+	})
+
+	t.Run("no valid URLs", func(t *testing.T) {
+		service := newTestService(t, database.NewMemoryStore())
+		_, _, err := service.CheckCRLURLSources(ctx, empty)
+		if err == nil || !strings.Contains(err.Error(), "no valid URLs") {
+			t.Errorf("got error %v, want the file rejected for having no valid URLs", err)
+		}
+	})
+
+	t.Run("valid file", func(t *testing.T) {
+		service := newTestService(t, database.NewMemoryStore())
+		count, source, err := service.CheckCRLURLSources(ctx, valid)
+		if err != nil || count != 2 || source != valid {
+			t.Errorf("CheckCRLURLSources = %d, %q, %v; want 2 URLs from %s", count, source, err, valid)
+		}
+	})
+
+	// Con fuentes en la base de datos el archivo no es necesario
+	t.Run("database sources", func(t *testing.T) {
+		store := database.NewMemoryStore()
+		for _, source := range []*models.CRLSource{
+			{URL: "http://crl.example/enabled.crl", Enabled: true},
+			{URL: "http://crl.example/disabled.crl", Enabled: false},
+		} {
+			if err := store.AddCRLSource(ctx, source); err != nil {
+				t.Fatalf("AddCRLSource: %v", err)
+			}
+		}
+		service := newTestService(t, store)
+		count, source, err := service.CheckCRLURLSources(ctx, missing)
+		if err != nil || count != 1 || source != "crl_sources" {
+			t.Errorf("CheckCRLURLSources = %d, %q, %v; want 1 enabled URL from crl_sources", count, source, err)
+		}
+	})
+
+	// CRL_URLS_JSON tiene prioridad sobre las fuentes de la base y el archivo
+	t.Run("inline JSON", func(t *testing.T) {
+		store := database.NewMemoryStore()
+		if err := store.AddCRLSource(ctx, &models.CRLSource{URL: "http://crl.example/enabled.crl", Enabled: true}); err != nil {
+			t.Fatalf("AddCRLSource: %v", err)
+		}
+		service := newTestService(t, store, func(cfg *config.Config) {
+			cfg.CRLURLsJSON = `["http://crl.example/inline.crl"]`
+		})
+		count, source, err := service.CheckCRLURLSources(ctx, valid)
+		if err != nil || count != 1 || source != "CRL_URLS_JSON" {
+			t.Errorf("CheckCRLURLSources = %d, %q, %v; want 1 URL from CRL_URLS_JSON", count, source, err)
+		}
+	})
+
+	t.Run("inline JSON without URLs", func(t *testing.T) {
+		service := newTestService(t, database.NewMemoryStore(), func(cfg *config.Config) {
+			cfg.CRLURLsJSON = `["not a url"]`
+		})
+		if _, _, err := service.CheckCRLURLSources(ctx, valid); err == nil {
+			t.Error("startup check accepted CRL_URLS_JSON without valid URLs")
+		}
+	})
+}
+
+func TestInlineCRLURLsOverrideFile(t *testing.T) {
+	ctx := context.Background()
+	revokedAt := time.Now().Add(-time.Hour)
+	fromFile := newCRLServer(t, newTestCA(t, "File CA").crl(t, 1, []x509.RevocationListEntry{revoked(601, models.ReasonKeyCompromise, revokedAt)}))
+	fromSource := newCRLServer(t, newTestCA(t, "Source CA").crl(t, 1, []x509.RevocationListEntry{revoked(602, models.ReasonKeyCompromise, revokedAt)}))
+	inline := newCRLServer(t, newTestCA(t, "Inline CA").crl(t, 1, []x509.RevocationListEntry{revoked(603, models.ReasonKeyCompromise, revokedAt)}))
+
+	urlsFile := filepath.Join(t.TempDir(), "crl_urls.json")
+	if err := os.WriteFile(urlsFile, []byte(`["`+fromFile.URL+`"]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store := database.NewMemoryStore()
+	if err := store.AddCRLSource(ctx, &models.CRLSource{URL: fromSource.URL, Enabled: true}); err != nil {
+		t.Fatalf("AddCRLSource: %v", err)
+	}
+	service := newTestService(t, store, func(cfg *config.Config) {
+		cfg.CRLURLsJSON = `["` + inline.URL + `", "not a url"]`
+	})
+
+	if err := service.ProcessAllCRLs(ctx, urlsFile); err != nil {
+		t.Fatalf("ProcessAllCRLs: %v", err)
+	}
+	if fromFile.hits.Load() != 0 || fromSource.hits.Load() != 0 || inline.hits.Load() != 1 {
+		t.Errorf("got %d file, %d crl_sources and %d inline downloads, want only the inline CRL",
+			fromFile.hits.Load(), fromSource.hits.Load(), inline.hits.Load())
+	}
+	if status, err := service.CheckCertificateStatus(ctx, "603"); err != nil || !status.IsRevoked {
+		t.Errorf("serial 603: got %+v, %v; want revoked", status, err)
+	}
+
+	// Una lista inline inválida impide crear el servicio
+	cfg := config.LoadConfig()
+	cfg.CRLURLsJSON = `{"urls": []}`
+	if _, err := NewCRLService(database.NewMemoryStore(), nil, cfg); err == nil || !strings.Contains(err.Error(), "CRL_URLS_JSON") {
+		t.Errorf("NewCRLService: got %v, want an error about CRL_URLS_JSON", err)
+	}
+}
+
+func TestDeltaCRLRemoveFromCRLUnrevokes(t *testing.T) {
+	ctx := context.Background()
+	redis, redisServer := newTestRedis(t)
+	service := newCachedTestService(t, database.NewMemoryStore(), redis)
+
+	ca := newTestCA(t, "Delta Test CA")
+	revokedAt := time.Now().Add(-time.Hour)
+	base := newCRLServer(t, ca.crl(t, 1, []x509.RevocationListEntry{
+		revoked(501, models.ReasonCertificateHold, revokedAt),
+		revoked(502, models.ReasonKeyCompromise, revokedAt),
+	}))
+	if err := service.ProcessSingleCRL(ctx, base.URL); err != nil {
+		t.Fatalf("ProcessSingleCRL(base): %v", err)
+	}
+
+	// La consulta deja en cache el estado retenido de 501
+	status, err := service.CheckCertificateStatus(ctx, "501")
+	if err != nil || status.Status != models.StatusOnHold {
+		t.Fatalf("serial 501 before the delta: got %+v, %v; want on hold", status, err)
+	}
+
+	baseNumber, err := asn1.Marshal(big.NewInt(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	delta := newCRLServer(t, ca.crl(t, 2, []x509.RevocationListEntry{
+		revoked(501, models.ReasonRemoveFromCRL, time.Now()),
+		revoked(503, models.ReasonSuperseded, time.Now()),
+	}, pkix.Extension{Id: oidDeltaCRLIndicator, Critical: true, Value: baseNumber}))
+	if err := service.ProcessSingleCRL(ctx, delta.URL); err != nil {
+		t.Fatalf("ProcessSingleCRL(delta): %v", err)
+	}
+
+	if _, cached := redisServer.Get("cert:501"); cached {
+		t.Error("cache entry of the removed serial was not invalidated")
+	}
+	for serial, want := range map[string]string{"501": models.StatusGood, "502": models.StatusRevoked, "503": models.StatusRevoked} {
+		status, err := service.CheckCertificateStatus(ctx, serial)
+		if err != nil {
+			t.Fatalf("CheckCertificateStatus(%s): %v", serial, err)
+		}
+		if status.Status != want {
+			t.Errorf("serial %s: got status %q, want %q", serial, status.Status, want)
+		}
+	}
+}
+
+func TestBaseCRLKeepsRemoveFromCRLEntries(t *testing.T) {
+	ctx := context.Background()
+	service := newTestService(t, database.NewMemoryStore())
+
+	ca := newTestCA(t, "Base RemoveFromCRL CA")
+	srv := newCRLServer(t, ca.crl(t, 1, []x509.RevocationListEntry{
+		revoked(601, models.ReasonRemoveFromCRL, time.Now().Add(-time.Hour)),
+	}))
+	if err := service.ProcessSingleCRL(ctx, srv.URL); err != nil {
+		t.Fatalf("ProcessSingleCRL: %v", err)
+	}
+
+	// En una CRL base el motivo no es válido y la entrada se guarda como revocada
+	status, err := service.CheckCertificateStatus(ctx, "601")
+	if err != nil {
+		t.Fatalf("CheckCertificateStatus: %v", err)
+	}
+	if !status.IsRevoked {
+		t.Error("serial 601 listed in a base CRL is not revoked")
+	}
+}
+
+func TestTracingSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	ctx := context.Background()
+	redis, _ := newTestRedis(t)
+	service := newCachedTestService(t, database.NewMemoryStore(), redis, func(cfg *config.Config) {
+		cfg.CRLPerHostRate = 0
+	})
+
+	ca := newTestCA(t, "Tracing Test CA")
+	srv := newCRLServer(t, ca.crl(t, 1, []x509.RevocationListEntry{
+		revoked(9001, models.ReasonKeyCompromise, time.Now().Add(-time.Hour)),
+	}))
+	if err := service.ProcessSingleCRL(ctx, srv.URL); err != nil {
+		t.Fatalf("ProcessSingleCRL: %v", err)
+	}
+	if _, err := service.CheckCertificateStatus(ctx, "9002"); err != nil {
+		t.Fatalf("CheckCertificateStatus: %v", err)
+	}
+
+	// El refresco asíncrono continúa la traza de la petición que lo inició
+	urlsFile := filepath.Join(t.TempDir(), "crl_urls.json")
+	if err := os.WriteFile(urlsFile, []byte(`["`+srv.URL+`"]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	requestCtx, requestSpan := otel.Tracer("test").Start(ctx, "POST /api/v1/admin/refresh")
+	if err := service.StartRefresh(requestCtx, urlsFile); err != nil {
+		t.Fatalf("StartRefresh: %v", err)
+	}
+	requestSpan.End()
+	deadline := time.Now().Add(2 * time.Second)
+	for !hasEndedSpan(recorder, "CRLService.ProcessAllCRLs") {
+		if time.Now().After(deadline) {
+			t.Fatal("refresh did not finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Cada span se identifica por su nombre y el de su padre
+	parents := make(map[string]string)
+	names := make(map[trace.SpanID]string)
+	spans := recorder.Ended()
+	for _, span := range spans {
+		names[span.SpanContext().SpanID()] = span.Name()
+	}
+	for _, span := range spans {
+		parents[span.Name()] = names[span.Parent().SpanID()]
+	}
+
+	want := map[string]string{
+		"CRLService.ProcessAllCRLs":         "POST /api/v1/admin/refresh",
+		"crl.download":                      "CRLService.ProcessSingleCRL",
+		"crl.parse":                         "CRLService.ProcessSingleCRL",
+		"crl.batch_insert":                  "CRLService.ProcessSingleCRL",
+		"CRLService.CheckCertificateStatus": "",
+		"redis.GetCertificateStatus":        "CRLService.CheckCertificateStatus",
+		"db.GetCertificateStatus":           "CRLService.CheckCertificateStatus",
+	}
+	for name, wantParent := range want {
+		parent, ok := parents[name]
+		if !ok {
+			t.Errorf("span %s was not recorded", name)
+			continue
+		}
+		if parent != wantParent {
+			t.Errorf("span %s: got parent %q, want %q", name, parent, wantParent)
+		}
+	}
+}
+
+// hasEndedSpan indica si el recorder registró un span terminado con ese nombre
+func hasEndedSpan(recorder *tracetest.SpanRecorder, name string) bool {
+	for _, span := range recorder.Ended() {
+		if span.Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestProcessingHistoryRecordsFailuresAndIsPruned(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	service := newTestService(t, store, func(cfg *config.Config) {
+		cfg.CRLPerHostRate = 0
+		cfg.ProcessingLogRetention = 24 * time.Hour
+	})
+
+	ca := newTestCA(t, "History Test CA")
+	srv := newCRLServer(t, ca.crl(t, 1, []x509.RevocationListEntry{revoked(1, models.ReasonKeyCompromise, time.Now())}))
+	if err := service.ProcessSingleCRL(ctx, srv.URL); err != nil {
+		t.Fatalf("ProcessSingleCRL: %v", err)
+	}
+	srv.status = http.StatusServiceUnavailable
+	if err := service.ProcessSingleCRL(ctx, srv.URL); err == nil {
+		t.Fatal("ProcessSingleCRL succeeded with the server failing")
+	}
+
+	history, err := store.GetProcessingHistory(ctx, srv.URL, 10)
+	if err != nil {
+		t.Fatalf("GetProcessingHistory: %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("got %d runs, want 2", len(history))
+	}
+	if failed := history[0]; failed.Status != models.ProcessingStatusFailed || failed.HTTPStatus != http.StatusServiceUnavailable || !strings.Contains(failed.Error, "503") {
+		t.Errorf("got failed run %+v, want status failed with HTTP 503 and its error", failed)
+	}
+	if ok := history[1]; ok.Status != models.ProcessingStatusSuccess || ok.HTTPStatus != http.StatusOK || ok.BytesDownloaded == 0 || ok.CertCount != 1 {
+		t.Errorf("got successful run %+v, want status success with HTTP 200, the downloaded bytes and 1 certificate", ok)
+	}
+
+	// Cleanup elimina las ejecuciones anteriores a PROCESSING_LOG_RETENTION
+	old := &models.ProcessingLogEntry{URL: srv.URL, StartedAt: time.Now().Add(-48 * time.Hour), Status: models.ProcessingStatusSuccess}
+	if err := store.InsertProcessingLog(ctx, old); err != nil {
+		t.Fatalf("InsertProcessingLog: %v", err)
+	}
+	if result := service.Cleanup(ctx, filepath.Join(t.TempDir(), "missing.json")); result.ProcessingLogs != 1 {
+		t.Errorf("Cleanup pruned %d runs, want 1", result.ProcessingLogs)
+	}
+	if history, _ := store.GetProcessingHistory(ctx, srv.URL, 10); len(history) != 2 {
+		t.Errorf("got %d runs after cleanup, want 2", len(history))
+	}
+}
+
+func TestProcessSingleCRLStoresMaximalSerial(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	service := newTestService(t, store)
+
+	// 20 octetos, el máximo de RFC 5280, con el bit alto libre para que el INTEGER sea positivo
+	octets := append([]byte{0x7f}, bytes.Repeat([]byte{0xff}, 19)...)
+	serial := new(big.Int).SetBytes(octets)
+	ca := newTestCA(t, "Long Serial CA")
+	srv := newCRLServer(t, ca.crl(t, 1, []x509.RevocationListEntry{{
+		SerialNumber:   serial,
+		RevocationTime: time.Now().Add(-time.Hour).UTC().Truncate(time.Second),
+		ReasonCode:     models.ReasonKeyCompromise,
+	}}))
+
+	if err := service.ProcessSingleCRL(ctx, srv.URL); err != nil {
+		t.Fatalf("ProcessSingleCRL: %v", err)
+	}
+
+	for _, query := range []string{serial.String(), "0x" + serial.Text(16), "7F" + strings.Repeat(":FF", 19)} {
+		status, err := service.CheckCertificateStatus(ctx, query)
+		if err != nil {
+			t.Fatalf("CheckCertificateStatus(%s): %v", query, err)
+		}
+		if !status.IsRevoked || status.Serial != serial.String() {
+			t.Errorf("lookup %s: got revoked=%v serial %q, want revoked with decimal serial %s", query, status.IsRevoked, status.Serial, serial)
+		}
+	}
+}
+
+func TestProcessSingleCRLInvalidatesEveryImportedEntry(t *testing.T) {
+	ctx := context.Background()
+	redis, redisServer := newTestRedis(t)
+	service := newCachedTestService(t, database.NewMemoryStore(), redis, func(cfg *config.Config) {
+		cfg.ImportBatchSize = 3
+		cfg.RedisChunkSize = 2
+	})
+
+	// Antes de la importación los seriales quedan en cache como no revocados
+	for serial := 7101; serial <= 7107; serial++ {
+		if status, err := service.CheckCertificateStatus(ctx, strconv.Itoa(serial)); err != nil || status.IsRevoked {
+			t.Fatalf("serial %d before the import: got %+v, %v; want not revoked", serial, status, err)
+		}
+	}
+
+	ca := newTestCA(t, "Batch Cache CA")
+	var entries []x509.RevocationListEntry
+	for serial := int64(7101); serial <= 7107; serial++ {
+		entries = append(entries, revoked(serial, models.ReasonKeyCompromise, time.Now().Add(-time.Hour)))
+	}
+	srv := newCRLServer(t, ca.crl(t, 1, entries))
+
+	if err := service.ProcessSingleCRL(ctx, srv.URL); err != nil {
+		t.Fatalf("ProcessSingleCRL: %v", err)
+	}
+
+	// Lotes de 3 y pipelines de 2 no deben dejar entradas sin invalidar en los bordes
+	for serial := 7101; serial <= 7107; serial++ {
+		if _, cached := redisServer.Get("cert:" + strconv.Itoa(serial)); cached {
+			t.Errorf("imported serial %d is still cached", serial)
+		}
+		if status, err := service.CheckCertificateStatus(ctx, strconv.Itoa(serial)); err != nil || !status.IsRevoked {
+			t.Errorf("serial %d after the import: got %+v, %v; want revoked", serial, status, err)
+		}
+	}
+}
+
+// failingInsertStore simula una base que rechaza todas las inserciones
+type failingInsertStore struct {
+	database.CertStore
+}
+
+func (failingInsertStore) BatchInsertRevokedCertificates(ctx context.Context, certs []*models.RevokedCertificate) ([]*models.RevokedCertificate, error) {
+	return nil, errors.New("insert failed")
+}
+
+func TestProcessSingleCRLKeepsCacheWhenInsertFails(t *testing.T) {
+	ctx := context.Background()
+	redis, _ := newTestRedis(t)
+	service := newCachedTestService(t, failingInsertStore{CertStore: database.NewMemoryStore()}, redis, func(cfg *config.Config) {
+		cfg.ImportBatchSize = 2
+	})
+
+	if status, err := service.CheckCertificateStatus(ctx, "7151"); err != nil || status.IsRevoked {
+		t.Fatalf("before the import: got %+v, %v; want not revoked", status, err)
+	}
+	ca := newTestCA(t, "Failed Insert CA")
+	srv := newCRLServer(t, ca.crl(t, 1, revokedRange(7150, 3)))
+	if err := service.ProcessSingleCRL(ctx, srv.URL); err != nil {
+		t.Fatalf("ProcessSingleCRL: %v", err)
+	}
+
+	// Los lotes que no se guardaron no tocan el cache, que sigue coincidiendo con la base
+	cached, err := redis.GetCertificateStatus(ctx, "7151")
+	if err != nil || cached == nil || cached.IsRevoked {
+		t.Errorf("got cached status %+v, %v; want the earlier not revoked entry", cached, err)
+	}
+}
+
+// batchRecorder registra el tamaño de cada lote guardado con BatchInsertRevokedCertificates
+type batchRecorder struct {
+	database.CertStore
+	mu      sync.Mutex
+	batches []int
+}
+
+func (s *batchRecorder) BatchInsertRevokedCertificates(ctx context.Context, certs []*models.RevokedCertificate) ([]*models.RevokedCertificate, error) {
+	s.mu.Lock()
+	s.batches = append(s.batches, len(certs))
+	s.mu.Unlock()
+	return s.CertStore.BatchInsertRevokedCertificates(ctx, certs)
+}
+
+func TestImportBatchSizeControlsFlushes(t *testing.T) {
+	ctx := context.Background()
+	der := newTestCA(t, "Batch Size CA").crl(t, 1, revokedRange(7401, 7))
+
+	tests := []struct {
+		batchSize int
+		want      []int
+	}{
+		{3, []int{3, 3, 1}},
+		{7, []int{7}},
+		{100, []int{7}},
+	}
+	for _, tt := range tests {
+		store := &batchRecorder{CertStore: database.NewMemoryStore()}
+		service := newTestService(t, store, func(cfg *config.Config) {
+			cfg.ImportBatchSize = tt.batchSize
+		})
+		srv := newCRLServer(t, der)
+
+		if err := service.ProcessSingleCRL(ctx, srv.URL); err != nil {
+			t.Fatalf("batch size %d: ProcessSingleCRL: %v", tt.batchSize, err)
+		}
+		if !slices.Equal(store.batches, tt.want) {
+			t.Errorf("batch size %d: got batches %v, want %v", tt.batchSize, store.batches, tt.want)
+		}
+	}
+}
+
+func TestProcessAllCRLsSkipsDisabledSources(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	service := newTestService(t, store, func(cfg *config.Config) {
+		cfg.CRLPerHostRate = 0
+	})
+
+	active, paused := newTestCA(t, "Active CA"), newTestCA(t, "Paused CA")
+	revokedAt := time.Now().Add(-time.Hour)
+	activeSrv := newCRLServer(t, active.crl(t, 1, []x509.RevocationListEntry{revoked(7201, models.ReasonKeyCompromise, revokedAt)}))
+	pausedSrv := newCRLServer(t, paused.crl(t, 1, []x509.RevocationListEntry{revoked(7301, models.ReasonKeyCompromise, revokedAt)}))
+
+	sources := make(map[string]*models.CRLSource)
+	for _, url := range []string{activeSrv.URL, pausedSrv.URL} {
+		source := &models.CRLSource{URL: url, Enabled: true}
+		if err := store.AddCRLSource(ctx, source); err != nil {
+			t.Fatalf("AddCRLSource: %v", err)
+		}
+		sources[url] = source
+	}
+	if err := service.ProcessAllCRLs(ctx, ""); err != nil {
+		t.Fatalf("ProcessAllCRLs: %v", err)
+	}
+	if hits := pausedSrv.hits.Load(); hits != 1 {
+		t.Fatalf("enabled source was downloaded %d times, want 1", hits)
+	}
+
+	if _, err := store.SetCRLSourceEnabled(ctx, sources[pausedSrv.URL].ID, false); err != nil {
+		t.Fatalf("SetCRLSourceEnabled: %v", err)
+	}
+	activeSrv.body = active.crl(t, 2, []x509.RevocationListEntry{
+		revoked(7201, models.ReasonKeyCompromise, revokedAt),
+		revoked(7202, models.ReasonKeyCompromise, revokedAt),
+	})
+	pausedSrv.body = paused.crl(t, 2, []x509.RevocationListEntry{
+		revoked(7301, models.ReasonKeyCompromise, revokedAt),
+		revoked(7302, models.ReasonKeyCompromise, revokedAt),
+	})
+	pausedSrv.hits.Store(0)
+	if err := service.ProcessAllCRLs(ctx, ""); err != nil {
+		t.Fatalf("ProcessAllCRLs: %v", err)
+	}
+
+	if hits := pausedSrv.hits.Load(); hits != 0 {
+		t.Errorf("disabled source was downloaded %d times", hits)
+	}
+	for serial, want := range map[string]bool{"7202": true, "7301": true, "7302": false} {
+		status, err := service.CheckCertificateStatus(ctx, serial)
+		if err != nil {
+			t.Fatalf("CheckCertificateStatus(%s): %v", serial, err)
+		}
+		if status.IsRevoked != want {
+			t.Errorf("serial %s: got revoked=%v, want %v", serial, status.IsRevoked, want)
+		}
+	}
+	// El historial de la fuente deshabilitada se conserva
+	if info, err := store.GetCRLInfo(ctx, pausedSrv.URL); err != nil || info.CRLNumber != "1" {
+		t.Errorf("got CRL info %+v (err %v) for the disabled source, want its last processed CRL kept", info, err)
+	}
+}
+
+// cancellingStore cancela el procesamiento durante la inserción del lote número cancelAt,
+// como un apagado que llega a mitad de la importación. Igual que una base real, rechaza las
+// inserciones con el contexto cancelado.
+type cancellingStore struct {
+	database.CertStore
+	cancel   context.CancelFunc
+	cancelAt int
+	calls    int
+}
+
+func (s *cancellingStore) BatchInsertRevokedCertificates(ctx context.Context, certs []*models.RevokedCertificate) ([]*models.RevokedCertificate, error) {
+	s.calls++
+	if s.calls == s.cancelAt {
+		s.cancel()
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.CertStore.BatchInsertRevokedCertificates(ctx, certs)
+}
+
+func TestCancelledImportFlushesBufferedBatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := &cancellingStore{CertStore: database.NewMemoryStore(), cancel: cancel, cancelAt: 2}
+	service := newTestService(t, store, func(cfg *config.Config) {
+		cfg.ImportBatchSize = 3
+	})
+	srv := newCRLServer(t, newTestCA(t, "Shutdown CA").crl(t, 1, revokedRange(7501, 8)))
+
+	if err := service.ProcessSingleCRL(ctx, srv.URL); err == nil || !strings.Contains(err.Error(), "cancelled") {
+		t.Fatalf("got error %v, want the import reported as cancelled", err)
+	}
+
+	// El primer lote ya estaba guardado y el segundo, interrumpido, se guarda al cancelar;
+	// las entradas que no se llegaron a leer quedan para el próximo procesamiento
+	background := context.Background()
+	for serial := 7501; serial <= 7508; serial++ {
+		status, err := store.GetCertificateStatus(background, strconv.Itoa(serial))
+		if err != nil {
+			t.Fatalf("GetCertificateStatus(%d): %v", serial, err)
+		}
+		if want := serial <= 7506; status.IsRevoked != want {
+			t.Errorf("serial %d: got persisted=%v, want %v", serial, status.IsRevoked, want)
+		}
+	}
+
+	flushed, err := service.StopProcessing(background)
+	if err != nil || flushed != 3 {
+		t.Errorf("StopProcessing = %d, %v; want 3 certificates flushed on cancellation", flushed, err)
+	}
+}
+
+func TestRejectExpiredCRLs(t *testing.T) {
+	ctx := context.Background()
+	ca := newTestCA(t, "Expiring CA")
+	entries := []x509.RevocationListEntry{revoked(7601, models.ReasonKeyCompromise, time.Now().Add(-72*time.Hour))}
+
+	tests := []struct {
+		name       string
+		reject     bool
+		nextUpdate time.Time
+		wantImport bool
+	}{
+		{"fresh", true, time.Now().Add(time.Hour), true},
+		{"expired within the grace period", true, time.Now().Add(-30 * time.Minute), true},
+		{"expired beyond the grace period", true, time.Now().Add(-48 * time.Hour), false},
+		{"expired with the flag off", false, time.Now().Add(-48 * time.Hour), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := database.NewMemoryStore()
+			service := newTestService(t, store, func(cfg *config.Config) {
+				cfg.RejectExpiredCRLs = tt.reject
+				cfg.ExpiredCRLGrace = time.Hour
+			})
+			srv := newCRLServer(t, ca.crlUntil(t, 1, tt.nextUpdate, entries))
+
+			if err := service.ProcessSingleCRL(ctx, srv.URL); err != nil {
+				t.Fatalf("ProcessSingleCRL: %v", err)
+			}
+
+			status, err := service.CheckCertificateStatus(ctx, "7601")
+			if err != nil {
+				t.Fatalf("CheckCertificateStatus: %v", err)
+			}
+			if status.IsRevoked != tt.wantImport {
+				t.Errorf("got imported=%v, want %v", status.IsRevoked, tt.wantImport)
+			}
+
+			// El intento queda registrado aunque no se importen las entradas
+			info, err := store.GetCRLInfo(ctx, srv.URL)
+			if err != nil {
+				t.Fatalf("GetCRLInfo: %v", err)
+			}
+			if !info.NextUpdate.Equal(tt.nextUpdate.UTC().Truncate(time.Second)) {
+				t.Errorf("got recorded NextUpdate %v, want %v", info.NextUpdate, tt.nextUpdate)
+			}
+			history, err := store.GetProcessingHistory(ctx, srv.URL, 1)
+			if err != nil || len(history) != 1 {
+				t.Fatalf("got processing history %+v (err %v), want one run", history, err)
+			}
+			wantStatus := models.ProcessingStatusSuccess
+			if !tt.wantImport {
+				wantStatus = models.ProcessingStatusSkipped
+			}
+			if history[0].Status != wantStatus {
+				t.Errorf("got run status %q, want %q", history[0].Status, wantStatus)
+			}
+		})
+	}
+}
+
+func TestProcessSingleCRLKeepsSameSerialFromTwoCAs(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	service := newTestService(t, store, func(cfg *config.Config) {
+		cfg.CRLPerHostRate = 0
+		cfg.ReconcileCRLs = true
+	})
+
+	revokedAt := time.Now().Add(-time.Hour)
+	first, second := newTestCA(t, "First Overlap CA"), newTestCA(t, "Second Overlap CA")
+	firstSrv := newCRLServer(t, first.crl(t, 1, []x509.RevocationListEntry{revoked(7171, models.ReasonKeyCompromise, revokedAt)}))
+	secondSrv := newCRLServer(t, second.crl(t, 1, []x509.RevocationListEntry{revoked(7171, models.ReasonSuperseded, revokedAt)}))
+	for _, url := range []string{firstSrv.URL, secondSrv.URL} {
+		if err := service.ProcessSingleCRL(ctx, url); err != nil {
+			t.Fatalf("ProcessSingleCRL: %v", err)
+		}
+	}
+
+	for ca, reason := range map[string]int{"First Overlap CA": models.ReasonKeyCompromise, "Second Overlap CA": models.ReasonSuperseded} {
+		status, err := store.GetCertificateStatusByCA(ctx, "7171", ca)
+		if err != nil || !status.IsRevoked || *status.ReasonCode != reason {
+			t.Errorf("%s: got %+v, %v; want revoked with reason %d", ca, status, err, reason)
+		}
+	}
+
+	// La CRL nueva de la primera CA ya no lista el serial: solo se borra su revocación
+	firstSrv.body = first.crl(t, 2, nil)
+	if err := service.ProcessSingleCRL(ctx, firstSrv.URL); err != nil {
+		t.Fatalf("ProcessSingleCRL: %v", err)
+	}
+	if status, err := store.GetCertificateStatusByCA(ctx, "7171", "First Overlap CA"); err != nil || status.IsRevoked {
+		t.Errorf("First Overlap CA: got %+v, %v; want the revocation removed", status, err)
+	}
+	status, err := service.CheckCertificateStatus(ctx, "7171")
+	if err != nil || !status.IsRevoked || status.CertificateAuthority == nil || *status.CertificateAuthority != "Second Overlap CA" {
+		t.Errorf("got %+v, %v; want the serial still revoked by Second Overlap CA", status, err)
+	}
+}
+
+func TestCachedStatusMatchesDatabaseForSerialFromTwoCAs(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	redis, _ := newTestRedis(t)
+	service := newCachedTestService(t, store, redis, func(cfg *config.Config) {
+		cfg.CRLPerHostRate = 0
+	})
+
+	newer, older := newTestCA(t, "Newer Revocation CA"), newTestCA(t, "Older Revocation CA")
+	newerSrv := newCRLServer(t, newer.crl(t, 1, []x509.RevocationListEntry{revoked(7181, models.ReasonKeyCompromise, time.Now().Add(-time.Hour))}))
+	olderSrv := newCRLServer(t, older.crl(t, 1, []x509.RevocationListEntry{revoked(7181, models.ReasonSuperseded, time.Now().Add(-2*time.Hour))}))
+
+	// La CRL procesada al final es la de la revocación más antigua: el cache no debe quedar
+	// con ella, ya que la base responde la más reciente
+	for _, url := range []string{newerSrv.URL, olderSrv.URL} {
+		if err := service.ProcessSingleCRL(ctx, url); err != nil {
+			t.Fatalf("ProcessSingleCRL: %v", err)
+		}
+		if _, err := service.CheckCertificateStatus(ctx, "7181"); err != nil {
+			t.Fatalf("CheckCertificateStatus: %v", err)
+		}
+	}
+
+	want, err := store.GetCertificateStatus(ctx, "7181")
+	if err != nil || want.CertificateAuthority == nil || *want.CertificateAuthority != "Newer Revocation CA" {
+		t.Fatalf("database status: got %+v, %v; want the newer revocation", want, err)
+	}
+	cached, err := redis.GetCertificateStatus(ctx, "7181")
+	if err != nil || cached == nil || cached.CertificateAuthority == nil || *cached.CertificateAuthority != *want.CertificateAuthority {
+		t.Errorf("got cached status %+v, %v; want the database answer from %s", cached, err, *want.CertificateAuthority)
+	}
+	for ca, reason := range map[string]int{"Newer Revocation CA": models.ReasonKeyCompromise, "Older Revocation CA": models.ReasonSuperseded} {
+		status, err := service.CheckCertificateStatusForCA(ctx, "7181", ca)
+		if err != nil || !status.IsRevoked || *status.ReasonCode != reason {
+			t.Errorf("%s: got %+v, %v; want revoked with reason %d", ca, status, err, reason)
+		}
+	}
+}
+
+func TestProcessSingleCRLRejectsHTMLResponse(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	service := newTestService(t, store, func(cfg *config.Config) {
+		cfg.CRLPerHostRate = 0
+	})
+
+	// Un servidor mal configurado devuelve su página de error con 200 OK
+	srv := newCRLServer(t, []byte("<html><body>Service temporarily unavailable</body></html>"))
+	srv.contentType = "text/html; charset=utf-8"
+	err := service.ProcessSingleCRL(ctx, srv.URL)
+	if err == nil || !strings.Contains(err.Error(), "HTML page") {
+		t.Fatalf("got error %v, want the HTML page rejected before parsing", err)
+	}
+	history, histErr := store.GetProcessingHistory(ctx, srv.URL, 1)
+	if histErr != nil || len(history) != 1 || history[0].Status != models.ProcessingStatusFailed || !strings.Contains(history[0].Error, "text/html") {
+		t.Errorf("got processing history %+v (err %v), want a failed run naming the Content-Type", history, histErr)
+	}
+
+	// Una CRL servida con un tipo genérico se acepta con la lista vacía
+	srv.body = newTestCA(t, "Content Type CA").crl(t, 1, []x509.RevocationListEntry{revoked(7101, models.ReasonKeyCompromise, time.Now())})
+	srv.contentType = "application/octet-stream"
+	if err := service.ProcessSingleCRL(ctx, srv.URL); err != nil {
+		t.Fatalf("ProcessSingleCRL with application/octet-stream: %v", err)
+	}
+}
+
+func TestCheckCRLContentType(t *testing.T) {
+	tests := []struct {
+		name        string
+		allowed     []string
+		contentType string
+		wantErr     bool
+	}{
+		{"permissive DER", nil, "application/pkix-crl", false},
+		{"permissive generic", nil, "application/octet-stream", false},
+		{"permissive text", nil, "text/plain", false},
+		{"permissive missing", nil, "", false},
+		{"permissive HTML", nil, "text/html; charset=utf-8", true},
+		{"permissive XHTML", nil, "application/xhtml+xml", true},
+		{"allowlist match", []string{"application/pkix-crl", "application/x-pkcs7-crl"}, "application/x-pkcs7-crl", false},
+		{"allowlist case and parameters", []string{"application/pkix-crl"}, "Application/PKIX-CRL; charset=binary", false},
+		{"allowlist missing", []string{"application/pkix-crl"}, "", false},
+		{"allowlist mismatch", []string{"application/pkix-crl"}, "application/octet-stream", true},
+		{"allowlist HTML", []string{"application/pkix-crl"}, "text/html", true},
+	}
+	for _, tt := range tests {
+		service := newTestService(t, database.NewMemoryStore(), func(cfg *config.Config) {
+			cfg.CRLContentTypes = tt.allowed
+		})
+		if err := service.checkCRLContentType(tt.contentType); (err != nil) != tt.wantErr {
+			t.Errorf("%s: got error %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+)
+
+// OIDs de los algoritmos de firma de CRLs (RFC 3279, RFC 4055, RFC 5758 y RFC 8410)
+var (
+	oidSignatureMD5WithRSA      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 4}
+	oidSignatureSHA1WithRSA     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 5}
+	oidSignatureSHA256WithRSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}
+	oidSignatureSHA384WithRSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 12}
+	oidSignatureSHA512WithRSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 13}
+	oidSignatureRSAPSS          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 10}
+	oidSignatureDSAWithSHA1     = asn1.ObjectIdentifier{1, 2, 840, 10040, 4, 3}
+	oidSignatureDSAWithSHA256   = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 3, 2}
+	oidSignatureECDSAWithSHA1   = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 1}
+	oidSignatureECDSAWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	oidSignatureECDSAWithSHA384 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 3}
+	oidSignatureECDSAWithSHA512 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 4}
+	oidSignatureEd25519         = asn1.ObjectIdentifier{1, 3, 101, 112}
+
+	oidSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+)
+
+var signatureAlgorithms = []struct {
+	oid  asn1.ObjectIdentifier
+	algo x509.SignatureAlgorithm
+}{
+	{oidSignatureMD5WithRSA, x509.MD5WithRSA},
+	{oidSignatureSHA1WithRSA, x509.SHA1WithRSA},
+	{oidSignatureSHA256WithRSA, x509.SHA256WithRSA},
+	{oidSignatureSHA384WithRSA, x509.SHA384WithRSA},
+	{oidSignatureSHA512WithRSA, x509.SHA512WithRSA},
+	{oidSignatureDSAWithSHA1, x509.DSAWithSHA1},
+	{oidSignatureDSAWithSHA256, x509.DSAWithSHA256},
+	{oidSignatureECDSAWithSHA1, x509.ECDSAWithSHA1},
+	{oidSignatureECDSAWithSHA256, x509.ECDSAWithSHA256},
+	{oidSignatureECDSAWithSHA384, x509.ECDSAWithSHA384},
+	{oidSignatureECDSAWithSHA512, x509.ECDSAWithSHA512},
+	{oidSignatureEd25519, x509.PureEd25519},
+}
+
+// rsaPSSParameters son los parámetros de RSASSA-PSS (RFC 4055); solo interesa el hash
+type rsaPSSParameters struct {
+	Hash pkix.AlgorithmIdentifier `asn1:"explicit,tag:0"`
+}
+
+// signatureAlgorithmFromAI traduce el AlgorithmIdentifier de la firma al algoritmo de
+// crypto/x509, igual que hace x509.ParseRevocationList. Devuelve UnknownSignatureAlgorithm
+// si no se reconoce, y CheckSignature lo rechaza.
+func signatureAlgorithmFromAI(ai pkix.AlgorithmIdentifier) x509.SignatureAlgorithm {
+	if ai.Algorithm.Equal(oidSignatureRSAPSS) {
+		var params rsaPSSParameters
+		if _, err := asn1.Unmarshal(ai.Parameters.FullBytes, &params); err != nil {
+			return x509.UnknownSignatureAlgorithm
+		}
+		switch {
+		case params.Hash.Algorithm.Equal(oidSHA256):
+			return x509.SHA256WithRSAPSS
+		case params.Hash.Algorithm.Equal(oidSHA384):
+			return x509.SHA384WithRSAPSS
+		case params.Hash.Algorithm.Equal(oidSHA512):
+			return x509.SHA512WithRSAPSS
+		}
+		return x509.UnknownSignatureAlgorithm
+	}
+
+	for _, entry := range signatureAlgorithms {
+		if ai.Algorithm.Equal(entry.oid) {
+			return entry.algo
+		}
+	}
+	return x509.UnknownSignatureAlgorithm
+}
+
+// signatureOnlyRevocationList arma un x509.RevocationList con solo lo necesario para
+// CheckSignatureFrom: el TBSCertList en DER, la firma y su algoritmo. Así se verifica con la
+// API actual de crypto/x509 sin decodificar las entradas, que se recorren después de a una.
+func signatureOnlyRevocationList(crl *pkix.CertificateList) *x509.RevocationList {
+	return &x509.RevocationList{
+		RawTBSRevocationList: crl.TBSCertList.Raw,
+		Signature:            crl.SignatureValue.RightAlign(),
+		SignatureAlgorithm:   signatureAlgorithmFromAI(crl.SignatureAlgorithm),
+	}
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+	"time"
+
+	"signerflow-crl/config"
+	"signerflow-crl/database"
+	"signerflow-crl/models"
+)
+
+// signedCRL firma con la CA una CRL que revoca serial, usando el algoritmo de firma dado
+func signedCRL(t *testing.T, ca *testCA, algo x509.SignatureAlgorithm, serial int64) []byte {
+	t.Helper()
+
+	der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		SignatureAlgorithm:        algo,
+		Number:                    big.NewInt(1),
+		ThisUpdate:                time.Now().Add(-time.Minute),
+		NextUpdate:                time.Now().Add(time.Hour),
+		RevokedCertificateEntries: []x509.RevocationListEntry{revoked(serial, models.ReasonKeyCompromise, time.Now().Add(-time.Hour))},
+	}, ca.cert, ca.key)
+	if err != nil {
+		t.Fatalf("creating CRL: %v", err)
+	}
+	return der
+}
+
+func TestProcessSingleCRLVerifiesSignatureAlgorithms(t *testing.T) {
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating Ed25519 key: %v", err)
+	}
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating P-384 key: %v", err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating RSA key: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		key    crypto.Signer
+		algo   x509.SignatureAlgorithm
+		serial int64
+	}{
+		{"Ed25519", ed25519Key, x509.PureEd25519, 8901},
+		{"ECDSA P-384", p384Key, x509.ECDSAWithSHA384, 8902},
+		{"RSA PKCS#1", rsaKey, x509.SHA256WithRSA, 8903},
+		{"RSA-PSS", rsaKey, x509.SHA384WithRSAPSS, 8904},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			service := newTestService(t, database.NewMemoryStore(), func(cfg *config.Config) {
+				cfg.CRLPerHostRate = 0
+			})
+			ca := newTestCAWithKey(t, tt.name+" CA", tt.key)
+			if _, err := service.AddCACertificate(ctx, ca.cert.Raw); err != nil {
+				t.Fatalf("AddCACertificate: %v", err)
+			}
+			srv := newCRLServer(t, signedCRL(t, ca, tt.algo, tt.serial))
+
+			result, err := service.DryRunCRL(ctx, srv.URL)
+			if err != nil {
+				t.Fatalf("DryRunCRL: %v", err)
+			}
+			if !result.SignatureVerified {
+				t.Error("signature was not verified against the registered CA")
+			}
+
+			if err := service.ProcessSingleCRL(ctx, srv.URL); err != nil {
+				t.Fatalf("ProcessSingleCRL: %v", err)
+			}
+			serial := big.NewInt(tt.serial).String()
+			if status, err := service.CheckCertificateStatus(ctx, serial); err != nil || !status.IsRevoked {
+				t.Errorf("serial %s: got %+v, %v; want revoked", serial, status, err)
+			}
+		})
+	}
+}
+
+func TestProcessSingleCRLRejectsForgedEd25519Signature(t *testing.T) {
+	ctx := context.Background()
+	service := newTestService(t, database.NewMemoryStore())
+
+	newKey := func() ed25519.PrivateKey {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatalf("generating Ed25519 key: %v", err)
+		}
+		return key
+	}
+	registered := newTestCAWithKey(t, "Ed25519 Signature CA", newKey())
+	if _, err := service.AddCACertificate(ctx, registered.cert.Raw); err != nil {
+		t.Fatalf("AddCACertificate: %v", err)
+	}
+	impostor := newTestCAWithKey(t, "Ed25519 Signature CA", newKey())
+	srv := newCRLServer(t, signedCRL(t, impostor, x509.PureEd25519, 8905))
+
+	if err := service.ProcessSingleCRL(ctx, srv.URL); err == nil {
+		t.Fatal("ProcessSingleCRL accepted an Ed25519 CRL signed by an unregistered key")
+	}
+	if status, err := service.CheckCertificateStatus(ctx, "8905"); err != nil || status.IsRevoked {
+		t.Errorf("serial 8905: got %+v, %v; want it not imported", status, err)
+	}
+}
+
+func TestSignatureAlgorithmFromAI(t *testing.T) {
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating Ed25519 key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating P-521 key: %v", err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating RSA key: %v", err)
+	}
+
+	// El algoritmo tiene que coincidir con el que obtiene x509.ParseRevocationList
+	for _, tt := range []struct {
+		key  crypto.Signer
+		algo x509.SignatureAlgorithm
+	}{
+		{ed25519Key, x509.PureEd25519},
+		{ecKey, x509.ECDSAWithSHA512},
+		{rsaKey, x509.SHA512WithRSA},
+		{rsaKey, x509.SHA256WithRSAPSS},
+		{rsaKey, x509.SHA512WithRSAPSS},
+	} {
+		der := signedCRL(t, newTestCAWithKey(t, "Algorithm CA", tt.key), tt.algo, 1)
+		var crl pkix.CertificateList
+		if _, err := asn1.Unmarshal(der, &crl); err != nil {
+			t.Fatalf("decoding CRL: %v", err)
+		}
+		if got := signatureAlgorithmFromAI(crl.SignatureAlgorithm); got != tt.algo {
+			t.Errorf("got %v, want %v", got, tt.algo)
+		}
+	}
+
+	unknown := []struct {
+		name string
+		ai   pkix.AlgorithmIdentifier
+	}{
+		{"unknown OID", pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 3, 4}}},
+		{"RSA-PSS without parameters", pkix.AlgorithmIdentifier{Algorithm: oidSignatureRSAPSS}},
+	}
+	for _, tt := range unknown {
+		if got := signatureAlgorithmFromAI(tt.ai); got != x509.UnknownSignatureAlgorithm {
+			t.Errorf("%s: got %v, want UnknownSignatureAlgorithm", tt.name, got)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+// decodedCRL es una CRL cuyas entradas se decodifican de a una al recorrerlas, para no
+// mantener en memoria millones de pkix.RevokedCertificate a la vez. CertificateList
+// tiene todos los campos salvo TBSCertList.RevokedCertificates, que queda vacío.
+type decodedCRL struct {
+	*pkix.CertificateList
+	// revoked es el contenido DER de la secuencia revokedCertificates, sin decodificar
+	revoked []byte
+}
+
+// certificateListEnvelope es el CertificateList externo con el TBSCertList sin decodificar
+type certificateListEnvelope struct {
+	TBSCertList        asn1.RawValue
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	SignatureValue     asn1.BitString
+}
+
+// parseDERCRL decodifica una CRL en DER igual que x509.ParseDERCRL pero sin decodificar
+// las entradas, que se recorren después con forEachEntry
+func parseDERCRL(der []byte) (*decodedCRL, error) {
+	var envelope certificateListEnvelope
+	rest, err := asn1.Unmarshal(der, &envelope)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, errors.New("trailing data after CRL")
+	}
+	if envelope.TBSCertList.Class != asn1.ClassUniversal || envelope.TBSCertList.Tag != asn1.TagSequence {
+		return nil, errors.New("invalid TBSCertList: expected SEQUENCE")
+	}
+
+	crl := &decodedCRL{
+		CertificateList: &pkix.CertificateList{
+			SignatureAlgorithm: envelope.SignatureAlgorithm,
+			SignatureValue:     envelope.SignatureValue,
+		},
+	}
+	tbs := &crl.TBSCertList
+	tbs.Raw = envelope.TBSCertList.FullBytes
+
+	// Los campos de TBSCertList se recorren en orden; version, nextUpdate,
+	// revokedCertificates y crlExtensions son opcionales (RFC 5280, 5.1)
+	fields := envelope.TBSCertList.Bytes
+	field, fields, err := nextCRLField(fields)
+	if err != nil {
+		return nil, err
+	}
+	if field.Class == asn1.ClassUniversal && field.Tag == asn1.TagInteger {
+		if _, err := asn1.Unmarshal(field.FullBytes, &tbs.Version); err != nil {
+			return nil, fmt.Errorf("invalid CRL version: %v", err)
+		}
+		if field, fields, err = nextCRLField(fields); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := asn1.Unmarshal(field.FullBytes, &tbs.Signature); err != nil {
+		return nil, fmt.Errorf("invalid CRL signature algorithm: %v", err)
+	}
+	if field, fields, err = nextCRLField(fields); err != nil {
+		return nil, err
+	}
+	if _, err := asn1.Unmarshal(field.FullBytes, &tbs.Issuer); err != nil {
+		return nil, fmt.Errorf("invalid CRL issuer: %v", err)
+	}
+	if field, fields, err = nextCRLField(fields); err != nil {
+		return nil, err
+	}
+	if _, err := asn1.Unmarshal(field.FullBytes, &tbs.ThisUpdate); err != nil {
+		return nil, fmt.Errorf("invalid CRL thisUpdate: %v", err)
+	}
+
+	for len(fields) > 0 {
+		if field, fields, err = nextCRLField(fields); err != nil {
+			return nil, err
+		}
+
+		switch {
+		case field.Class == asn1.ClassUniversal && (field.Tag == asn1.TagUTCTime || field.Tag == asn1.TagGeneralizedTime):
+			if _, err := asn1.Unmarshal(field.FullBytes, &tbs.NextUpdate); err != nil {
+				return nil, fmt.Errorf("invalid CRL nextUpdate: %v", err)
+			}
+		case field.Class == asn1.ClassUniversal && field.Tag == asn1.TagSequence:
+			crl.revoked = field.Bytes
+		case field.Class == asn1.ClassContextSpecific && field.Tag == 0:
+			if _, err := asn1.Unmarshal(field.Bytes, &tbs.Extensions); err != nil {
+				return nil, fmt.Errorf("invalid CRL extensions: %v", err)
+			}
+		default:
+			return nil, fmt.Errorf("unexpected field in TBSCertList (class %d, tag %d)", field.Class, field.Tag)
+		}
+	}
+
+	return crl, nil
+}
+
+// nextCRLField lee el siguiente campo de TBSCertList
+func nextCRLField(fields []byte) (asn1.RawValue, []byte, error) {
+	if len(fields) == 0 {
+		return asn1.RawValue{}, nil, errors.New("truncated TBSCertList")
+	}
+	var field asn1.RawValue
+	rest, err := asn1.Unmarshal(fields, &field)
+	if err != nil {
+		return asn1.RawValue{}, nil, fmt.Errorf("invalid TBSCertList: %v", err)
+	}
+	return field, rest, nil
+}
+
+// forEachEntry decodifica las entradas de a una y llama a fn con cada una. Una entrada que no
+// se puede decodificar se informa a malformed con su posición, desde 0, y se sigue con la
+// siguiente; el recorrido se detiene si la secuencia de entradas está truncada o si fn falla.
+func (c *decodedCRL) forEachEntry(fn func(pkix.RevokedCertificate) error, malformed func(index int, err error)) error {
+	entries := c.revoked
+	for index := 0; len(entries) > 0; index++ {
+		// Delimitar la entrada solo requiere su tag y longitud, así que una entrada con el
+		// contenido inválido no impide leer las siguientes
+		var raw asn1.RawValue
+		rest, err := asn1.Unmarshal(entries, &raw)
+		if err != nil {
+			return fmt.Errorf("invalid revoked certificate entry at index %d: %v", index, err)
+		}
+		entries = rest
+
+		var entry pkix.RevokedCertificate
+		if _, err := asn1.Unmarshal(raw.FullBytes, &entry); err != nil {
+			malformed(index, err)
+			continue
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// countEntries cuenta las entradas recorriendo solo su estructura DER, sin decodificarlas,
+// para aplicar el límite de entradas antes de importar
+func (c *decodedCRL) countEntries() (int, error) {
+	entries := cryptobyte.String(c.revoked)
+	count := 0
+	for !entries.Empty() {
+		if !entries.SkipASN1(cryptobyte_asn1.SEQUENCE) {
+			return 0, fmt.Errorf("invalid revoked certificate entry at index %d", count)
+		}
+		count++
+	}
+	return count, nil
+}
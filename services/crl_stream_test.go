@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"signerflow-crl/config"
+	"signerflow-crl/database"
+	"signerflow-crl/models"
+)
+
+// revokedRange arma n entradas revocadas con seriales consecutivos desde first
+func revokedRange(first int64, n int) []x509.RevocationListEntry {
+	revokedAt := time.Now().Add(-time.Hour)
+	list := make([]x509.RevocationListEntry, n)
+	for i := range list {
+		list[i] = revoked(first+int64(i), models.ReasonKeyCompromise, revokedAt)
+	}
+	return list
+}
+
+func TestDecodedCRLWalksEveryEntry(t *testing.T) {
+	der := newTestCA(t, "Stream CA").crl(t, 1, revokedRange(1000, 250))
+
+	crl, err := parseDERCRL(der)
+	if err != nil {
+		t.Fatalf("parseDERCRL: %v", err)
+	}
+	if len(crl.TBSCertList.RevokedCertificates) != 0 {
+		t.Errorf("parseDERCRL decoded %d entries up front, want none", len(crl.TBSCertList.RevokedCertificates))
+	}
+
+	count, err := crl.countEntries()
+	if err != nil || count != 250 {
+		t.Fatalf("countEntries = %d, %v; want 250", count, err)
+	}
+
+	next := big.NewInt(1000)
+	err = crl.forEachEntry(func(entry pkix.RevokedCertificate) error {
+		if entry.SerialNumber.Cmp(next) != 0 {
+			t.Fatalf("got serial %s, want %s", entry.SerialNumber, next)
+		}
+		next.Add(next, big.NewInt(1))
+		return nil
+	}, func(index int, err error) {
+		t.Errorf("entry %d reported as malformed: %v", index, err)
+	})
+	if err != nil {
+		t.Fatalf("forEachEntry: %v", err)
+	}
+	if next.Int64() != 1250 {
+		t.Errorf("visited entries up to serial %s, want 1249", new(big.Int).Sub(next, big.NewInt(1)))
+	}
+}
+
+func TestProcessSingleCRLEnforcesEntryCap(t *testing.T) {
+	ctx := context.Background()
+	ca := newTestCA(t, "Capped CA")
+
+	tests := []struct {
+		name    string
+		cap     int
+		entries int
+		wantErr bool
+	}{
+		{"at the cap", 5, 5, false},
+		{"above the cap", 5, 6, true},
+		{"cap disabled", 0, 6, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := database.NewMemoryStore()
+			service := newTestService(t, store, func(cfg *config.Config) {
+				cfg.MaxCRLEntries = tt.cap
+			})
+			srv := newCRLServer(t, ca.crl(t, 1, revokedRange(8000, tt.entries)))
+
+			err := service.ProcessSingleCRL(ctx, srv.URL)
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("ProcessSingleCRL: %v", err)
+				}
+				if status, _ := service.CheckCertificateStatus(ctx, "8000"); status == nil || !status.IsRevoked {
+					t.Error("entries were not imported")
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), "MAX_CRL_ENTRIES") {
+				t.Fatalf("got error %v, want the CRL rejected for exceeding MAX_CRL_ENTRIES", err)
+			}
+			// Se rechaza antes de importar: ninguna entrada queda guardada
+			for _, serial := range []string{"8000", "8005"} {
+				if status, _ := service.CheckCertificateStatus(ctx, serial); status != nil && status.IsRevoked {
+					t.Errorf("serial %s was imported from a rejected CRL", serial)
+				}
+			}
+			if _, err := store.GetCRLInfo(ctx, srv.URL); err == nil {
+				t.Error("CRL info was recorded for a rejected CRL")
+			}
+			history, err := store.GetProcessingHistory(ctx, srv.URL, 1)
+			if err != nil || len(history) != 1 || history[0].Status != models.ProcessingStatusFailed || history[0].CertCount != tt.entries {
+				t.Errorf("got processing history %+v (err %v), want a failed run counting %d entries", history, err, tt.entries)
+			}
+		})
+	}
+}
+
+// benchmarkCRLEntries es el tamaño de la CRL de los benchmarks, similar a las CRLs grandes reales
+const benchmarkCRLEntries = 50000
+
+func BenchmarkParseCRLEntries(b *testing.B) {
+	der := newTestCA(b, "Benchmark CA").crl(b, 1, revokedRange(1, benchmarkCRLEntries))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		crl, err := parseDERCRL(der)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := crl.countEntries(); err != nil {
+			b.Fatal(err)
+		}
+		visited := 0
+		if err := crl.forEachEntry(func(pkix.RevokedCertificate) error {
+			visited++
+			return nil
+		}, func(int, error) {}); err != nil {
+			b.Fatal(err)
+		}
+		if visited != benchmarkCRLEntries {
+			b.Fatalf("visited %d entries, want %d", visited, benchmarkCRLEntries)
+		}
+	}
+}
+
+func BenchmarkProcessSingleCRL(b *testing.B) {
+	ctx := context.Background()
+	srv := newCRLServer(b, newTestCA(b, "Benchmark CA").crl(b, 1, revokedRange(1, benchmarkCRLEntries)))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		service := newTestService(b, database.NewMemoryStore())
+		b.StartTimer()
+
+		if err := service.ProcessSingleCRL(ctx, srv.URL); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
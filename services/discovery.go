@@ -0,0 +1,35 @@
+package services
+
+// DistributionPoints son las URLs de CRL que publica un certificado en su extensión CRL
+// Distribution Points (2.5.29.31), junto con su sujeto y emisor para identificar la CA
+type DistributionPoints struct {
+	Subject string   `json:"subject"`
+	Issuer  string   `json:"issuer"`
+	CRLURLs []string `json:"crl_urls"`
+}
+
+// DiscoverDistributionPoints extrae las URLs de CRL de un certificado en DER o PEM, sin
+// repetidas y en el orden del certificado. Un certificado sin la extensión devuelve la lista
+// vacía; los datos que no son un certificado devuelven ErrInvalidCertificate.
+func DiscoverDistributionPoints(data []byte) (*DistributionPoints, error) {
+	cert, err := parseCertificate(data)
+	if err != nil {
+		return nil, err
+	}
+
+	urls := make([]string, 0, len(cert.CRLDistributionPoints))
+	seen := make(map[string]bool, len(cert.CRLDistributionPoints))
+	for _, crlURL := range cert.CRLDistributionPoints {
+		if seen[crlURL] {
+			continue
+		}
+		seen[crlURL] = true
+		urls = append(urls, crlURL)
+	}
+
+	return &DistributionPoints{
+		Subject: cert.Subject.String(),
+		Issuer:  cert.Issuer.String(),
+		CRLURLs: urls,
+	}, nil
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// issueWithCDP emite un certificado de hoja con las URLs dadas en CRL Distribution Points
+func (ca *testCA) issueWithCDP(t *testing.T, urls ...string) []byte {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating leaf key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(42),
+		Subject:               pkix.Name{CommonName: "discovered leaf"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		CRLDistributionPoints: urls,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, key.Public(), ca.key)
+	if err != nil {
+		t.Fatalf("creating leaf certificate: %v", err)
+	}
+	return der
+}
+
+func TestDiscoverDistributionPoints(t *testing.T) {
+	ca := newTestCA(t, "Discovery CA")
+	der := ca.issueWithCDP(t,
+		"http://crl.example/discovery.crl",
+		"ldap://ldap.example/cn=Discovery%20CA?certificateRevocationList",
+		"http://crl.example/discovery.crl",
+		"http://backup.example/discovery.crl",
+	)
+	want := []string{
+		"http://crl.example/discovery.crl",
+		"ldap://ldap.example/cn=Discovery%20CA?certificateRevocationList",
+		"http://backup.example/discovery.crl",
+	}
+
+	for name, data := range map[string][]byte{
+		"DER": der,
+		"PEM": pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	} {
+		points, err := DiscoverDistributionPoints(data)
+		if err != nil {
+			t.Fatalf("%s: DiscoverDistributionPoints: %v", name, err)
+		}
+		if !reflect.DeepEqual(points.CRLURLs, want) {
+			t.Errorf("%s: got URLs %q, want %q", name, points.CRLURLs, want)
+		}
+		if points.Subject != "CN=discovered leaf" || points.Issuer != ca.cert.Subject.String() {
+			t.Errorf("%s: got subject %q issuer %q", name, points.Subject, points.Issuer)
+		}
+	}
+
+	// Sin la extensión la lista está vacía, no es nil
+	points, err := DiscoverDistributionPoints(ca.issueWithCDP(t))
+	if err != nil {
+		t.Fatalf("DiscoverDistributionPoints: %v", err)
+	}
+	if points.CRLURLs == nil || len(points.CRLURLs) != 0 {
+		t.Errorf("got URLs %#v for a certificate without distribution points, want an empty list", points.CRLURLs)
+	}
+
+	if _, err := DiscoverDistributionPoints([]byte("not a certificate")); !errors.Is(err, ErrInvalidCertificate) {
+		t.Errorf("got error %v, want ErrInvalidCertificate", err)
+	}
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"crypto/x509/pkix"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"signerflow-crl/models"
+	"signerflow-crl/tracing"
+)
+
+// DryRunResult resume lo que importaría una CRL sin haberla procesado
+type DryRunResult struct {
+	URL               string    `json:"url"`
+	Issuer            string    `json:"issuer"`
+	IssuerDN          string    `json:"issuer_dn"`
+	CertCount         int       `json:"cert_count"`
+	NextUpdate        time.Time `json:"next_update"`
+	CRLNumber         string    `json:"crl_number,omitempty"`
+	IsDelta           bool      `json:"is_delta"`
+	IsIndirect        bool      `json:"is_indirect"`
+	SizeBytes         int       `json:"size_bytes"`
+	SignatureVerified bool      `json:"signature_verified"`
+	Warnings          []string  `json:"warnings"`
+}
+
+// DryRunCRL descarga, parsea y verifica la CRL igual que ProcessSingleCRL pero sin escribir
+// en PostgreSQL ni en Redis; sirve para validar una fuente antes de darla de alta
+func (s *CRLService) DryRunCRL(ctx context.Context, crlURL string) (*DryRunResult, error) {
+	ctx, span := tracing.Start(ctx, "CRLService.DryRunCRL",
+		trace.WithAttributes(attribute.String("crl.url", crlURL)))
+	defer span.End()
+
+	result, err := s.dryRunCRL(ctx, crlURL)
+	tracing.RecordError(span, err)
+	return result, err
+}
+
+func (s *CRLService) dryRunCRL(ctx context.Context, crlURL string) (*DryRunResult, error) {
+	// Sin validadores para forzar la descarga completa aunque la CRL ya se haya procesado
+	download, err := s.downloadCRL(ctx, crlURL, &models.CRLValidators{})
+	if err != nil {
+		return nil, fmt.Errorf("error downloading CRL: %v", err)
+	}
+
+	crl, err := s.decodeCRL(download.data)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing CRL %s: %v", crlURL, err)
+	}
+	entryCount, err := crl.countEntries()
+	if err != nil {
+		return nil, fmt.Errorf("error parsing CRL %s: %v", crlURL, err)
+	}
+
+	var issuerName pkix.Name
+	issuerName.FillFromRDNSequence(&crl.TBSCertList.Issuer)
+
+	verified, err := s.verifyCRLSignature(ctx, crl.CertificateList, issuerName)
+	if err != nil {
+		return nil, fmt.Errorf("error verifying CRL %s: %v", crlURL, err)
+	}
+
+	result := &DryRunResult{
+		URL:               crlURL,
+		Issuer:            s.extractIssuerName(issuerName),
+		IssuerDN:          canonicalIssuerDN(crl.TBSCertList.Issuer),
+		CertCount:         entryCount,
+		NextUpdate:        crl.TBSCertList.NextUpdate,
+		IsDelta:           s.isDeltaCRL(crl.CertificateList),
+		IsIndirect:        s.isIndirectCRL(crl.CertificateList),
+		SizeBytes:         len(download.data),
+		SignatureVerified: verified,
+		Warnings:          []string{},
+	}
+
+	if crlNumber := s.extractCRLNumber(crl.CertificateList); crlNumber != nil {
+		result.CRLNumber = crlNumber.String()
+	} else {
+		result.Warnings = append(result.Warnings, "CRL has no CRLNumber extension")
+	}
+
+	if !verified {
+		result.Warnings = append(result.Warnings, "signature not verified: no CA certificate registered for issuer")
+	}
+
+	switch {
+	case crl.TBSCertList.NextUpdate.IsZero():
+		result.Warnings = append(result.Warnings, "CRL has no nextUpdate")
+	case crl.TBSCertList.NextUpdate.Before(time.Now()):
+		result.Warnings = append(result.Warnings, fmt.Sprintf("CRL expired: nextUpdate %s is in the past", crl.TBSCertList.NextUpdate.Format(time.RFC3339)))
+	}
+
+	if s.cfg.MaxCRLEntries > 0 && entryCount > s.cfg.MaxCRLEntries {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("CRL lists %d entries, above MAX_CRL_ENTRIES (%d); it would be rejected", entryCount, s.cfg.MaxCRLEntries))
+	}
+
+	seen := make(map[string]struct{}, entryCount)
+	duplicates, removeFromBase, malformed, badExtensions := 0, 0, 0, 0
+	issuers := &entryIssuers{}
+	err = crl.forEachEntry(func(revokedCert pkix.RevokedCertificate) error {
+		// Igual que al importar, solo se omiten las entradas sin emisor conocido
+		if result.IsIndirect && issuers.next(s, revokedCert) != nil {
+			malformed++
+			return nil
+		}
+		reason, err := s.extractReasonCode(revokedCert)
+		if _, dateErr := extractInvalidityDate(revokedCert); err != nil || dateErr != nil {
+			badExtensions++
+		}
+
+		serial := s.formatSerial(revokedCert.SerialNumber)
+		if _, ok := seen[serial]; ok {
+			duplicates++
+		}
+		seen[serial] = struct{}{}
+
+		if !result.IsDelta && reason == models.ReasonRemoveFromCRL {
+			removeFromBase++
+		}
+		return nil
+	}, func(int, error) {
+		malformed++
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error parsing CRL %s: %v", crlURL, err)
+	}
+	if duplicates > 0 {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("%d duplicate serial entries", duplicates))
+	}
+	if malformed > 0 {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("%d malformed entries would be skipped", malformed))
+	}
+	if badExtensions > 0 {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("%d entries with a malformed CRLReason or InvalidityDate would be stored with reason unspecified or without invalidity date", badExtensions))
+	}
+	if removeFromBase > 0 {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("%d entries with reason removeFromCRL in a base CRL would be stored as revoked", removeFromBase))
+	}
+
+	return result, nil
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"context"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"signerflow-crl/database"
+	"signerflow-crl/models"
+)
+
+func TestDryRunCRLWritesNothing(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	redis, redisServer := newTestRedis(t)
+	service := newCachedTestService(t, store, redis)
+
+	ca := newTestCA(t, "Dry Run CA")
+	nextUpdate := time.Now().Add(2 * time.Hour).UTC().Truncate(time.Second)
+	srv := newCRLServer(t, ca.crlUntil(t, 5, nextUpdate, []x509.RevocationListEntry{
+		revoked(1, models.ReasonKeyCompromise, time.Now().Add(-time.Hour)),
+		revoked(2, models.ReasonSuperseded, time.Now().Add(-time.Hour)),
+	}))
+
+	result, err := service.DryRunCRL(ctx, srv.URL)
+	if err != nil {
+		t.Fatalf("DryRunCRL: %v", err)
+	}
+	if result.Issuer != "Dry Run CA" || result.CertCount != 2 || result.CRLNumber != "5" || !result.NextUpdate.Equal(nextUpdate) {
+		t.Errorf("got result %+v, want issuer Dry Run CA, 2 certificates, CRL number 5 and next update %v", result, nextUpdate)
+	}
+
+	if _, total, err := store.ListRevokedCertificates(ctx, models.CertificateFilter{}, 10, 0); err != nil || total != 0 {
+		t.Errorf("got %d revoked certificates (err %v), want none", total, err)
+	}
+	if crls, err := store.ListCRLInfo(ctx); err != nil || len(crls) != 0 {
+		t.Errorf("got %d CRL info rows (err %v), want none", len(crls), err)
+	}
+	if history, err := store.GetProcessingHistory(ctx, srv.URL, 10); err != nil || len(history) != 0 {
+		t.Errorf("got %d processing log rows (err %v), want none", len(history), err)
+	}
+	if keys := redisServer.Keys(); len(keys) != 0 {
+		t.Errorf("got Redis keys %q, want none", keys)
+	}
+}
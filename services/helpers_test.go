@@ -0,0 +1,220 @@
+package services
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"signerflow-crl/cache"
+	"signerflow-crl/cache/redistest"
+	"signerflow-crl/config"
+	"signerflow-crl/database"
+)
+
+// testCA es una CA autofirmada para emitir CRLs y certificados en las pruebas
+type testCA struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+}
+
+func newTestCA(t testing.TB, commonName string) *testCA {
+	t.Helper()
+	return newTestCAWithSubject(t, pkix.Name{CommonName: commonName, Organization: []string{"SignerFlow Test"}}.ToRDNSequence())
+}
+
+// newTestCAWithSubject crea la CA con el subject codificado tal cual, respetando el orden de sus atributos
+func newTestCAWithSubject(t testing.TB, subject pkix.RDNSequence) *testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating CA key: %v", err)
+	}
+	return createTestCA(t, subject, key)
+}
+
+// newTestCAWithKey crea la CA con la clave dada, para firmar con otros algoritmos
+func newTestCAWithKey(t testing.TB, commonName string, key crypto.Signer) *testCA {
+	t.Helper()
+	return createTestCA(t, pkix.Name{CommonName: commonName, Organization: []string{"SignerFlow Test"}}.ToRDNSequence(), key)
+}
+
+func createTestCA(t testing.TB, subject pkix.RDNSequence, key crypto.Signer) *testCA {
+	t.Helper()
+
+	rawSubject, err := asn1.Marshal(subject)
+	if err != nil {
+		t.Fatalf("encoding CA subject: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		RawSubject:            rawSubject,
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatalf("creating CA certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parsing CA certificate: %v", err)
+	}
+	return &testCA{cert: cert, key: key}
+}
+
+// issue emite un certificado de hoja con el serial dado
+func (ca *testCA) issue(t *testing.T, serial int64) *x509.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating leaf key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "leaf"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, key.Public(), ca.key)
+	if err != nil {
+		t.Fatalf("creating leaf certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parsing leaf certificate: %v", err)
+	}
+	return cert
+}
+
+// crl firma una CRL vigente durante la próxima hora con las entradas y extensiones adicionales dadas
+func (ca *testCA) crl(t testing.TB, number int64, entries []x509.RevocationListEntry, extensions ...pkix.Extension) []byte {
+	t.Helper()
+	return ca.crlUntil(t, number, time.Now().Add(time.Hour), entries, extensions...)
+}
+
+// crlUntil firma una CRL con el NextUpdate dado
+func (ca *testCA) crlUntil(t testing.TB, number int64, nextUpdate time.Time, entries []x509.RevocationListEntry, extensions ...pkix.Extension) []byte {
+	t.Helper()
+
+	thisUpdate := time.Now().Add(-time.Minute)
+	if nextUpdate.Before(thisUpdate) {
+		thisUpdate = nextUpdate.Add(-time.Hour)
+	}
+	template := &x509.RevocationList{
+		Number:                    big.NewInt(number),
+		ThisUpdate:                thisUpdate,
+		NextUpdate:                nextUpdate,
+		RevokedCertificateEntries: entries,
+		ExtraExtensions:           extensions,
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, template, ca.cert, ca.key)
+	if err != nil {
+		t.Fatalf("creating CRL: %v", err)
+	}
+	return der
+}
+
+// rawCRL firma una CRL con entradas arbitrarias, incluidas extensiones CRLReason mal formadas
+// que CreateRevocationList no admite
+func (ca *testCA) rawCRL(t *testing.T, entries []pkix.RevokedCertificate) []byte {
+	t.Helper()
+
+	der, err := ca.cert.CreateCRL(rand.Reader, ca.key, entries, time.Now().Add(-time.Minute), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("creating CRL: %v", err)
+	}
+	return der
+}
+
+// revoked arma una entrada de CRL con el serial y el motivo dados
+func revoked(serial int64, reason int, revokedAt time.Time) x509.RevocationListEntry {
+	return x509.RevocationListEntry{
+		SerialNumber:   big.NewInt(serial),
+		RevocationTime: revokedAt.UTC().Truncate(time.Second),
+		ReasonCode:     reason,
+	}
+}
+
+// crlServer publica la CRL que devuelve body en cada petición, o responde status si no es 0.
+// contentType reemplaza el Content-Type application/pkix-crl; hits cuenta las peticiones.
+type crlServer struct {
+	*httptest.Server
+	body        []byte
+	status      int
+	contentType string
+	hits        atomic.Int32
+}
+
+func newCRLServer(t testing.TB, body []byte) *crlServer {
+	t.Helper()
+
+	srv := &crlServer{body: body}
+	srv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		srv.hits.Add(1)
+		if srv.status != 0 {
+			w.WriteHeader(srv.status)
+			return
+		}
+		contentType := srv.contentType
+		if contentType == "" {
+			contentType = "application/pkix-crl"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Write(srv.body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newTestService crea un CRLService sin Redis sobre store, con la configuración por defecto
+// y sin reintentos de descarga
+func newTestService(t testing.TB, store database.CertStore, configure ...func(*config.Config)) *CRLService {
+	t.Helper()
+	return newCachedTestService(t, store, nil, configure...)
+}
+
+// newCachedTestService crea el servicio con un cliente Redis, que puede ser nil
+func newCachedTestService(t testing.TB, store database.CertStore, redis *cache.RedisClient, configure ...func(*config.Config)) *CRLService {
+	t.Helper()
+
+	cfg := config.LoadConfig()
+	cfg.DownloadAttempts = 1
+	for _, fn := range configure {
+		fn(cfg)
+	}
+
+	service, err := NewCRLService(store, redis, cfg)
+	if err != nil {
+		t.Fatalf("creating service: %v", err)
+	}
+	t.Cleanup(service.Close)
+	return service
+}
+
+// newTestRedis devuelve un cliente conectado a un servidor Redis en memoria, sin circuit breaker
+func newTestRedis(t *testing.T) (*cache.RedisClient, *redistest.Server) {
+	t.Helper()
+
+	srv := redistest.NewServer(t)
+	client, err := cache.NewRedisClient(srv.Addr(), "", 0, "", cache.BreakerConfig{})
+	if err != nil {
+		t.Fatalf("connecting to test Redis: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client, srv
+}
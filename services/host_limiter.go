@@ -0,0 +1,66 @@
+package services
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hostRateLimiter espacia las peticiones a un mismo host para no superar la tasa configurada
+type hostRateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// Wait bloquea hasta que el host admite una nueva petición o ctx se cancela
+func (l *hostRateLimiter) Wait(ctx context.Context) error {
+	if l.interval <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	return sleepContext(ctx, wait)
+}
+
+// waitForHost aplica el límite de peticiones por segundo del host de la URL
+func (s *CRLService) waitForHost(ctx context.Context, parsedURL *url.URL) error {
+	if s.cfg.CRLPerHostRate <= 0 {
+		return nil
+	}
+
+	host := strings.ToLower(parsedURL.Host)
+
+	s.limitersMu.Lock()
+	limiter, ok := s.limiters[host]
+	if !ok {
+		limiter = &hostRateLimiter{interval: time.Duration(float64(time.Second) / s.cfg.CRLPerHostRate)}
+		s.limiters[host] = limiter
+	}
+	s.limitersMu.Unlock()
+
+	return limiter.Wait(ctx)
+}
+
+// groupURLsByHost agrupa las URLs por host conservando el orden original
+func groupURLsByHost(urls []string) map[string][]string {
+	groups := make(map[string][]string)
+	for _, crlURL := range urls {
+		host := crlURL
+		if parsed, err := url.Parse(crlURL); err == nil && parsed.Host != "" {
+			host = strings.ToLower(parsed.Host)
+		}
+		groups[host] = append(groups[host], crlURL)
+	}
+	return groups
+}
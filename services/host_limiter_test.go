@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"signerflow-crl/config"
+	"signerflow-crl/database"
+)
+
+func TestProcessAllCRLsRespectsPerHostLimits(t *testing.T) {
+	const (
+		urls          = 6
+		perHost       = 2
+		ratePerSecond = 20
+	)
+	service := newTestService(t, database.NewMemoryStore(), func(cfg *config.Config) {
+		cfg.CRLConcurrency = 8
+		cfg.CRLPerHostConcurrency = perHost
+		cfg.CRLPerHostRate = ratePerSecond
+	})
+	crl := newTestCA(t, "Host Limit Test CA").crl(t, 1, nil)
+
+	var mu sync.Mutex
+	var inFlight, maxInFlight int
+	var starts []time.Time
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		starts = append(starts, time.Now())
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+
+		// Una respuesta lenta deja ver si se superan los workers por host
+		time.Sleep(300 * time.Millisecond)
+		w.Write(crl)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	lines := make([]string, urls)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("%s/crl-%d.crl", srv.URL, i)
+	}
+	urlsFile := filepath.Join(t.TempDir(), "crl_urls.txt")
+	if err := os.WriteFile(urlsFile, []byte(strings.Join(lines, "\n")), 0o644); err != nil {
+		t.Fatalf("writing URLs file: %v", err)
+	}
+
+	if err := service.ProcessAllCRLs(context.Background(), urlsFile); err != nil {
+		t.Fatalf("ProcessAllCRLs: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(starts) != urls {
+		t.Fatalf("got %d requests, want %d", len(starts), urls)
+	}
+	if maxInFlight > perHost {
+		t.Errorf("got %d concurrent requests to the host, want at most %d", maxInFlight, perHost)
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+	// Margen para la resolución del reloj y el planificador
+	minGap := time.Second/ratePerSecond - 5*time.Millisecond
+	for i := 1; i < len(starts); i++ {
+		if gap := starts[i].Sub(starts[i-1]); gap < minGap {
+			t.Errorf("requests %d and %d started %v apart, want at least %v", i, i+1, gap, minGap)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"log"
+)
+
+var (
+	oidIssuingDistributionPoint = asn1.ObjectIdentifier{2, 5, 29, 28}
+	oidCertificateIssuer        = asn1.ObjectIdentifier{2, 5, 29, 29}
+)
+
+// issuingDistributionPoint es la extensión IssuingDistributionPoint (RFC 5280, 5.2.5)
+type issuingDistributionPoint struct {
+	DistributionPoint          asn1.RawValue  `asn1:"optional,tag:0"`
+	OnlyContainsUserCerts      bool           `asn1:"optional,tag:1"`
+	OnlyContainsCACerts        bool           `asn1:"optional,tag:2"`
+	OnlySomeReasons            asn1.BitString `asn1:"optional,tag:3"`
+	IndirectCRL                bool           `asn1:"optional,tag:4"`
+	OnlyContainsAttributeCerts bool           `asn1:"optional,tag:5"`
+}
+
+// issuingDistributionPointOf decodifica la extensión IssuingDistributionPoint de la CRL; ok es
+// false si no la tiene o está mal formada
+func issuingDistributionPointOf(crl *pkix.CertificateList) (idp issuingDistributionPoint, ok bool) {
+	for _, ext := range crl.TBSCertList.Extensions {
+		if !ext.Id.Equal(oidIssuingDistributionPoint) {
+			continue
+		}
+		if _, err := asn1.Unmarshal(ext.Value, &idp); err != nil {
+			log.Printf("Error parsing IssuingDistributionPoint extension: %v", err)
+			return idp, false
+		}
+		return idp, true
+	}
+	return idp, false
+}
+
+// isIndirectCRL indica si la CRL tiene el flag indirectCRL en su IssuingDistributionPoint,
+// es decir, si puede listar certificados de emisores distintos al que la firma
+func (s *CRLService) isIndirectCRL(crl *pkix.CertificateList) bool {
+	idp, ok := issuingDistributionPointOf(crl)
+	return ok && idp.IndirectCRL
+}
+
+// isScopedCRL indica si el IssuingDistributionPoint limita la CRL a una parte de los
+// certificados del emisor: un punto de distribución propio (CRLs particionadas), solo
+// certificados de usuario, de CA o de atributos, o solo algunos motivos. Una CRL así no
+// lista todas las revocaciones del emisor y no sirve para reconciliarlo.
+func (s *CRLService) isScopedCRL(crl *pkix.CertificateList) bool {
+	idp, ok := issuingDistributionPointOf(crl)
+	if !ok {
+		return false
+	}
+	return len(idp.DistributionPoint.FullBytes) > 0 || idp.OnlyContainsUserCerts || idp.OnlyContainsCACerts ||
+		idp.OnlyContainsAttributeCerts || idp.OnlySomeReasons.BitLength > 0
+}
+
+// extractCertificateIssuer lee la extensión de entrada Certificate Issuer (2.5.29.29) y
+// devuelve el nombre del emisor en el mismo formato que extractIssuerName junto con su DN
+// canónico. ok es false si la entrada no la tiene; una extensión mal formada o sin un
+// directoryName devuelve error, ya que no permite saber a qué CA pertenece la entrada.
+func (s *CRLService) extractCertificateIssuer(revokedCert pkix.RevokedCertificate) (issuer, issuerDN string, ok bool, err error) {
+	for _, ext := range revokedCert.Extensions {
+		if !ext.Id.Equal(oidCertificateIssuer) {
+			continue
+		}
+
+		var generalNames []asn1.RawValue
+		if _, err := asn1.Unmarshal(ext.Value, &generalNames); err != nil {
+			return "", "", false, fmt.Errorf("invalid CertificateIssuer extension: %v", err)
+		}
+
+		// directoryName [4] lleva el Name con tag explícito
+		for _, name := range generalNames {
+			if name.Class != asn1.ClassContextSpecific || name.Tag != 4 {
+				continue
+			}
+			var rdns pkix.RDNSequence
+			if _, err := asn1.Unmarshal(name.Bytes, &rdns); err != nil {
+				return "", "", false, fmt.Errorf("invalid CertificateIssuer name: %v", err)
+			}
+			var issuerName pkix.Name
+			issuerName.FillFromRDNSequence(&rdns)
+			return s.extractIssuerName(issuerName), canonicalIssuerDN(rdns), true, nil
+		}
+		return "", "", false, fmt.Errorf("CertificateIssuer extension has no directoryName")
+	}
+	return "", "", false, nil
+}
+
+// errUnknownEntryIssuer indica una entrada de una CRL indirecta que hereda el emisor de una
+// extensión Certificate Issuer mal formada
+var errUnknownEntryIssuer = errors.New("issuer unknown after a malformed CertificateIssuer extension")
+
+// entryIssuers sigue el emisor de las entradas de una CRL indirecta, que se hereda de la
+// entrada anterior hasta que otra trae su propia extensión Certificate Issuer (RFC 5280, 5.3.3)
+type entryIssuers struct {
+	name string
+	dn   string
+	// unknown queda activo desde una extensión mal formada hasta la próxima válida: las
+	// entradas intermedias no se atribuyen a la CA de una extensión anterior
+	unknown bool
+}
+
+// next actualiza el emisor con la entrada y devuelve error si no se le puede atribuir uno
+func (e *entryIssuers) next(s *CRLService, revokedCert pkix.RevokedCertificate) error {
+	name, dn, ok, err := s.extractCertificateIssuer(revokedCert)
+	switch {
+	case err != nil:
+		e.unknown = true
+		return err
+	case ok:
+		e.name, e.dn, e.unknown = name, dn, false
+	case e.unknown:
+		return errUnknownEntryIssuer
+	}
+	return nil
+}
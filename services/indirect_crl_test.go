@@ -0,0 +1,181 @@
+package services
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"testing"
+	"time"
+
+	"signerflow-crl/database"
+	"signerflow-crl/models"
+)
+
+// indirectCRLExtension marca la CRL como indirecta en su IssuingDistributionPoint
+func indirectCRLExtension(t *testing.T) pkix.Extension {
+	t.Helper()
+
+	value, err := asn1.Marshal(issuingDistributionPoint{IndirectCRL: true})
+	if err != nil {
+		t.Fatalf("encoding IssuingDistributionPoint: %v", err)
+	}
+	return pkix.Extension{Id: oidIssuingDistributionPoint, Critical: true, Value: value}
+}
+
+// certificateIssuerExtension es la extensión de entrada Certificate Issuer con un directoryName
+func certificateIssuerExtension(t *testing.T, commonName string) pkix.Extension {
+	t.Helper()
+
+	rdns, err := asn1.Marshal(pkix.Name{CommonName: commonName}.ToRDNSequence())
+	if err != nil {
+		t.Fatalf("encoding issuer name: %v", err)
+	}
+	value, err := asn1.Marshal([]asn1.RawValue{{Class: asn1.ClassContextSpecific, Tag: 4, IsCompound: true, Bytes: rdns}})
+	if err != nil {
+		t.Fatalf("encoding CertificateIssuer: %v", err)
+	}
+	return pkix.Extension{Id: oidCertificateIssuer, Critical: true, Value: value}
+}
+
+func TestIndirectCRLStopsAttributionAfterMalformedIssuer(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	service := newTestService(t, store)
+
+	ca := newTestCA(t, "Indirect Test CA")
+	revokedAt := time.Now().Add(-time.Hour)
+	entry := func(serial int64, extensions ...pkix.Extension) x509.RevocationListEntry {
+		e := revoked(serial, models.ReasonKeyCompromise, revokedAt)
+		e.ExtraExtensions = extensions
+		return e
+	}
+	srv := newCRLServer(t, ca.crl(t, 1, []x509.RevocationListEntry{
+		entry(6001),
+		entry(6002, certificateIssuerExtension(t, "Other CA")),
+		entry(6003),
+		entry(6004, pkix.Extension{Id: oidCertificateIssuer, Critical: true, Value: []byte{0x30, 0x03, 0xa4}}),
+		entry(6005),
+		entry(6006, certificateIssuerExtension(t, "Third CA")),
+	}, indirectCRLExtension(t)))
+
+	if err := service.ProcessSingleCRL(ctx, srv.URL); err != nil {
+		t.Fatalf("ProcessSingleCRL: %v", err)
+	}
+
+	tests := []struct {
+		serial string
+		ca     string
+	}{
+		{"6001", "Indirect Test CA"},
+		{"6002", "Other CA"},
+		{"6003", "Other CA"},
+		{"6004", ""},
+		{"6005", ""},
+		{"6006", "Third CA"},
+	}
+	for _, tt := range tests {
+		status, err := service.CheckCertificateStatus(ctx, tt.serial)
+		if err != nil {
+			t.Fatalf("CheckCertificateStatus(%s): %v", tt.serial, err)
+		}
+		if tt.ca == "" {
+			if status.IsRevoked {
+				t.Errorf("serial %s after a malformed CertificateIssuer was attributed to %s", tt.serial, *status.CertificateAuthority)
+			}
+			continue
+		}
+		if !status.IsRevoked || status.CertificateAuthority == nil || *status.CertificateAuthority != tt.ca {
+			t.Errorf("serial %s: got revoked=%v CA %v, want revoked by %s", tt.serial, status.IsRevoked, status.CertificateAuthority, tt.ca)
+		}
+	}
+
+	history, err := store.GetProcessingHistory(ctx, srv.URL, 1)
+	if err != nil {
+		t.Fatalf("GetProcessingHistory: %v", err)
+	}
+	if len(history) != 1 || history[0].SkippedEntries != 2 {
+		t.Errorf("got processing history %+v, want 2 skipped entries", history)
+	}
+}
+
+func TestIndirectCRLAttributesEntriesToTheirIssuer(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	service := newTestService(t, store)
+
+	ca := newTestCA(t, "Indirect Test CA")
+	revokedAt := time.Now().Add(-time.Hour)
+	entry := func(serial int64, extensions ...pkix.Extension) x509.RevocationListEntry {
+		e := revoked(serial, models.ReasonKeyCompromise, revokedAt)
+		e.ExtraExtensions = extensions
+		return e
+	}
+	// El mismo serial 6101 aparece para ambos emisores; cada uno debe quedar con su CA
+	srv := newCRLServer(t, ca.crl(t, 1, []x509.RevocationListEntry{
+		entry(6101),
+		entry(6102),
+		entry(6101, certificateIssuerExtension(t, "Partner CA")),
+		entry(6103),
+	}, indirectCRLExtension(t)))
+
+	if err := service.ProcessSingleCRL(ctx, srv.URL); err != nil {
+		t.Fatalf("ProcessSingleCRL: %v", err)
+	}
+
+	tests := []struct {
+		serial  string
+		ca      string
+		revoked bool
+	}{
+		{"6101", "Indirect Test CA", true},
+		{"6102", "Indirect Test CA", true},
+		{"6103", "Indirect Test CA", false},
+		{"6101", "Partner CA", true},
+		{"6102", "Partner CA", false},
+		{"6103", "Partner CA", true},
+	}
+	for _, tt := range tests {
+		status, err := service.CheckCertificateStatusForCA(ctx, tt.serial, tt.ca)
+		if err != nil {
+			t.Fatalf("CheckCertificateStatusForCA(%s, %s): %v", tt.serial, tt.ca, err)
+		}
+		if status.IsRevoked != tt.revoked {
+			t.Errorf("serial %s of %s: got revoked=%v, want %v", tt.serial, tt.ca, status.IsRevoked, tt.revoked)
+		}
+	}
+
+	breakdown, err := store.GetStatsByCA(ctx, "")
+	if err != nil {
+		t.Fatalf("GetStatsByCA: %v", err)
+	}
+	counts := make(map[string]int)
+	for _, stat := range breakdown {
+		counts[stat.CertificateAuthority] = stat.RevokedCount
+	}
+	if counts["Indirect Test CA"] != 2 || counts["Partner CA"] != 2 || len(counts) != 2 {
+		t.Errorf("got revoked counts by CA %v, want 2 for each issuer", counts)
+	}
+}
+
+func TestCertificateIssuerIgnoredOutsideIndirectCRL(t *testing.T) {
+	ctx := context.Background()
+	service := newTestService(t, database.NewMemoryStore())
+
+	ca := newTestCA(t, "Direct Test CA")
+	e := revoked(6201, models.ReasonKeyCompromise, time.Now().Add(-time.Hour))
+	e.ExtraExtensions = []pkix.Extension{certificateIssuerExtension(t, "Partner CA")}
+	srv := newCRLServer(t, ca.crl(t, 1, []x509.RevocationListEntry{e}))
+
+	if err := service.ProcessSingleCRL(ctx, srv.URL); err != nil {
+		t.Fatalf("ProcessSingleCRL: %v", err)
+	}
+
+	status, err := service.CheckCertificateStatus(ctx, "6201")
+	if err != nil {
+		t.Fatalf("CheckCertificateStatus: %v", err)
+	}
+	if !status.IsRevoked || status.CertificateAuthority == nil || *status.CertificateAuthority != "Direct Test CA" {
+		t.Errorf("got revoked=%v CA %v, want revoked by the CRL issuer Direct Test CA", status.IsRevoked, status.CertificateAuthority)
+	}
+}
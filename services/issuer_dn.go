@@ -0,0 +1,59 @@
+package services
+
+import (
+	"crypto/x509/pkix"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// issuerAttributeRank ordena los atributos conocidos del DN canónico de lo general a lo
+// particular; los demás van al final ordenados por OID
+var issuerAttributeRank = map[string]int{
+	"2.5.4.6":  0, // C
+	"2.5.4.8":  1, // ST
+	"2.5.4.7":  2, // L
+	"2.5.4.9":  3, // STREET
+	"2.5.4.10": 4, // O
+	"2.5.4.11": 5, // OU
+	"2.5.4.3":  6, // CN
+	"2.5.4.5":  7, // SERIALNUMBER
+}
+
+// canonicalIssuerDN devuelve el DN del emisor en formato RFC 2253 con los atributos en un
+// orden fijo, un atributo por RDN y los espacios de los valores normalizados, de modo que
+// el mismo emisor codificado con otro orden de atributos produce el mismo DN
+func canonicalIssuerDN(rdns pkix.RDNSequence) string {
+	var attributes []pkix.AttributeTypeAndValue
+	for _, rdn := range rdns {
+		for _, attribute := range rdn {
+			if value, ok := attribute.Value.(string); ok {
+				attribute.Value = strings.Join(strings.Fields(value), " ")
+			}
+			attributes = append(attributes, attribute)
+		}
+	}
+
+	rank := func(attribute pkix.AttributeTypeAndValue) int {
+		if r, ok := issuerAttributeRank[attribute.Type.String()]; ok {
+			return r
+		}
+		return len(issuerAttributeRank)
+	}
+	sort.SliceStable(attributes, func(i, j int) bool {
+		ri, rj := rank(attributes[i]), rank(attributes[j])
+		if ri != rj {
+			return ri < rj
+		}
+		if oi, oj := attributes[i].Type.String(), attributes[j].Type.String(); oi != oj {
+			return oi < oj
+		}
+		return fmt.Sprint(attributes[i].Value) < fmt.Sprint(attributes[j].Value)
+	})
+
+	canonical := make(pkix.RDNSequence, len(attributes))
+	for i, attribute := range attributes {
+		canonical[i] = pkix.RelativeDistinguishedNameSET{attribute}
+	}
+	return canonical.String()
+}
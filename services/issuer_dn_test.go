@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"testing"
+	"time"
+
+	"signerflow-crl/config"
+	"signerflow-crl/database"
+	"signerflow-crl/models"
+)
+
+var (
+	oidCountry      = asn1.ObjectIdentifier{2, 5, 4, 6}
+	oidOrganization = asn1.ObjectIdentifier{2, 5, 4, 10}
+	oidCommonName   = asn1.ObjectIdentifier{2, 5, 4, 3}
+)
+
+// rdn arma un RDN con los pares tipo-valor dados
+func rdn(pairs ...any) pkix.RelativeDistinguishedNameSET {
+	var set pkix.RelativeDistinguishedNameSET
+	for i := 0; i+1 < len(pairs); i += 2 {
+		set = append(set, pkix.AttributeTypeAndValue{Type: pairs[i].(asn1.ObjectIdentifier), Value: pairs[i+1]})
+	}
+	return set
+}
+
+func TestCanonicalIssuerDN(t *testing.T) {
+	want := canonicalIssuerDN(pkix.RDNSequence{
+		rdn(oidCountry, "ES"),
+		rdn(oidOrganization, "SignerFlow"),
+		rdn(oidCommonName, "Issuing CA"),
+	})
+	if want != "CN=Issuing CA,O=SignerFlow,C=ES" {
+		t.Fatalf("got canonical DN %q, want CN=Issuing CA,O=SignerFlow,C=ES", want)
+	}
+
+	tests := []struct {
+		name string
+		rdns pkix.RDNSequence
+		same bool
+	}{
+		{"reversed order", pkix.RDNSequence{rdn(oidCommonName, "Issuing CA"), rdn(oidOrganization, "SignerFlow"), rdn(oidCountry, "ES")}, true},
+		{"multi-valued RDN", pkix.RDNSequence{rdn(oidOrganization, "SignerFlow", oidCountry, "ES"), rdn(oidCommonName, "Issuing CA")}, true},
+		{"extra whitespace", pkix.RDNSequence{rdn(oidCountry, "ES"), rdn(oidOrganization, " SignerFlow "), rdn(oidCommonName, "Issuing   CA")}, true},
+		{"different CN", pkix.RDNSequence{rdn(oidCountry, "ES"), rdn(oidOrganization, "SignerFlow"), rdn(oidCommonName, "Other CA")}, false},
+	}
+	for _, tt := range tests {
+		if got := canonicalIssuerDN(tt.rdns); (got == want) != tt.same {
+			t.Errorf("%s: got %q, same as %q = %v, want %v", tt.name, got, want, got == want, tt.same)
+		}
+	}
+}
+
+func TestAttributeOrderingGroupsIssuer(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	service := newTestService(t, store, func(cfg *config.Config) {
+		cfg.CRLPerHostRate = 0
+	})
+
+	// El mismo emisor publicado con los atributos del DN en orden inverso
+	forward := newTestCAWithSubject(t, pkix.RDNSequence{rdn(oidCountry, "ES"), rdn(oidOrganization, "SignerFlow"), rdn(oidCommonName, "Reordered CA")})
+	reversed := newTestCAWithSubject(t, pkix.RDNSequence{rdn(oidCommonName, "Reordered CA"), rdn(oidOrganization, "SignerFlow"), rdn(oidCountry, "ES")})
+	if string(forward.cert.RawSubject) == string(reversed.cert.RawSubject) {
+		t.Fatal("both CAs encode the same subject")
+	}
+
+	revokedAt := time.Now().Add(-time.Hour)
+	forwardSrv := newCRLServer(t, forward.crl(t, 1, []x509.RevocationListEntry{revoked(501, models.ReasonKeyCompromise, revokedAt)}))
+	reversedSrv := newCRLServer(t, reversed.crl(t, 1, []x509.RevocationListEntry{revoked(502, models.ReasonKeyCompromise, revokedAt)}))
+	for _, url := range []string{forwardSrv.URL, reversedSrv.URL} {
+		if err := service.ProcessSingleCRL(ctx, url); err != nil {
+			t.Fatalf("ProcessSingleCRL(%s): %v", url, err)
+		}
+	}
+
+	forwardInfo, err := store.GetCRLInfo(ctx, forwardSrv.URL)
+	if err != nil {
+		t.Fatalf("GetCRLInfo: %v", err)
+	}
+	reversedInfo, err := store.GetCRLInfo(ctx, reversedSrv.URL)
+	if err != nil {
+		t.Fatalf("GetCRLInfo: %v", err)
+	}
+	if forwardInfo.IssuerDN == "" || forwardInfo.IssuerDN != reversedInfo.IssuerDN {
+		t.Errorf("got issuer DNs %q and %q, want the same canonical DN", forwardInfo.IssuerDN, reversedInfo.IssuerDN)
+	}
+	if forwardInfo.Issuer != "Reordered CA" || reversedInfo.Issuer != "Reordered CA" {
+		t.Errorf("got issuer names %q and %q, want the CN Reordered CA", forwardInfo.Issuer, reversedInfo.Issuer)
+	}
+
+	stats, err := store.GetStatsByCA(ctx, "")
+	if err != nil {
+		t.Fatalf("GetStatsByCA: %v", err)
+	}
+	if len(stats) != 1 {
+		t.Fatalf("got %d CA groups, want both CRLs grouped under one CA", len(stats))
+	}
+	if stats[0].RevokedCount != 2 || stats[0].IssuerDN != forwardInfo.IssuerDN {
+		t.Errorf("got CA stats %+v, want 2 certificates under %q", *stats[0], forwardInfo.IssuerDN)
+	}
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+const (
+	ldapTimeout      = 30 * time.Second
+	ldapCRLAttribute = "certificateRevocationList;binary"
+)
+
+// fetchLDAPCRL lee la CRL del atributo certificateRevocationList de la entrada indicada en
+// la URL (ldap://host/dn?atributo, RFC 4516). Usa bind anónimo salvo que se configuren credenciales.
+func (s *CRLService) fetchLDAPCRL(ctx context.Context, parsedURL *url.URL) (*crlDownload, error) {
+	baseDN, err := url.PathUnescape(strings.TrimPrefix(parsedURL.Path, "/"))
+	if err != nil || baseDN == "" {
+		return nil, fmt.Errorf("invalid LDAP URL: missing distinguished name")
+	}
+
+	// Solo se usa la parte de atributos de la query; scope y filtro no aplican a una CRL
+	attribute := ldapCRLAttribute
+	if attrs := strings.SplitN(parsedURL.RawQuery, "?", 2)[0]; attrs != "" {
+		if unescaped, err := url.QueryUnescape(strings.Split(attrs, ",")[0]); err == nil {
+			attribute = unescaped
+		}
+	}
+
+	conn, err := ldap.DialURL(
+		parsedURL.Scheme+"://"+parsedURL.Host,
+		ldap.DialWithDialer(&net.Dialer{Timeout: ldapTimeout}),
+	)
+	if err != nil {
+		return nil, &retryableError{err: fmt.Errorf("error connecting to LDAP server: %v", err)}
+	}
+	defer conn.Close()
+	conn.SetTimeout(ldapTimeout)
+
+	// El cliente LDAP no acepta contexto; cerrar la conexión aborta la búsqueda en curso
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if s.cfg.LDAPBindDN != "" {
+		if err := conn.Bind(s.cfg.LDAPBindDN, s.cfg.LDAPBindPassword); err != nil {
+			return nil, fmt.Errorf("error binding to LDAP server: %v", err)
+		}
+	}
+
+	result, err := conn.Search(ldap.NewSearchRequest(
+		baseDN,
+		ldap.ScopeBaseObject,
+		ldap.NeverDerefAliases,
+		1,
+		int(ldapTimeout.Seconds()),
+		false,
+		"(objectClass=*)",
+		[]string{attribute},
+		nil,
+	))
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.ErrorNetwork) {
+			return nil, &retryableError{err: fmt.Errorf("error searching LDAP: %v", err)}
+		}
+		return nil, fmt.Errorf("error searching LDAP: %v", err)
+	}
+
+	if len(result.Entries) == 0 {
+		return nil, fmt.Errorf("LDAP entry %s not found", baseDN)
+	}
+
+	entry := result.Entries[0]
+	for _, name := range []string{attribute, ldapCRLAttribute, "certificateRevocationList"} {
+		if values := entry.GetRawAttributeValues(name); len(values) > 0 && len(values[0]) > 0 {
+			if int64(len(values[0])) > s.maxCRLSize() {
+				return nil, fmt.Errorf("CRL exceeds the limit of %d MB", s.cfg.MaxCRLSizeMB)
+			}
+			return &crlDownload{data: values[0]}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("LDAP entry %s has no %s attribute", baseDN, attribute)
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"signerflow-crl/database"
+	"signerflow-crl/models"
+)
+
+// Etiquetas [APPLICATION n] de las operaciones LDAP usadas por el servidor de prueba (RFC 4511)
+const (
+	ldapOpUnbind      = 2
+	ldapOpSearch      = 3
+	ldapOpSearchEntry = 4
+	ldapOpSearchDone  = 5
+	ldapNoSuchObject  = 32
+	ldapClassApp      = 1
+	ldapTestAttribute = "certificateRevocationList;binary"
+)
+
+// ldapMessage es un LDAPMessage sin controles
+type ldapMessage struct {
+	ID int
+	Op asn1.RawValue
+}
+
+type ldapAttribute struct {
+	Type   []byte
+	Values [][]byte `asn1:"set"`
+}
+
+// newLDAPServer levanta un servidor LDAP mínimo que responde las búsquedas por DN con la CRL
+// de crls en el atributo certificateRevocationList;binary, y devuelve su dirección
+func newLDAPServer(t *testing.T, crls map[string][]byte) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveLDAP(conn, crls)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func serveLDAP(conn net.Conn, crls map[string][]byte) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	for {
+		data, err := readBERElement(reader)
+		if err != nil {
+			return
+		}
+		var msg ldapMessage
+		if _, err := asn1.Unmarshal(data, &msg); err != nil || msg.Op.Class != ldapClassApp {
+			return
+		}
+
+		switch msg.Op.Tag {
+		case ldapOpUnbind:
+			return
+		case ldapOpSearch:
+			// El primer campo de la SearchRequest es el DN base
+			var baseDN []byte
+			if _, err := asn1.Unmarshal(msg.Op.Bytes, &baseDN); err != nil {
+				return
+			}
+			resultCode := ldapNoSuchObject
+			if crl, ok := crls[string(baseDN)]; ok {
+				name, _ := asn1.Marshal(baseDN)
+				attributes, _ := asn1.Marshal([]ldapAttribute{{Type: []byte(ldapTestAttribute), Values: [][]byte{crl}}})
+				writeLDAPMessage(conn, msg.ID, ldapOpSearchEntry, append(name, attributes...))
+				resultCode = 0
+			}
+			code, _ := asn1.Marshal(asn1.Enumerated(resultCode))
+			empty, _ := asn1.Marshal([]byte{})
+			writeLDAPMessage(conn, msg.ID, ldapOpSearchDone, append(append(code, empty...), empty...))
+		default:
+			return
+		}
+	}
+}
+
+func writeLDAPMessage(w io.Writer, id, op int, body []byte) {
+	data, _ := asn1.Marshal(ldapMessage{
+		ID: id,
+		Op: asn1.RawValue{Class: ldapClassApp, Tag: op, IsCompound: true, Bytes: body},
+	})
+	w.Write(data)
+}
+
+// readBERElement lee un elemento BER completo de longitud definida
+func readBERElement(r *bufio.Reader) ([]byte, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	length := int(header[1])
+	if header[1]&0x80 != 0 {
+		size := int(header[1] & 0x7f)
+		if size == 0 || size > 4 {
+			return nil, errors.New("unsupported BER length")
+		}
+		lengthBytes := make([]byte, size)
+		if _, err := io.ReadFull(r, lengthBytes); err != nil {
+			return nil, err
+		}
+		header = append(header, lengthBytes...)
+		length = 0
+		for _, b := range lengthBytes {
+			length = length<<8 | int(b)
+		}
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	return append(header, body...), nil
+}
+
+func TestProcessSingleCRLFromLDAP(t *testing.T) {
+	ctx := context.Background()
+	service := newTestService(t, database.NewMemoryStore())
+	ca := newTestCA(t, "LDAP Test CA")
+
+	const dn = "cn=LDAP Test CA,o=SignerFlow Test"
+	addr := newLDAPServer(t, map[string][]byte{
+		dn: ca.crl(t, 1, []x509.RevocationListEntry{revoked(4601, models.ReasonKeyCompromise, time.Now())}),
+	})
+	ldapURL := func(dn string) string {
+		return "ldap://" + addr + "/" + url.PathEscape(dn) + "?" + url.QueryEscape(ldapTestAttribute)
+	}
+
+	if err := service.ProcessSingleCRL(ctx, ldapURL(dn)); err != nil {
+		t.Fatalf("ProcessSingleCRL: %v", err)
+	}
+	status, err := service.CheckCertificateStatus(ctx, "4601")
+	if err != nil {
+		t.Fatalf("CheckCertificateStatus: %v", err)
+	}
+	if !status.IsRevoked {
+		t.Error("serial 4601 from the LDAP CRL was not imported")
+	}
+
+	if _, err := service.fetchCRL(ctx, ldapURL("cn=Missing CA"), &models.CRLValidators{}); err == nil {
+		t.Error("fetchCRL succeeded for a DN that does not exist")
+	}
+	if _, err := service.fetchCRL(ctx, "ldap://"+addr+"/", &models.CRLValidators{}); err == nil || !strings.Contains(err.Error(), "missing distinguished name") {
+		t.Errorf("got error %v for an LDAP URL without DN, want missing distinguished name", err)
+	}
+}
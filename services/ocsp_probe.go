@@ -0,0 +1,286 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+	"signerflow-crl/models"
+)
+
+const (
+	// ocspProbeCandidates es cuántas revocaciones recientes se consideran al elegir la muestra
+	ocspProbeCandidates = 500
+	// ocspProbeTimeout acota cada consulta al responder OCSP de una CA
+	ocspProbeTimeout = 10 * time.Second
+	// ocspProbeMaxResponse limita el tamaño de la respuesta OCSP que se lee
+	ocspProbeMaxResponse = 1 << 20
+	// maxConsistencyMismatches es cuántas discrepancias recientes conserva el informe
+	maxConsistencyMismatches = 100
+)
+
+// ConsistencyMismatch es una revocación en la que el responder OCSP de la CA contradice los
+// datos importados de su CRL. Field es status, revocation_date o reason; Expected es el valor
+// de la CRL y OCSP el que informó el responder.
+type ConsistencyMismatch struct {
+	Serial               string    `json:"serial"`
+	CertificateAuthority string    `json:"certificate_authority"`
+	OCSPURL              string    `json:"ocsp_url"`
+	Field                string    `json:"field"`
+	Expected             string    `json:"expected"`
+	OCSP                 string    `json:"ocsp"`
+	DetectedAt           time.Time `json:"detected_at"`
+}
+
+// ConsistencyCounts cuenta las revocaciones de una muestra según el resultado de compararlas:
+// coinciden, discrepan, se omiten porque no hay certificado de la CA registrado con un
+// responder OCSP en su AIA, o fallan porque el responder no dio una respuesta válida
+type ConsistencyCounts struct {
+	Checked    int `json:"checked"`
+	Agreed     int `json:"agreed"`
+	Mismatched int `json:"mismatched"`
+	Skipped    int `json:"skipped"`
+	Failed     int `json:"failed"`
+}
+
+func (c *ConsistencyCounts) add(other ConsistencyCounts) {
+	c.Checked += other.Checked
+	c.Agreed += other.Agreed
+	c.Mismatched += other.Mismatched
+	c.Skipped += other.Skipped
+	c.Failed += other.Failed
+}
+
+// ConsistencyReport es el informe de /api/v1/stats/consistency: los totales desde el arranque,
+// los de la última ejecución y las discrepancias más recientes, de la más nueva a la más vieja
+type ConsistencyReport struct {
+	Enabled          bool                  `json:"enabled"`
+	LastRun          *time.Time            `json:"last_run,omitempty"`
+	LastRunCounts    ConsistencyCounts     `json:"last_run_counts"`
+	Totals           ConsistencyCounts     `json:"totals"`
+	RecentMismatches []ConsistencyMismatch `json:"recent_mismatches"`
+}
+
+// ProbeOCSPConsistency elige al azar OCSP_PROBE_SAMPLE_SIZE de las revocaciones más recientes
+// y consulta su estado al responder OCSP de la CA, tomado del AIA de su certificado registrado
+// en ca_certificates. Cada discrepancia se registra en el log, en el contador
+// stats:ocsp_mismatches y en el informe de ConsistencyReport. Devuelve los totales de la
+// ejecución.
+func (s *CRLService) ProbeOCSPConsistency(ctx context.Context) (ConsistencyCounts, error) {
+	var counts ConsistencyCounts
+
+	candidates, err := s.db.GetRecentRevoked(ctx, ocspProbeCandidates)
+	if err != nil {
+		return counts, fmt.Errorf("error loading recent revocations: %v", err)
+	}
+	rand.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+	if len(candidates) > s.cfg.OCSPProbeSampleSize {
+		candidates = candidates[:s.cfg.OCSPProbeSampleSize]
+	}
+
+	issuers, err := s.ocspIssuers(ctx)
+	if err != nil {
+		return counts, err
+	}
+
+	var mismatches []ConsistencyMismatch
+	for _, cert := range candidates {
+		if ctx.Err() != nil {
+			return counts, ctx.Err()
+		}
+
+		issuer, ok := issuers[cert.CertificateAuthority]
+		if !ok {
+			counts.Skipped++
+			continue
+		}
+		ocspURL := issuer.OCSPServer[0]
+
+		resp, err := s.queryOCSP(ctx, ocspURL, issuer, cert.Serial)
+		if err != nil {
+			log.Printf("Error querying OCSP responder %s for serial %s: %v", ocspURL, cert.Serial, err)
+			counts.Failed++
+			continue
+		}
+
+		counts.Checked++
+		field, expected, got, agree := compareOCSPStatus(cert, resp)
+		if agree {
+			counts.Agreed++
+			continue
+		}
+
+		counts.Mismatched++
+		log.Printf("OCSP consistency mismatch for serial %s of %s: %s is %s in the CRL but %s in OCSP (%s)",
+			cert.Serial, cert.CertificateAuthority, field, expected, got, ocspURL)
+		if s.redis != nil {
+			s.redis.IncrementStats("stats:ocsp_mismatches")
+		}
+		mismatches = append(mismatches, ConsistencyMismatch{
+			Serial:               cert.Serial,
+			CertificateAuthority: cert.CertificateAuthority,
+			OCSPURL:              ocspURL,
+			Field:                field,
+			Expected:             expected,
+			OCSP:                 got,
+			DetectedAt:           time.Now(),
+		})
+	}
+
+	s.recordConsistency(counts, mismatches)
+	return counts, nil
+}
+
+// ConsistencyReport devuelve una copia del informe de las comparaciones con OCSP
+func (s *CRLService) ConsistencyReport() ConsistencyReport {
+	s.consistencyMu.Lock()
+	defer s.consistencyMu.Unlock()
+
+	report := s.consistency
+	report.Enabled = s.cfg.OCSPProbeCron != ""
+	report.RecentMismatches = append([]ConsistencyMismatch{}, s.consistency.RecentMismatches...)
+	return report
+}
+
+// recordConsistency suma la ejecución al informe y antepone sus discrepancias a las recientes
+func (s *CRLService) recordConsistency(counts ConsistencyCounts, mismatches []ConsistencyMismatch) {
+	s.consistencyMu.Lock()
+	defer s.consistencyMu.Unlock()
+
+	now := time.Now()
+	s.consistency.LastRun = &now
+	s.consistency.LastRunCounts = counts
+	s.consistency.Totals.add(counts)
+
+	for i, j := 0, len(mismatches)-1; i < j; i, j = i+1, j-1 {
+		mismatches[i], mismatches[j] = mismatches[j], mismatches[i]
+	}
+	recent := append(mismatches, s.consistency.RecentMismatches...)
+	if len(recent) > maxConsistencyMismatches {
+		recent = recent[:maxConsistencyMismatches]
+	}
+	s.consistency.RecentMismatches = recent
+}
+
+// ocspIssuers devuelve los certificados de CA registrados que anuncian un responder OCSP en su
+// AIA, por nombre de CA. Si una CA tiene varios se usa el de vencimiento más lejano.
+func (s *CRLService) ocspIssuers(ctx context.Context) (map[string]*x509.Certificate, error) {
+	cas, err := s.db.ListCACertificates(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error loading CA certificates: %v", err)
+	}
+
+	issuers := make(map[string]*x509.Certificate, len(cas))
+	for _, ca := range cas {
+		cert, err := x509.ParseCertificate(ca.DER)
+		if err != nil || len(cert.OCSPServer) == 0 {
+			continue
+		}
+		name := s.extractIssuerName(cert.Subject)
+		if current, ok := issuers[name]; !ok || cert.NotAfter.After(current.NotAfter) {
+			issuers[name] = cert
+		}
+	}
+	return issuers, nil
+}
+
+// queryOCSP consulta por POST el estado del serial (en decimal) al responder y devuelve la
+// respuesta verificada con el certificado de la CA, firmada por ella o por un responder
+// delegado que ella emitió
+func (s *CRLService) queryOCSP(ctx context.Context, ocspURL string, issuer *x509.Certificate, serial string) (*ocsp.Response, error) {
+	serialNumber, ok := new(big.Int).SetString(serial, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid serial %q", serial)
+	}
+	subject := &x509.Certificate{SerialNumber: serialNumber}
+
+	parsedURL, err := url.Parse(ocspURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OCSP URL: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, ocspProbeTimeout)
+	defer cancel()
+
+	if err := s.waitForHost(ctx, parsedURL); err != nil {
+		return nil, err
+	}
+
+	body, err := ocsp.CreateRequest(subject, issuer, &ocsp.RequestOptions{Hash: crypto.SHA1})
+	if err != nil {
+		return nil, fmt.Errorf("error creating OCSP request: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", parsedURL.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/ocsp-request")
+	req.Header.Set("Accept", "application/ocsp-response")
+	req.Header.Set("User-Agent", "SignerFlow-CRL-Service/1.0")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to OCSP responder: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP error: %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, ocspProbeMaxResponse))
+	if err != nil {
+		return nil, fmt.Errorf("error reading OCSP response: %v", err)
+	}
+
+	return ocsp.ParseResponseForCert(data, subject, issuer)
+}
+
+// compareOCSPStatus compara la revocación importada con la respuesta OCSP. Si no coinciden
+// devuelve el primer campo distinto con su valor en la CRL y en OCSP. Las fechas se comparan
+// con precisión de segundos, la de ambos formatos.
+func compareOCSPStatus(cert *models.RevokedCertificate, resp *ocsp.Response) (field, expected, got string, agree bool) {
+	if resp.Status != ocsp.Revoked {
+		return "status", models.RevokedStatus(cert.Reason), ocspStatusName(resp.Status), false
+	}
+
+	expectedDate := cert.RevocationDate.UTC().Truncate(time.Second)
+	ocspDate := resp.RevokedAt.UTC().Truncate(time.Second)
+	if !expectedDate.Equal(ocspDate) {
+		return "revocation_date", expectedDate.Format(time.RFC3339), ocspDate.Format(time.RFC3339), false
+	}
+
+	if resp.RevocationReason != cert.Reason {
+		return "reason", strconv.Itoa(cert.Reason), strconv.Itoa(resp.RevocationReason), false
+	}
+
+	return "", "", "", true
+}
+
+// ocspStatusName es el nombre del estado OCSP en los términos de CertificateStatus
+func ocspStatusName(status int) string {
+	switch status {
+	case ocsp.Good:
+		return models.StatusGood
+	case ocsp.Revoked:
+		return models.StatusRevoked
+	case ocsp.Unknown:
+		return "unknown"
+	default:
+		return "server_failed"
+	}
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+	"signerflow-crl/config"
+	"signerflow-crl/database"
+	"signerflow-crl/models"
+)
+
+// withOCSP vuelve a emitir el certificado de la CA, con el mismo subject y la misma clave,
+// anunciando ocspURL en su AIA
+func (ca *testCA) withOCSP(t *testing.T, ocspURL string) []byte {
+	t.Helper()
+
+	template := &x509.Certificate{
+		SerialNumber:          ca.cert.SerialNumber,
+		RawSubject:            ca.cert.RawSubject,
+		NotBefore:             ca.cert.NotBefore,
+		NotAfter:              ca.cert.NotAfter,
+		KeyUsage:              ca.cert.KeyUsage,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		OCSPServer:            []string{ocspURL},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, ca.key.Public(), ca.key)
+	if err != nil {
+		t.Fatalf("creating CA certificate: %v", err)
+	}
+	return der
+}
+
+// newStubOCSPResponder responde con la CA el estado configurado para cada serial, o un error
+// HTTP 500 para los que no tienen estado
+func newStubOCSPResponder(t *testing.T, ca *testCA, statuses map[int64]ocsp.Response) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		request, err := ocsp.ParseRequest(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		template, ok := statuses[request.SerialNumber.Int64()]
+		if !ok {
+			http.Error(w, "responder failure", http.StatusInternalServerError)
+			return
+		}
+		template.SerialNumber = request.SerialNumber
+		template.ThisUpdate = time.Now().Add(-time.Minute)
+		template.NextUpdate = time.Now().Add(time.Hour)
+		der, err := ocsp.CreateResponse(ca.cert, ca.cert, template, ca.key)
+		if err != nil {
+			t.Errorf("creating OCSP response: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		w.Write(der)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestProbeOCSPConsistency(t *testing.T) {
+	ctx := context.Background()
+	redis, redisServer := newTestRedis(t)
+	service := newCachedTestService(t, database.NewMemoryStore(), redis, func(cfg *config.Config) {
+		cfg.CRLPerHostRate = 0
+		cfg.OCSPProbeSampleSize = 10
+	})
+
+	revokedAt := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	ca := newTestCA(t, "OCSP Probe CA")
+	withoutOCSP := newTestCA(t, "OCSP Probe CA Without AIA")
+	for _, crl := range [][]byte{
+		ca.crl(t, 1, []x509.RevocationListEntry{
+			revoked(6101, models.ReasonKeyCompromise, revokedAt),
+			revoked(6102, models.ReasonKeyCompromise, revokedAt),
+			revoked(6103, models.ReasonKeyCompromise, revokedAt),
+			revoked(6104, models.ReasonKeyCompromise, revokedAt),
+			revoked(6105, models.ReasonKeyCompromise, revokedAt),
+		}),
+		withoutOCSP.crl(t, 1, []x509.RevocationListEntry{revoked(6201, models.ReasonKeyCompromise, revokedAt)}),
+	} {
+		if err := service.ProcessSingleCRL(ctx, newCRLServer(t, crl).URL); err != nil {
+			t.Fatalf("ProcessSingleCRL: %v", err)
+		}
+	}
+
+	// 6101 coincide, 6102 a 6104 discrepan en un campo cada uno y el responder falla con 6105
+	responder := newStubOCSPResponder(t, ca, map[int64]ocsp.Response{
+		6101: {Status: ocsp.Revoked, RevokedAt: revokedAt, RevocationReason: ocsp.KeyCompromise},
+		6102: {Status: ocsp.Good},
+		6103: {Status: ocsp.Revoked, RevokedAt: revokedAt.Add(-24 * time.Hour), RevocationReason: ocsp.KeyCompromise},
+		6104: {Status: ocsp.Revoked, RevokedAt: revokedAt, RevocationReason: ocsp.Superseded},
+	})
+	if _, err := service.AddCACertificate(ctx, ca.withOCSP(t, responder.URL)); err != nil {
+		t.Fatalf("AddCACertificate: %v", err)
+	}
+
+	counts, err := service.ProbeOCSPConsistency(ctx)
+	if err != nil {
+		t.Fatalf("ProbeOCSPConsistency: %v", err)
+	}
+	want := ConsistencyCounts{Checked: 4, Agreed: 1, Mismatched: 3, Skipped: 1, Failed: 1}
+	if counts != want {
+		t.Errorf("got counts %+v, want %+v", counts, want)
+	}
+
+	report := service.ConsistencyReport()
+	if report.Enabled || report.LastRun == nil || report.LastRunCounts != want || report.Totals != want {
+		t.Errorf("got report %+v, want a disabled probe with one run of %+v", report, want)
+	}
+	mismatches := make(map[string]ConsistencyMismatch)
+	for _, mismatch := range report.RecentMismatches {
+		mismatches[mismatch.Serial] = mismatch
+	}
+	for serial, want := range map[string]struct{ field, expected, ocsp string }{
+		"6102": {"status", models.StatusRevoked, models.StatusGood},
+		"6103": {"revocation_date", revokedAt.Format(time.RFC3339), revokedAt.Add(-24 * time.Hour).Format(time.RFC3339)},
+		"6104": {"reason", "1", "4"},
+	} {
+		got, ok := mismatches[serial]
+		if !ok {
+			t.Errorf("serial %s: no mismatch reported", serial)
+			continue
+		}
+		if got.Field != want.field || got.Expected != want.expected || got.OCSP != want.ocsp {
+			t.Errorf("serial %s: got %s %q in the CRL and %q in OCSP, want %s %q and %q",
+				serial, got.Field, got.Expected, got.OCSP, want.field, want.expected, want.ocsp)
+		}
+		if got.CertificateAuthority != "OCSP Probe CA" || got.OCSPURL != responder.URL {
+			t.Errorf("serial %s: got CA %q and OCSP URL %q, want OCSP Probe CA at %s",
+				serial, got.CertificateAuthority, got.OCSPURL, responder.URL)
+		}
+	}
+	if len(mismatches) != 3 {
+		t.Errorf("got %d mismatches, want 3", len(mismatches))
+	}
+	if value, _ := redisServer.Get("stats:ocsp_mismatches"); value != "3" {
+		t.Errorf("got stats:ocsp_mismatches %q, want 3", value)
+	}
+
+	// Los totales y las discrepancias recientes se acumulan entre ejecuciones
+	if _, err := service.ProbeOCSPConsistency(ctx); err != nil {
+		t.Fatalf("ProbeOCSPConsistency: %v", err)
+	}
+	report = service.ConsistencyReport()
+	if report.Totals.Mismatched != 6 || report.Totals.Agreed != 2 || len(report.RecentMismatches) != 6 {
+		t.Errorf("got totals %+v with %d recent mismatches after two runs, want 6 mismatched and 2 agreed",
+			report.Totals, len(report.RecentMismatches))
+	}
+}
+
+func TestProbeOCSPConsistencySamplesRecentRevocations(t *testing.T) {
+	ctx := context.Background()
+	service := newTestService(t, database.NewMemoryStore(), func(cfg *config.Config) {
+		cfg.CRLPerHostRate = 0
+		cfg.OCSPProbeSampleSize = 3
+		cfg.OCSPProbeCron = "@hourly"
+	})
+
+	ca := newTestCA(t, "OCSP Sample CA")
+	if err := service.ProcessSingleCRL(ctx, newCRLServer(t, ca.crl(t, 1, revokedRange(6301, 20))).URL); err != nil {
+		t.Fatalf("ProcessSingleCRL: %v", err)
+	}
+	statuses := make(map[int64]ocsp.Response)
+	for serial := int64(6301); serial < 6321; serial++ {
+		statuses[serial] = ocsp.Response{Status: ocsp.Good}
+	}
+	responder := newStubOCSPResponder(t, ca, statuses)
+	if _, err := service.AddCACertificate(ctx, ca.withOCSP(t, responder.URL)); err != nil {
+		t.Fatalf("AddCACertificate: %v", err)
+	}
+
+	// Solo se consulta la muestra configurada
+	counts, err := service.ProbeOCSPConsistency(ctx)
+	if err != nil {
+		t.Fatalf("ProbeOCSPConsistency: %v", err)
+	}
+	if counts.Checked != 3 || counts.Mismatched != 3 {
+		t.Errorf("got counts %+v, want a sample of 3 mismatched revocations", counts)
+	}
+	if !service.ConsistencyReport().Enabled {
+		t.Error("report does not show the probe as enabled with OCSP_PROBE_CRON set")
+	}
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+	"signerflow-crl/database"
+	"signerflow-crl/requestid"
+)
+
+// ocspResponseValidity es el intervalo anunciado en NextUpdate de cada respuesta
+const ocspResponseValidity = 1 * time.Hour
+
+// OCSPResponder responde consultas OCSP a partir de los datos de revocación de las CRLs
+type OCSPResponder struct {
+	crlService    *CRLService
+	db            database.CertStore
+	responderCert *x509.Certificate
+	signer        crypto.Signer
+	issuers       []*x509.Certificate
+}
+
+func NewOCSPResponder(crlService *CRLService, db database.CertStore, certFile, keyFile string, issuerFiles []string) (*OCSPResponder, error) {
+	responderCert, err := loadCertificate(certFile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading OCSP responder certificate: %v", err)
+	}
+
+	signer, err := loadSigner(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading OCSP responder key: %v", err)
+	}
+
+	if len(issuerFiles) == 0 {
+		return nil, fmt.Errorf("at least one OCSP issuer certificate is required")
+	}
+
+	issuers := make([]*x509.Certificate, 0, len(issuerFiles))
+	for _, file := range issuerFiles {
+		issuer, err := loadCertificate(file)
+		if err != nil {
+			return nil, fmt.Errorf("error loading OCSP issuer certificate %s: %v", file, err)
+		}
+		issuers = append(issuers, issuer)
+	}
+
+	log.Printf("OCSP responder enabled for %d issuers", len(issuers))
+	return &OCSPResponder{
+		crlService:    crlService,
+		db:            db,
+		responderCert: responderCert,
+		signer:        signer,
+		issuers:       issuers,
+	}, nil
+}
+
+// Respond procesa una petición OCSP en DER y devuelve la respuesta firmada.
+// Las peticiones inválidas o de emisores no configurados reciben respuestas de error OCSP.
+func (r *OCSPResponder) Respond(ctx context.Context, requestBytes []byte) ([]byte, error) {
+	req, err := ocsp.ParseRequest(requestBytes)
+	if err != nil {
+		return ocsp.MalformedRequestErrorResponse, nil
+	}
+
+	issuer := r.findIssuer(req)
+	if issuer == nil {
+		return ocsp.UnauthorizedErrorResponse, nil
+	}
+
+	now := time.Now().UTC().Truncate(time.Minute)
+	template := ocsp.Response{
+		SerialNumber: req.SerialNumber,
+		ThisUpdate:   now,
+		NextUpdate:   now.Add(ocspResponseValidity),
+		IssuerHash:   req.HashAlgorithm,
+		Status:       ocsp.Good,
+	}
+	if !r.responderCert.Equal(issuer) {
+		template.Certificate = r.responderCert
+	}
+
+	issuerName := r.crlService.extractIssuerName(issuer.Subject)
+	tracked, err := r.db.HasCRLForIssuer(ctx, issuerName)
+	if err != nil {
+		requestid.Logf(ctx, "Error checking OCSP issuer %s: %v", issuerName, err)
+		return ocsp.InternalErrorErrorResponse, nil
+	}
+
+	if !tracked {
+		template.Status = ocsp.Unknown
+	} else {
+		// La consulta se limita al emisor pedido: sin CA devolvería la revocación más reciente
+		// del serial entre todas las CAs, que puede ser de otra
+		status, err := r.crlService.CheckCertificateStatusForCA(ctx, req.SerialNumber.String(), issuerName)
+		if err != nil {
+			requestid.Logf(ctx, "Error checking OCSP certificate status: %v", err)
+			return ocsp.InternalErrorErrorResponse, nil
+		}
+
+		if status.IsRevoked {
+			template.Status = ocsp.Revoked
+			if status.RevocationDate != nil {
+				template.RevokedAt = *status.RevocationDate
+			}
+			if status.ReasonCode != nil {
+				template.RevocationReason = *status.ReasonCode
+			}
+		}
+	}
+
+	return ocsp.CreateResponse(issuer, r.responderCert, template, r.signer)
+}
+
+// findIssuer busca el emisor configurado cuyos hashes de nombre y clave coinciden con la petición
+func (r *OCSPResponder) findIssuer(req *ocsp.Request) *x509.Certificate {
+	if !req.HashAlgorithm.Available() {
+		return nil
+	}
+
+	for _, issuer := range r.issuers {
+		var spki struct {
+			Algorithm pkix.AlgorithmIdentifier
+			PublicKey asn1.BitString
+		}
+		if _, err := asn1.Unmarshal(issuer.RawSubjectPublicKeyInfo, &spki); err != nil {
+			continue
+		}
+
+		nameHash := req.HashAlgorithm.New()
+		nameHash.Write(issuer.RawSubject)
+		keyHash := req.HashAlgorithm.New()
+		keyHash.Write(spki.PublicKey.RightAlign())
+
+		if bytes.Equal(nameHash.Sum(nil), req.IssuerNameHash) && bytes.Equal(keyHash.Sum(nil), req.IssuerKeyHash) {
+			return issuer
+		}
+	}
+
+	return nil
+}
+
+func loadCertificate(file string) (*x509.Certificate, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	if block, _ := pem.Decode(data); block != nil {
+		data = block.Bytes
+	}
+
+	return x509.ParseCertificate(data)
+}
+
+func loadSigner(file string) (crypto.Signer, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type %T", key)
+		}
+		return signer, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("unsupported private key format")
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+	"signerflow-crl/config"
+	"signerflow-crl/database"
+	"signerflow-crl/models"
+)
+
+// writePEM guarda der en un archivo PEM temporal y devuelve su ruta
+func writePEM(t *testing.T, blockType string, der []byte) string {
+	t.Helper()
+
+	file, err := os.CreateTemp(t.TempDir(), "*.pem")
+	if err != nil {
+		t.Fatalf("creating PEM file: %v", err)
+	}
+	defer file.Close()
+	if err := pem.Encode(file, &pem.Block{Type: blockType, Bytes: der}); err != nil {
+		t.Fatalf("writing PEM file: %v", err)
+	}
+	return filepath.Clean(file.Name())
+}
+
+// certFile guarda el certificado de la CA en PEM
+func (ca *testCA) certFile(t *testing.T) string {
+	return writePEM(t, "CERTIFICATE", ca.cert.Raw)
+}
+
+// keyFile guarda la clave privada de la CA en PKCS#8
+func (ca *testCA) keyFile(t *testing.T) string {
+	der, err := x509.MarshalPKCS8PrivateKey(ca.key)
+	if err != nil {
+		t.Fatalf("encoding CA key: %v", err)
+	}
+	return writePEM(t, "PRIVATE KEY", der)
+}
+
+func TestOCSPResponder(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	service := newTestService(t, store)
+
+	tracked := newTestCA(t, "OCSP Tracked CA")
+	untracked := newTestCA(t, "OCSP Untracked CA")
+	unconfigured := newTestCA(t, "OCSP Unconfigured CA")
+	revokedAt := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+
+	srv := newCRLServer(t, tracked.crl(t, 1, []x509.RevocationListEntry{
+		revoked(6001, models.ReasonKeyCompromise, revokedAt),
+	}))
+	if err := service.ProcessSingleCRL(ctx, srv.URL); err != nil {
+		t.Fatalf("ProcessSingleCRL: %v", err)
+	}
+
+	// La CA rastreada firma sus propias respuestas
+	responder, err := NewOCSPResponder(service, store, tracked.certFile(t), tracked.keyFile(t),
+		[]string{tracked.certFile(t), untracked.certFile(t)})
+	if err != nil {
+		t.Fatalf("NewOCSPResponder: %v", err)
+	}
+
+	// verifier es el emisor con el que se verifica la respuesta; nil solo comprueba la firma
+	// con el certificado del responder incluido en ella
+	query := func(ca *testCA, serial int64, verifier *x509.Certificate) (*ocsp.Response, error) {
+		t.Helper()
+		request, err := ocsp.CreateRequest(ca.issue(t, serial), ca.cert, &ocsp.RequestOptions{Hash: crypto.SHA256})
+		if err != nil {
+			t.Fatalf("creating OCSP request: %v", err)
+		}
+		der, err := responder.Respond(ctx, request)
+		if err != nil {
+			t.Fatalf("Respond: %v", err)
+		}
+		return ocsp.ParseResponse(der, verifier)
+	}
+
+	resp, err := query(tracked, 6001, tracked.cert)
+	if err != nil {
+		t.Fatalf("parsing response for revoked serial: %v", err)
+	}
+	if resp.Status != ocsp.Revoked || resp.RevocationReason != ocsp.KeyCompromise || !resp.RevokedAt.Equal(revokedAt) {
+		t.Errorf("revoked serial: got status %d reason %d at %v, want revoked for key compromise at %v",
+			resp.Status, resp.RevocationReason, resp.RevokedAt, revokedAt)
+	}
+
+	resp, err = query(tracked, 6002, tracked.cert)
+	if err != nil {
+		t.Fatalf("parsing response for good serial: %v", err)
+	}
+	if resp.Status != ocsp.Good {
+		t.Errorf("good serial: got status %d, want good", resp.Status)
+	}
+
+	// Emisor configurado pero sin CRL importada: no se puede afirmar que el serial es válido
+	resp, err = query(untracked, 6001, nil)
+	if err != nil {
+		t.Fatalf("parsing response for untracked issuer: %v", err)
+	}
+	if resp.Status != ocsp.Unknown {
+		t.Errorf("untracked issuer: got status %d, want unknown", resp.Status)
+	}
+
+	_, err = query(unconfigured, 6001, nil)
+	var responseErr ocsp.ResponseError
+	if !errors.As(err, &responseErr) || responseErr.Status != ocsp.Unauthorized {
+		t.Errorf("unconfigured issuer: got error %v, want an unauthorized OCSP response", err)
+	}
+}
+
+func TestOCSPResponderSameSerialUnderTwoCAs(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	service := newTestService(t, store, func(cfg *config.Config) {
+		cfg.CRLPerHostRate = 0
+	})
+
+	first, second := newTestCA(t, "OCSP First CA"), newTestCA(t, "OCSP Second CA")
+	firstRevokedAt := time.Now().Add(-2 * time.Hour).UTC().Truncate(time.Second)
+	secondRevokedAt := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	// La segunda CA revocó después el mismo serial, y solo ella revocó 6102
+	firstSrv := newCRLServer(t, first.crl(t, 1, []x509.RevocationListEntry{
+		revoked(6101, models.ReasonKeyCompromise, firstRevokedAt),
+	}))
+	secondSrv := newCRLServer(t, second.crl(t, 1, []x509.RevocationListEntry{
+		revoked(6101, models.ReasonSuperseded, secondRevokedAt),
+		revoked(6102, models.ReasonSuperseded, secondRevokedAt),
+	}))
+	for _, url := range []string{firstSrv.URL, secondSrv.URL} {
+		if err := service.ProcessSingleCRL(ctx, url); err != nil {
+			t.Fatalf("ProcessSingleCRL: %v", err)
+		}
+	}
+
+	responder, err := NewOCSPResponder(service, store, first.certFile(t), first.keyFile(t),
+		[]string{first.certFile(t), second.certFile(t)})
+	if err != nil {
+		t.Fatalf("NewOCSPResponder: %v", err)
+	}
+	query := func(ca *testCA, serial int64) *ocsp.Response {
+		t.Helper()
+		request, err := ocsp.CreateRequest(ca.issue(t, serial), ca.cert, &ocsp.RequestOptions{Hash: crypto.SHA256})
+		if err != nil {
+			t.Fatalf("creating OCSP request: %v", err)
+		}
+		der, err := responder.Respond(ctx, request)
+		if err != nil {
+			t.Fatalf("Respond: %v", err)
+		}
+		resp, err := ocsp.ParseResponse(der, nil)
+		if err != nil {
+			t.Fatalf("parsing response: %v", err)
+		}
+		return resp
+	}
+
+	// Cada emisor responde con su propia revocación, no con la más reciente del serial
+	if resp := query(first, 6101); resp.Status != ocsp.Revoked || resp.RevocationReason != ocsp.KeyCompromise || !resp.RevokedAt.Equal(firstRevokedAt) {
+		t.Errorf("first CA: got status %d reason %d at %v, want revoked for key compromise at %v",
+			resp.Status, resp.RevocationReason, resp.RevokedAt, firstRevokedAt)
+	}
+	if resp := query(second, 6101); resp.Status != ocsp.Revoked || resp.RevocationReason != ocsp.Superseded || !resp.RevokedAt.Equal(secondRevokedAt) {
+		t.Errorf("second CA: got status %d reason %d at %v, want revoked as superseded at %v",
+			resp.Status, resp.RevocationReason, resp.RevokedAt, secondRevokedAt)
+	}
+	if resp := query(first, 6102); resp.Status != ocsp.Good {
+		t.Errorf("serial revoked only by the second CA: got status %d from the first CA, want good", resp.Status)
+	}
+}
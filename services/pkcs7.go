@@ -0,0 +1,72 @@
+package services
+
+import (
+	"encoding/asn1"
+	"errors"
+	"fmt"
+)
+
+// oidSignedData identifica el contenido SignedData de PKCS#7/CMS (RFC 5652)
+var oidSignedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+
+// pkcs7ContentInfo es el ContentInfo externo de un mensaje PKCS#7
+type pkcs7ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"optional,tag:0"`
+}
+
+var errNotPKCS7 = errors.New("data is not a PKCS#7 SignedData structure")
+
+// extractPKCS7CRLs devuelve en DER las CRLs del campo crls ([1] IMPLICIT) de un SignedData.
+// No verifica la firma del contenedor: cada CRL lleva su propia firma.
+func extractPKCS7CRLs(data []byte) ([][]byte, error) {
+	var contentInfo pkcs7ContentInfo
+	if rest, err := asn1.Unmarshal(data, &contentInfo); err != nil || len(rest) > 0 {
+		return nil, errNotPKCS7
+	}
+	if !contentInfo.ContentType.Equal(oidSignedData) {
+		return nil, errNotPKCS7
+	}
+
+	// content es [0] EXPLICIT, por lo que su contenido es el SEQUENCE de SignedData
+	var signedData asn1.RawValue
+	if _, err := asn1.Unmarshal(contentInfo.Content.Bytes, &signedData); err != nil {
+		return nil, fmt.Errorf("invalid PKCS#7 SignedData: %v", err)
+	}
+	if signedData.Class != asn1.ClassUniversal || signedData.Tag != asn1.TagSequence {
+		return nil, fmt.Errorf("invalid PKCS#7 SignedData: expected SEQUENCE")
+	}
+
+	// Los campos de SignedData se recorren en orden buscando el campo opcional crls
+	fields := signedData.Bytes
+	for len(fields) > 0 {
+		var field asn1.RawValue
+		rest, err := asn1.Unmarshal(fields, &field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PKCS#7 SignedData: %v", err)
+		}
+		fields = rest
+
+		if field.Class != asn1.ClassContextSpecific || field.Tag != 1 {
+			continue
+		}
+
+		var crls [][]byte
+		entries := field.Bytes
+		for len(entries) > 0 {
+			var crl asn1.RawValue
+			rest, err := asn1.Unmarshal(entries, &crl)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CRL in PKCS#7 SignedData: %v", err)
+			}
+			crls = append(crls, crl.FullBytes)
+			entries = rest
+		}
+		if len(crls) == 0 {
+			break
+		}
+		return crls, nil
+	}
+
+	return nil, fmt.Errorf("PKCS#7 SignedData does not contain any CRL")
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"testing"
+	"time"
+
+	"signerflow-crl/database"
+	"signerflow-crl/models"
+)
+
+var oidData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+
+// wrapPKCS7 arma un SignedData sin firmantes con los certificados y CRLs dados, como el que
+// publican algunas CAs en lugar de la CRL en DER
+func wrapPKCS7(t *testing.T, certs [][]byte, crls [][]byte) []byte {
+	t.Helper()
+
+	mustMarshal := func(v interface{}) []byte {
+		der, err := asn1.Marshal(v)
+		if err != nil {
+			t.Fatalf("marshaling PKCS#7: %v", err)
+		}
+		return der
+	}
+	concat := func(parts [][]byte) []byte {
+		var out []byte
+		for _, part := range parts {
+			out = append(out, part...)
+		}
+		return out
+	}
+
+	fields := [][]byte{
+		mustMarshal(1),
+		mustMarshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true}),
+		mustMarshal(struct{ ContentType asn1.ObjectIdentifier }{oidData}),
+	}
+	if len(certs) > 0 {
+		fields = append(fields, mustMarshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: concat(certs)}))
+	}
+	if len(crls) > 0 {
+		fields = append(fields, mustMarshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 1, IsCompound: true, Bytes: concat(crls)}))
+	}
+	fields = append(fields, mustMarshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true}))
+
+	signedData := mustMarshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSequence, IsCompound: true, Bytes: concat(fields)})
+	return mustMarshal(pkcs7ContentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: signedData},
+	})
+}
+
+func TestProcessSingleCRLWrappedInPKCS7(t *testing.T) {
+	ca := newTestCA(t, "PKCS7 Test CA")
+	crl := ca.crl(t, 1, []x509.RevocationListEntry{
+		revoked(8001, models.ReasonKeyCompromise, time.Now().Add(-time.Hour)),
+	})
+	// El certificado de la CA precede a las CRLs y debe ignorarse
+	wrapped := wrapPKCS7(t, [][]byte{ca.cert.Raw}, [][]byte{crl})
+
+	tests := []struct {
+		name        string
+		body        []byte
+		contentType string
+	}{
+		{"DER", wrapped, "application/pkcs7-mime"},
+		{"DER with generic content type", wrapped, "application/octet-stream"},
+		{"PEM", pem.EncodeToMemory(&pem.Block{Type: "PKCS7", Bytes: wrapped}), "application/x-pem-file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			service := newTestService(t, database.NewMemoryStore())
+			srv := newCRLServer(t, tt.body)
+			srv.contentType = tt.contentType
+
+			if err := service.ProcessSingleCRL(ctx, srv.URL); err != nil {
+				t.Fatalf("ProcessSingleCRL: %v", err)
+			}
+			status, err := service.CheckCertificateStatus(ctx, "8001")
+			if err != nil {
+				t.Fatalf("CheckCertificateStatus: %v", err)
+			}
+			if !status.IsRevoked || status.CertificateAuthority == nil || *status.CertificateAuthority != "PKCS7 Test CA" {
+				t.Errorf("got status %+v, want revoked by PKCS7 Test CA", status)
+			}
+		})
+	}
+}
+
+func TestExtractPKCS7CRLs(t *testing.T) {
+	ca := newTestCA(t, "PKCS7 Extract CA")
+	first := ca.crl(t, 1, nil)
+	second := ca.crl(t, 2, nil)
+
+	crls, err := extractPKCS7CRLs(wrapPKCS7(t, nil, [][]byte{first, second}))
+	if err != nil {
+		t.Fatalf("extractPKCS7CRLs: %v", err)
+	}
+	if len(crls) != 2 || string(crls[0]) != string(first) || string(crls[1]) != string(second) {
+		t.Errorf("got %d CRLs, want the 2 wrapped ones in order", len(crls))
+	}
+
+	if _, err := extractPKCS7CRLs(first); !errors.Is(err, errNotPKCS7) {
+		t.Errorf("bare CRL: got %v, want errNotPKCS7", err)
+	}
+	if _, err := extractPKCS7CRLs(wrapPKCS7(t, [][]byte{ca.cert.Raw}, nil)); err == nil {
+		t.Error("SignedData without CRLs was accepted")
+	}
+}
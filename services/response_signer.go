@@ -0,0 +1,90 @@
+package services
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+)
+
+// ResponseSigner firma los cuerpos de respuesta con una clave Ed25519 o ECDSA para que
+// los clientes puedan verificarlos con la clave pública publicada
+type ResponseSigner struct {
+	signer       crypto.Signer
+	hash         crypto.Hash
+	algorithm    string
+	keyID        string
+	publicKeyPEM string
+}
+
+// SignerPublicKey describe la clave pública con la que se verifican las respuestas firmadas
+type SignerPublicKey struct {
+	KeyID     string `json:"key_id"`
+	Algorithm string `json:"algorithm"`
+	PublicKey string `json:"public_key"`
+}
+
+// NewResponseSigner carga la clave privada PEM (PKCS#8 o EC) del archivo indicado
+func NewResponseSigner(keyFile string) (*ResponseSigner, error) {
+	signer, err := loadSigner(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading response signing key: %v", err)
+	}
+
+	r := &ResponseSigner{signer: signer}
+	switch key := signer.Public().(type) {
+	case ed25519.PublicKey:
+		r.algorithm = "Ed25519"
+	case *ecdsa.PublicKey:
+		r.algorithm = "ECDSA-SHA256"
+		r.hash = crypto.SHA256
+	default:
+		return nil, fmt.Errorf("unsupported response signing key type %T, use Ed25519 or ECDSA", key)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		return nil, fmt.Errorf("error encoding response signing public key: %v", err)
+	}
+
+	// El key_id son los primeros 8 bytes del SHA-256 de la clave pública en DER
+	sum := sha256.Sum256(der)
+	r.keyID = hex.EncodeToString(sum[:8])
+	r.publicKeyPEM = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+
+	return r, nil
+}
+
+// Sign devuelve en base64 la firma de body: Ed25519 sobre los bytes o ECDSA (ASN.1) sobre su SHA-256
+func (r *ResponseSigner) Sign(body []byte) (string, error) {
+	digest := body
+	if r.hash != 0 {
+		h := r.hash.New()
+		h.Write(body)
+		digest = h.Sum(nil)
+	}
+
+	signature, err := r.signer.Sign(rand.Reader, digest, r.hash)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(signature), nil
+}
+
+func (r *ResponseSigner) KeyID() string {
+	return r.keyID
+}
+
+func (r *ResponseSigner) PublicKey() SignerPublicKey {
+	return SignerPublicKey{
+		KeyID:     r.keyID,
+		Algorithm: r.algorithm,
+		PublicKey: r.publicKeyPEM,
+	}
+}
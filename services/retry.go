@@ -0,0 +1,246 @@
+package services
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+	"signerflow-crl/models"
+	"signerflow-crl/requestid"
+)
+
+// maxRetryAfter limita la espera indicada por el servidor para no bloquear el ciclo de procesamiento
+const maxRetryAfter = 60 * time.Second
+
+// retryableError marca fallos transitorios (red o 5xx) que vale la pena reintentar
+type retryableError struct {
+	err        error
+	retryAfter time.Duration
+}
+
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryableError) Unwrap() error {
+	return e.err
+}
+
+// downloadCRL descarga la CRL pasando por el cache en disco si está configurado: una copia
+// dentro de la ventana de frescura evita la descarga, y si la descarga falla por un error
+// transitorio se usa la última copia guardada, con el error en fallbackErr
+func (s *CRLService) downloadCRL(ctx context.Context, crlURL string, validators *models.CRLValidators) (*crlDownload, error) {
+	if s.diskCache == nil {
+		return s.downloadWithRetry(ctx, crlURL, validators)
+	}
+
+	if entry, ok := s.diskCache.fresh(crlURL); ok {
+		log.Printf("Using cached copy of CRL %s fetched at %s", crlURL, entry.FetchedAt.Format(time.RFC3339))
+		return entry.download(validators), nil
+	}
+
+	download, err := s.downloadWithRetry(ctx, crlURL, validators)
+	if err != nil {
+		var retryErr *retryableError
+		if !errors.As(err, &retryErr) || ctx.Err() != nil {
+			return nil, err
+		}
+		entry, ok := s.diskCache.load(crlURL)
+		if !ok {
+			return nil, err
+		}
+		log.Printf("Warning: download of CRL %s failed (%v), using cached copy fetched at %s", crlURL, err, entry.FetchedAt.Format(time.RFC3339))
+		download := entry.download(validators)
+		download.fallbackErr = err
+		return download, nil
+	}
+
+	if download.notModified {
+		s.diskCache.touch(crlURL)
+	} else {
+		s.diskCache.store(crlURL, download)
+	}
+	return download, nil
+}
+
+// downloadWithRetry descarga la CRL reintentando los fallos transitorios con backoff exponencial y jitter
+// y se interrumpe en cuanto ctx se cancela
+func (s *CRLService) downloadWithRetry(ctx context.Context, crlURL string, validators *models.CRLValidators) (*crlDownload, error) {
+	attempts := s.cfg.DownloadAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		download, err := s.fetchCRL(ctx, crlURL, validators)
+		if err == nil {
+			return download, nil
+		}
+		lastErr = err
+
+		var retryErr *retryableError
+		if !errors.As(err, &retryErr) || attempt == attempts || ctx.Err() != nil {
+			break
+		}
+
+		delay := retryErr.retryAfter
+		if delay <= 0 {
+			delay = s.backoffDelay(attempt)
+		}
+
+		log.Printf("Attempt %d/%d downloading CRL %s failed: %v, retrying in %v", attempt, attempts, crlURL, err, delay)
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, lastErr
+}
+
+// sleepContext espera el tiempo indicado o hasta que ctx se cancele
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	if delay <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// backoffDelay calcula base * 2^(attempt-1) más un jitter de hasta el 50%
+func (s *CRLService) backoffDelay(attempt int) time.Duration {
+	delay := s.cfg.DownloadRetryDelay << (attempt - 1)
+	if delay <= 0 {
+		return 0
+	}
+	return delay + time.Duration(rand.Int63n(int64(delay)/2+1))
+}
+
+// parseRetryAfter interpreta el header Retry-After en segundos o como fecha HTTP
+func parseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+
+	var delay time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil {
+		delay = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(value); err == nil {
+		delay = time.Until(date)
+	}
+
+	if delay < 0 {
+		return 0
+	}
+	if delay > maxRetryAfter {
+		return maxRetryAfter
+	}
+	return delay
+}
+
+// Reintentos de las consultas de estado ante fallos transitorios de PostgreSQL, cortos para
+// no demorar la respuesta más que lo que tarda un cliente en reintentar
+const (
+	dbLookupAttempts   = 3
+	dbLookupRetryDelay = 100 * time.Millisecond
+)
+
+// ErrDatabaseUnavailable indica que PostgreSQL no respondió una consulta de estado tras los
+// reintentos; el estado no estaba en cache y se puede reintentar más tarde
+var ErrDatabaseUnavailable = errors.New("database unavailable")
+
+// isTransientDBError indica si el error de la base se debe a la conexión o a que el
+// servidor no acepta conexiones por el momento, y no a la consulta
+func isTransientDBError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		// 08: excepciones de conexión; 57P01-57P03: el servidor se está deteniendo o no
+		// acepta conexiones; 53300: demasiadas conexiones
+		switch {
+		case pqErr.Code.Class() == "08", pqErr.Code == "57P01", pqErr.Code == "57P02", pqErr.Code == "57P03", pqErr.Code == "53300":
+			return true
+		}
+		return false
+	}
+
+	// SQLite: la base está bloqueada por otra escritura más allá de busy_timeout
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		code := sqliteErr.Code() & 0xff
+		return code == sqlite3.SQLITE_BUSY || code == sqlite3.SQLITE_LOCKED
+	}
+
+	var netErr net.Error
+	var opErr *net.OpError
+	return errors.As(err, &netErr) || errors.As(err, &opErr)
+}
+
+// getStatusFromDB consulta el estado en la base reintentando los fallos transitorios. Si
+// siguen fallando devuelve ErrDatabaseUnavailable. ca vacío busca el serial en todas las CAs.
+func (s *CRLService) getStatusFromDB(ctx context.Context, serial, ca string) (*models.CertificateStatus, error) {
+	return s.retryStatusLookup(ctx, func() (*models.CertificateStatus, error) {
+		if ca == "" {
+			return s.db.GetCertificateStatus(ctx, serial)
+		}
+		return s.db.GetCertificateStatusByCA(ctx, serial, ca)
+	})
+}
+
+// getStatusAsOfFromDB es getStatusFromDB para el estado en la fecha asOf
+func (s *CRLService) getStatusAsOfFromDB(ctx context.Context, serial, ca string, asOf time.Time) (*models.CertificateStatus, error) {
+	return s.retryStatusLookup(ctx, func() (*models.CertificateStatus, error) {
+		return s.db.GetCertificateStatusAsOf(ctx, serial, ca, asOf)
+	})
+}
+
+// retryStatusLookup ejecuta la consulta reintentando los errores transitorios con backoff
+func (s *CRLService) retryStatusLookup(ctx context.Context, lookup func() (*models.CertificateStatus, error)) (*models.CertificateStatus, error) {
+	var lastErr error
+	for attempt := 1; attempt <= dbLookupAttempts; attempt++ {
+		status, err := lookup()
+		if err == nil {
+			return status, nil
+		}
+		lastErr = err
+
+		if !isTransientDBError(err) {
+			return nil, err
+		}
+		if attempt < dbLookupAttempts {
+			requestid.Logf(ctx, "Attempt %d/%d getting certificate status from database failed: %v", attempt, dbLookupAttempts, err)
+			if err := sleepContext(ctx, dbLookupRetryDelay<<(attempt-1)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %v", ErrDatabaseUnavailable, lastErr)
+}
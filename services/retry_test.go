@@ -0,0 +1,215 @@
+package services
+
+import (
+	"context"
+	"crypto/x509"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"signerflow-crl/config"
+	"signerflow-crl/database"
+	"signerflow-crl/models"
+)
+
+func TestDiskCacheFallbackCountsAsFailedAttempt(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	service := newTestService(t, store, func(cfg *config.Config) {
+		cfg.CRLCacheDir = t.TempDir()
+		cfg.CRLCacheFreshness = 0
+	})
+
+	ca := newTestCA(t, "Cache Test CA")
+	srv := newCRLServer(t, ca.crl(t, 1, []x509.RevocationListEntry{
+		revoked(3001, models.ReasonKeyCompromise, time.Now()),
+	}))
+
+	if err := service.ProcessSingleCRL(ctx, srv.URL); err != nil {
+		t.Fatalf("ProcessSingleCRL: %v", err)
+	}
+	before, err := store.GetCRLInfo(ctx, srv.URL)
+	if err != nil {
+		t.Fatalf("GetCRLInfo: %v", err)
+	}
+
+	// El servidor deja de responder y se usa la copia guardada, que ya se importó
+	srv.status = http.StatusServiceUnavailable
+	for i := 0; i < 2; i++ {
+		if err := service.ProcessSingleCRL(ctx, srv.URL); err != nil {
+			t.Fatalf("ProcessSingleCRL with cached copy: %v", err)
+		}
+	}
+
+	after, err := store.GetCRLInfo(ctx, srv.URL)
+	if err != nil {
+		t.Fatalf("GetCRLInfo: %v", err)
+	}
+	if !after.LastProcessed.Equal(before.LastProcessed) {
+		t.Errorf("last_processed moved from %v to %v after serving the cached copy", before.LastProcessed, after.LastProcessed)
+	}
+	if after.LastSuccess == nil || before.LastSuccess == nil || !after.LastSuccess.Equal(*before.LastSuccess) {
+		t.Errorf("last_success changed from %v to %v after serving the cached copy", before.LastSuccess, after.LastSuccess)
+	}
+	if after.ConsecutiveFailures != 2 || after.LastError == "" {
+		t.Errorf("got %d consecutive failures and last error %q, want 2 and the download error", after.ConsecutiveFailures, after.LastError)
+	}
+
+	history, err := store.GetProcessingHistory(ctx, srv.URL, 1)
+	if err != nil {
+		t.Fatalf("GetProcessingHistory: %v", err)
+	}
+	if len(history) != 1 || history[0].Status != models.ProcessingStatusServedFromCache || history[0].Error == "" {
+		t.Fatalf("got processing history %+v, want a served_from_cache run with the download error", history)
+	}
+
+	// La copia se sigue usando para responder
+	status, err := service.CheckCertificateStatus(ctx, "3001")
+	if err != nil {
+		t.Fatalf("CheckCertificateStatus: %v", err)
+	}
+	if !status.IsRevoked {
+		t.Error("serial 3001 is no longer revoked")
+	}
+}
+
+func TestDownloadRetriesTransientFailures(t *testing.T) {
+	ctx := context.Background()
+	ca := newTestCA(t, "Retry Test CA")
+	der := ca.crl(t, 1, nil)
+
+	tests := []struct {
+		name         string
+		failures     int
+		status       int
+		wantErr      bool
+		wantRequests int32
+	}{
+		{"fails twice then succeeds", 2, http.StatusServiceUnavailable, false, 3},
+		{"exhausts attempts", 3, http.StatusBadGateway, true, 3},
+		// Un 404 no es transitorio y no se reintenta
+		{"client error", 1, http.StatusNotFound, true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := newTestService(t, database.NewMemoryStore(), func(cfg *config.Config) {
+				cfg.DownloadAttempts = 3
+				cfg.DownloadRetryDelay = time.Millisecond
+				cfg.CRLPerHostRate = 0
+			})
+
+			var requests atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if int(requests.Add(1)) <= tt.failures {
+					w.WriteHeader(tt.status)
+					return
+				}
+				w.Write(der)
+			}))
+			defer srv.Close()
+
+			download, err := service.downloadWithRetry(ctx, srv.URL, &models.CRLValidators{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && string(download.data) != string(der) {
+				t.Error("downloaded data does not match the CRL")
+			}
+			if got := requests.Load(); got != tt.wantRequests {
+				t.Errorf("got %d requests, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+// flakyStore hace fallar las primeras failures consultas de estado con err
+type flakyStore struct {
+	database.CertStore
+	err      error
+	failures int32
+	calls    atomic.Int32
+}
+
+func (s *flakyStore) GetCertificateStatus(ctx context.Context, serial string) (*models.CertificateStatus, error) {
+	if s.calls.Add(1) <= s.failures {
+		return nil, s.err
+	}
+	return s.CertStore.GetCertificateStatus(ctx, serial)
+}
+
+func TestCheckCertificateStatusRetriesTransientDBErrors(t *testing.T) {
+	ctx := context.Background()
+	queryErr := errors.New(`pq: column "serial" does not exist`)
+
+	tests := []struct {
+		name            string
+		err             error
+		failures        int32
+		wantErr         bool
+		wantUnavailable bool
+		wantCalls       int32
+	}{
+		{"recovers after a dropped connection", driver.ErrBadConn, 2, false, false, 3},
+		{"outage", driver.ErrBadConn, 10, true, true, dbLookupAttempts},
+		// Un error de la consulta no se reintenta ni se informa como caída
+		{"query error", queryErr, 10, true, false, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memory := database.NewMemoryStore()
+			if _, err := memory.BatchInsertRevokedCertificates(ctx, []*models.RevokedCertificate{
+				{Serial: "9001", RevocationDate: time.Now().Add(-time.Hour), CertificateAuthority: "Flaky CA"},
+			}); err != nil {
+				t.Fatalf("BatchInsertRevokedCertificates: %v", err)
+			}
+			store := &flakyStore{CertStore: memory, err: tt.err, failures: tt.failures}
+			service := newTestService(t, store)
+
+			status, err := service.CheckCertificateStatus(ctx, "9001")
+			if !tt.wantErr && (err != nil || !status.IsRevoked) {
+				t.Errorf("CheckCertificateStatus = %+v, %v; want revoked", status, err)
+			}
+			if tt.wantErr && err == nil {
+				t.Error("CheckCertificateStatus succeeded, want an error")
+			}
+			if errors.Is(err, ErrDatabaseUnavailable) != tt.wantUnavailable {
+				t.Errorf("got error %v, want ErrDatabaseUnavailable %v", err, tt.wantUnavailable)
+			}
+			if calls := store.calls.Load(); calls != tt.wantCalls {
+				t.Errorf("got %d database lookups, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestIsTransientDBError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"bad connection", driver.ErrBadConn, true},
+		{"unexpected EOF", fmt.Errorf("reading: %w", io.ErrUnexpectedEOF), true},
+		{"connection refused", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, true},
+		{"connection exception", &pq.Error{Code: "08006"}, true},
+		{"shutting down", &pq.Error{Code: "57P01"}, true},
+		{"too many connections", &pq.Error{Code: "53300"}, true},
+		{"undefined column", &pq.Error{Code: "42703"}, false},
+		{"canceled", context.Canceled, false},
+		{"deadline", context.DeadlineExceeded, false},
+		{"other", errors.New("boom"), false},
+	}
+	for _, tt := range tests {
+		if got := isTransientDBError(tt.err); got != tt.want {
+			t.Errorf("%s: isTransientDBError = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"strings"
+
+	"signerflow-crl/models"
+)
+
+// Formatos aceptados para el número de serie en los endpoints de verificación
+const (
+	SerialFormatAuto    = "auto"
+	SerialFormatDecimal = "decimal"
+	SerialFormatHex     = "hex"
+	SerialFormatBase64  = "base64"
+)
+
+// ErrInvalidSerial indica que el serial no se puede interpretar en el formato indicado
+var ErrInvalidSerial = errors.New("invalid serial number")
+
+// ErrInvalidSerialFormat indica un formato de serial no soportado
+var ErrInvalidSerialFormat = errors.New("invalid serial format")
+
+// ParseSerial convierte un serial en decimal, hexadecimal o base64 (bytes del INTEGER DER)
+// a la forma decimal usada en la base de datos. Con SerialFormatAuto o "" se asume decimal
+// si solo tiene dígitos, hexadecimal si solo tiene dígitos hex o separadores, y base64 solo
+// si usa caracteres propios de base64 y decodifica a una longitud de serial plausible.
+func ParseSerial(serial, format string) (string, error) {
+	serial = strings.TrimSpace(serial)
+	if serial == "" || len(serial) > maxSerialInputLength {
+		return "", ErrInvalidSerial
+	}
+
+	n, err := parseSerial(serial, format)
+	if err == nil && len(n) > models.MaxSerialLength {
+		return "", ErrInvalidSerial
+	}
+	return n, err
+}
+
+// maxSerialInputLength acota el texto recibido antes de interpretarlo; holgado para la
+// notación hexadecimal con separadores de un serial de MaxSerialLength dígitos
+const maxSerialInputLength = 4 * models.MaxSerialLength
+
+func parseSerial(serial, format string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "", SerialFormatAuto:
+		if n, ok := parseHexSerial(serial); ok {
+			return n, nil
+		}
+		return parseAutoBase64Serial(serial)
+	case SerialFormatDecimal:
+		if !isDecimal(serial) {
+			return "", ErrInvalidSerial
+		}
+		n, _ := new(big.Int).SetString(serial, 10)
+		return n.String(), nil
+	case SerialFormatHex:
+		cleaned := strings.NewReplacer(":", "", " ", "").Replace(serial)
+		if strings.HasPrefix(cleaned, "0x") || strings.HasPrefix(cleaned, "0X") {
+			cleaned = cleaned[2:]
+		}
+		n, ok := new(big.Int).SetString(cleaned, 16)
+		if !ok || n.Sign() < 0 {
+			return "", ErrInvalidSerial
+		}
+		return n.String(), nil
+	case SerialFormatBase64:
+		return parseBase64Serial(serial)
+	default:
+		return "", ErrInvalidSerialFormat
+	}
+}
+
+// normalizeSerial converts hexadecimal serial numbers to decimal
+// If the input is already decimal, it returns as-is
+func (s *CRLService) normalizeSerial(serial string) string {
+	serial = strings.TrimSpace(serial)
+
+	n, ok := parseHexSerial(serial)
+	if !ok {
+		return serial
+	}
+	return n
+}
+
+// parseHexSerial interpreta el serial como decimal o hexadecimal según su notación
+func parseHexSerial(serial string) (string, bool) {
+	// Los separadores (01:A2:FF, "01 A2 FF") solo aparecen en notación hexadecimal
+	isHex := strings.ContainsAny(serial, ": ")
+	cleaned := strings.NewReplacer(":", "", " ", "").Replace(serial)
+
+	if strings.HasPrefix(cleaned, "0x") || strings.HasPrefix(cleaned, "0X") {
+		cleaned = cleaned[2:]
+		isHex = true
+	}
+
+	if cleaned == "" {
+		return "", false
+	}
+
+	// Se reescribe con big.Int para quitar ceros a la izquierda y usar la forma almacenada
+	if !isHex && isDecimal(cleaned) {
+		n, _ := new(big.Int).SetString(cleaned, 10)
+		return n.String(), true
+	}
+
+	n, ok := new(big.Int).SetString(cleaned, 16)
+	if !ok || n.Sign() < 0 {
+		return "", false
+	}
+
+	return n.String(), true
+}
+
+// parseBase64Serial decodifica los bytes del contenido de un INTEGER DER en base64
+// (estándar o URL, con o sin relleno) y los interpreta como entero big-endian sin signo.
+// El byte 0x00 que DER antepone a los seriales con el bit alto activo no altera el valor.
+func parseBase64Serial(serial string) (string, error) {
+	data, err := decodeBase64Serial(serial)
+	if err != nil {
+		return "", err
+	}
+	return new(big.Int).SetBytes(data).String(), nil
+}
+
+// decodeBase64Serial prueba las variantes de base64 admitidas y devuelve los bytes del serial
+func decodeBase64Serial(serial string) ([]byte, error) {
+	var data []byte
+	var err error
+	for _, enc := range []*base64.Encoding{
+		base64.StdEncoding,
+		base64.RawStdEncoding,
+		base64.URLEncoding,
+		base64.RawURLEncoding,
+	} {
+		if data, err = enc.DecodeString(serial); err == nil {
+			break
+		}
+	}
+	if err != nil || len(data) == 0 {
+		return nil, ErrInvalidSerial
+	}
+	return data, nil
+}
+
+// Longitudes en bytes aceptadas para un serial base64 detectado automáticamente: RFC 5280
+// admite hasta 20 octetos (21 con el 0x00 de DER) y los emisores usan al menos 64 bits
+// aleatorios, así que valores más cortos suelen ser errores de tecleo de un hexadecimal
+const (
+	minAutoBase64SerialBytes = 8
+	maxAutoBase64SerialBytes = 21
+)
+
+// parseAutoBase64Serial decodifica como base64 un serial sin formato explícito. Exige
+// caracteres que no sean hexadecimales, todos del alfabeto base64, y una longitud
+// decodificada plausible; en otro caso el valor se rechaza en lugar de adivinarlo.
+func parseAutoBase64Serial(serial string) (string, error) {
+	if !isBase64Alphabet(serial) || !strings.ContainsFunc(serial, isBase64OnlyRune) {
+		return "", ErrInvalidSerial
+	}
+
+	data, err := decodeBase64Serial(serial)
+	if err != nil || len(data) < minAutoBase64SerialBytes || len(data) > maxAutoBase64SerialBytes {
+		return "", ErrInvalidSerial
+	}
+	return new(big.Int).SetBytes(data).String(), nil
+}
+
+// isBase64Alphabet indica si todos los caracteres pertenecen a base64 estándar o URL
+func isBase64Alphabet(s string) bool {
+	for _, r := range s {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+		case strings.ContainsRune("+/-_=", r):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// isBase64OnlyRune indica si el carácter no puede aparecer en un serial hexadecimal
+func isBase64OnlyRune(r rune) bool {
+	switch {
+	case r >= 'g' && r <= 'z', r >= 'G' && r <= 'Z':
+		return true
+	default:
+		return strings.ContainsRune("+/-_=", r)
+	}
+}
+
+func isDecimal(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizeSerial(t *testing.T) {
+	s := &CRLService{}
+
+	tests := []struct {
+		name   string
+		serial string
+		want   string
+	}{
+		{"decimal", "1234567890", "1234567890"},
+		{"decimal with leading zeros", "000123", "123"},
+		{"lowercase hex", "0x1a2b", "6699"},
+		{"uppercase hex", "0X1A2B", "6699"},
+		{"hex without prefix", "1A2B", "6699"},
+		{"colon-separated bytes", "01:A2:ff", "107263"},
+		{"space-separated bytes", "01 A2 FF", "107263"},
+		{"hex with leading zeros", "00:00:1A:2B", "6699"},
+		{"surrounding whitespace", "  42  ", "42"},
+		{"not a serial", "serial-xyz", "serial-xyz"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.normalizeSerial(tt.serial); got != tt.want {
+				t.Errorf("normalizeSerial(%q) = %q, want %q", tt.serial, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSerial(t *testing.T) {
+	// 0x9ABCDEF012345678 tiene el bit alto activo, así que su INTEGER DER lleva un 0x00 delante
+	const want = "11150031900141442680"
+
+	tests := []struct {
+		name   string
+		serial string
+		format string
+		want   string
+	}{
+		{"decimal", want, "", want},
+		{"hex with prefix", "0x9abcdef012345678", "", want},
+		{"colon-separated hex", "9A:BC:DE:F0:12:34:56:78", "", want},
+		{"base64 with DER sign byte", "AJq83vASNFZ4", "", want},
+		{"padded base64", "mrze8BI0Vng=", "", want},
+		{"unpadded URL base64", "mrze8BI0Vng", "", want},
+		{"explicit base64", "AJq83vASNFZ4", SerialFormatBase64, want},
+		{"explicit decimal", want, SerialFormatDecimal, want},
+		{"explicit hex", "9abcdef012345678", SerialFormatHex, want},
+		{"digits forced to hex", "12345678", SerialFormatHex, "305419896"},
+		{"hex letters forced to base64", "ABCD", SerialFormatBase64, "4227"},
+		{"hex letters autodetected as hex", "ABCD", SerialFormatAuto, "43981"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSerial(tt.serial, tt.format)
+			if err != nil {
+				t.Fatalf("ParseSerial(%q, %q): %v", tt.serial, tt.format, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseSerial(%q, %q) = %q, want %q", tt.serial, tt.format, got, tt.want)
+			}
+		})
+	}
+
+	invalid := []struct {
+		name    string
+		serial  string
+		format  string
+		wantErr error
+	}{
+		// Demasiado corto para ser un serial base64: probablemente un hexadecimal mal tecleado
+		{"short non-hex", "12G4", "", ErrInvalidSerial},
+		{"hex as decimal", "0x12", SerialFormatDecimal, ErrInvalidSerial},
+		{"not hex", "xyz", SerialFormatHex, ErrInvalidSerial},
+		{"not base64", "***", SerialFormatBase64, ErrInvalidSerial},
+		{"empty", "  ", "", ErrInvalidSerial},
+		{"unknown format", "42", "octal", ErrInvalidSerialFormat},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := ParseSerial(tt.serial, tt.format); !errors.Is(err, tt.wantErr) {
+				t.Errorf("ParseSerial(%q, %q) = %q, %v; want error %v", tt.serial, tt.format, got, err, tt.wantErr)
+			}
+		})
+	}
+}
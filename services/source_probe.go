@@ -0,0 +1,173 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// sourceProbeTimeout acota la prueba completa de una fuente, incluida la espera del
+	// límite por host
+	sourceProbeTimeout = 10 * time.Second
+	// sourceProbeBytes es la cantidad de bytes iniciales que se piden para reconocer el formato
+	sourceProbeBytes = 1024
+)
+
+// ErrProbeUnsupportedScheme indica que la URL no es HTTP ni HTTPS; las fuentes LDAP no se
+// pueden leer parcialmente
+var ErrProbeUnsupportedScheme = errors.New("only http and https sources can be tested")
+
+// SourceProbeResult resume la respuesta de una fuente sin descargar la CRL completa
+type SourceProbeResult struct {
+	URL       string `json:"url"`
+	Reachable bool   `json:"reachable"`
+	// Status y los campos siguientes solo se informan si el servidor respondió
+	Status        int    `json:"status,omitempty"`
+	ContentType   string `json:"content_type,omitempty"`
+	ContentLength int64  `json:"content_length,omitempty"`
+	// Format es der, pem, pkcs7 o unknown según los primeros bytes del cuerpo
+	Format       string `json:"format,omitempty"`
+	LooksLikeCRL bool   `json:"looks_like_crl"`
+	DurationMs   int64  `json:"duration_ms"`
+	Error        string `json:"error,omitempty"`
+}
+
+// ProbeSource pide los primeros bytes de una fuente HTTP con un GET parcial para comprobar que
+// responde y que publica una CRL, sin descargarla completa ni guardar nada
+func (s *CRLService) ProbeSource(ctx context.Context, crlURL string) (*SourceProbeResult, error) {
+	parsedURL, err := url.Parse(crlURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %v", err)
+	}
+	if scheme := strings.ToLower(parsedURL.Scheme); scheme != "http" && scheme != "https" {
+		return nil, ErrProbeUnsupportedScheme
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, sourceProbeTimeout)
+	defer cancel()
+
+	result := &SourceProbeResult{URL: crlURL}
+	start := time.Now()
+	defer func() {
+		result.DurationMs = time.Since(start).Milliseconds()
+	}()
+
+	if err := s.waitForHost(ctx, parsedURL); err != nil {
+		result.Error = err.Error()
+		return result, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", parsedURL.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+	req.Header.Set("User-Agent", "SignerFlow-CRL-Service/1.0")
+	req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", sourceProbeBytes-1))
+	// Sin compresión para que el tamaño y los primeros bytes sean los de la CRL
+	req.Header.Set("Accept-Encoding", "identity")
+
+	client, err := s.httpClientFor(ctx, parsedURL.String())
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := doCRLRequest(client, req)
+	if err != nil {
+		result.Error = fmt.Sprintf("error connecting to source: %v", err)
+		return result, nil
+	}
+	defer resp.Body.Close()
+
+	result.Reachable = true
+	result.Status = resp.StatusCode
+	result.ContentType = resp.Header.Get("Content-Type")
+	result.ContentLength = probeContentLength(resp)
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		result.Error = fmt.Sprintf("HTTP error: %s", resp.Status)
+		return result, nil
+	}
+
+	// Un servidor que ignora Range devuelve el cuerpo completo; solo se leen los primeros bytes
+	prefix, err := io.ReadAll(io.LimitReader(resp.Body, sourceProbeBytes))
+	if err != nil {
+		result.Error = fmt.Sprintf("error reading response body: %v", err)
+		return result, nil
+	}
+
+	result.Format = sniffCRLFormat(prefix)
+	result.LooksLikeCRL = result.Format != "unknown"
+	return result, nil
+}
+
+// probeContentLength devuelve el tamaño total de la CRL: el de Content-Range en una respuesta
+// parcial o Content-Length en una completa; 0 si el servidor no lo informa
+func probeContentLength(resp *http.Response) int64 {
+	if resp.StatusCode == http.StatusPartialContent {
+		// Content-Range: bytes 0-1023/123456
+		contentRange := resp.Header.Get("Content-Range")
+		if slash := strings.LastIndex(contentRange, "/"); slash >= 0 {
+			if total, err := strconv.ParseInt(contentRange[slash+1:], 10, 64); err == nil {
+				return total
+			}
+		}
+		return 0
+	}
+	if resp.ContentLength > 0 {
+		return resp.ContentLength
+	}
+	return 0
+}
+
+// oidSignedDataDER es el OID 1.2.840.113549.1.7.2 (SignedData) codificado en DER
+var oidSignedDataDER = []byte{0x06, 0x09, 0x2a, 0x86, 0x48, 0x86, 0xf7, 0x0d, 0x01, 0x07, 0x02}
+
+// sniffCRLFormat reconoce por los primeros bytes una CRL en PEM, en DER (una SEQUENCE cuyo
+// primer elemento es otra SEQUENCE, el tbsCertList) o un contenedor PKCS#7 SignedData
+func sniffCRLFormat(prefix []byte) string {
+	trimmed := bytes.TrimSpace(prefix)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("-----BEGIN X509 CRL-----")):
+		return "pem"
+	case bytes.HasPrefix(trimmed, []byte("-----BEGIN PKCS7-----")), bytes.HasPrefix(trimmed, []byte("-----BEGIN CMS-----")):
+		return "pkcs7"
+	}
+
+	content, ok := derSequenceContent(prefix)
+	if !ok || len(content) == 0 {
+		return "unknown"
+	}
+	if bytes.HasPrefix(content, oidSignedDataDER) {
+		return "pkcs7"
+	}
+	if content[0] == 0x30 {
+		return "der"
+	}
+	return "unknown"
+}
+
+// derSequenceContent devuelve los bytes disponibles del contenido de una SEQUENCE DER a partir
+// de su encabezado, aunque el prefijo no alcance a cubrirla completa
+func derSequenceContent(data []byte) ([]byte, bool) {
+	if len(data) < 2 || data[0] != 0x30 {
+		return nil, false
+	}
+	lengthByte := data[1]
+	if lengthByte < 0x80 {
+		return data[2:], true
+	}
+	// Forma larga: los 7 bits bajos indican cuántos bytes ocupa la longitud
+	lengthBytes := int(lengthByte & 0x7f)
+	if lengthBytes == 0 || lengthBytes > 4 || len(data) < 2+lengthBytes {
+		return nil, false
+	}
+	return data[2+lengthBytes:], true
+}
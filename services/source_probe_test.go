@@ -0,0 +1,139 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"signerflow-crl/config"
+	"signerflow-crl/database"
+)
+
+func TestProbeSource(t *testing.T) {
+	ctx := context.Background()
+	der := newTestCA(t, "Probe CA").crl(t, 1, revokedRange(7801, 200))
+	pemCRL := pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der})
+
+	var ranges []string
+	mux := http.NewServeMux()
+	// ServeContent responde el rango pedido con 206 y Content-Range
+	mux.HandleFunc("/ranged.crl", func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		w.Header().Set("Content-Type", "application/pkix-crl")
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(der))
+	})
+	mux.HandleFunc("/full.pem", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-pem-file")
+		w.Header().Set("Content-Length", strconv.Itoa(len(pemCRL)))
+		w.Write(pemCRL)
+	})
+	mux.HandleFunc("/page.html", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><body>Not a CRL</body></html>"))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	store := database.NewMemoryStore()
+	service := newTestService(t, store, func(cfg *config.Config) {
+		cfg.CRLPerHostRate = 0
+	})
+
+	tests := []struct {
+		path              string
+		wantStatus        int
+		wantContentType   string
+		wantContentLength int64
+		wantFormat        string
+		wantCRL           bool
+		wantError         bool
+	}{
+		{"/ranged.crl", http.StatusPartialContent, "application/pkix-crl", int64(len(der)), "der", true, false},
+		{"/full.pem", http.StatusOK, "application/x-pem-file", int64(len(pemCRL)), "pem", true, false},
+		{"/page.html", http.StatusOK, "text/html", 35, "unknown", false, false},
+		{"/missing.crl", http.StatusNotFound, "text/plain; charset=utf-8", 19, "", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			result, err := service.ProbeSource(ctx, srv.URL+tt.path)
+			if err != nil {
+				t.Fatalf("ProbeSource: %v", err)
+			}
+			if !result.Reachable || result.Status != tt.wantStatus {
+				t.Errorf("got reachable=%v status %d, want reachable with status %d", result.Reachable, result.Status, tt.wantStatus)
+			}
+			if result.ContentType != tt.wantContentType || result.ContentLength != tt.wantContentLength {
+				t.Errorf("got %q of %d bytes, want %q of %d bytes", result.ContentType, result.ContentLength, tt.wantContentType, tt.wantContentLength)
+			}
+			if result.Format != tt.wantFormat || result.LooksLikeCRL != tt.wantCRL {
+				t.Errorf("got format %q looks_like_crl=%v, want %q %v", result.Format, result.LooksLikeCRL, tt.wantFormat, tt.wantCRL)
+			}
+			if (result.Error != "") != tt.wantError {
+				t.Errorf("got error %q, want error %v", result.Error, tt.wantError)
+			}
+		})
+	}
+
+	// Solo se pidieron los primeros bytes y no se guardó nada
+	if len(ranges) != 1 || ranges[0] != "bytes=0-"+strconv.Itoa(sourceProbeBytes-1) {
+		t.Errorf("got Range headers %q, want a single request for the first %d bytes", ranges, sourceProbeBytes)
+	}
+	if _, err := store.GetCRLInfo(ctx, srv.URL+"/ranged.crl"); err == nil {
+		t.Error("probing a source recorded CRL info")
+	}
+	if history, _ := store.GetProcessingHistory(ctx, "", 10); len(history) != 0 {
+		t.Errorf("probing a source recorded %d processing runs", len(history))
+	}
+}
+
+func TestProbeSourceFailures(t *testing.T) {
+	ctx := context.Background()
+	service := newTestService(t, database.NewMemoryStore(), func(cfg *config.Config) {
+		cfg.CRLPerHostRate = 0
+	})
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	result, err := service.ProbeSource(ctx, closed.URL+"/ca.crl")
+	if err != nil {
+		t.Fatalf("ProbeSource: %v", err)
+	}
+	if result.Reachable || result.Status != 0 || result.Error == "" {
+		t.Errorf("got result %+v, want an unreachable source with its error", result)
+	}
+
+	if _, err := service.ProbeSource(ctx, "ldap://ldap.example/cn=CA?certificateRevocationList"); !errors.Is(err, ErrProbeUnsupportedScheme) {
+		t.Errorf("got error %v for an LDAP source, want ErrProbeUnsupportedScheme", err)
+	}
+}
+
+func TestSniffCRLFormat(t *testing.T) {
+	der := newTestCA(t, "Sniff CA").crl(t, 1, nil)
+
+	tests := []struct {
+		name   string
+		prefix []byte
+		want   string
+	}{
+		{"DER", der, "der"},
+		{"truncated DER", der[:16], "der"},
+		{"PEM", pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der}), "pem"},
+		{"PEM with leading whitespace", append([]byte("\n  "), pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der})...), "pem"},
+		{"PKCS#7 PEM", []byte("-----BEGIN PKCS7-----\nMIIB\n"), "pkcs7"},
+		{"PKCS#7 DER", append([]byte{0x30, 0x82, 0x01, 0x00}, oidSignedDataDER...), "pkcs7"},
+		{"HTML", []byte("<!DOCTYPE html>"), "unknown"},
+		{"SEQUENCE of an integer", []byte{0x30, 0x03, 0x02, 0x01, 0x01}, "unknown"},
+		{"empty", nil, "unknown"},
+	}
+	for _, tt := range tests {
+		if got := sniffCRLFormat(tt.prefix); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// reconciledCounters asocia cada contador de Redis con el total de GetCRLStats que lo corrige
+var reconciledCounters = map[string]string{
+	"stats:revoked_total": "total_revoked_certificates",
+	"stats:crls_total":    "total_crls_processed",
+}
+
+// StatsReconciliation es el resultado de corregir los contadores de Redis con los totales de
+// la base. Drift es la diferencia entre el valor que tenía cada contador y el de la base.
+type StatsReconciliation struct {
+	ReconciledAt time.Time        `json:"reconciled_at"`
+	Database     map[string]int64 `json:"database"`
+	Drift        map[string]int64 `json:"drift"`
+}
+
+// ReconcileStats recalcula en la base el total de certificados revocados y de CRLs y
+// reemplaza con ellos los contadores stats:revoked_total y stats:crls_total, que entre
+// reconciliaciones se ajustan con cada importación y eliminación y se pierden si Redis se
+// reinicia. Una importación concurrente puede dejar una diferencia que corrige la siguiente
+// ejecución. Sin Redis no hace nada y devuelve nil.
+func (s *CRLService) ReconcileStats(ctx context.Context) (*StatsReconciliation, error) {
+	if s.redis == nil {
+		return nil, nil
+	}
+
+	dbStats, err := s.db.GetCRLStats(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error loading database totals: %v", err)
+	}
+
+	totals := make(map[string]int64, len(reconciledCounters))
+	for counter, field := range reconciledCounters {
+		total, ok := dbStats[field].(int)
+		if !ok {
+			return nil, fmt.Errorf("unexpected database total %s: %v", field, dbStats[field])
+		}
+		totals[counter] = int64(total)
+	}
+
+	previous, err := s.redis.ReplaceStats(totals)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &StatsReconciliation{
+		ReconciledAt: time.Now(),
+		Database:     totals,
+		Drift:        make(map[string]int64, len(totals)),
+	}
+	for counter, total := range totals {
+		drift := previous[counter] - total
+		result.Drift[counter] = drift
+		if drift != 0 {
+			log.Printf("Stats counter %s drifted by %d, corrected to %d", counter, drift, total)
+		}
+	}
+
+	s.lastReconciliation.Store(result)
+	return result, nil
+}
+
+// LastStatsReconciliation devuelve el resultado de la última reconciliación, o nil si todavía
+// no se ejecutó ninguna
+func (s *CRLService) LastStatsReconciliation() *StatsReconciliation {
+	return s.lastReconciliation.Load()
+}
+
+// adjustRevokedTotal suma delta al contador stats:revoked_total. Los errores se ignoran, como
+// en el resto de los contadores: la siguiente reconciliación corrige el valor.
+func (s *CRLService) adjustRevokedTotal(delta int) {
+	if s.redis == nil || delta == 0 {
+		return
+	}
+	s.redis.IncrementStatsBy("stats:revoked_total", int64(delta))
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"signerflow-crl/config"
+	"signerflow-crl/database"
+)
+
+func TestReconcileStats(t *testing.T) {
+	ctx := context.Background()
+	redis, srv := newTestRedis(t)
+	service := newCachedTestService(t, database.NewMemoryStore(), redis, func(cfg *config.Config) {
+		cfg.CRLPerHostRate = 0
+	})
+	counter := func(key string) string {
+		value, _ := srv.Get(key)
+		return value
+	}
+
+	// Cada CRL procesada suma uno y cada certificado importado ajusta el total en vivo
+	first := newCRLServer(t, newTestCA(t, "Reconcile CA One").crl(t, 1, revokedRange(7901, 3)))
+	second := newCRLServer(t, newTestCA(t, "Reconcile CA Two").crl(t, 1, revokedRange(7911, 2)))
+	for _, url := range []string{first.URL, second.URL} {
+		if err := service.ProcessSingleCRL(ctx, url); err != nil {
+			t.Fatalf("ProcessSingleCRL %s: %v", url, err)
+		}
+	}
+	if processed, total := counter("stats:crls_processed"), counter("stats:revoked_total"); processed != "2" || total != "5" {
+		t.Errorf("got crls_processed %q and revoked_total %q, want 2 and 5", processed, total)
+	}
+
+	// Un contador desviado y otro perdido en un reinicio de Redis se corrigen con la base
+	srv.Set("stats:revoked_total", "100")
+	if service.LastStatsReconciliation() != nil {
+		t.Error("got a reconciliation result before any reconciliation ran")
+	}
+	result, err := service.ReconcileStats(ctx)
+	if err != nil {
+		t.Fatalf("ReconcileStats: %v", err)
+	}
+	if result.Database["stats:revoked_total"] != 5 || result.Database["stats:crls_total"] != 2 {
+		t.Errorf("got database totals %v, want 5 revoked certificates and 2 CRLs", result.Database)
+	}
+	if result.Drift["stats:revoked_total"] != 95 || result.Drift["stats:crls_total"] != -2 {
+		t.Errorf("got drift %v, want 95 revoked certificates and -2 CRLs", result.Drift)
+	}
+	if total, crls := counter("stats:revoked_total"), counter("stats:crls_total"); total != "5" || crls != "2" {
+		t.Errorf("got revoked_total %q and crls_total %q in Redis, want 5 and 2", total, crls)
+	}
+	if service.LastStatsReconciliation() != result {
+		t.Error("last reconciliation result was not kept")
+	}
+
+	// Sin desvío la reconciliación no cambia nada
+	result, err = service.ReconcileStats(ctx)
+	if err != nil {
+		t.Fatalf("ReconcileStats: %v", err)
+	}
+	for counter, drift := range result.Drift {
+		if drift != 0 {
+			t.Errorf("%s: got drift %d right after reconciling, want 0", counter, drift)
+		}
+	}
+
+	// Las eliminaciones descuentan del total en vivo
+	if _, err := service.PurgeCA(ctx, "Reconcile CA One"); err != nil {
+		t.Fatalf("PurgeCA: %v", err)
+	}
+	if total := counter("stats:revoked_total"); total != "2" {
+		t.Errorf("got revoked_total %q after purging a CA, want 2", total)
+	}
+}
+
+func TestReconcileStatsWithoutRedis(t *testing.T) {
+	service := newTestService(t, database.NewMemoryStore())
+
+	result, err := service.ReconcileStats(context.Background())
+	if err != nil || result != nil {
+		t.Errorf("got %+v, %v; want nil without Redis", result, err)
+	}
+	if service.LastStatsReconciliation() != nil {
+		t.Error("got a reconciliation result without Redis")
+	}
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"signerflow-crl/models"
+)
+
+// ErrMissingCRLSign indica que el certificado de CA no puede firmar CRLs
+var ErrMissingCRLSign = errors.New("certificate lacks the cRLSign key usage")
+
+// AddCACertificate valida y registra un certificado de CA en DER o PEM
+func (s *CRLService) AddCACertificate(ctx context.Context, data []byte) (*models.CACertificate, error) {
+	cert, err := parseCertificate(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if cert.KeyUsage&x509.KeyUsageCRLSign == 0 {
+		return nil, ErrMissingCRLSign
+	}
+
+	keyID, err := computeKeyID(cert)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCertificate, err)
+	}
+
+	ca := &models.CACertificate{
+		Subject:   cert.Subject.String(),
+		KeyID:     keyID,
+		NotBefore: cert.NotBefore,
+		NotAfter:  cert.NotAfter,
+		DER:       cert.Raw,
+	}
+
+	if err := s.db.AddCACertificate(ctx, ca); err != nil {
+		return nil, err
+	}
+
+	return ca, nil
+}
+
+// verifyCRLSignature verifica la firma de la CRL contra los certificados de CA registrados
+// para su emisor. Si no hay ninguno registrado la CRL se acepta sin verificar y verified es false.
+func (s *CRLService) verifyCRLSignature(ctx context.Context, crl *pkix.CertificateList, issuer pkix.Name) (verified bool, err error) {
+	cas, err := s.db.GetCACertificatesBySubject(ctx, issuer.String())
+	if err != nil {
+		return false, fmt.Errorf("error loading CA certificates: %v", err)
+	}
+
+	if len(cas) == 0 {
+		return false, nil
+	}
+
+	rl := signatureOnlyRevocationList(crl)
+
+	var lastErr error
+	for _, ca := range cas {
+		cert, err := x509.ParseCertificate(ca.DER)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		// Además de la firma (RSA, RSA-PSS, ECDSA o Ed25519) comprueba que el certificado
+		// sea de CA y tenga el uso de clave cRLSign
+		if lastErr = rl.CheckSignatureFrom(cert); lastErr == nil {
+			return true, nil
+		}
+	}
+
+	return false, fmt.Errorf("CRL signature does not match any registered CA certificate: %v", lastErr)
+}
+
+// isIssuedByRegisteredCA indica si la firma del certificado corresponde a alguno de los
+// certificados de CA registrados con el subject de su emisor
+func (s *CRLService) isIssuedByRegisteredCA(ctx context.Context, cert *x509.Certificate) (bool, error) {
+	cas, err := s.db.GetCACertificatesBySubject(ctx, cert.Issuer.String())
+	if err != nil {
+		return false, fmt.Errorf("error loading CA certificates: %v", err)
+	}
+
+	for _, ca := range cas {
+		caCert, err := x509.ParseCertificate(ca.DER)
+		if err != nil {
+			continue
+		}
+		if cert.CheckSignatureFrom(caCert) == nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// computeKeyID calcula el identificador de clave como el SHA-1 de la clave pública (RFC 5280, 4.2.1.2)
+func computeKeyID(cert *x509.Certificate) (string, error) {
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(cert.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return "", err
+	}
+
+	sum := sha1.Sum(spki.PublicKey.RightAlign())
+	return hex.EncodeToString(sum[:]), nil
+}
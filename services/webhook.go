@@ -0,0 +1,219 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"signerflow-crl/config"
+	"signerflow-crl/models"
+)
+
+const (
+	// webhookBatchSize limita la cantidad de certificados por notificación
+	webhookBatchSize = 500
+	webhookAttempts  = 3
+	webhookTimeout   = 10 * time.Second
+)
+
+// WebhookEvent es el cuerpo JSON enviado al webhook
+type WebhookEvent struct {
+	Event                string               `json:"event"`
+	CRLURL               string               `json:"crl_url"`
+	CertificateAuthority string               `json:"certificate_authority"`
+	DetectedAt           time.Time            `json:"detected_at"`
+	Certificates         []WebhookCertificate `json:"certificates"`
+}
+
+type WebhookCertificate struct {
+	Serial         string    `json:"serial"`
+	RevocationDate time.Time `json:"revocation_date"`
+	Reason         int       `json:"reason"`
+	ReasonText     string    `json:"reason_text"`
+}
+
+// WebhookNotifier envía de forma asíncrona las revocaciones nuevas a un webhook.
+// La cola es acotada: si el webhook no da abasto los eventos se descartan en lugar
+// de bloquear el procesamiento de CRLs.
+type WebhookNotifier struct {
+	url        string
+	secret     string
+	httpClient *http.Client
+	queue      chan *WebhookEvent
+	wg         sync.WaitGroup
+
+	// Filtro de códigos de motivo por CA; se reemplaza completo al recargar el archivo
+	filter         atomic.Pointer[reasonFilter]
+	defaultReasons []int
+	reasonFile     string
+
+	// Protege el cierre de la cola frente a procesamientos que aún notifican
+	mu      sync.RWMutex
+	stopped bool
+}
+
+func NewWebhookNotifier(cfg *config.Config) (*WebhookNotifier, error) {
+	filter, err := newReasonFilter(cfg.WebhookReasonCodes, cfg.WebhookReasonFile)
+	if err != nil {
+		return nil, err
+	}
+
+	queueSize := cfg.WebhookQueueSize
+	if queueSize < 1 {
+		queueSize = 1
+	}
+
+	n := &WebhookNotifier{
+		url:        cfg.WebhookURL,
+		secret:     cfg.WebhookSecret,
+		httpClient: &http.Client{Timeout: webhookTimeout},
+		queue:      make(chan *WebhookEvent, queueSize),
+
+		defaultReasons: cfg.WebhookReasonCodes,
+		reasonFile:     cfg.WebhookReasonFile,
+	}
+	n.filter.Store(filter)
+
+	n.wg.Add(1)
+	go n.run()
+
+	log.Printf("Webhook notifications enabled for %s", cfg.WebhookURL)
+	return n, nil
+}
+
+// ReloadReasonFilter vuelve a leer WEBHOOK_REASON_FILE; si el archivo no es válido se
+// conserva el filtro anterior
+func (n *WebhookNotifier) ReloadReasonFilter() error {
+	filter, err := newReasonFilter(n.defaultReasons, n.reasonFile)
+	if err != nil {
+		return err
+	}
+	n.filter.Store(filter)
+	return nil
+}
+
+// Notify encola las revocaciones nuevas que pasan el filtro de códigos de motivo de la CA
+func (n *WebhookNotifier) Notify(crlURL, issuer string, certs []*models.RevokedCertificate) {
+	filter := n.filter.Load()
+
+	var selected []WebhookCertificate
+	for _, cert := range certs {
+		if !filter.allows(issuer, cert.Reason) {
+			continue
+		}
+		selected = append(selected, WebhookCertificate{
+			Serial:         cert.Serial,
+			RevocationDate: cert.RevocationDate,
+			Reason:         cert.Reason,
+			ReasonText:     cert.ReasonText,
+		})
+	}
+
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	if n.stopped {
+		return
+	}
+
+	for start := 0; start < len(selected); start += webhookBatchSize {
+		end := start + webhookBatchSize
+		if end > len(selected) {
+			end = len(selected)
+		}
+
+		event := &WebhookEvent{
+			Event:                "certificates.revoked",
+			CRLURL:               crlURL,
+			CertificateAuthority: issuer,
+			DetectedAt:           time.Now().UTC(),
+			Certificates:         selected[start:end],
+		}
+
+		select {
+		case n.queue <- event:
+		default:
+			log.Printf("Webhook queue full, dropping notification of %d revocations from %s", len(event.Certificates), issuer)
+		}
+	}
+}
+
+// Stop cierra la cola y espera a que se entreguen los eventos pendientes
+func (n *WebhookNotifier) Stop() {
+	n.mu.Lock()
+	if !n.stopped {
+		n.stopped = true
+		close(n.queue)
+	}
+	n.mu.Unlock()
+
+	n.wg.Wait()
+}
+
+func (n *WebhookNotifier) run() {
+	defer n.wg.Done()
+	for event := range n.queue {
+		if err := n.deliver(event); err != nil {
+			log.Printf("Error delivering webhook for %s: %v", event.CertificateAuthority, err)
+		}
+	}
+}
+
+func (n *WebhookNotifier) deliver(event *WebhookEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("error marshaling webhook payload: %v", err)
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= webhookAttempts; attempt++ {
+		lastErr = n.send(payload)
+		if lastErr == nil {
+			return nil
+		}
+		if attempt < webhookAttempts {
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+	}
+
+	return lastErr
+}
+
+func (n *WebhookNotifier) send(payload []byte) error {
+	req, err := http.NewRequest("POST", n.url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("error creating webhook request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "SignerFlow-CRL-Service/1.0")
+	if n.secret != "" {
+		req.Header.Set("X-Signature-256", "sha256="+SignWebhookPayload(n.secret, payload))
+	}
+
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending webhook: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned HTTP %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// SignWebhookPayload calcula el HMAC-SHA256 en hexadecimal del cuerpo con el secreto compartido
+func SignWebhookPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
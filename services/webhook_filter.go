@@ -0,0 +1,91 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"signerflow-crl/models"
+)
+
+// reasonFilterFile es el formato del archivo WEBHOOK_REASON_FILE: códigos por defecto y por CA.
+// Una CA con lista vacía no genera notificaciones.
+type reasonFilterFile struct {
+	Default []int            `json:"default"`
+	CAs     map[string][]int `json:"cas"`
+}
+
+// reasonFilter decide qué revocaciones se notifican según la CA y el código de motivo.
+// Un conjunto nil acepta cualquier código.
+type reasonFilter struct {
+	defaultCodes map[int]bool
+	byCA         map[string]map[int]bool
+}
+
+// newReasonFilter arma el filtro a partir de los códigos globales y, si se indica, del
+// archivo de configuración; el default del archivo reemplaza a defaultCodes
+func newReasonFilter(defaultCodes []int, path string) (*reasonFilter, error) {
+	filter := &reasonFilter{}
+
+	if len(defaultCodes) > 0 {
+		codes, err := reasonCodeSet(defaultCodes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid WEBHOOK_REASON_CODES: %v", err)
+		}
+		filter.defaultCodes = codes
+	}
+
+	if path == "" {
+		return filter, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading webhook reason file: %v", err)
+	}
+
+	var file reasonFilterFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("error parsing webhook reason file: %v", err)
+	}
+
+	if file.Default != nil {
+		codes, err := reasonCodeSet(file.Default)
+		if err != nil {
+			return nil, fmt.Errorf("invalid default reason codes: %v", err)
+		}
+		filter.defaultCodes = codes
+	}
+
+	filter.byCA = make(map[string]map[int]bool, len(file.CAs))
+	for ca, list := range file.CAs {
+		codes, err := reasonCodeSet(list)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reason codes for CA %q: %v", ca, err)
+		}
+		filter.byCA[ca] = codes
+	}
+
+	return filter, nil
+}
+
+// allows indica si una revocación de la CA con ese código debe notificarse
+func (f *reasonFilter) allows(ca string, reason int) bool {
+	codes, ok := f.byCA[ca]
+	if !ok {
+		codes = f.defaultCodes
+	}
+	return codes == nil || codes[reason]
+}
+
+// reasonCodeSet convierte la lista en conjunto validando que sean códigos de RevocationReasons
+func reasonCodeSet(list []int) (map[int]bool, error) {
+	codes := make(map[int]bool, len(list))
+	for _, code := range list {
+		if _, ok := models.RevocationReasons[code]; !ok {
+			return nil, fmt.Errorf("unknown reason code %d", code)
+		}
+		codes[code] = true
+	}
+	return codes, nil
+}
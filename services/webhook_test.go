@@ -0,0 +1,246 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"signerflow-crl/config"
+	"signerflow-crl/database"
+	"signerflow-crl/models"
+)
+
+// webhookReceiver guarda los eventos recibidos y verifica su firma con secret
+type webhookReceiver struct {
+	*httptest.Server
+	secret string
+
+	mu     sync.Mutex
+	events []WebhookEvent
+	// badSignatures cuenta los eventos cuya firma no coincide con secret
+	badSignatures int
+}
+
+func newWebhookReceiver(t *testing.T, secret string) *webhookReceiver {
+	t.Helper()
+
+	receiver := &webhookReceiver{secret: secret}
+	receiver.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		valid := hmac.Equal([]byte(r.Header.Get("X-Signature-256")), []byte("sha256="+hex.EncodeToString(mac.Sum(nil))))
+
+		var event WebhookEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		receiver.mu.Lock()
+		receiver.events = append(receiver.events, event)
+		if !valid {
+			receiver.badSignatures++
+		}
+		receiver.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(receiver.Close)
+	return receiver
+}
+
+// serials devuelve, por evento recibido, los seriales notificados ordenados
+func (r *webhookReceiver) serials() [][]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := make([][]string, len(r.events))
+	for i, event := range r.events {
+		for _, cert := range event.Certificates {
+			result[i] = append(result[i], cert.Serial)
+		}
+		sort.Strings(result[i])
+	}
+	return result
+}
+
+func TestWebhookNotifiesNewRevocations(t *testing.T) {
+	ctx := context.Background()
+	receiver := newWebhookReceiver(t, "webhook-secret")
+	service := newTestService(t, database.NewMemoryStore(), func(cfg *config.Config) {
+		cfg.WebhookURL = receiver.URL
+		cfg.WebhookSecret = "webhook-secret"
+	})
+	ca := newTestCA(t, "Webhook Test CA")
+
+	srv := newCRLServer(t, ca.crl(t, 1, []x509.RevocationListEntry{revoked(4801, models.ReasonKeyCompromise, time.Now())}))
+	if err := service.ProcessSingleCRL(ctx, srv.URL); err != nil {
+		t.Fatalf("ProcessSingleCRL: %v", err)
+	}
+	srv.body = ca.crl(t, 2, []x509.RevocationListEntry{
+		revoked(4801, models.ReasonKeyCompromise, time.Now()),
+		revoked(4802, models.ReasonSuperseded, time.Now()),
+	})
+	if err := service.ProcessSingleCRL(ctx, srv.URL); err != nil {
+		t.Fatalf("ProcessSingleCRL: %v", err)
+	}
+	// Close espera a que se entreguen los eventos encolados
+	service.Close()
+
+	got := receiver.serials()
+	if len(got) != 2 || len(got[0]) != 1 || got[0][0] != "4801" || len(got[1]) != 1 || got[1][0] != "4802" {
+		t.Fatalf("got notified serials %v, want [[4801] [4802]]", got)
+	}
+	if receiver.badSignatures != 0 {
+		t.Errorf("got %d events with an invalid signature", receiver.badSignatures)
+	}
+	event := receiver.events[1]
+	if event.Event != "certificates.revoked" || event.CRLURL != srv.URL || event.CertificateAuthority != "Webhook Test CA" {
+		t.Errorf("got event %+v", event)
+	}
+	if cert := event.Certificates[0]; cert.Reason != models.ReasonSuperseded || cert.ReasonText != models.RevocationReasons[models.ReasonSuperseded] {
+		t.Errorf("got certificate %+v, want reason superseded", cert)
+	}
+}
+
+func TestWebhookFiltersReasonCodes(t *testing.T) {
+	ctx := context.Background()
+	reasonFile := filepath.Join(t.TempDir(), "webhook-reasons.json")
+	writeReasonFile := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(reasonFile, []byte(content), 0o600); err != nil {
+			t.Fatalf("writing reason file: %v", err)
+		}
+	}
+	writeReasonFile(`{"default": [1], "cas": {"Filtered CA": [1, 6]}}`)
+
+	receiver := newWebhookReceiver(t, "webhook-secret")
+	service := newTestService(t, database.NewMemoryStore(), func(cfg *config.Config) {
+		cfg.WebhookURL = receiver.URL
+		cfg.WebhookSecret = "webhook-secret"
+		cfg.WebhookReasonFile = reasonFile
+	})
+
+	now := time.Now()
+	filtered := newTestCA(t, "Filtered CA")
+	other := newTestCA(t, "Default CA")
+	filteredSrv := newCRLServer(t, filtered.crl(t, 1, []x509.RevocationListEntry{
+		revoked(4901, models.ReasonKeyCompromise, now),
+		revoked(4902, models.ReasonSuperseded, now),
+		revoked(4903, models.ReasonCertificateHold, now),
+	}))
+	otherSrv := newCRLServer(t, other.crl(t, 1, []x509.RevocationListEntry{
+		revoked(4951, models.ReasonKeyCompromise, now),
+		revoked(4952, models.ReasonCertificateHold, now),
+	}))
+	for _, url := range []string{filteredSrv.URL, otherSrv.URL} {
+		if err := service.ProcessSingleCRL(ctx, url); err != nil {
+			t.Fatalf("ProcessSingleCRL: %v", err)
+		}
+	}
+
+	// Tras recargar, Default CA también notifica las suspensiones; un archivo inválido
+	// no reemplaza el filtro vigente
+	writeReasonFile(`{"default": [1, 6]}`)
+	if err := service.ReloadWebhookFilter(); err != nil {
+		t.Fatalf("ReloadWebhookFilter: %v", err)
+	}
+	writeReasonFile(`{"default": [42]}`)
+	if err := service.ReloadWebhookFilter(); err == nil {
+		t.Error("ReloadWebhookFilter accepted an unknown reason code")
+	}
+	otherSrv.body = other.crl(t, 2, []x509.RevocationListEntry{
+		revoked(4951, models.ReasonKeyCompromise, now),
+		revoked(4952, models.ReasonCertificateHold, now),
+		revoked(4953, models.ReasonCertificateHold, now),
+		revoked(4954, models.ReasonSuperseded, now),
+	})
+	if err := service.ProcessSingleCRL(ctx, otherSrv.URL); err != nil {
+		t.Fatalf("ProcessSingleCRL: %v", err)
+	}
+	service.Close()
+
+	got := receiver.serials()
+	want := [][]string{{"4901", "4903"}, {"4951"}, {"4953"}}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got notified serials %v, want %v", got, want)
+	}
+}
+
+func TestNewReasonFilter(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("writing %s: %v", name, err)
+		}
+		return path
+	}
+	perCA := writeFile("per-ca.json", `{"cas": {"Quiet CA": [], "Strict CA": [1]}}`)
+
+	filter, err := newReasonFilter([]int{models.ReasonKeyCompromise, models.ReasonCACompromise}, perCA)
+	if err != nil {
+		t.Fatalf("newReasonFilter: %v", err)
+	}
+	tests := []struct {
+		ca     string
+		reason int
+		want   bool
+	}{
+		{"Any CA", models.ReasonKeyCompromise, true},
+		{"Any CA", models.ReasonCACompromise, true},
+		{"Any CA", models.ReasonSuperseded, false},
+		{"Strict CA", models.ReasonKeyCompromise, true},
+		{"Strict CA", models.ReasonCACompromise, false},
+		{"Quiet CA", models.ReasonKeyCompromise, false},
+	}
+	for _, tt := range tests {
+		if got := filter.allows(tt.ca, tt.reason); got != tt.want {
+			t.Errorf("allows(%q, %d) = %v, want %v", tt.ca, tt.reason, got, tt.want)
+		}
+	}
+
+	// Sin códigos configurados se notifica todo
+	if unfiltered, err := newReasonFilter(nil, ""); err != nil || !unfiltered.allows("Any CA", models.ReasonSuperseded) {
+		t.Errorf("filter without codes rejected a revocation (err %v)", err)
+	}
+
+	invalid := []struct {
+		name    string
+		codes   []int
+		content string
+	}{
+		{"unknown global code", []int{99}, ""},
+		{"unknown CA code", nil, `{"cas": {"CA": [7]}}`},
+		{"malformed file", nil, `{"cas": [`},
+	}
+	for _, tt := range invalid {
+		path := ""
+		if tt.content != "" {
+			path = writeFile(strings.ReplaceAll(tt.name, " ", "-")+".json", tt.content)
+		}
+		if _, err := newReasonFilter(tt.codes, path); err == nil {
+			t.Errorf("%s: newReasonFilter accepted an invalid configuration", tt.name)
+		}
+	}
+	if _, err := newReasonFilter(nil, filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("newReasonFilter accepted a missing file")
+	}
+}